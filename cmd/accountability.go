@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/accountability"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var (
+	accountabilityTo     string
+	accountabilityDryRun bool
+)
+
+var accountabilityCmd = &cobra.Command{
+	Use:   "accountability",
+	Short: "Send check-ins to an accountability partner",
+	Long:  "Sends a weekly summary of your habits to an accountability partner by email or to a Matrix room.",
+}
+
+var accountabilitySendCmd = &cobra.Command{
+	Use:   "send",
+	Short: "Send this week's check-in",
+	Long: `Sends a summary of the last seven days of the habits in the accountability_habits
+setting (all scoreable habits when empty) to accountability_to, which is either an
+email address or a Matrix room ID like !abc123:matrix.org.
+
+Email goes through the smtp_host, smtp_port, and smtp_user settings, with the
+password read from HARSH_SMTP_PASSWORD or stored with harsh auth set smtp.
+Matrix messages are posted through matrix_homeserver with the access token in
+HARSH_MATRIX_TOKEN or harsh auth set matrix. Run it weekly from cron to
+automate your check-in.`,
+	Example: `  harsh accountability send --dry-run
+  harsh accountability send --to friend@example.com`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings := harsh.GetSettings()
+		habits, err := accountabilityHabits(settings.String("accountability_habits"))
+		if err != nil {
+			return err
+		}
+		today := clock.Today()
+		message := accountability.Message{
+			Subject: fmt.Sprintf("harsh check-in for the week to %s", today),
+			Body:    ui.WeeklyCheckIn(habits, harsh.GetLog(), today),
+		}
+		// The plan for the week most of the check-in covers
+		if plan, ok := loadPlans().For(today.AddDays(-3)); ok {
+			message.Body += ui.PlanCheckIn(plan, ui.BuildPlanProgress(plan, harsh.GetHabits(), harsh.GetLog(), today))
+		}
+		if accountabilityDryRun {
+			fmt.Printf("Subject: %s\n\n%s", message.Subject, message.Body)
+			return nil
+		}
+
+		to := accountabilityTo
+		if to == "" {
+			to = settings.String("accountability_to")
+		}
+		switch {
+		case to == "":
+			return errors.New("no one to send to, set accountability_to with harsh config set or pass --to")
+		case accountability.IsMatrixRoom(to):
+			err = accountability.SendMatrix(settings.String("matrix_homeserver"), LookupCredential("matrix"), to, message)
+		default:
+			err = accountability.SendEmail(accountability.SMTPServer{
+				Host:     settings.String("smtp_host"),
+				Port:     settings.Int("smtp_port"),
+				User:     settings.String("smtp_user"),
+				Password: LookupCredential("smtp"),
+				From:     settings.String("smtp_from"),
+			}, to, message)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Sent this week's check-in to %s.\n", to)
+		return nil
+	},
+}
+
+// accountabilityHabits returns the habits named in a comma separated list of
+// habit names or fragments, or every habit for an empty list
+func accountabilityHabits(list string) ([]*storage.Habit, error) {
+	if strings.TrimSpace(list) == "" {
+		return harsh.GetHabits(), nil
+	}
+	var habits []*storage.Habit
+	for _, name := range strings.Split(list, ",") {
+		habit, err := harsh.FindHabit(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("accountability_habits: %w", err)
+		}
+		habits = append(habits, habit)
+	}
+	return habits, nil
+}
+
+func init() {
+	accountabilitySendCmd.Flags().StringVar(&accountabilityTo, "to", "", "send to this email address or Matrix room ID instead of accountability_to")
+	accountabilitySendCmd.Flags().BoolVar(&accountabilityDryRun, "dry-run", false, "print the check-in instead of sending it")
+	accountabilityCmd.AddCommand(accountabilitySendCmd)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// aliasGroup lists user-defined aliases apart from harsh's own commands in help
+const aliasGroup = "aliases"
+
+// registerAliases adds a command for each alias set in the config file.
+// Aliases can't replace harsh's own commands or their shorthands.
+func registerAliases(aliases map[string]string) {
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if existing, _, err := RootCmd.Find([]string{name}); err == nil && existing != RootCmd {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring alias %s, harsh already has a %s command\n", name, existing.Name())
+			continue
+		}
+		if !RootCmd.ContainsGroup(aliasGroup) {
+			RootCmd.AddGroup(&cobra.Group{ID: aliasGroup, Title: "Aliases:"})
+		}
+		expansion := splitAlias(aliases[name])
+		RootCmd.AddCommand(&cobra.Command{
+			Use:                name,
+			Short:              "Alias for harsh " + aliases[name],
+			GroupID:            aliasGroup,
+			DisableFlagParsing: true,
+			// Execute expands aliases before parsing, so this only runs when
+			// the alias command is invoked some other way
+			RunE: func(cmd *cobra.Command, args []string) error {
+				RootCmd.SetArgs(append(expansion, args...))
+				return RootCmd.Execute()
+			},
+		})
+	}
+}
+
+// expandAliases replaces the alias in args, if they run one, with the
+// command it stands for, keeping any flags and arguments around it
+func expandAliases(args []string, aliases map[string]string) []string {
+	cmd, _, err := RootCmd.Find(args)
+	if err != nil || cmd.GroupID != aliasGroup {
+		return args
+	}
+	for i, arg := range args {
+		if arg == cmd.Name() {
+			expanded := append([]string{}, args[:i]...)
+			expanded = append(expanded, splitAlias(aliases[arg])...)
+			return append(expanded, args[i+1:]...)
+		}
+	}
+	return args
+}
+
+// splitAlias splits an alias into arguments at spaces, keeping quoted
+// stretches like "Push ups" together
+func splitAlias(expansion string) []string {
+	var args []string
+	var arg strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range expansion {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// loadAliases reads the aliases from the config file, before harsh itself
+// is loaded. A config file with problems is reported once harsh loads it.
+func loadAliases() map[string]string {
+	settings, err := storage.LoadSettings(storage.ConfigDir())
+	if err != nil {
+		return nil
+	}
+	return settings.Aliases()
+}
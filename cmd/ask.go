@@ -1,10 +1,15 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"strings"
 
+	"cloud.google.com/go/civil"
 	"github.com/gookit/color"
 	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
 	"github.com/wakatara/harsh/internal/ui"
 )
 
@@ -16,11 +21,32 @@ var askCmd = &cobra.Command{
 	Aliases:           []string{"a"},
 	Args:              cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if nonInteractive {
+			return withExitCode(ExitNeedsInput, errors.New("ask prompts for answers, use harsh done <habit> [y|n|s] with --non-interactive"))
+		}
+		if err := keepTagged(); err != nil {
+			return err
+		}
 		var habitFragment string
 		if len(args) > 0 {
 			habitFragment = args[0]
 		}
+		// Refuse up front rather than prompting for every habit on a locked day
+		if askDate, err := civil.ParseDate(habitFragment); err == nil {
+			if locked, ok := harsh.GetRepository().(*storage.LockedRepository); ok {
+				if err := locked.CheckLocked(askDate); err != nil {
+					return err
+				}
+			}
+		}
 		input := ui.NewInput(!color.Enable)
+		input.BreakReasons = harsh.GetSettings().Bool("break_reasons")
+		input.Snoozed = loadSnoozed()
+		input.Review = askReview || harsh.GetSettings().Bool("review_answers")
+		if jsonOutput {
+			// Questions go to stderr so only the summary is on stdout
+			input.SetOutput(os.Stderr)
+		}
 		input.AskHabits(
 			harsh.GetHabits(),
 			harsh.GetLog(),
@@ -29,10 +55,39 @@ var askCmd = &cobra.Command{
 			harsh.GetCountBack(),
 			habitFragment,
 		)
+		publishMQTT()
+		syncAfterWrite("ask")
+		display := newDisplay()
+		if jsonOutput {
+			return printJSON(ui.BuildAskSummary(harsh.GetHabits(), harsh.GetLog(), display.Today(), loadSnoozed(), harsh.GetSettings().Bool("xp")))
+		}
+		display.Quotes = loadQuotes()
+		display.ShowQuote(harsh.GetHabits(), harsh.GetLog(), display.Today())
+		if harsh.GetSettings().Bool("xp") {
+			today := display.Today()
+			display.ShowXPGain(ui.BuildXP(harsh.GetHabits(), harsh.GetLog(), today), today)
+		}
 		return nil
 	},
 }
 
+var askReview bool
+
+func init() {
+	askCmd.Flags().BoolVar(&askReview, "review", false, "hold answers until the end of the session to check and amend them before writing")
+	addTagFlag(askCmd)
+}
+
+// loadQuotes returns the user's motivational quotes, warning rather than
+// failing when the quotes file can't be read
+func loadQuotes() *storage.Quotes {
+	quotes, err := storage.LoadQuotes(harsh.GetRepository().GetConfigDir())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return quotes
+}
+
 func askCmdValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
 	out := []cobra.Completion{"yesterday", "yday", "yd", "w", "week", "last-week"}
 	for _, habit := range harsh.GetHabits() {
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/keychain"
+	"golang.org/x/term"
+)
+
+// credential is a secret harsh reads from the environment or the keychain
+type credential struct {
+	// Service names the credential in harsh auth, and its keychain entry
+	Service     string
+	Env         string
+	Description string
+}
+
+var credentials = []credential{
+	{Service: "matrix", Env: "HARSH_MATRIX_TOKEN", Description: "Matrix access token for accountability check-ins"},
+	{Service: "mqtt", Env: "HARSH_MQTT_PASSWORD", Description: "MQTT broker password"},
+	{Service: "serve", Env: "HARSH_SERVE_TOKEN", Description: "bearer token harsh serve requires"},
+	{Service: "signing", Env: "HARSH_SIGNING_KEY", Description: "key log entries are signed with"},
+	{Service: "smtp", Env: "HARSH_SMTP_PASSWORD", Description: "mail server password"},
+}
+
+// findCredential returns the credential for service
+func findCredential(service string) (credential, error) {
+	for _, c := range credentials {
+		if strings.EqualFold(c.Service, service) {
+			return c, nil
+		}
+	}
+	names := make([]string, len(credentials))
+	for i, c := range credentials {
+		names[i] = c.Service
+	}
+	return credential{}, withExitCode(ExitUsage, fmt.Errorf("unknown service %q, choose from %s", service, strings.Join(names, ", ")))
+}
+
+// LookupCredential returns service's secret from its environment variable,
+// or else the keychain or its fallback file, or "" when it's in none
+func LookupCredential(service string) string {
+	c, err := findCredential(service)
+	if err != nil {
+		return ""
+	}
+	if secret := os.Getenv(c.Env); secret != "" {
+		return secret
+	}
+	// A keychain that isn't there just means nothing's stored in it
+	secret, _, _ := keychain.Get(c.Service)
+	return secret
+}
+
+// StoreCredential stores secret for service, returning where it's kept
+func StoreCredential(service string, secret string) (string, error) {
+	c, err := findCredential(service)
+	if err != nil {
+		return "", err
+	}
+	if secret = strings.TrimSpace(secret); secret == "" {
+		return "", withExitCode(ExitUsage, errors.New("no secret given, nothing stored"))
+	}
+	return keychain.Set(c.Service, secret)
+}
+
+// RemoveCredential removes the secret stored for service
+func RemoveCredential(service string) error {
+	c, err := findCredential(service)
+	if err != nil {
+		return err
+	}
+	if err := keychain.Remove(c.Service); err != nil {
+		if errors.Is(err, keychain.ErrNotFound) {
+			return withExitCode(ExitNotFound, fmt.Errorf("no %s is stored", c.Service))
+		}
+		return err
+	}
+	return nil
+}
+
+// storedIn describes where keychain.Get or keychain.Set says a secret is kept
+func storedIn(where string) string {
+	if where == keychain.Keychain {
+		return "the keychain"
+	}
+	return where
+}
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Keep integration passwords and tokens in the OS keychain",
+	Long: `Stores the passwords and tokens harsh's integrations need in your operating
+system's keychain (macOS Keychain, or GNOME Keyring/KWallet through secret-tool)
+instead of shell profiles. Without a keychain, eg. on a server with no
+secret-tool or on Windows, they go in a file only you can read,
+~/.local/share/harsh/credentials, outside the config directory so they're
+never synced or bundled. An environment variable still wins when set.`,
+}
+
+var authSetCmd = &cobra.Command{
+	Use:               "set <service>",
+	Short:             "Store a service's password or token in the keychain",
+	Example:           `  harsh auth set smtp`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: authServiceValidArgs,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := findCredential(args[0])
+		if err != nil {
+			return err
+		}
+		var secret string
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			if nonInteractive {
+				return withExitCode(ExitNeedsInput, errors.New("auth set prompts for the secret, pipe it in with --non-interactive"))
+			}
+			fmt.Printf("%s: ", c.Description)
+			typed, err := term.ReadPassword(int(os.Stdin.Fd()))
+			fmt.Println()
+			if err != nil {
+				return fmt.Errorf("cannot read the secret: %w", err)
+			}
+			secret = string(typed)
+		} else {
+			// Piped in, eg. from a password manager
+			line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			secret = line
+		}
+		where, err := StoreCredential(c.Service, secret)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Stored the %s in %s.\n", c.Description, storedIn(where))
+		return nil
+	},
+}
+
+var authRemoveCmd = &cobra.Command{
+	Use:               "remove <service>",
+	Short:             "Remove a service's password or token from the keychain",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: authServiceValidArgs,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := findCredential(args[0])
+		if err != nil {
+			return err
+		}
+		if err := RemoveCredential(c.Service); err != nil {
+			return err
+		}
+		fmt.Printf("Removed the %s.\n", c.Description)
+		return nil
+	},
+}
+
+var authListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show where each service's password or token comes from",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var keychainErr error
+		for _, c := range credentials {
+			source := "not set"
+			if os.Getenv(c.Env) != "" {
+				source = "environment (" + c.Env + ")"
+			} else if _, where, err := keychain.Get(c.Service); err == nil {
+				source = where
+			} else if !errors.Is(err, keychain.ErrNotFound) {
+				keychainErr = err
+			}
+			fmt.Printf("%-8s %-48s %s\n", c.Service, c.Description, source)
+		}
+		if keychainErr != nil {
+			fmt.Printf("\nThe keychain couldn't be checked: %v\n", keychainErr)
+		}
+		return nil
+	},
+}
+
+func authServiceValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var out []cobra.Completion
+	for _, c := range credentials {
+		out = append(out, cobra.CompletionWithDesc(c.Service, c.Description))
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	authCmd.AddCommand(authSetCmd, authRemoveCmd, authListCmd)
+}
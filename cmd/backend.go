@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var backendCmd = &cobra.Command{
+	Use:   "backend <file|sqlite>",
+	Short: "Move your entries between the log and a SQLite database",
+	Long: `Moves entries and notes to the backend given and switches the backend setting
+to it. Habits stay in the habits file either way.
+
+sqlite copies the log into ` + storage.SQLiteFile + ` in your config directory, to query
+with harsh sql and read ranges of days through harsh serve. Graphs and stats
+still load every entry. The log is left as it was, but isn't read or written
+any more. It needs the sqlite3 command line tool installed.
+
+file writes the database's entries back to the log, normalized, keeping the
+old log as log.bak. ` + storage.SQLiteFile + ` is left for you to remove.`,
+	Example: `  harsh backend sqlite
+  harsh backend file`,
+	Args:         cobra.ExactArgs(1),
+	ValidArgs:    []string{"file", "sqlite"},
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configDir := harsh.GetRepository().GetConfigDir()
+		if configDir == "" {
+			return errors.New("cannot change backend when reading habits or log from a stream")
+		}
+		switch args[0] {
+		case "sqlite":
+			entries, notes, err := storage.MigrateToSQLite(configDir)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Copied %d entries and %d notes into %s.\n", entries, notes, storage.SQLiteFile)
+		case "file":
+			result, err := storage.ExportSQLiteLog(configDir)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Wrote %d entries and %d notes to the log. The old log is in log.bak.\n", result.Entries, result.Notes)
+		default:
+			return withExitCode(ExitUsage, fmt.Errorf(`unknown backend %q, choose "file" or "sqlite"`, args[0]))
+		}
+
+		settings := harsh.GetSettings()
+		if err := settings.Set("backend", args[0]); err != nil {
+			return err
+		}
+		if err := settings.Save(configDir, "backend"); err != nil {
+			return err
+		}
+		fmt.Printf("harsh now keeps entries in the %s backend.\n", args[0])
+		return nil
+	},
+}
+
+// usingSQLite is whether entries are in the database rather than the log
+func usingSQLite() bool {
+	return backend == "sqlite" && habitsFile == "" && logFile == ""
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Pack up or restore your whole harsh setup",
+	Long: `Bundles are a single .tar.gz of everything in your config directory: habits, logs
+(archived ones too), settings, quotes, profiles, and state like challenges and
+snoozes, with a manifest of what's inside and which harsh wrote it. Use them to
+move to a new machine or to attach to a bug report.`,
+}
+
+var bundleExportCmd = &cobra.Command{
+	Use:     "export <file>",
+	Short:   "Write a bundle of your config directory",
+	Example: `  harsh bundle export harsh-backup.tar.gz`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configDir := harsh.GetRepository().GetConfigDir()
+		if configDir == "" {
+			return errors.New("cannot bundle habits or log read from a stream")
+		}
+		var out bytes.Buffer
+		manifest, err := storage.WriteBundle(&out, configDir, storage.BundleManifest{
+			HarshVersion: version,
+			Platform:     runtime.GOOS + "/" + runtime.GOARCH,
+			Created:      clock.Today().String(),
+		})
+		if err != nil {
+			return err
+		}
+		if args[0] == "-" {
+			_, err := os.Stdout.Write(out.Bytes())
+			return err
+		}
+		if err := os.WriteFile(args[0], out.Bytes(), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Bundled %d files into %s.\n", len(manifest.Files), args[0])
+		return nil
+	},
+}
+
+var bundleImportCmd = &cobra.Command{
+	Use:     "import <file>",
+	Short:   "Restore a bundle into your config directory",
+	Long:    "Restores a bundle into your config directory. Files already there with other contents are only replaced with --force.",
+	Example: `  harsh bundle import harsh-backup.tar.gz`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if habitsFile != "" || logFile != "" {
+			return errors.New("cannot import a bundle when reading habits or log from a stream")
+		}
+		configDir := storage.ConfigDir()
+		var in io.Reader = os.Stdin
+		if args[0] != "-" {
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			in = file
+		}
+		manifest, files, err := storage.ReadBundle(in)
+		if err != nil {
+			return err
+		}
+		if conflicts := storage.BundleConflicts(configDir, files); len(conflicts) > 0 && !force {
+			return fmt.Errorf("importing would replace %s in %s, add --force to replace them", strings.Join(conflicts, ", "), configDir)
+		}
+		if err := storage.ExtractBundle(configDir, manifest, files); err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d files from a bundle made by harsh %s on %s.\n", len(manifest.Files), manifest.HarshVersion, manifest.Created)
+		return nil
+	},
+}
+
+func init() {
+	bundleCmd.AddCommand(bundleExportCmd)
+	bundleCmd.AddCommand(bundleImportCmd)
+}
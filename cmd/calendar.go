@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var (
+	calendarMonths  int
+	calendarAmounts bool
+)
+
+var calendarCmd = &cobra.Command{
+	Use:   "calendar [habit]",
+	Short: "Show a habit's days as a calendar heatmap",
+	Long: `Shows a habit's last year as a heatmap like GitHub's contribution graph, a row
+per weekday and a column per week, each day colored by how it went: done, met
+by the habit's target, skipped, broken, or unlogged. --amounts shades done days
+by their amount instead. Without a habit, each day is shaded by its score.
+Weeks with notes are marked with a ^ under the grid, and the notes listed.`,
+	Example: `  harsh calendar gym
+  harsh calendar running --amounts --months 6
+  harsh calendar`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: habitNameValidArgs,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if calendarMonths < 1 {
+			return withExitCode(ExitUsage, errors.New("--months must be at least 1"))
+		}
+		var habit *storage.Habit
+		if len(args) > 0 {
+			found, err := harsh.FindHabit(args[0])
+			if err != nil {
+				return withExitCode(ExitNotFound, err)
+			}
+			habit = found
+		}
+		display := newDisplay()
+		display.Notes = harsh.GetLog().Notes
+		to := display.Today()
+		display.ShowCalendar(habit, harsh.GetHabits(), harsh.GetLog(), to.AddMonths(-calendarMonths).AddDays(1), to, calendarAmounts)
+		return nil
+	},
+}
+
+func init() {
+	calendarCmd.Flags().IntVar(&calendarMonths, "months", 12, "months to show")
+	calendarCmd.Flags().BoolVar(&calendarAmounts, "amounts", false, "shade done days by their amount")
+}
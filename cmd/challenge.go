@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"cloud.google.com/go/civil"
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var (
+	challengeHabit string
+	challengeDays  int
+	challengeStart string
+)
+
+var challengeCmd = &cobra.Command{
+	Use:   "challenge",
+	Short: "Take on time-boxed habit challenges",
+	Long: `Challenges are goals of doing a habit every day for a set number of days, eg. a
+30-day meditation challenge. Running challenges show a progress bar under harsh log
+and stand out in harsh todo. Without a subcommand, lists your challenges.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		challenges, err := storage.LoadChallenges(harsh.GetRepository().GetConfigDir())
+		if err != nil {
+			return err
+		}
+		if len(challenges) == 0 {
+			fmt.Println(`No challenges yet. Start one with harsh challenge start "30-day meditation" --habit Meditate --days 30`)
+			return nil
+		}
+		display := newDisplay()
+		display.ShowChallenges(challenges, harsh.GetLog(), display.Today(), harsh.GetMaxHabitNameLength())
+		return nil
+	},
+}
+
+var challengeStartCmd = &cobra.Command{
+	Use:     "start <name>",
+	Short:   "Start a challenge",
+	Example: `  harsh challenge start "30-day meditation" --habit Meditate --days 30`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configDir, challenges, err := loadChallengesForChange()
+		if err != nil {
+			return err
+		}
+		if _, ok := challenges.Find(args[0]); ok {
+			return fmt.Errorf("there's already a challenge called %q", args[0])
+		}
+		if challengeHabit == "" {
+			return errors.New("say which habit the challenge is for with --habit")
+		}
+		if challengeDays < 1 {
+			return fmt.Errorf("--days must be at least 1, got %d", challengeDays)
+		}
+		habit, err := harsh.FindHabit(challengeHabit)
+		if err != nil {
+			return err
+		}
+		start := clock.Today()
+		if challengeStart != "" {
+			if start, err = civil.ParseDate(challengeStart); err != nil {
+				return fmt.Errorf("invalid --start date %q (expected YYYY-MM-DD)", challengeStart)
+			}
+		}
+
+		challenge := storage.Challenge{Name: args[0], Habit: habit.Name, Start: start, Days: challengeDays}
+		if err := append(challenges, challenge).Save(configDir); err != nil {
+			return err
+		}
+		fmt.Printf("Started %s: %s every day until %s. Good luck!\n", challenge.Name, habit.Name, challenge.End())
+		return nil
+	},
+}
+
+var challengeReportCmd = &cobra.Command{
+	Use:               "report <name>",
+	Short:             "Show how a challenge went",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: challengeValidArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		challenges, err := storage.LoadChallenges(harsh.GetRepository().GetConfigDir())
+		if err != nil {
+			return err
+		}
+		challenge, ok := challenges.Find(args[0])
+		if !ok {
+			return fmt.Errorf("no challenge called %q", args[0])
+		}
+		display := newDisplay()
+		display.ShowChallengeReport(ui.BuildChallengeProgress(challenge, harsh.GetLog(), display.Today()))
+		return nil
+	},
+}
+
+var challengeStopCmd = &cobra.Command{
+	Use:               "stop <name>",
+	Short:             "Remove a challenge",
+	Long:              "Removes a challenge, finished or not. Its habit's log entries are kept.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: challengeValidArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configDir, challenges, err := loadChallengesForChange()
+		if err != nil {
+			return err
+		}
+		challenge, ok := challenges.Find(args[0])
+		if !ok {
+			return fmt.Errorf("no challenge called %q", args[0])
+		}
+		if err := challenges.Remove(challenge.Name).Save(configDir); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s.\n", challenge.Name)
+		return nil
+	},
+}
+
+// loadChallengesForChange returns the config directory and challenges in it,
+// failing when there's no config directory to save changes to
+func loadChallengesForChange() (string, storage.Challenges, error) {
+	configDir := harsh.GetRepository().GetConfigDir()
+	if configDir == "" {
+		return "", nil, errors.New("cannot change challenges when reading habits or log from a stream")
+	}
+	challenges, err := storage.LoadChallenges(configDir)
+	return configDir, challenges, err
+}
+
+// loadChallenges returns the challenges, warning rather than failing when
+// the challenges file can't be read
+func loadChallenges() storage.Challenges {
+	configDir := harsh.GetRepository().GetConfigDir()
+	if configDir == "" {
+		return nil
+	}
+	challenges, err := storage.LoadChallenges(configDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return challenges
+}
+
+func challengeValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	var out []cobra.Completion
+	for _, challenge := range loadChallenges() {
+		out = append(out, challenge.Name)
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	challengeStartCmd.Flags().StringVar(&challengeHabit, "habit", "", "habit to do every day of the challenge")
+	challengeStartCmd.Flags().IntVar(&challengeDays, "days", 30, "how many days the challenge lasts")
+	challengeStartCmd.Flags().StringVar(&challengeStart, "start", "", "first day of the challenge (YYYY-MM-DD), today by default")
+	challengeCmd.AddCommand(challengeStartCmd)
+	challengeCmd.AddCommand(challengeReportCmd)
+	challengeCmd.AddCommand(challengeStopCmd)
+}
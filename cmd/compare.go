@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var compareCmd = &cobra.Command{
+	Use:               "compare <habit> <habit>",
+	Short:             "Compare two habits side by side",
+	Long:              "Shows two habits' graphs, streaks, and completion rates together, plus how likely you are to do one on a day you did the other.",
+	ValidArgsFunction: habitNameValidArgs,
+	Args:              cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := harsh.FindHabit(args[0])
+		if err != nil {
+			return err
+		}
+		b, err := harsh.FindHabit(args[1])
+		if err != nil {
+			return err
+		}
+
+		display := newDisplay()
+		display.ShowComparison(
+			a,
+			b,
+			harsh.GetLog(),
+			harsh.GetCountBack(),
+			harsh.GetMaxHabitNameLength(),
+		)
+		return nil
+	},
+}
+
+func habitNameValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	out := []cobra.Completion{}
+	for _, habit := range harsh.GetHabits() {
+		if strings.Contains(habit.Name, toComplete) {
+			out = append(out, habit.Name)
+		}
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
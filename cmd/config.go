@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "View and change settings in the config file",
+	Long:  "Lists, reads, and changes settings in " + storage.SettingsFile + " in your harsh config directory, validating values as they are set.",
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all settings and their values",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings := harsh.GetSettings()
+		for _, def := range storage.SettingDefinitions {
+			source := "default"
+			if settings.IsSet(def.Key) {
+				source = "set"
+			}
+			fmt.Printf("%-*v = %-10v # %s (%s)\n", settingKeyWidth(), def.Key, settings.String(def.Key), def.Description, source)
+		}
+		aliases := settings.Aliases()
+		names := make([]string, 0, len(aliases))
+		for name := range aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%-*v = %s\n", settingKeyWidth(), storage.AliasPrefix+name, aliases[name])
+		}
+		return nil
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:               "get <key>",
+	Short:             "Print the value of a setting",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: settingKeyValidArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		value, err := harsh.GetSettings().Get(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:               "set <key> <value>",
+	Short:             "Change the value of a setting",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: settingKeyValidArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configDir, err := settingsDir()
+		if err != nil {
+			return err
+		}
+		settings := harsh.GetSettings()
+		if err := settings.Set(args[0], args[1]); err != nil {
+			return err
+		}
+		return settings.Save(configDir, args[0])
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:               "unset <key>",
+	Short:             "Return a setting to its default",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: settingKeyValidArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configDir, err := settingsDir()
+		if err != nil {
+			return err
+		}
+		settings := harsh.GetSettings()
+		if err := settings.Unset(args[0]); err != nil {
+			return err
+		}
+		return settings.Save(configDir, args[0])
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+}
+
+// settingsDir returns the directory the config file is saved in
+func settingsDir() (string, error) {
+	configDir := harsh.GetRepository().GetConfigDir()
+	if configDir == "" {
+		return "", errors.New("settings cannot be changed when reading habits or log from a stream")
+	}
+	return configDir, nil
+}
+
+func settingKeyWidth() int {
+	width := 0
+	for _, def := range storage.SettingDefinitions {
+		width = max(width, len(def.Key))
+	}
+	return width
+}
+
+func settingKeyValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		out := []cobra.Completion{}
+		for _, def := range storage.SettingDefinitions {
+			out = append(out, def.Key)
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp
+	}
+	if len(args) == 1 && cmd.Name() == "set" {
+		if def, err := storage.SettingDefinitionFor(args[0]); err == nil {
+			return def.Choices, cobra.ShellCompDirectiveNoFileComp
+		}
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var correlateCmd = &cobra.Command{
+	Use:   "correlate",
+	Short: "Relate your habits to what you track",
+}
+
+var correlateScoreCmd = &cobra.Command{
+	Use:   "score <tracking-habit>",
+	Short: "Plot your daily score against a tracking habit's amounts",
+	Long: `Plots each day's score against the amount logged for a tracking habit, eg. hours
+of sleep, and measures how closely they move together. The habit itself is left
+out of the score.`,
+	Example:           `  harsh correlate score Sleep`,
+	ValidArgsFunction: habitNameValidArgs,
+	Args:              cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		habit, err := harsh.FindHabit(args[0])
+		if err != nil {
+			return err
+		}
+		display := newDisplay()
+		display.ShowScoreCorrelation(ui.BuildScoreCorrelation(habit, harsh.GetHabits(), harsh.GetLog(), display.Today()))
+		return nil
+	},
+}
+
+func init() {
+	correlateCmd.AddCommand(correlateScoreCmd)
+}
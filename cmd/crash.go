@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// CrashReportFile is the file in a crash bundle describing the crash
+const CrashReportFile = "crash.txt"
+
+// recoverCrash turns a panic into an error saying where a diagnostics bundle
+// of the crash was written, so a bug doesn't end in a wall of goroutines.
+// Deferred by Execute, it sets *err to that error.
+func recoverCrash(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	report := crashReport(r, debug.Stack())
+	path, bundleErr := writeCrashBundle(report)
+	message := fmt.Sprintf("harsh crashed: %v", r)
+	if bundleErr != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", report)
+		message += fmt.Sprintf(" (and couldn't write a diagnostics bundle: %v)", bundleErr)
+	} else {
+		message += fmt.Sprintf("\nA diagnostics bundle with your habits, log, settings and what harsh was doing is at %s.\nPlease attach it to a bug report, after checking there's nothing in it you'd rather keep private.", path)
+	}
+	fmt.Fprintf(os.Stderr, "Error: %s\n", message)
+	*err = errors.New(message)
+}
+
+// crashReport describes a crash: what was run, where, and the stack it died with
+func crashReport(r any, stack []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "panic: %v\n\n", r)
+	fmt.Fprintf(&b, "harsh %s on %s/%s (%s)\n", version, runtime.GOOS, runtime.GOARCH, runtime.Version())
+	fmt.Fprintf(&b, "command: harsh %s\n", strings.Join(commandArgs, " "))
+	fmt.Fprintf(&b, "today: %s\n", clock.Today())
+	if configDir := crashConfigDir(); configDir != "" {
+		fmt.Fprintf(&b, "config directory: %s\n", configDir)
+	}
+	fmt.Fprintf(&b, "\n%s", stack)
+	return b.String()
+}
+
+// crashConfigDir is the config directory in use, empty before it's known or
+// when reading habits and log from streams
+func crashConfigDir() string {
+	if harsh == nil || harsh.GetRepository() == nil {
+		return ""
+	}
+	return harsh.GetRepository().GetConfigDir()
+}
+
+// crashFiles are the config directory files a crash bundle takes along,
+// enough to reproduce most crashes. Anything else, keys especially, stays put.
+var crashFiles = []string{"habits", "log", storage.SettingsFile}
+
+// writeCrashBundle writes a bundle of the report and crashFiles to the
+// temporary directory, returning its path
+func writeCrashBundle(report string) (string, error) {
+	files := map[string][]byte{CrashReportFile: []byte(report)}
+	if configDir := crashConfigDir(); configDir != "" {
+		for _, name := range crashFiles {
+			if data, err := os.ReadFile(filepath.Join(configDir, name)); err == nil {
+				files[name] = data
+			}
+		}
+	}
+	file, err := os.CreateTemp("", "harsh-crash-"+time.Now().Format("20060102-150405")+"-*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	_, err = storage.WriteBundleWith(file, "", storage.BundleManifest{
+		HarshVersion: version,
+		Platform:     runtime.GOOS + "/" + runtime.GOARCH,
+		Created:      clock.Today().String(),
+	}, files)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
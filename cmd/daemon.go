@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var daemonOnce bool
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Fill in unanswered habits at the end of each day",
+	Long: `Runs in the background and, every day at the autofill_at setting (23:30 unless
+set), logs habits you haven't answered yet as not done, keeping your log free of
+gaps on days you forget. Entries it writes are commented "[auto]". Set
+autofill_notify to get a desktop notification listing them instead, and add
+autofill=no after a habit's frequency to leave it alone.
+
+With --once it fills in today straight away and exits, for running from cron or
+a systemd timer instead.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if harsh.GetRepository().GetConfigDir() == "" {
+			return errors.New("the daemon rereads your config directory, so it can't be used with --habits-file or --log-file")
+		}
+		at := harsh.GetSettings().String("autofill_at")
+		if daemonOnce {
+			return autofillToday()
+		}
+		for {
+			next, err := nextAutofill(time.Now(), at)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Next autofill at %s\n", next.Format("2006-01-02 15:04"))
+			time.Sleep(time.Until(next))
+
+			// Pick up everything logged since the daemon started
+			if err := reloadHarsh(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v, filling in from what was read before\n", err)
+			}
+			at = harsh.GetSettings().String("autofill_at")
+			if err := autofillToday(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+		}
+	},
+}
+
+// nextAutofill returns the first time after now at the time of day at (HH:MM)
+func nextAutofill(now time.Time, at string) (time.Time, error) {
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("autofill_at must be a time of day like 21:30, got %q", at)
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// autofillToday logs today's unanswered habits as not done, or notifies
+// about them when the autofill_notify setting is on
+func autofillToday() error {
+	today := clock.Today()
+	if harsh.GetSettings().Bool("autofill_notify") {
+		unanswered := ui.Unanswered(harsh.GetHabits(), harsh.GetLog(), today, loadSnoozed())
+		if len(unanswered) == 0 {
+			return nil
+		}
+		names := make([]string, len(unanswered))
+		for i, habit := range unanswered {
+			names[i] = habit.Name
+		}
+		fmt.Printf("%s: still unanswered: %s\n", today, strings.Join(names, ", "))
+		return notify("harsh: habits left to log", strings.Join(names, ", "))
+	}
+
+	filled, err := ui.Autofill(harsh.GetHabits(), harsh.GetLog(), harsh.GetRepository(), today, loadSnoozed())
+	if len(filled) > 0 {
+		fmt.Printf("%s: logged as not done: %s\n", today, strings.Join(filled, ", "))
+	}
+	return err
+}
+
+// notify shows a desktop notification with notify-send on Linux and the BSDs,
+// or osascript on macOS
+func notify(title string, body string) error {
+	var command *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", body, title)
+		command = exec.Command("osascript", "-e", script)
+	default:
+		command = exec.Command("notify-send", title, body)
+	}
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("cannot send notification with %s: %w", command.Path, err)
+	}
+	return nil
+}
+
+func init() {
+	daemonCmd.Flags().BoolVar(&daemonOnce, "once", false, "fill in today's unanswered habits now and exit")
+}
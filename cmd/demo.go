@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/sample"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var (
+	demoMonths int
+	demoFresh  bool
+)
+
+// demoSeed makes up the same demo data every time
+const demoSeed = 1
+
+var demoCmd = &cobra.Command{
+	Use:   "demo [command...]",
+	Short: "Try harsh out on months of made-up habit data",
+	Long: `Sets up a temporary profile with a dozen habits and several months of made-up
+but plausible history, with good and bad spells, skips, a holiday, comments,
+amounts and notes, then runs a harsh command on it. Your own habits and log
+aren't touched.
+
+With no command the demo shows harsh log. The demo profile is kept between
+runs so you can try one command after another, and made up again each new day
+with history up to yesterday, leaving today to ask about. --fresh makes it up
+again now.`,
+	Example: `  harsh demo
+  harsh demo log stats
+  harsh demo ask
+  harsh demo --months 12 log --week`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if demoMonths < 1 {
+			return withExitCode(ExitUsage, errors.New("--months must be at least 1"))
+		}
+		dir := filepath.Join(os.TempDir(), fmt.Sprintf("harsh-demo-%d", os.Getuid()))
+		if err := storage.PrivateDir(dir); err != nil {
+			return fmt.Errorf("cannot use the demo profile: %w", err)
+		}
+		yesterday := clock.Today().AddDays(-1)
+		// The demo file records the last day made up, to start afresh on a new day
+		made, _ := os.ReadFile(filepath.Join(dir, "demo"))
+		if demoFresh || cmd.Flags().Changed("months") || string(made) != yesterday.String() {
+			if err := os.RemoveAll(dir); err != nil {
+				return fmt.Errorf("cannot clear the demo profile: %w", err)
+			}
+			// Made again straight away, before anyone else can make it
+			if err := storage.PrivateDir(dir); err != nil {
+				return fmt.Errorf("cannot use the demo profile: %w", err)
+			}
+			habits := sample.Habits(13)
+			if err := sample.Write(dir, habits, yesterday.AddMonths(-demoMonths), yesterday, demoSeed); err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(dir, "demo"), []byte(yesterday.String()), 0644); err != nil {
+				return fmt.Errorf("cannot write the demo profile: %w", err)
+			}
+			fmt.Printf("Made up %d months of history for %d habits in %s\n\n", demoMonths, len(habits), dir)
+		}
+
+		if len(args) == 0 {
+			args = []string{"log"}
+		}
+		if today != "" {
+			args = append([]string{"--today", today}, args...)
+		}
+		executable, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("cannot find harsh to run the demo: %w", err)
+		}
+		command := exec.Command(executable, args...)
+		command.Env = append(os.Environ(), "HARSHPATH="+dir)
+		command.Stdin, command.Stdout, command.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := command.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				os.Exit(exitErr.ExitCode())
+			}
+			return err
+		}
+
+		fmt.Println()
+		fmt.Println("Try harsh demo log stats, harsh demo todo, or harsh demo ask next.")
+		fmt.Printf("Or point any harsh command at the demo: HARSHPATH=%s harsh ...\n", dir)
+		fmt.Println("When you're ready for your own habits, run harsh and edit the habits file it makes.")
+		return nil
+	},
+}
+
+func init() {
+	demoCmd.Flags().IntVar(&demoMonths, "months", 6, "months of history to make up")
+	demoCmd.Flags().BoolVar(&demoFresh, "fresh", false, "make up the demo data again")
+	// Flags after the command are the command's own
+	demoCmd.Flags().SetInterspersed(false)
+}
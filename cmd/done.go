@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var (
+	doneDate    string
+	doneComment string
+	doneAmount  string
+)
+
+var doneCmd = &cobra.Command{
+	Use:   "done <habit> [y|n|s|o]",
+	Short: "Record one habit without prompting",
+	Long:  "Records a single habit (done unless n or s is given, or o for its fallback) for today or --date, without ever prompting. Meant for scripts, SSH and phone automations such as Apple Shortcuts.",
+	Example: `  harsh done Meditated
+  harsh done gym s --comment "travelling"
+  harsh done "Push ups" --amount 40 --date 2025-03-10`,
+	Aliases:           []string{"d"},
+	Args:              cobra.RangeArgs(1, 2),
+	SilenceUsage:      true,
+	ValidArgsFunction: doneCmdValidArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result := "y"
+		if len(args) > 1 {
+			result = args[1]
+		}
+		return recordEntry(args[0], result, doneDate, doneComment, doneAmount)
+	},
+}
+
+// recordEntry records result for the habit matching query on date, or today
+// when it's empty, for harsh done and harsh log <habit> <result>
+func recordEntry(query, result, date, comment, amount string) error {
+	key, outcome, err := writeEntry(query, result, date, comment, amount)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Recorded %s: %s for %s.\n", key.Habit, outcome.Result, key.Day)
+	publishMQTT()
+	syncAfterWrite("done")
+	return nil
+}
+
+// writeEntry checks and writes an entry as recordEntry does, without
+// printing, returning what was written
+func writeEntry(query, result, date, comment, amount string) (storage.DailyHabit, storage.Outcome, error) {
+	var key storage.DailyHabit
+	var outcome storage.Outcome
+	if result != "y" && result != "n" && result != "s" && result != "o" {
+		return key, outcome, withExitCode(ExitUsage, fmt.Errorf(`invalid result "%s". should be "y", "n", "s" or "o"`, result))
+	}
+	day := clock.Today()
+	if date != "" {
+		d, err := civil.ParseDate(date)
+		if err != nil {
+			return key, outcome, withExitCode(ExitUsage, fmt.Errorf("invalid --date %q (expected YYYY-MM-DD)", date))
+		}
+		day = d
+	}
+	if amount != "" {
+		if _, err := strconv.ParseFloat(amount, 64); err != nil {
+			return key, outcome, withExitCode(ExitUsage, fmt.Errorf("invalid --amount %q", amount))
+		}
+	}
+	habit, err := harsh.FindHabit(query)
+	if err != nil {
+		return key, outcome, withExitCode(ExitNotFound, err)
+	}
+
+	// Sanitize : colons out of the comment as ask does
+	comment = strings.TrimSpace(strings.ReplaceAll(comment, ":", ""))
+	if result == "o" {
+		if habit.Fallback == "" {
+			return key, outcome, withExitCode(ExitUsage, fmt.Errorf(`%s has no fallback, add one in your habits file like "%s | or: <easier version>: %s"`, habit.Name, habit.Name, habit.Frequency))
+		}
+		result, comment = "y", storage.WithFallback(comment)
+	}
+	if err := harsh.GetRepository().WriteEntry(day, habit.Name, result, comment, amount, harsh.GetLog().Header); err != nil {
+		return key, outcome, err
+	}
+	value, _ := strconv.ParseFloat(amount, 64)
+	key, outcome = storage.DailyHabit{Day: day, Habit: habit.Name}, storage.Outcome{Result: result, Comment: comment, Amount: value}
+	harsh.GetLog().Record(key, outcome)
+	return key, outcome, nil
+}
+
+func init() {
+	doneCmd.Flags().StringVar(&doneDate, "date", "", "record for this date (YYYY-MM-DD) instead of today")
+	doneCmd.Flags().StringVarP(&doneComment, "comment", "c", "", "comment to record with the answer")
+	doneCmd.Flags().StringVarP(&doneAmount, "amount", "a", "", "amount to record with the answer, eg. minutes or reps")
+}
+
+func doneCmdValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) == 1 {
+		return []cobra.Completion{"y", "n", "s", "o"}, cobra.ShellCompDirectiveNoFileComp
+	}
+	var out []cobra.Completion
+	for _, habit := range harsh.GetHabits() {
+		if strings.Contains(habit.Name, toComplete) {
+			out = append(out, habit.Name)
+		}
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var (
+	editResult  string
+	editComment string
+	editAmount  string
+)
+
+var editCmd = &cobra.Command{
+	Use:   "edit <habit> <date>",
+	Short: "Change an entry already logged, in place",
+	Long: `Changes the result, comment, or amount of a habit's entry for a day, rewriting
+its line in the log rather than adding another below. Fields without a flag are
+kept, and with no flags at all it asks for each one, Enter keeping what's there.
+--comment "" and --amount "" clear them.`,
+	Example: `  harsh edit Gym 2026-10-12 --result s --comment "sick"
+  harsh edit "Push ups" 2026-10-14 --amount 45
+  harsh edit gym 2026-10-12`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: habitNameValidArgs,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		habit, err := harsh.FindHabit(args[0])
+		if err != nil {
+			return withExitCode(ExitNotFound, err)
+		}
+		day, err := civil.ParseDate(args[1])
+		if err != nil {
+			return withExitCode(ExitUsage, fmt.Errorf("invalid date %q (expected YYYY-MM-DD)", args[1]))
+		}
+		key := storage.DailyHabit{Day: day, Habit: habit.Name}
+		outcome, ok := harsh.GetLog().Entries[key]
+		if !ok {
+			return withExitCode(ExitNotFound, fmt.Errorf("%s has nothing logged on %s, record it with harsh done %q --date %s", habit.Name, day, habit.Name, day))
+		}
+
+		result, comment, amount := outcome.Result, outcome.Comment, ""
+		if outcome.Amount != 0 {
+			amount = strconv.FormatFloat(outcome.Amount, 'f', -1, 64)
+		}
+		flags := cmd.Flags()
+		if flags.Changed("result") || flags.Changed("comment") || flags.Changed("amount") {
+			if flags.Changed("result") {
+				result = editResult
+			}
+			if flags.Changed("comment") {
+				comment = editComment
+			}
+			if flags.Changed("amount") {
+				amount = editAmount
+			}
+		} else {
+			if nonInteractive {
+				return withExitCode(ExitNeedsInput, errors.New("edit asks what to change without --result, --comment, or --amount"))
+			}
+			reader := bufio.NewReader(os.Stdin)
+			fmt.Printf("%s on %s\n", habit.Name, day)
+			result = promptField(reader, "Result (y/n/s)", result)
+			comment = promptField(reader, "Comment", comment)
+			amount = promptField(reader, "Amount", amount)
+		}
+
+		if result != "y" && result != "n" && result != "s" {
+			return withExitCode(ExitUsage, fmt.Errorf(`invalid result "%s". should be "y", "n" or "s"`, result))
+		}
+		amount = strings.TrimSpace(amount)
+		if amount != "" {
+			if _, err := strconv.ParseFloat(amount, 64); err != nil {
+				return withExitCode(ExitUsage, fmt.Errorf("invalid amount %q", amount))
+			}
+		}
+		// Sanitize : colons out of the comment as ask does
+		comment = strings.TrimSpace(strings.ReplaceAll(comment, ":", ""))
+
+		value, _ := strconv.ParseFloat(amount, 64)
+		edited := storage.Outcome{Result: result, Comment: comment, Amount: value}
+		if edited == outcome {
+			fmt.Println("Nothing changed.")
+			return nil
+		}
+		if err := harsh.GetRepository().UpdateEntry(day, habit.Name, result, comment, amount, harsh.GetLog().Header); err != nil {
+			if errors.Is(err, storage.ErrNoEntry) {
+				// Read from an archived log, which stays as it was
+				return withExitCode(ExitNotFound, fmt.Errorf("%w, archived entries can't be edited", err))
+			}
+			return err
+		}
+		harsh.GetLog().Record(key, edited)
+		fmt.Printf("Changed %s on %s to %s.\n", habit.Name, day, describeOutcome(edited))
+		publishMQTT()
+		syncAfterWrite("edit")
+		return nil
+	},
+}
+
+// promptField asks for a field, keeping current when the answer is blank
+func promptField(reader *bufio.Reader, name string, current string) string {
+	fmt.Printf("%s [%s]: ", name, current)
+	answer, _ := reader.ReadString('\n')
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return current
+	}
+	return answer
+}
+
+// describeOutcome sums an entry up in a line, eg. "y, sick, 45"
+func describeOutcome(outcome storage.Outcome) string {
+	parts := []string{outcome.Result}
+	if outcome.Comment != "" {
+		parts = append(parts, outcome.Comment)
+	}
+	if outcome.Amount != 0 {
+		parts = append(parts, strconv.FormatFloat(outcome.Amount, 'f', -1, 64))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func init() {
+	editCmd.Flags().StringVarP(&editResult, "result", "r", "", "the new result, y, n or s")
+	editCmd.Flags().StringVarP(&editComment, "comment", "c", "", "the new comment")
+	editCmd.Flags().StringVarP(&editAmount, "amount", "a", "", "the new amount, eg. minutes or reps")
+}
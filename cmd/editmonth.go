@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"cloud.google.com/go/civil"
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+	"golang.org/x/term"
+)
+
+var editMonthCmd = &cobra.Command{
+	Use:   "edit-month <habit> <YYYY-MM>",
+	Short: "Correct a habit's month in a grid",
+	Long: `Shows a habit's month as a calendar grid to correct many days at once. Type y, n,
+or s to set the day under the cursor and move on, space to cycle it, and u to
+undo. Arrow keys or hjkl move around. Nothing is written until you save with w,
+when every change is logged together. q quits without saving.`,
+	Example:           `  harsh edit-month gym 2025-03`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: habitNameValidArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if nonInteractive {
+			return withExitCode(ExitNeedsInput, errors.New("edit-month is interactive, use harsh done <habit> [y|n|s] --date with --non-interactive"))
+		}
+		habit, err := harsh.FindHabit(args[0])
+		if err != nil {
+			return err
+		}
+		month, err := civil.ParseDate(args[1] + "-01")
+		if err != nil {
+			return withExitCode(ExitUsage, fmt.Errorf("invalid month %q (expected YYYY-MM)", args[1]))
+		}
+		today := newDisplay().Today()
+		editor := ui.NewMonthEditor(habit, month, harsh.GetLog(), today)
+		if editor.Days() == 0 {
+			return fmt.Errorf("%s hasn't started yet", args[1])
+		}
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return errors.New("edit-month needs a terminal to read keys from")
+		}
+
+		state, err := term.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			return fmt.Errorf("cannot read keys from the terminal: %w", err)
+		}
+		renderer := ui.NewRenderer(os.Stdout)
+		action := ui.MonthEditorContinue
+		for action == ui.MonthEditorContinue {
+			if err = renderer.Render(editor.Render()); err != nil {
+				break
+			}
+			var key string
+			if key, err = ui.ReadKey(os.Stdin); err != nil {
+				break
+			}
+			action = editor.HandleKey(key)
+		}
+		term.Restore(int(os.Stdin.Fd()), state)
+		if err != nil {
+			return err
+		}
+		if action == ui.MonthEditorQuit {
+			fmt.Println("Quit without saving.")
+			return nil
+		}
+
+		changes := editor.Changes()
+		writes := make([]storage.EntryWrite, len(changes))
+		for i, change := range changes {
+			writes[i] = storage.OutcomeWrite(storage.DailyHabit{Day: change.Day, Habit: habit.Name}, change.Outcome)
+		}
+		if err := storage.WriteEntries(harsh.GetRepository(), writes, harsh.GetLog().Header); err != nil {
+			return err
+		}
+		fmt.Printf("Saved %d changes to %s.\n", len(changes), habit.Name)
+		return nil
+	},
+}
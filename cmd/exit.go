@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Exit codes harsh returns, stable so scripts and phone automations can
+// branch on them
+const (
+	ExitOK         = 0
+	ExitError      = 1
+	ExitUsage      = 2 // bad flags or arguments
+	ExitNotFound   = 3 // the habit given matched none or several habits
+	ExitNeedsInput = 4 // a prompt was needed under --non-interactive
+)
+
+// exitError is an error carrying the exit code harsh should return for it
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// ExitCode returns the exit code for an error returned by Execute
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exit *exitError
+	if errors.As(err, &exit) {
+		return exit.code
+	}
+	// cobra reports unknown commands without a typed error
+	if strings.HasPrefix(err.Error(), "unknown command") {
+		return ExitUsage
+	}
+	return ExitError
+}
+
+// usageErrors makes argument errors of cmd and its subcommands exit with
+// ExitUsage
+func usageErrors(cmd *cobra.Command) {
+	if args := cmd.Args; args != nil {
+		cmd.Args = func(cmd *cobra.Command, a []string) error {
+			return withExitCode(ExitUsage, args(cmd, a))
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		usageErrors(sub)
+	}
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var (
+	exportFormat string
+	exportOutput string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Export habits to other tools' formats",
+	Long: `Exports your habits and their history for use in other tools. The org format
+produces org-mode habit entries that org-agenda shows in its consistency graph.
+The png format draws today's score and todos onto a small image, to set as a
+lock screen or widget wallpaper or push to an e-ink display. Files ending in
+.png are written as png without needing --format.`,
+	Example: `  harsh export -o habits.org
+  harsh export status.png`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output := exportOutput
+		if len(args) == 1 {
+			if output != "" {
+				return withExitCode(ExitUsage, fmt.Errorf("give the file either as an argument or with --output, not both"))
+			}
+			output = args[0]
+		}
+		format := exportFormat
+		if !cmd.Flags().Changed("format") && filepath.Ext(output) == ".png" {
+			format = "png"
+		}
+
+		var out bytes.Buffer
+		switch format {
+		case "org":
+			out.WriteString(ui.BuildOrgHabits(harsh.GetHabits(), harsh.GetLog(), clock.Today()))
+		case "png":
+			status := ui.BuildStatus(harsh.GetHabits(), harsh.GetLog(), clock.Today(), loadSnoozed())
+			if err := status.PNG(&out); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf(`invalid format "%s". should be "org" or "png"`, format)
+		}
+
+		if output == "" || output == "-" {
+			_, err := os.Stdout.Write(out.Bytes())
+			return err
+		}
+		return os.WriteFile(output, out.Bytes(), 0644)
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "org", `export format, "org" or "png"`)
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "write the export to a file instead of stdout")
+	exportCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		return []cobra.Completion{"org", "png"}, cobra.ShellCompDirectiveNoFileComp
+	})
+}
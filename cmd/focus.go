@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var focusCount int
+
+var focusCmd = &cobra.Command{
+	Use:   "focus",
+	Short: "Show the three of today's todos closest to breaking",
+	Long: `Picks the few habits on today's todo list that are closest to breaking if left
+undone, for when the whole list is too much. The days each has left are
+weighed by its priority=N option, so a priority=2 habit breaking in two days
+ranks with one breaking today, and ties go to the longer streak. Snoozed habits
+and those that won't break within their interval are left out.`,
+	Example: `  harsh focus
+  harsh focus -n 1
+  harsh focus --json`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if focusCount < 1 {
+			return withExitCode(ExitUsage, errors.New("--count must be at least 1"))
+		}
+		display := newDisplay()
+		focus := ui.BuildFocus(harsh.GetHabits(), harsh.GetLog(), display.Today(), loadSnoozed(), focusCount)
+		if jsonOutput {
+			return printJSON(focus)
+		}
+		display.ShowFocus(focus)
+		return nil
+	},
+}
+
+func init() {
+	focusCmd.Flags().IntVarP(&focusCount, "count", "n", ui.FocusCount, "how many habits to pick")
+}
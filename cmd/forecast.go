@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var (
+	forecastDays int
+	forecastAll  bool
+)
+
+var forecastCmd = &cobra.Command{
+	Use:   "forecast [habit-fragment]",
+	Short: "Show which habits come due or break in the next two weeks",
+	Long: `Looks ahead at the habits kept over several days, like 3/7 or weekly ones, and
+shows the days they'd come due (their warning) and break on if nothing more is
+logged, as a small calendar. Daily habits are left out, as they break the first
+day they're missed.`,
+	Example: `  harsh forecast
+  harsh forecast gym --days 7
+  harsh forecast --json`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if forecastDays < 1 {
+			return withExitCode(ExitUsage, errors.New("--days must be at least 1"))
+		}
+		var habitFragment string
+		if len(args) > 0 {
+			habitFragment = args[0]
+		}
+		display := newDisplay()
+		display.ShowHidden = forecastAll
+		forecast := display.BuildForecast(harsh.GetHabits(), harsh.GetLog(), display.Today(), forecastDays, habitFragment)
+		if jsonOutput {
+			return printJSON(forecast)
+		}
+		display.ShowForecast(forecast)
+		return nil
+	},
+}
+
+func init() {
+	forecastCmd.Flags().IntVar(&forecastDays, "days", ui.ForecastDays, "days to look ahead")
+	forecastCmd.Flags().BoolVar(&forecastAll, "all", false, "include habits marked hidden=yes")
+}
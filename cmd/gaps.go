@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var gapsHabit string
+
+var gapsCmd = &cobra.Command{
+	Use:   "gaps",
+	Short: "List stretches of days with nothing logged",
+	Long: `Lists, for each habit, the stretches of days since its first entry with no entry
+at all, as opposed to days logged as not done, to tell periods you forgot to
+log from periods you didn't do the habit. Today is left out, as it's still
+open. Fill gaps in with harsh ask <date> or harsh edit-month.`,
+	Example: `  harsh gaps
+  harsh gaps --habit gym`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		display := newDisplay()
+		display.ShowGaps(harsh.GetHabits(), harsh.GetLog(), display.Today().AddDays(-1), gapsHabit)
+		return nil
+	},
+}
+
+func init() {
+	gapsCmd.Flags().StringVar(&gapsHabit, "habit", "", "only list gaps of habits whose names contain this")
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/sample"
+)
+
+var (
+	genHabits int
+	genYears  int
+	genSeed   int64
+)
+
+var genCmd = &cobra.Command{
+	Use:   "gen <dir>",
+	Short: "Write a large made-up habits file and log for load testing",
+	Long: `Writes a habits file and a log of made-up history up to today into dir, for
+checking how harsh copes at scale. The same seed always makes the same data,
+so timings before and after a change compare like with like. dir mustn't
+have a habits file or log already.
+
+Time commands against it with HARSHPATH, or point the benchmarks in test/ at
+it with HARSH_BENCH_DIR.`,
+	Example: `  harsh gen /tmp/harsh-load --habits 50 --years 10
+  time HARSHPATH=/tmp/harsh-load harsh log
+  HARSH_BENCH_DIR=/tmp/harsh-load go test ./test -bench Generated`,
+	Args:         cobra.ExactArgs(1),
+	Hidden:       true,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if genHabits < 1 || genYears < 1 {
+			return withExitCode(ExitUsage, errors.New("--habits and --years must be at least 1"))
+		}
+		to := clock.Today()
+		habits := sample.Habits(genHabits)
+		if err := sample.Write(args[0], habits, to.AddYears(-genYears), to, genSeed); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %d habits and %d years of log to %s\n", len(habits), genYears, args[0])
+		return nil
+	},
+}
+
+func init() {
+	genCmd.Flags().IntVar(&genHabits, "habits", 50, "number of habits")
+	genCmd.Flags().IntVar(&genYears, "years", 10, "years of history")
+	genCmd.Flags().Int64Var(&genSeed, "seed", 1, "seed for the made-up data")
+}
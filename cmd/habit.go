@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var (
+	retuneSince  string
+	retuneYes    bool
+	retuneDryRun bool
+)
+
+var habitCmd = &cobra.Command{
+	Use:   "habit",
+	Short: "Change habits in your habits file",
+}
+
+var habitRetuneCmd = &cobra.Command{
+	Use:   "retune <habit> <frequency>",
+	Short: "Change a habit's frequency, previewing what it does to its stats",
+	Long: `Changes a habit's frequency in your habits file and records the day it changed
+in the frequencies file, so days before then keep being judged by the old one.
+
+Before changing anything it shows the habit's current and longest streaks and
+its stats as they are and as they'll be, and asks to go ahead. --since backdates
+the change, for a frequency you've been keeping to for a while already.`,
+	Example: `  harsh habit retune Gym 3/7
+  harsh habit retune Gym 2/7 --since 2026-09-01 --dry-run
+  harsh habit retune "Call mum" 1w --yes`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: habitNameValidArgs,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		habit, err := harsh.FindHabit(args[0])
+		if err != nil {
+			return withExitCode(ExitNotFound, err)
+		}
+		frequency := strings.TrimSpace(args[1])
+		if frequency == habit.Frequency {
+			return withExitCode(ExitUsage, fmt.Errorf("%s is already %s", habit.Name, frequency))
+		}
+
+		display := newDisplay()
+		since := display.Today()
+		if retuneSince != "" {
+			if since, err = civil.ParseDate(retuneSince); err != nil {
+				return withExitCode(ExitUsage, fmt.Errorf("cannot read --since date %q, use YYYY-MM-DD", retuneSince))
+			}
+			if since.After(display.Today()) {
+				return withExitCode(ExitUsage, errors.New("cannot retune a habit from a day still to come"))
+			}
+		}
+		retuned, err := habit.Retuned(frequency, since)
+		if err != nil {
+			return withExitCode(ExitUsage, fmt.Errorf("frequency %v", err))
+		}
+
+		entries := harsh.GetLog()
+		display.ShowRetunePreview(ui.BuildRetunePreview(habit, retuned, since, entries, display.Today()))
+		if retuneDryRun {
+			return nil
+		}
+
+		configDir := harsh.GetRepository().GetConfigDir()
+		if configDir == "" {
+			return errors.New("cannot retune a habit when reading habits or log from a stream")
+		}
+		if !retuneYes {
+			if nonInteractive {
+				return withExitCode(ExitNeedsInput, errors.New("retune asks before changing the habit, add --yes with --non-interactive"))
+			}
+			fmt.Printf("Retune %s to %s? [y/N] ", habit.Name, frequency)
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+				fmt.Println("Left as it was.")
+				return nil
+			}
+		}
+
+		habitsFile, err := storage.LoadHabitsFile(configDir)
+		if err != nil {
+			return err
+		}
+		if err := habitsFile.SetFrequency(habit, frequency); err != nil {
+			return err
+		}
+		if err := habitsFile.Save(); err != nil {
+			return err
+		}
+		// Recorded now, so it keeps its since date rather than being picked
+		// up as a change on the next run
+		change := storage.FrequencyChange{Since: since, Habit: habit.Name, Frequency: frequency}
+		if err := storage.RecordFrequencyChanges(configDir, []storage.FrequencyChange{change}); err != nil {
+			return err
+		}
+		fmt.Printf("%s is now %s from %s.\n", habit.Name, frequency, since)
+		return nil
+	},
+}
+
+func init() {
+	habitRetuneCmd.Flags().StringVar(&retuneSince, "since", "", "the day the new frequency starts, YYYY-MM-DD (default today)")
+	habitRetuneCmd.Flags().BoolVarP(&retuneYes, "yes", "y", false, "change the habit without asking")
+	habitRetuneCmd.Flags().BoolVar(&retuneDryRun, "dry-run", false, "show the preview without changing the habit")
+	habitCmd.AddCommand(habitRetuneCmd)
+}
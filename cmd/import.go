@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var (
+	importFrom      string
+	importDryRun    bool
+	importDateOrder string
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <export-file>",
+	Short: "Import habits and history from another habit tracker",
+	Long:  "Imports habits and their history from a Streaks (CSV) or Habitify (CSV or JSON) export, adding new habits to your habits file and outcomes to your log. Days you've already logged are left untouched.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		importer, ok := storage.Importers[importFrom]
+		if !ok {
+			return fmt.Errorf(`invalid --from source "%s". should be one of %s`, importFrom, strings.Join(importSources(), ", "))
+		}
+		order := storage.DateOrder(importDateOrder)
+		if order != storage.DetectDateOrder && order != storage.MonthFirst && order != storage.DayFirst {
+			return withExitCode(ExitUsage, fmt.Errorf(`invalid --date-order %q, should be "mdy" or "dmy"`, importDateOrder))
+		}
+		configDir := harsh.GetRepository().GetConfigDir()
+		if configDir == "" && !importDryRun {
+			return errors.New("cannot import when reading habits or log from a stream")
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("cannot open export: %w", err)
+		}
+		defer file.Close()
+		report, err := importer(file, order)
+		if err != nil {
+			return err
+		}
+
+		existing := map[string]bool{}
+		for _, habit := range harsh.GetHabits() {
+			existing[habit.Name] = true
+		}
+		var newHabits []*storage.Habit
+		for _, habit := range report.Habits {
+			if !existing[habit.Name] {
+				if habit.Heading == "" {
+					habit.Heading = "Imported from " + report.Source
+				}
+				newHabits = append(newHabits, habit)
+			}
+		}
+		sort.SliceStable(newHabits, func(i, j int) bool {
+			return newHabits[i].Heading < newHabits[j].Heading
+		})
+
+		log := harsh.GetLog()
+		before, after := maps.Clone(log.Entries), maps.Clone(log.Entries)
+		var kept int
+		var writes []storage.EntryWrite
+		for _, key := range report.SortedEntryKeys() {
+			outcome := report.Entries[key]
+			if _, ok := log.Entries[key]; ok {
+				kept++
+				continue
+			}
+			after[key] = outcome
+			writes = append(writes, storage.OutcomeWrite(key, outcome))
+		}
+		if !importDryRun {
+			// Habits first, so entries are never left in the log for habits
+			// the habits file doesn't have
+			if len(newHabits) > 0 {
+				if err := storage.AppendHabitsConfig(configDir, newHabits); err != nil {
+					return err
+				}
+			}
+			if err := storage.WriteEntries(harsh.GetRepository(), writes, log.Header); err != nil {
+				return err
+			}
+			for _, key := range report.SortedEntryKeys() {
+				if _, ok := log.Entries[key]; !ok {
+					log.Record(key, report.Entries[key])
+				}
+			}
+		}
+
+		verb := "Imported"
+		if importDryRun {
+			verb = "Would import"
+		}
+		diff := ui.BuildLogDiff(before, after, report.Entries)
+		fmt.Printf("%s %d new habits and %d entries from %s.\n", verb, len(newHabits), diff.AddedCount(), report.Source)
+		for _, habit := range newHabits {
+			fmt.Printf("  + %s: %s\n", habit.Name, habit.Frequency)
+		}
+		display := newDisplay()
+		display.ShowAdded(diff)
+		if kept > 0 {
+			fmt.Printf("Kept %d days already in your log (%d of them differ from the export).\n", kept, len(diff.Conflicts))
+			display.ShowConflicts(diff)
+		}
+		if len(report.Unsupported) > 0 {
+			fmt.Printf("Couldn't be represented exactly (%d):\n", len(report.Unsupported))
+			for _, note := range report.Unsupported {
+				fmt.Printf("  - %s\n", note)
+			}
+		}
+		return nil
+	},
+}
+
+func importSources() []string {
+	sources := make([]string, 0, len(storage.Importers))
+	for source := range storage.Importers {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importFrom, "from", "", "app the export comes from: "+strings.Join(importSources(), ", "))
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "report what would be imported without changing any files")
+	importCmd.Flags().StringVar(&importDateOrder, "date-order", "", `order of dates like 03/04/2025, "mdy" or "dmy", worked out from the export when it can be`)
+	importCmd.MarkFlagRequired("from")
+	importCmd.RegisterFlagCompletionFunc("from", func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		return importSources(), cobra.ShellCompDirectiveNoFileComp
+	})
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var levelDays int
+
+var levelCmd = &cobra.Command{
+	Use:   "level",
+	Short: "Show your XP and level",
+	Long:  "Shows the XP and level earned from completions, streaks, and perfect days in your log, what you've gained since you last checked, and your recent daily gains.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		display := newDisplay()
+		today := display.Today()
+		report := ui.BuildXP(harsh.GetHabits(), harsh.GetLog(), today)
+
+		configDir := harsh.GetRepository().GetConfigDir()
+		var previous storage.XPState
+		if configDir != "" {
+			var err error
+			if previous, err = storage.LoadXPState(configDir); err != nil {
+				return err
+			}
+		}
+
+		display.ShowLevel(report, previous, levelDays)
+
+		if configDir == "" {
+			return nil
+		}
+		level, _, _ := ui.LevelFor(report.Total)
+		return storage.XPState{XP: report.Total, Level: level, Checked: today}.Save(configDir)
+	},
+}
+
+func init() {
+	levelCmd.Flags().IntVar(&levelDays, "days", 7, "number of recent days to show gains for")
+}
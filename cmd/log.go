@@ -1,31 +1,226 @@
 package cmd
 
 import (
-	"github.com/gookit/color"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
 	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
 	"github.com/wakatara/harsh/internal/ui"
 )
 
+var (
+	logUntil   string
+	logCompact bool
+	logAmounts bool
+	logWatch   bool
+	logMonths  bool
+	logAll     bool
+	logSort    string
+
+	logDate    string
+	logComment string
+	logAmount  string
+)
+
 var logCmd = &cobra.Command{
-	Use:     "log [habit-fragment]",
-	Short:   "Show graph of logged habits",
-	Long:    "Shows consistency graph of logged habits. Can filter by habit fragment.",
-	Aliases: []string{"l"},
-	Args:    cobra.MaximumNArgs(1),
+	Use:   "log [habit-fragment] [y|n|s|o]",
+	Short: "Show graph of logged habits, or record one",
+	Long: `Shows consistency graph of logged habits. Can filter by habit fragment.
+
+Given a habit and a result, records it instead like harsh done, for today or
+--date, without ever prompting. A habit named like one of log's subcommands,
+stats or s, runs that subcommand instead, so record it with harsh done.`,
+	Example: `  harsh log
+  harsh log gym
+  harsh log gym y --amount 45 --comment "legs"
+  harsh log Meditated n --date 2025-03-10`,
+	Aliases:           []string{"l"},
+	Args:              cobra.MaximumNArgs(2),
+	ValidArgsFunction: doneCmdValidArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 2 {
+			// Scripts recording entries want the error, not the usage
+			cmd.SilenceUsage = true
+			if jsonOutput || logWatch {
+				return withExitCode(ExitUsage, errors.New("--json and --watch are for showing the log, not recording"))
+			}
+			return recordEntry(args[0], args[1], logDate, logComment, logAmount)
+		}
+		if logDate != "" || logComment != "" || logAmount != "" {
+			cmd.SilenceUsage = true
+			return withExitCode(ExitUsage, errors.New(`--date, --comment, and --amount are for recording, eg. harsh log gym y --amount 45`))
+		}
+		if err := keepTagged(); err != nil {
+			return err
+		}
+
 		var habitFragment string
 		if len(args) > 0 {
 			habitFragment = args[0]
 		}
 
-		display := ui.NewDisplay(!color.Enable)
-		display.ShowHabitLog(
+		var until civil.Date
+		if logUntil != "" {
+			d, err := civil.ParseDate(logUntil)
+			if err != nil {
+				return fmt.Errorf("invalid --until date %q (expected YYYY-MM-DD)", logUntil)
+			}
+			until = d
+		}
+
+		if logSort != ui.SortFile && logSort != ui.SortRisk {
+			return withExitCode(ExitUsage, fmt.Errorf(`invalid --sort %q, choose "%s" or "%s"`, logSort, ui.SortFile, ui.SortRisk))
+		}
+
+		if jsonOutput {
+			if logWatch {
+				return withExitCode(ExitUsage, errors.New("--watch redraws the log on screen, so it can't be used with --json"))
+			}
+			return printJSON(logReport(newDisplay(), habitFragment, until))
+		}
+		if !logWatch {
+			showLog(newDisplay(), habitFragment, until)
+			return nil
+		}
+		if harsh.GetRepository().GetConfigDir() == "" {
+			return fmt.Errorf("--watch rereads your config directory, so it can't be used with --habits or --log")
+		}
+		renderer := ui.NewRenderer(os.Stdout)
+		var reloadErr error
+		for {
+			var frame bytes.Buffer
+			display := newDisplay()
+			display.SetOutput(&frame)
+			showLog(display, habitFragment, until)
+			if reloadErr != nil {
+				fmt.Fprintf(&frame, "Warning: %v, showing what was read before\n", reloadErr)
+			}
+			if err := renderer.Render(frame.String()); err != nil {
+				return err
+			}
+			time.Sleep(logWatchInterval)
+			// Pick up answers logged elsewhere since the last frame
+			reloadErr = reloadHarsh()
+		}
+	},
+}
+
+// logWatchInterval is how often `harsh log --watch` redraws
+const logWatchInterval = 2 * time.Second
+
+// showLog prints the habit log on display, ending on until or today when until is zero
+func showLog(display *ui.Display, habitFragment string, until civil.Date) {
+	to := display.Today()
+	if until != (civil.Date{}) {
+		to = until
+	}
+
+	display.Amounts = logAmounts
+	display.MonthShading = logMonths
+	display.ShowHidden = logAll
+	display.Sort = logSort
+	display.Notes = harsh.GetLog().Notes
+	display.Challenges = loadChallenges()
+	display.ScoreGoal = float64(harsh.GetSettings().Int("score_goal"))
+	scores := loadScoreCache()
+	display.Scores = scores.Scores
+	defer saveScoreCache(scores)
+	if logCompact {
+		nameWidth := harsh.GetMaxHabitNameLength() - storage.HabitNamePadding
+		countBack := harsh.GetCountBack()
+		if !harsh.GetSettings().IsSet("countback") {
+			// Fill the whole terminal, less the name and the space after it
+			countBack = max(1, display.TerminalWidth()-nameWidth-2)
+		}
+		display.ShowCompactLog(
 			harsh.GetHabits(),
-			&harsh.GetLog().Entries,
-			harsh.GetCountBack(),
-			harsh.GetMaxHabitNameLength(),
+			harsh.GetLog(),
+			to,
+			countBack,
+			nameWidth,
 			habitFragment,
 		)
-		return nil
-	},
+		return
+	}
+	countBack := harsh.GetCountBack()
+	if display.DaysSince && !harsh.GetSettings().IsSet("countback") {
+		// Make room for the column after the graphs
+		countBack = max(1, countBack-ui.DaysSinceWidth)
+	}
+	if display.WindowProgress && !harsh.GetSettings().IsSet("countback") && slices.ContainsFunc(harsh.GetHabits(), ui.HasWindow) {
+		countBack = max(1, countBack-ui.WindowProgressWidth)
+	}
+	display.ShowHabitLog(
+		harsh.GetHabits(),
+		harsh.GetLog(),
+		to,
+		countBack,
+		harsh.GetMaxHabitNameLength(),
+		habitFragment,
+	)
+}
+
+// logReport returns the habit log showLog would print as data for --json
+func logReport(display *ui.Display, habitFragment string, until civil.Date) ui.LogReport {
+	to := display.Today()
+	if until != (civil.Date{}) {
+		to = until
+	}
+	display.ShowHidden = logAll
+	display.Sort = logSort
+	display.Notes = harsh.GetLog().Notes
+	scores := loadScoreCache()
+	display.Scores = scores.Scores
+	defer saveScoreCache(scores)
+	return display.BuildLog(harsh.GetHabits(), harsh.GetLog(), to, harsh.GetCountBack(), habitFragment)
+}
+
+// loadScoreCache reads the daily scores cached for the log footer's
+// averages, empty when they were worked out from habits, a log, or settings
+// since changed, or when reading from streams
+func loadScoreCache() *storage.ScoreCache {
+	configDir := harsh.GetRepository().GetConfigDir()
+	if configDir == "" {
+		return &storage.ScoreCache{}
+	}
+	fingerprint := storage.DataFingerprint(configDir, strings.Join(habitTags, ","),
+		fmt.Sprint(graph.PositiveSkips, graph.BreakingSkips, graph.ProRateFirstWindow, graph.EmptyDays))
+	scores, err := storage.LoadScoreCache(configDir, fingerprint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return scores
+}
+
+// saveScoreCache keeps the daily scores worked out for next time
+func saveScoreCache(scores *storage.ScoreCache) {
+	configDir := harsh.GetRepository().GetConfigDir()
+	if configDir == "" || scores.Scores == nil {
+		return
+	}
+	if err := scores.Save(configDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+func init() {
+	logCmd.Flags().BoolVar(&logAll, "all", false, "include habits marked hidden=yes")
+	logCmd.Flags().BoolVar(&logAmounts, "amounts", false, "draw days with amounts as bars scaled to the habit's largest amount")
+	logCmd.Flags().BoolVar(&logCompact, "compact", false, "only show each habit's name and graph, sized to the terminal width")
+	logCmd.Flags().BoolVar(&logMonths, "months", false, "shade every other month in graphs so month boundaries stand out")
+	logCmd.Flags().StringVar(&logSort, "sort", ui.SortFile, `order habits by "file" (the habits file, under headings) or "risk" (closest to breaking first)`)
+	logCmd.Flags().StringVar(&logUntil, "until", "", "end the graph on this date (YYYY-MM-DD) instead of today")
+	logCmd.Flags().BoolVar(&logWatch, "watch", false, "keep the log on screen, redrawing it as entries are logged")
+	logCmd.Flags().StringVar(&logDate, "date", "", "when recording, record for this date (YYYY-MM-DD) instead of today")
+	logCmd.Flags().StringVarP(&logComment, "comment", "c", "", "when recording, comment to record with the answer")
+	logCmd.Flags().StringVarP(&logAmount, "amount", "a", "", "when recording, amount to record with the answer, eg. minutes or reps")
+	addTagFlag(logCmd)
 }
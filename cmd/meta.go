@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var metaCmd = &cobra.Command{
+	Use:   "meta",
+	Short: "Show how consistently you log",
+	Long: `Shows how consistently you run harsh itself: logging sessions per week, how many
+days after the fact entries get written on average, and how many are
+backfilled. It's worked out from the write times recorded with the
+write_times setting, which stay on this machine like the rest of your data.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		writes, err := storage.LoadWrites(harsh.GetRepository().GetConfigDir())
+		if err != nil {
+			return err
+		}
+		display := newDisplay()
+		display.ShowMeta(ui.BuildMeta(harsh.GetHabits(), writes, display.Today()))
+		return nil
+	},
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/mqtt"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var mqttDryRun bool
+
+var mqttCmd = &cobra.Command{
+	Use:   "mqtt",
+	Short: "Publish your daily state to an MQTT broker",
+	Long: `Publishes today's score, number of todos, and each habit's status to the MQTT
+broker in the mqtt_broker setting, for Home Assistant dashboards and smart-home
+automations. Once mqtt_broker is set, harsh ask and harsh done publish after
+every logging session by themselves.`,
+}
+
+var mqttPublishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Publish today's state now",
+	Long: `Publishes today's state under the mqtt_topic setting (harsh by default), as
+retained messages:
+
+  harsh/state          everything below as JSON
+  harsh/score          today's score, eg. 66.7
+  harsh/todos          habits still to do today
+  harsh/habits/<habit> done, missed, skipped, or todo
+
+Habit names are lowercased with spaces and punctuation turned into _, so
+"Push ups" is harsh/habits/push_ups. Logs in with mqtt_user and the password in
+HARSH_MQTT_PASSWORD (or harsh auth set mqtt) when mqtt_user is set. Brokers
+starting ssl:// or tls:// are connected to over TLS, on port 8883 unless
+another is given.`,
+	Example: `  harsh config set mqtt_broker homeassistant.local:1883
+  harsh mqtt publish --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		messages, err := mqtt.StateMessages(harsh.GetSettings().String("mqtt_topic"), mqttState())
+		if err != nil {
+			return err
+		}
+		if mqttDryRun {
+			for _, message := range messages {
+				fmt.Printf("%s %s\n", message.Topic, message.Payload)
+			}
+			return nil
+		}
+		if err := mqtt.Publish(mqttBroker(), messages); err != nil {
+			return err
+		}
+		fmt.Printf("Published %d messages to %s.\n", len(messages), harsh.GetSettings().String("mqtt_broker"))
+		return nil
+	},
+}
+
+// mqttState gathers today's score, todos, and each habit's status
+func mqttState() mqtt.State {
+	today := clock.Today()
+	entries := harsh.GetLog()
+	status := ui.BuildStatus(harsh.GetHabits(), entries, today, loadSnoozed())
+	state := mqtt.State{
+		Date:           today.String(),
+		Score:          status.Score,
+		YesterdayScore: status.Yesterday,
+		Todos:          len(status.Todos),
+		Habits:         []mqtt.HabitState{},
+	}
+	for _, habit := range harsh.GetHabits() {
+		outcome := storage.OutcomeAt(entries, storage.DailyHabit{Day: today, Habit: habit.Name})
+		state.Habits = append(state.Habits, mqtt.HabitState{Name: habit.Name, Status: mqtt.HabitStatus(outcome.Result)})
+	}
+	return state
+}
+
+func mqttBroker() mqtt.Broker {
+	settings := harsh.GetSettings()
+	hostname, _ := os.Hostname()
+	broker := mqtt.Broker{
+		Address:  settings.String("mqtt_broker"),
+		User:     settings.String("mqtt_user"),
+		Password: LookupCredential("mqtt"),
+		ClientID: "harsh-" + hostname,
+	}
+	if broker.Plaintext() {
+		fmt.Fprintf(os.Stderr, "Warning: the MQTT password goes to %s unencrypted, put ssl:// in front of mqtt_broker to use TLS\n", broker.Address)
+	}
+	return broker
+}
+
+// publishMQTT publishes today's state after logging when mqtt_broker is set,
+// warning rather than failing so a broker being down never loses answers
+func publishMQTT() {
+	if harsh.GetSettings().String("mqtt_broker") == "" {
+		return
+	}
+	messages, err := mqtt.StateMessages(harsh.GetSettings().String("mqtt_topic"), mqttState())
+	if err == nil {
+		err = mqtt.Publish(mqttBroker(), messages)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+func init() {
+	mqttPublishCmd.Flags().BoolVar(&mqttDryRun, "dry-run", false, "print the topics and payloads instead of publishing them")
+	mqttCmd.AddCommand(mqttPublishCmd)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var normalizeCheck bool
+
+var normalizeCmd = &cobra.Command{
+	Use:   "normalize",
+	Short: "Rewrite the log in one canonical, diff-friendly form",
+	Long: `Rewrites the log so the same history always comes out the same: sorted by date
+then habit with each day's notes after its entries, the default column order,
+fields trimmed, amounts written plainly (5 rather than 5.0), blank lines
+dropped, and only the last of any repeated entry for a habit on a day kept,
+which is the one harsh reads anyway. Comment lines move with the line below
+them. The old log is kept as log.bak.
+
+Logs synced through git then diff and merge cleanly. With --check nothing is
+rewritten and the exit code is 1 if the log isn't normalized, eg. for a git
+pre-commit hook.`,
+	Example: `  harsh normalize
+  harsh normalize --check`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configDir := harsh.GetRepository().GetConfigDir()
+		if configDir == "" {
+			return errors.New("cannot normalize the log when reading habits or log from a stream")
+		}
+		if usingSQLite() {
+			return errors.New("entries are in " + storage.SQLiteFile + " with the sqlite backend, there's no log to normalize")
+		}
+		result, err := storage.NormalizeLogFile(configDir, normalizeCheck)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !result.Changed:
+			fmt.Printf("The log is already normalized (%d entries, %d notes).\n", result.Entries, result.Notes)
+		case normalizeCheck:
+			return fmt.Errorf("the log isn't normalized (%d duplicate entries), run harsh normalize", result.Duplicates)
+		default:
+			fmt.Printf("Normalized the log: %d entries and %d notes kept, %d duplicate entries dropped. The old log is in log.bak.\n",
+				result.Entries, result.Notes, result.Duplicates)
+			if signatures, err := storage.LoadSignatures(configDir); err == nil && len(signatures) > 0 {
+				fmt.Println("Its lines were signed, run harsh verify --sign to sign the normalized log.")
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	normalizeCmd.Flags().BoolVar(&normalizeCheck, "check", false, "only check whether the log is normalized, exiting 1 if not")
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var noteCmd = &cobra.Command{
+	Use:   "note [YYYY-MM-DD] <text>",
+	Short: "Add a dated note to your log",
+	Long:  "Adds a note about a day (today unless a date comes first) to your log, eg. a life event that explains a change in your habits. Notes are marked under the calendar and listed in harsh log.",
+	Example: `  harsh note Started new job
+  harsh note 2025-03-10 Moved house`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		day := clock.Today()
+		if d, err := civil.ParseDate(args[0]); err == nil {
+			day = d
+			args = args[1:]
+		}
+		text := strings.TrimSpace(strings.Join(args, " "))
+		if text == "" {
+			return errors.New("a note needs some text")
+		}
+
+		configDir := harsh.GetRepository().GetConfigDir()
+		if configDir == "" {
+			return errors.New("cannot add notes when reading the log from a stream")
+		}
+		write := func() error { return storage.WriteNote(configDir, day, text) }
+		if usingSQLite() {
+			write = func() error { return storage.NewSQLiteRepository(configDir).WriteNote(day, text) }
+		}
+		if err := write(); err != nil {
+			return err
+		}
+		if !usingSQLite() && harsh.GetSettings().Bool("sign_entries") {
+			// Signed like entries, or it would break the chain harsh verify follows
+			key, err := signingKey(configDir)
+			if err == nil {
+				err = storage.AppendSignatures(configDir, key, []string{storage.NoteLine(day, text)})
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: the note wasn't signed: %v\n", err)
+			}
+		}
+		fmt.Printf("Noted for %s.\n", day)
+		return nil
+	},
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var planWeek string
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Plan how often to do habits this week",
+	Long: `Plans are how many times you mean to do each habit in a week, starting
+on Monday unless the week_start setting says otherwise.
+Make one at the start of the week with harsh plan set, then harsh plan shows how
+you're doing against it. Accountability check-ins include the plan too.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		week, err := plannedWeek()
+		if err != nil {
+			return err
+		}
+		plans, err := storage.LoadPlans(harsh.GetRepository().GetConfigDir())
+		if err != nil {
+			return err
+		}
+		plan, ok := plans.For(week)
+		if !ok {
+			fmt.Printf("No plan for the week of %s yet. Make one with harsh plan set.\n", week)
+			return nil
+		}
+		display := newDisplay()
+		to := display.Today()
+		display.ShowPlan(plan, ui.BuildPlanProgress(plan, harsh.GetHabits(), harsh.GetLog(), to), to, harsh.GetMaxHabitNameLength())
+		return nil
+	},
+}
+
+var planSetCmd = &cobra.Command{
+	Use:   "set [habit=count]...",
+	Short: "Set this week's plan",
+	Long: `Sets how many times you mean to do habits this week, asking about each scoreable
+habit unless counts are given as arguments. Arguments change just those habits in
+the week's plan, and a count of 0 takes a habit out of it.`,
+	Example: `  harsh plan set
+  harsh plan set gym=3 reading=5
+  harsh plan set --week 2025-03-17`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configDir := harsh.GetRepository().GetConfigDir()
+		if configDir == "" {
+			return errors.New("cannot plan when reading habits or log from a stream")
+		}
+		week, err := plannedWeek()
+		if err != nil {
+			return err
+		}
+		plans, err := storage.LoadPlans(configDir)
+		if err != nil {
+			return err
+		}
+		plan, ok := plans.For(week)
+		if !ok {
+			plan = storage.Plan{Week: storage.WeekStart(week)}
+		}
+
+		if len(args) == 0 {
+			if nonInteractive {
+				return withExitCode(ExitNeedsInput, errors.New("plan set prompts for counts, give them as habit=count with --non-interactive"))
+			}
+			plan = ui.NewInput(!color.Enable).AskPlan(harsh.GetHabits(), plan, harsh.GetMaxHabitNameLength())
+		}
+		for _, arg := range args {
+			i := strings.LastIndex(arg, "=")
+			if i == -1 {
+				return withExitCode(ExitUsage, fmt.Errorf("expected habit=count, got %q", arg))
+			}
+			count, err := strconv.Atoi(strings.TrimSpace(arg[i+1:]))
+			if err != nil || count < 0 {
+				return withExitCode(ExitUsage, fmt.Errorf("count for %s must be a whole number, got %q", arg[:i], arg[i+1:]))
+			}
+			habit, err := harsh.FindHabit(strings.TrimSpace(arg[:i]))
+			if err != nil {
+				return err
+			}
+			plan = withPlanTarget(plan, habit.Name, count)
+		}
+
+		if err := plans.Set(plan).Save(configDir); err != nil {
+			return err
+		}
+		fmt.Printf("Planned %d habits for the week of %s.\n", len(plan.Targets), plan.Week)
+		return nil
+	},
+}
+
+// withPlanTarget returns plan with habit planned count times, or left out for 0
+func withPlanTarget(plan storage.Plan, habit string, count int) storage.Plan {
+	targets := []storage.PlanTarget{}
+	replaced := false
+	for _, target := range plan.Targets {
+		if target.Habit == habit {
+			replaced = true
+			if count == 0 {
+				continue
+			}
+			target.Count = count
+		}
+		targets = append(targets, target)
+	}
+	if !replaced && count > 0 {
+		targets = append(targets, storage.PlanTarget{Habit: habit, Count: count})
+	}
+	plan.Targets = targets
+	return plan
+}
+
+// plannedWeek returns a day in the week --week asks for, today by default
+func plannedWeek() (civil.Date, error) {
+	if planWeek == "" {
+		return clock.Today(), nil
+	}
+	d, err := civil.ParseDate(planWeek)
+	if err != nil {
+		return d, withExitCode(ExitUsage, fmt.Errorf("invalid --week date %q (expected YYYY-MM-DD)", planWeek))
+	}
+	return d, nil
+}
+
+// loadPlans returns the plans, warning rather than failing when the plans
+// file can't be read
+func loadPlans() storage.Plans {
+	configDir := harsh.GetRepository().GetConfigDir()
+	if configDir == "" {
+		return nil
+	}
+	plans, err := storage.LoadPlans(configDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return plans
+}
+
+func init() {
+	planCmd.PersistentFlags().StringVar(&planWeek, "week", "", "plan the week this date (YYYY-MM-DD) falls in instead of this week")
+	planCmd.AddCommand(planSetCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/query"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var queryCount bool
+
+var queryCmd = &cobra.Command{
+	Use:   "query <expression>",
+	Short: "Find log entries matching an expression",
+	Long: `Prints the log entries matching an expression, or just how many with --count.
+
+Compare the fields habit, result, amount, comment, and date with = != < <= > >=
+(or ~ to find text within habit names and comments), combine comparisons with
+and, or, not, and parentheses, and end with since/until YYYY-MM-DD to bound the
+dates. Quote values with spaces, eg. habit="Morning Pages".`,
+	Example: `  harsh query 'habit=Run and result=y and amount>5 since 2025-01-01'
+  harsh query --count 'result=s and comment~sick'`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		q, err := query.Parse(strings.Join(args, " "))
+		if err != nil {
+			return fmt.Errorf("invalid query: %w", err)
+		}
+
+		entries := harsh.GetLog().Entries
+		matches := q.Filter(entries)
+		if queryCount {
+			fmt.Println(len(matches))
+			return nil
+		}
+		printEntries(entries, matches)
+		return nil
+	},
+}
+
+// printEntries prints the entries for keys as log lines
+func printEntries(entries storage.Entries, keys []storage.DailyHabit) {
+	for _, key := range keys {
+		outcome := entries[key]
+		var amount string
+		if outcome.Amount != 0 {
+			amount = strconv.FormatFloat(outcome.Amount, 'f', -1, 64)
+		}
+		fmt.Printf("%s : %s : %s : %s : %s\n", key.Day, key.Habit, outcome.Result, outcome.Comment, amount)
+	}
+}
+
+func init() {
+	queryCmd.Flags().BoolVarP(&queryCount, "count", "c", false, "print only the number of matching entries")
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"cloud.google.com/go/civil"
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var recomputeDryRun bool
+
+var recomputeCmd = &cobra.Command{
+	Use:   "recompute",
+	Short: "Rebuild cached state from the log",
+	Long: `Rebuilds everything harsh keeps derived from your log, reporting where it
+disagreed with the log: a safety valve after editing the log by hand or
+importing history. Streaks and stats are worked out from the log on every
+run. Daily scores cached for the log's averages are worked out again whenever
+your habits, log, or settings change, and dropped here to be sure. The XP
+remembered by harsh level is recomputed as of the day it was last checked.
+Lines of the log harsh can't read are listed too, as they're left out of
+everything.
+
+With --dry-run nothing is rewritten, and the exit code is 1 if anything
+disagreed.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configDir := harsh.GetRepository().GetConfigDir()
+		if configDir == "" {
+			return errors.New("nothing is cached when reading habits or log from a stream")
+		}
+		cached, err := storage.LoadXPState(configDir)
+		if err != nil {
+			return err
+		}
+
+		discrepancies := 0
+		fmt.Println("Streaks and stats: worked out from the log on every run, nothing cached.")
+		if !recomputeDryRun {
+			if err := os.Remove(filepath.Join(configDir, storage.ScoresFile)); err == nil {
+				fmt.Println("Daily scores: dropped the cache, harsh log works them out again.")
+			} else if !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if cached.Checked == (civil.Date{}) {
+			fmt.Println("XP: not cached yet, harsh level caches it.")
+		} else {
+			rebuilt := ui.RebuildXPState(harsh.GetHabits(), harsh.GetLog(), cached.Checked)
+			if rebuilt.XP == cached.XP && rebuilt.Level == cached.Level {
+				fmt.Printf("XP as of %s: %d XP, level %d, matches the log.\n", cached.Checked, cached.XP, cached.Level)
+			} else {
+				discrepancies++
+				fmt.Printf("XP as of %s: cached %d XP, level %d, but the log gives %d XP, level %d.\n",
+					cached.Checked, cached.XP, cached.Level, rebuilt.XP, rebuilt.Level)
+				if !recomputeDryRun {
+					if err := rebuilt.Save(configDir); err != nil {
+						return err
+					}
+					fmt.Println("  Rebuilt the XP cache.")
+				}
+			}
+		}
+		if warnings := harsh.GetLog().Warnings; len(warnings) > 0 {
+			discrepancies += len(warnings)
+			fmt.Printf("Log: %d line(s) couldn't be read and are left out:\n", len(warnings))
+			for _, warning := range warnings {
+				fmt.Printf("  %s\n", warning)
+			}
+		}
+
+		if discrepancies == 0 {
+			fmt.Println("Everything matches the log.")
+			return nil
+		}
+		if recomputeDryRun {
+			return fmt.Errorf("found %d discrepancies with the log", discrepancies)
+		}
+		return nil
+	},
+}
+
+func init() {
+	recomputeCmd.Flags().BoolVar(&recomputeDryRun, "dry-run", false, "report discrepancies without rebuilding anything")
+}
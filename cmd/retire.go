@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var retireDryRun bool
+
+var retireCmd = &cobra.Command{
+	Use:   "retire <habit>",
+	Short: "Retire a habit with a final report",
+	Long: `Retires a habit you've finished with: shows a final report of its lifetime
+completion rate, longest streak, total amount, and how long you tracked it, adds
+the report to the retired file in your config directory for posterity, and takes
+the habit out of your habits file. Its log entries are kept.`,
+	Example: `  harsh retire "Couch to 5k"
+  harsh retire gym --dry-run`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: habitNameValidArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		habit, err := harsh.FindHabit(args[0])
+		if err != nil {
+			return err
+		}
+		display := newDisplay()
+		report := ui.BuildRetirementReport(habit, harsh.GetLog(), display.Today())
+		display.ShowRetirementReport(report)
+		if retireDryRun {
+			return nil
+		}
+
+		configDir := harsh.GetRepository().GetConfigDir()
+		if configDir == "" {
+			return errors.New("cannot retire a habit when reading habits or log from a stream")
+		}
+		if err := storage.RemoveHabitConfig(configDir, habit); err != nil {
+			return err
+		}
+		if err := storage.AppendRetiredHabit(configDir, report.String()); err != nil {
+			return err
+		}
+		fmt.Printf("Retired %s. Its report is in %s and its log entries are kept.\n", habit.Name, storage.RetiredHabitsFile)
+		return nil
+	},
+}
+
+func init() {
+	retireCmd.Flags().BoolVar(&retireDryRun, "dry-run", false, "show the report without retiring the habit")
+}
@@ -1,16 +1,37 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"cloud.google.com/go/civil"
 	"github.com/gookit/color"
 	"github.com/spf13/cobra"
 	"github.com/wakatara/harsh/internal"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
 )
 
 var (
 	colorOption string
+	habitsFile  string
+	logFile     string
+	force       bool
+	golden      string
+	today       string
+	nonInteractive bool
+	accessible     bool
+	strict         bool
+	backend        string
+	jsonOutput     bool
 	RootCmd = &cobra.Command{
 		Use:     "harsh",
 		Short:   "habit tracking for geeks",
@@ -21,13 +42,74 @@ var (
 
 var harsh *internal.Harsh
 
+// openedRepository is harsh's repository before wrapRepository wraps it
+var openedRepository storage.Repository
+
+// commandArgs are the command line arguments run, with any alias expanded
+var commandArgs = os.Args[1:]
+
+// goldenDate is the --golden date, zero unless rendering deterministically
+var goldenDate civil.Date
+
+// goldenWidth is the terminal width --golden output is fitted to
+const goldenWidth = 80
+
 func init() {
 	RootCmd.PersistentFlags().StringVarP(&colorOption, "color", "C", "auto", `manage colors in output, "always", "never" or "auto" (defaults to auto)`)
 	RootCmd.RegisterFlagCompletionFunc("color", colorCompletionFunc)
+	RootCmd.PersistentFlags().StringVar(&golden, "golden", "", "render as of this date (YYYY-MM-DD), 80 columns wide and without colors, for output that only changes with your data")
+	RootCmd.PersistentFlags().StringVar(&today, "today", "", "act as if today were this date (YYYY-MM-DD), eg. to preview next Monday")
+	RootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false, "never prompt, failing with exit code 4 instead, for scripts and phone automations")
+	RootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "print log, log stats, todo, forecast, focus, ask's summary, and sql's rows as JSON, for jq and scripts")
+	RootCmd.PersistentFlags().StringVar(&habitsFile, "habits-file", "", `read habits from this file instead of the config directory ("-" for stdin)`)
+	RootCmd.PersistentFlags().BoolVar(&accessible, "accessible", false, "draw graphs with letters and describe each in words, for screen readers")
+	RootCmd.PersistentFlags().BoolVar(&force, "force", false, "allow changing entries older than the lock_after_days setting")
+	RootCmd.PersistentFlags().BoolVar(&strict, "strict", false, "fail instead of skipping lines with problems in the log, to catch corrupted data early in scripts")
+	RootCmd.PersistentFlags().StringVar(&backend, "backend", "", `keep entries in "file" (the log) or "sqlite" (log.db), overriding the backend setting`)
+	RootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", `read the log from this file instead of the config directory ("-" for stdin)`)
 	RootCmd.AddCommand(askCmd)
 	RootCmd.AddCommand(todoCmd)
 	RootCmd.AddCommand(logCmd)
 	RootCmd.AddCommand(versionCmd)
+	RootCmd.AddCommand(compareCmd)
+	RootCmd.AddCommand(teamCmd)
+	RootCmd.AddCommand(shareCmd)
+	RootCmd.AddCommand(configCmd)
+	RootCmd.AddCommand(importCmd)
+	RootCmd.AddCommand(exportCmd)
+	RootCmd.AddCommand(levelCmd)
+	RootCmd.AddCommand(queryCmd)
+	RootCmd.AddCommand(noteCmd)
+	RootCmd.AddCommand(searchCmd)
+	RootCmd.AddCommand(snoozeCmd)
+	RootCmd.AddCommand(daemonCmd)
+	RootCmd.AddCommand(accountabilityCmd)
+	RootCmd.AddCommand(doneCmd)
+	RootCmd.AddCommand(challengeCmd)
+	RootCmd.AddCommand(bundleCmd)
+	RootCmd.AddCommand(correlateCmd)
+	RootCmd.AddCommand(planCmd)
+	RootCmd.AddCommand(retireCmd)
+	RootCmd.AddCommand(editMonthCmd)
+	RootCmd.AddCommand(mqttCmd)
+	RootCmd.AddCommand(serveCmd)
+	RootCmd.AddCommand(recomputeCmd)
+	RootCmd.AddCommand(gapsCmd)
+	RootCmd.AddCommand(metaCmd)
+	RootCmd.AddCommand(normalizeCmd)
+	RootCmd.AddCommand(verifyCmd)
+	RootCmd.AddCommand(authCmd)
+	RootCmd.AddCommand(backendCmd)
+	RootCmd.AddCommand(calendarCmd)
+	RootCmd.AddCommand(demoCmd)
+	RootCmd.AddCommand(genCmd)
+	RootCmd.AddCommand(habitCmd)
+	RootCmd.AddCommand(tuiCmd)
+	RootCmd.AddCommand(editCmd)
+	RootCmd.AddCommand(sqlCmd)
+	RootCmd.AddCommand(forecastCmd)
+	RootCmd.AddCommand(focusCmd)
+	RootCmd.AddCommand(syncCmd)
 
 	// Add stats as subcommand of log
 	logCmd.AddCommand(statsCmd)
@@ -51,14 +133,270 @@ func init() {
 		}
 	})
 	// initialize the global harsh instance (for context aware completion)
-	harsh = internal.NewHarsh()
+	cobra.OnInitialize(initHarsh)
+}
+
+// initHarsh loads the global harsh instance, reading from --habits-file and
+// --log-file instead of the config directory when either is given
+func initHarsh() {
+	// bundle import restores a config directory, so it mustn't create one first
+	if cmd, _, err := RootCmd.Find(commandArgs); err == nil && cmd == bundleImportCmd {
+		return
+	}
+	if nonInteractive {
+		// Scripts want the error, not the help text
+		RootCmd.SilenceUsage = true
+	}
+	if cmd, _, err := RootCmd.Find(commandArgs); err == nil && jsonOutput && !slices.Contains(jsonCommands(), cmd) {
+		fmt.Fprintf(os.Stderr, "Error: harsh %s has no JSON output, --json works with log, log stats, todo, forecast, focus, ask, and sql\n", cmd.Name())
+		os.Exit(ExitUsage)
+	}
+	if today != "" {
+		d, err := civil.ParseDate(today)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --today date %q (expected YYYY-MM-DD)\n", today)
+			os.Exit(1)
+		}
+		clock.Default = clock.Fixed(d)
+	}
+	if golden != "" {
+		d, err := civil.ParseDate(golden)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --golden date %q (expected YYYY-MM-DD)\n", golden)
+			os.Exit(1)
+		}
+		goldenDate = d
+		clock.Default = clock.Fixed(d)
+		color.Enable = false
+	}
+	// The demo and gen bring their own habits, so there's no need to set up yours first
+	if cmd, _, err := RootCmd.Find(commandArgs); err == nil && (cmd == demoCmd || cmd == genCmd) {
+		return
+	}
+
+	if habitsFile == "" && logFile == "" {
+		repository, settings, err := openRepository()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if cmd, _, err := RootCmd.Find(commandArgs); err == nil && cmd == askCmd {
+			// Before the log's read, so answers from other machines are in it
+			pullBeforeAsk(repository.GetConfigDir())
+			// Settings may have come with them
+			settings = internal.LoadSettings(repository.GetConfigDir())
+		}
+		// Before the log's read, which these settings shape
+		applyReadSettings(settings)
+		if harsh, err = internal.LoadHarshWithSettings(repository, settings); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		habits, log, err := openDataStreams(habitsFile, logFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		harsh = internal.NewHarshWithRepository(storage.NewReaderRepository(habits, log))
+	}
+	fitGoldenCountBack()
+
+	if warnings := harsh.GetLog().Warnings; strict && len(warnings) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: found %d problem(s) in the log with --strict, fix the lines warned about above\n", len(warnings))
+		os.Exit(ExitError)
+	}
+
+	wrapRepository()
+
+	applySettings(harsh.GetSettings())
+
+	// The mono theme turns colors off unless asked for with --color
+	if colorOption == "auto" && harsh.GetSettings().String("theme") == "mono" {
+		color.Enable = false
+	}
+}
+
+// applyReadSettings applies the settings that shape how the log is read,
+// before reading it
+func applyReadSettings(settings *storage.Settings) {
+	storage.MaxLineLength = settings.Int("max_line_length")
+	if today == "" && golden == "" {
+		// Loading the log looks at what day it is
+		clock.Default = clock.System{RolloverHour: settings.Int("rollover_hour")}
+	}
+}
+
+// applySettings applies the settings that shape how weeks and graphs are drawn
+func applySettings(settings *storage.Settings) {
+	storage.FirstWeekday = time.Monday
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), settings.String("week_start")) {
+			storage.FirstWeekday = day
+		}
+	}
+	graph.ProRateFirstWindow = settings.Bool("prorate_first_window")
+	graph.PositiveSkips = settings.String("skips") == "positive"
+	graph.BreakingSkips = settings.String("skips") == "breaking"
+	graph.EmptyDays = settings.String("empty_days")
+
+	graph.Symbols = graph.Blocks
+	if accessible || settings.String("graph_symbols") == "letters" {
+		graph.Symbols = graph.Letters
+	}
+}
+
+// wrapRepository wraps harsh's repository in what its settings ask for
+// around writes, remembering the repository wrapped for reloadHarsh
+func wrapRepository() {
+	openedRepository = harsh.Repository
+	// Frequencies are recorded as of the real today, with the first write
+	if harsh.GetRepository().GetConfigDir() != "" && today == "" && golden == "" {
+		harsh.Repository = storage.NewVersionedRepository(harsh.Repository, harsh.FrequencyChanges)
+	}
+	if harsh.GetSettings().Bool("write_times") {
+		harsh.Repository = storage.NewTimedRepository(harsh.Repository)
+	}
+	if configDir := harsh.GetRepository().GetConfigDir(); configDir != "" && harsh.GetSettings().Bool("sign_entries") {
+		if key, err := signingKey(configDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: entries won't be signed: %v\n", err)
+		} else {
+			harsh.Repository = storage.NewSignedRepository(harsh.Repository, key)
+		}
+	}
+	if lockAfterDays := harsh.GetSettings().Int("lock_after_days"); lockAfterDays > 0 {
+		harsh.Repository = storage.NewLockedRepository(harsh.Repository, lockAfterDays, force)
+	}
+}
+
+// reloadHarsh rereads the habits, log, and settings for commands that keep
+// running, keeping what was read before when they can't be read
+func reloadHarsh() error {
+	settings := internal.LoadSettings(openedRepository.GetConfigDir())
+	applyReadSettings(settings)
+	reloaded, err := internal.LoadHarshWithSettings(openedRepository, settings)
+	if err != nil {
+		return err
+	}
+	harsh = reloaded
+	wrapRepository()
+	applySettings(harsh.GetSettings())
+	fitGoldenCountBack()
+	return nil
+}
+
+// jsonCommands are the commands --json changes the output of
+func jsonCommands() []*cobra.Command {
+	return []*cobra.Command{logCmd, todoCmd, statsCmd, askCmd, sqlCmd, forecastCmd, focusCmd}
+}
+
+// printJSON prints v as indented JSON for --json
+func printJSON(v any) error {
+	out, err := ui.IndentJSON(v)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// openRepository opens the config directory with the backend chosen by
+// --backend, or else the backend setting, along with the settings read
+func openRepository() (storage.Repository, *storage.Settings, error) {
+	files := storage.NewFileRepository()
+	settings := internal.LoadSettings(files.GetConfigDir())
+	if backend == "" {
+		backend = settings.String("backend")
+	}
+	switch backend {
+	case "file":
+		return files, settings, nil
+	case "sqlite":
+		// The database is created by the first write, not by reading
+		return storage.NewSQLiteRepository(files.GetConfigDir()), settings, nil
+	}
+	return nil, nil, fmt.Errorf(`invalid --backend %q, should be "file" or "sqlite"`, backend)
+}
+
+// fitGoldenCountBack sizes graphs to the --golden width instead of the
+// terminal's, unless the countback setting sizes them already
+func fitGoldenCountBack() {
+	if goldenDate != (civil.Date{}) && !harsh.GetSettings().IsSet("countback") {
+		harsh.CountBack = max(1, min(goldenWidth-harsh.MaxHabitNameLength-2, 100))
+	}
+}
+
+// newDisplay creates the display commands print with, deterministic under --golden
+func newDisplay() *ui.Display {
+	display := ui.NewDisplay(!color.Enable)
+	if goldenDate != (civil.Date{}) {
+		display = ui.NewDeterministicDisplay(goldenDate, goldenWidth)
+	}
+	display.Summaries = accessible || harsh.GetSettings().Bool("graph_summaries")
+	display.DaysSince = harsh.GetSettings().Bool("days_since")
+	display.WindowProgress = harsh.GetSettings().Bool("window_progress")
+	return display
+}
+
+// openDataStreams reads the habits and log data from the given paths, stdin
+// for "-", or the config directory when a path is empty. When both come from
+// stdin, the habits come first, separated from the log by a line of "---".
+func openDataStreams(habitsPath string, logPath string) (io.Reader, io.Reader, error) {
+	if habitsPath == "-" && logPath == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot read stdin: %w", err)
+		}
+		habits, log, found := strings.Cut(string(data), "\n---\n")
+		if !found {
+			return nil, nil, fmt.Errorf(`reading both habits and log from stdin needs a "---" line between them`)
+		}
+		return strings.NewReader(habits), strings.NewReader(log), nil
+	}
+	habits, err := openDataStream(habitsPath, "habits")
+	if err != nil {
+		return nil, nil, err
+	}
+	log, err := openDataStream(logPath, "log")
+	if err != nil {
+		return nil, nil, err
+	}
+	return habits, log, nil
+}
+
+func openDataStream(path string, name string) (io.Reader, error) {
+	var data []byte
+	var err error
+	switch path {
+	case "-":
+		data, err = io.ReadAll(os.Stdin)
+	case "":
+		path = filepath.Join(storage.FindConfigFiles(), name)
+		fallthrough
+	default:
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", name, err)
+	}
+	return bytes.NewReader(data), nil
 }
 
 func colorCompletionFunc(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
 	return []cobra.Completion{"always", "never", "auto"}, cobra.ShellCompDirectiveNoFileComp
 }
 
-// Execute runs the root command
-func Execute() error {
+// Execute runs the root command. Panics come back as an error pointing at a
+// diagnostics bundle instead of crashing.
+func Execute() (err error) {
+	defer recoverCrash(&err)
+	RootCmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return withExitCode(ExitUsage, err)
+	})
+	usageErrors(RootCmd)
+	aliases := loadAliases()
+	registerAliases(aliases)
+	commandArgs = expandAliases(os.Args[1:], aliases)
+	RootCmd.SetArgs(commandArgs)
 	return RootCmd.Execute()
 }
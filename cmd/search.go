@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var (
+	searchHabit string
+	searchCount bool
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <words>",
+	Short: "Find log entries by their comments",
+	Long: `Prints the log entries whose comments contain every one of the words, ignoring case.
+Words match anywhere in a comment, so "knee" finds "sore knees".`,
+	Example: `  harsh search knee
+  harsh search --habit run sore legs`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log := harsh.GetLog()
+		var matches []storage.DailyHabit
+		for _, key := range log.CommentIndex().Search(strings.Join(args, " ")) {
+			if strings.Contains(strings.ToLower(key.Habit), strings.ToLower(searchHabit)) {
+				matches = append(matches, key)
+			}
+		}
+		if searchCount {
+			fmt.Println(len(matches))
+			return nil
+		}
+		printEntries(log.Entries, matches)
+		return nil
+	},
+}
+
+func init() {
+	searchCmd.Flags().StringVar(&searchHabit, "habit", "", "only search entries of habits whose names contain this")
+	searchCmd.Flags().BoolVarP(&searchCount, "count", "c", false, "print only the number of matching entries")
+}
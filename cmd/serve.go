@@ -0,0 +1,348 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var (
+	serveListen string
+	servePort   int
+	serveHA     bool
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve your habits over HTTP",
+	Long: `Serves your habits as a JSON API, for phone shortcuts and home dashboards:
+
+  GET  /api/habits   every habit, with its frequency and first record
+  GET  /api/entries  entries in date order, narrowed with ?habit=, ?from=, and
+                     ?to= (the days harsh log shows by default)
+  GET  /api/log      what harsh log --json gives, with ?habit= and ?until=
+  GET  /api/stats    what harsh log stats --json gives
+  GET  /api/todo     what harsh todo --json gives
+  POST /api/entries  records an entry like harsh done, from a JSON object with
+                     habit and result, and optionally date, amount, and comment
+
+With --ha it also serves sensors for Home Assistant's RESTful sensor
+integration, each a JSON value with attributes:
+
+  GET /api/ha/score          today's score, with yesterday's and what's left to do
+  GET /api/ha/habits         every habit's sensor, keyed as below
+  GET /api/ha/habits/<habit> done, missed, skipped, or todo today, with the
+                             habit's frequency, whether it's satisfied, days
+                             since it was done, and its 30-day completion rate
+
+Habits are keyed as in MQTT topics: "Push ups" is push_ups, and a second habit
+with the same key gets push_ups_2. Your habits and log are reread whenever
+they've changed, answering from what was read before while they can't be. When HARSH_SERVE_TOKEN is set (or stored with harsh auth set
+serve), requests need it as a bearer token. It listens on localhost unless --listen says otherwise,
+which needs a token. Entries are only recorded from JSON requests, and not
+from pages on other sites.`,
+	Example: `  harsh serve --port 8080
+  curl -H 'Content-Type: application/json' -d '{"habit": "gym", "result": "y", "amount": 45}' localhost:8080/api/entries
+  harsh serve --ha
+  HARSH_SERVE_TOKEN=... harsh serve --ha --listen 0.0.0.0:8765`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listen := serveListen
+		if servePort != 0 {
+			host, _, err := net.SplitHostPort(serveListen)
+			if err != nil {
+				return withExitCode(ExitUsage, fmt.Errorf("invalid --listen %q: %w", serveListen, err))
+			}
+			listen = net.JoinHostPort(host, strconv.Itoa(servePort))
+		}
+		token := LookupCredential("serve")
+		if err := CheckListen(listen, token); err != nil {
+			return withExitCode(ExitUsage, err)
+		}
+		server := &http.Server{
+			Addr:              listen,
+			Handler:           requireToken(token, serveHandler(serveHA)),
+			ReadHeaderTimeout: serveTimeout,
+			ReadTimeout:       serveTimeout,
+			WriteTimeout:      serveTimeout,
+			IdleTimeout:       2 * time.Minute,
+		}
+		fmt.Printf("Serving your habits on http://%s/api/\n", listen)
+		if serveHA {
+			fmt.Printf("Serving Home Assistant sensors on http://%s/api/ha/\n", listen)
+		}
+		return server.ListenAndServe()
+	},
+}
+
+// serveTimeout is how long harsh serve waits on a request, and to answer it,
+// so slow clients can't hold on to it
+const serveTimeout = 30 * time.Second
+
+// ServeHandler returns what harsh serve answers with from h, the Home
+// Assistant sensors too with ha, and requiring token unless it's empty
+func ServeHandler(h *internal.Harsh, token string, ha bool) http.Handler {
+	harsh = h
+	wrapRepository()
+	return requireToken(token, serveHandler(ha))
+}
+
+// CheckListen refuses to serve beyond this machine on listen without a token
+func CheckListen(listen string, token string) error {
+	if token == "" && !loopback(listen) {
+		return fmt.Errorf("serving on %s needs HARSH_SERVE_TOKEN set, or stored with harsh auth set serve", listen)
+	}
+	return nil
+}
+
+// maxEntryRequest is the most POST /api/entries reads of a request's body
+const maxEntryRequest = 64 << 10
+
+// entryRequest is the JSON body POST /api/entries records
+type entryRequest struct {
+	Habit   string   `json:"habit"`
+	Result  string   `json:"result"`
+	Date    string   `json:"date"`
+	Amount  *float64 `json:"amount"`
+	Comment string   `json:"comment"`
+}
+
+// serveHandler serves the API, and the Home Assistant sensors with ha,
+// rereading the config directory when its habits, log, or settings have
+// changed so answers logged since show up, and keeping what was read before
+// while they can't be read. Requests are served one at a time
+// as a reload replaces harsh.
+func serveHandler(ha bool) http.Handler {
+	mux := http.NewServeMux()
+	apiRoutes(mux)
+	if ha {
+		homeAssistantRoutes(mux)
+	}
+
+	var mu sync.Mutex
+	configDir := harsh.GetRepository().GetConfigDir()
+	var loaded string
+	if configDir != "" {
+		loaded = storage.DataFingerprint(configDir)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if configDir != "" {
+			if fingerprint := storage.DataFingerprint(configDir); fingerprint != loaded {
+				// A half-saved file is tried again on the next request
+				if err := reloadHarsh(); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v, answering from what was read before\n", err)
+				} else {
+					loaded = fingerprint
+				}
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// apiRoutes adds the API's endpoints to mux
+func apiRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/habits", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ui.BuildHabits(harsh.GetHabits()))
+	})
+	mux.HandleFunc("GET /api/entries", func(w http.ResponseWriter, r *http.Request) {
+		to, err := queryDate(r, "to", clock.Today())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		from, err := queryDate(r, "from", to.AddDays(-harsh.CountBack))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		var name string
+		if query := r.URL.Query().Get("habit"); query != "" {
+			habit, err := harsh.FindHabit(query)
+			if err != nil {
+				writeError(w, withExitCode(ExitNotFound, err))
+				return
+			}
+			name = habit.Name
+		}
+		entries := harsh.GetLog().Entries
+		if repository, ok := harsh.GetRepository().(storage.RangeRepository); ok {
+			// Only the days asked for are read from the database
+			if entries, err = repository.EntriesBetween(name, from, to); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, ui.BuildEntries(&entries, name, from, to))
+	})
+	mux.HandleFunc("GET /api/log", func(w http.ResponseWriter, r *http.Request) {
+		until, err := queryDate(r, "until", civil.Date{})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, logReport(newDisplay(), r.URL.Query().Get("habit"), until))
+	})
+	mux.HandleFunc("GET /api/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ui.BuildStatsReport(harsh.GetHabits(), harsh.GetLog(), clock.Today()))
+	})
+	mux.HandleFunc("GET /api/todo", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ui.BuildTodos(harsh.GetHabits(), harsh.GetLog(), clock.Today(), loadSnoozed()))
+	})
+	mux.HandleFunc("POST /api/entries", func(w http.ResponseWriter, r *http.Request) {
+		if harsh.GetRepository().GetConfigDir() == "" {
+			writeError(w, withExitCode(ExitUsage, errors.New("cannot record entries when reading habits or log from a stream")))
+			return
+		}
+		if err := checkEntryRequest(r); err != nil {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+			return
+		}
+		var entry entryRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxEntryRequest)).Decode(&entry); err != nil {
+			writeError(w, withExitCode(ExitUsage, fmt.Errorf("cannot read entry: %w", err)))
+			return
+		}
+		if entry.Result == "" {
+			entry.Result = "y"
+		}
+		var amount string
+		if entry.Amount != nil {
+			amount = strconv.FormatFloat(*entry.Amount, 'f', -1, 64)
+		}
+		key, outcome, err := writeEntry(entry.Habit, entry.Result, entry.Date, entry.Comment, amount)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		publishMQTT()
+		syncAfterWrite("serve")
+		writeJSON(w, http.StatusCreated, ui.EntryReport{
+			Date:    key.Day.String(),
+			Habit:   key.Habit,
+			Result:  outcome.Result,
+			Comment: outcome.Comment,
+			Amount:  outcome.Amount,
+		})
+	})
+}
+
+// homeAssistantRoutes adds the Home Assistant sensors to mux
+func homeAssistantRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/ha/score", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ui.BuildHAScore(harsh.GetHabits(), harsh.GetLog(), clock.Today(), loadSnoozed()))
+	})
+	mux.HandleFunc("GET /api/ha/habits", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ui.BuildHAHabits(harsh.GetHabits(), harsh.GetLog(), clock.Today()))
+	})
+	mux.HandleFunc("GET /api/ha/habits/{habit}", func(w http.ResponseWriter, r *http.Request) {
+		sensor, ok := ui.BuildHAHabits(harsh.GetHabits(), harsh.GetLog(), clock.Today())[r.PathValue("habit")]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no habit " + r.PathValue("habit")})
+			return
+		}
+		writeJSON(w, http.StatusOK, sensor)
+	})
+}
+
+// checkEntryRequest refuses entries that aren't sent as JSON or come from a
+// page on another site. Browsers only send JSON across sites after asking,
+// so together they keep any page you visit from recording entries.
+func checkEntryRequest(r *http.Request) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errors.New("entries must be sent with Content-Type: application/json")
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+			return fmt.Errorf("entries cannot be recorded from %s", origin)
+		}
+	}
+	return nil
+}
+
+// loopback reports whether address only listens on this machine
+func loopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// queryDate reads the request's name parameter as a date, or fallback when
+// it isn't given
+func queryDate(r *http.Request, name string, fallback civil.Date) (civil.Date, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := civil.ParseDate(value)
+	if err != nil {
+		return d, withExitCode(ExitUsage, fmt.Errorf("invalid %s date %q (expected YYYY-MM-DD)", name, value))
+	}
+	return d, nil
+}
+
+// writeError answers with err, as a bad request or not found for the errors
+// harsh exits with ExitUsage or ExitNotFound for
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch ExitCode(err) {
+	case ExitUsage:
+		status = http.StatusBadRequest
+	case ExitNotFound:
+		status = http.StatusNotFound
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// requireToken refuses requests without token as a bearer token, unless
+// token is empty
+func requireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or wrong bearer token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveListen, "listen", "127.0.0.1:8765", "address to listen on")
+	serveCmd.Flags().IntVar(&servePort, "port", 0, "port to listen on, in place of --listen's")
+	serveCmd.Flags().BoolVar(&serveHA, "ha", false, "serve sensors for Home Assistant's RESTful sensor integration")
+}
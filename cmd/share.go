@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var (
+	shareFormat       string
+	shareDays         int
+	sharePseudonymize bool
+	shareOutput       string
+)
+
+var shareCmd = &cobra.Command{
+	Use:   "share",
+	Short: "Export an anonymised snapshot of your progress",
+	Long:  "Exports recent progress as JSON or an HTML snippet with comments and amounts stripped, and optionally habit names pseudonymised, for sharing with accountability partners.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if shareDays < 1 {
+			return fmt.Errorf("--days must be at least 1")
+		}
+		share := ui.BuildShare(
+			harsh.GetHabits(),
+			harsh.GetLog(),
+			clock.Today(),
+			shareDays,
+			sharePseudonymize,
+		)
+
+		var out []byte
+		switch shareFormat {
+		case "json":
+			var err error
+			out, err = share.JSON()
+			if err != nil {
+				return err
+			}
+			out = append(out, '\n')
+		case "html":
+			out = []byte(share.HTML())
+		default:
+			return fmt.Errorf(`invalid format "%s". should be "json" or "html"`, shareFormat)
+		}
+
+		if shareOutput == "" || shareOutput == "-" {
+			_, err := os.Stdout.Write(out)
+			return err
+		}
+		return os.WriteFile(shareOutput, out, 0644)
+	},
+}
+
+func init() {
+	shareCmd.Flags().StringVarP(&shareFormat, "format", "f", "json", `snapshot format, "json" or "html"`)
+	shareCmd.Flags().IntVar(&shareDays, "days", 30, "number of days of history to include")
+	shareCmd.Flags().BoolVarP(&sharePseudonymize, "pseudonymize", "p", false, `replace habit names with "Habit N" and drop headings`)
+	shareCmd.Flags().StringVarP(&shareOutput, "output", "o", "", "write the snapshot to a file instead of stdout")
+	shareCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		return []cobra.Completion{"json", "html"}, cobra.ShellCompDirectiveNoFileComp
+	})
+}
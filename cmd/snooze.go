@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var snoozeUndo bool
+
+var snoozeCmd = &cobra.Command{
+	Use:   "snooze [habit]",
+	Short: "Hide a habit from today's todos and ask",
+	Long: `Hides a habit from todo and ask for the rest of today without logging anything,
+for when you'd rather decide later than mark it skipped. Tomorrow it's back, and
+yesterday's answer can still be given. Without a habit, lists what's snoozed.`,
+	Example: `  harsh snooze gym
+  harsh snooze --undo gym`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configDir := harsh.GetRepository().GetConfigDir()
+		if configDir == "" {
+			return errors.New("cannot snooze habits when reading them from a stream")
+		}
+		snoozed, err := storage.LoadSnoozed(configDir, clock.Today())
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			if len(snoozed.Habits) == 0 {
+				fmt.Println("Nothing snoozed today.")
+				return nil
+			}
+			fmt.Printf("Snoozed today: %s\n", strings.Join(snoozed.Habits, ", "))
+			return nil
+		}
+
+		habit, err := harsh.FindHabit(args[0])
+		if err != nil {
+			return err
+		}
+		if snoozeUndo {
+			snoozed.Remove(habit.Name)
+		} else {
+			snoozed.Add(habit.Name)
+		}
+		if err := snoozed.Save(configDir); err != nil {
+			return err
+		}
+		if snoozeUndo {
+			fmt.Printf("%s is back on today's todos.\n", habit.Name)
+		} else {
+			fmt.Printf("Snoozed %s for today.\n", habit.Name)
+		}
+		return nil
+	},
+}
+
+// loadSnoozed returns today's snoozed habits, warning rather than failing
+// when the snooze file can't be read
+func loadSnoozed() storage.Snoozed {
+	configDir := harsh.GetRepository().GetConfigDir()
+	if configDir == "" {
+		return storage.Snoozed{Day: clock.Today()}
+	}
+	snoozed, err := storage.LoadSnoozed(configDir, clock.Today())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return snoozed
+}
+
+func init() {
+	snoozeCmd.Flags().BoolVar(&snoozeUndo, "undo", false, "put the habit back on today's todos")
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var sqlSchema bool
+
+var sqlCmd = &cobra.Command{
+	Use:   "sql <query>",
+	Short: "Run a read-only SQL query against your entries with the sqlite backend",
+	Long: `Runs a SQL query against ` + storage.SQLiteFile + ` with the sqlite backend and prints
+the rows, or a JSON array of them with --json. The tables are:
+
+` + storage.QuerySchema + `
+
+The schema is kept stable, so new columns may turn up but none are renamed or
+removed. Only one query that reads runs at a time: the database is opened read
+only, in sqlite3's safe mode, so nothing can be written and no other files
+reached. It needs sqlite3 3.37 or newer.`,
+	Example: `  harsh sql "SELECT habit, count(*) FROM entries WHERE result = 'y' GROUP BY habit"
+  harsh sql "SELECT h.name, sum(e.amount) FROM habits h JOIN entries e ON e.habit = h.name GROUP BY 1" --json
+  harsh sql --schema`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if sqlSchema {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if sqlSchema {
+			fmt.Println(storage.QuerySchema)
+			return nil
+		}
+		configDir := harsh.GetRepository().GetConfigDir()
+		if configDir == "" {
+			return errors.New("cannot query entries when reading habits or log from a stream")
+		}
+		if !usingSQLite() {
+			return withExitCode(ExitUsage, errors.New("harsh sql needs the sqlite backend, move your entries over with harsh backend sqlite"))
+		}
+		if err := storage.CheckReadOnlyQuery(args[0]); err != nil {
+			return withExitCode(ExitUsage, err)
+		}
+
+		repository := storage.NewSQLiteRepository(configDir)
+		if jsonOutput {
+			out, err := repository.QueryJSON(args[0], harsh.GetHabits())
+			if err != nil {
+				return err
+			}
+			var indented bytes.Buffer
+			if err := json.Indent(&indented, bytes.TrimSpace(out), "", "  "); err != nil {
+				return fmt.Errorf("cannot read sqlite3's JSON: %w", err)
+			}
+			fmt.Println(indented.String())
+			return nil
+		}
+		columns, rows, err := repository.Query(args[0], harsh.GetHabits())
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 {
+			fmt.Println("No rows.")
+			return nil
+		}
+		printTable(columns, rows)
+		return nil
+	},
+}
+
+// printTable prints rows under their column names, in columns as wide as
+// their widest value
+func printTable(columns []string, rows [][]string) {
+	widths := make([]int, len(columns))
+	for _, row := range append([][]string{columns}, rows...) {
+		for i, value := range row {
+			if i < len(widths) {
+				widths[i] = max(widths[i], utf8.RuneCountInString(value))
+			}
+		}
+	}
+	for _, row := range append([][]string{columns}, rows...) {
+		var b strings.Builder
+		for i, value := range row {
+			if i == len(row)-1 {
+				b.WriteString(value)
+				break
+			}
+			fmt.Fprintf(&b, "%-*s  ", widths[i], value)
+		}
+		fmt.Println(strings.TrimRight(b.String(), " "))
+	}
+}
+
+func init() {
+	sqlCmd.Flags().BoolVar(&sqlSchema, "schema", false, "print the tables queries can use")
+}
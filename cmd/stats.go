@@ -1,23 +1,112 @@
 package cmd
 
 import (
-	"github.com/gookit/color"
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
 	"github.com/wakatara/harsh/internal/ui"
 )
 
+var (
+	statsAge         bool
+	statsAllProfiles bool
+	statsGoal        bool
+	statsReasons     bool
+)
+
 var statsCmd = &cobra.Command{
 	Use:     "stats",
 	Short:   "Show habit stats for entire log file",
 	Long:    "Shows statistics for all habits including streaks, breaks, skips, and totals.",
 	Aliases: []string{"s"},
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			// harsh log <habit> <result> for a habit named like stats ends up here
+			cmd.SilenceUsage = true
+			name := cmd.CalledAs()
+			if name == "" {
+				name = cmd.Name()
+			}
+			return withExitCode(ExitUsage, fmt.Errorf("harsh log %s takes no arguments, to record a habit matching %q use harsh done %s %s",
+				name, name, name, strings.Join(args, " ")))
+		}
+		return nil
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		display := ui.NewDisplay(!color.Enable)
+		display := newDisplay()
+		if statsAllProfiles {
+			if statsGoal || statsReasons || statsAge || len(habitTags) > 0 {
+				return withExitCode(ExitUsage, errors.New("--all-profiles only covers the stats themselves, not --goal, --reasons, --age, or --tag"))
+			}
+			configDir := harsh.GetRepository().GetConfigDir()
+			names, err := storage.ListProfiles(configDir)
+			if err != nil {
+				return err
+			}
+			if len(names) == 0 {
+				return fmt.Errorf("--all-profiles needs profiles in %s", storage.ProfileDir(configDir, ""))
+			}
+			profiles, err := loadProfiles(configDir, names, display.Today())
+			if err != nil {
+				return err
+			}
+			groups := ui.BuildProfileStats(profiles, display.Today())
+			if jsonOutput {
+				return printJSON(groups)
+			}
+			maxHabitNameLength := 0
+			for _, group := range groups {
+				maxHabitNameLength = max(maxHabitNameLength, len(group.Name)+10)
+			}
+			display.ShowProfileStats(groups, maxHabitNameLength)
+			return nil
+		}
+		if err := keepTagged(); err != nil {
+			return err
+		}
+		if jsonOutput {
+			if statsGoal || statsReasons || statsAge {
+				return withExitCode(ExitUsage, errors.New("--json only covers the stats themselves, not --goal, --reasons, or --age"))
+			}
+			return printJSON(ui.BuildStatsReport(harsh.GetHabits(), harsh.GetLog(), display.Today()))
+		}
+		if statsGoal {
+			display.ScoreGoal = float64(harsh.GetSettings().Int("score_goal"))
+			display.ShowGoalMonths(harsh.GetHabits(), harsh.GetLog())
+			return nil
+		}
+		if statsReasons {
+			display.ShowBreakReasons(
+				harsh.GetHabits(),
+				harsh.GetLog(),
+				harsh.GetMaxHabitNameLength(),
+			)
+			return nil
+		}
+		if statsAge {
+			display.ShowHabitAge(
+				harsh.GetHabits(),
+				harsh.GetLog(),
+				harsh.GetMaxHabitNameLength(),
+			)
+			return nil
+		}
 		display.ShowHabitStats(
 			harsh.GetHabits(),
-			&harsh.GetLog().Entries,
+			harsh.GetLog(),
 			harsh.GetMaxHabitNameLength(),
 		)
 		return nil
 	},
 }
+
+func init() {
+	statsCmd.Flags().BoolVar(&statsAge, "age", false, "show completion rate by month since each habit's first record")
+	statsCmd.Flags().BoolVar(&statsAllProfiles, "all-profiles", false, "sum up habits of the same name across every profile, with each profile's share")
+	statsCmd.Flags().BoolVar(&statsGoal, "goal", false, "show how many days reached the score_goal setting each month")
+	statsCmd.Flags().BoolVar(&statsReasons, "reasons", false, "show the reasons given for each habit's broken streaks")
+	addTagFlag(statsCmd)
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/gitsync"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Sync your habits and log with a git remote",
+	Long: `Commits the changes in your config directory, merges in the git remote's, and
+pushes the result, to keep harsh the same on several machines. Start with harsh
+sync init <remote> on each of them. The log is merged line by line, so entries
+logged on two machines are both kept. Your signing key and cached includes stay
+out of the remote.
+
+With the git_sync setting on, harsh ask pulls before asking and ask, done, edit,
+and tui sync after logging by themselves. It works with the file backend, as a
+SQLite database can't be merged.`,
+	Example: `  harsh sync init git@github.com:me/harsh-data.git
+  harsh config set git_sync true
+  harsh sync`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configDir, err := syncDir()
+		if err != nil {
+			return err
+		}
+		if !gitsync.IsRepository(configDir) {
+			return withExitCode(ExitUsage, fmt.Errorf("%s isn't a git repository yet, start with harsh sync init <remote>", configDir))
+		}
+		result, err := gitsync.Sync(configDir, syncMessage("sync"))
+		if err != nil {
+			return syncError(err)
+		}
+		switch {
+		case !result.Pushed && result.Committed:
+			fmt.Println("Committed your changes, add a remote with harsh sync init <remote> to sync them.")
+		case !result.Pushed:
+			fmt.Println("Nothing to sync, add a remote with harsh sync init <remote>.")
+		default:
+			done := []string{}
+			if result.Committed {
+				done = append(done, "sent your changes")
+			}
+			if result.Pulled {
+				done = append(done, "merged in its changes")
+			}
+			if len(done) == 0 {
+				done = append(done, "already the same")
+			}
+			fmt.Printf("Synced with %s: %s.\n", gitsync.Remote, strings.Join(done, ", "))
+		}
+		if result.Pulled {
+			showMerged(harsh.GetLog().Entries, storage.LoadLog(configDir).Entries)
+		}
+		return nil
+	},
+}
+
+var syncInitCmd = &cobra.Command{
+	Use:   "init [remote]",
+	Short: "Make your config directory a git repository to sync",
+	Long: `Makes your config directory a git repository, unless it is already, with
+remote as where it syncs to, and commits your habits and log. Run it on every
+machine with the same remote, then harsh sync merges their logs together.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configDir, err := syncDir()
+		if err != nil {
+			return err
+		}
+		var remote string
+		if len(args) > 0 {
+			remote = args[0]
+		}
+		if err := gitsync.Init(configDir, remote); err != nil {
+			return err
+		}
+		if remote == "" {
+			fmt.Printf("Made %s a git repository, add a remote with harsh sync init <remote> to sync it.\n", configDir)
+			return nil
+		}
+		fmt.Printf("Made %s a git repository syncing with %s, run harsh sync to sync it now.\n", configDir, remote)
+		return nil
+	},
+}
+
+// syncDir returns the config directory to sync, refusing streams and the
+// SQLite backend
+func syncDir() (string, error) {
+	configDir := harsh.GetRepository().GetConfigDir()
+	if configDir == "" {
+		return "", errors.New("cannot sync when reading habits or log from a stream")
+	}
+	if usingSQLite() {
+		return "", withExitCode(ExitUsage, fmt.Errorf("harsh sync keeps the log in sync, and %s can't be merged, move back with harsh backend file", storage.SQLiteFile))
+	}
+	return configDir, nil
+}
+
+// showMerged shows the entries a pull brought in, and the days it changed
+// the answer for
+func showMerged(before storage.Entries, after storage.Entries) {
+	diff := ui.BuildLogDiff(before, after, nil)
+	display := newDisplay()
+	display.ShowAdded(diff)
+	if len(diff.Conflicts) > 0 {
+		fmt.Printf("Changed %d days already logged here:\n", len(diff.Conflicts))
+		display.ShowConflicts(diff)
+	}
+}
+
+// syncMessage is the commit message for changes made by command
+func syncMessage(command string) string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("harsh %s on %s", command, hostname)
+}
+
+// syncError points to how to finish a merge that conflicted
+func syncError(err error) error {
+	if strings.Contains(err.Error(), "CONFLICT") || strings.Contains(err.Error(), "conflict") {
+		return fmt.Errorf("%w\nFix the conflicting lines in your config directory, then commit them with git and run harsh sync again", err)
+	}
+	return err
+}
+
+// gitSyncing reports whether the git_sync setting is on for a config
+// directory harsh sync init has set up
+func gitSyncing(settings *storage.Settings, configDir string) bool {
+	return configDir != "" && settings.Bool("git_sync") && gitsync.IsRepository(configDir)
+}
+
+// pullBeforeAsk merges in the remote's entries before harsh ask reads the
+// log, so nothing answered on another machine is asked again
+func pullBeforeAsk(configDir string) {
+	settings, err := storage.LoadSettings(configDir)
+	if err != nil || !gitSyncing(settings, configDir) || usingSQLite() {
+		return
+	}
+	// Answers logged here since the last sync are committed first, as git
+	// won't merge over uncommitted changes
+	_, err = gitsync.Commit(configDir, syncMessage("ask"))
+	if err == nil {
+		_, err = gitsync.Pull(configDir)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot pull before asking: %v\n", syncError(err))
+	}
+}
+
+// syncAfterWrite syncs after command logs when git_sync is on, warning
+// rather than failing so a remote being down never loses answers
+func syncAfterWrite(command string) {
+	configDir := harsh.GetRepository().GetConfigDir()
+	if !gitSyncing(harsh.GetSettings(), configDir) || usingSQLite() {
+		return
+	}
+	if _, err := gitsync.Sync(configDir, syncMessage(command)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", syncError(err))
+	}
+}
+
+func init() {
+	syncCmd.AddCommand(syncInitCmd)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// habitTags are the tags --tag narrows habits to
+var habitTags []string
+
+// addTagFlag lets cmd narrow the habits it works with to tagged ones
+func addTagFlag(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&habitTags, "tag", nil, "only habits tagged with this in the habits file, eg. fitness (repeat for any of several)")
+	cmd.RegisterFlagCompletionFunc("tag", func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		var tags []cobra.Completion
+		for _, habit := range harsh.GetHabits() {
+			for _, tag := range habit.Tags {
+				if !slices.Contains(tags, tag) {
+					tags = append(tags, tag)
+				}
+			}
+		}
+		return tags, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// keepTagged narrows harsh's habits to those tagged as --tag asks
+func keepTagged() error {
+	if len(habitTags) == 0 {
+		return nil
+	}
+	for i, tag := range habitTags {
+		habitTags[i] = strings.TrimPrefix(tag, "#")
+	}
+	if !harsh.KeepTagged(habitTags) {
+		return withExitCode(ExitNotFound, fmt.Errorf("no habit is tagged #%s, tag habits after their frequency like \"Run 5k: 3/7 #fitness\"", strings.Join(habitTags, " or #")))
+	}
+	return nil
+}
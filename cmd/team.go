@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var (
+	teamProfiles []string
+	teamDays     int
+)
+
+var teamCmd = &cobra.Command{
+	Use:   "team",
+	Short: "Show a leaderboard of habits shared across profiles",
+	Long:  "Loads several profiles' habits and logs (from the profiles folder of your harsh config directory) and shows a combined leaderboard of the habits they share.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configDir := harsh.GetRepository().GetConfigDir()
+		names := teamProfiles
+		if len(names) == 0 {
+			var err error
+			names, err = storage.ListProfiles(configDir)
+			if err != nil {
+				return err
+			}
+		}
+		if len(names) < 2 {
+			return fmt.Errorf("team mode needs at least two profiles in %s", storage.ProfileDir(configDir, ""))
+		}
+		if teamDays < 1 {
+			return fmt.Errorf("--days must be at least 1")
+		}
+
+		display := newDisplay()
+		profiles, err := loadProfiles(configDir, names, display.Today())
+		if err != nil {
+			return err
+		}
+		maxHabitNameLength := 0
+		for _, profile := range profiles {
+			maxHabitNameLength = max(maxHabitNameLength, len(profile.Name)+10)
+		}
+
+		display.ShowTeam(profiles, teamDays, maxHabitNameLength)
+		return nil
+	},
+}
+
+// loadProfiles loads the named profiles from configDir, with their habits'
+// first records found as of day now
+func loadProfiles(configDir string, names []string, now civil.Date) ([]*storage.Profile, error) {
+	profiles := make([]*storage.Profile, 0, len(names))
+	for _, name := range names {
+		profile, err := storage.LoadProfile(configDir, strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		profile.Log.Entries.FirstRecords(now.AddDays(-365*5), now, profile.Habits)
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+func init() {
+	teamCmd.Flags().StringSliceVar(&teamProfiles, "profiles", nil, "comma separated profiles to include (defaults to all profiles)")
+	teamCmd.Flags().IntVar(&teamDays, "days", 30, "number of days the leaderboard covers")
+}
@@ -1,23 +1,48 @@
 package cmd
 
 import (
-	"github.com/gookit/color"
+	"fmt"
+
 	"github.com/spf13/cobra"
 	"github.com/wakatara/harsh/internal/ui"
 )
 
+var todoFormat string
+
 var todoCmd = &cobra.Command{
 	Use:     "todo",
 	Short:   "Show undone habits for today",
 	Long:    "Shows undone habits for today and recent days.",
 	Aliases: []string{"t"},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		display := ui.NewDisplay(!color.Enable)
+		if err := keepTagged(); err != nil {
+			return err
+		}
+		display := newDisplay()
+		display.Snoozed = loadSnoozed()
+		if jsonOutput {
+			todoFormat = "json"
+		}
+		switch todoFormat {
+		case "text":
+		case "json":
+			return printJSON(ui.BuildTodos(harsh.GetHabits(), harsh.GetLog(), display.Today(), display.Snoozed))
+		default:
+			return withExitCode(ExitUsage, fmt.Errorf(`invalid format "%s". should be "text" or "json"`, todoFormat))
+		}
+		display.Quotes = loadQuotes()
+		display.StaleAfterDays = harsh.GetSettings().Int("stale_after_days")
+		display.Challenges = loadChallenges()
 		display.ShowTodos(
 			harsh.GetHabits(),
-			&harsh.GetLog().Entries,
+			harsh.GetLog(),
 			harsh.GetMaxHabitNameLength(),
 		)
 		return nil
 	},
 }
+
+func init() {
+	todoCmd.Flags().StringVarP(&todoFormat, "format", "f", "text", `output format, "text" or "json" (same as --json)`)
+	addTagFlag(todoCmd)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+	"golang.org/x/term"
+)
+
+var tuiAll bool
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui [habit-fragment]",
+	Short: "Correct any habit's recent days in a full-screen grid",
+	Long: `Shows your habits' graphs as a full-screen grid to move around and correct
+past days in. Type y, n, or s to set the day under the cursor and move to the
+next habit, space to cycle it, and u to undo. Arrow keys or hjkl move around,
+scrolling back past the first day shown. Nothing is written until you save with
+w, when every change is logged together in one write. q quits without saving.`,
+	Example: `  harsh tui
+  harsh tui gym`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if nonInteractive {
+			return withExitCode(ExitNeedsInput, errors.New("tui is interactive, use harsh done <habit> [y|n|s] --date with --non-interactive"))
+		}
+		var habitFragment string
+		if len(args) > 0 {
+			habitFragment = args[0]
+		}
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return errors.New("tui needs a terminal to read keys from")
+		}
+
+		display := newDisplay()
+		display.ShowHidden = tuiAll
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			width, height = 120, 24
+		}
+		editor := display.NewGridEditor(harsh.GetHabits(), &harsh.GetLog().Entries, habitFragment, width, height)
+		if len(editor.Habits) == 0 {
+			return withExitCode(ExitNotFound, fmt.Errorf("no habits match %q", habitFragment))
+		}
+
+		// The same render, read key, update loop as edit-month, rather
+		// than bubbletea: raw mode from x/term is all it needs, and it keeps
+		// harsh to the one terminal dependency it already had
+		state, err := term.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			return fmt.Errorf("cannot read keys from the terminal: %w", err)
+		}
+		renderer := ui.NewRenderer(os.Stdout)
+		action := ui.MonthEditorContinue
+		for action == ui.MonthEditorContinue {
+			if err = renderer.Render(editor.Render()); err != nil {
+				break
+			}
+			var key string
+			if key, err = ui.ReadKey(os.Stdin); err != nil {
+				break
+			}
+			action = editor.HandleKey(key)
+		}
+		term.Restore(int(os.Stdin.Fd()), state)
+		if err != nil {
+			return err
+		}
+		if action == ui.MonthEditorQuit {
+			fmt.Println("Quit without saving.")
+			return nil
+		}
+
+		changes := editor.Changes()
+		writes := make([]storage.EntryWrite, len(changes))
+		for i, change := range changes {
+			writes[i] = storage.OutcomeWrite(storage.DailyHabit{Day: change.Day, Habit: change.Habit}, change.Outcome)
+		}
+		if err := storage.WriteEntries(harsh.GetRepository(), writes, harsh.GetLog().Header); err != nil {
+			return err
+		}
+		fmt.Printf("Saved %d changes.\n", len(changes))
+		if len(changes) > 0 {
+			publishMQTT()
+			syncAfterWrite("tui")
+		}
+		return nil
+	},
+}
+
+func init() {
+	tuiCmd.Flags().BoolVar(&tuiAll, "all", false, "include habits marked hidden=yes")
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+var verifySign bool
+
+// maxListedLines is how many line numbers of each kind harsh verify lists
+const maxListedLines = 10
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the log against its entry signatures",
+	Long: `Checks every line of the log against the signatures written alongside each entry
+and note with the sign_entries setting on, to catch historical lines that were
+edited, removed, reordered, or corrupted. Each signature is chained to the one
+before, so from the first signed line on every line has to follow on from the
+signatures in order: lines added by hand, changed, or moved are a failure, as
+are signatures whose line is gone, and the exit code is 1. Lines before the
+first signed one, from before signing was turned on, are listed but aren't.
+
+After fixing the log on purpose, or merging it from another machine, --sign
+re-signs it as it stands.
+
+Entries are signed with the key in HARSH_SIGNING_KEY or stored with harsh auth
+set signing, or else the signing.key file harsh creates in your config
+directory. Keep the key out of the config
+directory (and out of sync) if you want to detect deliberate edits rather than
+just accidents.`,
+	Example: `  harsh verify
+  harsh verify --sign`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configDir := harsh.GetRepository().GetConfigDir()
+		if configDir == "" {
+			return errors.New("cannot verify the log when reading habits or log from a stream")
+		}
+		if usingSQLite() {
+			return errors.New("entries are in " + storage.SQLiteFile + " with the sqlite backend, there's no log to verify")
+		}
+		data, err := os.ReadFile(filepath.Join(configDir, "log"))
+		if err != nil {
+			return fmt.Errorf("cannot read log: %w", err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+
+		if verifySign {
+			key, err := signingKey(configDir)
+			if err != nil {
+				return err
+			}
+			signatures := storage.SignLog(lines, key)
+			if err := storage.WriteSignatures(configDir, signatures); err != nil {
+				return err
+			}
+			fmt.Printf("Signed all %d lines of the log as it stands.\n", len(signatures))
+			return nil
+		}
+
+		signatures, err := storage.LoadSignatures(configDir)
+		if err != nil {
+			return err
+		}
+		if len(signatures) == 0 {
+			fmt.Println("Nothing is signed yet. Turn on `harsh config set sign_entries true` to sign new entries, and run harsh verify --sign to sign the log so far.")
+			return nil
+		}
+		key, err := signingKey(configDir)
+		if err != nil {
+			return err
+		}
+		v := storage.VerifyLog(lines, key, signatures)
+		fmt.Printf("%d signed lines intact.\n", v.Signed)
+		if len(v.Unsigned) > 0 {
+			fmt.Printf("%d lines from before signing aren't signed: %s.\n", len(v.Unsigned), listLines(v.Unsigned))
+		}
+		if len(v.Broken) > 0 {
+			fmt.Printf("%d lines don't follow on from the signatures (added by hand, changed, or moved): %s.\n", len(v.Broken), listLines(v.Broken))
+		}
+		if !v.Intact() {
+			return fmt.Errorf("the log was changed since it was signed: %d lines out of place and %d signed lines changed or removed", len(v.Broken), v.Orphaned)
+		}
+		return nil
+	},
+}
+
+// listLines lists line numbers, up to maxListedLines of them
+func listLines(numbers []int) string {
+	listed := make([]string, 0, maxListedLines)
+	for _, n := range numbers[:min(len(numbers), maxListedLines)] {
+		listed = append(listed, strconv.Itoa(n))
+	}
+	more := ""
+	if len(numbers) > maxListedLines {
+		more = fmt.Sprintf(" and %d more", len(numbers)-maxListedLines)
+	}
+	return "line " + strings.Join(listed, ", ") + more
+}
+
+// signingKey returns the key entries are signed with, from HARSH_SIGNING_KEY
+// or the keychain, or else the key file in configDir
+func signingKey(configDir string) ([]byte, error) {
+	if key := LookupCredential("signing"); key != "" {
+		return []byte(key), nil
+	}
+	return storage.LoadSigningKey(configDir)
+}
+
+func init() {
+	verifyCmd.Flags().BoolVar(&verifySign, "sign", false, "re-sign the whole log as it stands, eg. after fixing it on purpose")
+}
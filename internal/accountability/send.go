@@ -0,0 +1,126 @@
+// Package accountability delivers check-in messages to an accountability
+// partner by email or to a Matrix room.
+package accountability
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a check-in to send
+type Message struct {
+	Subject string
+	Body    string
+}
+
+// SMTPServer is the mail server email check-ins are sent through
+type SMTPServer struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	// From is the sender address, User when empty
+	From string
+}
+
+// IsMatrixRoom reports whether a recipient is a Matrix room ID, eg.
+// "!abc123:matrix.org", rather than an email address
+func IsMatrixRoom(to string) bool {
+	return strings.HasPrefix(to, "!")
+}
+
+// SendEmail mails the message to the address to
+func SendEmail(server SMTPServer, to string, message Message) error {
+	if server.Host == "" {
+		return fmt.Errorf("no mail server set, set smtp_host (and smtp_user) with harsh config set")
+	}
+	from := server.From
+	if from == "" {
+		from = server.User
+	}
+	if from == "" {
+		return fmt.Errorf("no sender address, set smtp_from or smtp_user with harsh config set")
+	}
+
+	var auth smtp.Auth
+	if server.User != "" {
+		auth = smtp.PlainAuth("", server.User, server.Password, server.Host)
+	}
+	address := net.JoinHostPort(server.Host, strconv.Itoa(server.Port))
+	if err := smtp.SendMail(address, auth, from, []string{to}, composeEmail(from, to, message)); err != nil {
+		return fmt.Errorf("cannot send email through %s: %w", address, err)
+	}
+	return nil
+}
+
+// composeEmail formats the message as a plain text UTF-8 email
+func composeEmail(from string, to string, message Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// SendMatrix posts the message to a Matrix room on homeserver, eg.
+// "https://matrix.org", with a user's access token. The body is sent in a
+// preformatted block so graphs stay aligned.
+func SendMatrix(homeserver string, token string, room string, message Message) error {
+	if homeserver == "" {
+		return fmt.Errorf("no Matrix homeserver set, set matrix_homeserver with harsh config set")
+	}
+	if token == "" {
+		return fmt.Errorf("no Matrix access token, set the HARSH_MATRIX_TOKEN environment variable")
+	}
+
+	text := message.Subject + "\n\n" + message.Body
+	event, err := json.Marshal(map[string]string{
+		"msgtype":        "m.text",
+		"body":           text,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": "<p><strong>" + html.EscapeString(message.Subject) + "</strong></p><pre>" + html.EscapeString(message.Body) + "</pre>",
+	})
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/harsh-%d",
+		strings.TrimRight(homeserver, "/"), url.PathEscape(room), time.Now().UnixNano())
+	request, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(event))
+	if err != nil {
+		return fmt.Errorf("invalid Matrix homeserver %q: %w", homeserver, err)
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("cannot reach Matrix homeserver: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		var matrixError struct {
+			Error string `json:"error"`
+		}
+		data, _ := io.ReadAll(response.Body)
+		if json.Unmarshal(data, &matrixError) == nil && matrixError.Error != "" {
+			return fmt.Errorf("matrix refused the message: %s (%s)", matrixError.Error, response.Status)
+		}
+		return fmt.Errorf("matrix refused the message: %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+// Package clock tells the rest of harsh what day it is. Commands, graphs, and
+// views ask it rather than the system clock directly, so the date can be fixed
+// for reproducible reports, tests, and looking at what a view would show on
+// another day.
+package clock
+
+import (
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+// Clock reports the current date
+type Clock interface {
+	Today() civil.Date
+}
+
+// System is the clock of the machine harsh runs on
+type System struct {
+	// RolloverHour is the hour a new day starts, so times before it still
+	// belong to the day before, eg. 4 for night owls
+	RolloverHour int
+}
+
+// Today returns the local date
+func (s System) Today() civil.Date {
+	return civil.DateOf(time.Now().Add(-time.Duration(s.RolloverHour) * time.Hour))
+}
+
+// Fixed is a clock stopped on one date
+type Fixed civil.Date
+
+// Today returns the fixed date
+func (f Fixed) Today() civil.Date {
+	return civil.Date(f)
+}
+
+// Default is the clock harsh uses, the system clock unless replaced
+var Default Clock = System{}
+
+// Today returns the current date according to the Default clock
+func Today() civil.Date {
+	return Default.Today()
+}
@@ -0,0 +1,226 @@
+// Package gitsync keeps a config directory the same on several machines
+// through a git remote: local changes are committed, the remote's merged in,
+// and the result pushed back. The log and the other files harsh only appends
+// to merge as a union, so entries logged on two machines keep both sides
+// rather than conflicting. An entry changed in place on both machines, eg. by
+// harsh edit, keeps both lines too, and the one merged in last wins.
+package gitsync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Remote is the git remote synced with
+const Remote = "origin"
+
+// Attributes are written to .gitattributes by Init, merging the files harsh
+// appends to as a union of both sides' lines
+const Attributes = `log merge=union
+log.sig merge=union
+writes merge=union
+frequencies merge=union
+retired merge=union
+`
+
+// Ignores are written to .gitignore by Init, keeping secrets, caches, and
+// files harsh is halfway through writing out of the remote
+const Ignores = `signing.key
+includes/
+*.tmp
+log.db
+scores
+`
+
+// Result is what a Sync did
+type Result struct {
+	// Committed is whether there were local changes to commit
+	Committed bool
+	// Pulled is whether the remote had changes to merge in
+	Pulled bool
+	// Pushed is whether there's a remote to push to
+	Pushed bool
+}
+
+// IsRepository reports whether dir is the top of a git repository, rather
+// than just inside one such as a dotfiles repository
+func IsRepository(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// Init makes dir a git repository ready to sync, with remote as its Remote
+// when given, and commits what's there. Files already set up get the lines
+// they're missing added.
+func Init(dir string, remote string) error {
+	if !IsRepository(dir) {
+		if _, err := run(dir, "init", "-q"); err != nil {
+			return err
+		}
+	}
+	for name, content := range map[string]string{".gitattributes": Attributes, ".gitignore": Ignores} {
+		if err := addLines(filepath.Join(dir, name), content); err != nil {
+			return err
+		}
+	}
+	if remote != "" {
+		change := "add"
+		if _, err := run(dir, "remote", "get-url", Remote); err == nil {
+			change = "set-url"
+		}
+		if _, err := run(dir, "remote", change, Remote, remote); err != nil {
+			return err
+		}
+	}
+	_, err := Commit(dir, "Start syncing harsh")
+	return err
+}
+
+// Commit commits every change in dir with message, reporting whether there
+// were any. It refuses while a merge is unfinished, rather than committing
+// its conflict markers.
+func Commit(dir string, message string) (bool, error) {
+	if _, err := run(dir, "rev-parse", "-q", "--verify", "MERGE_HEAD"); err == nil {
+		return false, fmt.Errorf("cannot sync during a merge, finish or abort it in %s first", dir)
+	}
+	if unmerged, err := run(dir, "diff", "--name-only", "--diff-filter=U"); err != nil {
+		return false, err
+	} else if unmerged != "" {
+		return false, fmt.Errorf("cannot sync with unmerged files, resolve them in %s first: %s", dir, strings.ReplaceAll(unmerged, "\n", ", "))
+	}
+	if _, err := run(dir, "add", "-A"); err != nil {
+		return false, err
+	}
+	if status, err := run(dir, "status", "--porcelain"); err != nil || status == "" {
+		return false, err
+	}
+	_, err := runAs(dir, committer(dir), "commit", "-q", "-m", message)
+	return err == nil, err
+}
+
+// Pull merges in the Remote's changes, reporting whether there were any.
+// It does nothing without a remote or before anything's been pushed to it.
+// A machine that started syncing on its own merges the other's history in.
+func Pull(dir string) (bool, error) {
+	branch, ok, err := remoteBranch(dir)
+	if err != nil || !ok {
+		return false, err
+	}
+	if heads, err := run(dir, "ls-remote", "--heads", Remote, branch); err != nil || heads == "" {
+		return false, err
+	}
+	before, _ := run(dir, "rev-parse", "-q", "--verify", "HEAD")
+	if _, err := runAs(dir, committer(dir), "pull", "-q", "--no-rebase", "--no-edit", "--allow-unrelated-histories", Remote, branch); err != nil {
+		return false, err
+	}
+	after, _ := run(dir, "rev-parse", "-q", "--verify", "HEAD")
+	return before != after, nil
+}
+
+// Push pushes to the Remote, reporting whether there is one
+func Push(dir string) (bool, error) {
+	branch, ok, err := remoteBranch(dir)
+	if err != nil || !ok {
+		return false, err
+	}
+	_, err = run(dir, "push", "-q", "-u", Remote, branch)
+	return err == nil, err
+}
+
+// Sync commits dir's changes with message, merges in the Remote's, and
+// pushes the result
+func Sync(dir string, message string) (Result, error) {
+	var result Result
+	var err error
+	if result.Committed, err = Commit(dir, message); err != nil {
+		return result, err
+	}
+	if result.Pulled, err = Pull(dir); err != nil {
+		return result, err
+	}
+	result.Pushed, err = Push(dir)
+	return result, err
+}
+
+// addLines adds the lines of content path doesn't have yet to its end,
+// creating it if need be
+func addLines(path string, content string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	have := map[string]bool{}
+	for line := range strings.Lines(string(data)) {
+		have[strings.TrimSpace(line)] = true
+	}
+	var missing strings.Builder
+	for line := range strings.Lines(content) {
+		if !have[strings.TrimSpace(line)] {
+			missing.WriteString(line)
+		}
+	}
+	if missing.Len() == 0 {
+		return nil
+	}
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	if err := os.WriteFile(path, append(data, missing.String()...), 0600); err != nil {
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	return nil
+}
+
+// remoteBranch returns the branch checked out in dir, and false when there's
+// no Remote to sync it with
+func remoteBranch(dir string) (string, bool, error) {
+	if _, err := run(dir, "remote", "get-url", Remote); err != nil {
+		return "", false, nil
+	}
+	branch, err := run(dir, "symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return "", false, errors.New("cannot sync a detached HEAD, check out a branch first")
+	}
+	return branch, true, nil
+}
+
+// committer returns the environment naming the machine as who commits when
+// git has no user.email set, as committing needs someone to commit as
+func committer(dir string) []string {
+	if email, _ := run(dir, "config", "user.email"); email != "" {
+		return nil
+	}
+	hostname, _ := os.Hostname()
+	return []string{
+		"GIT_AUTHOR_NAME=harsh", "GIT_AUTHOR_EMAIL=harsh@" + hostname,
+		"GIT_COMMITTER_NAME=harsh", "GIT_COMMITTER_EMAIL=harsh@" + hostname,
+	}
+}
+
+// run runs git in dir, returning its trimmed output
+func run(dir string, args ...string) (string, error) {
+	return runAs(dir, nil, args...)
+}
+
+// runAs runs git in dir with env added to the environment
+func runAs(dir string, env []string, args ...string) (string, error) {
+	command := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	if env != nil {
+		command.Env = append(os.Environ(), env...)
+	}
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	out, err := command.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", errors.New("cannot sync: git isn't installed")
+	}
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %s (%w)", args[0], strings.TrimSpace(stderr.String()), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
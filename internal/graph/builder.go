@@ -3,51 +3,93 @@ package graph
 import (
 	"math"
 	"strings"
-	"time"
 
 	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/clock"
 	"github.com/wakatara/harsh/internal/storage"
 )
 
-// BuildGraph creates a consistency graph for a single habit
-func BuildGraph(habit *storage.Habit, entries *storage.Entries, countBack int, ask bool) string {
+// GraphSymbols are the glyphs graphs draw each kind of day with
+type GraphSymbols struct {
+	Done      string // done
+	Fallback  string // done the habit's easier fallback way
+	Satisfied string // not done, but the habit's target was met around it
+	Short     string // done, but under the habit's amount goal
+	Skip      string // skipped
+	Skipified string // not done, but covered by a nearby skip
+	Broken    string // not done
+	Warning   string // unlogged, and the habit is about to break
+	Missing   string // unlogged since the habit started
+	Blank     string // before the habit started
+}
+
+// Blocks are the default line-drawing graph symbols
+var Blocks = GraphSymbols{Done: "━", Fallback: "╍", Satisfied: "─", Short: "┄", Skip: "•", Skipified: "·", Broken: " ", Warning: "!", Missing: "◌", Blank: " "}
+
+// Letters are graph symbols a screen reader or braille display can read
+// out, with a letter for every answer
+var Letters = GraphSymbols{Done: "Y", Fallback: "O", Satisfied: "y", Short: "u", Skip: "S", Skipified: "s", Broken: "N", Warning: "!", Missing: "·", Blank: " "}
+
+// Symbols are the glyphs graphs are drawn with
+var Symbols = Blocks
+
+// ProRateFirstWindow judges days in a habit's first interval against a target
+// pro-rated to the days since it started, so a 3/7 habit started on a Friday
+// can be met by its first Sunday. Off by default since it changes past scores.
+var ProRateFirstWindow = false
+
+// PositiveSkips counts skipped days, and days a nearby skip covers, as done
+// for streaks, stats, and graphs rather than leaving them out. Off by default,
+// when skips are neutral.
+var PositiveSkips = false
+
+// BreakingSkips has skipped days end streaks, and cover no nearby days, while
+// still being left out of rates. Off by default, when skips are neutral.
+var BreakingSkips = false
+
+// priority lists the symbols from most to least telling, so a compressed
+// cell shows the most telling of the days it covers
+func (s GraphSymbols) priority() string {
+	return s.Done + s.Fallback + s.Satisfied + s.Skip + s.Skipified + s.Warning + s.Short + s.Missing + s.Broken + s.Blank
+}
+
+// BuildGraph creates a consistency graph for a single habit ending today
+func BuildGraph(habit *storage.Habit, entries storage.History, countBack int, ask bool) string {
+	return BuildGraphUntil(habit, entries, clock.Today(), countBack, ask)
+}
+
+// BuildNotedGraph creates a consistency graph for a single habit ending
+// today, with the days that have notes marked
+func BuildNotedGraph(habit *storage.Habit, entries storage.History, notes storage.Notes, countBack int, ask bool) string {
+	return BuildNotedGraphUntil(habit, entries, notes, clock.Today(), countBack, ask)
+}
+
+// BuildNotedGraphUntil creates a consistency graph for a single habit ending
+// on date to, with the days that have notes marked
+func BuildNotedGraphUntil(habit *storage.Habit, entries storage.History, notes storage.Notes, to civil.Date, countBack int, ask bool) string {
+	return MarkNotes(BuildGraphUntil(habit, entries, to, countBack, ask), habit, notes, to)
+}
+
+// BuildGraphUntil creates a consistency graph for a single habit ending on date to
+func BuildGraphUntil(habit *storage.Habit, entries storage.History, to civil.Date, countBack int, ask bool) string {
 	graphLen := countBack
 	if ask {
 		graphLen = max(1, graphLen-12)
 	}
+	if habit.GraphDays > graphLen+1 {
+		// Slow habits can look further back, squeezed into the usual width
+		return compressGraph(BuildGraphUntil(habit, entries, to, habit.GraphDays-1, false), graphLen+1)
+	}
 	var graphDay string
 	var consistency strings.Builder
 
-	to := civil.DateOf(time.Now())
 	from := to.AddDays(-graphLen)
 	consistency.Grow(graphLen)
 
 	for d := from; !d.After(to); d = d.AddDays(1) {
-		if outcome, ok := (*entries)[storage.DailyHabit{Day: d, Habit: habit.Name}]; ok {
-			switch {
-			case outcome.Result == "y":
-				graphDay = "━"
-			case outcome.Result == "s":
-				graphDay = "•"
-			// look at cases of "n" being entered but
-			// within bounds of the habit every x days
-			case Satisfied(d, habit, *entries):
-				graphDay = "─"
-			case Skipified(d, habit, *entries):
-				graphDay = "·"
-			case outcome.Result == "n":
-				graphDay = " "
-			}
-		} else {
-			if Warning(d, habit, *entries) && (to.DaysSince(d) < 14) {
-				// warning: sigils max out at 2 weeks (~90 day habit in formula)
-				graphDay = "!"
-			} else if d.After(habit.FirstRecord) {
-				// For people who miss days but then put in later ones
-				graphDay = "◌"
-			} else {
-				graphDay = " "
-			}
+		// Entries with an unknown result repeat the day before
+		if state := DayState(d, to, habit, entries); state != "" {
+			graphDay = Symbols.Symbol(state)
 		}
 		consistency.WriteString(graphDay)
 	}
@@ -55,12 +97,121 @@ func BuildGraph(habit *storage.Habit, entries *storage.Entries, countBack int, a
 	return consistency.String()
 }
 
+// The kinds of day a graph draws, as named in JSON output
+const (
+	StateDone      = "done"
+	StateFallback  = "fallback"
+	StateSatisfied = "satisfied"
+	StateShort     = "short"
+	StateSkipped   = "skipped"
+	StateSkipified = "skipified"
+	StateBroken    = "broken"
+	StateWarning   = "warning"
+	StateMissing   = "missing"
+	StateBlank     = "blank"
+)
+
+// DayState returns what kind of day d is for habit in a graph ending on date
+// to, or "" for an entry with a result harsh doesn't know
+func DayState(d civil.Date, to civil.Date, habit *storage.Habit, entries storage.History) string {
+	outcome, ok := entries.Outcome(storage.DailyHabit{Day: d, Habit: habit.Name})
+	switch {
+	case !ok && Warning(d, habit, entries) && to.DaysSince(d) < 14:
+		// warning: sigils max out at 2 weeks (~90 day habit in formula)
+		return StateWarning
+	case !ok && d.After(habit.FirstRecord) && !habit.At(d).ExpectedOn(d):
+		// A day off for a habit kept on certain weekdays carries its line on
+		return StateSatisfied
+	case !ok && d.After(habit.FirstRecord):
+		// For people who miss days but then put in later ones
+		return StateMissing
+	case !ok:
+		return StateBlank
+	case habit.MetGoal(outcome) && storage.IsFallback(outcome.Comment):
+		return StateFallback
+	case habit.MetGoal(outcome):
+		return StateDone
+	case outcome.Result == "s" && PositiveSkips:
+		return StateSatisfied
+	case outcome.Result == "s":
+		return StateSkipped
+	// look at cases of "n" being entered but
+	// within bounds of the habit every x days
+	case Satisfied(d, habit, entries):
+		return StateSatisfied
+	case Skipified(d, habit, entries) && PositiveSkips:
+		return StateSatisfied
+	case Skipified(d, habit, entries):
+		return StateSkipified
+	case outcome.Result == "y":
+		// Logged, but under the habit's amount goal
+		return StateShort
+	case outcome.Result == "n":
+		return StateBroken
+	}
+	return ""
+}
+
+// Symbol returns the glyph drawn for a kind of day
+func (s GraphSymbols) Symbol(state string) string {
+	switch state {
+	case StateDone:
+		return s.Done
+	case StateFallback:
+		return s.Fallback
+	case StateSatisfied:
+		return s.Satisfied
+	case StateShort:
+		return s.Short
+	case StateSkipped:
+		return s.Skip
+	case StateSkipified:
+		return s.Skipified
+	case StateBroken:
+		return s.Broken
+	case StateWarning:
+		return s.Warning
+	case StateMissing:
+		return s.Missing
+	}
+	return s.Blank
+}
+
+// compressGraph squeezes a graph into width cells, each covering an even
+// share of its days
+func compressGraph(consistency string, width int) string {
+	days := []rune(consistency)
+	priority := Symbols.priority()
+	var compressed strings.Builder
+	for cell := 0; cell < width; cell++ {
+		start, end := cell*len(days)/width, (cell+1)*len(days)/width
+		best := []rune(Symbols.Blank)[0]
+		for _, day := range days[start:end] {
+			if strings.IndexRune(priority, day) < strings.IndexRune(priority, best) {
+				best = day
+			}
+		}
+		compressed.WriteRune(best)
+	}
+	return compressed.String()
+}
+
 // Satisfied checks if a habit target is satisfied within its interval window
-func Satisfied(d civil.Date, habit *storage.Habit, entries storage.Entries) bool {
+func Satisfied(d civil.Date, habit *storage.Habit, entries storage.History) bool {
+	// Judged by the frequency the habit had on d
+	habit = habit.At(d)
+	if !habit.ExpectedOn(d) {
+		// Nothing's expected on a habit's days off
+		return true
+	}
 	if habit.Target <= 1 && habit.Interval == 1 {
 		return false
 	}
 
+	if ProRateFirstWindow && proRatedSatisfied(d, habit, entries) {
+		return true
+	}
+
 	// For date d, check all possible interval-length windows that include d
 	// Key insight: Allow future data only if there's supporting data at or before d in the same window
 	
@@ -70,8 +221,18 @@ func Satisfied(d civil.Date, habit *storage.Habit, entries storage.Entries) bool
 	}
 	
 	latestStart := d
+
+	// Only days the goal was met on count, so every window is scanned from
+	// the habit's outcomes over all of them
+	var met []storage.DatedOutcome
+	for _, v := range entries.Outcomes(habit.Name, earliestStart, latestStart.AddDays(habit.Interval-1)) {
+		if habit.MetGoal(v.Outcome) {
+			met = append(met, v)
+		}
+	}
 	
 	// Try all possible window start positions that would include date d
+	first := 0
 	for winStart := earliestStart; !winStart.After(latestStart); winStart = winStart.AddDays(1) {
 		winEnd := winStart.AddDays(habit.Interval - 1)
 		
@@ -79,24 +240,35 @@ func Satisfied(d civil.Date, habit *storage.Habit, entries storage.Entries) bool
 		if winEnd.Before(d) || winStart.After(d) {
 			continue
 		}
+		for first < len(met) && met[first].Day.Before(winStart) {
+			first++
+		}
 
 		// Count successes in the entire window (including potential future data)
 		countTotal := 0
 		countUpToD := 0
+		var lastCounted civil.Date
 		
 		// Early termination: stop counting once we exceed target
-		for dt := winStart; !dt.After(winEnd) && countTotal < habit.Target+1; dt = dt.AddDays(1) {
-			if v, ok := entries[storage.DailyHabit{Day: dt, Habit: habit.Name}]; ok && v.Result == "y" {
-				countTotal++
-				if !dt.After(d) {
-					countUpToD++
-				}
-				// Early exit optimization for Target=1 special case
-				if habit.Target == 1 && countTotal == 2 {
-					// But only if we have supporting data up to d
-					if countUpToD > 0 {
-						return true
-					}
+		for _, v := range met[first:] {
+			if v.Day.After(winEnd) || countTotal >= habit.Target+1 {
+				break
+			}
+			// Completions without enough rest since the last one don't count
+			if countTotal > 0 && v.Day.DaysSince(lastCounted) <= habit.MinGap {
+				continue
+			}
+			lastCounted = v.Day
+			completions := habit.Completions(v.Outcome)
+			countTotal += completions
+			if !v.Day.After(d) {
+				countUpToD += completions
+			}
+			// Early exit optimization for Target=1 special case
+			if habit.Target == 1 && countTotal >= 2 {
+				// But only if we have supporting data up to d
+				if countUpToD > 0 {
+					return true
 				}
 			}
 		}
@@ -114,27 +286,67 @@ func Satisfied(d civil.Date, habit *storage.Habit, entries storage.Entries) bool
 	return false
 }
 
-// Skipified checks if a habit has been skipped within its grace period
-func Skipified(d civil.Date, habit *storage.Habit, entries storage.Entries) bool {
-	if habit.Target <= 1 && habit.Interval == 1 {
+// proRatedSatisfied checks if the completions since the habit's first record
+// meet its target scaled down to the days elapsed, while d is still in the
+// habit's first interval
+func proRatedSatisfied(d civil.Date, habit *storage.Habit, entries storage.History) bool {
+	days := d.DaysSince(habit.FirstRecord) + 1
+	if days < 1 || days >= habit.Interval {
 		return false
 	}
+	target := int(math.Ceil(float64(habit.Target) * float64(days) / float64(habit.Interval)))
 
-	from := d
-	to := d.AddDays(-int(math.Ceil(float64(habit.Interval) / float64(habit.Target))))
-	for dt := from; !dt.Before(to); dt = dt.AddDays(-1) {
-		if v, ok := entries[storage.DailyHabit{Day: dt, Habit: habit.Name}]; ok {
-			if v.Result == "s" {
-				return true
+	count := 0
+	var lastCounted civil.Date
+	for _, v := range entries.Outcomes(habit.Name, habit.FirstRecord, d) {
+		if habit.MetGoal(v.Outcome) {
+			if count > 0 && v.Day.DaysSince(lastCounted) <= habit.MinGap {
+				continue
 			}
+			lastCounted = v.Day
+			count += habit.Completions(v.Outcome)
+		}
+	}
+	return count > 0 && count >= target
+}
+
+// TooClose checks if a completion on d would come without the rest days
+// the habit's minGap asks for since or before another completion
+func TooClose(d civil.Date, habit *storage.Habit, entries storage.History) bool {
+	for _, v := range entries.Outcomes(habit.Name, d.AddDays(-habit.MinGap), d.AddDays(habit.MinGap)) {
+		if v.Day != d && habit.MetGoal(v.Outcome) {
+			return true
+		}
+	}
+	return false
+}
+
+// Skipified checks if a habit has been skipped within its grace period.
+// With BreakingSkips a skip covers no other day.
+func Skipified(d civil.Date, habit *storage.Habit, entries storage.History) bool {
+	if BreakingSkips {
+		return false
+	}
+	// Judged by the frequency the habit had on d
+	habit = habit.At(d)
+	if habit.Target <= 1 && habit.Interval == 1 {
+		return false
+	}
+
+	from := d.AddDays(-int(math.Ceil(float64(habit.Interval) / float64(habit.Target))))
+	for _, v := range entries.Outcomes(habit.Name, from, d) {
+		if v.Result == "s" {
+			return true
 		}
 	}
 	return false
 }
 
 // Warning checks if a habit should show a warning indicator
-func Warning(d civil.Date, habit *storage.Habit, entries storage.Entries) bool {
-	if habit.Target < 1 {
+func Warning(d civil.Date, habit *storage.Habit, entries storage.History) bool {
+	// Judged by the frequency the habit had on d
+	habit = habit.At(d)
+	if habit.Target < 1 || !habit.ExpectedOn(d) {
 		return false
 	}
 
@@ -142,22 +354,79 @@ func Warning(d civil.Date, habit *storage.Habit, entries storage.Entries) bool {
 	to := d
 	from := d.AddDays(-int(habit.Interval) + warningDays)
 	noFirstRecord := civil.Date{Year: 0, Month: 0, Day: 0}
-	for dt := from; !dt.After(to); dt = dt.AddDays(1) {
-		if v, ok := entries[storage.DailyHabit{Day: dt, Habit: habit.Name}]; ok {
-			switch v.Result {
-			case "y":
-				return false
-			case "s":
-				return false
-			}
-		}
-		// Edge case for 0 day onboard and later completes null entry habits
-		if habit.FirstRecord == noFirstRecord {
-			return false
-		}
-		if dt.Before(habit.FirstRecord) {
+	// Edge case for 0 day onboard and later completes null entry habits
+	if !from.After(to) && (habit.FirstRecord == noFirstRecord || from.Before(habit.FirstRecord)) {
+		return false
+	}
+	for _, v := range entries.Outcomes(habit.Name, from, to) {
+		// A day short of the habit's amount goal doesn't put off its warning,
+		// nor does a skip that breaks streaks
+		if habit.MetGoal(v.Outcome) || (v.Result == "s" && !BreakingSkips) {
 			return false
 		}
 	}
 	return true
 }
+
+// AmountBars are the glyphs drawn for days with amounts, smallest first
+var AmountBars = []string{"▁", "▃", "▅", "▇"}
+
+// AmountOverlay redraws the days of a graph ending on date to that have amounts
+// as bars scaled to the largest amount in the graph, turning quantified habits
+// into small bar charts
+func AmountOverlay(consistency string, habit *storage.Habit, entries storage.History, to civil.Date) string {
+	days := []rune(consistency)
+	from := to.AddDays(-len(days) + 1)
+
+	largest := 0.0
+	for d := from; !d.After(to); d = d.AddDays(1) {
+		if outcome, ok := entries.Outcome(storage.DailyHabit{Day: d, Habit: habit.Name}); ok {
+			largest = math.Max(largest, outcome.Amount)
+		}
+	}
+	if largest <= 0 {
+		return consistency
+	}
+
+	var overlay strings.Builder
+	for i, day := range days {
+		outcome, ok := entries.Outcome(storage.DailyHabit{Day: from.AddDays(i), Habit: habit.Name})
+		if !ok || outcome.Amount <= 0 {
+			overlay.WriteRune(day)
+			continue
+		}
+		level := int(math.Ceil(outcome.Amount/largest*float64(len(AmountBars)))) - 1
+		overlay.WriteString(AmountBars[max(0, min(level, len(AmountBars)-1))])
+	}
+	return overlay.String()
+}
+
+// NoteMarker is drawn on days with notes that have nothing else to show, so
+// noted days line up as a dotted column through the graphs
+const NoteMarker = "┆"
+
+// MarkNotes marks the days with notes in habit's graph ending on date to.
+// Only graphs drawn in blocks, a day to a cell, are marked: among letters
+// the marker would read as an answer, and compressed cells hold several days.
+func MarkNotes(consistency string, habit *storage.Habit, notes storage.Notes, to civil.Date) string {
+	if len(notes) == 0 || Symbols != Blocks || habit.GraphDays > len([]rune(consistency)) {
+		return consistency
+	}
+	return NoteOverlay(consistency, notes, to)
+}
+
+// NoteOverlay marks the days of a graph ending on date to that have notes,
+// leaving days with outcomes or warnings as they are
+func NoteOverlay(consistency string, notes storage.Notes, to civil.Date) string {
+	days := []rune(consistency)
+	from := to.AddDays(-len(days) + 1)
+	var overlay strings.Builder
+	for i, day := range days {
+		if _, noted := notes[from.AddDays(i)]; noted && (day == ' ' || day == '◌') {
+			overlay.WriteString(NoteMarker)
+			continue
+		}
+		overlay.WriteRune(day)
+	}
+	return overlay.String()
+}
@@ -1,9 +1,13 @@
 package graph
 
 import (
+	"fmt"
 	"runtime"
+	"runtime/debug"
 	"sync"
 
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/clock"
 	"github.com/wakatara/harsh/internal/storage"
 )
 
@@ -14,8 +18,13 @@ type HabitGraphResult struct {
 	Error     error
 }
 
-// BuildGraphsParallel builds graphs for multiple habits concurrently
-func BuildGraphsParallel(habits []*storage.Habit, entries *storage.Entries, countBack int, ask bool) map[string]string {
+// BuildGraphsParallel builds graphs ending today for multiple habits concurrently
+func BuildGraphsParallel(habits []*storage.Habit, entries storage.History, countBack int, ask bool) map[string]string {
+	return BuildGraphsParallelUntil(habits, entries, clock.Today(), countBack, ask)
+}
+
+// BuildGraphsParallelUntil builds graphs ending on date to for multiple habits concurrently
+func BuildGraphsParallelUntil(habits []*storage.Habit, entries storage.History, to civil.Date, countBack int, ask bool) map[string]string {
 	// Determine optimal number of workers
 	numWorkers := min(len(habits), runtime.NumCPU())
 
@@ -31,12 +40,7 @@ func BuildGraphsParallel(habits []*storage.Habit, entries *storage.Entries, coun
 		go func() {
 			defer wg.Done()
 			for habit := range habitChan {
-				graph := BuildGraph(habit, entries, countBack, ask)
-				resultChan <- HabitGraphResult{
-					HabitName: habit.Name,
-					Graph:     graph,
-					Error:     nil,
-				}
+				resultChan <- buildGraphResult(habit, entries, to, countBack, ask)
 			}
 		}()
 	}
@@ -57,11 +61,31 @@ func BuildGraphsParallel(habits []*storage.Habit, entries *storage.Entries, coun
 
 	// Collect results
 	results := make(map[string]string, len(habits))
+	var failed error
 	for result := range resultChan {
 		if result.Error == nil {
 			results[result.HabitName] = result.Graph
+		} else if failed == nil {
+			failed = result.Error
 		}
 	}
+	if failed != nil {
+		// Raised here rather than in a worker, where nothing can recover it
+		panic(failed)
+	}
 
 	return results
 }
+
+// buildGraphResult builds a habit's graph, turning a panic into the result's
+// Error along with the worker's stack
+func buildGraphResult(habit *storage.Habit, entries storage.History, to civil.Date, countBack int, ask bool) (result HabitGraphResult) {
+	result.HabitName = habit.Name
+	defer func() {
+		if r := recover(); r != nil {
+			result.Error = fmt.Errorf("building %s's graph: %v\n\n%s", habit.Name, r, debug.Stack())
+		}
+	}()
+	result.Graph = BuildGraphUntil(habit, entries, to, countBack, ask)
+	return result
+}
@@ -8,70 +8,213 @@ import (
 	"github.com/wakatara/harsh/internal/storage"
 )
 
+// Sparks are the glyphs used to draw percentages in sparklines, lowest first
+var Sparks = []string{" ", "▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+
+// SparkFor maps a 0-100 percentage onto its sparkline glyph
+func SparkFor(pct float64) string {
+	// divide score into score to map to sparks slice graphic for sparkline
+	if pct >= 100 {
+		return Sparks[len(Sparks)-1]
+	}
+	if pct <= 0 {
+		return Sparks[0]
+	}
+	i := int(math.Ceil(pct / float64(100/(len(Sparks)-1))))
+	return Sparks[min(i, len(Sparks)-1)]
+}
+
 // BuildSpark creates sparkline and calendar line for visualization
-func BuildSpark(from civil.Date, to civil.Date, habits []*storage.Habit, entries *storage.Entries) ([]string, []string) {
+func BuildSpark(from civil.Date, to civil.Date, habits []*storage.Habit, entries storage.History) ([]string, []string) {
 	sparkline := []string{}
 	calline := []string{}
-	sparks := []string{" ", "▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
-	i := 0
 	LetterDay := map[string]string{
 		"Sunday": " ", "Monday": "M", "Tuesday": " ", "Wednesday": "W",
 		"Thursday": " ", "Friday": "F", "Saturday": " ",
 	}
 
 	for d := from; !d.After(to); d = d.AddDays(1) {
-		dailyScore := Score(d, habits, entries)
-		// divide score into score to map to sparks slice graphic for sparkline
-		if dailyScore == 100 {
-			i = 8
-		} else {
-			i = int(math.Ceil(dailyScore / float64(100/(len(sparks)-1))))
-		}
 		t, _ := time.Parse(time.DateOnly, d.String())
 		w := t.Weekday().String()
 
 		calline = append(calline, LetterDay[w])
-		sparkline = append(sparkline, sparks[i])
+		dailyScore, ok := DayScore(d, habits, entries)
+		switch {
+		case ok:
+			sparkline = append(sparkline, SparkFor(dailyScore))
+		case Begun(d, habits):
+			sparkline = append(sparkline, EmptySpark())
+		default:
+			// Before tracking began there's no day to speak of
+			sparkline = append(sparkline, Sparks[0])
+		}
 	}
 
 	return sparkline, calline
 }
 
-// Score calculates the daily score for a given date
-func Score(d civil.Date, habits []*storage.Habit, entries *storage.Entries) float64 {
+// How days with nothing to score are scored, see EmptyDays
+const (
+	// EmptyDaysNA shows them as EmptyScore and leaves them out of averages
+	EmptyDaysNA = "na"
+	// EmptyDaysPerfect scores them 100%, as every habit due was dealt with
+	EmptyDaysPerfect = "100"
+	// EmptyDaysSkip leaves them out of averages and blank in score history
+	EmptyDaysSkip = "skip"
+)
+
+// EmptyDays is how days with nothing to score are scored: days when every
+// habit due was skipped, or only tracking habits were
+var EmptyDays = EmptyDaysNA
+
+// EmptyScore stands in for the score of a day with nothing to score
+const EmptyScore = "–"
+
+// Score calculates the daily score for a given date, for callers that need a
+// number every day. As it always has, a day every habit due was skipped
+// scores 100 and one with no habit due 0, unless EmptyDays is
+// EmptyDaysPerfect, which makes both 100. See DayScore to tell them apart.
+func Score(d civil.Date, habits []*storage.Habit, entries storage.History) float64 {
+	score, ok := DayScore(d, habits, entries)
+	switch {
+	case ok:
+		return score
+	case EmptyDays == EmptyDaysPerfect || anyDue(d, habits):
+		return 100
+	}
+	return 0
+}
+
+// anyDue reports whether any scoreable habit was due on d
+func anyDue(d civil.Date, habits []*storage.Habit) bool {
+	for _, habit := range habits {
+		habit := habit.At(d)
+		if habit.Target > 0 && !d.Before(habit.FirstRecord) && habit.ExpectedOn(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// DayScore returns the daily score for a given date and whether there was
+// anything to score, ie. a scoreable habit that wasn't skipped
+func DayScore(d civil.Date, habits []*storage.Habit, entries storage.History) (float64, bool) {
 	scored := 0.0
 	skipped := 0.0
 	scorableHabits := 0.0
 
 	for _, habit := range habits {
-		if habit.Target > 0 && !d.Before(habit.FirstRecord) {
+		// Scored by the frequency the habit had on d
+		habit := habit.At(d)
+		if habit.Target > 0 && !d.Before(habit.FirstRecord) && habit.ExpectedOn(d) {
 			scorableHabits++
-			if outcome, ok := (*entries)[storage.DailyHabit{Day: d, Habit: habit.Name}]; ok {
+			if outcome, ok := entries.Outcome(storage.DailyHabit{Day: d, Habit: habit.Name}); ok {
 				switch {
-				case outcome.Result == "y":
+				case habit.MetGoal(outcome):
 					scored++
 				case outcome.Result == "s":
 					skipped++
 				// look at cases of n being entered but
 				// within bounds of the habit every x days
-				case Satisfied(d, habit, *entries):
+				case Satisfied(d, habit, entries):
 					scored++
-				case Skipified(d, habit, *entries):
+				case Skipified(d, habit, entries):
 					skipped++
 				}
 			}
 		}
 	}
 
-	var score float64
-	// Edge case on if there is nothing to score and the scorable vs skipped issue
-	if scorableHabits == 0 {
-		score = 0.0
-	} else {
-		score = 100.0 // deal with scorable habits - skipped == 0 causing divide by zero issue
+	if scorableHabits-skipped <= 0 {
+		return 0, false
+	}
+	return (scored / (scorableHabits - skipped)) * 100, true
+}
+
+// Begun reports whether any habit had been logged by d
+func Begun(d civil.Date, habits []*storage.Habit) bool {
+	for _, habit := range habits {
+		if !habit.FirstRecord.IsZero() && !d.Before(habit.FirstRecord) {
+			return true
+		}
+	}
+	return false
+}
+
+// EmptySpark returns the sparkline glyph of a day with nothing to score
+func EmptySpark() string {
+	switch EmptyDays {
+	case EmptyDaysPerfect:
+		return SparkFor(100)
+	case EmptyDaysSkip:
+		return Sparks[0]
+	}
+	return EmptyScore
+}
+
+// AverageScores returns the mean daily score over each window of days ending
+// on date to, in the order given. Every day's score is calculated once and
+// shared between windows. Days before any scoreable habit was first recorded
+// don't count towards an average, nor do days with nothing to score unless
+// EmptyDays scores them 100%.
+func AverageScores(to civil.Date, windows []int, habits []*storage.Habit, entries storage.History) []float64 {
+	return CachedAverageScores(to, windows, habits, entries, nil)
+}
+
+// CachedAverageScores is AverageScores taking days' scores from cache when
+// they're there, and adding the ones it works out. A nil cache scores every
+// day.
+func CachedAverageScores(to civil.Date, windows []int, habits []*storage.Habit, entries storage.History, cache map[civil.Date]float64) []float64 {
+	longest := 0
+	for _, days := range windows {
+		longest = max(longest, days)
+	}
+
+	noFirstRecord := civil.Date{Year: 0, Month: 0, Day: 0}
+	scores := make([]float64, 0, longest)
+	for i := 0; i < longest; i++ {
+		d := to.AddDays(-i)
+		scorable := false
+		for _, habit := range habits {
+			if habit.Target > 0 && habit.FirstRecord != noFirstRecord && !d.Before(habit.FirstRecord) {
+				scorable = true
+				break
+			}
+		}
+		if !scorable {
+			break
+		}
+		if score, ok := cache[d]; ok {
+			scores = append(scores, score)
+			continue
+		}
+		// NaN marks days with nothing to score, left out below
+		score, ok := DayScore(d, habits, entries)
+		switch {
+		case ok:
+		case EmptyDays == EmptyDaysPerfect:
+			score = 100
+		default:
+			score = math.NaN()
+		}
+		if cache != nil {
+			cache[d] = score
+		}
+		scores = append(scores, score)
 	}
-	if scorableHabits-skipped != 0 {
-		score = (scored / (scorableHabits - skipped)) * 100
+
+	averages := make([]float64, len(windows))
+	for i, days := range windows {
+		total, counted := 0.0, 0
+		for _, score := range scores[:min(days, len(scores))] {
+			if !math.IsNaN(score) {
+				total += score
+				counted++
+			}
+		}
+		if counted > 0 {
+			averages[i] = total / float64(counted)
+		}
 	}
-	return score
+	return averages
 }
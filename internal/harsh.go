@@ -1,10 +1,11 @@
 package internal
 
 import (
+	"fmt"
 	"os"
-	"time"
+	"strings"
 
-	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/clock"
 	"github.com/wakatara/harsh/internal/storage"
 	"golang.org/x/term"
 )
@@ -12,37 +13,101 @@ import (
 // Harsh is the main application struct containing all habit data and configuration
 type Harsh struct {
 	Repository         storage.Repository
+	Settings           *storage.Settings
 	Habits             []*storage.Habit
 	MaxHabitNameLength int
 	CountBack          int
 	Log               *storage.Log
+	// FrequencyChanges are habits' frequencies not yet recorded, applied
+	// but left for storage.VersionedRepository, see storage.TrackFrequencies
+	FrequencyChanges []storage.FrequencyChange
 }
 
 // NewHarsh creates a new Harsh instance with loaded configuration and data
 func NewHarsh() *Harsh {
-	repository := storage.NewFileRepository()
-	habits, maxHabitNameLength, _ := repository.LoadHabits()
-	log, _ := repository.LoadEntries()
+	return NewHarshWithRepository(storage.NewFileRepository())
+}
+
+// NewHarshWithRepository creates a new Harsh instance loading its data from
+// repository, exiting with an explanation when it can't be
+func NewHarshWithRepository(repository storage.Repository) *Harsh {
+	h, err := LoadHarsh(repository)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return h
+}
+
+// LoadHarsh creates a new Harsh instance loading its data from repository,
+// returning why when its habits or log can't be loaded
+func LoadHarsh(repository storage.Repository) (*Harsh, error) {
+	return LoadHarshWithSettings(repository, LoadSettings(repository.GetConfigDir()))
+}
+
+// LoadSettings reads the settings in configDir, warning about a config file
+// with problems and using defaults for what couldn't be read
+func LoadSettings(configDir string) *storage.Settings {
+	settings, err := storage.LoadSettings(configDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v (using defaults)\n", err)
+	}
+	return settings
+}
+
+// LoadHarshWithSettings is LoadHarsh with settings already read
+func LoadHarshWithSettings(repository storage.Repository, settings *storage.Settings) (*Harsh, error) {
+	habits, maxHabitNameLength, err := repository.LoadHabits()
+	if err != nil {
+		return nil, err
+	}
+	log, err := repository.LoadEntries()
+	if err != nil {
+		return nil, err
+	}
+	log.IndexHabits()
 
-	now := civil.DateOf(time.Now())
+	now := clock.Today()
 	to := now
 	from := to.AddDays(-365 * 5)
-	log.Entries.FirstRecords(from, to, habits)
+	log.FirstRecords(from, to, habits)
+	var frequencyChanges []storage.FrequencyChange
+	if configDir := repository.GetConfigDir(); configDir != "" {
+		var err error
+		if frequencyChanges, err = storage.TrackFrequencies(configDir, habits, now); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
 
-	width, _, err := term.GetSize(int(os.Stdout.Fd()))
-	if err != nil {
-		// Default width for testing or when terminal size cannot be determined
-		width = 120
+	// Cap the name column so long names don't push graphs off screen
+	if nameWidth := settings.Int("name_width"); nameWidth > 0 {
+		maxHabitNameLength = min(maxHabitNameLength, nameWidth+storage.HabitNamePadding)
+	}
+
+	countBack := max(1, min(TerminalWidth()-maxHabitNameLength-2, 100))
+	if settings.Int("countback") > 0 {
+		countBack = settings.Int("countback")
 	}
-	countBack := max(1, min(width-maxHabitNameLength-2, 100))
 
 	return &Harsh{
 		Repository:         repository,
+		Settings:           settings,
 		Habits:             habits,
 		MaxHabitNameLength: maxHabitNameLength,
 		CountBack:          countBack,
 		Log:            log,
+		FrequencyChanges:   frequencyChanges,
+	}, nil
+}
+
+// TerminalWidth returns the width of the terminal stdout is attached to
+func TerminalWidth() int {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		// Default width for testing or when terminal size cannot be determined
+		width = 120
 	}
+	return width
 }
 
 // GetRepository returns the repository instance
@@ -50,6 +115,11 @@ func (h *Harsh) GetRepository() storage.Repository {
 	return h.Repository
 }
 
+// GetSettings returns the settings from the config file
+func (h *Harsh) GetSettings() *storage.Settings {
+	return h.Settings
+}
+
 // GetHabits returns the habits slice
 func (h *Harsh) GetHabits() []*storage.Habit {
 	return h.Habits
@@ -69,3 +139,51 @@ func (h *Harsh) GetMaxHabitNameLength() int {
 func (h *Harsh) GetCountBack() int {
 	return h.CountBack
 }
+
+// KeepTagged narrows the habits to those tagged with any of tags, fitting
+// the name column to them, and reports whether any were
+func (h *Harsh) KeepTagged(tags []string) bool {
+	var kept []*storage.Habit
+	nameLength := 0
+	for _, habit := range h.Habits {
+		for _, tag := range tags {
+			if habit.HasTag(tag) {
+				kept = append(kept, habit)
+				nameLength = max(nameLength, len(habit.Name))
+				break
+			}
+		}
+	}
+	if len(kept) == 0 {
+		return false
+	}
+	h.Habits = kept
+	h.MaxHabitNameLength = min(h.MaxHabitNameLength, nameLength+storage.HabitNamePadding)
+	return true
+}
+
+// FindHabit returns the habit matching query, preferring an exact
+// (case-insensitive) name match and otherwise a unique name fragment
+func (h *Harsh) FindHabit(query string) (*storage.Habit, error) {
+	var matches []*storage.Habit
+	for _, habit := range h.Habits {
+		if strings.EqualFold(habit.Name, query) {
+			return habit, nil
+		}
+		if strings.Contains(strings.ToLower(habit.Name), strings.ToLower(query)) {
+			matches = append(matches, habit)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no habit matches %q", query)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, len(matches))
+		for i, habit := range matches {
+			names[i] = habit.Name
+		}
+		return nil, fmt.Errorf("%q matches several habits: %s", query, strings.Join(names, ", "))
+	}
+}
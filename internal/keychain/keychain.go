@@ -0,0 +1,296 @@
+// Package keychain keeps harsh's credentials in the operating system's
+// keychain rather than in plain text. It drives the keychain's own command
+// line tool: security on macOS and secret-tool (libsecret, as used by GNOME
+// Keyring and KWallet) on Linux and the BSDs. Shelling out keeps harsh free
+// of cgo and D-Bus bindings and uses whatever keychain the user unlocked.
+// Where there's no keychain to reach, credentials go in FallbackFile,
+// readable only by you.
+package keychain
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// service groups harsh's credentials in the keychain
+const service = "harsh"
+
+// ErrNotFound is returned when nothing is stored for an account
+var ErrNotFound = errors.New("not in the keychain")
+
+// ErrUnavailable is wrapped by errors for a keychain that isn't there: its
+// tool isn't installed, or harsh doesn't support the OS's keychain
+var ErrUnavailable = errors.New("no keychain")
+
+// Keychain is where Get and Set say a secret is kept when it's in the keychain
+const Keychain = "keychain"
+
+// FallbackFile holds credentials when there's no keychain, as account: secret
+// lines. It's kept out of the config directory so syncing and bundles never
+// pick it up.
+var FallbackFile = fallbackFile()
+
+// fallbackFile is $XDG_DATA_HOME/harsh/credentials, or its Windows equivalent
+func fallbackFile() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("LOCALAPPDATA"), "harsh", "credentials")
+	}
+	if data := os.Getenv("XDG_DATA_HOME"); data != "" {
+		return filepath.Join(data, "harsh", "credentials")
+	}
+	return filepath.Join(os.Getenv("HOME"), ".local", "share", "harsh", "credentials")
+}
+
+// Get returns the secret stored for account and where it's kept, Keychain
+// or FallbackFile
+func Get(account string) (string, string, error) {
+	secret, err := get(account)
+	if err == nil || !(errors.Is(err, ErrNotFound) || errors.Is(err, ErrUnavailable)) {
+		return secret, Keychain, err
+	}
+	secrets, fileErr := readFile()
+	if fileErr != nil {
+		return "", "", fileErr
+	}
+	if secret, ok := secrets[account]; ok {
+		return secret, FallbackFile, nil
+	}
+	if errors.Is(err, ErrUnavailable) {
+		return "", "", ErrNotFound
+	}
+	return "", "", err
+}
+
+// Set stores secret for account, replacing any stored before, and returns
+// where it's kept: the keychain, or FallbackFile when there's none
+func Set(account string, secret string) (string, error) {
+	if strings.ContainsAny(secret, "\r\n") {
+		return "", errors.New("secrets with line breaks can't be stored")
+	}
+	err := set(account, secret)
+	if errors.Is(err, ErrUnavailable) {
+		return FallbackFile, setFile(account, secret, true)
+	}
+	if err != nil {
+		return "", err
+	}
+	// The keychain's copy wins from now on, so an older one mustn't linger
+	if err := setFile(account, "", false); err != nil {
+		return Keychain, err
+	}
+	return Keychain, nil
+}
+
+// Remove deletes the secret stored for account from the keychain and
+// FallbackFile, returning ErrNotFound when neither had one
+func Remove(account string) error {
+	err := remove(account)
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrUnavailable) {
+		return err
+	}
+	secrets, fileErr := readFile()
+	if fileErr != nil {
+		return fileErr
+	}
+	if _, ok := secrets[account]; ok {
+		return setFile(account, "", false)
+	}
+	if errors.Is(err, ErrUnavailable) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// get returns the secret the keychain has for account
+func get(account string) (string, error) {
+	var out string
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		out, err = run("", "security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "windows":
+		return "", unsupported()
+	default:
+		out, err = run("", "secret-tool", "lookup", "service", service, "account", account)
+	}
+	if notFound(err) || (err == nil && out == "") {
+		return "", ErrNotFound
+	}
+	return out, err
+}
+
+// set stores secret for account in the keychain
+func set(account string, secret string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		// security only takes the secret as an argument when not prompting,
+		// so it's given the whole command on stdin to keep the secret out of
+		// the process list
+		command := strings.Join([]string{"add-generic-password", "-U", "-s", quote(service), "-a", quote(account), "-w", quote(secret)}, " ")
+		if _, err := run(command+"\n", "security", "-i"); err != nil {
+			return err
+		}
+		// Interactive mode exits cleanly whether or not the command worked
+		if stored, err := get(account); err != nil || stored != secret {
+			return fmt.Errorf("security didn't store the secret for %s", account)
+		}
+		return nil
+	case "windows":
+		return unsupported()
+	default:
+		_, err := run(secret, "secret-tool", "store", "--label", "harsh "+account, "service", service, "account", account)
+		return err
+	}
+}
+
+// remove deletes the secret the keychain has for account
+func remove(account string) error {
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		_, err = run("", "security", "delete-generic-password", "-s", service, "-a", account)
+	case "windows":
+		return unsupported()
+	default:
+		if _, err = get(account); err == nil {
+			_, err = run("", "secret-tool", "clear", "service", service, "account", account)
+		}
+	}
+	if notFound(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// run runs a keychain tool with stdin, returning its trimmed output
+func run(stdin string, name string, args ...string) (string, error) {
+	command := exec.Command(name, args...)
+	command.Stdin = strings.NewReader(stdin)
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	out, err := command.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", fmt.Errorf("cannot reach the keychain: %s isn't installed: %w", name, ErrUnavailable)
+	}
+	if err != nil {
+		return "", &toolError{name: name, stderr: strings.TrimSpace(stderr.String()), err: err}
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// toolError is a keychain tool failing, with what it said about it
+type toolError struct {
+	name   string
+	stderr string
+	err    error
+}
+
+func (e *toolError) Error() string {
+	return fmt.Sprintf("%s failed: %s (%v)", e.name, e.stderr, e.err)
+}
+
+func (e *toolError) Unwrap() error {
+	return e.err
+}
+
+// notFound reports whether err is the keychain tool saying there's no such
+// item: security exits with 44 (errSecItemNotFound), and secret-tool with 1
+// without saying anything. Other failures, a locked keychain say, are errors.
+func notFound(err error) bool {
+	var toolErr *toolError
+	var exitErr *exec.ExitError
+	if !errors.As(err, &toolErr) || !errors.As(err, &exitErr) {
+		return false
+	}
+	if toolErr.name == "security" {
+		return exitErr.ExitCode() == 44
+	}
+	return exitErr.ExitCode() == 1 && toolErr.stderr == ""
+}
+
+// quote quotes an argument for security's interactive mode
+func quote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+func unsupported() error {
+	return fmt.Errorf("keychain storage isn't supported on %s yet: %w", runtime.GOOS, ErrUnavailable)
+}
+
+// readFile returns the secrets in FallbackFile by account, none when it
+// doesn't exist
+func readFile() (map[string]string, error) {
+	secrets := map[string]string{}
+	file, err := os.Open(FallbackFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read credentials: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if account, secret, ok := strings.Cut(scanner.Text(), ":"); ok {
+			secrets[strings.TrimSpace(account)] = strings.TrimSpace(secret)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read credentials: %w", err)
+	}
+	return secrets, nil
+}
+
+// setFile stores secret for account in FallbackFile, or removes account's
+// line when store is false. The file is replaced whole and only ever
+// readable by you, whatever it was before.
+func setFile(account string, secret string, store bool) error {
+	secrets, err := readFile()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[account]; !ok && !store {
+		return nil
+	}
+	if store {
+		secrets[account] = secret
+	} else {
+		delete(secrets, account)
+	}
+	accounts := make([]string, 0, len(secrets))
+	for a := range secrets {
+		accounts = append(accounts, a)
+	}
+	slices.Sort(accounts)
+	var b strings.Builder
+	for _, a := range accounts {
+		fmt.Fprintf(&b, "%s: %s\n", a, secrets[a])
+	}
+	if err := os.MkdirAll(filepath.Dir(FallbackFile), 0700); err != nil {
+		return fmt.Errorf("cannot write credentials: %w", err)
+	}
+	temp, err := os.CreateTemp(filepath.Dir(FallbackFile), ".credentials-*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot write credentials: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.WriteString(b.String()); err != nil {
+		temp.Close()
+		return fmt.Errorf("cannot write credentials: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("cannot write credentials: %w", err)
+	}
+	if err := os.Rename(temp.Name(), FallbackFile); err != nil {
+		return fmt.Errorf("cannot write credentials: %w", err)
+	}
+	return nil
+}
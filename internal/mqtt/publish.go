@@ -0,0 +1,293 @@
+// Package mqtt publishes harsh's daily state to an MQTT broker, for Home
+// Assistant dashboards and smart-home automations. It speaks just enough
+// MQTT 3.1.1 to publish retained messages at most once.
+package mqtt
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Broker is the MQTT server state is published to
+type Broker struct {
+	// Address is host:port, port 1883 when left out. ssl:// or tls:// in
+	// front connects over TLS, port 8883 when left out.
+	Address  string
+	User     string
+	Password string
+	ClientID string
+}
+
+// Message is a payload for a topic. Retained messages are handed to
+// subscribers as soon as they connect.
+type Message struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// HabitState is how a habit stands today
+type HabitState struct {
+	Name string `json:"name"`
+	// Status is "done", "missed", "skipped", or "todo"
+	Status string `json:"status"`
+}
+
+// State is the daily state harsh publishes after logging
+type State struct {
+	Date           string       `json:"date"`
+	Score          float64      `json:"score"`
+	YesterdayScore float64      `json:"yesterday_score"`
+	Todos          int          `json:"todos"`
+	Habits         []HabitState `json:"habits"`
+}
+
+// HabitStatus names a day's result for publishing, "todo" when unanswered
+func HabitStatus(result string) string {
+	switch result {
+	case "y":
+		return "done"
+	case "n":
+		return "missed"
+	case "s":
+		return "skipped"
+	default:
+		return "todo"
+	}
+}
+
+// Slug turns a habit name into a single topic level, eg. "Push ups" into
+// "push_ups"
+func Slug(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteRune('_')
+			underscore = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// Slugs slugs each of names, numbering any that would share a slug with an
+// earlier one, eg. "Push ups" and "Push-ups" as push_ups and push_ups_2.
+// Names without a letter or digit get an empty slug.
+func Slugs(names []string) []string {
+	slugs := make([]string, len(names))
+	taken := map[string]bool{}
+	for i, name := range names {
+		slug := Slug(name)
+		if slug == "" {
+			continue
+		}
+		unique := slug
+		for n := 2; taken[unique]; n++ {
+			unique = slug + "_" + strconv.Itoa(n)
+		}
+		taken[unique] = true
+		slugs[i] = unique
+	}
+	return slugs
+}
+
+// StateMessages lays out state under topic: the whole state as JSON on
+// topic/state, the score and todo count on topic/score and topic/todos, and
+// each habit's status on topic/habits/<slug>, see Slugs. All are retained.
+func StateMessages(topic string, state State) ([]Message, error) {
+	topic = strings.TrimRight(topic, "/")
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	messages := []Message{
+		{Topic: topic + "/state", Payload: data, Retain: true},
+		{Topic: topic + "/score", Payload: []byte(strconv.FormatFloat(state.Score, 'f', 1, 64)), Retain: true},
+		{Topic: topic + "/todos", Payload: []byte(strconv.Itoa(state.Todos)), Retain: true},
+	}
+	names := make([]string, len(state.Habits))
+	for i, habit := range state.Habits {
+		names[i] = habit.Name
+	}
+	for i, slug := range Slugs(names) {
+		if slug != "" {
+			messages = append(messages, Message{Topic: topic + "/habits/" + slug, Payload: []byte(state.Habits[i].Status), Retain: true})
+		}
+	}
+	return messages, nil
+}
+
+// MQTT control packet types
+const (
+	packetConnect    = 1 << 4
+	packetConnack    = 2 << 4
+	packetPublish    = 3 << 4
+	packetDisconnect = 14 << 4
+)
+
+// Publish connects to broker, publishes messages, and disconnects
+func Publish(broker Broker, messages []Message) error {
+	if broker.Address == "" {
+		return errors.New("no MQTT broker set, set mqtt_broker with harsh config set")
+	}
+	address, secure := broker.dialAddress()
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if secure {
+		host, _, _ := net.SplitHostPort(address)
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot reach MQTT broker %s: %w", address, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	return publish(conn, broker, messages)
+}
+
+// dialAddress is the host:port to connect to for broker, and whether it's
+// over TLS
+func (b Broker) dialAddress() (string, bool) {
+	address, secure := b.Address, false
+	for _, scheme := range []string{"ssl://", "tls://", "mqtts://"} {
+		if rest, ok := strings.CutPrefix(address, scheme); ok {
+			address, secure = rest, true
+		}
+	}
+	for _, scheme := range []string{"tcp://", "mqtt://"} {
+		address = strings.TrimPrefix(address, scheme)
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		port := "1883"
+		if secure {
+			port = "8883"
+		}
+		address = net.JoinHostPort(address, port)
+	}
+	return address, secure
+}
+
+// Plaintext reports whether publishing to the broker would send its
+// password unencrypted across the network, ie. it logs in without TLS to a
+// broker on another machine
+func (b Broker) Plaintext() bool {
+	address, secure := b.dialAddress()
+	if secure || b.Password == "" {
+		return false
+	}
+	host, _, _ := net.SplitHostPort(address)
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsLoopback()
+}
+
+// publish runs an MQTT session over an open connection
+func publish(conn io.ReadWriter, broker Broker, messages []Message) error {
+	w := bufio.NewWriter(conn)
+	w.Write(connectPacket(broker))
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("cannot connect to MQTT broker: %w", err)
+	}
+	connack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, connack); err != nil {
+		return fmt.Errorf("no answer from MQTT broker: %w", err)
+	}
+	if connack[0] != packetConnack || connack[1] != 2 {
+		return errors.New("MQTT broker answered with something other than CONNACK")
+	}
+	if code := connack[3]; code != 0 {
+		return fmt.Errorf("MQTT broker refused the connection: %s", connackReason(code))
+	}
+
+	for _, message := range messages {
+		header := byte(packetPublish)
+		if message.Retain {
+			header |= 1
+		}
+		w.Write(packet(header, encodeString(message.Topic), message.Payload))
+	}
+	w.Write([]byte{packetDisconnect, 0})
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("cannot publish to MQTT broker: %w", err)
+	}
+	return nil
+}
+
+// connectPacket asks for a clean session, with a login when broker has a user
+func connectPacket(broker Broker) []byte {
+	clientID := broker.ClientID
+	if clientID == "" {
+		clientID = "harsh"
+	}
+	var flags byte = 0b10 // clean session
+	payload := encodeString(clientID)
+	if broker.User != "" {
+		flags |= 0b1000_0000
+		payload = append(payload, encodeString(broker.User)...)
+		if broker.Password != "" {
+			flags |= 0b0100_0000
+			payload = append(payload, encodeString(broker.Password)...)
+		}
+	}
+	// Protocol name and level 4 (3.1.1), flags, and a 60 second keep alive
+	variable := append(encodeString("MQTT"), 4, flags, 0, 60)
+	return packet(packetConnect, variable, payload)
+}
+
+// packet frames a control packet with its remaining length
+func packet(header byte, variable []byte, payload []byte) []byte {
+	length := len(variable) + len(payload)
+	out := []byte{header}
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 128
+		}
+		out = append(out, b)
+		if length == 0 {
+			break
+		}
+	}
+	out = append(out, variable...)
+	return append(out, payload...)
+}
+
+// encodeString prefixes s with its length, as MQTT strings are sent
+func encodeString(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
+
+func connackReason(code byte) string {
+	switch code {
+	case 1:
+		return "unsupported protocol version"
+	case 2:
+		return "client ID rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad user name or password (check mqtt_user and HARSH_MQTT_PASSWORD)"
+	case 5:
+		return "not authorized"
+	default:
+		return "code " + strconv.Itoa(int(code))
+	}
+}
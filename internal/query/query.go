@@ -0,0 +1,371 @@
+// Package query implements the small filter language used by `harsh query`
+// to pick log entries, eg. `habit=Run and result=y and amount>5 since 2025-01-01`.
+//
+//	query      = [expr] { ("since" | "until") date }
+//	expr       = term { "or" term }
+//	term       = factor { "and" factor }
+//	factor     = "not" factor | "(" expr ")" | field op value
+//	field      = "habit" | "result" | "amount" | "comment" | "date"
+//	op         = "=" | "!=" | "<" | "<=" | ">" | ">=" | "~"
+//
+// Values containing spaces or operators are written in double quotes. Text
+// fields compare case-insensitively and "~" matches a substring.
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// Query is a parsed filter over log entries
+type Query struct {
+	expr node
+	// Since and Until bound the entries' dates, inclusively, when set
+	Since, Until civil.Date
+}
+
+// Parse parses a query string
+func Parse(input string) (*Query, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	q := &Query{}
+
+	if !p.done() && !p.atKeyword("since", "until") {
+		if q.expr, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	for !p.done() {
+		tok := p.next()
+		keyword := strings.ToLower(tok.text)
+		if tok.kind != tokenWord || (keyword != "since" && keyword != "until") {
+			return nil, fmt.Errorf("unexpected %q, expected and, or, since, or until", tok.text)
+		}
+		if p.done() {
+			return nil, fmt.Errorf("%s needs a date (YYYY-MM-DD)", keyword)
+		}
+		value := p.next()
+		d, err := civil.ParseDate(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("%s needs a date (YYYY-MM-DD), got %q", keyword, value.text)
+		}
+		if keyword == "since" {
+			q.Since = d
+		} else {
+			q.Until = d
+		}
+	}
+	return q, nil
+}
+
+// Match reports whether an entry satisfies the query
+func (q *Query) Match(key storage.DailyHabit, outcome storage.Outcome) bool {
+	noDate := civil.Date{}
+	if q.Since != noDate && key.Day.Before(q.Since) {
+		return false
+	}
+	if q.Until != noDate && key.Day.After(q.Until) {
+		return false
+	}
+	return q.expr == nil || q.expr.match(key, outcome)
+}
+
+// Filter returns the keys of the matching entries, ordered by date then habit
+func (q *Query) Filter(entries storage.Entries) []storage.DailyHabit {
+	var matches []storage.DailyHabit
+	for _, key := range entries.SortedKeys() {
+		if q.Match(key, entries[key]) {
+			matches = append(matches, key)
+		}
+	}
+	return matches
+}
+
+type node interface {
+	match(key storage.DailyHabit, outcome storage.Outcome) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) match(key storage.DailyHabit, outcome storage.Outcome) bool {
+	return n.left.match(key, outcome) && n.right.match(key, outcome)
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) match(key storage.DailyHabit, outcome storage.Outcome) bool {
+	return n.left.match(key, outcome) || n.right.match(key, outcome)
+}
+
+type notNode struct{ inner node }
+
+func (n notNode) match(key storage.DailyHabit, outcome storage.Outcome) bool {
+	return !n.inner.match(key, outcome)
+}
+
+// comparison compares one field of an entry against a value
+type comparison struct {
+	field  string
+	op     string
+	text   string
+	number float64
+	date   civil.Date
+}
+
+func (c comparison) match(key storage.DailyHabit, outcome storage.Outcome) bool {
+	switch c.field {
+	case "habit":
+		return compareText(key.Habit, c.op, c.text)
+	case "comment":
+		return compareText(outcome.Comment, c.op, c.text)
+	case "result":
+		return compareText(outcome.Result, c.op, c.text)
+	case "amount":
+		return compareOrdered(outcome.Amount, c.op, c.number)
+	case "date":
+		return compareOrdered(key.Day.DaysSince(c.date), c.op, 0)
+	}
+	return false
+}
+
+func compareText(value string, op string, want string) bool {
+	value, want = strings.ToLower(value), strings.ToLower(want)
+	switch op {
+	case "=":
+		return value == want
+	case "!=":
+		return value != want
+	case "~":
+		return strings.Contains(value, want)
+	}
+	return false
+}
+
+func compareOrdered[T int | float64](value T, op string, want T) bool {
+	switch op {
+	case "=":
+		return value == want
+	case "!=":
+		return value != want
+	case "<":
+		return value < want
+	case "<=":
+		return value <= want
+	case ">":
+		return value > want
+	case ">=":
+		return value >= want
+	}
+	return false
+}
+
+// fieldOps lists the operators each field supports
+var fieldOps = map[string][]string{
+	"habit":   {"=", "!=", "~"},
+	"comment": {"=", "!=", "~"},
+	"result":  {"=", "!="},
+	"amount":  {"=", "!=", "<", "<=", ">", ">="},
+	"date":    {"=", "!=", "<", "<=", ">", ">="},
+}
+
+func newComparison(field string, op string, value string) (comparison, error) {
+	field = strings.ToLower(field)
+	ops, ok := fieldOps[field]
+	if !ok {
+		return comparison{}, fmt.Errorf("unknown field %q, expected habit, result, amount, comment, or date", field)
+	}
+	supported := false
+	for _, fieldOp := range ops {
+		supported = supported || fieldOp == op
+	}
+	if !supported {
+		return comparison{}, fmt.Errorf("%s can't be compared with %s, use one of %s", field, op, strings.Join(ops, " "))
+	}
+
+	c := comparison{field: field, op: op, text: value}
+	switch field {
+	case "result":
+		if value != "y" && value != "n" && value != "s" {
+			return c, fmt.Errorf("result must be y, n, or s, got %q", value)
+		}
+	case "amount":
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return c, fmt.Errorf("amount must be a number, got %q", value)
+		}
+		c.number = number
+	case "date":
+		d, err := civil.ParseDate(value)
+		if err != nil {
+			return c, fmt.Errorf("date must be YYYY-MM-DD, got %q", value)
+		}
+		c.date = d
+	}
+	return c, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	p.pos++
+	return tok
+}
+
+// atKeyword reports whether the next token is one of the given keywords
+func (p *parser) atKeyword(keywords ...string) bool {
+	if p.done() || p.tokens[p.pos].kind != tokenWord {
+		return false
+	}
+	for _, keyword := range keywords {
+		if strings.EqualFold(p.tokens[p.pos].text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseExpr() (node, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.atKeyword("or") {
+		p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.atKeyword("and") {
+		p.next()
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseFactor() (node, error) {
+	if p.done() {
+		return nil, fmt.Errorf("query ended early, expected a comparison like habit=Run")
+	}
+	if p.atKeyword("not") {
+		p.next()
+		inner, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+
+	tok := p.next()
+	if tok.kind == tokenOpen {
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.done() || p.next().kind != tokenClose {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return inner, nil
+	}
+	if tok.kind != tokenWord {
+		return nil, fmt.Errorf("unexpected %q, expected a comparison like habit=Run", tok.text)
+	}
+
+	if p.done() || p.tokens[p.pos].kind != tokenOp {
+		return nil, fmt.Errorf("%q needs an operator and value, eg. %s=...", tok.text, tok.text)
+	}
+	op := p.next()
+	if p.done() || (p.tokens[p.pos].kind != tokenWord && p.tokens[p.pos].kind != tokenString) {
+		return nil, fmt.Errorf("%s%s needs a value", tok.text, op.text)
+	}
+	return newComparison(tok.text, op.text, p.next().text)
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenOp
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokenOpen, "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenClose, ")"})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated quote in query")
+			}
+			tokens = append(tokens, token{tokenString, string(runes[i+1 : end])})
+			i = end + 1
+		case strings.ContainsRune("=!<>~", r):
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' && r != '=' && r != '~' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected \"!\", did you mean !=?")
+			}
+			tokens = append(tokens, token{tokenOp, op})
+			i += len(op)
+		default:
+			end := i
+			for end < len(runes) && !strings.ContainsRune(" \t\n()\"=!<>~", runes[end]) {
+				end++
+			}
+			tokens = append(tokens, token{tokenWord, string(runes[i:end])})
+			i = end
+		}
+	}
+	return tokens, nil
+}
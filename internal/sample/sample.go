@@ -0,0 +1,177 @@
+// Package sample makes up plausible habits and logs: a few months of them
+// for harsh demo to show off with, or years of many habits for harsh gen to
+// load test with.
+package sample
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// habit is a sample habit and how reliably its made-up owner keeps it
+type habit struct {
+	heading   string
+	name      string
+	frequency string
+	// chance of doing the habit on a day it's due
+	chance float64
+	// weekdays is whether it's mostly done Monday to Friday
+	weekdays bool
+	// amount, when set, gives done days an amount up to this much
+	amount float64
+}
+
+var habits = []habit{
+	{heading: "Health", name: "Gym", frequency: "3/7", chance: 0.6},
+	{heading: "Health", name: "Walk 10k steps", frequency: "1", chance: 0.7, amount: 14000},
+	{heading: "Health", name: "Stretch", frequency: "1", chance: 0.55},
+	{heading: "Health", name: "Bed by midnight", frequency: "1", chance: 0.65},
+	{heading: "Mind", name: "Meditate", frequency: "1", chance: 0.75, amount: 25},
+	{heading: "Mind", name: "Read", frequency: "1", chance: 0.8, amount: 40},
+	{heading: "Mind", name: "Journal", frequency: "4/7", chance: 0.5},
+	{heading: "Mind", name: "Practice guitar", frequency: "3/7", chance: 0.45, amount: 60},
+	{heading: "Work", name: "Inbox zero", frequency: "1", chance: 0.6, weekdays: true},
+	{heading: "Work", name: "Weekly review", frequency: "7", chance: 0.8},
+	{heading: "Home", name: "Water plants", frequency: "3", chance: 0.85},
+	{heading: "Home", name: "Call family", frequency: "14", chance: 0.9},
+	{heading: "Tracking", name: "Coffee", frequency: "0", chance: 0.9, amount: 4},
+}
+
+// Comments written on some days, by outcome
+var (
+	doneComments   = []string{"felt great", "early start", "short one", "with a friend", "new personal best"}
+	missedComments = []string{storage.WithBreakReason("", "tired"), storage.WithBreakReason("", "travel"), "busy day", "forgot", storage.WithBreakReason("", "sick")}
+	notes          = []string{"Started new job", "Holiday", "Moved house", "Back from holiday", "Started a new routine"}
+)
+
+// Habits returns n sample habits, repeating the sample ones with numbered
+// names beyond the first few
+func Habits(n int) []*storage.Habit {
+	out := make([]*storage.Habit, n)
+	for i := range n {
+		h := habits[i%len(habits)]
+		name := h.name
+		if round := i / len(habits); round > 0 {
+			name += " " + strconv.Itoa(round+1)
+		}
+		out[i] = &storage.Habit{Heading: h.heading, Name: name, Frequency: h.frequency}
+	}
+	return out
+}
+
+// Log makes up a log for habits from day from up to and including day to,
+// the same for the same seed. Owners keep habits better after a good day and
+// worse after a bad one, drift in and out of good spells, and now and then
+// skip, forget to log, or go on holiday.
+func Log(habitList []*storage.Habit, from civil.Date, to civil.Date, seed int64) []string {
+	rng := rand.New(rand.NewSource(seed))
+	lines := []string{storage.DefaultHeader.String(), storage.LogVersionMarker + strconv.Itoa(storage.LogVersion)}
+	days := to.DaysSince(from) + 1
+	holiday := from.AddDays(rng.Intn(max(1, days)))
+	doneYesterday := make([]bool, len(habitList))
+	lastDone := make([]civil.Date, len(habitList))
+	for i := range lastDone {
+		lastDone[i] = from.AddDays(-30)
+	}
+
+	for d := from; !d.After(to); d = d.AddDays(1) {
+		// Spells of a month or so when everything goes better, or worse
+		mood := 1 + 0.15*math.Sin(float64(d.DaysSince(from))/15)
+		onHoliday := !d.Before(holiday) && d.DaysSince(holiday) < 7
+		if d == holiday || (rng.Float64() < 0.01 && days > 30) {
+			lines = append(lines, d.String()+storage.NoteSeparator+notes[rng.Intn(len(notes))])
+		}
+		weekend := d.Weekday() == 0 || d.Weekday() == 6
+		for i, habit := range habitList {
+			h := habits[i%len(habits)]
+			if rng.Float64() < 0.02 {
+				// Forgot to log
+				doneYesterday[i] = false
+				continue
+			}
+			target, interval := frequency(h.frequency)
+			chance := h.chance * mood
+			if doneYesterday[i] {
+				chance += 0.1
+			} else {
+				chance -= 0.05
+			}
+			if h.weekdays && weekend {
+				chance /= 4
+			}
+			if interval > 1 {
+				// Spread the target over the interval, more likely as it runs out
+				chance *= float64(target) / float64(interval) * (1 + float64(d.DaysSince(lastDone[i]))/float64(interval))
+			}
+
+			result, comment, amount := "n", "", ""
+			switch {
+			case onHoliday && target > 0:
+				result, comment = "s", "holiday"
+			case rng.Float64() < chance:
+				result = "y"
+				lastDone[i] = d
+				if rng.Float64() < 0.08 {
+					comment = doneComments[rng.Intn(len(doneComments))]
+				}
+			case rng.Float64() < 0.04:
+				result = "s"
+			case target == 0 || (interval > 1 && rng.Float64() < 0.7):
+				// Tracking habits and habits on track are only logged when done
+				doneYesterday[i] = false
+				continue
+			case rng.Float64() < 0.15:
+				comment = missedComments[rng.Intn(len(missedComments))]
+			}
+			if result == "y" && h.amount > 0 {
+				amount = strconv.Itoa(1 + int(h.amount*(0.4+0.6*rng.Float64())))
+			}
+			doneYesterday[i] = result == "y"
+			lines = append(lines, storage.EntryLine(d, habit.Name, result, comment, amount, storage.DefaultHeader))
+		}
+	}
+	return lines
+}
+
+// frequency returns a sample frequency's target and interval in days
+func frequency(f string) (int, int) {
+	target, interval, found := strings.Cut(f, "/")
+	t, _ := strconv.Atoi(target)
+	if !found {
+		if t == 0 {
+			return 0, 1
+		}
+		return 1, t
+	}
+	i, _ := strconv.Atoi(interval)
+	return t, i
+}
+
+// Write makes up habits and a log from day from up to and including day to in
+// configDir, which must not have habits or a log yet
+func Write(configDir string, habitList []*storage.Habit, from civil.Date, to civil.Date, seed int64) error {
+	for _, file := range []string{"habits", "log"} {
+		if _, err := os.Stat(filepath.Join(configDir, file)); err == nil {
+			return fmt.Errorf("%s already has a %s file", configDir, file)
+		}
+	}
+	if err := os.MkdirAll(configDir, os.ModePerm); err != nil {
+		return fmt.Errorf("cannot create %s: %w", configDir, err)
+	}
+	if err := storage.AppendHabitsConfig(configDir, habitList); err != nil {
+		return err
+	}
+	lines := Log(habitList, from, to, seed)
+	if err := os.WriteFile(filepath.Join(configDir, "log"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("cannot write log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"cloud.google.com/go/civil"
+)
+
+// EntryWrite is an entry to write, with WriteEntry's arguments
+type EntryWrite struct {
+	Day     civil.Date
+	Habit   string
+	Result  string
+	Comment string
+	Amount  string
+}
+
+// OutcomeWrite is the write logging outcome for key
+func OutcomeWrite(key DailyHabit, outcome Outcome) EntryWrite {
+	var amount string
+	if outcome.Amount != 0 {
+		amount = strconv.FormatFloat(outcome.Amount, 'f', -1, 64)
+	}
+	return EntryWrite{Day: key.Day, Habit: key.Habit, Result: outcome.Result, Comment: outcome.Comment, Amount: amount}
+}
+
+// BatchWriter is implemented by repositories that write many entries at
+// once, in a single append or transaction rather than one per entry
+type BatchWriter interface {
+	WriteEntries(writes []EntryWrite, header Header) error
+}
+
+// WriteEntries writes entries through repository, in one batch when it's a
+// BatchWriter and one at a time otherwise
+func WriteEntries(repository Repository, writes []EntryWrite, header Header) error {
+	if batch, ok := repository.(BatchWriter); ok {
+		return batch.WriteEntries(writes, header)
+	}
+	for _, w := range writes {
+		if err := repository.WriteEntry(w.Day, w.Habit, w.Result, w.Comment, w.Amount, header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteEntries appends entries to the log file in one write
+func (r *FileRepository) WriteEntries(writes []EntryWrite, header Header) error {
+	return WriteHabitLogs(r.configDir, writes, header)
+}
+
+// WriteEntries writes entries to the database in one transaction
+func (r *SQLiteRepository) WriteEntries(writes []EntryWrite, header Header) error {
+	script := sqliteSchema + "BEGIN;\n"
+	for _, w := range writes {
+		statement, err := entryInsert(w.Day, w.Habit, w.Result, w.Comment, w.Amount)
+		if err != nil {
+			return err
+		}
+		script += statement
+	}
+	return r.exec(script + "COMMIT;\n")
+}
+
+// WriteEntries writes entries unless any of their dates is locked
+func (r *LockedRepository) WriteEntries(writes []EntryWrite, header Header) error {
+	for _, w := range writes {
+		if err := r.CheckLocked(w.Day); err != nil {
+			return err
+		}
+	}
+	return WriteEntries(r.Repository, writes, header)
+}
+
+// WriteEntries writes entries, then records when, like WriteEntry
+func (r *TimedRepository) WriteEntries(writes []EntryWrite, header Header) error {
+	if err := WriteEntries(r.Repository, writes, header); err != nil {
+		return err
+	}
+	for _, w := range writes {
+		r.recordWrite(w.Day, w.Habit)
+	}
+	return nil
+}
+
+// WriteEntries writes entries, then their signatures, like WriteEntry
+func (r *SignedRepository) WriteEntries(writes []EntryWrite, header Header) error {
+	if err := WriteEntries(r.Repository, writes, header); err != nil {
+		return err
+	}
+	lines := make([]string, len(writes))
+	for i, w := range writes {
+		lines[i] = EntryLine(w.Day, w.Habit, w.Result, w.Comment, w.Amount, header)
+	}
+	if err := AppendSignatures(r.GetConfigDir(), r.Key, lines); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// BundleManifestFile is the first file in a bundle, describing the rest
+const BundleManifestFile = "manifest.json"
+
+// BundleFormat identifies harsh bundles in their manifest
+const BundleFormat = "harsh-bundle"
+
+// BundleVersion is the version of the bundle layout written. Bundles from
+// newer versions are refused rather than half understood.
+const BundleVersion = 1
+
+// BundleManifest describes a bundle of a config directory: the habits, logs,
+// settings, and sidecar state files, for moving to a new machine or
+// attaching to a bug report
+type BundleManifest struct {
+	Format       string       `json:"format"`
+	Version      int          `json:"version"`
+	HarshVersion string       `json:"harsh_version"`
+	Platform     string       `json:"platform"`
+	Created      string       `json:"created"`
+	Files        []BundleFile `json:"files"`
+}
+
+// BundleFile is one file in a bundle, with its path relative to the config directory
+type BundleFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// Mode is the file's permissions, restored on import. Bundles from
+	// before it was kept restore files as 0644.
+	Mode fs.FileMode `json:"mode,omitempty"`
+}
+
+// unbundled reports whether a file in the config directory is kept out of
+// bundles: the signing key, which mustn't travel, and caches and files harsh
+// is halfway through writing, which are rebuilt. The SQLite database is only
+// bundled while it's the backend in use, as otherwise it's a stale copy.
+func unbundled(rel string, sqliteBackend bool) bool {
+	switch {
+	case rel == SigningKeyFile, rel == IncludesDir, strings.HasPrefix(rel, IncludesDir+"/"), rel == ScoresFile:
+		return true
+	case strings.HasSuffix(rel, ".tmp"):
+		return true
+	case rel == SQLiteFile:
+		return !sqliteBackend
+	}
+	return false
+}
+
+// BundlePaths returns every file in configDir that goes in a bundle, relative
+// to it and slash separated. Hidden files and directories are left out, as
+// are the signing key and caches.
+func BundlePaths(configDir string) ([]string, error) {
+	var paths []string
+	settings, _ := LoadSettings(configDir)
+	sqliteBackend := settings.String("backend") == "sqlite"
+	err := filepath.WalkDir(configDir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != configDir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(configDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if p != configDir && unbundled(rel, sqliteBackend) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list config directory %s: %w", configDir, err)
+	}
+	return paths, nil
+}
+
+// WriteBundle writes the bundle files of configDir to w as a gzipped tar,
+// manifest first. manifest's Files are filled in from the files written.
+func WriteBundle(w io.Writer, configDir string, manifest BundleManifest) (BundleManifest, error) {
+	return WriteBundleWith(w, configDir, manifest, nil)
+}
+
+// WriteBundleWith writes a bundle like WriteBundle with extra files, by path,
+// added after configDir's, eg. a crash report. Extra files at a path configDir
+// already has a file at are left out. An empty configDir bundles only the
+// extra files.
+func WriteBundleWith(w io.Writer, configDir string, manifest BundleManifest, extra map[string][]byte) (BundleManifest, error) {
+	var paths []string
+	if configDir != "" {
+		var err error
+		if paths, err = BundlePaths(configDir); err != nil {
+			return manifest, err
+		}
+	}
+	manifest.Format, manifest.Version, manifest.Files = BundleFormat, BundleVersion, nil
+	contents := make([][]byte, len(paths))
+	modes := make([]fs.FileMode, len(paths))
+	for i, p := range paths {
+		name := filepath.Join(configDir, filepath.FromSlash(p))
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return manifest, fmt.Errorf("cannot read %s: %w", p, err)
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return manifest, fmt.Errorf("cannot read %s: %w", p, err)
+		}
+		contents[i], modes[i] = data, info.Mode().Perm()
+	}
+	for _, p := range sortedKeys(extra) {
+		if slices.Contains(paths, p) {
+			continue
+		}
+		paths = append(paths, p)
+		contents = append(contents, extra[p])
+		modes = append(modes, 0644)
+	}
+	for i, p := range paths {
+		sum := sha256.Sum256(contents[i])
+		manifest.Files = append(manifest.Files, BundleFile{Path: p, Size: int64(len(contents[i])), SHA256: hex.EncodeToString(sum[:]), Mode: modes[i]})
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+	add := func(name string, data []byte, mode fs.FileMode) error {
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: int64(mode), Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		_, err := archive.Write(data)
+		return err
+	}
+	if err := add(BundleManifestFile, append(manifestData, '\n'), 0644); err != nil {
+		return manifest, fmt.Errorf("cannot write bundle: %w", err)
+	}
+	for i, p := range paths {
+		if err := add(p, contents[i], modes[i]); err != nil {
+			return manifest, fmt.Errorf("cannot write bundle: %w", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return manifest, fmt.Errorf("cannot write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return manifest, fmt.Errorf("cannot write bundle: %w", err)
+	}
+	return manifest, nil
+}
+
+// ReadBundle reads a bundle written by WriteBundle, checking every file
+// against the manifest. It returns the manifest and the files' contents by path.
+func ReadBundle(r io.Reader) (BundleManifest, map[string][]byte, error) {
+	var manifest BundleManifest
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return manifest, nil, fmt.Errorf("not a harsh bundle: %w", err)
+	}
+	defer gz.Close()
+	archive := tar.NewReader(gz)
+
+	header, err := archive.Next()
+	if err != nil || header.Name != BundleManifestFile {
+		return manifest, nil, errors.New("not a harsh bundle: it doesn't start with a manifest")
+	}
+	if err := json.NewDecoder(archive).Decode(&manifest); err != nil || manifest.Format != BundleFormat {
+		return manifest, nil, errors.New("not a harsh bundle: unreadable manifest")
+	}
+	if manifest.Version > BundleVersion {
+		return manifest, nil, fmt.Errorf("bundle version %d is newer than this harsh understands (%d), upgrade harsh to import it", manifest.Version, BundleVersion)
+	}
+
+	files := map[string][]byte{}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, nil, fmt.Errorf("cannot read bundle: %w", err)
+		}
+		if !validBundlePath(header.Name) {
+			return manifest, nil, fmt.Errorf("bundle has a file outside the config directory: %s", header.Name)
+		}
+		var data bytes.Buffer
+		if _, err := io.Copy(&data, archive); err != nil {
+			return manifest, nil, fmt.Errorf("cannot read %s from bundle: %w", header.Name, err)
+		}
+		files[header.Name] = data.Bytes()
+	}
+
+	for _, file := range manifest.Files {
+		data, ok := files[file.Path]
+		if !ok {
+			return manifest, nil, fmt.Errorf("bundle is missing %s", file.Path)
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != file.SHA256 {
+			return manifest, nil, fmt.Errorf("bundle's %s is corrupt", file.Path)
+		}
+	}
+	if len(files) != len(manifest.Files) {
+		return manifest, nil, errors.New("bundle has files its manifest doesn't list")
+	}
+	return manifest, files, nil
+}
+
+// validBundlePath reports whether p stays inside the directory it's extracted to
+func validBundlePath(p string) bool {
+	clean := path.Clean(p)
+	return clean == p && !path.IsAbs(p) && clean != ".." && !strings.HasPrefix(clean, "../") && clean != BundleManifestFile
+}
+
+// BundleConflicts returns the bundle files that would replace a file in
+// configDir holding something else. The example habits file and an empty
+// log, as created on first run, don't count.
+func BundleConflicts(configDir string, files map[string][]byte) []string {
+	var conflicts []string
+	for _, file := range sortedKeys(files) {
+		existing, err := os.ReadFile(filepath.Join(configDir, filepath.FromSlash(file)))
+		if err != nil || bytes.Equal(existing, files[file]) || len(existing) == 0 || string(existing) == DEFAULT_HABITS {
+			continue
+		}
+		conflicts = append(conflicts, file)
+	}
+	return conflicts
+}
+
+// ExtractBundle writes bundle files into configDir, replacing any already
+// there, with the permissions manifest recorded for them
+func ExtractBundle(configDir string, manifest BundleManifest, files map[string][]byte) error {
+	modes := make(map[string]fs.FileMode, len(manifest.Files))
+	for _, file := range manifest.Files {
+		modes[file.Path] = file.Mode.Perm()
+	}
+	for _, file := range sortedKeys(files) {
+		dest := filepath.Join(configDir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return fmt.Errorf("cannot create %s: %w", filepath.Dir(dest), err)
+		}
+		mode := modes[file]
+		if mode == 0 {
+			mode = 0644
+		}
+		if err := os.WriteFile(dest, files[file], mode); err != nil {
+			return fmt.Errorf("cannot write %s: %w", dest, err)
+		}
+		// WriteFile leaves the mode of files already there alone
+		if err := os.Chmod(dest, mode); err != nil {
+			return fmt.Errorf("cannot set permissions of %s: %w", dest, err)
+		}
+	}
+	return nil
+}
+
+func sortedKeys(files map[string][]byte) []string {
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
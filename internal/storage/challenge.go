@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/civil"
+)
+
+// ChallengesFile is the sidecar file in the config directory holding the
+// challenges started with `harsh challenge start`
+const ChallengesFile = "challenges"
+
+// Challenge is a time-boxed goal of doing a habit every day for a number of days
+type Challenge struct {
+	Name  string
+	Habit string
+	Start civil.Date
+	Days  int
+}
+
+// End returns the last day of the challenge
+func (c Challenge) End() civil.Date {
+	return c.Start.AddDays(c.Days - 1)
+}
+
+// Active reports whether day falls within the challenge
+func (c Challenge) Active(day civil.Date) bool {
+	return !day.Before(c.Start) && !day.After(c.End())
+}
+
+// Challenges are all the challenges in the sidecar file, in the order started
+type Challenges []Challenge
+
+// LoadChallenges reads the challenges from the sidecar file in configDir.
+// Each starts with a `challenge = <name>` line followed by its `habit`,
+// `start`, and `days`. A missing file yields none.
+func LoadChallenges(configDir string) (Challenges, error) {
+	path := filepath.Join(configDir, ChallengesFile)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot open challenges file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var challenges Challenges
+	scanner := bufio.NewScanner(file)
+	lineCount := 0
+	for scanner.Scan() {
+		lineCount++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		key, value, _ := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key != "challenge" && len(challenges) == 0 {
+			return nil, fmt.Errorf("%s line %d: %q comes before any challenge", path, lineCount, key)
+		}
+		var err error
+		switch key {
+		case "challenge":
+			challenges = append(challenges, Challenge{Name: value})
+		case "habit":
+			challenges[len(challenges)-1].Habit = value
+		case "start":
+			challenges[len(challenges)-1].Start, err = civil.ParseDate(value)
+		case "days":
+			challenges[len(challenges)-1].Days, err = strconv.Atoi(value)
+		default:
+			err = fmt.Errorf("unknown key %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, lineCount, err)
+		}
+	}
+	return challenges, scanner.Err()
+}
+
+// Find returns the challenge named name, ignoring case
+func (c Challenges) Find(name string) (Challenge, bool) {
+	i := slices.IndexFunc(c, func(challenge Challenge) bool { return strings.EqualFold(challenge.Name, name) })
+	if i == -1 {
+		return Challenge{}, false
+	}
+	return c[i], true
+}
+
+// Active returns the challenges running on day
+func (c Challenges) Active(day civil.Date) Challenges {
+	var active Challenges
+	for _, challenge := range c {
+		if challenge.Active(day) {
+			active = append(active, challenge)
+		}
+	}
+	return active
+}
+
+// Remove drops the challenge named name, ignoring case
+func (c Challenges) Remove(name string) Challenges {
+	return slices.DeleteFunc(c, func(challenge Challenge) bool { return strings.EqualFold(challenge.Name, name) })
+}
+
+// Save writes the challenges to the sidecar file in configDir, removing the
+// file once there are none
+func (c Challenges) Save(configDir string) error {
+	path := filepath.Join(configDir, ChallengesFile)
+	if len(c) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove challenges file %s: %w", path, err)
+		}
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString("# challenges started with `harsh challenge start`\n")
+	for _, challenge := range c {
+		fmt.Fprintf(&b, "\nchallenge = %s\nhabit = %s\nstart = %s\ndays = %d\n", challenge.Name, challenge.Habit, challenge.Start, challenge.Days)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("cannot write challenges file %s: %w", path, err)
+	}
+	return nil
+}
@@ -2,13 +2,17 @@ package storage
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/civil"
 )
@@ -21,8 +25,112 @@ type Habit struct {
 	Target      int
 	Interval    int
 	FirstRecord civil.Date
+	// GraphDays is how many days the habit's graph covers when longer than the
+	// usual countback, set with the graphdays=N option
+	GraphDays int
+	// NoAutofill keeps harsh daemon from filling in the habit, set with the
+	// autofill=no option
+	NoAutofill bool
+	// Hidden leaves the habit out of harsh log unless asked for with --all
+	// or by name, set with the hidden=yes option
+	Hidden bool
+	// MinGap is how many rest days completions need between them to count
+	// toward the target, set with the minGap=N option
+	MinGap int
+	// CountTimes counts a done day's amount as that many completions toward
+	// the target instead of one, set with the count=times option
+	CountTimes bool
+	// Tags group habits to narrow commands to with --tag, set with #words
+	// after the frequency like "Run 5k: 3/7 #fitness #outdoor"
+	Tags []string
+	// Priority weighs how urgent the habit is for harsh focus, set with the
+	// priority=N option. Habits without it count as 1.
+	Priority int
+	// AmountGoal is the amount a done day needs to count as done, eg. 50 for
+	// "Pushups: 1 @ 50". Done days short of it are logged but count for
+	// nothing. It's 0 for habits without one.
+	AmountGoal float64
+	// Fallback is the easier version of the habit that still keeps its streak
+	// going, eg. "Walk 20min" for "Run 5k | or: Walk 20min"
+	Fallback string
+	// AskDue only asks about the habit once it's about to break, set with the
+	// ask=due option
+	AskDue bool
+	// AskDays only asks about the habit on these weekdays, set with an option
+	// like ask=mon,thu
+	AskDays []time.Weekday
+	// Days are the weekdays the habit is expected on, for a frequency like
+	// Mon,Wed,Fri. It's nil for habits expected any day.
+	Days []time.Weekday
+	// Line is the habits file line the habit is defined on, 0 when it wasn't
+	// read from one
+	Line int
+	// Include is the URL of the shared habits file the habit was included
+	// from, which harsh only reads
+	Include string
+	// History is when the habit's frequency changed, in the order recorded,
+	// for At
+	History []FrequencyChange
 }
 
+// FallbackSeparator sets a habit's fallback apart from its name in the
+// habits file
+const FallbackSeparator = "| or:"
+
+// AsksOn reports whether harsh ask asks about the habit on day d's weekday
+func (habit *Habit) AsksOn(d civil.Date) bool {
+	if len(habit.AskDays) == 0 {
+		return true
+	}
+	return slices.Contains(habit.AskDays, d.In(time.UTC).Weekday())
+}
+
+// ExpectedOn reports whether the habit is expected on day d's weekday, so
+// other days neither count against it nor break its streaks
+func (habit *Habit) ExpectedOn(d civil.Date) bool {
+	if len(habit.Days) == 0 {
+		return true
+	}
+	return slices.Contains(habit.Days, d.In(time.UTC).Weekday())
+}
+
+// MetGoal reports whether outcome is a done day that reaches the habit's
+// amount goal, or any done day for a habit without one
+func (habit *Habit) MetGoal(outcome Outcome) bool {
+	return outcome.Result == "y" && outcome.Amount >= habit.AmountGoal
+}
+
+// Short reports whether outcome is a done day under the habit's amount goal
+func (habit *Habit) Short(outcome Outcome) bool {
+	return outcome.Result == "y" && !habit.MetGoal(outcome)
+}
+
+// Completions returns how many times outcome counts toward habit's target
+func (habit *Habit) Completions(outcome Outcome) int {
+	if !habit.MetGoal(outcome) {
+		return 0
+	}
+	if habit.CountTimes {
+		return max(1, int(outcome.Amount))
+	}
+	return 1
+}
+
+// HasTag reports whether the habit is tagged with tag, ignoring case and a
+// leading #
+func (habit *Habit) HasTag(tag string) bool {
+	tag = strings.TrimPrefix(tag, "#")
+	for _, t := range habit.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// HabitNamePadding is the blank space kept to the left of the habit name column
+const HabitNamePadding = 10
+
 const DEFAULT_HABITS = 
 `# This is your habits file.
 # It tells harsh what to track and how frequently.
@@ -44,12 +152,38 @@ Used harsh: 0
 
 // ParseHabitFrequency parses the frequency string and sets Target and Interval
 func (habit *Habit) ParseHabitFrequency() {
+	if err := habit.parseFrequency(); err != nil {
+		fmt.Printf("Error: A frequency in your habit file has %v.\n", err)
+		fmt.Println("The problem entry to fix is: " + habit.Name + " : " + habit.Frequency)
+		os.Exit(1)
+	}
+}
+
+// parseFrequency sets Target and Interval from the frequency string, or
+// describes what is wrong with it
+func (habit *Habit) parseFrequency() error {
+	// Days like 7 or w come first, so anything else starting with a letter
+	// is weekdays like Mon,Wed,Fri, expected once on each of them
+	frequency := strings.TrimSpace(habit.Frequency)
+	if _, err := parseDay(frequency); err != nil && IsNotDigit(rune(frequency[0])) && !strings.Contains(frequency, "/") {
+		var days []time.Weekday
+		for _, day := range strings.Split(frequency, ",") {
+			weekday, ok := weekdays[strings.ToLower(strings.TrimSpace(day))]
+			if !ok {
+				return fmt.Errorf("an unknown weekday %q", strings.TrimSpace(day))
+			}
+			if !slices.Contains(days, weekday) {
+				days = append(days, weekday)
+			}
+		}
+		habit.Target, habit.Interval, habit.Days = 1, 1, days
+		return nil
+	}
+
 	freq := strings.Split(habit.Frequency, "/")
 	target, err := parseDay(strings.TrimSpace(freq[0]))
 	if err != nil {
-		fmt.Println("Error: A frequency in your habit file has a non-integer before the slash.")
-		fmt.Println("The problem entry to fix is: " + habit.Name + " : " + habit.Frequency)
-		os.Exit(1)
+		return errors.New("a non-integer before the slash")
 	}
 
 	var interval int
@@ -63,18 +197,16 @@ func (habit *Habit) ParseHabitFrequency() {
 	} else {
 		interval, err = parseDay(strings.TrimSpace(freq[1]))
 		if err != nil || interval == 0 {
-			fmt.Println("Error: A frequency in your habit file has a non-integer or zero after the slash.")
-			fmt.Println("The problem entry to fix is: " + habit.Name + " : " + habit.Frequency)
-			os.Exit(1)
+			return errors.New("a non-integer or zero after the slash")
 		}
 	}
-	if target > interval {
-		fmt.Println("Error: A frequency in your habit file has a target value greater than the interval period.")
-		fmt.Println("The problem entry to fix is: " + habit.Name + " : " + habit.Frequency)
-		os.Exit(1)
+	if target > interval && !habit.CountTimes {
+		return errors.New("a target value greater than the interval period (add count=times to count several a day)")
 	}
 	habit.Target = target
 	habit.Interval = interval
+	habit.Days = nil
+	return nil
 }
 
 func parseDay(input string) (int, error) {
@@ -111,8 +243,20 @@ func IsNotDigit(r rune) bool {
 	return r > '9' || r < '0'
 }
 
-// LoadHabitsConfig loads habits in config file ordered slice
+// LoadHabitsConfig loads habits in config file ordered slice, exiting with
+// an explanation when they can't be
 func LoadHabitsConfig(configDir string) ([]*Habit, int) {
+	habits, maxHabitNameLength, err := ReadHabitsConfig(configDir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return habits, maxHabitNameLength
+}
+
+// ReadHabitsConfig loads habits in config file ordered slice, returning why
+// when the habits file can't be read or has errors
+func ReadHabitsConfig(configDir string) ([]*Habit, int, error) {
 	habitsPath := filepath.Join(configDir, "/habits")
 	file, err := os.Open(habitsPath)
 	if err != nil {
@@ -120,106 +264,513 @@ func LoadHabitsConfig(configDir string) ([]*Habit, int) {
 			// Check for common cloud storage scenarios
 			icloudPath := filepath.Join(configDir, ".habits.icloud")
 			if _, err := os.Stat(icloudPath); err == nil {
-				fmt.Println("Error: Your habits file is currently syncing with iCloud.")
-				fmt.Println("The file appears as '.habits.icloud' while syncing.")
-				fmt.Println("Please wait for sync to complete, or disable iCloud for the harsh folder.")
-				os.Exit(1)
+				return nil, 0, errors.New("your habits file is currently syncing with iCloud.\n" +
+					"The file appears as '.habits.icloud' while syncing.\n" +
+					"Please wait for sync to complete, or disable iCloud for the harsh folder.")
 			}
 
 			// Check if config directory exists but habits file doesn't
 			if _, err := os.Stat(configDir); err == nil {
-				fmt.Printf("Error: Habits file not found at %s\n", habitsPath)
-				fmt.Println("This might be your first time using harsh.")
-				fmt.Println("Run 'harsh' without arguments to create an example habits file.")
-				os.Exit(1)
+				return nil, 0, fmt.Errorf("habits file not found at %s\n"+
+					"This might be your first time using harsh.\n"+
+					"Run 'harsh' without arguments to create an example habits file.", habitsPath)
 			}
 
 			// Config directory doesn't exist
-			fmt.Printf("Error: Configuration directory not found at %s\n", configDir)
-			fmt.Println("Run 'harsh' without arguments to initialize your configuration.")
-			os.Exit(1)
+			return nil, 0, fmt.Errorf("configuration directory not found at %s\n"+
+				"Run 'harsh' without arguments to initialize your configuration.", configDir)
 		}
 
 		// For permission errors or other issues, provide context
 		if os.IsPermission(err) {
-			fmt.Printf("Error: Permission denied accessing habits file at %s\n", habitsPath)
-			fmt.Println("Check file permissions or try running with appropriate privileges.")
-			os.Exit(1)
+			return nil, 0, fmt.Errorf("permission denied accessing habits file at %s\n"+
+				"Check file permissions or try running with appropriate privileges.", habitsPath)
 		}
 
-		// For other errors, use the original behavior but with more context
-		fmt.Printf("Error opening habits file at %s: %v\n", habitsPath, err)
-		os.Exit(1)
+		return nil, 0, fmt.Errorf("cannot open habits file at %s: %w", habitsPath, err)
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	return readHabitsConfig(file, CachedIncludes(configDir))
+}
+
+// HabitsConfigProblem describes a malformed line in a habits file
+type HabitsConfigProblem struct {
+	Line       int
+	Text       string
+	Problem    string
+	Suggestion string
+	// Fatal problems make the habits file too ambiguous to load at all
+	Fatal bool
+}
+
+// ParseHabitsConfig parses habits from any reader in habits file format.
+// Every malformed line is reported with its line number; lines that can be
+// skipped are, while fatal problems stop harsh until the file is fixed.
+func ParseHabitsConfig(r io.Reader) ([]*Habit, int) {
+	return parseHabitsConfig(r, nil)
+}
+
+// parseHabitsConfig is ParseHabitsConfig reading includes with fetch
+func parseHabitsConfig(r io.Reader, fetch IncludeFetcher) ([]*Habit, int) {
+	habits, maxHabitNameLength, err := readHabitsConfig(r, fetch)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return habits, maxHabitNameLength
+}
+
+// readHabitsConfig parses habits reading includes with fetch, printing every
+// problem found and returning an error when any were fatal
+func readHabitsConfig(r io.Reader, fetch IncludeFetcher) ([]*Habit, int, error) {
+	habits, problems := CheckHabitsConfigWith(r, fetch)
 
-	var heading string
+	fatal := false
+	for _, problem := range problems {
+		level := "Warning"
+		if problem.Fatal {
+			level = "Error"
+			fatal = true
+		}
+		fmt.Printf("%s: %s at line %d: %s\n", level, problem.Problem, problem.Line, problem.Text)
+		if problem.Suggestion != "" {
+			fmt.Printf("  %s\n", problem.Suggestion)
+		}
+	}
+	if fatal {
+		return nil, 0, errors.New("fix the errors above in your habits file and run harsh again")
+	}
+
+	maxHabitNameLength := 0
+	for _, habit := range habits {
+		if len(habit.Name) > maxHabitNameLength {
+			maxHabitNameLength = len(habit.Name)
+		}
+	}
+
+	return habits, maxHabitNameLength + HabitNamePadding, nil
+}
+
+// CheckHabitsConfig parses habits file lines, collecting the habits that
+// loaded cleanly along with a problem for every line that didn't. Includes
+// are skipped, see CheckHabitsConfigWith.
+func CheckHabitsConfig(r io.Reader) ([]*Habit, []HabitsConfigProblem) {
+	return CheckHabitsConfigWith(r, nil)
+}
+
+// IncludeFetcher returns the text of the habits file at url, which must
+// match checksum unless it's empty. With an error it can still return an
+// older copy to use.
+type IncludeFetcher func(url string, checksum string) (string, error)
+
+// CheckHabitsConfigWith is CheckHabitsConfig reading the habits files
+// included from URLs with fetch
+func CheckHabitsConfigWith(r io.Reader, fetch IncludeFetcher) ([]*Habit, []HabitsConfigProblem) {
+	scanner := bufio.NewScanner(r)
+
+	var heading, defaultFrequency string
 	var habits []*Habit
+	var problems []HabitsConfigProblem
+	// Names only need to be unique within their heading, see qualifyHabitNames.
+	// Included habits are kept apart so they can't shadow your own.
+	definedAt := map[[2]string]int{}
+	includedAt := map[[2]string]int{}
 	lineCount := 0
+	// The include line each included habit came from
+	includeLines := map[*Habit]int{}
 
-	for scanner.Scan() {
-		lineCount++
-		line := scanner.Text()
-
-		if len(line) > 0 {
-			if line[0] == '!' {
-				// Parse heading line
-				if !strings.Contains(line, "! ") {
-					fmt.Printf("Warning: Malformed heading at line %d: %s\n", lineCount, line)
-					fmt.Println("Expected format: ! Heading Name")
-					continue
-				}
-				result := strings.Split(line, "! ")
-				if len(result) > 1 {
-					heading = result[1]
+	// Lines of an included habits file, read before the next line of r, and
+	// the URL they came from
+	var included []string
+	var include string
+	// The heading to go back to after an included file sets its own
+	var outerHeading, outerDefault string
+
+	report := func(line string, fatal bool, problem string, suggestion string) {
+		if include != "" {
+			// Someone else's mistake shouldn't stop harsh, so it's only skipped
+			problem += " in " + include
+			fatal = false
+		}
+		problems = append(problems, HabitsConfigProblem{
+			Line:       lineCount,
+			Text:       line,
+			Problem:    problem,
+			Suggestion: suggestion,
+			Fatal:      fatal,
+		})
+	}
+
+	for {
+		var line string
+		if len(included) > 0 {
+			line, included = included[0], included[1:]
+		} else {
+			if include != "" {
+				include, heading, defaultFrequency = "", outerHeading, outerDefault
+			}
+			if !scanner.Scan() {
+				break
+			}
+			lineCount++
+			line = scanner.Text()
+		}
+
+		if len(strings.TrimSpace(line)) == 0 || line[0] == '#' {
+			continue
+		}
+
+		if strings.HasPrefix(line, "include ") && !strings.Contains(line, ": ") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				report(line, false, "Skipping include", "Add the URL to include, like include https://example.com/habits.txt")
+				continue
+			}
+			url, checksum := fields[1], ""
+			if len(fields) > 2 {
+				checksum = strings.TrimPrefix(fields[2], "sha256=")
+			}
+			switch {
+			case include != "":
+				report(line, false, "Skipping include", "Included habits files can't include others")
+				continue
+			case fetch == nil:
+				report(line, false, "Skipping include", "Habits from URLs are only read from your config directory")
+				continue
+			}
+			text, err := fetch(url, checksum)
+			if err != nil && text == "" {
+				report(line, false, "Skipping include", err.Error())
+				continue
+			}
+			if err != nil {
+				report(line, false, "Using the copy cached earlier", err.Error())
+			}
+			// Habits before any heading in the included file go under this one
+			included, include = strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n"), url
+			outerHeading, outerDefault = heading, defaultFrequency
+			continue
+		}
+
+		if line[0] == '!' {
+			// Parse heading line
+			if !strings.HasPrefix(line, "! ") {
+				report(line, false, "Malformed heading", fmt.Sprintf("Expected format: ! Heading Name (eg. \"! %s\")", strings.TrimSpace(line[1:])))
+				continue
+			}
+			heading, defaultFrequency = parseHeading(line[2:])
+			if defaultFrequency != "" {
+				if err := (&Habit{Frequency: defaultFrequency}).parseFrequency(); err != nil {
+					report(line, true, fmt.Sprintf("Default frequency '%s' has %v", defaultFrequency, err), "Use days like 1, 7, or 1w, a target within days like 3/7, or weekdays like Mon,Wed,Fri")
+					defaultFrequency = ""
 				}
-			} else if line[0] != '#' {
-				// Parse habit line
-				var habitName, frequency string
-				i := strings.LastIndex(line, ": ")
-				if i == -1 {
-					habitName = line
-					frequency = ""
-				} else {
-					habitName = strings.TrimSpace(line[:i])
-					frequency = strings.TrimSpace(line[i+2:])
-					if habitName == "" {
-						fmt.Printf("Warning: Skipping habit with empty name at line %d\n", lineCount)
-						continue
-					}
-
-					if frequency == "" {
-						fmt.Printf("Warning: Skipping habit '%s' with empty frequency at line %d\n", habitName, lineCount)
-						continue
-					}
+			}
+			continue
+		}
+
+		// Parse habit line
+		var habitName, frequency string
+		if i := strings.LastIndex(line, ": "); i != -1 {
+			habitName = strings.TrimSpace(line[:i])
+			frequency = strings.TrimSpace(line[i+2:])
+		} else {
+			// A habit without a frequency is daily, or takes its heading's
+			habitName = strings.TrimSuffix(strings.TrimSpace(line), ":")
+			if defaultFrequency == "" {
+				frequency = "1"
+			}
+			if fields := strings.Fields(habitName); len(fields) > 1 && (&Habit{Frequency: fields[len(fields)-1]}).parseFrequency() == nil {
+				// Looks like a habit with the colon left out, eg. "Gym 3/7"
+				last := fields[len(fields)-1]
+				name := strings.TrimSpace(strings.TrimSuffix(habitName, last))
+				report(line, false, fmt.Sprintf("Reading '%s' as a habit without a frequency", habitName), fmt.Sprintf("Did you mean \"%s: %s\"?", name, last))
+			}
+		}
+
+		habitName, fallback, _ := strings.Cut(habitName, FallbackSeparator)
+		habitName, fallback = strings.TrimSpace(habitName), strings.TrimSpace(fallback)
+		if habitName == "" {
+			report(line, false, "Skipping habit with empty name", "Give the habit a name before the colon, eg. \"Read: "+frequency+"\"")
+			continue
+		}
+		frequency, options := splitHabitOptions(frequency)
+		frequency, goal, hasGoal := strings.Cut(frequency, "@")
+		frequency = strings.TrimSpace(frequency)
+		if frequency == "" && defaultFrequency != "" {
+			frequency = defaultFrequency
+		}
+		if frequency == "" {
+			report(line, false, fmt.Sprintf("Skipping habit '%s' with empty frequency", habitName), fmt.Sprintf("Add one after the colon, eg. \"%s: 1\"", habitName))
+			continue
+		}
+		if strings.Contains(habitName, " : ") {
+			// The log separates fields with " : " so this name could never be read back
+			report(line, true, fmt.Sprintf("Habit name '%s' contains \" : \"", habitName), "Remove or replace the colon in the name, which harsh uses to separate log fields")
+			continue
+		}
+		defined := definedAt
+		if include != "" {
+			defined = includedAt
+		}
+		if first, ok := defined[[2]string{heading, habitName}]; ok {
+			report(line, false, fmt.Sprintf("Skipping duplicate habit '%s'", habitName), fmt.Sprintf("It's already defined at line %d under the same heading. Rename one of them so their log entries can be told apart", first))
+			continue
+		}
+
+		h := Habit{Heading: heading, Name: habitName, Frequency: frequency, Fallback: fallback}
+		// Options go first since count=times allows targets above the interval
+		optionErrs := make([]error, len(options))
+		for i, option := range options {
+			optionErrs[i] = h.setOption(option)
+		}
+		if err := h.parseFrequency(); err != nil {
+			report(line, true, fmt.Sprintf("Frequency '%s' has %v", frequency, err), "Use days like 1, 7, or 1w, a target within days like 3/7, or weekdays like Mon,Wed,Fri")
+			continue
+		}
+		for i, err := range optionErrs {
+			if err != nil {
+				report(line, false, fmt.Sprintf("Ignoring option '%s' for '%s' (%v)", options[i], habitName, err), "Habit options go after the frequency, eg. \"Pay rent: 30 graphdays=365\"")
+			}
+		}
+		if hasGoal {
+			if amount, err := strconv.ParseFloat(strings.TrimSpace(goal), 64); err == nil && amount > 0 {
+				h.AmountGoal = amount
+			} else {
+				report(line, false, fmt.Sprintf("Ignoring amount goal '%s' for '%s'", strings.TrimSpace(goal), habitName), fmt.Sprintf("Put the amount a day needs after an @, eg. \"%s: %s @ 50\"", habitName, frequency))
+			}
+		}
+		defined[[2]string{heading, habitName}] = lineCount
+		if include != "" {
+			h.Include = include
+			includeLines[&h] = lineCount
+		} else {
+			h.Line = lineCount
+		}
+		habits = append(habits, &h)
+	}
+
+	// An included habit named like one of yours would rename yours or take
+	// its log entries, so yours wins and the included one is skipped
+	ownAt := map[string]int{}
+	for _, habit := range habits {
+		if _, ok := ownAt[habit.Name]; !ok && habit.Include == "" {
+			ownAt[habit.Name] = habit.Line
+		}
+	}
+	kept := habits[:0]
+	for _, habit := range habits {
+		if first, ok := ownAt[habit.Name]; ok && habit.Include != "" {
+			lineCount, include = includeLines[habit], habit.Include
+			report(habit.Name, false, fmt.Sprintf("Skipping included habit '%s'", habit.Name), fmt.Sprintf("Your habit at line %d has the same name", first))
+			continue
+		}
+		kept = append(kept, habit)
+	}
+	habits = kept
+
+	qualifyHabitNames(habits)
+
+	// Qualified names can still clash with a habit literally named
+	// "Heading/Name". Your own habits claim their names first.
+	claimedAt := map[string]int{}
+	clashing := map[*Habit]bool{}
+	for _, own := range []bool{true, false} {
+		for _, habit := range habits {
+			if (habit.Include == "") != own {
+				continue
+			}
+			if first, ok := claimedAt[habit.Name]; ok {
+				lineCount, include = habit.Line, habit.Include
+				if include != "" {
+					lineCount = includeLines[habit]
 				}
-				h := Habit{Heading: heading, Name: habitName, Frequency: frequency}
+				report(habit.Name, true, fmt.Sprintf("Habit '%s' clashes with the habit at line %d", habit.Name, first), "Rename one of them so their log entries can be told apart")
+				clashing[habit] = true
+				continue
+			}
+			claimedAt[habit.Name] = habit.Line
+		}
+	}
+	// Included habits that clashed are skipped rather than stopping harsh
+	kept = habits[:0]
+	for _, habit := range habits {
+		if !clashing[habit] || habit.Include == "" {
+			kept = append(kept, habit)
+		}
+	}
+
+	return kept, problems
+}
 
-				// ParseHabitFrequency may call os.Exit on invalid frequency
-				// This is the intended behavior for invalid config
-				(&h).ParseHabitFrequency()
-				habits = append(habits, &h)
+// splitHabitOptions separates the key=value options and #tags trailing a
+// frequency, eg. "30 graphdays=365 #home", from the frequency itself
+func splitHabitOptions(frequency string) (string, []string) {
+	fields := strings.Fields(frequency)
+	split := len(fields)
+	for split > 0 && (strings.Contains(fields[split-1], "=") || strings.HasPrefix(fields[split-1], "#")) {
+		split--
+	}
+	return strings.Join(fields[:split], " "), fields[split:]
+}
+
+// weekdays are the day names the ask option takes
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// setOption applies a key=value habit option or #tag from the habits file
+func (habit *Habit) setOption(option string) error {
+	if tag, ok := strings.CutPrefix(option, "#"); ok {
+		if tag == "" || strings.Contains(tag, "=") {
+			return fmt.Errorf("tags are a # and a word, eg. #fitness, got %q", option)
+		}
+		if !habit.HasTag(tag) {
+			habit.Tags = append(habit.Tags, tag)
+		}
+		return nil
+	}
+	key, value, _ := strings.Cut(option, "=")
+	switch strings.ToLower(key) {
+	case "graphdays":
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 1 {
+			return fmt.Errorf("graphdays must be a whole number of days, got %q", value)
+		}
+		habit.GraphDays = days
+	case "count":
+		switch strings.ToLower(value) {
+		case "days":
+			habit.CountTimes = false
+		case "times":
+			habit.CountTimes = true
+		default:
+			return fmt.Errorf("count must be days or times, got %q", value)
+		}
+	case "hidden":
+		switch strings.ToLower(value) {
+		case "yes", "true":
+			habit.Hidden = true
+		case "no", "false":
+			habit.Hidden = false
+		default:
+			return fmt.Errorf("hidden must be yes or no, got %q", value)
+		}
+	case "mingap":
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return fmt.Errorf("minGap must be a whole number of rest days, got %q", value)
+		}
+		habit.MinGap = days
+	case "priority":
+		priority, err := strconv.Atoi(value)
+		if err != nil || priority < 1 {
+			return fmt.Errorf("priority must be a whole number from 1 up, got %q", value)
+		}
+		habit.Priority = priority
+	case "ask":
+		habit.AskDue, habit.AskDays = false, nil
+		switch strings.ToLower(value) {
+		case "daily":
+		case "due":
+			habit.AskDue = true
+		default:
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := weekdays[strings.ToLower(day)]
+				if !ok {
+					return fmt.Errorf("ask must be daily, due, or weekdays like mon,thu, got %q", value)
+				}
+				habit.AskDays = append(habit.AskDays, weekday)
 			}
 		}
+	case "autofill":
+		switch strings.ToLower(value) {
+		case "yes", "true":
+			habit.NoAutofill = false
+		case "no", "false":
+			habit.NoAutofill = true
+		default:
+			return fmt.Errorf("autofill must be yes or no, got %q", value)
+		}
+	default:
+		return fmt.Errorf("unknown option %q", key)
 	}
+	return nil
+}
 
-	maxHabitNameLength := 0
+// parseHeading splits a heading line, minus its "! ", into the heading name and
+// the default frequency for habits below it, eg. "Health (default 3/7)"
+func parseHeading(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	open := strings.LastIndex(line, "(default ")
+	if open == -1 || !strings.HasSuffix(line, ")") {
+		return line, ""
+	}
+	return strings.TrimSpace(line[:open]), strings.TrimSpace(line[open+len("(default ") : len(line)-1])
+}
+
+// qualifyHabitNames prefixes habits sharing a name with one under an earlier
+// heading with their own heading, eg. "Work/Stretch" after "Stretch" under
+// Health, so each keeps its own log entries. The first keeps its name, so
+// adding a duplicate never takes entries from the habit already logged.
+func qualifyHabitNames(habits []*Habit) {
+	firstHeading := map[string]string{}
 	for _, habit := range habits {
-		if len(habit.Name) > maxHabitNameLength {
-			maxHabitNameLength = len(habit.Name)
+		first, ok := firstHeading[habit.Name]
+		if !ok {
+			firstHeading[habit.Name] = habit.Heading
+			continue
+		}
+		if habit.Heading != first {
+			habit.Name = habit.Heading + "/" + habit.Name
 		}
 	}
+}
+
+// ProjectConfigDir is the name of project-local config directories, which
+// take over from the os's default inside the directory they're in, eg. to
+// keep thesis habits in the thesis repository
+const ProjectConfigDir = ".harsh"
 
-	return habits, maxHabitNameLength + 10
+// FindProjectConfigDir returns the nearest .harsh directory in dir or any
+// directory above it, the way git finds .git, or "" when there's none. Like
+// git it doesn't look in or above your home directory, nor past the
+// filesystem dir is on, and it passes over .harsh directories someone else
+// owns, which could otherwise feed habits and settings to anyone below them.
+func FindProjectConfigDir(dir string) string {
+	home, _ := os.UserHomeDir()
+	start, err := os.Stat(dir)
+	if err != nil {
+		return ""
+	}
+	for home == "" || dir != filepath.Clean(home) {
+		candidate := filepath.Join(dir, ProjectConfigDir)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() && ownedByCurrentUser(info) {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		if info, err := os.Stat(parent); err != nil || !sameDevice(start, info) {
+			return ""
+		}
+		dir = parent
+	}
+	return ""
 }
 
-// FindConfigFiles checks os relevant habits and log file exist, returns path
-// If they do not exist, calls CreateExampleHabitsFile and CreateNewLogFile
-func FindConfigFiles() string {
+// ConfigDir returns the config directory, $HARSHPATH, the nearest project's
+// .harsh directory, or the os's default, without creating anything in it
+func ConfigDir() string {
 	configDir := os.Getenv("HARSHPATH")
 
+	if len(configDir) == 0 {
+		if wd, err := os.Getwd(); err == nil {
+			configDir = FindProjectConfigDir(wd)
+		}
+	}
 	if len(configDir) == 0 {
 		if runtime.GOOS == "windows" {
 			configDir = filepath.Join(os.Getenv("APPDATA"), "harsh")
@@ -227,8 +778,18 @@ func FindConfigFiles() string {
 			configDir = filepath.Join(os.Getenv("HOME"), ".config/harsh")
 		}
 	}
+	return configDir
+}
+
+// FindConfigFiles checks os relevant habits and log file exist, returns path
+// If they do not exist, calls CreateExampleHabitsFile and CreateNewLogFile,
+// or starts a temporary session when the config directory can't be written
+func FindConfigFiles() string {
+	configDir := ConfigDir()
 
 	if _, err := os.Stat(filepath.Join(configDir, "habits")); err == nil {
+	} else if err := checkWritable(configDir); err != nil {
+		return temporarySession(configDir, err)
 	} else {
 		welcome(configDir)
 	}
@@ -236,6 +797,47 @@ func FindConfigFiles() string {
 	return configDir
 }
 
+// checkWritable creates configDir if needed and checks files can be made in it
+func checkWritable(configDir string) error {
+	if err := os.MkdirAll(configDir, os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(configDir, ".harsh-write-check-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// TemporarySessionDir is where harsh keeps its files when the config
+// directory can't be written, eg. in read-only containers or on live USBs.
+// It lasts until the system's temporary files are cleaned up.
+func TemporarySessionDir() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("harsh-session-%d", os.Getuid()))
+}
+
+// temporarySession sets up example habits and an empty log in the temporary
+// session directory, so harsh can still be tried out and browsed when
+// configDir can't be written, and says so on every run
+func temporarySession(configDir string, reason error) string {
+	sessionDir := TemporarySessionDir()
+	if err := PrivateDir(sessionDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot set up %s (%v), nor a temporary session (%v). Set HARSHPATH to a writable directory.\n", configDir, reason, err)
+		os.Exit(1)
+	}
+	if _, err := os.Stat(filepath.Join(sessionDir, "habits")); err == nil {
+		fmt.Fprintf(os.Stderr, "Temporary session in %s, as %s isn't writable. Set HARSHPATH to keep your data.\n", sessionDir, configDir)
+		return sessionDir
+	}
+	CreateExampleHabitsFile(sessionDir)
+	CreateNewLogFile(sessionDir)
+	fmt.Fprintf(os.Stderr, "harsh can't write to its config directory %s (%v).\n", configDir, reason)
+	fmt.Fprintf(os.Stderr, "Started a temporary session in %s with example habits to try things out.\n", sessionDir)
+	fmt.Fprintln(os.Stderr, "Anything you log there is lost when temporary files are cleaned up. Set HARSHPATH to a writable directory to keep your data.")
+	return sessionDir
+}
+
 // CreateExampleHabitsFile writes a fresh Habits file for people to follow
 func CreateExampleHabitsFile(configDir string) {
 	fileName := filepath.Join(configDir, "/habits")
@@ -289,3 +891,51 @@ func welcome(configDir string) {
 	fmt.Println("Happy tracking! I genuinely hope this helps you with your goals. Buena suerte!")
 	os.Exit(0)
 }
+
+// RetiredHabitsFile is the file in the config directory that `harsh retire`
+// adds a final report to for every habit it retires
+const RetiredHabitsFile = "retired"
+
+// RemoveHabitConfig removes the line defining habit from the habits file in
+// configDir, leaving its log entries and the rest of the file alone
+func RemoveHabitConfig(configDir string, habit *Habit) error {
+	f, err := LoadHabitsFile(configDir)
+	if err != nil {
+		return err
+	}
+	if err := f.Remove(habit); err != nil {
+		return err
+	}
+	return f.Save()
+}
+
+// AppendRetiredHabit adds a retired habit's report to the retired habits
+// file in configDir, after any already there
+func AppendRetiredHabit(configDir string, report string) error {
+	fileName := filepath.Join(configDir, RetiredHabitsFile)
+	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open retired habits file %s: %w", fileName, err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		report = "\n" + report
+	}
+	if _, err := f.WriteString(report); err != nil {
+		return fmt.Errorf("failed to write to %s: %w", fileName, err)
+	}
+	return f.Close()
+}
+
+// AppendHabitsConfig adds habits to the habits file, each at the end of its
+// heading, leaving the rest of the file as it was
+func AppendHabitsConfig(configDir string, habits []*Habit) error {
+	f, err := LoadHabitsFile(configDir)
+	if err != nil {
+		return err
+	}
+	for _, habit := range habits {
+		f.Add(habit)
+	}
+	return f.Save()
+}
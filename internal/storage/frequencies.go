@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/civil"
+)
+
+// FrequenciesFile is the sidecar file in the config directory recording when
+// habits' frequencies changed, so old days are judged by the frequency they
+// had then rather than rescored against the new one
+const FrequenciesFile = "frequencies"
+
+// frequenciesHeader explains the frequencies file to anyone opening it
+const frequenciesHeader = `# harsh records here when your habits' frequencies change, so days before a
+# change keep the frequency they had. Each line is the date a frequency took
+# effect : habit : frequency. The first line for a habit is when harsh first
+# saw it, and days before it use that frequency too. Where lines overlap, the
+# later line wins.
+`
+
+// FrequencyChange is a habit taking on a frequency from a day on
+type FrequencyChange struct {
+	Since     civil.Date
+	Habit     string
+	Frequency string
+}
+
+// LoadFrequencyHistory reads the frequency changes recorded in configDir,
+// oldest first. A missing file yields none.
+func LoadFrequencyHistory(configDir string) ([]FrequencyChange, error) {
+	path := filepath.Join(configDir, FrequenciesFile)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot open frequencies file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var changes []FrequencyChange
+	scanner := bufio.NewScanner(file)
+	lineCount := 0
+	for scanner.Scan() {
+		lineCount++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Split(line, " : ")
+		if len(fields) != 3 {
+			return changes, fmt.Errorf("%s line %d: expected date : habit : frequency", path, lineCount)
+		}
+		since, err := civil.ParseDate(fields[0])
+		if err != nil {
+			return changes, fmt.Errorf("%s line %d: %w", path, lineCount, err)
+		}
+		changes = append(changes, FrequencyChange{Since: since, Habit: fields[1], Frequency: fields[2]})
+	}
+	return changes, scanner.Err()
+}
+
+// RecordFrequencyChanges appends changes to the frequencies file in configDir
+func RecordFrequencyChanges(configDir string, changes []FrequencyChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	path := filepath.Join(configDir, FrequenciesFile)
+	_, statErr := os.Stat(path)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open frequencies file %s: %w", path, err)
+	}
+	var b strings.Builder
+	if os.IsNotExist(statErr) {
+		b.WriteString(frequenciesHeader)
+	}
+	for _, change := range changes {
+		fmt.Fprintf(&b, "%s : %s : %s\n", change.Since, change.Habit, change.Frequency)
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot record frequency change in %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// TrackFrequencies gives every habit its recorded frequency history, along
+// with the frequency it has as of day today where that differs from the last
+// recorded or none is. Those changes are only applied, not recorded: they're
+// returned for RecordFrequencyChanges, so runs that only read leave the file
+// alone. Habits are left as they are when the history can't be read.
+func TrackFrequencies(configDir string, habits []*Habit, today civil.Date) ([]FrequencyChange, error) {
+	history, err := LoadFrequencyHistory(configDir)
+	if err != nil {
+		return nil, err
+	}
+	latest := map[string]string{}
+	for _, change := range history {
+		latest[change.Habit] = change.Frequency
+	}
+	var changes []FrequencyChange
+	for _, habit := range habits {
+		if frequency, ok := latest[habit.Name]; !ok || frequency != habit.Frequency {
+			changes = append(changes, FrequencyChange{Since: today, Habit: habit.Name, Frequency: habit.Frequency})
+		}
+	}
+	ApplyFrequencyHistory(habits, append(history, changes...))
+	return changes, nil
+}
+
+// ApplyFrequencyHistory gives each habit the changes recorded for it, so At
+// finds the frequency it had on a day
+func ApplyFrequencyHistory(habits []*Habit, history []FrequencyChange) {
+	byHabit := map[string][]FrequencyChange{}
+	for _, change := range history {
+		byHabit[change.Habit] = append(byHabit[change.Habit], change)
+	}
+	for _, habit := range habits {
+		habit.History = nil
+		for _, change := range byHabit[habit.Name] {
+			// A frequency that no longer parses can't be judged by, so it's left out
+			version := Habit{Frequency: change.Frequency, CountTimes: habit.CountTimes}
+			if version.parseFrequency() == nil {
+				habit.History = append(habit.History, change)
+			}
+		}
+	}
+}
+
+// At returns habit as it was on day d, with the frequency it had then. It's
+// habit itself unless its frequency has changed since d.
+func (habit *Habit) At(d civil.Date) *Habit {
+	if len(habit.History) == 0 {
+		return habit
+	}
+	// Days before the first change recorded keep the frequency it recorded.
+	// Later records win for the days they cover, so a change backdated to
+	// before an earlier record still applies.
+	frequency := habit.History[0].Frequency
+	for _, change := range habit.History[1:] {
+		if !change.Since.After(d) {
+			frequency = change.Frequency
+		}
+	}
+	if frequency == habit.Frequency {
+		return habit
+	}
+	then := *habit
+	then.Frequency = frequency
+	then.History = nil
+	then.parseFrequency()
+	return &then
+}
+
+// Retuned returns a copy of habit with frequency from day since on, keeping
+// its old frequency for the days before
+func (habit *Habit) Retuned(frequency string, since civil.Date) (*Habit, error) {
+	retuned := *habit
+	retuned.Frequency = frequency
+	if err := retuned.parseFrequency(); err != nil {
+		return nil, fmt.Errorf("%s has %v", frequency, err)
+	}
+	retuned.History = slices.Clone(habit.History)
+	if len(retuned.History) == 0 {
+		retuned.History = append(retuned.History, FrequencyChange{Since: since, Habit: habit.Name, Frequency: habit.Frequency})
+	}
+	retuned.History = append(retuned.History, FrequencyChange{Since: since, Habit: habit.Name, Frequency: frequency})
+	return &retuned, nil
+}
+
+// VersionedRepository wraps a Repository, recording the habits' frequencies
+// not recorded yet with the first entry written, so every command that logs
+// keeps the frequencies days were logged under while those only reading
+// leave the frequencies file alone
+type VersionedRepository struct {
+	Repository
+	// Changes are the frequencies left to record, see TrackFrequencies
+	Changes []FrequencyChange
+}
+
+// NewVersionedRepository wraps repository to record changes with its first write
+func NewVersionedRepository(repository Repository, changes []FrequencyChange) *VersionedRepository {
+	return &VersionedRepository{Repository: repository, Changes: changes}
+}
+
+// WriteEntry writes a log entry, then records the frequencies left to
+func (r *VersionedRepository) WriteEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	if err := r.Repository.WriteEntry(d, habit, result, comment, amount, header); err != nil {
+		return err
+	}
+	r.recordChanges()
+	return nil
+}
+
+// UpdateEntry changes a log entry, then records the frequencies left to
+func (r *VersionedRepository) UpdateEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	if err := r.Repository.UpdateEntry(d, habit, result, comment, amount, header); err != nil {
+		return err
+	}
+	r.recordChanges()
+	return nil
+}
+
+// WriteEntries writes entries, then records the frequencies left to
+func (r *VersionedRepository) WriteEntries(writes []EntryWrite, header Header) error {
+	if err := WriteEntries(r.Repository, writes, header); err != nil {
+		return err
+	}
+	r.recordChanges()
+	return nil
+}
+
+// recordChanges records the frequencies left to record. The entries stand
+// even if they can't be, and they're tried again with the next write.
+func (r *VersionedRepository) recordChanges() {
+	if len(r.Changes) == 0 {
+		return
+	}
+	if err := RecordFrequencyChanges(r.GetConfigDir(), r.Changes); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+	r.Changes = nil
+}
@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HabitsFile is a habits file line by line as written, so commands changing
+// habits in it leave everything else, comments, blank lines, spacing and
+// order, exactly as it was
+type HabitsFile struct {
+	path  string
+	lines []string
+	// newline ends lines harsh adds, matching the file's own line endings
+	newline string
+}
+
+// LoadHabitsFile reads the habits file in configDir for changing. A missing
+// file reads as empty, to be created on Save.
+func LoadHabitsFile(configDir string) (*HabitsFile, error) {
+	path := filepath.Join(configDir, "habits")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("cannot read habits file %s: %w", path, err)
+	}
+	f := ParseHabitsFile(string(data))
+	f.path = path
+	return f, nil
+}
+
+// ParseHabitsFile splits the text of a habits file into lines for changing
+func ParseHabitsFile(text string) *HabitsFile {
+	f := &HabitsFile{newline: "\n"}
+	if text != "" {
+		f.lines = strings.SplitAfter(text, "\n")
+		if f.lines[len(f.lines)-1] == "" {
+			f.lines = f.lines[:len(f.lines)-1]
+		}
+	}
+	if len(f.lines) > 0 && strings.HasSuffix(f.lines[0], "\r\n") {
+		f.newline = "\r\n"
+	}
+	return f
+}
+
+// String returns the habits file's text
+func (f *HabitsFile) String() string {
+	return strings.Join(f.lines, "")
+}
+
+// Save writes the habits file back over the one it was loaded from
+func (f *HabitsFile) Save() error {
+	if err := replaceFile(f.path, []byte(f.String()), 0644); err != nil {
+		return fmt.Errorf("cannot write habits file %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// Add adds a habit at the end of its heading, or of the habits before any
+// heading when it has none, starting the heading at the end of the file when
+// there isn't one yet
+func (f *HabitsFile) Add(habit *Habit) {
+	line := habit.Name + ": " + habit.Frequency + f.newline
+	heading, last, firstHeading := "", -1, -1
+	found := habit.Heading == ""
+	for i, text := range f.lines {
+		switch kind := habitsLineKind(text); {
+		case kind == '!':
+			heading, _ = parseHeading(strings.TrimPrefix(strings.TrimRight(text, "\r\n"), "!"))
+			found = found || heading == habit.Heading
+			if firstHeading == -1 {
+				firstHeading = i
+			}
+		case kind == 'h' && heading == habit.Heading:
+			last = i
+		}
+	}
+
+	switch {
+	case last != -1:
+		f.insert(last+1, line)
+	case found && habit.Heading != "":
+		// An empty heading, so the habit goes right below it
+		for i, text := range f.lines {
+			if habitsLineKind(text) == '!' {
+				if name, _ := parseHeading(strings.TrimPrefix(strings.TrimRight(text, "\r\n"), "!")); name == habit.Heading {
+					f.insert(i+1, line)
+					return
+				}
+			}
+		}
+	case habit.Heading == "" && firstHeading != -1:
+		// Above the first heading, and the comments introducing it
+		i := firstHeading
+		for i > 0 && habitsLineKind(f.lines[i-1]) == '#' {
+			i--
+		}
+		f.insert(i, line, f.newline)
+	default:
+		f.endLastLine()
+		if len(f.lines) > 0 && habitsLineKind(f.lines[len(f.lines)-1]) != ' ' {
+			f.lines = append(f.lines, f.newline)
+		}
+		if habit.Heading != "" {
+			f.lines = append(f.lines, "! "+habit.Heading+f.newline)
+		}
+		f.lines = append(f.lines, line)
+	}
+}
+
+// Remove removes the line defining habit, which must still be where the
+// habits file had it when habit was loaded
+func (f *HabitsFile) Remove(habit *Habit) error {
+	i, err := f.find(habit)
+	if err != nil {
+		return err
+	}
+	f.lines = append(f.lines[:i], f.lines[i+1:]...)
+	return nil
+}
+
+// Rename renames habit where it's defined, keeping its frequency, options,
+// fallback, and spacing as they were
+func (f *HabitsFile) Rename(habit *Habit, name string) error {
+	i, err := f.find(habit)
+	if err != nil {
+		return err
+	}
+	old, _ := habitsLineName(f.lines[i])
+	at := strings.Index(f.lines[i], old)
+	f.lines[i] = f.lines[i][:at] + name + f.lines[i][at+len(old):]
+	return nil
+}
+
+// SetFrequency changes habit's frequency where it's defined, keeping its
+// name, fallback, amount goal, options, and spacing as they were. A habit
+// taking its heading's default frequency gets one of its own.
+func (f *HabitsFile) SetFrequency(habit *Habit, frequency string) error {
+	i, err := f.find(habit)
+	if err != nil {
+		return err
+	}
+	line := strings.TrimRight(f.lines[i], "\r\n")
+	ending := f.lines[i][len(line):]
+	at := strings.LastIndex(line, ": ")
+	if at == -1 {
+		f.lines[i] = strings.TrimSuffix(strings.TrimRight(line, " \t"), ":") + ": " + frequency + ending
+		return nil
+	}
+	rest := line[at+2:]
+	start := len(rest) - len(strings.TrimLeft(rest, " \t"))
+	end := len(rest)
+	_, options := splitHabitOptions(rest)
+	for range options {
+		// Back over an option to the space before it
+		end = strings.LastIndexAny(strings.TrimRight(rest[:end], " \t"), " \t") + 1
+	}
+	if goal := strings.Index(rest[start:end], "@"); goal != -1 {
+		// The amount goal stays, eg. "1 @ 50" keeps "@ 50"
+		end = start + goal
+	}
+	tail := rest[end:]
+	if tail != "" {
+		tail = " " + tail
+	}
+	f.lines[i] = line[:at+2] + rest[:start] + frequency + tail + ending
+	return nil
+}
+
+// find returns the index of the line defining habit
+func (f *HabitsFile) find(habit *Habit) (int, error) {
+	if habit.Include != "" {
+		return 0, fmt.Errorf("%s comes from %s, change it there", habit.Name, habit.Include)
+	}
+	i := habit.Line - 1
+	if i >= 0 && i < len(f.lines) {
+		if name, ok := habitsLineName(f.lines[i]); ok && name == strings.TrimPrefix(habit.Name, habit.Heading+"/") {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("cannot find %s in habits file %s, has it changed?", habit.Name, f.path)
+}
+
+// insert puts lines in before index i
+func (f *HabitsFile) insert(i int, lines ...string) {
+	if i == len(f.lines) {
+		f.endLastLine()
+	}
+	f.lines = append(f.lines[:i], append(lines, f.lines[i:]...)...)
+}
+
+// endLastLine ends a last line left without a line ending, so more can follow
+func (f *HabitsFile) endLastLine() {
+	if n := len(f.lines); n > 0 && !strings.HasSuffix(f.lines[n-1], "\n") {
+		f.lines[n-1] += f.newline
+	}
+}
+
+// habitsLineKind returns what a habits file line is: ' ' for blank, '#' for a
+// comment, '!' for a heading, and 'h' for a habit
+func habitsLineKind(line string) byte {
+	switch {
+	case strings.TrimSpace(line) == "":
+		return ' '
+	case line[0] == '#' || line[0] == '!':
+		return line[0]
+	}
+	return 'h'
+}
+
+// habitsLineName returns the name a habit line defines, read the way
+// CheckHabitsConfig reads it
+func habitsLineName(line string) (string, bool) {
+	if habitsLineKind(line) != 'h' {
+		return "", false
+	}
+	name := strings.TrimRight(line, "\r\n")
+	if i := strings.LastIndex(name, ": "); i != -1 {
+		name = name[:i]
+	} else {
+		name = strings.TrimSuffix(strings.TrimSpace(name), ":")
+	}
+	name, _, _ = strings.Cut(name, FallbackSeparator)
+	return strings.TrimSpace(name), true
+}
@@ -0,0 +1,369 @@
+package storage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+// ImportReport holds the habits and entries read from another app's export,
+// along with anything that couldn't be represented in harsh
+type ImportReport struct {
+	Source      string
+	Habits      []*Habit
+	Entries     Entries
+	Unsupported []string
+	// dateOrder reads the export's dates like 03/04/2025
+	dateOrder DateOrder
+}
+
+// DateOrder is the order of day and month in dates like 03/04/2025
+type DateOrder string
+
+const (
+	// DetectDateOrder works the order out from dates that can only be read
+	// one way, like 03/14/2025
+	DetectDateOrder DateOrder = ""
+	MonthFirst      DateOrder = "mdy"
+	DayFirst        DateOrder = "dmy"
+)
+
+// Importers maps supported --from sources to their import functions
+var Importers = map[string]func(io.Reader, DateOrder) (*ImportReport, error){
+	"streaks":       ImportStreaksCSV,
+	"habitify":      ImportHabitifyCSV,
+	"habitify-json": ImportHabitifyJSON,
+}
+
+// ImportStreaksCSV reads a CSV export from the Streaks iOS app
+func ImportStreaksCSV(r io.Reader, order DateOrder) (*ImportReport, error) {
+	return importCSV(r, order, "Streaks", csvColumns{
+		habit:    []string{"title", "task", "task_title", "habit"},
+		date:     []string{"entry_date", "date", "day"},
+		status:   []string{"entry_type", "type", "status"},
+		amount:   []string{"entry_quantity", "quantity", "value"},
+		comment:  []string{"entry_note", "note", "notes"},
+		schedule: []string{"task_frequency", "frequency", "schedule", "repeat"},
+		category: []string{"category", "group"},
+	})
+}
+
+// ImportHabitifyCSV reads a CSV export from Habitify
+func ImportHabitifyCSV(r io.Reader, order DateOrder) (*ImportReport, error) {
+	return importCSV(r, order, "Habitify", csvColumns{
+		habit:    []string{"habit name", "habit", "name"},
+		date:     []string{"date", "log date", "day"},
+		status:   []string{"status", "state", "result"},
+		amount:   []string{"value", "amount", "quantity"},
+		comment:  []string{"note", "notes", "journal"},
+		schedule: []string{"goal period", "periodicity", "repeat", "frequency", "goal"},
+		category: []string{"area", "areas", "category"},
+	})
+}
+
+// habitifyJSONHabit is a habit in Habitify's JSON export
+type habitifyJSONHabit struct {
+	Name string `json:"name"`
+	Area string `json:"area"`
+	Goal struct {
+		Periodicity string  `json:"periodicity"`
+		Value       float64 `json:"value"`
+	} `json:"goal"`
+	Logs []struct {
+		Date   string  `json:"date"`
+		Status string  `json:"status"`
+		Value  float64 `json:"value"`
+		Note   string  `json:"note"`
+	} `json:"logs"`
+}
+
+// ImportHabitifyJSON reads a JSON export from Habitify: a list of habits, each
+// with a goal periodicity and its logs
+func ImportHabitifyJSON(r io.Reader, order DateOrder) (*ImportReport, error) {
+	var exported []habitifyJSONHabit
+	if err := json.NewDecoder(r).Decode(&exported); err != nil {
+		return nil, fmt.Errorf("cannot read Habitify JSON export: %w", err)
+	}
+	var dates []string
+	for _, h := range exported {
+		for _, l := range h.Logs {
+			dates = append(dates, l.Date)
+		}
+	}
+	order, err := detectDateOrder(dates, order)
+	if err != nil {
+		return nil, err
+	}
+	report := &ImportReport{Source: "Habitify", Entries: Entries{}, dateOrder: order}
+	for _, h := range exported {
+		name := sanitizeField(h.Name)
+		if name == "" {
+			report.Unsupported = append(report.Unsupported, "habit without a name")
+			continue
+		}
+		frequency, note := frequencyFromSchedule(h.Goal.Periodicity)
+		if note != "" {
+			report.Unsupported = append(report.Unsupported, fmt.Sprintf("%s: %s", name, note))
+		}
+		report.addHabit(name, sanitizeField(h.Area), frequency)
+		for _, l := range h.Logs {
+			if err := report.addEntry(name, l.Date, l.Status, l.Value, l.Note); err != nil {
+				report.Unsupported = append(report.Unsupported, err.Error())
+			}
+		}
+	}
+	return report, nil
+}
+
+type csvColumns struct {
+	habit, date, status, amount, comment, schedule, category []string
+}
+
+func importCSV(r io.Reader, order DateOrder, source string, columns csvColumns) (*ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s CSV export: %w", source, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s CSV export is empty", source)
+	}
+
+	header := map[string]int{}
+	for i, name := range rows[0] {
+		header[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	column := func(names []string) int {
+		for _, name := range names {
+			if i, ok := header[name]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	habitCol, dateCol, statusCol := column(columns.habit), column(columns.date), column(columns.status)
+	if habitCol == -1 || dateCol == -1 || statusCol == -1 {
+		return nil, fmt.Errorf("%s CSV export needs habit, date, and status columns (looked for %s / %s / %s)",
+			source, strings.Join(columns.habit, ", "), strings.Join(columns.date, ", "), strings.Join(columns.status, ", "))
+	}
+	amountCol, commentCol := column(columns.amount), column(columns.comment)
+	scheduleCol, categoryCol := column(columns.schedule), column(columns.category)
+
+	field := func(row []string, i int) string {
+		if i == -1 || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	dates := make([]string, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		dates = append(dates, field(row, dateCol))
+	}
+	order, err = detectDateOrder(dates, order)
+	if err != nil {
+		return nil, fmt.Errorf("%s CSV export: %w", source, err)
+	}
+	report := &ImportReport{Source: source, Entries: Entries{}, dateOrder: order}
+	scheduleNotes := map[string]bool{}
+	for lineCount, row := range rows[1:] {
+		name := sanitizeField(field(row, habitCol))
+		if name == "" {
+			report.Unsupported = append(report.Unsupported, fmt.Sprintf("line %d: no habit name", lineCount+2))
+			continue
+		}
+		frequency, note := frequencyFromSchedule(field(row, scheduleCol))
+		if note != "" && !scheduleNotes[name] {
+			scheduleNotes[name] = true
+			report.Unsupported = append(report.Unsupported, fmt.Sprintf("%s: %s", name, note))
+		}
+		report.addHabit(name, sanitizeField(field(row, categoryCol)), frequency)
+
+		var amount float64
+		if value := field(row, amountCol); value != "" {
+			amount, _ = strconv.ParseFloat(value, 64)
+		}
+		if err := report.addEntry(name, field(row, dateCol), field(row, statusCol), amount, field(row, commentCol)); err != nil {
+			report.Unsupported = append(report.Unsupported, fmt.Sprintf("line %d: %v", lineCount+2, err))
+		}
+	}
+	return report, nil
+}
+
+func (report *ImportReport) addHabit(name string, heading string, frequency string) {
+	for _, habit := range report.Habits {
+		if habit.Name == name {
+			return
+		}
+	}
+	habit := &Habit{Heading: heading, Name: name, Frequency: frequency}
+	habit.ParseHabitFrequency()
+	report.Habits = append(report.Habits, habit)
+}
+
+func (report *ImportReport) addEntry(name string, date string, status string, amount float64, comment string) error {
+	d, err := parseImportDate(date, report.dateOrder)
+	if err != nil {
+		return fmt.Errorf("%s: unreadable date %q", name, date)
+	}
+	result, ok := resultFromStatus(status)
+	if !ok {
+		return fmt.Errorf("%s on %s: status %q has no harsh equivalent", name, d, status)
+	}
+	report.Entries[DailyHabit{Day: d, Habit: name}] = Outcome{Result: result, Amount: amount, Comment: sanitizeField(comment)}
+	return nil
+}
+
+// SortedEntryKeys returns the report's entries ordered by date, then habit
+func (report *ImportReport) SortedEntryKeys() []DailyHabit {
+	return report.Entries.SortedKeys()
+}
+
+// resultFromStatus maps the other apps' completion states to y, n, or s
+func resultFromStatus(status string) (string, bool) {
+	status = strings.ToLower(status)
+	switch {
+	case strings.Contains(status, "skip"), strings.Contains(status, "rest"), strings.Contains(status, "pause"):
+		return "s", true
+	case strings.Contains(status, "miss"), strings.Contains(status, "fail"), strings.Contains(status, "broken"):
+		return "n", true
+	case strings.Contains(status, "complete"), strings.Contains(status, "done"), status == "success":
+		return "y", true
+	}
+	return "", false
+}
+
+var (
+	timesPerPeriod = regexp.MustCompile(`^(\d+)\s*(?:x|times?)?\s*(?:per|a|/|every)\s*(day|week|month)$`)
+	weekdayNames   = regexp.MustCompile(`\b(mon|tue|wed|thu|fri|sat|sun)[a-z]*\b`)
+)
+
+// frequencyFromSchedule maps another app's schedule description to a harsh
+// frequency, with a note when the mapping is approximate
+func frequencyFromSchedule(schedule string) (string, string) {
+	s := strings.ToLower(strings.TrimSpace(schedule))
+	switch s {
+	case "":
+		return "1", "no schedule in export, imported as daily"
+	case "daily", "every day", "everyday", "day":
+		return "1", ""
+	case "weekly", "every week", "once a week", "week":
+		return "7", ""
+	case "monthly", "every month", "once a month", "month":
+		return "30", "monthly schedule approximated as every 30 days"
+	}
+	if m := timesPerPeriod.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "day":
+			if n == 1 {
+				return "1", ""
+			}
+			return "1", fmt.Sprintf("%d times a day can't be represented, imported as daily", n)
+		case "week":
+			if n >= 1 && n <= 7 {
+				return fmt.Sprintf("%d/7", n), ""
+			}
+		case "month":
+			if n >= 1 && n <= 30 {
+				return fmt.Sprintf("%d/30", n), "monthly target approximated over 30 days"
+			}
+		}
+	}
+	if matches := weekdayNames.FindAllStringSubmatch(s, -1); len(matches) > 0 {
+		var days []string
+		for _, m := range matches {
+			day := strings.ToUpper(m[1][:1]) + m[1][1:]
+			if !slices.Contains(days, day) {
+				days = append(days, day)
+			}
+		}
+		return strings.Join(days, ","), ""
+	}
+	return "1", fmt.Sprintf("schedule %q not understood, imported as daily", schedule)
+}
+
+func parseImportDate(date string, order DateOrder) (civil.Date, error) {
+	date = strings.TrimSpace(date)
+	if d, err := civil.ParseDate(date); err == nil {
+		return d, nil
+	}
+	if m := slashDate.FindStringSubmatch(date); m != nil {
+		first, _ := strconv.Atoi(m[1])
+		second, _ := strconv.Atoi(m[2])
+		year, _ := strconv.Atoi(m[3])
+		d := civil.Date{Year: year, Month: time.Month(first), Day: second}
+		if order == DayFirst {
+			d = civil.Date{Year: year, Month: time.Month(second), Day: first}
+		}
+		if !d.IsValid() {
+			return civil.Date{}, fmt.Errorf("unreadable date %q", date)
+		}
+		return d, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006/01/02"} {
+		if t, err := time.Parse(layout, date); err == nil {
+			return civil.DateOf(t), nil
+		}
+	}
+	if len(date) > 10 {
+		return civil.ParseDate(date[:10])
+	}
+	return civil.Date{}, fmt.Errorf("unreadable date %q", date)
+}
+
+// slashDate matches dates like 03/04/2025, whose day and month can be either
+// way round
+var slashDate = regexp.MustCompile(`^(\d{1,2})/(\d{1,2})/(\d{4})\b`)
+
+// detectDateOrder returns order, or when it's DetectDateOrder the order
+// dates like 03/14/2025 among dates show. It fails when they show both, or
+// only ones like 03/04/2025 that could be either.
+func detectDateOrder(dates []string, order DateOrder) (DateOrder, error) {
+	if order != DetectDateOrder {
+		return order, nil
+	}
+	var ambiguous string
+	monthFirst, dayFirst := "", ""
+	for _, date := range dates {
+		m := slashDate.FindStringSubmatch(strings.TrimSpace(date))
+		if m == nil {
+			continue
+		}
+		first, _ := strconv.Atoi(m[1])
+		second, _ := strconv.Atoi(m[2])
+		switch {
+		case first > 12:
+			dayFirst = date
+		case second > 12:
+			monthFirst = date
+		default:
+			ambiguous = date
+		}
+	}
+	switch {
+	case monthFirst != "" && dayFirst != "":
+		return order, fmt.Errorf("dates %q and %q put the day and month in different orders", monthFirst, dayFirst)
+	case monthFirst != "":
+		return MonthFirst, nil
+	case dayFirst != "":
+		return DayFirst, nil
+	case ambiguous != "":
+		return order, fmt.Errorf("can't tell whether dates like %q are month/day or day/month, say which with --date-order mdy or dmy", ambiguous)
+	}
+	return order, nil
+}
+
+// sanitizeField strips the log's field separator out of imported text
+func sanitizeField(s string) string {
+	return strings.TrimSpace(strings.ReplaceAll(s, ":", ""))
+}
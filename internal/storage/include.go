@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// IncludesDir is the folder under the config directory caching the habits
+// files included from URLs
+const IncludesDir = "includes"
+
+// IncludeMaxAge is how long an included habits file is read from the cache
+// before it's fetched again
+const IncludeMaxAge = 24 * time.Hour
+
+// includeTimeout keeps an unreachable include from holding harsh up for long,
+// as it falls back to the cached copy anyway
+const includeTimeout = 10 * time.Second
+
+// maxIncludeSize is far beyond any habits file, so a wrong URL can't fill the cache
+const maxIncludeSize = 1 << 20
+
+// CachedIncludes returns an IncludeFetcher caching the habits files it
+// fetches in configDir. Plain http URLs need a checksum. A cached copy younger than IncludeMaxAge is used
+// without fetching, and an older one when the URL can't be reached or no
+// longer matches its checksum.
+func CachedIncludes(configDir string) IncludeFetcher {
+	return func(url string, checksum string) (string, error) {
+		if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+			return "", fmt.Errorf("only http and https URLs can be included, not %q", url)
+		}
+		if strings.HasPrefix(url, "http://") && checksum == "" {
+			// Anyone on the way could change the habits otherwise
+			return "", fmt.Errorf("%s isn't https, pin its sha256 to include it", url)
+		}
+		checksum = strings.ToLower(checksum)
+		path := filepath.Join(configDir, IncludesDir, includeCacheName(url))
+		cached, cacheErr := os.ReadFile(path)
+		usable := cacheErr == nil && (checksum == "" || sha256Hex(cached) == checksum)
+		if info, err := os.Stat(path); usable && err == nil && time.Since(info.ModTime()) < IncludeMaxAge {
+			return string(cached), nil
+		}
+
+		fallback := ""
+		if usable {
+			fallback = string(cached)
+		}
+		data, err := fetchInclude(url)
+		if err != nil {
+			return fallback, err
+		}
+		if sum := sha256Hex(data); checksum != "" && sum != checksum {
+			return fallback, fmt.Errorf("%s has changed: its sha256 is %s, not the %s pinned, update the pin once you've checked it", url, sum, checksum)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return string(data), fmt.Errorf("cannot cache %s: %w", url, err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return string(data), fmt.Errorf("cannot cache %s: %w", url, err)
+		}
+		return string(data), nil
+	}
+}
+
+// fetchInclude downloads the habits file at url
+func fetchInclude(url string) ([]byte, error) {
+	client := &http.Client{Timeout: includeTimeout}
+	response, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch %s: %w", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch %s: %s", url, response.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxIncludeSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch %s: %w", url, err)
+	}
+	if len(data) > maxIncludeSize {
+		return nil, fmt.Errorf("%s is too big to be a habits file", url)
+	}
+	return data, nil
+}
+
+// includeCacheName names the cache file for url, which can't be a file name itself
+func includeCacheName(url string) string {
+	return sha256Hex([]byte(url))[:16]
+}
+
+// sha256Hex returns the hex SHA-256 of data, as include lines pin it
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"sort"
+
+	"cloud.google.com/go/civil"
+)
+
+// DatedOutcome is an entry's outcome along with the day it was logged for
+type DatedOutcome struct {
+	Day civil.Date
+	Outcome
+}
+
+// HabitIndex holds every habit's entries sorted by day, so windows of days
+// are scanned as short slices instead of looked up in Entries one day at a
+// time. With years of logs and dozens of habits that's most of the work
+// graphs and stats do.
+type HabitIndex map[string][]DatedOutcome
+
+// NewHabitIndex indexes entries by habit
+func NewHabitIndex(entries Entries) HabitIndex {
+	index := HabitIndex{}
+	for key, outcome := range entries {
+		index[key.Habit] = append(index[key.Habit], DatedOutcome{Day: key.Day, Outcome: outcome})
+	}
+	for _, outcomes := range index {
+		sort.Slice(outcomes, func(i, j int) bool { return outcomes[i].Day.Before(outcomes[j].Day) })
+	}
+	return index
+}
+
+// search returns where day is or would go in habit's outcomes
+func (ix HabitIndex) search(habit string, day civil.Date) int {
+	outcomes := ix[habit]
+	return sort.Search(len(outcomes), func(i int) bool { return !outcomes[i].Day.Before(day) })
+}
+
+// Set indexes an entry, replacing whatever was indexed for its habit and day
+func (ix HabitIndex) Set(key DailyHabit, outcome Outcome) {
+	i := ix.search(key.Habit, key.Day)
+	outcomes := ix[key.Habit]
+	if i < len(outcomes) && outcomes[i].Day == key.Day {
+		outcomes[i].Outcome = outcome
+		return
+	}
+	ix[key.Habit] = append(outcomes[:i], append([]DatedOutcome{{Day: key.Day, Outcome: outcome}}, outcomes[i:]...)...)
+}
+
+// Delete drops an entry from the index
+func (ix HabitIndex) Delete(key DailyHabit) {
+	i := ix.search(key.Habit, key.Day)
+	outcomes := ix[key.Habit]
+	if i < len(outcomes) && outcomes[i].Day == key.Day {
+		ix[key.Habit] = append(outcomes[:i], outcomes[i+1:]...)
+	}
+}
+
+// Between returns habit's outcomes from from up to and including to, in day
+// order. The slice is the index's own, not to be changed.
+func (ix HabitIndex) Between(habit string, from civil.Date, to civil.Date) []DatedOutcome {
+	outcomes := ix[habit]
+	start := ix.search(habit, from)
+	end := start + sort.Search(len(outcomes)-start, func(i int) bool { return outcomes[start+i].Day.After(to) })
+	return outcomes[start:end]
+}
+
+// IndexHabits builds the log's habit index, which the log's Outcomes then
+// answers from. Record and Forget keep it up to date, so once indexed the
+// log's entries are only changed through them.
+func (l *Log) IndexHabits() {
+	if l.Entries == nil {
+		l.Entries = Entries{}
+	}
+	l.habits = NewHabitIndex(l.Entries)
+}
+
+// Outcomes returns habit's outcomes from from up to and including to, in day
+// order, from the log's habit index once built
+func (l *Log) Outcomes(habit string, from civil.Date, to civil.Date) []DatedOutcome {
+	if l.habits != nil {
+		return l.habits.Between(habit, from, to)
+	}
+	return l.Entries.Outcomes(habit, from, to)
+}
+
+// Outcomes returns habit's outcomes from from up to and including to, in day
+// order, looking up each day
+func (e Entries) Outcomes(habit string, from civil.Date, to civil.Date) []DatedOutcome {
+	var outcomes []DatedOutcome
+	for d := from; !d.After(to); d = d.AddDays(1) {
+		if outcome, ok := e[DailyHabit{Day: d, Habit: habit}]; ok {
+			outcomes = append(outcomes, DatedOutcome{Day: d, Outcome: outcome})
+		}
+	}
+	return outcomes
+}
+
+// History is entries as graphs and stats read them: one day's outcome, or a
+// habit's outcomes over a range of days. Entries look each day up, while a
+// Log with its habit index built answers ranges from per-habit sorted slices.
+type History interface {
+	Outcome(key DailyHabit) (Outcome, bool)
+	Outcomes(habit string, from civil.Date, to civil.Date) []DatedOutcome
+	// All is every entry, for the few readers that need them all at once
+	All() Entries
+}
+
+// OutcomeAt returns the outcome logged for key in history, or no outcome
+func OutcomeAt(history History, key DailyHabit) Outcome {
+	outcome, _ := history.Outcome(key)
+	return outcome
+}
+
+// Outcome returns the outcome logged for key, if any
+func (e Entries) Outcome(key DailyHabit) (Outcome, bool) {
+	outcome, ok := e[key]
+	return outcome, ok
+}
+
+// All returns the entries themselves
+func (e Entries) All() Entries {
+	return e
+}
+
+// Outcome returns the outcome logged for key, if any
+func (l *Log) Outcome(key DailyHabit) (Outcome, bool) {
+	outcome, ok := l.Entries[key]
+	return outcome, ok
+}
+
+// All returns the log's entries
+func (l *Log) All() Entries {
+	return l.Entries
+}
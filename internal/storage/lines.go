@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// MaxLineLength caps how many bytes a log line may have before it's skipped
+// with a warning rather than read, guarding against reading a huge file with
+// no line breaks into memory. 0 reads lines of any length. Set from the
+// max_line_length setting.
+var MaxLineLength = 0
+
+// lineScanner reads lines like bufio.Scanner, but without its 64KB limit, so
+// entries with long pasted comments are read rather than stopping the read
+// with "token too long"
+type lineScanner struct {
+	reader *bufio.Reader
+	line   string
+	// tooLong is whether the line just scanned was over MaxLineLength, and
+	// so left unread
+	tooLong bool
+	done    bool
+	err     error
+}
+
+func newLineScanner(r io.Reader) *lineScanner {
+	return &lineScanner{reader: bufio.NewReader(r)}
+}
+
+// Scan reads the next line, reporting whether there was one
+func (s *lineScanner) Scan() bool {
+	if s.done {
+		return false
+	}
+	var line []byte
+	s.tooLong = false
+	for {
+		chunk, err := s.reader.ReadSlice('\n')
+		if !s.tooLong {
+			line = append(line, chunk...)
+			if MaxLineLength > 0 && len(bytes.TrimRight(line, "\r\n")) > MaxLineLength {
+				// Keep reading to the end of the line, but not into memory
+				s.tooLong, line = true, nil
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			s.done = true
+			if err != io.EOF {
+				s.err = err
+				return false
+			}
+			if len(line) == 0 && !s.tooLong {
+				return false
+			}
+		}
+		break
+	}
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	s.line = string(bytes.TrimSuffix(line, []byte("\r")))
+	return true
+}
+
+// Text returns the line just scanned, without its line ending
+func (s *lineScanner) Text() string {
+	return s.line
+}
+
+// Err returns the first error reading, other than reaching the end
+func (s *lineScanner) Err() error {
+	return s.err
+}
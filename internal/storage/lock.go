@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/clock"
+)
+
+// LockedRepository wraps a Repository, refusing writes to days older than
+// LockAfterDays unless Force is set, to protect long-term data from
+// accidental edits
+type LockedRepository struct {
+	Repository
+	LockAfterDays int
+	Force         bool
+}
+
+// NewLockedRepository wraps repository with a lock after lockAfterDays days
+func NewLockedRepository(repository Repository, lockAfterDays int, force bool) *LockedRepository {
+	return &LockedRepository{Repository: repository, LockAfterDays: lockAfterDays, Force: force}
+}
+
+// CheckLocked returns an error if entries for date d are locked
+func (r *LockedRepository) CheckLocked(d civil.Date) error {
+	if r.Force || r.LockAfterDays <= 0 {
+		return nil
+	}
+	today := clock.Today()
+	if today.DaysSince(d) > r.LockAfterDays {
+		return fmt.Errorf("entries before %s are locked (lock_after_days is %d), use --force to change them",
+			today.AddDays(-r.LockAfterDays), r.LockAfterDays)
+	}
+	return nil
+}
+
+// WriteEntry writes a log entry unless its date is locked
+func (r *LockedRepository) WriteEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	if err := r.CheckLocked(d); err != nil {
+		return err
+	}
+	return r.Repository.WriteEntry(d, habit, result, comment, amount, header)
+}
+
+// UpdateEntry changes a log entry unless its date is locked
+func (r *LockedRepository) UpdateEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	if err := r.CheckLocked(d); err != nil {
+		return err
+	}
+	return r.Repository.UpdateEntry(d, habit, result, comment, amount, header)
+}
@@ -1,11 +1,14 @@
 package storage
 import (
-	"bufio"
+	"compress/gzip"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -49,10 +52,116 @@ var DefaultHeader = Header {
 type Log struct {
 	Entries Entries
 	Header Header
+	Notes Notes
+	// Version is the log's format version, see LogVersion
+	Version int
+	// Warnings describe lines that were skipped or misread while parsing
+	Warnings []string
+	// comments indexes entry comments once searched, see CommentIndex
+	comments *CommentIndex
+	// habits indexes entries by habit once indexed, see IndexHabits
+	habits HabitIndex
 }
 
-// LoadLog reads entries from log file
+// CommentIndex returns the index of the log's comments, building it on first use
+func (l *Log) CommentIndex() *CommentIndex {
+	if l.comments == nil {
+		l.comments = NewCommentIndex(l.Entries)
+	}
+	return l.comments
+}
+
+// Record sets the outcome of a habit on a day, keeping the comment and habit
+// indexes, if built, up to date with entries appended after loading
+func (l *Log) Record(key DailyHabit, outcome Outcome) {
+	l.Entries[key] = outcome
+	if l.comments != nil {
+		l.comments.Add(key, outcome.Comment)
+	}
+	if l.habits != nil {
+		l.habits.Set(key, outcome)
+	}
+}
+
+// Forget drops the entry of a habit on a day, and from the indexes too
+func (l *Log) Forget(key DailyHabit) {
+	delete(l.Entries, key)
+	if l.comments != nil {
+		l.comments.Add(key, "")
+	}
+	if l.habits != nil {
+		l.habits.Delete(key)
+	}
+}
+
+// NoteSeparator splits the date from the text of a note line in the log,
+// eg. "2025-03-10 :: Started new job"
+const NoteSeparator = " :: "
+
+// Notes maps days to the notes written about them in the log
+type Notes map[civil.Date][]string
+
+// SortedDays returns the days with notes from from up to and including to, in order
+func (n Notes) SortedDays(from civil.Date, to civil.Date) []civil.Date {
+	var days []civil.Date
+	for d := range n {
+		if !d.Before(from) && !d.After(to) {
+			days = append(days, d)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// BreakReasonTag starts the part of an entry's comment recording why a
+// streak broke, eg. "n : [why] travelling"
+const BreakReasonTag = "[why] "
+
+// WithBreakReason adds a break reason to a comment
+func WithBreakReason(comment string, reason string) string {
+	reason = BreakReasonTag + strings.ReplaceAll(reason, ":", "")
+	if comment == "" {
+		return reason
+	}
+	return comment + " " + reason
+}
+
+// BreakReason returns the break reason recorded in a comment, if any
+func BreakReason(comment string) (string, bool) {
+	_, reason, ok := strings.Cut(comment, BreakReasonTag)
+	return strings.TrimSpace(reason), ok && strings.TrimSpace(reason) != ""
+}
+
+// FallbackTag starts the comment of an entry done the habit's easier
+// fallback way, eg. "y : [or] rainy, walked instead"
+const FallbackTag = "[or]"
+
+// WithFallback marks a comment as done the fallback way
+func WithFallback(comment string) string {
+	if comment == "" {
+		return FallbackTag
+	}
+	return FallbackTag + " " + comment
+}
+
+// IsFallback reports whether a comment marks its entry as done the fallback way
+func IsFallback(comment string) bool {
+	return strings.HasPrefix(comment, FallbackTag)
+}
+
+// LoadLog reads entries from log file, exiting with an explanation when it
+// can't be
 func LoadLog(configDir string) *Log {
+	log, err := ReadLog(configDir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return log
+}
+
+// ReadLog reads entries from log file, returning why when it can't be read
+func ReadLog(configDir string) (*Log, error) {
 	logPath := filepath.Join(configDir, "/log")
 	file, err := os.Open(logPath)
 	if err != nil {
@@ -60,53 +169,135 @@ func LoadLog(configDir string) *Log {
 			// Check for common cloud storage scenarios
 			icloudPath := filepath.Join(configDir, ".log.icloud")
 			if _, err := os.Stat(icloudPath); err == nil {
-				fmt.Println("Error: Your log file is currently syncing with iCloud.")
-				fmt.Println("The file appears as '.log.icloud' while syncing.")
-				fmt.Println("Please wait for sync to complete, or disable iCloud for the harsh folder.")
-				os.Exit(1)
+				return nil, errors.New("your log file is currently syncing with iCloud.\n" +
+					"The file appears as '.log.icloud' while syncing.\n" +
+					"Please wait for sync to complete, or disable iCloud for the harsh folder.")
 			}
 
 			// Check if config directory exists but log file doesn't
 			if _, err := os.Stat(configDir); err == nil {
-				fmt.Printf("Error: Log file not found at %s\n", logPath)
-				fmt.Println("This might be your first time using harsh.")
-				fmt.Println("Run 'harsh' without arguments to initialize your configuration.")
-				os.Exit(1)
+				return nil, fmt.Errorf("log file not found at %s\n"+
+					"This might be your first time using harsh.\n"+
+					"Run 'harsh' without arguments to initialize your configuration.", logPath)
 			}
 
 			// Config directory doesn't exist
-			fmt.Printf("Error: Configuration directory not found at %s\n", configDir)
-			fmt.Println("Run 'harsh' without arguments to initialize your configuration.")
-			os.Exit(1)
+			return nil, fmt.Errorf("configuration directory not found at %s\n"+
+				"Run 'harsh' without arguments to initialize your configuration.", configDir)
 		}
 
 		// For permission errors or other issues, provide context
 		if os.IsPermission(err) {
-			fmt.Printf("Error: Permission denied accessing log file at %s\n", logPath)
-			fmt.Println("Check file permissions or try running with appropriate privileges.")
-			os.Exit(1)
+			return nil, fmt.Errorf("permission denied accessing log file at %s\n"+
+				"Check file permissions or try running with appropriate privileges.", logPath)
 		}
 
-		// For other errors, use the original behavior but with more context
-		fmt.Printf("Error opening log file at %s: %v\n", logPath, err)
-		os.Exit(1)
+		return nil, fmt.Errorf("cannot open log file at %s: %w", logPath, err)
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	current := ParseLog(file)
+	archived, err := LoadArchivedLogs(configDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		current.Warnings = append(current.Warnings, err.Error())
+	}
+	if archived != nil {
+		current.mergeOlder(archived)
+	}
+	return current, nil
+}
+
+// ArchivedLogPattern matches older log segments rotated out of the log file
+// and gzip-compressed, eg. "log-2021.gz"
+const ArchivedLogPattern = "log-*.gz"
+
+// LoadArchivedLogs reads the archived log segments in configDir, in name
+// order so later segments win, returning nil when there are none
+func LoadArchivedLogs(configDir string) (*Log, error) {
+	paths, err := filepath.Glob(filepath.Join(configDir, ArchivedLogPattern))
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var archived *Log
+	for _, path := range paths {
+		segment, err := loadArchivedLog(path)
+		if err != nil {
+			return archived, err
+		}
+		if archived != nil {
+			segment.mergeOlder(archived)
+		}
+		archived = segment
+	}
+	return archived, nil
+}
+
+func loadArchivedLog(path string) (*Log, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open archived log %s: %w", path, err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress archived log %s: %w", path, err)
+	}
+	defer gz.Close()
+	return ParseLog(gz), nil
+}
+
+// mergeOlder adds the entries and notes of an older log, keeping this log's
+// entries where both have one for the same habit and day
+func (l *Log) mergeOlder(older *Log) {
+	for key, outcome := range older.Entries {
+		if _, ok := l.Entries[key]; !ok {
+			l.Entries[key] = outcome
+		}
+	}
+	for day, notes := range older.Notes {
+		l.Notes[day] = append(append([]string{}, notes...), l.Notes[day]...)
+	}
+	l.Warnings = append(older.Warnings, l.Warnings...)
+}
+
+// ParseLog reads entries from any reader in log file format
+func ParseLog(r io.Reader) *Log {
+	scanner := newLineScanner(r)
 
 	entries := Entries{}
+	notes := Notes{}
+	var warnings []string
+	warn := func(format string, args ...any) {
+		warning := fmt.Sprintf(format, args...)
+		fmt.Println("Warning: " + warning)
+		warnings = append(warnings, warning)
+	}
 	lineCount := 0
 	scanner.Scan()
 	header, err := ParseHeader(scanner.Text())
+	parse := func() {
+		if scanner.tooLong {
+			warn("Skipping line %d, longer than %d bytes", lineCount, MaxLineLength)
+			return
+		}
+		parseLogLine(scanner.Text(), lineCount, header, entries, notes, warn)
+	}
 	if err != nil {
 		header = DefaultHeader
 		lineCount++
-		parseLogLine(scanner.Text(), lineCount, header, entries)
+		parse()
 	}
+	version := 1
 	for scanner.Scan() {
 		lineCount++
-		parseLogLine(scanner.Text(), lineCount, header, entries)
+		if lineCount <= 2 && strings.HasPrefix(scanner.Text(), LogVersionMarker) {
+			version = logVersion([]string{scanner.Text()})
+		}
+		parse()
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -115,6 +306,9 @@ func LoadLog(configDir string) *Log {
 	return &Log {
 		Entries: entries,
 		Header: header,
+		Notes: notes,
+		Version: version,
+		Warnings: warnings,
 	}
 }
 
@@ -132,15 +326,26 @@ func ParseHeader(line string) (Header, error) {
 	return out, nil
 }
 
-func parseLogLine(line string, lineCount int, header map[string]int, entries Entries) {
+func parseLogLine(line string, lineCount int, header map[string]int, entries Entries, notes Notes, warn func(format string, args ...any)) {
 	if len(line) > 0 {
+		// Notes have nothing but a date before the separator, unlike entries
+		// whose comments happen to contain one
+		if date, text, ok := strings.Cut(line, NoteSeparator); ok && line[0] != '#' && !strings.Contains(date, " : ") {
+			cd, err := civil.ParseDate(strings.TrimSpace(date))
+			if err != nil {
+				warn("Skipping note with invalid date at line %d: %s", lineCount, date)
+				return
+			}
+			notes[cd] = append(notes[cd], strings.TrimSpace(text))
+			return
+		}
 		if line[0] != '#' {
 			// Discards comments from read record read as result[header[HeaderComment]]
 			result := strings.Split(line, " : ")
 
 			// Warn for entries that have less than header's count
 			if len(result) != len(header) {
-				fmt.Printf("Warning: expected (%d) fields, found (%d) at line %d\n", len(header), len(result), lineCount)
+				warn("expected (%d) fields, found (%d) at line %d", len(header), len(result), lineCount)
 			}
 
 			var cd civil.Date
@@ -148,32 +353,37 @@ func parseLogLine(line string, lineCount int, header map[string]int, entries Ent
 				var err error
 				cd, err = civil.ParseDate(result[i])
 				if err != nil {
-					fmt.Printf("Warning: Skipping log entry with invalid date at line %d: %s\n", lineCount, result[i])
+					warn("Skipping log entry with invalid date at line %d: %s", lineCount, result[i])
 					return
 				}
 			}
 
 			if i, ok := header[HeaderHabit]; !ok || i >= len(result) || strings.TrimSpace(result[i]) == "" {
 				// Validate habit name is not empty
-				fmt.Printf("Warning: Skipping log entry with empty habit name at line %d\n", lineCount)
+				warn("Skipping log entry with empty habit name at line %d", lineCount)
 				return
 			}
 
 			// Validate result is y, n, or s
-			if i, ok := header[HeaderStatus]; ok && i < len(result) {
-				result[i] = strings.TrimSpace(result[i])
-				if result[i] != "y" && result[i] != "n" && result[i] != "s" {
-					fmt.Printf("Warning: Skipping log entry with invalid result '%s' at line %d (expected y/n/s)\n", result[i], lineCount)
-					return
-				}
+			i, ok := header[HeaderStatus]
+			if !ok || i >= len(result) {
+				warn("Skipping log entry without a result at line %d", lineCount)
+				return
+			}
+			result[i] = strings.TrimSpace(result[i])
+			if result[i] != "y" && result[i] != "n" && result[i] != "s" {
+				warn("Skipping log entry with invalid result '%s' at line %d (expected y/n/s)", result[i], lineCount)
+				return
 			}
 
 			var amount float64
 			if  i, ok := header[HeaderAmount]; ok && i < len(result) && result[i] != "" {
 				var err error
 				amount, err = strconv.ParseFloat(result[i], 64)
-				if err != nil {
-					fmt.Printf("Warning: Invalid amount '%s' at line %d, using %f\n", result[i], lineCount, amount)
+				// Out of range amounts parse as infinite, which nothing can chart
+				if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+					amount = 0
+					warn("Invalid amount '%s' at line %d, using %f", result[i], lineCount, amount)
 				}
 			}
 
@@ -186,22 +396,8 @@ func parseLogLine(line string, lineCount int, header map[string]int, entries Ent
 	}
 }
 
-// WriteHabitLog writes the log entry for a habit to file
-func WriteHabitLog(configDir string, d civil.Date, habit string, result string, comment string, amount string, header Header) error {
-	fileName := filepath.Join(configDir, "/log")
-	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		// Provide more specific error messages based on the type of error
-		if os.IsNotExist(err) {
-			return fmt.Errorf("configuration directory does not exist: %s", configDir)
-		}
-		if os.IsPermission(err) {
-			return fmt.Errorf("permission denied writing to log file: %s (check file permissions)", fileName)
-		}
-		// Check for disk space issues (this is a common cause of write failures)
-		return fmt.Errorf("cannot open log file %s: %w (this might be due to insufficient disk space or file system issues)", fileName, err)
-	}
-	defer f.Close()
+// EntryLine lays out a log entry's fields in header's column order
+func EntryLine(d civil.Date, habit string, result string, comment string, amount string, header Header) string {
 	fields := make([]string, len(header))
 	for header, i := range header {
 		var field string
@@ -219,7 +415,53 @@ func WriteHabitLog(configDir string, d civil.Date, habit string, result string,
 		}
 		fields[i] = field
 	}
-	logEntry := strings.Join(fields, " : ") + "\n"
+	return strings.Join(fields, " : ")
+}
+
+// WriteHabitLog writes the log entry for a habit to file
+func WriteHabitLog(configDir string, d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	return WriteHabitLogs(configDir, []EntryWrite{{Day: d, Habit: habit, Result: result, Comment: comment, Amount: amount}}, header)
+}
+
+// WriteHabitLogs appends the log entries for writes to file in one write
+func WriteHabitLogs(configDir string, writes []EntryWrite, header Header) error {
+	if len(writes) == 0 {
+		return nil
+	}
+	fileName := filepath.Join(configDir, "/log")
+	if _, err := os.Stat(configDir); os.IsNotExist(err) {
+		return fmt.Errorf("configuration directory does not exist: %s", configDir)
+	}
+	unlock, err := lockLog(configDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := upgradeLogForWrite(configDir); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		// Provide more specific error messages based on the type of error
+		if os.IsNotExist(err) {
+			return fmt.Errorf("configuration directory does not exist: %s", configDir)
+		}
+		if os.IsPermission(err) {
+			return fmt.Errorf("permission denied writing to log file: %s (check file permissions)", fileName)
+		}
+		// Check for disk space issues (this is a common cause of write failures)
+		return fmt.Errorf("cannot open log file %s: %w (this might be due to insufficient disk space or file system issues)", fileName, err)
+	}
+	defer f.Close()
+	var b strings.Builder
+	for _, w := range writes {
+		b.WriteString(EntryLine(w.Day, w.Habit, w.Result, w.Comment, w.Amount, header) + "\n")
+	}
+	logEntry := b.String()
+	if missingTrailingNewline(fileName) {
+		// Don't glue the entry onto a hand-edited last line
+		logEntry = "\n" + logEntry
+	}
 	if _, err := f.Write([]byte(logEntry)); err != nil {
 		f.Close() // ignore error; Write error takes precedence
 		// Check for common write failure causes
@@ -235,13 +477,139 @@ func WriteHabitLog(configDir string, d civil.Date, habit string, result string,
 	return nil
 }
 
+// ErrNoEntry is returned when editing an entry the log doesn't have
+var ErrNoEntry = errors.New("no entry to edit")
+
+// UpdateHabitLog rewrites the log line holding habit's entry for day d in
+// place, rather than appending a new one. Where the entry was logged more
+// than once, the last line, the one harsh reads, is rewritten. The log is
+// written beside itself and renamed over, so it's never half written, while
+// holding the lock appending takes, so no entry appended meanwhile is lost.
+func UpdateHabitLog(configDir string, d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	logPath := filepath.Join(configDir, "log")
+	unlock, err := lockLog(configDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := upgradeLogForWrite(configDir); err != nil {
+		return err
+	}
+	lines, i, err := findEntryLine(logPath, d, habit)
+	if err != nil {
+		return err
+	}
+	lines[i] = EntryLine(d, habit, result, comment, amount, header)
+
+	if err := replaceFile(logPath, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("cannot edit log: %w", err)
+	}
+	return nil
+}
+
+// findEntryLine reads the log at logPath, returning its lines and the index
+// of the last one holding habit's entry for day d
+func findEntryLine(logPath string, d civil.Date, habit string) ([]string, int, error) {
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot read log: %w", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	header := DefaultHeader
+	if parsed, err := ParseHeader(lines[0]); err == nil {
+		header = parsed
+	}
+	key := DailyHabit{Day: d, Habit: habit}
+	for i := len(lines) - 1; i >= 0; i-- {
+		entries := Entries{}
+		parseLogLine(strings.TrimSuffix(lines[i], "\r"), i+1, header, entries, Notes{}, func(string, ...any) {})
+		if _, ok := entries[key]; ok {
+			return lines, i, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("%w: %s has nothing logged on %s", ErrNoEntry, habit, d)
+}
+
+// SortedKeys returns the entries' keys ordered by date, then habit
+func (e Entries) SortedKeys() []DailyHabit {
+	keys := make([]DailyHabit, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Day != keys[j].Day {
+			return keys[i].Day.Before(keys[j].Day)
+		}
+		return keys[i].Habit < keys[j].Habit
+	})
+	return keys
+}
+
 // FirstRecords sets the FirstRecord field for habits based on their earliest entries
 func (e *Entries) FirstRecords(from civil.Date, to civil.Date, habits []*Habit) {
-	for dt := to; !dt.Before(from); dt = dt.AddDays(-1) {
-		for _, habit := range habits {
-			if _, ok := (*e)[DailyHabit{Day: dt, Habit: habit.Name}]; ok {
-				habit.FirstRecord = dt
-			}
+	firstRecords(e.Outcomes, from, to, habits)
+}
+
+// FirstRecords sets the FirstRecord field for habits based on their earliest
+// entries, read from the log's habit index once built
+func (l *Log) FirstRecords(from civil.Date, to civil.Date, habits []*Habit) {
+	firstRecords(l.Outcomes, from, to, habits)
+}
+
+func firstRecords(outcomes func(string, civil.Date, civil.Date) []DatedOutcome, from civil.Date, to civil.Date, habits []*Habit) {
+	for _, habit := range habits {
+		if dated := outcomes(habit.Name, from, to); len(dated) > 0 {
+			habit.FirstRecord = dated[0].Day
 		}
 	}
 }
+
+// NoteLine returns the log line for a note of text on day d
+func NoteLine(d civil.Date, text string) string {
+	return d.String() + NoteSeparator + strings.ReplaceAll(text, "\n", " ")
+}
+
+// WriteNote appends a dated note line to the log file in configDir
+func WriteNote(configDir string, d civil.Date, text string) error {
+	fileName := filepath.Join(configDir, "/log")
+	unlock, err := lockLog(configDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := upgradeLogForWrite(configDir); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open log file %s: %w", fileName, err)
+	}
+	defer f.Close()
+
+	line := NoteLine(d, text) + "\n"
+	if missingTrailingNewline(fileName) {
+		line = "\n" + line
+	}
+	if _, err := f.WriteString(line); err != nil {
+		return fmt.Errorf("failed to write note to %s: %w", fileName, err)
+	}
+	return f.Close()
+}
+
+// missingTrailingNewline reports whether a non-empty file's last line is unterminated
+func missingTrailingNewline(fileName string) bool {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return false
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return false
+	}
+	return last[0] != '\n'
+}
@@ -0,0 +1,27 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+
+package storage
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockLog holds an advisory lock on configDir while its log is written,
+// returning what releases it. Appending and rewriting the log both take it,
+// so an entry can't be appended to a log that's about to be renamed over.
+func lockLog(configDir string) (func(), error) {
+	dir, err := os.Open(configDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot lock log: %w", err)
+	}
+	if err := syscall.Flock(int(dir.Fd()), syscall.LOCK_EX); err != nil {
+		dir.Close()
+		return nil, fmt.Errorf("cannot lock log: %w", err)
+	}
+	return func() {
+		syscall.Flock(int(dir.Fd()), syscall.LOCK_UN)
+		dir.Close()
+	}, nil
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LogVersion is the version of the log format this harsh writes
+const LogVersion = 2
+
+// LogVersionMarker starts the comment line, right after the column header,
+// recording the log's format version, eg. "# harsh log version 2". Logs
+// without one are version 1. Being a comment, older harsh skips it.
+const LogVersionMarker = "# harsh log version "
+
+// LogMigration upgrades the lines of a log from one format version to the next
+type LogMigration struct {
+	From        int
+	Description string
+	Migrate     func(lines []string) []string
+}
+
+// LogMigrations are applied in order to bring older logs up to LogVersion.
+// Format changes add one here and bump LogVersion.
+var LogMigrations = []LogMigration{
+	{From: 1, Description: "add the column header", Migrate: migrateLogV1},
+}
+
+// migrateLogV1 gives logs without a column header the default one, so later
+// versions can add columns without guessing at the old layout
+func migrateLogV1(lines []string) []string {
+	if len(lines) > 0 {
+		if _, err := ParseHeader(lines[0]); err == nil {
+			return lines
+		}
+	}
+	return append([]string{DefaultHeader.String()}, lines...)
+}
+
+// String returns the header line of a log with this header
+func (h Header) String() string {
+	fields := make([]string, len(h))
+	for name, i := range h {
+		fields[i] = name
+	}
+	return strings.Join(fields, " : ")
+}
+
+// logVersion returns the format version recorded in a log's first lines
+func logVersion(lines []string) int {
+	for i := 0; i < len(lines) && i < 2; i++ {
+		if rest, ok := strings.CutPrefix(lines[i], LogVersionMarker); ok {
+			if version, err := strconv.Atoi(strings.TrimSpace(rest)); err == nil {
+				return version
+			}
+		}
+	}
+	return 1
+}
+
+// withVersionMarker returns lines with their version marker, if any,
+// replaced by one for version, placed after the column header
+func withVersionMarker(lines []string, version int) []string {
+	var out []string
+	for i, line := range lines {
+		if i < 2 && strings.HasPrefix(line, LogVersionMarker) {
+			continue
+		}
+		out = append(out, line)
+	}
+	at := 0
+	if len(out) > 0 {
+		if _, err := ParseHeader(out[0]); err == nil {
+			at = 1
+		}
+	}
+	marker := LogVersionMarker + strconv.Itoa(version)
+	return append(out[:at], append([]string{marker}, out[at:]...)...)
+}
+
+// MigrateLog upgrades the log file in configDir to LogVersion in place,
+// first copying it to log.v<version>.bak. It returns the version migrated
+// from, or 0 when the log is already current or empty. Logs from a newer
+// harsh are left alone with an error.
+func MigrateLog(configDir string) (int, error) {
+	unlock, err := lockLog(configDir)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	return migrateLog(configDir)
+}
+
+// migrateLog is MigrateLog for callers already holding the log lock
+func migrateLog(configDir string) (int, error) {
+	logPath := filepath.Join(configDir, "log")
+	info, err := os.Stat(logPath)
+	if err != nil {
+		// A missing log is reported when it's loaded
+		return 0, nil
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil || strings.TrimSpace(string(data)) == "" {
+		return 0, nil
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	version := logVersion(lines)
+	switch {
+	case version == LogVersion:
+		return 0, nil
+	case version > LogVersion:
+		return 0, newerLogError(version)
+	}
+
+	backup := filepath.Join(configDir, fmt.Sprintf("log.v%d.bak", version))
+	if _, err := os.Stat(backup); os.IsNotExist(err) {
+		if err := replaceFile(backup, data, info.Mode().Perm()); err != nil {
+			return 0, fmt.Errorf("cannot back up log before upgrading it: %w", err)
+		}
+	}
+	for _, migration := range LogMigrations {
+		if migration.From >= version {
+			lines = migration.Migrate(lines)
+		}
+	}
+	lines = withVersionMarker(lines, LogVersion)
+	if err := replaceFile(logPath, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return 0, fmt.Errorf("cannot upgrade log: %w", err)
+	}
+	return version, nil
+}
+
+// newerLogError is what refuses writing to a log from a newer harsh
+func newerLogError(version int) error {
+	return fmt.Errorf("log is format version %d, newer than this harsh understands (%d), upgrade harsh before logging", version, LogVersion)
+}
+
+// upgradeLogForWrite brings the log in configDir up to LogVersion before
+// something is written to it, refusing logs from a newer harsh. Reading
+// leaves the log as it is, so only the lock holder writing upgrades it.
+func upgradeLogForWrite(configDir string) error {
+	from, err := migrateLog(configDir)
+	if err != nil {
+		return err
+	}
+	if from > 0 {
+		fmt.Fprintf(os.Stderr, "Upgraded your log from format version %d to %d, the old one is in log.v%d.bak\n", from, LogVersion, from)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/civil"
+)
+
+// NormalizeResult describes what normalizing a log changed
+type NormalizeResult struct {
+	Lines []string
+	// Entries and Notes count what's kept
+	Entries int
+	Notes   int
+	// Duplicates counts entries dropped for a later entry of the same habit
+	// on the same day, which is the one harsh reads anyway
+	Duplicates int
+	// Changed is whether the normalized log differs from the original
+	Changed bool
+}
+
+// logRecord is an entry or note line of the log, with the comment lines
+// above it that travel with it
+type logRecord struct {
+	day      civil.Date
+	habit    string
+	note     bool
+	line     string
+	comments []string
+}
+
+// NormalizeLog rewrites a log's lines into one canonical form so the same
+// history always reads the same byte for byte: the default column header and
+// current version marker, entries sorted by date then habit with each day's
+// notes after them, later duplicates winning, fields trimmed, amounts written
+// plainly (eg. 5 rather than 5.0), and no blank lines. Comment lines move
+// with the line below them, except those above the first line, which stay
+// at the top. A log with lines harsh can't read is refused, as
+// they can't be placed.
+func NormalizeLog(lines []string) (NormalizeResult, error) {
+	header := DefaultHeader
+	body := lines
+	if len(lines) > 0 {
+		if parsed, err := ParseHeader(lines[0]); err == nil {
+			header, body = parsed, lines[1:]
+		}
+	}
+
+	var problems []string
+	var records []*logRecord
+	latest := map[DailyHabit]*logRecord{}
+	var leading, comments []string
+	result := NormalizeResult{}
+	for i, line := range body {
+		lineCount := i + 1 + len(lines) - len(body)
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, LogVersionMarker) {
+			continue
+		}
+		if trimmed[0] == '#' {
+			comments = append(comments, trimmed)
+			continue
+		}
+		entries, notes := Entries{}, Notes{}
+		var warnings []string
+		parseLogLine(line, lineCount, header, entries, notes, func(format string, args ...any) {
+			warnings = append(warnings, fmt.Sprintf(format, args...))
+		})
+		if len(entries) == 0 && len(notes) == 0 {
+			// Skipped lines can't be placed, but misshapen ones are rewritten
+			problems = append(problems, warnings...)
+			continue
+		}
+		if len(records) == 0 {
+			// Comments above the first line describe the whole log
+			leading, comments = comments, nil
+		}
+		for day, text := range notes {
+			records = append(records, &logRecord{day: day, note: true, line: day.String() + NoteSeparator + text[0], comments: comments})
+		}
+		for key, outcome := range entries {
+			entry, err := entryLine(key, outcome, header, line)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%v at line %d", err, lineCount))
+				continue
+			}
+			record := &logRecord{day: key.Day, habit: key.Habit, line: entry, comments: comments}
+			if earlier, ok := latest[key]; ok {
+				// Keep the comments that went with the dropped entry
+				record.comments = append(earlier.comments, comments...)
+				earlier.line, earlier.comments = "", nil
+				result.Duplicates++
+			}
+			latest[key] = record
+			records = append(records, record)
+		}
+		comments = nil
+	}
+	if len(problems) > 0 {
+		return result, fmt.Errorf("fix the log's unreadable lines before normalizing it:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	records = slices.DeleteFunc(records, func(r *logRecord) bool { return r.line == "" })
+	slices.SortStableFunc(records, func(a, b *logRecord) int {
+		if c := a.day.Compare(b.day); c != 0 {
+			return c
+		}
+		if a.note != b.note {
+			if a.note {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(a.habit, b.habit)
+	})
+
+	result.Lines = append([]string{DefaultHeader.String(), LogVersionMarker + strconv.Itoa(LogVersion)}, leading...)
+	for _, record := range records {
+		result.Lines = append(result.Lines, record.comments...)
+		result.Lines = append(result.Lines, record.line)
+		if record.note {
+			result.Notes++
+		} else {
+			result.Entries++
+		}
+	}
+	// Comments after the last line stay at the end
+	result.Lines = append(result.Lines, comments...)
+	result.Changed = !slices.Equal(result.Lines, lines)
+	return result, nil
+}
+
+// entryLine writes an entry in the default column order. Amounts are
+// rewritten from their value, and left empty when there wasn't one.
+func entryLine(key DailyHabit, outcome Outcome, header Header, original string) (string, error) {
+	amount := ""
+	fields := strings.Split(original, " : ")
+	if i, ok := header[HeaderAmount]; ok && i < len(fields) && strings.TrimSpace(fields[i]) != "" {
+		value, err := strconv.ParseFloat(strings.TrimSpace(fields[i]), 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid amount '%s'", fields[i])
+		}
+		amount = strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	values := map[string]string{
+		HeaderDate:    key.Day.String(),
+		HeaderHabit:   key.Habit,
+		HeaderStatus:  outcome.Result,
+		HeaderComment: strings.TrimSpace(outcome.Comment),
+		HeaderAmount:  amount,
+	}
+	out := make([]string, len(DefaultHeader))
+	for name, i := range DefaultHeader {
+		out[i] = values[name]
+	}
+	return strings.Join(out, " : "), nil
+}
+
+// NormalizeLogFile normalizes the log in configDir in place, first copying
+// it to log.bak, unless check is set. The log is read and rewritten holding
+// the lock appending takes, so no entry appended meanwhile is lost.
+func NormalizeLogFile(configDir string, check bool) (NormalizeResult, error) {
+	logPath := filepath.Join(configDir, "log")
+	unlock, err := lockLog(configDir)
+	if err != nil {
+		return NormalizeResult{}, err
+	}
+	defer unlock()
+	info, err := os.Stat(logPath)
+	if err != nil {
+		return NormalizeResult{}, fmt.Errorf("cannot read log: %w", err)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		return NormalizeResult{}, fmt.Errorf("cannot read log: %w", err)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return NormalizeResult{}, errors.New("the log is empty, there's nothing to normalize")
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if version := logVersion(lines); version > LogVersion {
+		return NormalizeResult{}, newerLogError(version)
+	}
+	result, err := NormalizeLog(lines)
+	// A last line without its newline gains one
+	result.Changed = result.Changed || !strings.HasSuffix(string(data), "\n")
+	if err != nil || check || !result.Changed {
+		return result, err
+	}
+
+	// The backup is as private as the log, even over an older one
+	backup := filepath.Join(configDir, "log.bak")
+	if err := replaceFile(backup, data, info.Mode().Perm()); err != nil {
+		return result, fmt.Errorf("cannot back up log before normalizing it: %w", err)
+	}
+	if err := os.Chmod(backup, info.Mode().Perm()); err != nil {
+		return result, fmt.Errorf("cannot back up log before normalizing it: %w", err)
+	}
+	if err := replaceFile(logPath, []byte(strings.Join(result.Lines, "\n")+"\n"), 0644); err != nil {
+		return result, fmt.Errorf("cannot normalize log: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+// PlansFile is the sidecar file in the config directory holding the weekly
+// plans made with `harsh plan set`
+const PlansFile = "plans"
+
+// FirstWeekday is the day weeks start on, Monday unless the week_start
+// setting says otherwise
+var FirstWeekday = time.Monday
+
+// WeekStart returns the FirstWeekday starting the week d falls in
+func WeekStart(d civil.Date) civil.Date {
+	return d.AddDays(-((int(d.Weekday()) - int(FirstWeekday) + 7) % 7))
+}
+
+// PlanTarget is how many times a habit is meant to be done in a planned week
+type PlanTarget struct {
+	Habit string
+	Count int
+}
+
+// Plan is the intended count of each planned habit for the week starting Week
+type Plan struct {
+	Week    civil.Date
+	Targets []PlanTarget
+}
+
+// Count returns how many times habit is planned for the week, and false if it isn't
+func (p Plan) Count(habit string) (int, bool) {
+	i := slices.IndexFunc(p.Targets, func(target PlanTarget) bool { return target.Habit == habit })
+	if i == -1 {
+		return 0, false
+	}
+	return p.Targets[i].Count, true
+}
+
+// Plans are the weekly plans in the sidecar file, oldest week first
+type Plans []Plan
+
+// LoadPlans reads the plans from the sidecar file in configDir. Each starts
+// with a `week = <first day>` line followed by `<habit> = <count>` lines. A
+// missing file yields none.
+func LoadPlans(configDir string) (Plans, error) {
+	path := filepath.Join(configDir, PlansFile)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot open plans file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var plans Plans
+	scanner := bufio.NewScanner(file)
+	lineCount := 0
+	for scanner.Scan() {
+		lineCount++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		// Habit names can contain "=", so the count is after the last one
+		i := strings.LastIndex(line, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("%s line %d: expected key = value", path, lineCount)
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if key == "week" {
+			week, err := civil.ParseDate(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", path, lineCount, err)
+			}
+			plans = append(plans, Plan{Week: week})
+			continue
+		}
+		if len(plans) == 0 {
+			return nil, fmt.Errorf("%s line %d: %q comes before any week", path, lineCount, key)
+		}
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("%s line %d: %s's count must be a whole number, got %q", path, lineCount, key, value)
+		}
+		plans[len(plans)-1].Targets = append(plans[len(plans)-1].Targets, PlanTarget{Habit: key, Count: count})
+	}
+	return plans, scanner.Err()
+}
+
+// For returns the plan for the week d falls in, and false if there isn't one
+func (p Plans) For(d civil.Date) (Plan, bool) {
+	week := WeekStart(d)
+	i := slices.IndexFunc(p, func(plan Plan) bool { return plan.Week == week })
+	if i == -1 {
+		return Plan{}, false
+	}
+	return p[i], true
+}
+
+// Set adds plan, replacing any plan already made for its week
+func (p Plans) Set(plan Plan) Plans {
+	plans := slices.DeleteFunc(slices.Clone(p), func(existing Plan) bool { return existing.Week == plan.Week })
+	plans = append(plans, plan)
+	sort.Slice(plans, func(i, j int) bool { return plans[i].Week.Before(plans[j].Week) })
+	return plans
+}
+
+// Save writes the plans to the sidecar file in configDir
+func (p Plans) Save(configDir string) error {
+	var b strings.Builder
+	b.WriteString("# weekly plans made with `harsh plan set`\n")
+	for _, plan := range p {
+		fmt.Fprintf(&b, "\nweek = %s\n", plan.Week)
+		for _, target := range plan.Targets {
+			fmt.Fprintf(&b, "%s = %d\n", target.Habit, target.Count)
+		}
+	}
+	path := filepath.Join(configDir, PlansFile)
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("cannot write plans file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+)
+
+// PrivateDir makes sure dir is a directory only the current user can get
+// into, creating it if need be. Directories at known paths in shared places
+// like the temporary directory can be made by someone else first, to read or
+// plant what harsh keeps there, so one already there must be the current
+// user's and closed to everyone else.
+func PrivateDir(dir string) error {
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+	if !info.IsDir() || !ownedByCurrentUser(info) || !closedToOthers(info) {
+		return fmt.Errorf("%s isn't a private directory of yours, remove it to carry on", dir)
+	}
+	return nil
+}
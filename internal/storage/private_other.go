@@ -0,0 +1,20 @@
+//go:build !(linux || darwin || freebsd || openbsd || netbsd || dragonfly)
+
+package storage
+
+import "io/fs"
+
+// ownedByCurrentUser is always true where files have no owner harsh can read
+func ownedByCurrentUser(info fs.FileInfo) bool {
+	return true
+}
+
+// closedToOthers is always true where permissions aren't Unix modes
+func closedToOthers(info fs.FileInfo) bool {
+	return true
+}
+
+// sameDevice is always true where files have no device harsh can read
+func sameDevice(a fs.FileInfo, b fs.FileInfo) bool {
+	return true
+}
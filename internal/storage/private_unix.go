@@ -0,0 +1,27 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+
+package storage
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// ownedByCurrentUser reports whether the current user owns the file info describes
+func ownedByCurrentUser(info fs.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Getuid()
+}
+
+// closedToOthers reports whether only the file's owner has any permissions on it
+func closedToOthers(info fs.FileInfo) bool {
+	return info.Mode().Perm()&0077 == 0
+}
+
+// sameDevice reports whether two files are on the same filesystem
+func sameDevice(a fs.FileInfo, b fs.FileInfo) bool {
+	statA, okA := a.Sys().(*syscall.Stat_t)
+	statB, okB := b.Sys().(*syscall.Stat_t)
+	return okA && okB && statA.Dev == statB.Dev
+}
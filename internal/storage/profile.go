@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ProfilesDir is the folder under the config directory holding one
+// subdirectory (with its own habits and log files) per profile
+const ProfilesDir = "profiles"
+
+// Profile is a named set of habits and log entries, eg. one per household member
+type Profile struct {
+	Name   string
+	Habits []*Habit
+	Log    *Log
+}
+
+// ProfileDir returns the directory holding the named profile's files
+func ProfileDir(configDir string, name string) string {
+	return filepath.Join(configDir, ProfilesDir, name)
+}
+
+// ListProfiles returns the names of all profiles found under the config directory
+func ListProfiles(configDir string) ([]string, error) {
+	dirEntries, err := os.ReadDir(filepath.Join(configDir, ProfilesDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot read profiles directory: %w", err)
+	}
+	var names []string
+	for _, entry := range dirEntries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// LoadProfile loads the habits and log of the named profile
+func LoadProfile(configDir string, name string) (*Profile, error) {
+	dir := ProfileDir(configDir, name)
+	for _, file := range []string{"habits", "log"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			return nil, fmt.Errorf("profile %q has no %s file at %s", name, file, dir)
+		}
+	}
+	habits, _, err := ReadHabitsConfig(dir)
+	if err != nil {
+		return nil, fmt.Errorf("profile %q: %w", name, err)
+	}
+	log, err := ReadLog(dir)
+	if err != nil {
+		return nil, fmt.Errorf("profile %q: %w", name, err)
+	}
+	return &Profile{Name: name, Habits: habits, Log: log}, nil
+}
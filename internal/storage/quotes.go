@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/civil"
+)
+
+// QuotesFile is the optional file under the config directory holding
+// motivational quotes, one per line
+const QuotesFile = "quotes"
+
+// Quotes holds motivational quotes for all habits, or scoped to a heading
+// ("! Heading" sections) or a single habit ("@ Habit" sections)
+type Quotes struct {
+	General  []string
+	Headings map[string][]string
+	Habits   map[string][]string
+}
+
+// LoadQuotes reads the quotes file in the config directory, returning nil
+// when there isn't one
+func LoadQuotes(configDir string) (*Quotes, error) {
+	if configDir == "" {
+		return nil, nil
+	}
+	fileName := filepath.Join(configDir, QuotesFile)
+	f, err := os.Open(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot open quotes file %s: %w", fileName, err)
+	}
+	defer f.Close()
+
+	quotes, err := ParseQuotes(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read quotes file %s: %w", fileName, err)
+	}
+	return quotes, nil
+}
+
+// ParseQuotes parses quotes from any reader in quotes file format
+func ParseQuotes(r io.Reader) (*Quotes, error) {
+	quotes := &Quotes{Headings: map[string][]string{}, Habits: map[string][]string{}}
+	// section is the map and key the following quotes belong to, nil for general
+	var section map[string][]string
+	var key string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#':
+			continue
+		case strings.HasPrefix(line, "! "):
+			section, key = quotes.Headings, strings.TrimSpace(line[2:])
+		case strings.HasPrefix(line, "@ "):
+			section, key = quotes.Habits, strings.TrimSpace(line[2:])
+		case section == nil:
+			quotes.General = append(quotes.General, line)
+		default:
+			section[key] = append(section[key], line)
+		}
+	}
+	return quotes, scanner.Err()
+}
+
+// Pick chooses the quote of the day for the given habits. Quotes for habits
+// done on day come first, then quotes for their headings, then general ones,
+// and the same day always picks the same quote.
+func (q *Quotes) Pick(habits []*Habit, entries History, day civil.Date) string {
+	if q == nil {
+		return ""
+	}
+	var forHabits, forHeadings []string
+	seenHeadings := map[string]bool{}
+	for _, habit := range habits {
+		if outcome, ok := entries.Outcome(DailyHabit{Day: day, Habit: habit.Name}); !ok || outcome.Result != "y" {
+			continue
+		}
+		forHabits = append(forHabits, q.Habits[habit.Name]...)
+		if !seenHeadings[habit.Heading] {
+			seenHeadings[habit.Heading] = true
+			forHeadings = append(forHeadings, q.Headings[habit.Heading]...)
+		}
+	}
+
+	for _, pool := range [][]string{forHabits, forHeadings, q.General} {
+		if len(pool) > 0 {
+			i := day.DaysSince(civil.Date{Year: 1970, Month: 1, Day: 1}) % len(pool)
+			return pool[(i+len(pool))%len(pool)]
+		}
+	}
+	return ""
+}
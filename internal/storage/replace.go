@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// replaceFile writes data over the file at path without it ever being half
+// written: data goes to a temporary file beside it, synced to disk, which is
+// then renamed over it. The file keeps its permissions, or gets mode when
+// it's new.
+func replaceFile(path string, data []byte, mode os.FileMode) error {
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -1,6 +1,13 @@
 package storage
 
-import "cloud.google.com/go/civil"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"cloud.google.com/go/civil"
+)
 
 // Repository defines the interface for data access operations
 type Repository interface {
@@ -10,12 +17,24 @@ type Repository interface {
 	// Log operations
 	LoadEntries() (*Log, error)
 	WriteEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error
+	// UpdateEntry changes an entry already logged in place, failing with
+	// ErrNoEntry when there isn't one
+	UpdateEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error
 
 	// Configuration
 	GetConfigDir() string
 	InitializeConfig() error
 }
 
+// RangeRepository is a Repository that can read a range of days' entries
+// without loading the rest, as SQLiteRepository can
+type RangeRepository interface {
+	Repository
+	// EntriesBetween returns the entries from day from to day to inclusive,
+	// for habit or every habit when it's empty
+	EntriesBetween(habit string, from civil.Date, to civil.Date) (Entries, error)
+}
+
 // FileRepository implements Repository using file-based storage
 type FileRepository struct {
 	configDir string
@@ -29,13 +48,21 @@ func NewFileRepository() *FileRepository {
 
 // LoadHabits loads habits from the config file
 func (r *FileRepository) LoadHabits() ([]*Habit, int, error) {
-	habits, maxLength := LoadHabitsConfig(r.configDir)
-	return habits, maxLength, nil
+	return ReadHabitsConfig(r.configDir)
 }
 
-// LoadEntries loads log entries from the log file
+// LoadEntries loads log entries from the log file. Older logs are read as
+// they are and only upgraded when something is written.
 func (r *FileRepository) LoadEntries() (*Log, error) {
-	log := LoadLog(r.configDir)
+	log, err := ReadLog(r.configDir)
+	if err != nil {
+		return nil, err
+	}
+	if log.Version > LogVersion {
+		err := newerLogError(log.Version)
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		log.Warnings = append(log.Warnings, err.Error())
+	}
 	return log, nil
 }
 
@@ -44,6 +71,11 @@ func (r *FileRepository) WriteEntry(d civil.Date, habit string, result string, c
 	return WriteHabitLog(r.configDir, d, habit, result, comment, amount, header)
 }
 
+// UpdateEntry rewrites a log entry's line in the log file
+func (r *FileRepository) UpdateEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	return UpdateHabitLog(r.configDir, d, habit, result, comment, amount, header)
+}
+
 // GetConfigDir returns the configuration directory
 func (r *FileRepository) GetConfigDir() string {
 	return r.configDir
@@ -54,3 +86,45 @@ func (r *FileRepository) InitializeConfig() error {
 	// This is handled by FindConfigFiles() which calls welcome() if needed
 	return nil
 }
+
+// ReaderRepository implements Repository over arbitrary readers (eg. stdin),
+// for one-off analysis that never touches the config directory
+type ReaderRepository struct {
+	habits io.Reader
+	log    io.Reader
+}
+
+// NewReaderRepository creates a read-only repository from habits and log readers
+func NewReaderRepository(habits io.Reader, log io.Reader) *ReaderRepository {
+	return &ReaderRepository{habits: habits, log: log}
+}
+
+// LoadHabits parses habits from the habits reader
+func (r *ReaderRepository) LoadHabits() ([]*Habit, int, error) {
+	return readHabitsConfig(r.habits, nil)
+}
+
+// LoadEntries parses log entries from the log reader
+func (r *ReaderRepository) LoadEntries() (*Log, error) {
+	return ParseLog(r.log), nil
+}
+
+// WriteEntry always fails as reader-backed data is read-only
+func (r *ReaderRepository) WriteEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	return errors.New("cannot write entries when reading habits or log from a stream")
+}
+
+// UpdateEntry always fails as reader-backed data is read-only
+func (r *ReaderRepository) UpdateEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	return errors.New("cannot edit entries when reading habits or log from a stream")
+}
+
+// GetConfigDir returns an empty path as reader-backed data has no config directory
+func (r *ReaderRepository) GetConfigDir() string {
+	return ""
+}
+
+// InitializeConfig is a no-op for reader-backed data
+func (r *ReaderRepository) InitializeConfig() error {
+	return nil
+}
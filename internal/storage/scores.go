@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/civil"
+)
+
+// ScoresFile is the sidecar file in the config directory caching daily
+// scores, so the log footer's averages don't rescore a year of days on
+// every run
+const ScoresFile = "scores"
+
+// dataFiles are the config directory files habits, entries, and the scores
+// worked out from them are read from. Changing any of them makes cached
+// scores stale.
+var dataFiles = []string{"habits", "log", SQLiteFile, SettingsFile, FrequenciesFile, RetiredHabitsFile}
+
+// ScoreCache is daily scores as worked out from the habits, log and settings
+// that gave Fingerprint
+type ScoreCache struct {
+	Fingerprint string
+	Scores      map[civil.Date]float64
+	// saved is how many scores were read or last written, so Save can tell
+	// whether there's anything new
+	saved int
+}
+
+// DataFingerprint identifies the state of the habits, log, and settings in
+// configDir: the files they're read from, as of their last change, and
+// extra, eg. settings given on the command line
+func DataFingerprint(configDir string, extra ...string) string {
+	hash := sha256.New()
+	names := append([]string{}, dataFiles...)
+	if archived, err := filepath.Glob(filepath.Join(configDir, ArchivedLogPattern)); err == nil {
+		for _, path := range archived {
+			names = append(names, filepath.Base(path))
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if info, err := os.Stat(filepath.Join(configDir, name)); err == nil {
+			fmt.Fprintf(hash, "%s %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	for _, value := range extra {
+		fmt.Fprintf(hash, "%s\n", value)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// LoadScoreCache reads the scores cached in configDir, keeping them only if
+// they were worked out for fingerprint. A missing or stale cache yields an
+// empty one.
+func LoadScoreCache(configDir string, fingerprint string) (*ScoreCache, error) {
+	cache := &ScoreCache{Fingerprint: fingerprint, Scores: map[civil.Date]float64{}}
+	path := filepath.Join(configDir, ScoresFile)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+		return cache, fmt.Errorf("cannot open scores file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineCount := 0
+	matched := false
+	for scanner.Scan() {
+		lineCount++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		key, value, _ := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "fingerprint" {
+			matched = value == fingerprint
+			continue
+		}
+		if !matched {
+			// Worked out from habits or a log since changed
+			break
+		}
+		day, err := civil.ParseDate(key)
+		if err != nil {
+			return &ScoreCache{Fingerprint: fingerprint, Scores: map[civil.Date]float64{}}, fmt.Errorf("%s line %d: %w", path, lineCount, err)
+		}
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return &ScoreCache{Fingerprint: fingerprint, Scores: map[civil.Date]float64{}}, fmt.Errorf("%s line %d: %w", path, lineCount, err)
+		}
+		cache.Scores[day] = score
+	}
+	if !matched {
+		cache.Scores = map[civil.Date]float64{}
+	}
+	cache.saved = len(cache.Scores)
+	return cache, scanner.Err()
+}
+
+// Save writes the cached scores to the sidecar file in configDir, unless
+// nothing's been added since they were read
+func (cache *ScoreCache) Save(configDir string) error {
+	if len(cache.Scores) == cache.saved {
+		return nil
+	}
+	days := make([]civil.Date, 0, len(cache.Scores))
+	for day := range cache.Scores {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# harsh daily scores, worked out again when your habits, log, or settings change\nfingerprint = %s\n", cache.Fingerprint)
+	for _, day := range days {
+		fmt.Fprintf(&b, "%s = %s\n", day, strconv.FormatFloat(cache.Scores[day], 'f', -1, 64))
+	}
+	path := filepath.Join(configDir, ScoresFile)
+	if err := replaceFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("cannot write scores file %s: %w", path, err)
+	}
+	cache.saved = len(cache.Scores)
+	return nil
+}
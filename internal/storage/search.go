@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"strings"
+)
+
+// CommentIndex is a trigram index over entry comments, so searching stays
+// fast on logs with tens of thousands of commented entries
+type CommentIndex struct {
+	comments map[DailyHabit]string
+	trigrams map[string]map[DailyHabit]struct{}
+}
+
+// NewCommentIndex indexes the comments of entries
+func NewCommentIndex(entries Entries) *CommentIndex {
+	index := &CommentIndex{
+		comments: make(map[DailyHabit]string),
+		trigrams: make(map[string]map[DailyHabit]struct{}),
+	}
+	for key, outcome := range entries {
+		index.Add(key, outcome.Comment)
+	}
+	return index
+}
+
+// Add indexes an entry's comment, replacing whatever was indexed for it before
+func (ix *CommentIndex) Add(key DailyHabit, comment string) {
+	if old, ok := ix.comments[key]; ok {
+		for _, trigram := range trigramsOf(old) {
+			delete(ix.trigrams[trigram], key)
+		}
+		delete(ix.comments, key)
+	}
+	comment = strings.ToLower(comment)
+	if comment == "" {
+		return
+	}
+	ix.comments[key] = comment
+	for _, trigram := range trigramsOf(comment) {
+		if ix.trigrams[trigram] == nil {
+			ix.trigrams[trigram] = make(map[DailyHabit]struct{})
+		}
+		ix.trigrams[trigram][key] = struct{}{}
+	}
+}
+
+// Search returns the entries whose comments contain every word of text,
+// ignoring case, ordered by date then habit
+func (ix *CommentIndex) Search(text string) []DailyHabit {
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) == 0 {
+		return nil
+	}
+
+	// Narrow down to the entries having every trigram of the words, then
+	// check the words really appear since trigrams can match out of order
+	var candidates map[DailyHabit]struct{}
+	for _, word := range words {
+		for _, trigram := range trigramsOf(word) {
+			posting := ix.trigrams[trigram]
+			if candidates == nil || len(posting) < len(candidates) {
+				candidates = intersect(posting, candidates)
+			} else {
+				candidates = intersect(candidates, posting)
+			}
+			if len(candidates) == 0 {
+				return nil
+			}
+		}
+	}
+
+	matches := Entries{}
+	check := func(key DailyHabit) {
+		comment := ix.comments[key]
+		for _, word := range words {
+			if !strings.Contains(comment, word) {
+				return
+			}
+		}
+		matches[key] = Outcome{}
+	}
+	if candidates == nil {
+		// Only words shorter than a trigram, so every comment is a candidate
+		for key := range ix.comments {
+			check(key)
+		}
+	} else {
+		for key := range candidates {
+			check(key)
+		}
+	}
+	return matches.SortedKeys()
+}
+
+// trigramsOf returns the three-rune substrings of text
+func trigramsOf(text string) []string {
+	runes := []rune(text)
+	var trigrams []string
+	for i := 0; i+3 <= len(runes); i++ {
+		trigrams = append(trigrams, string(runes[i:i+3]))
+	}
+	return trigrams
+}
+
+// intersect returns the keys of small that are also in large, or all of
+// small when large is nil
+func intersect(small, large map[DailyHabit]struct{}) map[DailyHabit]struct{} {
+	result := make(map[DailyHabit]struct{}, len(small))
+	for key := range small {
+		if _, ok := large[key]; ok || large == nil {
+			result[key] = struct{}{}
+		}
+	}
+	return result
+}
@@ -0,0 +1,396 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SettingsFile is the name of the optional config file in the config directory.
+// It uses a flat subset of TOML: one `key = value` pair per line and # comments.
+const SettingsFile = "config.toml"
+
+// SettingKind is the type of value a setting holds
+type SettingKind int
+
+const (
+	SettingString SettingKind = iota
+	SettingInt
+	SettingBool
+	// SettingTime is a time of day, HH:MM on a 24 hour clock
+	SettingTime
+)
+
+// SettingDefinition describes a known config key, its default, and valid values
+type SettingDefinition struct {
+	Key         string
+	Kind        SettingKind
+	Default     string
+	Description string
+	// Choices restricts string settings to a fixed set of values
+	Choices []string
+	// Min and Max bound int settings
+	Min, Max int
+}
+
+// SettingDefinitions lists every key the config file understands
+var SettingDefinitions = []SettingDefinition{
+	{Key: "accountability_habits", Kind: SettingString, Default: "",
+		Description: "comma separated habits in harsh accountability check-ins (empty for all)"},
+	{Key: "accountability_to", Kind: SettingString, Default: "",
+		Description: "email address or Matrix room ID (!room:server) harsh accountability sends check-ins to"},
+	{Key: "autofill_at", Kind: SettingTime, Default: "23:30",
+		Description: "time of day harsh daemon fills in unanswered habits"},
+	{Key: "autofill_notify", Kind: SettingBool, Default: "false",
+		Description: "harsh daemon sends a notification about unanswered habits instead of logging them as not done"},
+	{Key: "backend", Kind: SettingString, Default: "file", Choices: []string{"file", "sqlite"},
+		Description: `where entries and notes are kept, "file" (the log) or "sqlite" (log.db, move with harsh backend)`},
+	{Key: "break_reasons", Kind: SettingBool, Default: "false",
+		Description: "ask why when an answer breaks a streak (see harsh stats --reasons)"},
+	{Key: "countback", Kind: SettingInt, Default: "0", Min: 0, Max: 3650,
+		Description: "days shown in graphs (0 fits the terminal width)"},
+	{Key: "days_since", Kind: SettingBool, Default: "false",
+		Description: "show how many days since each habit was last done in harsh log and todo"},
+	{Key: "empty_days", Kind: SettingString, Default: "na", Choices: []string{"na", "100", "skip"},
+		Description: `score of days when every habit due was skipped or only tracked, "na" (shown as – and left out of averages), "100", or "skip" (left out and blank in the sparkline)`},
+	{Key: "git_sync", Kind: SettingBool, Default: "false",
+		Description: "pull before harsh ask and commit and push after logging, once harsh sync init has set up the config directory"},
+	{Key: "graph_summaries", Kind: SettingBool, Default: "false",
+		Description: "follow each graph with a line like \"Run: 5 of last 7 days\", for screen readers"},
+	{Key: "graph_symbols", Kind: SettingString, Default: "blocks", Choices: []string{"blocks", "letters"},
+		Description: `graph glyphs, "blocks" or "letters" (Y done, N not, S skipped, · unlogged) for screen readers and braille displays`},
+	{Key: "lock_after_days", Kind: SettingInt, Default: "0", Min: 0, Max: 36500,
+		Description: "entries older than this many days need --force to change (0 never locks)"},
+	{Key: "matrix_homeserver", Kind: SettingString, Default: "https://matrix.org",
+		Description: "Matrix homeserver check-ins are posted through, with the HARSH_MATRIX_TOKEN access token"},
+	{Key: "max_line_length", Kind: SettingInt, Default: "0", Min: 0, Max: 1 << 30,
+		Description: "log lines longer than this many bytes are skipped with a warning rather than read (0 reads lines of any length)"},
+	{Key: "mqtt_broker", Kind: SettingString, Default: "",
+		Description: "MQTT broker (host:port) harsh publishes its daily state to after logging, for Home Assistant (empty never publishes)"},
+	{Key: "mqtt_topic", Kind: SettingString, Default: "harsh",
+		Description: "topic harsh publishes its daily state under, eg. harsh/score"},
+	{Key: "mqtt_user", Kind: SettingString, Default: "",
+		Description: "MQTT broker login, with the password in HARSH_MQTT_PASSWORD"},
+	{Key: "name_width", Kind: SettingInt, Default: "40", Min: 0, Max: 500,
+		Description: "longest habit name shown before truncating with … (0 never truncates)"},
+	{Key: "prorate_first_window", Kind: SettingBool, Default: "false",
+		Description: "judge a habit's first, partial interval against a pro-rated target, eg. 2 of 3 days into 3/7 (changes past scores)"},
+	{Key: "review_answers", Kind: SettingBool, Default: "false",
+		Description: "hold harsh ask's answers until the end of the session to amend them before anything is written"},
+	{Key: "rollover_hour", Kind: SettingInt, Default: "0", Min: 0, Max: 23,
+		Description: "hour a new day starts, eg. 4 so answers before 4am go to the day before"},
+	{Key: "score_goal", Kind: SettingInt, Default: "0", Min: 0, Max: 100,
+		Description: "daily score percentage to aim for, coloring scores against it (0 for no goal)"},
+	{Key: "sign_entries", Kind: SettingBool, Default: "false",
+		Description: "sign each entry written so harsh verify can detect tampering, with the key in HARSH_SIGNING_KEY or signing.key"},
+	{Key: "skips", Kind: SettingString, Default: "neutral", Choices: []string{"neutral", "positive", "breaking"},
+		Description: `what skips mean, "neutral" (left out of rates and streaks), "positive" (counted as done) or "breaking" (left out of rates but ending streaks)`},
+	{Key: "smtp_from", Kind: SettingString, Default: "",
+		Description: "sender address of emailed check-ins (smtp_user when empty)"},
+	{Key: "smtp_host", Kind: SettingString, Default: "",
+		Description: "mail server emailed check-ins are sent through"},
+	{Key: "smtp_port", Kind: SettingInt, Default: "587", Min: 1, Max: 65535,
+		Description: "port of the mail server"},
+	{Key: "smtp_user", Kind: SettingString, Default: "",
+		Description: "mail server login, with the password in HARSH_SMTP_PASSWORD"},
+	{Key: "stale_after_days", Kind: SettingInt, Default: "14", Min: 0, Max: 3650,
+		Description: "todo flags habits with no entries for longer than this many days (0 never flags)"},
+	{Key: "theme", Kind: SettingString, Default: "default", Choices: []string{"default", "mono"},
+		Description: `output colors, "default" or "mono" for no colors unless --color is given`},
+	{Key: "week_start", Kind: SettingString, Default: "monday",
+		Choices:     []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"},
+		Description: "day weeks start on, for plans and calendars"},
+	{Key: "window_progress", Kind: SettingBool, Default: "true",
+		Description: "show how often interval habits have been done in their current window, eg. \"2/3 last 7 days\", in harsh log and todo"},
+	{Key: "write_times", Kind: SettingBool, Default: "false",
+		Description: "record when each entry is written, in the writes file, for harsh meta"},
+	{Key: "xp", Kind: SettingBool, Default: "false",
+		Description: "show XP gained after ask (see harsh level)"},
+}
+
+// AliasPrefix starts config keys defining command aliases, eg.
+// `alias.weekly = "log stats --age"` makes `harsh weekly` run `harsh log stats --age`
+const AliasPrefix = "alias."
+
+// Settings holds the values set in the config file
+type Settings struct {
+	values  map[string]string
+	aliases map[string]string
+}
+
+// NewSettings returns settings with every key at its default
+func NewSettings() *Settings {
+	return &Settings{values: map[string]string{}, aliases: map[string]string{}}
+}
+
+// Aliases returns the command aliases set in the config file, by name
+func (s *Settings) Aliases() map[string]string {
+	return s.aliases
+}
+
+// setAlias validates and sets the command an alias expands to
+func (s *Settings) setAlias(name string, expansion string) error {
+	if name == "" || strings.ContainsAny(name, " \t") || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("alias name %q must be a single word", name)
+	}
+	if strings.TrimSpace(expansion) == "" {
+		return fmt.Errorf("alias %s needs a command to run", name)
+	}
+	s.aliases[name] = expansion
+	return nil
+}
+
+// SettingDefinitionFor returns the definition of a config key
+func SettingDefinitionFor(key string) (SettingDefinition, error) {
+	for _, def := range SettingDefinitions {
+		if def.Key == key {
+			return def, nil
+		}
+	}
+	keys := make([]string, len(SettingDefinitions))
+	for i, def := range SettingDefinitions {
+		keys[i] = def.Key
+	}
+	sort.Strings(keys)
+	return SettingDefinition{}, fmt.Errorf("unknown setting %q (known settings: %s)", key, strings.Join(keys, ", "))
+}
+
+// Validate checks value is acceptable for the setting
+func (def SettingDefinition) Validate(value string) error {
+	switch def.Kind {
+	case SettingInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a whole number, got %q", def.Key, value)
+		}
+		if n < def.Min || n > def.Max {
+			return fmt.Errorf("%s must be between %d and %d, got %d", def.Key, def.Min, def.Max, n)
+		}
+	case SettingBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s must be true or false, got %q", def.Key, value)
+		}
+	case SettingTime:
+		if _, err := time.Parse("15:04", value); err != nil {
+			return fmt.Errorf("%s must be a time of day like 21:30, got %q", def.Key, value)
+		}
+	case SettingString:
+		if len(def.Choices) > 0 {
+			for _, choice := range def.Choices {
+				if value == choice {
+					return nil
+				}
+			}
+			return fmt.Errorf("%s must be one of %s, got %q", def.Key, strings.Join(def.Choices, ", "), value)
+		}
+	}
+	return nil
+}
+
+// LoadSettings reads the config file in configDir. A missing file yields defaults.
+func LoadSettings(configDir string) (*Settings, error) {
+	settings := NewSettings()
+	if configDir == "" {
+		return settings, nil
+	}
+	path := filepath.Join(configDir, SettingsFile)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return settings, nil
+		}
+		return settings, fmt.Errorf("cannot open config file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineCount := 0
+	for scanner.Scan() {
+		lineCount++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return settings, fmt.Errorf("%s line %d: expected key = value", path, lineCount)
+		}
+		key = strings.TrimSpace(key)
+		value = unquoteSetting(strings.TrimSpace(value))
+		if err := settings.Set(key, value); err != nil {
+			return settings, fmt.Errorf("%s line %d: %w", path, lineCount, err)
+		}
+	}
+	return settings, scanner.Err()
+}
+
+// settingsComment starts a config file harsh creates
+const settingsComment = "# harsh config file, edit with `harsh config set <key> <value>`\n"
+
+// Save writes keys to the config file in configDir, or every setting set
+// when none are given. Each key's line is edited in place, or added at the
+// end, and removed when the key is unset; comments, other keys, and lines
+// harsh doesn't understand are left as they are.
+func (s *Settings) Save(configDir string, keys ...string) error {
+	if len(keys) == 0 {
+		for _, def := range SettingDefinitions {
+			if _, ok := s.values[def.Key]; ok {
+				keys = append(keys, def.Key)
+			}
+		}
+		names := make([]string, 0, len(s.aliases))
+		for name := range s.aliases {
+			names = append(names, AliasPrefix+name)
+		}
+		sort.Strings(names)
+		keys = append(keys, names...)
+	}
+
+	path := filepath.Join(configDir, SettingsFile)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot read config file %s: %w", path, err)
+	}
+	text := string(data)
+	if len(data) == 0 {
+		text = settingsComment
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+
+	for _, key := range keys {
+		line, set := s.settingLine(key)
+		kept := lines[:0:0]
+		for _, existing := range lines {
+			trimmed := strings.TrimSpace(existing)
+			name, _, found := strings.Cut(trimmed, "=")
+			if !found || trimmed[0] == '#' || strings.TrimSpace(name) != key {
+				kept = append(kept, existing)
+				continue
+			}
+			// The first line for the key takes its value, and any later
+			// ones, which would override it, go
+			if set {
+				kept = append(kept, line)
+				set = false
+			}
+		}
+		if set {
+			kept = append(kept, line)
+		}
+		lines = kept
+	}
+
+	if err := replaceFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("cannot write config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// settingLine returns key's line in the config file, and whether it's set
+func (s *Settings) settingLine(key string) (string, bool) {
+	if name, ok := strings.CutPrefix(key, AliasPrefix); ok {
+		expansion, ok := s.aliases[name]
+		return fmt.Sprintf("%s = %s", key, strconv.Quote(expansion)), ok
+	}
+	value, ok := s.values[key]
+	if def, err := SettingDefinitionFor(key); err == nil && def.Kind == SettingString {
+		value = strconv.Quote(value)
+	}
+	return fmt.Sprintf("%s = %s", key, value), ok
+}
+
+// Get returns the value of a setting, or its default when unset
+func (s *Settings) Get(key string) (string, error) {
+	if name, ok := strings.CutPrefix(key, AliasPrefix); ok {
+		expansion, ok := s.aliases[name]
+		if !ok {
+			return "", fmt.Errorf("no alias called %q", name)
+		}
+		return expansion, nil
+	}
+	def, err := SettingDefinitionFor(key)
+	if err != nil {
+		return "", err
+	}
+	if value, ok := s.values[key]; ok {
+		return value, nil
+	}
+	return def.Default, nil
+}
+
+// IsSet reports whether a setting has been given a value in the config file
+func (s *Settings) IsSet(key string) bool {
+	if name, ok := strings.CutPrefix(key, AliasPrefix); ok {
+		_, ok := s.aliases[name]
+		return ok
+	}
+	_, ok := s.values[key]
+	return ok
+}
+
+// Set validates and sets a setting's value
+func (s *Settings) Set(key string, value string) error {
+	if name, ok := strings.CutPrefix(key, AliasPrefix); ok {
+		return s.setAlias(name, value)
+	}
+	def, err := SettingDefinitionFor(key)
+	if err != nil {
+		return err
+	}
+	if err := def.Validate(value); err != nil {
+		return err
+	}
+	s.values[key] = value
+	return nil
+}
+
+// Unset returns a setting to its default
+func (s *Settings) Unset(key string) error {
+	if name, ok := strings.CutPrefix(key, AliasPrefix); ok {
+		if _, ok := s.aliases[name]; !ok {
+			return fmt.Errorf("no alias called %q", name)
+		}
+		delete(s.aliases, name)
+		return nil
+	}
+	if _, err := SettingDefinitionFor(key); err != nil {
+		return err
+	}
+	delete(s.values, key)
+	return nil
+}
+
+// String returns a setting's value, or its default
+func (s *Settings) String(key string) string {
+	value, _ := s.Get(key)
+	return value
+}
+
+// Int returns a setting's value as an int, or its default
+func (s *Settings) Int(key string) int {
+	n, _ := strconv.Atoi(s.String(key))
+	return n
+}
+
+// Bool returns a setting's value as a bool, or its default
+func (s *Settings) Bool(key string) bool {
+	b, _ := strconv.ParseBool(s.String(key))
+	return b
+}
+
+func unquoteSetting(value string) string {
+	if !strings.HasPrefix(value, `"`) {
+		// strip trailing comments from bare values
+		value, _, _ = strings.Cut(value, "#")
+		return strings.TrimSpace(value)
+	}
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	return value
+}
@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/civil"
+)
+
+// SignaturesFile is the sidecar file in the config directory holding an
+// HMAC of each signed log line, kept with the sign_entries setting on
+const SignaturesFile = "log.sig"
+
+// signaturesComment starts the signatures file
+const signaturesComment = "# HMAC-SHA256 of each signed log line in order, each chained to the one before, checked by harsh verify\n"
+
+// SigningKeyFile holds the key log lines are signed with when it isn't
+// given in HARSH_SIGNING_KEY
+const SigningKeyFile = "signing.key"
+
+// resyncWindow is how many signatures ahead VerifyLog looks for a line that
+// doesn't follow on from the one before, to pick the chain up again after
+// a line that was changed or removed
+const resyncWindow = 64
+
+// SignLine returns the hex HMAC-SHA256 of a log line under key, chained to
+// previous, the signature of the signed line before it or "" for the first,
+// so signed lines can't be reordered or removed, or others slipped in
+// between, without breaking the chain
+func SignLine(key []byte, previous string, line string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(previous + "\n" + line))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// LoadSigningKey reads the signing key file in configDir, creating one with
+// a random key, readable only by you, when there isn't one yet
+func LoadSigningKey(configDir string) ([]byte, error) {
+	path := filepath.Join(configDir, SigningKeyFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key := strings.TrimSpace(string(data))
+		if key == "" {
+			return nil, fmt.Errorf("signing key file %s is empty", path)
+		}
+		return []byte(key), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("cannot read signing key %s: %w", path, err)
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("cannot make a signing key: %w", err)
+	}
+	key := hex.EncodeToString(random)
+	if err := os.WriteFile(path, []byte(key+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("cannot write signing key %s: %w", path, err)
+	}
+	return []byte(key), nil
+}
+
+// LoadSignatures reads the signatures in configDir in the order their lines
+// were signed. A missing file yields none.
+func LoadSignatures(configDir string) ([]string, error) {
+	var signatures []string
+	path := filepath.Join(configDir, SignaturesFile)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return signatures, nil
+		}
+		return nil, fmt.Errorf("cannot open signatures file %s: %w", path, err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		signatures = append(signatures, line)
+	}
+	return signatures, scanner.Err()
+}
+
+// AppendSignatures signs lines just added to the end of the log in
+// configDir with key, chaining them onto the signatures already there
+func AppendSignatures(configDir string, key []byte, lines []string) error {
+	unlock, err := lockLog(configDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	signatures, err := LoadSignatures(configDir)
+	if err != nil {
+		return err
+	}
+	previous := ""
+	if len(signatures) > 0 {
+		previous = signatures[len(signatures)-1]
+	}
+
+	path := filepath.Join(configDir, SignaturesFile)
+	_, statErr := os.Stat(path)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open signatures file %s: %w", path, err)
+	}
+	var b strings.Builder
+	if os.IsNotExist(statErr) {
+		b.WriteString(signaturesComment)
+	}
+	for _, line := range lines {
+		previous = SignLine(key, previous, line)
+		b.WriteString(previous + "\n")
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write signatures file %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// Verification is how a log's lines compare with their signatures
+type Verification struct {
+	Signed int
+	// Unsigned lists the line numbers of entries and notes before the first
+	// signed one, written before signing was turned on
+	Unsigned []int
+	// Broken lists the line numbers of entries and notes after the first
+	// signed one that don't follow on from the signatures: added by hand,
+	// changed, or moved since
+	Broken []int
+	// Orphaned counts signatures whose line is gone or was changed
+	Orphaned int
+	// First is the line number of the first signed line, 0 when none is
+	First int
+}
+
+// Intact reports whether every line from the first signed one on is still
+// in the log as signed, in the order it was signed
+func (v Verification) Intact() bool {
+	return len(v.Broken) == 0 && v.Orphaned == 0
+}
+
+// VerifyLog follows the chain of signatures down the log's entry and note
+// lines. The header, comments, and blank lines aren't signed.
+func VerifyLog(lines []string, key []byte, signatures []string) Verification {
+	var v Verification
+	next := 0
+	for i, line := range lines {
+		if !signable(line, i) {
+			continue
+		}
+		// Usually the next signature, or one a little further on when
+		// lines before this one were changed or removed
+		found := -1
+		for j := next; j < min(len(signatures), next+resyncWindow); j++ {
+			previous := ""
+			if j > 0 {
+				previous = signatures[j-1]
+			}
+			if SignLine(key, previous, line) == signatures[j] {
+				found = j
+				break
+			}
+		}
+		switch {
+		case found >= 0:
+			v.Signed++
+			v.Orphaned += found - next
+			next = found + 1
+			if v.First == 0 {
+				v.First = i + 1
+			}
+		case v.First == 0:
+			v.Unsigned = append(v.Unsigned, i+1)
+		default:
+			v.Broken = append(v.Broken, i+1)
+		}
+	}
+	v.Orphaned += len(signatures) - next
+	return v
+}
+
+// signable reports whether the line at index i of the log gets signed
+func signable(line string, i int) bool {
+	if strings.TrimSpace(line) == "" || line[0] == '#' {
+		return false
+	}
+	if _, err := ParseHeader(line); err == nil && i == 0 {
+		return false
+	}
+	return true
+}
+
+// SignLog returns the chain of signatures for every entry and note line of
+// the log
+func SignLog(lines []string, key []byte) []string {
+	var signatures []string
+	previous := ""
+	for i, line := range lines {
+		if signable(line, i) {
+			previous = SignLine(key, previous, line)
+			signatures = append(signatures, previous)
+		}
+	}
+	return signatures
+}
+
+// WriteSignatures replaces the signatures file in configDir, vouching for
+// the log as it stands
+func WriteSignatures(configDir string, signatures []string) error {
+	path := filepath.Join(configDir, SignaturesFile)
+	var b strings.Builder
+	b.WriteString(signaturesComment)
+	for _, signature := range signatures {
+		b.WriteString(signature + "\n")
+	}
+	if err := replaceFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("cannot write signatures file %s: %w", path, err)
+	}
+	return nil
+}
+
+// SignedRepository wraps a Repository, signing each entry it writes so
+// harsh verify can detect later tampering or corruption
+type SignedRepository struct {
+	Repository
+	Key []byte
+}
+
+// NewSignedRepository wraps repository to sign entries with key
+func NewSignedRepository(repository Repository, key []byte) *SignedRepository {
+	return &SignedRepository{Repository: repository, Key: key}
+}
+
+// WriteEntry writes a log entry, then its signature. The entry stands even
+// if it can't be signed, and shows up as broken in harsh verify.
+func (r *SignedRepository) WriteEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	if err := r.Repository.WriteEntry(d, habit, result, comment, amount, header); err != nil {
+		return err
+	}
+	line := EntryLine(d, habit, result, comment, amount, header)
+	if err := AppendSignatures(r.GetConfigDir(), r.Key, []string{line}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return nil
+}
+
+// UpdateEntry changes a log entry in place, then signs the log again from
+// its first signed line on, so an edit through harsh isn't reported as
+// tampering. A log that didn't verify before the edit is left for harsh
+// verify to report, as re-signing it would vouch for what broke it.
+func (r *SignedRepository) UpdateEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	configDir := r.GetConfigDir()
+	// Checked first, as the edit rewrites the log
+	var before Verification
+	signatures, err := LoadSignatures(configDir)
+	if err == nil {
+		if lines, err := readLogLines(configDir); err == nil {
+			before = VerifyLog(lines, r.Key, signatures)
+		}
+	}
+	if err := r.Repository.UpdateEntry(d, habit, result, comment, amount, header); err != nil {
+		return err
+	}
+	if len(signatures) == 0 {
+		return nil
+	}
+	if before.First == 0 || !before.Intact() {
+		fmt.Fprintf(os.Stderr, "Warning: the log didn't verify before this edit, so it wasn't signed again, see harsh verify\n")
+		return nil
+	}
+	if err := r.resign(before.First); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return nil
+}
+
+// resign signs the log in the config directory again from line number first on
+func (r *SignedRepository) resign(first int) error {
+	configDir := r.GetConfigDir()
+	unlock, err := lockLog(configDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	lines, err := readLogLines(configDir)
+	if err != nil {
+		return err
+	}
+	var signatures []string
+	previous := ""
+	for i := first - 1; i < len(lines); i++ {
+		if signable(lines[i], i) {
+			previous = SignLine(r.Key, previous, lines[i])
+			signatures = append(signatures, previous)
+		}
+	}
+	return WriteSignatures(configDir, signatures)
+}
+
+// readLogLines reads the lines of the log in configDir
+func readLogLines(configDir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(configDir, "log"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read log: %w", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
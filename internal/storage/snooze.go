@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/civil"
+)
+
+// SnoozeFile is the sidecar file in the config directory listing the habits
+// snoozed for the day. It only holds for the day written in it.
+const SnoozeFile = "snoozed"
+
+// Snoozed lists the habits hidden from todos and ask on a day, without
+// anything being logged for them
+type Snoozed struct {
+	Day    civil.Date
+	Habits []string
+}
+
+// LoadSnoozed reads the habits snoozed for today from the sidecar file in
+// configDir. Snoozes from earlier days, or a missing file, yield none.
+func LoadSnoozed(configDir string, today civil.Date) (Snoozed, error) {
+	snoozed := Snoozed{Day: today}
+	path := filepath.Join(configDir, SnoozeFile)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return snoozed, nil
+		}
+		return snoozed, fmt.Errorf("cannot open snooze file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var day civil.Date
+	var habits []string
+	scanner := bufio.NewScanner(file)
+	lineCount := 0
+	for scanner.Scan() {
+		lineCount++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		key, value, _ := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "day":
+			day, err = civil.ParseDate(value)
+		case "habit":
+			habits = append(habits, value)
+		default:
+			err = fmt.Errorf("unknown key %q", key)
+		}
+		if err != nil {
+			return snoozed, fmt.Errorf("%s line %d: %w", path, lineCount, err)
+		}
+	}
+	if day == today {
+		snoozed.Habits = habits
+	}
+	return snoozed, scanner.Err()
+}
+
+// Has reports whether habit is snoozed
+func (s Snoozed) Has(habit string) bool {
+	return slices.Contains(s.Habits, habit)
+}
+
+// Add snoozes habit
+func (s *Snoozed) Add(habit string) {
+	if !s.Has(habit) {
+		s.Habits = append(s.Habits, habit)
+	}
+}
+
+// Remove wakes habit back up
+func (s *Snoozed) Remove(habit string) {
+	s.Habits = slices.DeleteFunc(s.Habits, func(name string) bool { return name == habit })
+}
+
+// Save writes the snoozed habits to the sidecar file in configDir, removing
+// the file once nothing is snoozed
+func (s Snoozed) Save(configDir string) error {
+	path := filepath.Join(configDir, SnoozeFile)
+	if len(s.Habits) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove snooze file %s: %w", path, err)
+		}
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# habits hidden from todo and ask by `harsh snooze`, for this day only\nday = %s\n", s.Day)
+	for _, habit := range s.Habits {
+		fmt.Fprintf(&b, "habit = %s\n", habit)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("cannot write snooze file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,364 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/civil"
+)
+
+// SQLiteFile is the database in the config directory holding entries and
+// notes with the sqlite backend
+const SQLiteFile = "log.db"
+
+// sqliteSchema sets up a new database. An entry per habit per day, like the
+// log reads, and an index for looking a habit's entries up by date.
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS entries (
+	day TEXT NOT NULL,
+	habit TEXT NOT NULL,
+	result TEXT NOT NULL,
+	comment TEXT NOT NULL DEFAULT '',
+	amount REAL,
+	PRIMARY KEY (day, habit)
+);
+CREATE INDEX IF NOT EXISTS entries_by_habit ON entries (habit, day);
+CREATE TABLE IF NOT EXISTS notes (
+	day TEXT NOT NULL,
+	text TEXT NOT NULL
+);
+`
+
+// SQLiteRepository implements Repository with entries and notes in a SQLite
+// database, for querying them with SQL and reading ranges of days. Graphs and
+// stats still load every entry, as they look back over a habit's whole
+// history. Habits stay in the habits file. It drives the sqlite3 command line
+// tool, so needs no driver built in.
+type SQLiteRepository struct {
+	configDir string
+}
+
+// NewSQLiteRepository creates a repository over the database in configDir
+func NewSQLiteRepository(configDir string) *SQLiteRepository {
+	return &SQLiteRepository{configDir: configDir}
+}
+
+// LoadHabits loads habits from the habits file
+func (r *SQLiteRepository) LoadHabits() ([]*Habit, int, error) {
+	return ReadHabitsConfig(r.configDir)
+}
+
+// LoadEntries loads every entry and note from the database, reading it
+// without writing, so a missing database reads as empty
+func (r *SQLiteRepository) LoadEntries() (*Log, error) {
+	log := &Log{Entries: Entries{}, Header: DefaultHeader, Notes: Notes{}, Version: LogVersion}
+	entries, err := r.EntriesBetween("", civil.Date{}, civil.Date{})
+	if err != nil {
+		return log, err
+	}
+	log.Entries = entries
+
+	notes, err := r.query("SELECT day, text FROM notes ORDER BY rowid;", 2)
+	if err != nil {
+		return log, err
+	}
+	for _, note := range notes {
+		d, err := civil.ParseDate(note[0])
+		if err != nil {
+			log.Warnings = append(log.Warnings, fmt.Sprintf("Skipping note with invalid date in %s: %s", SQLiteFile, note[0]))
+			continue
+		}
+		log.Notes[d] = append(log.Notes[d], note[1])
+	}
+	return log, nil
+}
+
+// EntriesBetween returns the entries from day from to day to inclusive, for
+// habit or every habit when it's empty, reading only those. Zero days leave
+// the range open at that end.
+func (r *SQLiteRepository) EntriesBetween(habit string, from civil.Date, to civil.Date) (Entries, error) {
+	var where []string
+	if habit != "" {
+		where = append(where, "habit = "+sqlQuote(habit))
+	}
+	if !from.IsZero() {
+		where = append(where, "day >= "+sqlQuote(from.String()))
+	}
+	if !to.IsZero() {
+		where = append(where, "day <= "+sqlQuote(to.String()))
+	}
+	query := "SELECT day, habit, result, comment, amount FROM entries"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := r.query(query+";", 5)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(Entries, len(rows))
+	for _, row := range rows {
+		d, err := civil.ParseDate(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q in %s", row[0], SQLiteFile)
+		}
+		outcome := Outcome{Result: row[2], Comment: row[3]}
+		if row[4] != "" {
+			// NULL, for no amount, reads as empty
+			if outcome.Amount, err = strconv.ParseFloat(row[4], 64); err != nil {
+				return nil, fmt.Errorf("invalid amount %q in %s", row[4], SQLiteFile)
+			}
+		}
+		entries[DailyHabit{Day: d, Habit: row[1]}] = outcome
+	}
+	return entries, nil
+}
+
+// WriteEntry writes an entry to the database, replacing any for the same
+// habit and day as a later line in the log would
+func (r *SQLiteRepository) WriteEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	statement, err := entryInsert(d, habit, result, comment, amount)
+	if err != nil {
+		return err
+	}
+	return r.exec(sqliteSchema + statement)
+}
+
+// UpdateEntry changes an entry in the database, failing with ErrNoEntry
+// when there isn't one for the habit and day
+func (r *SQLiteRepository) UpdateEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	statement, err := entryUpdate(d, habit, result, comment, amount)
+	if err != nil {
+		return err
+	}
+	out, err := r.run(sqliteSchema + statement + "SELECT changes();")
+	if err != nil {
+		return err
+	}
+	rows, err := sqliteRows(out, 1)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 || rows[0][0] == "0" {
+		return fmt.Errorf("%w: %s has nothing logged on %s", ErrNoEntry, habit, d)
+	}
+	return nil
+}
+
+// WriteNote adds a dated note to the database
+func (r *SQLiteRepository) WriteNote(d civil.Date, text string) error {
+	return r.exec(sqliteSchema + noteInsert(d, text))
+}
+
+// GetConfigDir returns the configuration directory
+func (r *SQLiteRepository) GetConfigDir() string {
+	return r.configDir
+}
+
+// InitializeConfig creates the database's tables if they're missing
+func (r *SQLiteRepository) InitializeConfig() error {
+	return r.exec(sqliteSchema)
+}
+
+// MigrateToSQLite copies the log's entries and notes, archived ones
+// included, into a new database in configDir, returning how many of each it
+// copied. The log is left as it was.
+func MigrateToSQLite(configDir string) (int, int, error) {
+	path := filepath.Join(configDir, SQLiteFile)
+	if _, err := os.Stat(path); err == nil {
+		return 0, 0, fmt.Errorf("%s already exists, remove it first to migrate again", path)
+	}
+	log, err := ReadLog(configDir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var b strings.Builder
+	b.WriteString(sqliteSchema + "BEGIN;\n")
+	for _, key := range log.Entries.SortedKeys() {
+		outcome := log.Entries[key]
+		amount := ""
+		if outcome.Amount != 0 {
+			amount = strconv.FormatFloat(outcome.Amount, 'f', -1, 64)
+		}
+		statement, err := entryInsert(key.Day, key.Habit, outcome.Result, outcome.Comment, amount)
+		if err != nil {
+			return 0, 0, err
+		}
+		b.WriteString(statement)
+	}
+	notes := 0
+	for _, day := range log.Notes.SortedDays(civil.Date{}, civil.Date{Year: 9999, Month: 12, Day: 31}) {
+		for _, text := range log.Notes[day] {
+			b.WriteString(noteInsert(day, text))
+			notes++
+		}
+	}
+	b.WriteString("COMMIT;\n")
+	if err := NewSQLiteRepository(configDir).exec(b.String()); err != nil {
+		os.Remove(path)
+		return 0, 0, err
+	}
+	return len(log.Entries), notes, nil
+}
+
+// ExportSQLiteLog writes the database's entries and notes in configDir back
+// to the log file, first copying the log there to log.bak
+func ExportSQLiteLog(configDir string) (NormalizeResult, error) {
+	log, err := NewSQLiteRepository(configDir).LoadEntries()
+	if err != nil {
+		return NormalizeResult{}, err
+	}
+	lines := []string{DefaultHeader.String()}
+	for _, key := range log.Entries.SortedKeys() {
+		outcome := log.Entries[key]
+		amount := ""
+		if outcome.Amount != 0 {
+			amount = strconv.FormatFloat(outcome.Amount, 'f', -1, 64)
+		}
+		lines = append(lines, EntryLine(key.Day, key.Habit, outcome.Result, outcome.Comment, amount, DefaultHeader))
+	}
+	for day, texts := range log.Notes {
+		for _, text := range texts {
+			lines = append(lines, day.String()+NoteSeparator+text)
+		}
+	}
+	// Normalizing sorts notes in with their days
+	result, err := NormalizeLog(lines)
+	if err != nil {
+		return result, err
+	}
+
+	// Held while the log's replaced, so nothing appended meanwhile is lost
+	unlock, err := lockLog(configDir)
+	if err != nil {
+		return result, err
+	}
+	defer unlock()
+	logPath := filepath.Join(configDir, "log")
+	if info, err := os.Stat(logPath); err == nil {
+		data, err := os.ReadFile(logPath)
+		if err != nil {
+			return result, fmt.Errorf("cannot back up log before replacing it: %w", err)
+		}
+		backup := filepath.Join(configDir, "log.bak")
+		if err := replaceFile(backup, data, info.Mode().Perm()); err != nil {
+			return result, fmt.Errorf("cannot back up log before replacing it: %w", err)
+		}
+		if err := os.Chmod(backup, info.Mode().Perm()); err != nil {
+			return result, fmt.Errorf("cannot back up log before replacing it: %w", err)
+		}
+	}
+	if err := replaceFile(logPath, []byte(strings.Join(result.Lines, "\n")+"\n"), 0644); err != nil {
+		return result, fmt.Errorf("cannot write log: %w", err)
+	}
+	return result, nil
+}
+
+// entryInsert returns the statement writing an entry
+func entryInsert(d civil.Date, habit string, result string, comment string, amount string) (string, error) {
+	value, err := sqlAmount(amount)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("INSERT INTO entries (day, habit, result, comment, amount) VALUES (%s, %s, %s, %s, %s) "+
+		"ON CONFLICT (day, habit) DO UPDATE SET result = excluded.result, comment = excluded.comment, amount = excluded.amount;\n",
+		sqlQuote(d.String()), sqlQuote(habit), sqlQuote(result), sqlQuote(strings.TrimSpace(comment)), value), nil
+}
+
+// entryUpdate returns the statement changing an entry, if there is one
+func entryUpdate(d civil.Date, habit string, result string, comment string, amount string) (string, error) {
+	value, err := sqlAmount(amount)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("UPDATE entries SET result = %s, comment = %s, amount = %s WHERE day = %s AND habit = %s;\n",
+		sqlQuote(result), sqlQuote(strings.TrimSpace(comment)), value, sqlQuote(d.String()), sqlQuote(habit)), nil
+}
+
+// sqlAmount returns an entry's amount as an SQL value, NULL when it's empty
+func sqlAmount(amount string) (string, error) {
+	if strings.TrimSpace(amount) == "" {
+		return "NULL", nil
+	}
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(amount), 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid amount %q", amount)
+	}
+	return strconv.FormatFloat(parsed, 'f', -1, 64), nil
+}
+
+// noteInsert returns the statement writing a note
+func noteInsert(d civil.Date, text string) string {
+	return fmt.Sprintf("INSERT INTO notes (day, text) VALUES (%s, %s);\n", sqlQuote(d.String()), sqlQuote(strings.ReplaceAll(text, "\n", " ")))
+}
+
+// sqlQuote quotes s as an SQL string literal
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// query runs a select returning columns columns on the database opened read
+// only, and returns its rows, none when there's no database yet
+func (r *SQLiteRepository) query(sql string, columns int) ([][]string, error) {
+	if _, err := os.Stat(filepath.Join(r.configDir, SQLiteFile)); os.IsNotExist(err) {
+		return nil, nil
+	}
+	out, err := r.runWith(sql, "-readonly", "-ascii")
+	if err != nil {
+		return nil, err
+	}
+	return sqliteRows(out, columns)
+}
+
+// sqliteRows splits sqlite3's -ascii output into rows of columns columns.
+// It separates them with the ASCII unit and record separators, which decode
+// far faster than its JSON output on big logs.
+func sqliteRows(out []byte, columns int) ([][]string, error) {
+	if len(out) == 0 {
+		return nil, nil
+	}
+	records := strings.Split(strings.TrimSuffix(string(out), "\x1e"), "\x1e")
+	rows := make([][]string, 0, len(records))
+	for _, record := range records {
+		row := strings.Split(record, "\x1f")
+		if len(row) != columns {
+			return nil, fmt.Errorf("cannot read from %s: expected %d columns, found %d", SQLiteFile, columns, len(row))
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// exec runs statements, stopping at the first that fails
+func (r *SQLiteRepository) exec(sql string) error {
+	_, err := r.run(sql)
+	return err
+}
+
+// run runs sql through sqlite3 on the database, returning any rows it prints
+func (r *SQLiteRepository) run(sql string) ([]byte, error) {
+	return r.runWith(sql, "-ascii")
+}
+
+// runWith runs sql like run, with sqlite3 options such as its output mode
+func (r *SQLiteRepository) runWith(sql string, options ...string) ([]byte, error) {
+	args := append(append([]string{"-batch", "-bail"}, options...), filepath.Join(r.configDir, SQLiteFile))
+	command := exec.Command("sqlite3", args...)
+	command.Stdin = strings.NewReader(sql)
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	out, err := command.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, errors.New("the sqlite backend needs the sqlite3 command line tool, which isn't installed")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sqlite3 failed on %s: %s (%w)", SQLiteFile, strings.TrimSpace(stderr.String()), err)
+	}
+	return out, nil
+}
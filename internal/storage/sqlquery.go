@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// QuerySchema describes the tables harsh sql can query. It's kept stable
+// so saved queries keep working: columns may be added, but none renamed or
+// removed.
+const QuerySchema = `entries   one row per habit per day logged
+  day       TEXT     the day, YYYY-MM-DD
+  habit     TEXT     the habit's name, qualified with its heading where needed
+  result    TEXT     y, n, or s
+  comment   TEXT     the comment, '' for none
+  amount    REAL     the amount, NULL for none
+
+notes     dated notes, in the order written
+  day       TEXT     the day, YYYY-MM-DD
+  text      TEXT     the note
+
+habits    the habits in your habits file, for joining with entries
+  name      TEXT     the habit's name, as in entries.habit
+  heading   TEXT     the heading it's under, '' for none
+  frequency TEXT     the frequency as written, eg. 3/7
+  target    INTEGER  how many times it's due...
+  interval  INTEGER  ...in how many days
+  hidden    INTEGER  1 when it's hidden=yes, else 0`
+
+// readingStatements are the statements a query can start with
+var readingStatements = []string{"SELECT", "WITH", "VALUES", "EXPLAIN"}
+
+// CheckReadOnlyQuery returns an error unless sql is a single statement that
+// only reads, the first guard against writes before the database is opened
+// read only
+func CheckReadOnlyQuery(sql string) error {
+	statements, err := splitStatements(sql)
+	if err != nil {
+		return err
+	}
+	switch len(statements) {
+	case 0:
+		return errors.New("the query is empty")
+	case 1:
+	default:
+		return fmt.Errorf("run one query at a time, found %d", len(statements))
+	}
+	keyword := statements[0]
+	if end := strings.IndexFunc(keyword, func(r rune) bool { return !unicode.IsLetter(r) }); end >= 0 {
+		keyword = keyword[:end]
+	}
+	// WITH can go on to write, which opening the database read only stops
+	if !slices.Contains(readingStatements, strings.ToUpper(keyword)) {
+		return fmt.Errorf("only queries that read can be run, starting with %s", strings.Join(readingStatements, ", "))
+	}
+	return nil
+}
+
+// splitStatements splits sql into its statements, without comments or the
+// semicolons between them, leaving semicolons in quotes alone
+func splitStatements(sql string) ([]string, error) {
+	var statements []string
+	var b strings.Builder
+	end := func() {
+		if statement := strings.TrimSpace(b.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		b.Reset()
+	}
+	closing := map[byte]byte{'\'': '\'', '"': '"', '`': '`', '[': ']'}
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case closing[c] != 0:
+			j := strings.IndexByte(sql[i+1:], closing[c])
+			if j < 0 {
+				return nil, fmt.Errorf("the query has an unclosed %c", c)
+			}
+			b.WriteString(sql[i : i+j+2])
+			i += j + 1
+		case strings.HasPrefix(sql[i:], "--"):
+			j := strings.IndexByte(sql[i:], '\n')
+			if j < 0 {
+				j = len(sql) - i
+			}
+			b.WriteByte(' ')
+			i += j
+		case strings.HasPrefix(sql[i:], "/*"):
+			j := strings.Index(sql[i+2:], "*/")
+			if j < 0 {
+				return nil, errors.New("the query has an unclosed /* comment")
+			}
+			b.WriteByte(' ')
+			i += j + 3
+		case c == ';':
+			end()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	end()
+	return statements, nil
+}
+
+// Query runs a query from harsh sql against the database, opened read only
+// with sqlite3's safe mode keeping it from reaching other files, and
+// habits in the habits table. It returns the query's column names and its
+// rows as text, NULL reading as empty.
+func (r *SQLiteRepository) Query(sql string, habits []*Habit) ([]string, [][]string, error) {
+	script, err := queryScript(sql, habits)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err := r.runWith(script, "-readonly", "-safe", "-ascii", "-header")
+	if err != nil || len(out) == 0 {
+		return nil, nil, err
+	}
+	records := strings.Split(strings.TrimSuffix(string(out), "\x1e"), "\x1e")
+	columns := strings.Split(records[0], "\x1f")
+	rows := make([][]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		rows = append(rows, strings.Split(record, "\x1f"))
+	}
+	return columns, rows, nil
+}
+
+// QueryJSON runs a query like Query, returning its rows as a JSON array of
+// objects keyed by column name, with numbers and NULLs as such
+func (r *SQLiteRepository) QueryJSON(sql string, habits []*Habit) ([]byte, error) {
+	script, err := queryScript(sql, habits)
+	if err != nil {
+		return nil, err
+	}
+	out, err := r.runWith(script, "-readonly", "-safe", "-json")
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return []byte("[]"), nil
+	}
+	return out, nil
+}
+
+// queryScript checks sql only reads and sets the habits table up before it.
+// The table is temporary, so it's written outside the read only database.
+func queryScript(sql string, habits []*Habit) (string, error) {
+	if err := CheckReadOnlyQuery(sql); err != nil {
+		return "", err
+	}
+	statements, _ := splitStatements(sql)
+	var b strings.Builder
+	b.WriteString("CREATE TEMP TABLE habits (name TEXT PRIMARY KEY, heading TEXT NOT NULL, frequency TEXT NOT NULL, " +
+		"target INTEGER NOT NULL, interval INTEGER NOT NULL, hidden INTEGER NOT NULL);\n")
+	for _, habit := range habits {
+		hidden := 0
+		if habit.Hidden {
+			hidden = 1
+		}
+		fmt.Fprintf(&b, "INSERT INTO temp.habits VALUES (%s, %s, %s, %d, %d, %d);\n", sqlQuote(habit.Name), sqlQuote(habit.Heading),
+			sqlQuote(habit.Frequency), habit.Target, habit.Interval, hidden)
+	}
+	b.WriteString(statements[0] + ";\n")
+	return b.String(), nil
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/clock"
+)
+
+// WritesFile is the sidecar file in the config directory recording when each
+// entry was written, kept only with the write_times setting on
+const WritesFile = "writes"
+
+// Write is one entry written to the log, and when
+type Write struct {
+	// At is when the entry was written, in local time
+	At    civil.DateTime
+	Day   civil.Date
+	Habit string
+}
+
+// Delay returns how many days after its day the entry was written
+func (w Write) Delay() int {
+	return w.At.Date.DaysSince(w.Day)
+}
+
+// LoadWrites reads the write times recorded in configDir, oldest first. A
+// missing file yields none.
+func LoadWrites(configDir string) ([]Write, error) {
+	path := filepath.Join(configDir, WritesFile)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot open writes file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var writes []Write
+	scanner := bufio.NewScanner(file)
+	lineCount := 0
+	for scanner.Scan() {
+		lineCount++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.SplitN(line, " : ", 3)
+		if len(fields) != 3 {
+			return writes, fmt.Errorf("%s line %d: expected time : date : habit", path, lineCount)
+		}
+		at, err := civil.ParseDateTime(fields[0])
+		if err != nil {
+			return writes, fmt.Errorf("%s line %d: %w", path, lineCount, err)
+		}
+		day, err := civil.ParseDate(fields[1])
+		if err != nil {
+			return writes, fmt.Errorf("%s line %d: %w", path, lineCount, err)
+		}
+		writes = append(writes, Write{At: at, Day: day, Habit: fields[2]})
+	}
+	return writes, scanner.Err()
+}
+
+// RecordWrite appends a write to the writes file in configDir
+func RecordWrite(configDir string, write Write) error {
+	path := filepath.Join(configDir, WritesFile)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open writes file %s: %w", path, err)
+	}
+	if _, err := fmt.Fprintf(f, "%s : %s : %s\n", write.At, write.Day, write.Habit); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot record write time in %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// TimedRepository wraps a Repository, recording when each entry is written
+// so harsh meta can tell same-day logging from backfilling
+type TimedRepository struct {
+	Repository
+}
+
+// NewTimedRepository wraps repository to record write times
+func NewTimedRepository(repository Repository) *TimedRepository {
+	return &TimedRepository{Repository: repository}
+}
+
+// WriteEntry writes a log entry, then records when. The entry stands even
+// if its time can't be recorded.
+func (r *TimedRepository) WriteEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	if err := r.Repository.WriteEntry(d, habit, result, comment, amount, header); err != nil {
+		return err
+	}
+	r.recordWrite(d, habit)
+	return nil
+}
+
+// UpdateEntry changes a log entry, then records when, like WriteEntry
+func (r *TimedRepository) UpdateEntry(d civil.Date, habit string, result string, comment string, amount string, header Header) error {
+	if err := r.Repository.UpdateEntry(d, habit, result, comment, amount, header); err != nil {
+		return err
+	}
+	r.recordWrite(d, habit)
+	return nil
+}
+
+// recordWrite records that habit's entry for day d was written now
+func (r *TimedRepository) recordWrite(d civil.Date, habit string) {
+	// The date follows harsh's clock, so --today logs as if on that day
+	at := civil.DateTime{Date: clock.Today(), Time: civil.TimeOf(time.Now().Truncate(time.Second))}
+	if err := RecordWrite(r.GetConfigDir(), Write{At: at, Day: d, Habit: habit}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
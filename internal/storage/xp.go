@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/civil"
+)
+
+// XPStateFile is the sidecar file in the config directory remembering XP
+// as of the last time it was shown, so gains since then can be reported
+const XPStateFile = "xp"
+
+// XPState is the XP and level last shown to the user
+type XPState struct {
+	XP      int
+	Level   int
+	Checked civil.Date
+}
+
+// LoadXPState reads the XP sidecar file in configDir. A missing file yields
+// a zero state.
+func LoadXPState(configDir string) (XPState, error) {
+	var state XPState
+	path := filepath.Join(configDir, XPStateFile)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return state, fmt.Errorf("cannot open XP file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineCount := 0
+	for scanner.Scan() {
+		lineCount++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		key, value, _ := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "xp":
+			state.XP, err = strconv.Atoi(value)
+		case "level":
+			state.Level, err = strconv.Atoi(value)
+		case "checked":
+			state.Checked, err = civil.ParseDate(value)
+		default:
+			err = fmt.Errorf("unknown key %q", key)
+		}
+		if err != nil {
+			return state, fmt.Errorf("%s line %d: %w", path, lineCount, err)
+		}
+	}
+	return state, scanner.Err()
+}
+
+// Save writes the XP state to the sidecar file in configDir
+func (state XPState) Save(configDir string) error {
+	content := fmt.Sprintf("# harsh XP, rebuilt from your log by `harsh level`\nxp = %d\nlevel = %d\nchecked = %s\n",
+		state.XP, state.Level, state.Checked)
+	path := filepath.Join(configDir, XPStateFile)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("cannot write XP file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// WeeklyCheckIn writes a plain text summary of the week ending on date to for
+// an accountability partner: each scoreable habit's graph for the last seven
+// days and its completion rate, then the average daily score
+func WeeklyCheckIn(habits []*storage.Habit, entries storage.History, to civil.Date) string {
+	from := to.AddDays(-6)
+	var tracked []*storage.Habit
+	nameWidth := 0
+	for _, habit := range habits {
+		if habit.Target == 0 || habit.FirstRecord == (civil.Date{}) || habit.FirstRecord.After(to) {
+			continue
+		}
+		tracked = append(tracked, habit)
+		nameWidth = max(nameWidth, len([]rune(habit.Name)))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Habits for the week of %s to %s\n\n", from, to)
+	if len(tracked) == 0 {
+		b.WriteString("No habits tracked this week.\n")
+		return b.String()
+	}
+	for _, habit := range tracked {
+		padding := strings.Repeat(" ", nameWidth-len([]rune(habit.Name)))
+		fmt.Fprintf(&b, "%s%s  %s  %3.0f%%\n", habit.Name, padding,
+			graph.BuildGraphUntil(habit, entries, to, 6, false),
+			RateBetween(habit, entries, teamFrom(habit, from), to))
+	}
+	score := graph.AverageScores(to, []int{7}, tracked, entries)[0]
+	fmt.Fprintf(&b, "\nAverage daily score: %.0f%%\n", score)
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"slices"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// AutofillComment marks entries logged by harsh daemon rather than by you
+const AutofillComment = "[auto] unanswered by end of day"
+
+// Unanswered returns, in habits file order, the habits still waiting for an
+// answer on day that autofill may fill in: ones on day's todos that count
+// towards scores, have been logged before, haven't opted out with
+// autofill=no, and aren't snoozed
+func Unanswered(habits []*storage.Habit, entries storage.History, day civil.Date, snoozed storage.Snoozed) []*storage.Habit {
+	todos := withoutSnoozed(GetTodos(habits, entries, day, 1), snoozed)[day.String()]
+	var unanswered []*storage.Habit
+	for _, habit := range habits {
+		started := habit.FirstRecord != civil.Date{}
+		if habit.Target > 0 && started && !habit.NoAutofill && slices.Contains(todos, habit.Name) {
+			unanswered = append(unanswered, habit)
+		}
+	}
+	return unanswered
+}
+
+// Autofill logs the unanswered habits on day as not done, returning their names
+func Autofill(habits []*storage.Habit, log *storage.Log, repository storage.Repository, day civil.Date, snoozed storage.Snoozed) ([]string, error) {
+	var filled []string
+	for _, habit := range Unanswered(habits, log, day, snoozed) {
+		if err := repository.WriteEntry(day, habit.Name, "n", AutofillComment, "", log.Header); err != nil {
+			return filled, err
+		}
+		log.Record(storage.DailyHabit{Day: day, Habit: habit.Name}, storage.Outcome{Result: "n", Comment: AutofillComment})
+		filled = append(filled, habit.Name)
+	}
+	return filled, nil
+}
@@ -0,0 +1,96 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// BreakReasonCount is how often one reason was given for breaking a streak
+type BreakReasonCount struct {
+	Reason string
+	Count  int
+}
+
+// HabitBreakReasons holds the reasons given for a habit's broken streaks,
+// most frequent first
+type HabitBreakReasons struct {
+	Habit   string
+	Total   int
+	Reasons []BreakReasonCount
+}
+
+// BreaksStreak reports whether not doing a habit on d ends a running streak:
+// the habit was done the day before and d isn't already satisfied or skipped
+func BreaksStreak(d civil.Date, habit *storage.Habit, entries storage.History) bool {
+	return habit.Target > 0 &&
+		completion(d.AddDays(-1), habit, entries) == "y" &&
+		!graph.Satisfied(d, habit, entries) &&
+		!graph.Skipified(d, habit, entries)
+}
+
+// BuildBreakReasons tallies the break reasons recorded in entry comments for
+// each habit, in habits file order. Reasons are compared case-insensitively.
+func BuildBreakReasons(habits []*storage.Habit, entries storage.History) []HabitBreakReasons {
+	counts := map[string]map[string]*BreakReasonCount{}
+	for key, outcome := range entries.All() {
+		reason, ok := storage.BreakReason(outcome.Comment)
+		if !ok {
+			continue
+		}
+		if counts[key.Habit] == nil {
+			counts[key.Habit] = map[string]*BreakReasonCount{}
+		}
+		normalized := strings.ToLower(reason)
+		if counts[key.Habit][normalized] == nil {
+			counts[key.Habit][normalized] = &BreakReasonCount{Reason: normalized}
+		}
+		counts[key.Habit][normalized].Count++
+	}
+
+	var report []HabitBreakReasons
+	for _, habit := range habits {
+		if len(counts[habit.Name]) == 0 {
+			continue
+		}
+		breaks := HabitBreakReasons{Habit: habit.Name}
+		for _, count := range counts[habit.Name] {
+			breaks.Total += count.Count
+			breaks.Reasons = append(breaks.Reasons, *count)
+		}
+		sort.Slice(breaks.Reasons, func(i, j int) bool {
+			if breaks.Reasons[i].Count != breaks.Reasons[j].Count {
+				return breaks.Reasons[i].Count > breaks.Reasons[j].Count
+			}
+			return breaks.Reasons[i].Reason < breaks.Reasons[j].Reason
+		})
+		report = append(report, breaks)
+	}
+	return report
+}
+
+// ShowBreakReasons displays the reasons given for each habit's broken streaks
+func (d *Display) ShowBreakReasons(habits []*storage.Habit, entries storage.History, maxHabitNameLength int) {
+	report := BuildBreakReasons(habits, entries)
+	if len(report) == 0 {
+		fmt.Fprintln(d.out(), "No break reasons logged yet. Turn on `harsh config set break_reasons true` to be asked when a streak breaks.")
+		return
+	}
+	for _, breaks := range report {
+		d.colorManager.PrintfBold("%*v", maxHabitNameLength, fitName(breaks.Habit, maxHabitNameLength)+": ")
+		fmt.Fprintf(d.out(), "%d break", breaks.Total)
+		if breaks.Total != 1 {
+			fmt.Fprint(d.out(), "s")
+		}
+		fmt.Fprintln(d.out())
+		for _, reason := range breaks.Reasons {
+			fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, "")
+			d.colorManager.PrintfRed("%4d", reason.Count)
+			fmt.Fprintf(d.out(), "  %s\n", reason.Reason)
+		}
+	}
+}
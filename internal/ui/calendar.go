@@ -0,0 +1,220 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// CalendarShades shade calendar days from least to most: lowest scores, or
+// smallest amounts, first
+var CalendarShades = []string{"░", "▒", "▓", "█"}
+
+// calendarLabelWidth is the room left of the calendar for weekday names
+const calendarLabelWidth = 4
+
+// ShowCalendar prints a heatmap of the days from the week start on or before from
+// up to to, a row per weekday and a column per week like GitHub's
+// contribution graph. With a habit each day shows how it went, or with
+// amounts, done days are shaded by their amount. Without one each day is
+// shaded by its score. Weeks with notes are marked under the grid, and their
+// notes listed after the key.
+func (d *Display) ShowCalendar(habit *storage.Habit, habits []*storage.Habit, entries storage.History, from civil.Date, to civil.Date, amounts bool) {
+	start := storage.WeekStart(from)
+	weeks := to.DaysSince(start)/7 + 1
+
+	largest := 0.0
+	if habit != nil && amounts {
+		for day := start; !day.After(to); day = day.AddDays(1) {
+			largest = math.Max(largest, storage.OutcomeAt(entries, storage.DailyHabit{Day: day, Habit: habit.Name}).Amount)
+		}
+	}
+
+	title := "Daily scores"
+	if habit != nil {
+		title = habit.Name + ", " + habit.Frequency
+	}
+	d.colorManager.PrintlnBold(fmt.Sprintf("%s, %s %d to %s %d", title, time.Month(from.Month).String()[:3], from.Year, time.Month(to.Month).String()[:3], to.Year))
+	fmt.Fprintln(d.out(), calendarMonths(start, weeks))
+
+	for row := 0; row < 7; row++ {
+		label := ""
+		if row%2 == 0 && row < 6 {
+			// Every other weekday, as GitHub labels them
+			label = time.Weekday((int(storage.FirstWeekday) + row) % 7).String()[:3]
+		}
+		fmt.Fprintf(d.out(), "%-*s", calendarLabelWidth, label)
+		for week := 0; week < weeks; week++ {
+			day := start.AddDays(week*7 + row)
+			switch {
+			case day.After(to):
+				fmt.Fprint(d.out(), " ")
+			case habit == nil:
+				d.printScoreCell(day, habits, entries)
+			default:
+				d.printHabitCell(day, to, habit, entries, largest)
+			}
+		}
+		fmt.Fprintln(d.out())
+	}
+	noteDays := d.Notes.SortedDays(start, to)
+	if len(noteDays) > 0 {
+		fmt.Fprintln(d.out(), calendarNoteMarkers(noteDays, start, weeks))
+	}
+	d.showCalendarKey(habit != nil, largest > 0, habit != nil && habit.AmountGoal > 0, habit != nil && habit.Fallback != "")
+	if len(noteDays) > 0 {
+		fmt.Fprintln(d.out())
+		for _, day := range noteDays {
+			for _, note := range d.Notes[day] {
+				d.colorManager.PrintfBold("%s", day.String())
+				fmt.Fprintf(d.out(), " %s\n", note)
+			}
+		}
+	}
+}
+
+// calendarNoteMarkers returns a line with a ^ under each week that has notes
+func calendarNoteMarkers(noteDays []civil.Date, start civil.Date, weeks int) string {
+	markers := []rune(strings.Repeat(" ", calendarLabelWidth+weeks))
+	for _, day := range noteDays {
+		markers[calendarLabelWidth+day.DaysSince(start)/7] = '^'
+	}
+	return strings.TrimRight(string(markers), " ")
+}
+
+// calendarMonths labels the weeks each month starts in, leaving a month out
+// when there isn't room for it before the next
+func calendarMonths(start civil.Date, weeks int) string {
+	line := []rune(strings.Repeat(" ", calendarLabelWidth+weeks))
+	next := 0
+	for week := 0; week < weeks; week++ {
+		// The week holding the 1st of a month starts it
+		sunday := start.AddDays(week*7 + 6)
+		at := calendarLabelWidth + week
+		if sunday.Day > 7 || at < next || at+3 > len(line) {
+			continue
+		}
+		copy(line[at:], []rune(time.Month(sunday.Month).String()[:3]))
+		next = at + 4
+	}
+	return strings.TrimRight(string(line[:calendarLabelWidth+weeks]), " ")
+}
+
+// printHabitCell prints one day of a habit's calendar
+func (d *Display) printHabitCell(day civil.Date, to civil.Date, habit *storage.Habit, entries storage.History, largest float64) {
+	state := graph.DayState(day, to, habit, entries)
+	if outcome := storage.OutcomeAt(entries, storage.DailyHabit{Day: day, Habit: habit.Name}); state == graph.StateDone && largest > 0 && outcome.Amount > 0 {
+		level := int(math.Ceil(outcome.Amount/largest*float64(len(CalendarShades)))) - 1
+		d.colorManager.PrintGreen(CalendarShades[max(0, min(level, len(CalendarShades)-1))])
+		return
+	}
+	d.printStateCell(state)
+}
+
+// printStateCell prints a kind of day as a calendar cell
+func (d *Display) printStateCell(state string) {
+	if d.colorManager.IsDisabled() || graph.Symbols == graph.Letters {
+		// Without colors the cells need letters to tell days apart
+		fmt.Fprint(d.out(), graph.Letters.Symbol(state))
+		return
+	}
+	switch state {
+	case graph.StateDone:
+		d.colorManager.PrintGreen("■")
+	case graph.StateFallback:
+		d.colorManager.PrintGreen("▣")
+	case graph.StateSatisfied:
+		d.colorManager.PrintGreen("□")
+	case graph.StateShort:
+		d.colorManager.PrintGreen("▪")
+	case graph.StateSkipped:
+		d.colorManager.PrintYellow("■")
+	case graph.StateSkipified:
+		d.colorManager.PrintYellow("□")
+	case graph.StateBroken:
+		d.colorManager.PrintRed("■")
+	case graph.StateWarning:
+		d.colorManager.PrintYellow("!")
+	case graph.StateMissing:
+		fmt.Fprint(d.out(), "·")
+	default:
+		fmt.Fprint(d.out(), " ")
+	}
+}
+
+// printScoreCell prints one day of the daily scores calendar, shaded by score
+func (d *Display) printScoreCell(day civil.Date, habits []*storage.Habit, entries storage.History) {
+	score, ok := graph.DayScore(day, habits, entries)
+	switch {
+	case !graph.Begun(day, habits):
+		fmt.Fprint(d.out(), " ")
+	case !ok && graph.EmptyDays == graph.EmptyDaysPerfect:
+		d.colorManager.PrintGreen(CalendarShades[len(CalendarShades)-1])
+	case !ok && graph.EmptyDays == graph.EmptyDaysSkip:
+		fmt.Fprint(d.out(), " ")
+	case !ok:
+		fmt.Fprint(d.out(), graph.EmptyScore)
+	case score == 0:
+		d.colorManager.PrintRed("·")
+	default:
+		level := int(math.Ceil(score/100*float64(len(CalendarShades)))) - 1
+		d.colorManager.PrintGreen(CalendarShades[max(0, min(level, len(CalendarShades)-1))])
+	}
+}
+
+// showCalendarKey explains the calendar's cells, with short days for a
+// habit with an amount goal and fallback days for one with a fallback
+func (d *Display) showCalendarKey(habit bool, amounts bool, short bool, fallback bool) {
+	fmt.Fprintf(d.out(), "\n%*s", calendarLabelWidth, "")
+	if !habit {
+		d.colorManager.PrintRed("·")
+		fmt.Fprint(d.out(), " 0%  ")
+		d.colorManager.PrintGreen(strings.Join(CalendarShades, ""))
+		fmt.Fprint(d.out(), " up to 100%")
+		if graph.EmptyDays == graph.EmptyDaysNA {
+			fmt.Fprint(d.out(), "  "+graph.EmptyScore+" nothing to score")
+		}
+		fmt.Fprintln(d.out())
+		return
+	}
+	if amounts {
+		d.colorManager.PrintGreen(strings.Join(CalendarShades, ""))
+		fmt.Fprint(d.out(), " done, by amount  ")
+	}
+	if d.colorManager.IsDisabled() || graph.Symbols == graph.Letters {
+		fmt.Fprintf(d.out(), "%s done  ", graph.Letters.Done)
+		if fallback {
+			fmt.Fprintf(d.out(), "%s fallback  ", graph.Letters.Fallback)
+		}
+		fmt.Fprintf(d.out(), "%s met  ", graph.Letters.Satisfied)
+		if short {
+			fmt.Fprintf(d.out(), "%s short  ", graph.Letters.Short)
+		}
+		fmt.Fprintf(d.out(), "%s skipped  %s broken  %s due  %s unlogged\n",
+			graph.Letters.Skip, graph.Letters.Broken, graph.Letters.Warning, graph.Letters.Missing)
+		return
+	}
+	d.colorManager.PrintGreen("■")
+	fmt.Fprint(d.out(), " done  ")
+	if fallback {
+		d.colorManager.PrintGreen("▣")
+		fmt.Fprint(d.out(), " fallback  ")
+	}
+	d.colorManager.PrintGreen("□")
+	fmt.Fprint(d.out(), " met  ")
+	if short {
+		d.colorManager.PrintGreen("▪")
+		fmt.Fprint(d.out(), " short  ")
+	}
+	d.colorManager.PrintYellow("■")
+	fmt.Fprint(d.out(), " skipped  ")
+	d.colorManager.PrintRed("■")
+	fmt.Fprint(d.out(), " broken  ")
+	d.colorManager.PrintYellow("!")
+	fmt.Fprint(d.out(), " due  · unlogged\n")
+}
@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// ChallengeProgress is how far a challenge has got by a given day
+type ChallengeProgress struct {
+	Challenge storage.Challenge
+	// Day is the challenge day reached, from 1 up to the challenge's Days
+	Day     int
+	Done    int
+	Skipped int
+	// Missed counts the days gone by without the habit done or skipped.
+	// The last day counted isn't missed until it's over.
+	Missed int
+	// BestRun is the longest run of days done in a row, skips not breaking
+	// it unless graph.BreakingSkips
+	BestRun int
+	// Finished is set once the challenge's last day is over
+	Finished bool
+}
+
+// Succeeded reports whether every day of a finished challenge was done or skipped
+func (p ChallengeProgress) Succeeded() bool {
+	return p.Finished && p.Done+p.Skipped == p.Challenge.Days
+}
+
+// BuildChallengeProgress tallies challenge's days up to and including to
+func BuildChallengeProgress(challenge storage.Challenge, entries storage.History, to civil.Date) ChallengeProgress {
+	progress := ChallengeProgress{Challenge: challenge, Finished: to.After(challenge.End())}
+	last := to
+	if challenge.End().Before(last) {
+		last = challenge.End()
+	}
+	run := 0
+	for d := challenge.Start; !d.After(last); d = d.AddDays(1) {
+		progress.Day++
+		switch storage.OutcomeAt(entries, storage.DailyHabit{Day: d, Habit: challenge.Habit}).Result {
+		case "y":
+			progress.Done++
+			run++
+			progress.BestRun = max(progress.BestRun, run)
+		case "s":
+			progress.Skipped++
+			if graph.BreakingSkips {
+				run = 0
+			}
+		default:
+			if d != to {
+				progress.Missed++
+				run = 0
+			}
+		}
+	}
+	return progress
+}
+
+// ProgressBar draws done out of total as a bar width cells wide
+func ProgressBar(done int, total int, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = min(width, done*width/total)
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
+
+// challengeBarWidth is the width of challenge progress bars
+const challengeBarWidth = 30
+
+// ShowChallenges prints a progress bar for each challenge as of date to
+func (d *Display) ShowChallenges(challenges storage.Challenges, entries storage.History, to civil.Date, maxHabitNameLength int) {
+	for _, challenge := range challenges {
+		progress := BuildChallengeProgress(challenge, entries, to)
+		fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, fitName(challenge.Name, maxHabitNameLength)+"  ")
+		fmt.Fprint(d.out(), ProgressBar(progress.Done+progress.Skipped, challenge.Days, challengeBarWidth))
+		switch {
+		case progress.Succeeded():
+			d.colorManager.PrintfGreen(" %d/%d, completed\n", progress.Done+progress.Skipped, challenge.Days)
+		case progress.Finished:
+			d.colorManager.PrintfRed(" %d/%d, ended %s\n", progress.Done+progress.Skipped, challenge.Days, challenge.End())
+		case to.Before(challenge.Start):
+			fmt.Fprintf(d.out(), " starts %s\n", challenge.Start)
+		default:
+			fmt.Fprintf(d.out(), " %d/%d, day %d\n", progress.Done+progress.Skipped, challenge.Days, progress.Day)
+		}
+	}
+}
+
+// ShowChallengeReport prints how a challenge went, day by day totals and all
+func (d *Display) ShowChallengeReport(progress ChallengeProgress) {
+	challenge := progress.Challenge
+	d.colorManager.PrintlnBold(challenge.Name)
+	fmt.Fprintf(d.out(), "%s every day, %s to %s (%d days)\n\n", challenge.Habit, challenge.Start, challenge.End(), challenge.Days)
+	fmt.Fprintf(d.out(), "%-10s%d\n", "Done", progress.Done)
+	fmt.Fprintf(d.out(), "%-10s%d\n", "Skipped", progress.Skipped)
+	fmt.Fprintf(d.out(), "%-10s%d\n", "Missed", progress.Missed)
+	fmt.Fprintf(d.out(), "%-10s%d days\n\n", "Best run", progress.BestRun)
+	switch {
+	case progress.Succeeded():
+		d.colorManager.PrintfGreen("Challenge completed, %d of %d days!\n", challenge.Days, challenge.Days)
+	case progress.Finished:
+		d.colorManager.PrintfRed("Challenge over with %d of %d days.\n", progress.Done+progress.Skipped, challenge.Days)
+	default:
+		fmt.Fprintf(d.out(), "Day %d of %d, %d to go.\n", progress.Day, challenge.Days, challenge.Days-progress.Day)
+	}
+}
+
+// challengeNote returns the reminder shown beside habit in todos for day, if
+// it's part of a challenge running then
+func challengeNote(challenges storage.Challenges, habit string, day civil.Date) string {
+	for _, challenge := range challenges.Active(day) {
+		if challenge.Habit == habit {
+			return fmt.Sprintf("%s, day %d/%d", challenge.Name, day.DaysSince(challenge.Start)+1, challenge.Days)
+		}
+	}
+	return ""
+}
@@ -2,6 +2,8 @@ package ui
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/gookit/color"
 )
@@ -9,6 +11,7 @@ import (
 // ColorManager handles color output configuration
 type ColorManager struct {
 	disabled bool
+	out      io.Writer
 }
 
 // NewColorManager creates a new color manager
@@ -30,6 +33,19 @@ func (cm *ColorManager) SetNoColor(disabled bool) {
 	}
 }
 
+// SetOutput sends output to w rather than stdout
+func (cm *ColorManager) SetOutput(w io.Writer) {
+	cm.out = w
+}
+
+// writer is where output goes, stdout unless set otherwise
+func (cm *ColorManager) writer() io.Writer {
+	if cm.out == nil {
+		return os.Stdout
+	}
+	return cm.out
+}
+
 // IsDisabled returns whether color output is disabled
 func (cm *ColorManager) IsDisabled() bool {
 	return cm.disabled
@@ -38,98 +54,106 @@ func (cm *ColorManager) IsDisabled() bool {
 // PrintBold prints text in bold
 func (cm *ColorManager) PrintBold(text string) {
 	if cm.disabled {
-		fmt.Print(text)
+		fmt.Fprint(cm.writer(), text)
 	} else {
-		color.Bold.Print(text)
+		fmt.Fprint(cm.writer(), color.Bold.Sprint(text))
 	}
 }
 
 // PrintlnBold prints text in bold with newline
 func (cm *ColorManager) PrintlnBold(text string) {
 	if cm.disabled {
-		fmt.Println(text)
+		fmt.Fprintln(cm.writer(), text)
 	} else {
-		color.Bold.Println(text)
+		fmt.Fprintln(cm.writer(), color.Bold.Sprint(text))
 	}
 }
 
 // PrintfBold prints formatted text in bold
 func (cm *ColorManager) PrintfBold(format string, args ...interface{}) {
 	if cm.disabled {
-		fmt.Printf(format, args...)
+		fmt.Fprintf(cm.writer(), format, args...)
 	} else {
-		color.Bold.Printf(format, args...)
+		fmt.Fprint(cm.writer(), color.Bold.Sprintf(format, args...))
 	}
 }
 
 // PrintGreen prints text in green
 func (cm *ColorManager) PrintGreen(text string) {
 	if cm.disabled {
-		fmt.Print(text)
+		fmt.Fprint(cm.writer(), text)
 	} else {
-		color.FgGreen.Print(text)
+		fmt.Fprint(cm.writer(), color.FgGreen.Sprint(text))
 	}
 }
 
 // PrintfGreen prints formatted text in green
 func (cm *ColorManager) PrintfGreen(format string, args ...interface{}) {
 	if cm.disabled {
-		fmt.Printf(format, args...)
+		fmt.Fprintf(cm.writer(), format, args...)
 	} else {
-		color.FgGreen.Printf(format, args...)
+		fmt.Fprint(cm.writer(), color.FgGreen.Sprintf(format, args...))
 	}
 }
 
 // PrintRed prints text in red
 func (cm *ColorManager) PrintRed(text string) {
 	if cm.disabled {
-		fmt.Print(text)
+		fmt.Fprint(cm.writer(), text)
 	} else {
-		color.FgRed.Print(text)
+		fmt.Fprint(cm.writer(), color.FgRed.Sprint(text))
 	}
 }
 
 // PrintfRed prints formatted text in red
 func (cm *ColorManager) PrintfRed(format string, args ...interface{}) {
 	if cm.disabled {
-		fmt.Printf(format, args...)
+		fmt.Fprintf(cm.writer(), format, args...)
 	} else {
-		color.FgRed.Printf(format, args...)
+		fmt.Fprint(cm.writer(), color.FgRed.Sprintf(format, args...))
 	}
 }
 
 // PrintYellow prints text in yellow
 func (cm *ColorManager) PrintYellow(text string) {
 	if cm.disabled {
-		fmt.Print(text)
+		fmt.Fprint(cm.writer(), text)
 	} else {
-		color.FgYellow.Print(text)
+		fmt.Fprint(cm.writer(), color.FgYellow.Sprint(text))
 	}
 }
 
 // PrintfYellow prints formatted text in yellow
 func (cm *ColorManager) PrintfYellow(format string, args ...interface{}) {
 	if cm.disabled {
-		fmt.Printf(format, args...)
+		fmt.Fprintf(cm.writer(), format, args...)
 	} else {
-		color.FgYellow.Printf(format, args...)
+		fmt.Fprint(cm.writer(), color.FgYellow.Sprintf(format, args...))
 	}
 }
 
 // PrintBlue prints text in blue
 func (cm *ColorManager) PrintBlue(text string) {
 	if cm.disabled {
-		fmt.Print(text)
+		fmt.Fprint(cm.writer(), text)
 	} else {
-		color.FgBlue.Print(text)
+		fmt.Fprint(cm.writer(), color.FgBlue.Sprint(text))
 	}
 }
 
 // PrintfBlue prints formatted text in blue
 func (cm *ColorManager) PrintfBlue(format string, args ...interface{}) {
 	if cm.disabled {
-		fmt.Printf(format, args...)
+		fmt.Fprintf(cm.writer(), format, args...)
 	} else {
-		color.FgBlue.Printf(format, args...)
+		fmt.Fprint(cm.writer(), color.FgBlue.Sprintf(format, args...))
+	}
+}
+
+// Shade returns text on a subtle background, or unchanged without colors
+func (cm *ColorManager) Shade(text string) string {
+	if cm.disabled {
+		return text
 	}
+	return color.C256(236, true).Sprint(text)
 }
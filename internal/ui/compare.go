@@ -0,0 +1,121 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// HabitComparison holds the joint statistics of two habits over the days both were tracked
+type HabitComparison struct {
+	DaysCompared int
+	DoneA        int
+	DoneB        int
+	DoneBoth     int
+}
+
+// ProbAGivenB returns the probability habit A was done on a day habit B was done
+func (c HabitComparison) ProbAGivenB() float64 {
+	if c.DoneB == 0 {
+		return 0
+	}
+	return float64(c.DoneBoth) / float64(c.DoneB) * 100
+}
+
+// ProbBGivenA returns the probability habit B was done on a day habit A was done
+func (c HabitComparison) ProbBGivenA() float64 {
+	if c.DoneA == 0 {
+		return 0
+	}
+	return float64(c.DoneBoth) / float64(c.DoneA) * 100
+}
+
+// BuildComparison counts the days habits a and b were done alone and together,
+// from the later of their first records up to date to
+func BuildComparison(a *storage.Habit, b *storage.Habit, entries storage.History, to civil.Date) HabitComparison {
+	var comparison HabitComparison
+	from := a.FirstRecord
+	if b.FirstRecord.After(from) {
+		from = b.FirstRecord
+	}
+	noFirstRecord := civil.Date{Year: 0, Month: 0, Day: 0}
+	if a.FirstRecord == noFirstRecord || b.FirstRecord == noFirstRecord {
+		return comparison
+	}
+
+	for d := from; !d.After(to); d = d.AddDays(1) {
+		comparison.DaysCompared++
+		outcomeA := storage.OutcomeAt(entries, storage.DailyHabit{Day: d, Habit: a.Name})
+		outcomeB := storage.OutcomeAt(entries, storage.DailyHabit{Day: d, Habit: b.Name})
+		doneA := a.MetGoal(outcomeA)
+		doneB := b.MetGoal(outcomeB)
+		if doneA {
+			comparison.DoneA++
+		}
+		if doneB {
+			comparison.DoneB++
+		}
+		if doneA && doneB {
+			comparison.DoneBoth++
+		}
+	}
+	return comparison
+}
+
+// CompletionRate returns the percentage of tracked, non-skipped days a habit was done
+func CompletionRate(stats HabitStats) float64 {
+	if stats.DaysTracked-stats.Skips <= 0 {
+		return 0
+	}
+	return float64(stats.Streaks) / float64(stats.DaysTracked-stats.Skips) * 100
+}
+
+// ShowComparison displays two habits' graphs, stats, and conditional probabilities side by side
+func (d *Display) ShowComparison(a *storage.Habit, b *storage.Habit, entries storage.History, countBack int, maxHabitNameLength int) {
+	now := d.Today()
+
+	_, calline := graph.BuildSpark(now.AddDays(-countBack), now, []*storage.Habit{}, entries)
+	fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, "")
+	for _, c := range calline {
+		fmt.Fprint(d.out(), c)
+	}
+	fmt.Fprintf(d.out(), "\n")
+	for _, habit := range []*storage.Habit{a, b} {
+		fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, fitName(habit.Name, maxHabitNameLength)+"  ")
+		fmt.Fprint(d.out(), graph.BuildGraphUntil(habit, entries, now, countBack, false))
+		fmt.Fprintf(d.out(), "\n")
+	}
+	fmt.Fprintf(d.out(), "\n")
+
+	for _, habit := range []*storage.Habit{a, b} {
+		stats := BuildStatsUntil(habit, entries, now)
+		fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, fitName(habit.Name, maxHabitNameLength)+"  ")
+		d.colorManager.PrintGreen("Streaks ")
+		d.colorManager.PrintfGreen("%4v", strconv.Itoa(stats.Streaks))
+		d.colorManager.PrintGreen(" days")
+		fmt.Fprintf(d.out(), "%4v", "")
+		d.colorManager.PrintRed("Breaks ")
+		d.colorManager.PrintfRed("%4v", strconv.Itoa(stats.Breaks))
+		d.colorManager.PrintRed(" days")
+		fmt.Fprintf(d.out(), "%4v", "")
+		fmt.Fprintf(d.out(), "Rate ")
+		fmt.Fprintf(d.out(), "%5.1f%%\n", CompletionRate(stats))
+	}
+	fmt.Fprintf(d.out(), "\n")
+
+	comparison := BuildComparison(a, b, entries, now)
+	if comparison.DaysCompared == 0 {
+		fmt.Fprintln(d.out(), "These habits have no days tracked in common yet.")
+		return
+	}
+	fmt.Fprintf(d.out(), "Both done on %d of %d days tracked together.\n", comparison.DoneBoth, comparison.DaysCompared)
+	fmt.Fprintf(d.out(), "P(%s | %s): ", b.Name, a.Name)
+	d.colorManager.PrintfBold("%5.1f%%", comparison.ProbBGivenA())
+	fmt.Fprintf(d.out(), "  (%d of %d days)\n", comparison.DoneBoth, comparison.DoneA)
+	fmt.Fprintf(d.out(), "P(%s | %s): ", a.Name, b.Name)
+	d.colorManager.PrintfBold("%5.1f%%", comparison.ProbAGivenB())
+	fmt.Fprintf(d.out(), "  (%d of %d days)\n", comparison.DoneBoth, comparison.DoneB)
+}
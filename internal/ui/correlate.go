@@ -0,0 +1,234 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// ScorePoint is one day's score beside the amount logged for a tracking habit
+type ScorePoint struct {
+	Day    civil.Date
+	Amount float64
+	Score  float64
+}
+
+// ScoreCorrelation relates daily scores to a tracking habit's amounts over
+// the days both were recorded
+type ScoreCorrelation struct {
+	Habit  string
+	Points []ScorePoint
+	// R is the Pearson correlation of amount and score, NaN when it can't be told
+	R float64
+	// Median splits the days into low and high amounts, with the mean score of each
+	Median    float64
+	LowScore  float64
+	HighScore float64
+}
+
+// minCorrelationDays is how many days are needed before a correlation means anything
+const minCorrelationDays = 3
+
+// BuildScoreCorrelation pairs each day habit was done with an amount with
+// that day's score over the other habits, up to date to
+func BuildScoreCorrelation(habit *storage.Habit, habits []*storage.Habit, entries storage.History, to civil.Date) ScoreCorrelation {
+	correlation := ScoreCorrelation{Habit: habit.Name, R: math.NaN()}
+	var scored []*storage.Habit
+	for _, h := range habits {
+		if h != habit && h.Target > 0 {
+			scored = append(scored, h)
+		}
+	}
+
+	for d := habit.FirstRecord; !d.After(to); d = d.AddDays(1) {
+		outcome, ok := entries.Outcome(storage.DailyHabit{Day: d, Habit: habit.Name})
+		if !ok || outcome.Result != "y" || !anyScoreable(scored, d) {
+			continue
+		}
+		correlation.Points = append(correlation.Points, ScorePoint{Day: d, Amount: outcome.Amount, Score: graph.Score(d, scored, entries)})
+	}
+	if len(correlation.Points) < minCorrelationDays {
+		return correlation
+	}
+
+	amounts := make([]float64, len(correlation.Points))
+	scores := make([]float64, len(correlation.Points))
+	for i, point := range correlation.Points {
+		amounts[i], scores[i] = point.Amount, point.Score
+	}
+	correlation.R = pearson(amounts, scores)
+
+	sorted := append([]float64(nil), amounts...)
+	sort.Float64s(sorted)
+	correlation.Median = sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		correlation.Median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+	var low, high []float64
+	for _, point := range correlation.Points {
+		if point.Amount >= correlation.Median {
+			high = append(high, point.Score)
+		} else {
+			low = append(low, point.Score)
+		}
+	}
+	correlation.LowScore, correlation.HighScore = mean(low), mean(high)
+	return correlation
+}
+
+// anyScoreable reports whether any of habits was being tracked on d
+func anyScoreable(habits []*storage.Habit, d civil.Date) bool {
+	for _, habit := range habits {
+		if !d.Before(habit.FirstRecord) {
+			return true
+		}
+	}
+	return false
+}
+
+// pearson returns the correlation coefficient of xs and ys, or NaN when
+// either doesn't vary
+func pearson(xs, ys []float64) float64 {
+	mx, my := mean(xs), mean(ys)
+	var cov, vx, vy float64
+	for i := range xs {
+		cov += (xs[i] - mx) * (ys[i] - my)
+		vx += (xs[i] - mx) * (xs[i] - mx)
+		vy += (ys[i] - my) * (ys[i] - my)
+	}
+	if vx == 0 || vy == 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total / float64(len(values))
+}
+
+// Strength describes the correlation in words, eg. "moderate positive"
+func (c ScoreCorrelation) Strength() string {
+	r := math.Abs(c.R)
+	var strength string
+	switch {
+	case math.IsNaN(c.R):
+		return "unknown"
+	case r < 0.1:
+		return "no"
+	case r < 0.3:
+		strength = "weak"
+	case r < 0.5:
+		strength = "moderate"
+	default:
+		strength = "strong"
+	}
+	if c.R < 0 {
+		return strength + " negative"
+	}
+	return strength + " positive"
+}
+
+// scatterRows and scatterColumns size the scatter plot of score against amount
+const (
+	scatterRows    = 10
+	scatterColumns = 40
+)
+
+// Scatter plots the points with score up the side and amount along the
+// bottom. Cells show how many days landed in them: · for one, • for a few,
+// and ● for more.
+func (c ScoreCorrelation) Scatter() []string {
+	if len(c.Points) == 0 {
+		return nil
+	}
+	lo, hi := c.Points[0].Amount, c.Points[0].Amount
+	for _, point := range c.Points {
+		lo, hi = min(lo, point.Amount), max(hi, point.Amount)
+	}
+	counts := make([][]int, scatterRows)
+	for i := range counts {
+		counts[i] = make([]int, scatterColumns)
+	}
+	for _, point := range c.Points {
+		column := 0
+		if hi > lo {
+			column = int((point.Amount - lo) / (hi - lo) * (scatterColumns - 1))
+		}
+		row := scatterRows - 1 - int(point.Score/100*(scatterRows-1))
+		// Clamped, as amounts like NaN convert to any int at all
+		counts[max(0, min(row, scatterRows-1))][max(0, min(column, scatterColumns-1))]++
+	}
+
+	var lines []string
+	for i, row := range counts {
+		label := "    "
+		switch i {
+		case 0:
+			label = "100%"
+		case scatterRows - 1:
+			label = "  0%"
+		}
+		var line strings.Builder
+		line.WriteString(label + " │")
+		for _, count := range row {
+			switch {
+			case count == 0:
+				line.WriteString(" ")
+			case count == 1:
+				line.WriteString("·")
+			case count < 4:
+				line.WriteString("•")
+			default:
+				line.WriteString("●")
+			}
+		}
+		lines = append(lines, strings.TrimRight(line.String(), " "))
+	}
+	lines = append(lines, "     └"+strings.Repeat("─", scatterColumns))
+	loLabel, hiLabel := formatAmount(lo), formatAmount(hi)
+	gap := max(1, scatterColumns-len(loLabel)-len(hiLabel))
+	lines = append(lines, "      "+loLabel+strings.Repeat(" ", gap)+hiLabel)
+	return lines
+}
+
+// formatAmount writes an amount without needless decimals, eg. 7 or 7.5
+func formatAmount(amount float64) string {
+	return fmt.Sprintf("%g", math.Round(amount*100)/100)
+}
+
+// ShowScoreCorrelation displays a scatter of daily score against a tracking
+// habit's amounts, with how strongly they're related
+func (d *Display) ShowScoreCorrelation(c ScoreCorrelation) {
+	if len(c.Points) < minCorrelationDays {
+		fmt.Fprintf(d.out(), "Not enough days with an amount logged for %s yet (%d of %d needed).\n", c.Habit, len(c.Points), minCorrelationDays)
+		return
+	}
+	fmt.Fprintf(d.out(), "Daily score against %s over %d days\n\n", c.Habit, len(c.Points))
+	for _, line := range c.Scatter() {
+		fmt.Fprintln(d.out(), line)
+	}
+	fmt.Fprintln(d.out())
+
+	if math.IsNaN(c.R) {
+		fmt.Fprintf(d.out(), "%s or your score didn't change over these days, so there's nothing to correlate.\n", c.Habit)
+		return
+	}
+	fmt.Fprint(d.out(), "Correlation: ")
+	d.colorManager.PrintfBold("r = %.2f", c.R)
+	fmt.Fprintf(d.out(), " (%s)\n", c.Strength())
+	if !math.IsNaN(c.LowScore) {
+		fmt.Fprintf(d.out(), "Days with %s below %s averaged %.1f%%, at or above it %.1f%%.\n", c.Habit, formatAmount(c.Median), c.LowScore, c.HighScore)
+	}
+}
@@ -2,27 +2,131 @@ package ui
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/clock"
 	"github.com/wakatara/harsh/internal/graph"
 	"github.com/wakatara/harsh/internal/storage"
+	"golang.org/x/term"
 )
 
 // HabitStats holds total stats for a Habit in the file
 type HabitStats struct {
-	DaysTracked int
-	Total       float64
-	Streaks     int
-	Breaks      int
-	Skips       int
+	DaysTracked int     `json:"days_tracked"`
+	Total       float64 `json:"total"`
+	Streaks     int     `json:"streaks"`
+	Breaks      int     `json:"breaks"`
+	Skips       int     `json:"skips"`
+	// Fallbacks are the done days done the habit's easier fallback way
+	Fallbacks int `json:"fallbacks"`
+	// Short are the days done but under the habit's amount goal, which
+	// count neither as streaks nor breaks
+	Short int `json:"short"`
+	// CurrentStreak is how many days in a row up to now the habit was done
+	// or on target, and LongestStreak the most it's ever been
+	CurrentStreak int `json:"current_streak"`
+	LongestStreak int `json:"longest_streak"`
+	// Consistency scores how evenly spaced completions are, from 0 to 100.
+	// It is 0 when there are too few completions to tell.
+	Consistency float64 `json:"consistency"`
+	// MonthPercentile is the share of the habit's earlier months whose
+	// completion rate this month's so far beats, from 0 to 100. It is nil
+	// until there are MinPercentileMonths earlier months to compare with.
+	MonthPercentile *float64 `json:"month_percentile,omitempty"`
+	// MonthRate is this month's completion rate so far
+	MonthRate float64 `json:"month_rate"`
 }
 
+// MinPercentileMonths is how many earlier months a habit needs before this
+// month is ranked against them
+const MinPercentileMonths = 3
+
+// MonthRate holds the completion rate of a habit over one calendar month
+type MonthRate struct {
+	Month civil.Date
+	Days  int
+	Rate  float64
+}
+
+// RollingScoreDays are the windows averaged in the log footer
+var RollingScoreDays = []int{7, 30, 365}
+
 // Display handles the formatting and output of habit information
 type Display struct {
 	colorManager *ColorManager
+	// Amounts draws days with amounts in graphs as bars scaled to the habit's largest amount
+	Amounts bool
+	// Quotes are the motivational quotes shown once todos are done, if any
+	Quotes *storage.Quotes
+	// StaleAfterDays flags habits in todos that have gone unlogged for longer, 0 never flags
+	StaleAfterDays int
+	// Notes are the dated notes from the log, marked and listed in the log view
+	Notes storage.Notes
+	// ScoreGoal colors scores green at or above it, amber within
+	// ScoreGoalMargin below it, and red under that. 0 leaves scores plain.
+	ScoreGoal float64
+	// Scores are daily scores already worked out for the footer's averages,
+	// added to as more are. Nil works every one out.
+	Scores map[civil.Date]float64
+	// Date is the day views treat as today, the current date when unset
+	Date civil.Date
+	// Width is the terminal width views fit to, measured from stdout when unset
+	Width int
+	// Snoozed habits are left out of todos on their day
+	Snoozed storage.Snoozed
+	// MonthShading shades every other month in graphs so month boundaries
+	// stay legible over long countbacks. It needs colors.
+	MonthShading bool
+	// ShowHidden includes habits with the hidden option in the log
+	ShowHidden bool
+	// Sort is the order the log shows habits in, SortFile or SortRisk. Unset
+	// keeps the habits file's order.
+	Sort string
+	// Challenges running are shown under the log and emphasized in todos
+	Challenges storage.Challenges
+	// Summaries follow each graph with a line saying how the last
+	// SummaryDays went, for screen readers and braille displays
+	Summaries bool
+	// DaysSince adds how many days it's been since each habit was last done
+	// to the log and todos
+	DaysSince bool
+	// WindowProgress adds how often interval habits have been done in their
+	// current window, eg. "2/3 last 7 days", to the log and todos
+	WindowProgress bool
+}
+
+// DaysSinceWidth is the width of the log's days since column, space included
+const DaysSinceWidth = 6
+
+// WindowProgressWidth is the width of the log's window progress column,
+// space included
+const WindowProgressWidth = 11
+
+// SummaryDays is the window graph summaries describe
+const SummaryDays = 7
+
+// ScoreGoalMargin is how far below the score goal still shows as amber
+const ScoreGoalMargin = 20.0
+
+// GoalMonth counts the days in a calendar month whose score reached the goal
+type GoalMonth struct {
+	Month  civil.Date
+	Days   int
+	AtGoal int
+}
+
+// StaleHabit is a habit that has gone without any entries, done or not, for a while
+type StaleHabit struct {
+	Habit     *storage.Habit
+	LastEntry civil.Date
+	Days      int
 }
 
 // NewDisplay creates a new display handler
@@ -32,121 +136,522 @@ func NewDisplay(noColor bool) *Display {
 	}
 }
 
-// ShowHabitLog displays the habit log with sparkline and graphs
-func (d *Display) ShowHabitLog(habits []*storage.Habit, entries *storage.Entries, countBack int, maxHabitNameLength int, habitFragment string) {
-	// Filter habits by fragment if provided
-	filteredHabits := []*storage.Habit{}
-	if len(strings.TrimSpace(habitFragment)) > 0 {
-		for _, habit := range habits {
-			if strings.Contains(strings.ToLower(habit.Name), strings.ToLower(habitFragment)) {
-				filteredHabits = append(filteredHabits, habit)
-			}
-		}
-	} else {
-		filteredHabits = habits
+// NewDeterministicDisplay creates a display whose output depends only on the
+// data shown: no colors, today fixed to date, and fitted to width columns.
+// Scripts and golden-file tests can rely on its formatting staying stable.
+func NewDeterministicDisplay(date civil.Date, width int) *Display {
+	d := NewDisplay(true)
+	d.Date = date
+	d.Width = width
+	return d
+}
+
+// SetOutput sends what the display shows to w rather than stdout, eg. a
+// buffer to draw a frame in
+func (d *Display) SetOutput(w io.Writer) {
+	d.colorManager.SetOutput(w)
+}
+
+// out is where the display's output goes
+func (d *Display) out() io.Writer {
+	return d.colorManager.writer()
+}
+
+// Today returns the day the display treats as today
+func (d *Display) Today() civil.Date {
+	if d.Date != (civil.Date{}) {
+		return d.Date
 	}
+	return clock.Today()
+}
+
+// TerminalWidth returns the width the display fits its views to
+func (d *Display) TerminalWidth() int {
+	if d.Width > 0 {
+		return d.Width
+	}
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 120
+	}
+	return width
+}
+
+// ShowHabitLog displays the habit log with sparkline and graphs ending on date to
+func (d *Display) ShowHabitLog(habits []*storage.Habit, entries storage.History, to civil.Date, countBack int, maxHabitNameLength int, habitFragment string) {
+	filteredHabits := d.sortHabits(d.logHabits(habits, habitFragment), entries, to)
 
-	now := civil.DateOf(time.Now())
-	to := now
 	from := to.AddDays(-countBack)
 
 	// Build sparkline
 	sparkline, calline := graph.BuildSpark(from, to, habits, entries)
-	fmt.Printf("%*v", maxHabitNameLength, "")
-	fmt.Print(strings.Join(sparkline, ""))
-	fmt.Printf("\n")
-	fmt.Printf("%*v", maxHabitNameLength, "")
-	fmt.Print(strings.Join(calline, ""))
-	fmt.Printf("\n")
+	if d.MonthShading {
+		sparkline = []string{d.shadeMonths(sparkline, from)}
+		calline = []string{d.shadeMonths(calline, from)}
+	}
+	fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, "")
+	fmt.Fprint(d.out(), strings.Join(sparkline, ""))
+	fmt.Fprintf(d.out(), "\n")
+	fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, "")
+	fmt.Fprint(d.out(), strings.Join(calline, ""))
+	fmt.Fprintf(d.out(), "\n")
+	noteDays := d.Notes.SortedDays(from, to)
+	if len(noteDays) > 0 {
+		fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, "")
+		fmt.Fprint(d.out(), noteMarkers(noteDays, from, to))
+		fmt.Fprintf(d.out(), "\n")
+	}
 
 	// Build graphs in parallel
-	graphResults := graph.BuildGraphsParallel(filteredHabits, entries, countBack, false)
+	graphResults := graph.BuildGraphsParallelUntil(filteredHabits, entries, to, countBack, false)
 
 	heading := ""
 	for _, habit := range filteredHabits {
-		if heading != habit.Heading {
+		// Sorted by risk, habits no longer fall under their headings
+		if heading != habit.Heading && d.Sort != SortRisk {
 			d.colorManager.PrintfBold("%s\n", habit.Heading)
 			heading = habit.Heading
 		}
-		fmt.Printf("%*v", maxHabitNameLength, habit.Name+"  ")
-		fmt.Print(graphResults[habit.Name])
-		fmt.Printf("\n")
+		fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, fitName(habit.Name, maxHabitNameLength)+"  ")
+		fmt.Fprint(d.out(), d.graphLine(graphResults[habit.Name], habit, entries, to))
+		if d.DaysSince {
+			d.printDaysSince(fmt.Sprintf(" %*v", DaysSinceWidth-1, daysSinceLabel(habit, entries, to)), habit, entries, to)
+		}
+		if d.WindowProgress && HasWindow(habit) {
+			d.printWindowProgress(fmt.Sprintf(" %*v", WindowProgressWidth-1, windowLabel(habit, entries, to, true)), habit, entries, to)
+		}
+		fmt.Fprintf(d.out(), "\n")
+		d.showSummary(habit, entries, to, maxHabitNameLength)
+	}
+
+	if active := d.Challenges.Active(to); len(active) > 0 {
+		d.colorManager.PrintfBold("Challenges\n")
+		d.ShowChallenges(active, entries, to, maxHabitNameLength)
+	}
+
+	if len(noteDays) > 0 {
+		fmt.Fprintln(d.out())
+		for _, day := range noteDays {
+			for _, note := range d.Notes[day] {
+				d.colorManager.PrintfBold("%s", day.String())
+				fmt.Fprintf(d.out(), " %s\n", note)
+			}
+		}
 	}
 
 	// Show scores and undone count
-	undone := GetTodos(habits, entries, now, 7)
+	undone := GetTodos(habits, entries, to, 7)
 	var undoneCount int
 	for _, v := range undone {
 		undoneCount += len(v)
 	}
 
-	yscore := fmt.Sprintf("%.1f", graph.Score(now.AddDays(-1), habits, entries))
-	tscore := fmt.Sprintf("%.1f", graph.Score(now, habits, entries))
-	fmt.Printf("\n" + "Yesterday's Score: ")
-	fmt.Printf("%8v", yscore)
-	fmt.Printf("%%\n")
-	fmt.Printf("Today's Score: ")
-	fmt.Printf("%12v", tscore)
-	fmt.Printf("%%\n")
+	yscore, yok := graph.DayScore(to.AddDays(-1), habits, entries)
+	tscore, tok := graph.DayScore(to, habits, entries)
+	upTo := "today"
+	if to == d.Today() {
+		fmt.Fprintf(d.out(), "\n"+"Yesterday's Score: ")
+		d.printDayScore(8, yscore, yok)
+		fmt.Fprintf(d.out(), "Today's Score: ")
+		d.printDayScore(12, tscore, tok)
+	} else {
+		upTo = to.String()
+		fmt.Fprint(d.out(), "\n"+"Score on "+to.AddDays(-1).String()+": ")
+		d.printDayScore(6, yscore, yok)
+		fmt.Fprint(d.out(), "Score on "+to.String()+": ")
+		d.printDayScore(6, tscore, tok)
+	}
+
+	// Longer-horizon averages up to the last full day
+	averages := graph.CachedAverageScores(to.AddDays(-1), RollingScoreDays, habits, entries, d.Scores)
+	for i, days := range RollingScoreDays {
+		label := fmt.Sprintf("%d-day average: ", days)
+		fmt.Fprint(d.out(), label)
+		d.printScore(27-len(label), averages[i])
+	}
+	if d.ScoreGoal > 0 {
+		if month, ok := CurrentGoalMonth(habits, entries, to, d.ScoreGoal); ok {
+			label := "Days at goal this month: "
+			fmt.Fprint(d.out(), label)
+			fmt.Fprintf(d.out(), "%*v\n", 28-len(label), fmt.Sprintf("%d/%d", month.AtGoal, month.Days))
+		}
+	}
 	if undoneCount == 0 {
-		fmt.Printf("All habits logged up to today.")
+		fmt.Fprint(d.out(), "All habits logged up to "+upTo+".")
 	} else {
-		fmt.Printf("Total unlogged habits: ")
-		fmt.Printf("%2v", undoneCount)
+		fmt.Fprintf(d.out(), "Total unlogged habits: ")
+		fmt.Fprintf(d.out(), "%2v", undoneCount)
+	}
+	fmt.Fprintf(d.out(), "\n")
+}
+
+// ShowCompactLog displays one line per habit with just its name and graph,
+// without sparkline, headings, or scores, for embedding in popups and scripts
+func (d *Display) ShowCompactLog(habits []*storage.Habit, entries storage.History, to civil.Date, countBack int, nameWidth int, habitFragment string) {
+	filteredHabits := d.sortHabits(d.logHabits(habits, habitFragment), entries, to)
+	graphResults := graph.BuildGraphsParallelUntil(filteredHabits, entries, to, countBack, false)
+	for _, habit := range filteredHabits {
+		fmt.Fprintf(d.out(), "%*v", nameWidth, fitName(habit.Name, nameWidth+storage.HabitNamePadding))
+		fmt.Fprint(d.out(), " ")
+		fmt.Fprint(d.out(), d.graphLine(graphResults[habit.Name], habit, entries, to))
+		fmt.Fprintf(d.out(), "\n")
+		d.showSummary(habit, entries, to, nameWidth)
+	}
+}
+
+// printScore prints a score percentage right-aligned in width, colored
+// against ScoreGoal when one is set
+func (d *Display) printScore(width int, score float64) {
+	switch {
+	case d.ScoreGoal <= 0:
+		fmt.Fprintf(d.out(), "%*.1f%%", width, score)
+	case score >= d.ScoreGoal:
+		d.colorManager.PrintfGreen("%*.1f%%", width, score)
+	case score >= d.ScoreGoal-ScoreGoalMargin:
+		d.colorManager.PrintfYellow("%*.1f%%", width, score)
+	default:
+		d.colorManager.PrintfRed("%*.1f%%", width, score)
+	}
+	fmt.Fprintf(d.out(), "\n")
+}
+
+// printDayScore prints a day's score like printScore, or graph.EmptyScore
+// when there was nothing to score and graph.EmptyDays doesn't make it 100%
+func (d *Display) printDayScore(width int, score float64, ok bool) {
+	switch {
+	case ok:
+		d.printScore(width, score)
+	case graph.EmptyDays == graph.EmptyDaysPerfect:
+		d.printScore(width, 100)
+	default:
+		// Lined up with the digits before the % of other scores
+		fmt.Fprintf(d.out(), "%*s \n", width, graph.EmptyScore)
+	}
+}
+
+// BuildGoalMonths counts, for each calendar month up to and including the
+// month of to, how many days with scoreable habits reached the score goal.
+// Days with nothing to score aren't counted unless graph.EmptyDays makes
+// them 100%.
+func BuildGoalMonths(habits []*storage.Habit, entries storage.History, to civil.Date, goal float64) []GoalMonth {
+	return goalMonths(habits, entries, firstScored(habits, to), to, goal)
+}
+
+// CurrentGoalMonth is the last of BuildGoalMonths, counted without going
+// through the months before it. It's false before anything was scoreable.
+func CurrentGoalMonth(habits []*storage.Habit, entries storage.History, to civil.Date, goal float64) (GoalMonth, bool) {
+	from := civil.Date{Year: to.Year, Month: to.Month, Day: 1}
+	if first := firstScored(habits, to); first.After(from) {
+		from = first
+	}
+	months := goalMonths(habits, entries, from, to, goal)
+	if len(months) == 0 {
+		return GoalMonth{}, false
 	}
-	fmt.Printf("\n")
+	return months[0], true
+}
+
+// firstScored is the first day a scoreable habit was recorded, or the day
+// after to when none was by then
+func firstScored(habits []*storage.Habit, to civil.Date) civil.Date {
+	noFirstRecord := civil.Date{Year: 0, Month: 0, Day: 0}
+	from := to.AddDays(1)
+	for _, habit := range habits {
+		if habit.Target > 0 && habit.FirstRecord != noFirstRecord && habit.FirstRecord.Before(from) {
+			from = habit.FirstRecord
+		}
+	}
+	return from
+}
+
+// goalMonths counts the days at goal from from up to and including to, by
+// calendar month
+func goalMonths(habits []*storage.Habit, entries storage.History, from civil.Date, to civil.Date, goal float64) []GoalMonth {
+	var months []GoalMonth
+	for day := from; !day.After(to); day = day.AddDays(1) {
+		month := civil.Date{Year: day.Year, Month: day.Month, Day: 1}
+		if len(months) == 0 || months[len(months)-1].Month != month {
+			months = append(months, GoalMonth{Month: month})
+		}
+		current := &months[len(months)-1]
+		score, ok := graph.DayScore(day, habits, entries)
+		if !ok {
+			if graph.EmptyDays != graph.EmptyDaysPerfect {
+				continue
+			}
+			score = 100
+		}
+		current.Days++
+		if score >= goal {
+			current.AtGoal++
+		}
+	}
+	return months
+}
+
+// ShowGoalMonths displays how many days reached the score goal in each month
+func (d *Display) ShowGoalMonths(habits []*storage.Habit, entries storage.History) {
+	if d.ScoreGoal <= 0 {
+		fmt.Fprintln(d.out(), "No score goal set. Set one with `harsh config set score_goal 80`.")
+		return
+	}
+	months := BuildGoalMonths(habits, entries, d.Today(), d.ScoreGoal)
+	d.colorManager.PrintfBold("Days at %.0f%% score goal\n", d.ScoreGoal)
+	for _, month := range months {
+		fmt.Fprintf(d.out(), "%s  ", monthLabel(month.Month))
+		fmt.Fprint(d.out(), graph.SparkFor(float64(month.AtGoal)/float64(month.Days)*100))
+		fmt.Fprintf(d.out(), "  %2d of %2d days\n", month.AtGoal, month.Days)
+	}
+}
+
+// noteMarkers returns a line with a ^ under each day from from to to that has notes
+func noteMarkers(noteDays []civil.Date, from civil.Date, to civil.Date) string {
+	markers := []rune(strings.Repeat(" ", to.DaysSince(from)+1))
+	for _, day := range noteDays {
+		markers[day.DaysSince(from)] = '^'
+	}
+	return strings.TrimRight(string(markers), " ")
+}
+
+// graphLine applies the display's graph options to a habit's graph ending on date to
+func (d *Display) graphLine(consistency string, habit *storage.Habit, entries storage.History, to civil.Date) string {
+	if habit.GraphDays > len([]rune(consistency)) {
+		// Compressed graphs have several days per cell, so nothing lines up by day
+		return consistency
+	}
+	// Amount bars only make sense among the default blocks
+	if graph.Symbols == graph.Blocks && d.Amounts {
+		consistency = graph.AmountOverlay(consistency, habit, entries, to)
+	}
+	consistency = graph.MarkNotes(consistency, habit, d.Notes, to)
+	if d.MonthShading {
+		days := strings.Split(consistency, "")
+		consistency = d.shadeMonths(days, to.AddDays(-len(days)+1))
+	}
+	return consistency
+}
+
+// shadeMonths joins days, the first on date from, shading every other
+// calendar month so month boundaries stand out in long graphs
+func (d *Display) shadeMonths(days []string, from civil.Date) string {
+	var line, run strings.Builder
+	shaded := false
+	for i, day := range days {
+		if shade := from.AddDays(i).Month%2 == 0; shade != shaded {
+			line.WriteString(d.shadeRun(run.String(), shaded))
+			run.Reset()
+			shaded = shade
+		}
+		run.WriteString(day)
+	}
+	line.WriteString(d.shadeRun(run.String(), shaded))
+	return line.String()
+}
+
+func (d *Display) shadeRun(run string, shaded bool) string {
+	if !shaded || run == "" {
+		return run
+	}
+	return d.colorManager.Shade(run)
+}
+
+// showSummary prints habit's summary under its graph, when Summaries are on
+func (d *Display) showSummary(habit *storage.Habit, entries storage.History, to civil.Date, indent int) {
+	if d.Summaries {
+		fmt.Fprintf(d.out(), "%*v  %s\n", indent, "", HabitSummary(habit, entries, to, SummaryDays))
+	}
+}
+
+// HabitSummary describes in words how habit went over the days up to to,
+// eg. "Run: 5 of last 7 days, 1 skipped"
+func HabitSummary(habit *storage.Habit, entries storage.History, to civil.Date, days int) string {
+	var done, skipped int
+	for d := to.AddDays(-days + 1); !d.After(to); d = d.AddDays(1) {
+		switch storage.OutcomeAt(entries, storage.DailyHabit{Day: d, Habit: habit.Name}).Result {
+		case "y":
+			done++
+		case "s":
+			skipped++
+		}
+	}
+	summary := fmt.Sprintf("%s: %d of last %d days", habit.Name, done, days)
+	if skipped > 0 {
+		summary += fmt.Sprintf(", %d skipped", skipped)
+	}
+	if graph.Warning(to, habit, entries) {
+		summary += ", about to break"
+	}
+	return summary
+}
+
+// logHabits returns the habits the log shows: those matching habitFragment,
+// less hidden ones unless ShowHidden is set or a fragment picked them out
+func (d *Display) logHabits(habits []*storage.Habit, habitFragment string) []*storage.Habit {
+	filteredHabits := filterHabits(habits, habitFragment)
+	if d.ShowHidden || strings.TrimSpace(habitFragment) != "" {
+		return filteredHabits
+	}
+	return slices.DeleteFunc(slices.Clone(filteredHabits), func(habit *storage.Habit) bool { return habit.Hidden })
+}
+
+// filterHabits returns the habits whose names contain fragment, or all habits for an empty fragment
+func filterHabits(habits []*storage.Habit, habitFragment string) []*storage.Habit {
+	if len(strings.TrimSpace(habitFragment)) == 0 {
+		return habits
+	}
+	filteredHabits := []*storage.Habit{}
+	for _, habit := range habits {
+		if strings.Contains(strings.ToLower(habit.Name), strings.ToLower(habitFragment)) {
+			filteredHabits = append(filteredHabits, habit)
+		}
+	}
+	return filteredHabits
 }
 
 // ShowHabitStats displays statistics for all habits
-func (d *Display) ShowHabitStats(habits []*storage.Habit, entries *storage.Entries, maxHabitNameLength int) {
+func (d *Display) ShowHabitStats(habits []*storage.Habit, entries storage.History, maxHabitNameLength int) {
 	heading := ""
 	for _, habit := range habits {
 		if heading != habit.Heading {
 			d.colorManager.PrintfBold("\n%s\n", habit.Heading)
 			heading = habit.Heading
 		}
-		stats := BuildStats(habit, entries)
-		fmt.Printf("%*v", maxHabitNameLength, habit.Name+"  ")
+		stats := BuildStatsUntil(habit, entries, d.Today())
+		fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, fitName(habit.Name, maxHabitNameLength)+"  ")
 		d.colorManager.PrintGreen("Streaks ")
 		d.colorManager.PrintfGreen("%4v", strconv.Itoa(stats.Streaks))
 		d.colorManager.PrintGreen(" days")
-		fmt.Printf("%4v", "")
+		fmt.Fprintf(d.out(), "%4v", "")
+		d.colorManager.PrintGreen("Longest ")
+		d.colorManager.PrintfGreen("%4v", strconv.Itoa(stats.LongestStreak))
+		fmt.Fprintf(d.out(), "%4v", "")
+		d.colorManager.PrintGreen("Current ")
+		d.colorManager.PrintfGreen("%4v", strconv.Itoa(stats.CurrentStreak))
+		fmt.Fprintf(d.out(), "%4v", "")
 		d.colorManager.PrintRed("Breaks ")
 		d.colorManager.PrintfRed("%4v", strconv.Itoa(stats.Breaks))
 		d.colorManager.PrintRed(" days")
-		fmt.Printf("%4v", "")
+		fmt.Fprintf(d.out(), "%4v", "")
 		d.colorManager.PrintYellow("Skips ")
 		d.colorManager.PrintfYellow("%4v", strconv.Itoa(stats.Skips))
 		d.colorManager.PrintYellow(" days")
-		fmt.Printf("%4v", "")
-		fmt.Printf("Tracked ")
-		fmt.Printf("%4v", strconv.Itoa(stats.DaysTracked))
-		fmt.Printf(" days")
+		fmt.Fprintf(d.out(), "%4v", "")
+		fmt.Fprintf(d.out(), "Tracked ")
+		fmt.Fprintf(d.out(), "%4v", strconv.Itoa(stats.DaysTracked))
+		fmt.Fprintf(d.out(), " days")
+		fmt.Fprintf(d.out(), "%4v", "")
+		fmt.Fprintf(d.out(), "Consistency ")
+		if stats.Consistency == 0 {
+			fmt.Fprintf(d.out(), "%4v", "-")
+		} else {
+			fmt.Fprintf(d.out(), "%3.0f%%", stats.Consistency)
+		}
 		if stats.Total == 0 {
-			fmt.Printf("%4v", "")
-			fmt.Printf("      ")
-			fmt.Printf("%5v", "")
-			fmt.Printf("     \n")
+			fmt.Fprintf(d.out(), "%4v", "")
+			fmt.Fprintf(d.out(), "      ")
+			fmt.Fprintf(d.out(), "%5v", "")
+			fmt.Fprintf(d.out(), "     \n")
 		} else {
-			fmt.Printf("%4v", "")
+			fmt.Fprintf(d.out(), "%4v", "")
 			d.colorManager.PrintBlue("Total ")
 			d.colorManager.PrintfBlue("%5v", (stats.Total))
 			d.colorManager.PrintBlue("     \n")
 		}
+		if fitName(habit.Name, maxHabitNameLength) != habit.Name {
+			// Show truncated names in full underneath, like a tooltip
+			fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, "")
+			fmt.Fprintf(d.out(), "↳ %s\n", habit.Name)
+		}
+		if habit.Fallback != "" || stats.Fallbacks > 0 {
+			fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, "")
+			fmt.Fprintf(d.out(), "↳ %d days done the fallback way", stats.Fallbacks)
+			if habit.Fallback != "" {
+				fmt.Fprintf(d.out(), " (%s)", habit.Fallback)
+			}
+			fmt.Fprintln(d.out())
+		}
+		if habit.AmountGoal > 0 || stats.Short > 0 {
+			fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, "")
+			fmt.Fprintf(d.out(), "↳ %d days logged under the goal of %v\n", stats.Short, habit.AmountGoal)
+		}
+		if stats.MonthPercentile != nil {
+			fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, "")
+			fmt.Fprintf(d.out(), "↳ %.0f%% this month, better than %.0f%% of your months\n", stats.MonthRate, *stats.MonthPercentile)
+		}
 	}
 }
 
+// ShowHabitAge displays each habit's completion rate month by month since its first record
+func (d *Display) ShowHabitAge(habits []*storage.Habit, entries storage.History, maxHabitNameLength int) {
+	now := d.Today()
+	habitRates := make(map[string][]MonthRate, len(habits))
+	sparkWidth := 0
+	for _, habit := range habits {
+		habitRates[habit.Name] = BuildMonthlyRates(habit, entries, now)
+		sparkWidth = max(sparkWidth, len(habitRates[habit.Name]))
+	}
+
+	heading := ""
+	for _, habit := range habits {
+		rates := habitRates[habit.Name]
+		if len(rates) == 0 {
+			continue
+		}
+		if heading != habit.Heading {
+			d.colorManager.PrintfBold("\n%s\n", habit.Heading)
+			heading = habit.Heading
+		}
+		var sparkline strings.Builder
+		for _, rate := range rates {
+			sparkline.WriteString(graph.SparkFor(rate.Rate))
+		}
+		first, last := rates[0], rates[len(rates)-1]
+		fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, fitName(habit.Name, maxHabitNameLength)+"  ")
+		fmt.Fprintf(d.out(), "%-*v", sparkWidth, sparkline.String())
+		fmt.Fprintf(d.out(), "%4v", "")
+		fmt.Fprintf(d.out(), "%s %5.1f%%", monthLabel(first.Month), first.Rate)
+		switch {
+		case last.Rate > first.Rate:
+			d.colorManager.PrintGreen("  →  ")
+		case last.Rate < first.Rate:
+			d.colorManager.PrintRed("  →  ")
+		default:
+			fmt.Fprintf(d.out(), "  →  ")
+		}
+		fmt.Fprintf(d.out(), "%s %5.1f%%", monthLabel(last.Month), last.Rate)
+		fmt.Fprintf(d.out(), "%4v", "")
+		fmt.Fprintf(d.out(), "(%d months)\n", len(rates))
+	}
+}
+
+// fitName truncates a habit name with an ellipsis so it fits a name column of
+// width, which includes the padding habit name columns keep on the left
+func fitName(name string, width int) string {
+	limit := width - storage.HabitNamePadding
+	runes := []rune(name)
+	if limit < 1 || len(runes) <= limit {
+		return name
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// monthLabel formats the first day of a month as e.g. "Mar 2025"
+func monthLabel(month civil.Date) string {
+	return month.In(time.UTC).Format("Jan 2006")
+}
+
 // ShowTodos displays undone habits for today and recent days
-func (d *Display) ShowTodos(habits []*storage.Habit, entries *storage.Entries, maxHabitNameLength int) {
-	now := civil.DateOf(time.Now())
-	undone := GetTodos(habits, entries, now, 8)
+func (d *Display) ShowTodos(habits []*storage.Habit, entries storage.History, maxHabitNameLength int) {
+	now := d.Today()
+	undone := withoutSnoozed(GetTodos(habits, entries, now, 8), d.Snoozed)
 
 	heading := ""
 	if len(undone) == 0 {
-		fmt.Println("All todos logged up to today.")
+		fmt.Fprintln(d.out(), "All todos logged up to today.")
+		d.ShowQuote(habits, entries, now)
 	} else {
 		for date, todos := range undone {
-			t, _ := time.Parse(time.DateOnly, date)
+			t, _ := civil.ParseDate(date)
 			dayOfWeek := t.Weekday().String()[:3]
 			d.colorManager.PrintlnBold(date + " " + dayOfWeek + ":")
 			for _, habit := range habits {
@@ -156,16 +661,247 @@ func (d *Display) ShowTodos(habits []*storage.Habit, entries *storage.Entries, m
 						heading = habit.Heading
 					}
 					if habit.Name == todo {
-						fmt.Printf("%*v", maxHabitNameLength, todo+"\n")
+						if note := challengeNote(d.Challenges, todo, t); note != "" {
+							// Other names count their newline in the padding, so align with them
+							d.colorManager.PrintfBold("%*v", maxHabitNameLength-1, fitName(todo, maxHabitNameLength))
+							d.colorManager.PrintfYellow("  %s\n", note)
+							continue
+						}
+						// The countdown is for the window running now, so only today's todos have it
+						daysLeft, due := DaysLeft(habit, entries, now)
+						due = due && t == now
+						if progress := d.WindowProgress && HasWindow(habit); d.DaysSince || progress || due {
+							fmt.Fprintf(d.out(), "%*v", maxHabitNameLength-1, fitName(todo, maxHabitNameLength))
+							if progress {
+								d.printWindowProgress("  "+windowLabel(habit, entries, t, false), habit, entries, t)
+							}
+							if due {
+								d.printDaysLeft("  "+daysLeftLabel(daysLeft), daysLeft, habit, entries, now)
+							}
+							if d.DaysSince {
+								d.printDaysSince("  "+lastDoneLabel(habit, entries, now), habit, entries, now)
+							}
+							fmt.Fprintln(d.out())
+							continue
+						}
+						fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, fitName(todo, maxHabitNameLength)+"\n")
 					}
 				}
 			}
 		}
 	}
+	if d.Snoozed.Day == now && len(d.Snoozed.Habits) > 0 {
+		fmt.Fprintf(d.out(), "\nSnoozed today: %s\n", strings.Join(d.Snoozed.Habits, ", "))
+	}
+	d.showStale(habits, entries, now, maxHabitNameLength)
+}
+
+// withoutSnoozed drops the snoozed habits from the todos of their day
+func withoutSnoozed(todos map[string][]string, snoozed storage.Snoozed) map[string][]string {
+	day := snoozed.Day.String()
+	if len(snoozed.Habits) == 0 || len(todos[day]) == 0 {
+		return todos
+	}
+	remaining := slices.DeleteFunc(slices.Clone(todos[day]), snoozed.Has)
+	if len(remaining) == 0 {
+		delete(todos, day)
+	} else {
+		todos[day] = remaining
+	}
+	return todos
+}
+
+// StaleHabits returns the habits with no entries at all in the more than
+// afterDays days up to date to. Habits never logged and tracking habits
+// (frequency 0), which are only logged when they happen, are left out.
+func StaleHabits(habits []*storage.Habit, entries storage.History, to civil.Date, afterDays int) []StaleHabit {
+	noFirstRecord := civil.Date{Year: 0, Month: 0, Day: 0}
+	var stale []StaleHabit
+	for _, habit := range habits {
+		if habit.Target == 0 || habit.FirstRecord == noFirstRecord || habit.FirstRecord.After(to) {
+			continue
+		}
+		last := habit.FirstRecord
+		for d := to; d.After(habit.FirstRecord); d = d.AddDays(-1) {
+			if _, ok := entries.Outcome(storage.DailyHabit{Day: d, Habit: habit.Name}); ok {
+				last = d
+				break
+			}
+		}
+		if days := to.DaysSince(last); days > afterDays {
+			stale = append(stale, StaleHabit{Habit: habit, LastEntry: last, Days: days})
+		}
+	}
+	return stale
+}
+
+// DaysSinceDone returns how many days before to habit was last done, and
+// false when it hasn't been done since its first record
+func DaysSinceDone(habit *storage.Habit, entries storage.History, to civil.Date) (int, bool) {
+	if habit.FirstRecord == (civil.Date{}) {
+		return 0, false
+	}
+	for d := to; !d.Before(habit.FirstRecord); d = d.AddDays(-1) {
+		if habit.MetGoal(storage.OutcomeAt(entries, storage.DailyHabit{Day: d, Habit: habit.Name})) {
+			return to.DaysSince(d), true
+		}
+	}
+	return 0, false
+}
+
+// daysSinceLabel shortens the days since habit was last done for the log's
+// column, eg. "3d", or "-" if it never was
+func daysSinceLabel(habit *storage.Habit, entries storage.History, to civil.Date) string {
+	days, ok := DaysSinceDone(habit, entries, to)
+	switch {
+	case !ok:
+		return "-"
+	case days > 9999:
+		return "9999+"
+	}
+	return strconv.Itoa(days) + "d"
+}
+
+// lastDoneLabel describes when habit was last done for todos
+func lastDoneLabel(habit *storage.Habit, entries storage.History, to civil.Date) string {
+	days, ok := DaysSinceDone(habit, entries, to)
+	switch {
+	case !ok:
+		return "never done"
+	case days == 0:
+		return "done today"
+	case days == 1:
+		return "last done yesterday"
+	}
+	return fmt.Sprintf("last done %d days ago", days)
+}
+
+// printDaysSince prints label in yellow once habit has gone longer undone
+// than its interval, as it's lapsing
+func (d *Display) printDaysSince(label string, habit *storage.Habit, entries storage.History, to civil.Date) {
+	days, ok := DaysSinceDone(habit, entries, to)
+	if habit.Target > 0 && (!ok || days >= habit.Interval) {
+		d.colorManager.PrintfYellow("%s", label)
+		return
+	}
+	fmt.Fprint(d.out(), label)
+}
+
+// HasWindow reports whether habit has a target to meet over more than a
+// day, so progress through its window means something
+func HasWindow(habit *storage.Habit) bool {
+	return habit.Target > 0 && habit.Interval > 1
+}
+
+// WindowProgress returns how many times habit was done in the interval
+// ending on day to, counted the way its target is
+func WindowProgress(habit *storage.Habit, entries storage.History, to civil.Date) int {
+	done := 0
+	var lastCounted civil.Date
+	for d := to.AddDays(-habit.Interval + 1); !d.After(to); d = d.AddDays(1) {
+		outcome, ok := entries.Outcome(storage.DailyHabit{Day: d, Habit: habit.Name})
+		if !ok || outcome.Result != "y" {
+			continue
+		}
+		// Completions without enough rest since the last one don't count
+		if done > 0 && d.DaysSince(lastCounted) <= habit.MinGap {
+			continue
+		}
+		lastCounted = d
+		done += habit.Completions(outcome)
+	}
+	return done
+}
+
+// windowLabel describes habit's progress through the window ending on to,
+// eg. "2/3 last 7 days", or "2/3 7d" short enough for the log's column. The
+// window rolls with to rather than following calendar weeks, so it's never
+// called "this week".
+func windowLabel(habit *storage.Habit, entries storage.History, to civil.Date, short bool) string {
+	window := fmt.Sprintf("last %d days", habit.Interval)
+	if short {
+		window = strconv.Itoa(habit.Interval) + "d"
+	}
+	label := fmt.Sprintf("%d/%d %s", WindowProgress(habit, entries, to), habit.Target, window)
+	if short && len(label) > WindowProgressWidth-1 {
+		return fmt.Sprintf("%d/%d", min(WindowProgress(habit, entries, to), 999), habit.Target)
+	}
+	return label
+}
+
+// printWindowProgress prints label in green once habit's target for the
+// window is met
+func (d *Display) printWindowProgress(label string, habit *storage.Habit, entries storage.History, to civil.Date) {
+	if WindowProgress(habit, entries, to) >= habit.Target {
+		d.colorManager.PrintfGreen("%s", label)
+		return
+	}
+	fmt.Fprint(d.out(), label)
+}
+
+// DaysLeft returns how many days, to included, are left to meet habit's
+// target before the window ending on to rolls past its oldest completion,
+// or the whole interval when nothing in it is done yet. It's false for habits
+// without a window and those whose target is already met.
+func DaysLeft(habit *storage.Habit, entries storage.History, to civil.Date) (int, bool) {
+	if !HasWindow(habit) || WindowProgress(habit, entries, to) >= habit.Target {
+		return 0, false
+	}
+	start := to
+	for d := to.AddDays(-habit.Interval + 1); d.Before(to); d = d.AddDays(1) {
+		if habit.MetGoal(storage.OutcomeAt(entries, storage.DailyHabit{Day: d, Habit: habit.Name})) {
+			start = d
+			break
+		}
+	}
+	return start.AddDays(habit.Interval).DaysSince(to), true
+}
+
+// daysLeftLabel describes the days left in a window for todos
+func daysLeftLabel(days int) string {
+	if days == 1 {
+		return "1 day left"
+	}
+	return fmt.Sprintf("%d days left", days)
+}
+
+// printDaysLeft prints label in yellow when there are no more days left than
+// completions still to do, as the window is about to be missed
+func (d *Display) printDaysLeft(label string, days int, habit *storage.Habit, entries storage.History, to civil.Date) {
+	if days <= habit.Target-WindowProgress(habit, entries, to) {
+		d.colorManager.PrintfYellow("%s", label)
+		return
+	}
+	fmt.Fprint(d.out(), label)
+}
+
+// showStale lists habits that have gone unlogged for longer than StaleAfterDays
+func (d *Display) showStale(habits []*storage.Habit, entries storage.History, to civil.Date, maxHabitNameLength int) {
+	if d.StaleAfterDays <= 0 {
+		return
+	}
+	stale := StaleHabits(habits, entries, to, d.StaleAfterDays)
+	if len(stale) == 0 {
+		return
+	}
+	fmt.Fprintln(d.out())
+	d.colorManager.PrintlnBold("Stale (nothing logged in over " + strconv.Itoa(d.StaleAfterDays) + " days):")
+	for _, s := range stale {
+		fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, fitName(s.Habit.Name, maxHabitNameLength)+"  ")
+		d.colorManager.PrintfYellow("last logged %s, %d days ago\n", s.LastEntry, s.Days)
+	}
+}
+
+// ShowQuote prints the quote of the day for the given habits, if there are quotes
+func (d *Display) ShowQuote(habits []*storage.Habit, entries storage.History, day civil.Date) {
+	if quote := d.Quotes.Pick(habits, entries, day); quote != "" {
+		fmt.Fprintln(d.out())
+		d.colorManager.PrintfBlue("%s\n", quote)
+	}
 }
 
 // GetTodos returns a map of date strings to habit names that are undone
-func GetTodos(habits []*storage.Habit, entries *storage.Entries, to civil.Date, daysBack int) map[string][]string {
+func GetTodos(habits []*storage.Habit, entries storage.History, to civil.Date, daysBack int) map[string][]string {
 	tasksUndone := map[string][]string{}
 	dayHabits := map[string]bool{}
 	from := to.AddDays(-daysBack)
@@ -187,14 +923,14 @@ func GetTodos(habits []*storage.Habit, entries *storage.Entries, to civil.Date,
 			}
 
 			for _, habit := range habits {
-				if _, ok := (*entries)[storage.DailyHabit{Day: dt, Habit: habit.Name}]; ok {
+				if _, ok := entries.Outcome(storage.DailyHabit{Day: dt, Habit: habit.Name}); ok {
 					delete(dayHabits, habit.Name)
 				}
 
 				// if habit's target is once, remove from todos if is done in past <interval> days
 				if habit.Target <= 1 {
 					for days := 1; days < habit.Interval; days++ {
-						if outcome, ok := (*entries)[storage.DailyHabit{Day: dt.AddDays(-days), Habit: habit.Name}]; ok && outcome.Result == "y" {
+						if outcome, ok := entries.Outcome(storage.DailyHabit{Day: dt.AddDays(-days), Habit: habit.Name}); ok && habit.MetGoal(outcome) {
 							delete(dayHabits, habit.Name)
 							break
 						}
@@ -208,6 +944,10 @@ func GetTodos(habits []*storage.Habit, entries *storage.Entries, to civil.Date,
 				if dt.Before(habit.FirstRecord) {
 					delete(dayHabits, habit.Name)
 				}
+				// and the habit's days off
+				if !habit.At(dt).ExpectedOn(dt) {
+					delete(dayHabits, habit.Name)
+				}
 			}
 
 			for habit := range dayHabits {
@@ -218,31 +958,198 @@ func GetTodos(habits []*storage.Habit, entries *storage.Entries, to civil.Date,
 	return tasksUndone
 }
 
-// BuildStats calculates statistics for a habit
-func BuildStats(habit *storage.Habit, entries *storage.Entries) HabitStats {
-	var streaks, breaks, skips int
+// BuildStats calculates statistics for a habit up to today
+func BuildStats(habit *storage.Habit, entries storage.History) HabitStats {
+	return BuildStatsUntil(habit, entries, clock.Today())
+}
+
+// BuildStatsUntil calculates statistics for a habit up to date to
+func BuildStatsUntil(habit *storage.Habit, entries storage.History, to civil.Date) HabitStats {
+	var streaks, breaks, skips, fallbacks, short int
 	var total float64
-	now := civil.DateOf(time.Now())
-	to := now
+
+	for _, dated := range entries.Outcomes(habit.Name, habit.FirstRecord, to) {
+		d, outcome := dated.Day, dated.Outcome
+		switch {
+		case habit.MetGoal(outcome):
+			streaks += 1
+			if storage.IsFallback(outcome.Comment) {
+				fallbacks += 1
+			}
+		case outcome.Result == "s" && graph.PositiveSkips:
+			streaks += 1
+		case outcome.Result == "s":
+			skips += 1
+		// look at cases of "n" being entered but streak within
+		// bounds of a sliding window of the habit every x days
+		case graph.Satisfied(d, habit, entries):
+			streaks += 1
+		case graph.Skipified(d, habit, entries) && graph.PositiveSkips:
+			streaks += 1
+		case graph.Skipified(d, habit, entries):
+			skips += 1
+		case outcome.Result == "y":
+			short += 1
+		case outcome.Result == "n":
+			breaks += 1
+		}
+		total += outcome.Amount
+	}
+	rate, percentile := MonthPercentile(habit, entries, to)
+	return HabitStats{
+		DaysTracked:     int((to.DaysSince(habit.FirstRecord)) + 1),
+		Streaks:         streaks,
+		Breaks:          breaks,
+		Skips:           skips,
+		Fallbacks:       fallbacks,
+		Short:           short,
+		Total:           total,
+		Consistency:     ConsistencyIndex(habit, entries, to),
+		CurrentStreak:   CurrentStreak(habit, entries, to),
+		LongestStreak:   LongestStreak(habit, entries, to),
+		MonthRate:       rate,
+		MonthPercentile: percentile,
+	}
+}
+
+// ConsistencyIndex measures how regularly a habit is done up to date to, as
+// opposed to how often. It takes the gaps between completions, ignoring
+// skipped days, and scores their spread relative to the gap the frequency
+// asks for: 100 means perfectly even spacing and the score drops towards 0 as
+// gaps grow erratic. Fewer than three completions give 0.
+func ConsistencyIndex(habit *storage.Habit, entries storage.History, to civil.Date) float64 {
+	var gaps []float64
+	var last civil.Date
+	skipped, done := 0, 0
+	for _, dated := range entries.Outcomes(habit.Name, habit.FirstRecord, to) {
+		switch {
+		case habit.MetGoal(dated.Outcome):
+			if done > 0 {
+				// Skipped days neither break nor stretch the rhythm
+				gaps = append(gaps, float64(dated.Day.DaysSince(last)-1-skipped))
+			}
+			last, skipped = dated.Day, 0
+			done++
+		case dated.Result == "s":
+			skipped++
+		}
+	}
+	if len(gaps) < 2 {
+		return 0
+	}
+
+	var mean, variance float64
+	for _, g := range gaps {
+		mean += g
+	}
+	mean /= float64(len(gaps))
+	for _, g := range gaps {
+		variance += (g - mean) * (g - mean)
+	}
+	variance /= float64(len(gaps))
+
+	expectedGap := float64(habit.Interval) / float64(max(habit.Target, 1))
+	return 100 / (1 + math.Sqrt(variance)/expectedGap)
+}
+
+// BuildMonthlyRates calculates a habit's completion rate for every calendar month
+// from its FirstRecord up to and including the month of to. Skipped days are
+// excluded from the rate, as they are for the daily score.
+func BuildMonthlyRates(habit *storage.Habit, entries storage.History, to civil.Date) []MonthRate {
+	noFirstRecord := civil.Date{Year: 0, Month: 0, Day: 0}
+	if habit.FirstRecord == noFirstRecord || habit.FirstRecord.After(to) {
+		return nil
+	}
+
+	rates := []MonthRate{}
+	var current MonthRate
+	var done, skipped int
+	flush := func() {
+		if current.Days-skipped > 0 {
+			current.Rate = float64(done) / float64(current.Days-skipped) * 100
+		}
+		rates = append(rates, current)
+	}
 
 	for d := habit.FirstRecord; !d.After(to); d = d.AddDays(1) {
-		if outcome, ok := (*entries)[storage.DailyHabit{Day: d, Habit: habit.Name}]; ok {
-			switch {
-			case outcome.Result == "y":
-				streaks += 1
-			case outcome.Result == "s":
-				skips += 1
-			// look at cases of "n" being entered but streak within
-			// bounds of a sliding window of the habit every x days
-			case graph.Satisfied(d, habit, *entries):
-				streaks += 1
-			case graph.Skipified(d, habit, *entries):
-				skips += 1
-			case outcome.Result == "n":
-				breaks += 1
+		month := civil.Date{Year: d.Year, Month: d.Month, Day: 1}
+		if month != current.Month {
+			if current.Days > 0 {
+				flush()
 			}
-			total += outcome.Amount
+			current = MonthRate{Month: month}
+			done, skipped = 0, 0
+		}
+		current.Days++
+
+		switch completion(d, habit, entries) {
+		case "y":
+			done++
+		case "s":
+			skipped++
+		}
+	}
+	flush()
+	return rates
+}
+
+// MonthPercentile returns the completion rate of the month of to so far and
+// the percentage of the habit's earlier months it beats, "better than 80% of
+// your months". The percentage is nil with fewer than MinPercentileMonths
+// earlier months to compare with.
+func MonthPercentile(habit *storage.Habit, entries storage.History, to civil.Date) (float64, *float64) {
+	rates := BuildMonthlyRates(habit, entries, to)
+	if len(rates) == 0 {
+		return 0, nil
+	}
+	current, earlier := rates[len(rates)-1], rates[:len(rates)-1]
+	if len(earlier) < MinPercentileMonths {
+		return current.Rate, nil
+	}
+	beaten := 0
+	for _, month := range earlier {
+		if month.Rate < current.Rate {
+			beaten++
+		}
+	}
+	percentile := float64(beaten) / float64(len(earlier)) * 100
+	return current.Rate, &percentile
+}
+
+// RateBetween returns the percentage of non-skipped days between from and to
+// on which the habit was done or within a satisfied window
+func RateBetween(habit *storage.Habit, entries storage.History, from civil.Date, to civil.Date) float64 {
+	var days, done, skipped int
+	for d := from; !d.After(to); d = d.AddDays(1) {
+		days++
+		switch completion(d, habit, entries) {
+		case "y":
+			done++
+		case "s":
+			skipped++
+		}
+	}
+	if days-skipped <= 0 {
+		return 0
+	}
+	return float64(done) / float64(days-skipped) * 100
+}
+
+// completion classifies a day as done ("y"), skipped ("s"), or not done ("")
+// counting satisfied and skipified windows as done and skipped respectively.
+// With graph.PositiveSkips, skipped days count as done.
+func completion(d civil.Date, habit *storage.Habit, entries storage.History) string {
+	outcome, ok := entries.Outcome(storage.DailyHabit{Day: d, Habit: habit.Name})
+	switch {
+	case ok && habit.MetGoal(outcome):
+		return "y"
+	case graph.Satisfied(d, habit, entries):
+		return "y"
+	case ok && outcome.Result == "s", graph.Skipified(d, habit, entries):
+		if graph.PositiveSkips {
+			return "y"
 		}
+		return "s"
 	}
-	return HabitStats{DaysTracked: int((to.DaysSince(habit.FirstRecord)) + 1), Streaks: streaks, Breaks: breaks, Skips: skips, Total: total}
+	return ""
 }
@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// FocusCount is how many habits harsh focus picks by default
+const FocusCount = 3
+
+// Focus is the few of today's todos harsh focus picks as most urgent
+type Focus struct {
+	Date   string       `json:"date"`
+	Habits []FocusHabit `json:"habits"`
+	// Others is how many of today's todos weren't picked
+	Others int `json:"others"`
+}
+
+// FocusHabit is a habit picked for a Focus
+type FocusHabit struct {
+	Name      string `json:"name"`
+	Heading   string `json:"heading,omitempty"`
+	Frequency string `json:"frequency"`
+	Priority  int    `json:"priority"`
+	// DaysLeft is how many days, today included, are left before the habit
+	// breaks if nothing more is logged
+	DaysLeft int `json:"days_left"`
+	// Streak is the current streak breaking it would end
+	Streak int `json:"streak"`
+}
+
+// BuildFocus picks up to count of the habits harsh todo lists for today,
+// leaving out snoozed ones, that are closest to breaking. Each habit's days
+// left are weighed by its priority, so a priority=2 habit breaking in two
+// days ranks with one breaking today, and ties go to the longer streak.
+// Habits that won't break within their interval aren't picked.
+func BuildFocus(habits []*storage.Habit, entries storage.History, today civil.Date, snoozed storage.Snoozed, count int) Focus {
+	todos := withoutSnoozed(GetTodos(habits, entries, today, 8), snoozed)[today.String()]
+	focus := Focus{Date: today.String(), Habits: []FocusHabit{}}
+
+	todoHabits := slices.DeleteFunc(slices.Clone(habits), func(habit *storage.Habit) bool {
+		return !slices.Contains(todos, habit.Name)
+	})
+	breakDays := BreakDays(todoHabits, entries, today)
+	candidates := []FocusHabit{}
+	for _, habit := range todoHabits {
+		left, ok := breakDays[habit.Name]
+		if !ok {
+			continue
+		}
+		candidate := FocusHabit{Name: habit.Name, Heading: habit.Heading, Frequency: habit.At(today).Frequency, Priority: max(1, habit.Priority), DaysLeft: left}
+		if !habit.FirstRecord.IsZero() {
+			candidate.Streak = CurrentStreak(habit, entries, today)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	slices.SortStableFunc(candidates, func(a, b FocusHabit) int {
+		// The most priority per day left first, compared without dividing
+		if c := cmp.Compare(b.Priority*a.DaysLeft, a.Priority*b.DaysLeft); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.Streak, a.Streak)
+	})
+	focus.Habits = append(focus.Habits, candidates[:min(count, len(candidates))]...)
+	focus.Others = len(todos) - len(focus.Habits)
+	return focus
+}
+
+// ShowFocus prints the habits picked for today, most urgent first, and when
+// each breaks
+func (d *Display) ShowFocus(focus Focus) {
+	today, _ := civil.ParseDate(focus.Date)
+	if len(focus.Habits) == 0 {
+		if focus.Others > 0 {
+			fmt.Fprintf(d.out(), "Nothing's about to break, the %d todos left can wait.\n", focus.Others)
+			return
+		}
+		fmt.Fprintln(d.out(), "All todos logged up to today.")
+		return
+	}
+
+	nameWidth := 0
+	for _, habit := range focus.Habits {
+		nameWidth = max(nameWidth, len([]rune(habit.Name)))
+	}
+	d.colorManager.PrintlnBold("Focus for " + forecastDate(today) + ":")
+	for i, habit := range focus.Habits {
+		fmt.Fprintf(d.out(), "%d. %-*s  ", i+1, nameWidth, habit.Name)
+		switch habit.DaysLeft {
+		case 1:
+			d.colorManager.PrintYellow("breaks today")
+		case 2:
+			fmt.Fprint(d.out(), "breaks tomorrow")
+		default:
+			fmt.Fprint(d.out(), "breaks "+forecastDate(today.AddDays(habit.DaysLeft-1)))
+		}
+		if habit.Streak > 0 {
+			fmt.Fprintf(d.out(), ", %d day streak at stake", habit.Streak)
+		}
+		fmt.Fprintln(d.out())
+	}
+	if focus.Others > 0 {
+		fmt.Fprintf(d.out(), "\n%d more in harsh todo.\n", focus.Others)
+	}
+}
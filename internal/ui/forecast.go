@@ -0,0 +1,134 @@
+package ui
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// ForecastDays is how many days ahead harsh forecast looks by default
+const ForecastDays = 14
+
+// Forecast is how the habits kept over several days will go in the days
+// ahead if nothing more is logged, for harsh forecast
+type Forecast struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Habits are those that come due or break in the forecast
+	Habits []HabitForecast `json:"habits"`
+}
+
+// HabitForecast is a habit's days in a Forecast
+type HabitForecast struct {
+	Name      string `json:"name"`
+	Heading   string `json:"heading,omitempty"`
+	Frequency string `json:"frequency"`
+	// Due is the first day it shows a warning and Breaks the first it
+	// breaks, empty when that's not in the forecast
+	Due    string     `json:"due,omitempty"`
+	Breaks string     `json:"breaks,omitempty"`
+	Days   []HabitDay `json:"days"`
+}
+
+// BuildForecast works out the days from today on that the habits harsh log
+// would show for habitFragment come due or break if they're left undone.
+// Daily habits are left out, as they break the first day they're missed.
+func (d *Display) BuildForecast(habits []*storage.Habit, entries storage.History, today civil.Date, days int, habitFragment string) Forecast {
+	to := today.AddDays(days - 1)
+	forecast := Forecast{From: today.String(), To: to.String(), Habits: []HabitForecast{}}
+
+	// Leaving a day undone reads as answering n, so unlogged days are
+	// filled in with n to see how they'd be drawn
+	undone := maps.Clone(entries.All())
+	forecasted := []*storage.Habit{}
+	for _, habit := range d.logHabits(habits, habitFragment) {
+		current := habit.At(today)
+		if current.Interval <= 1 || current.Target < 1 || habit.FirstRecord.IsZero() || habit.FirstRecord.After(today) {
+			continue
+		}
+		forecasted = append(forecasted, habit)
+		for day := today; !day.After(to); day = day.AddDays(1) {
+			key := storage.DailyHabit{Day: day, Habit: habit.Name}
+			if _, ok := undone[key]; !ok {
+				undone[key] = storage.Outcome{Result: "n"}
+			}
+		}
+	}
+
+	for _, habit := range forecasted {
+		habitForecast := HabitForecast{Name: habit.Name, Heading: habit.Heading, Frequency: habit.At(today).Frequency}
+		for day := today; !day.After(to); day = day.AddDays(1) {
+			state := graph.DayState(day, day, habit, undone)
+			if _, logged := entries.Outcome(storage.DailyHabit{Day: day, Habit: habit.Name}); !logged && state != graph.StateBroken && graph.Warning(day, habit, entries) {
+				state = graph.StateWarning
+			}
+			switch {
+			case state == graph.StateWarning && habitForecast.Due == "":
+				habitForecast.Due = day.String()
+			case state == graph.StateBroken && habitForecast.Breaks == "":
+				habitForecast.Breaks = day.String()
+			}
+			habitForecast.Days = append(habitForecast.Days, HabitDay{Date: day.String(), State: state})
+		}
+		if habitForecast.Due != "" || habitForecast.Breaks != "" {
+			forecast.Habits = append(forecast.Habits, habitForecast)
+		}
+	}
+	return forecast
+}
+
+// ShowForecast prints a forecast as a small calendar, a row per habit under
+// the days' dates and weekdays drawn like harsh calendar's days, then when
+// each habit comes due and breaks
+func (d *Display) ShowForecast(forecast Forecast) {
+	from, _ := civil.ParseDate(forecast.From)
+	to, _ := civil.ParseDate(forecast.To)
+	if len(forecast.Habits) == 0 {
+		fmt.Fprintf(d.out(), "Nothing comes due or breaks from %s to %s.\n", forecastDate(from), forecastDate(to))
+		return
+	}
+
+	nameWidth := 0
+	for _, habit := range forecast.Habits {
+		nameWidth = max(nameWidth, len([]rune(habit.Name)))
+	}
+	d.colorManager.PrintlnBold(fmt.Sprintf("If nothing more is logged, %s to %s", forecastDate(from), forecastDate(to)))
+	var dates, weekdays strings.Builder
+	for day := from; !day.After(to); day = day.AddDays(1) {
+		fmt.Fprintf(&dates, "%3d", day.Day)
+		fmt.Fprintf(&weekdays, "%3s", day.In(time.UTC).Weekday().String()[:2])
+	}
+	fmt.Fprintf(d.out(), "%*s %s\n%*s %s\n", nameWidth, "", dates.String(), nameWidth, "", weekdays.String())
+
+	for _, habit := range forecast.Habits {
+		fmt.Fprintf(d.out(), "%*s ", nameWidth, habit.Name)
+		for _, day := range habit.Days {
+			fmt.Fprint(d.out(), "  ")
+			d.printStateCell(day.State)
+		}
+		fmt.Fprintln(d.out())
+	}
+	d.showCalendarKey(true, false, false, false)
+
+	fmt.Fprintln(d.out())
+	for _, habit := range forecast.Habits {
+		var parts []string
+		if due, err := civil.ParseDate(habit.Due); err == nil {
+			parts = append(parts, "is due from "+forecastDate(due))
+		}
+		if breaks, err := civil.ParseDate(habit.Breaks); err == nil {
+			parts = append(parts, "breaks on "+forecastDate(breaks))
+		}
+		fmt.Fprintf(d.out(), "%s (%s) %s.\n", habit.Name, habit.Frequency, strings.Join(parts, " and "))
+	}
+}
+
+// forecastDate writes a day the way forecasts name them, eg. Mon 19 Oct
+func forecastDate(day civil.Date) string {
+	return day.In(time.UTC).Format("Mon 2 Jan")
+}
@@ -0,0 +1,67 @@
+package ui
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// LogGap is a run of days with no entry at all for a habit, as opposed to
+// days logged as not done
+type LogGap struct {
+	From civil.Date
+	To   civil.Date
+}
+
+// Days returns how many days the gap spans
+func (g LogGap) Days() int {
+	return g.To.DaysSince(g.From) + 1
+}
+
+// BuildGaps returns the runs of unlogged days between habit's first record
+// and date to, oldest first
+func BuildGaps(habit *storage.Habit, entries storage.History, to civil.Date) []LogGap {
+	var gaps []LogGap
+	if habit.FirstRecord == (civil.Date{}) {
+		return gaps
+	}
+	var gap *LogGap
+	for d := habit.FirstRecord; !d.After(to); d = d.AddDays(1) {
+		if _, ok := entries.Outcome(storage.DailyHabit{Day: d, Habit: habit.Name}); ok {
+			gap = nil
+			continue
+		}
+		if gap == nil {
+			gaps = append(gaps, LogGap{From: d})
+			gap = &gaps[len(gaps)-1]
+		}
+		gap.To = d
+	}
+	return gaps
+}
+
+// ShowGaps lists the unlogged stretches of each habit whose name contains
+// habitFragment, up to and including date to
+func (d *Display) ShowGaps(habits []*storage.Habit, entries storage.History, to civil.Date, habitFragment string) {
+	total, days := 0, 0
+	for _, habit := range d.logHabits(habits, habitFragment) {
+		gaps := BuildGaps(habit, entries, to)
+		d.colorManager.PrintfBold("%s", habit.Name)
+		if len(gaps) == 0 {
+			fmt.Fprintln(d.out(), ": no gaps")
+			continue
+		}
+		fmt.Fprintln(d.out())
+		for _, gap := range gaps {
+			if gap.Days() == 1 {
+				fmt.Fprintf(d.out(), "  %s                (1 day)\n", gap.From)
+			} else {
+				fmt.Fprintf(d.out(), "  %s to %s  (%d days)\n", gap.From, gap.To, gap.Days())
+			}
+			days += gap.Days()
+		}
+		total += len(gaps)
+	}
+	fmt.Fprintf(d.out(), "\n%d gaps, %d days with nothing logged.\n", total, days)
+}
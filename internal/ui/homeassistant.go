@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/mqtt"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// HASensor is a value with attributes, the shape Home Assistant's RESTful
+// sensors read with value_template: "{{ value_json.value }}" and
+// json_attributes_path: "$.attributes"
+type HASensor struct {
+	Value      any            `json:"value"`
+	Attributes map[string]any `json:"attributes"`
+}
+
+// haRateDays is how far back habit sensors' completion rate looks
+const haRateDays = 30
+
+// BuildHAScore makes the daily score sensor for day to, with yesterday's
+// score and what's left to do as attributes
+func BuildHAScore(habits []*storage.Habit, entries storage.History, to civil.Date, snoozed storage.Snoozed) HASensor {
+	status := BuildStatus(habits, entries, to, snoozed)
+	todos := status.Todos
+	if todos == nil {
+		todos = []string{}
+	}
+	return HASensor{
+		Value: status.Score,
+		Attributes: map[string]any{
+			"date":            to.String(),
+			"yesterday_score": status.Yesterday,
+			"todos":           len(todos),
+			"todo_habits":     todos,
+		},
+	}
+}
+
+// BuildHAHabits makes a sensor for each habit, keyed by the habit's slug as
+// in MQTT topics, see mqtt.Slugs. Each sensor's value is today's status (done, missed,
+// skipped, or todo).
+func BuildHAHabits(habits []*storage.Habit, entries storage.History, to civil.Date) map[string]HASensor {
+	sensors := map[string]HASensor{}
+	names := make([]string, len(habits))
+	for i, habit := range habits {
+		names[i] = habit.Name
+	}
+	slugs := mqtt.Slugs(names)
+	for i, habit := range habits {
+		slug := slugs[i]
+		if slug == "" {
+			continue
+		}
+		outcome := storage.OutcomeAt(entries, storage.DailyHabit{Day: to, Habit: habit.Name})
+		attributes := map[string]any{
+			"name":            habit.Name,
+			"heading":         habit.Heading,
+			"frequency":       habit.Frequency,
+			"satisfied":       habit.MetGoal(outcome) || graph.Satisfied(to, habit, entries),
+			"days_since_done": nil,
+			"completion_rate": 0.0,
+		}
+		if days, ok := DaysSinceDone(habit, entries, to); ok {
+			attributes["days_since_done"] = days
+		}
+		if habit.FirstRecord != (civil.Date{}) && !habit.FirstRecord.After(to) {
+			attributes["completion_rate"] = RateBetween(habit, entries, teamFrom(habit, to.AddDays(-haRateDays+1)), to)
+		}
+		sensors[slug] = HASensor{Value: mqtt.HabitStatus(outcome.Result), Attributes: attributes}
+	}
+	return sensors
+}
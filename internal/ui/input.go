@@ -3,12 +3,15 @@ package ui
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/clock"
 	"github.com/wakatara/harsh/internal/graph"
 	"github.com/wakatara/harsh/internal/storage"
 )
@@ -16,6 +19,24 @@ import (
 // Input handles user input operations
 type Input struct {
 	colorManager *ColorManager
+	// BreakReasons asks why whenever an answer breaks a habit's streak
+	BreakReasons bool
+	// Snoozed habits aren't asked about on their day unless asked for by name
+	Snoozed storage.Snoozed
+	// Review holds answers until the end of the session, when they can be
+	// amended before anything is written
+	Review  bool
+	reader  *bufio.Reader
+	answers []Answer
+}
+
+// Answer is an outcome given for a habit on a day during an ask session
+type Answer struct {
+	Day     civil.Date
+	Habit   string
+	Result  string
+	Amount  string
+	Comment string
 }
 
 // NewInput creates a new input handler
@@ -25,18 +46,40 @@ func NewInput(noColor bool) *Input {
 	}
 }
 
+// SetOutput sends questions and everything else said during a session to w
+// rather than stdout, eg. stderr to keep stdout for a summary
+func (i *Input) SetOutput(w io.Writer) {
+	i.colorManager.SetOutput(w)
+}
+
+// out is where a session's output goes
+func (i *Input) out() io.Writer {
+	return i.colorManager.writer()
+}
+
+// stdin returns the one reader every prompt shares, so piped answers
+// buffered ahead aren't lost between prompts
+func (i *Input) stdin() *bufio.Reader {
+	if i.reader == nil {
+		i.reader = bufio.NewReader(os.Stdin)
+	}
+	return i.reader
+}
+
 // Onboard prompts new users for initial setup
 func (i *Input) Onboard() int {
-	fmt.Println("Your log file looks empty. Let's setup your tracking.")
-	fmt.Println("How many days back shall we start tracking from in days?")
-	fmt.Println("harsh will ask you about each habit for every day back.")
-	fmt.Println("Starting today would be 0. Choose. (0-7) ")
+	fmt.Fprintln(i.out(), "Your log file looks empty. Let's setup your tracking.")
+	fmt.Fprintln(i.out(), "How many days back shall we start tracking from in days?")
+	fmt.Fprintln(i.out(), "harsh will ask you about each habit for every day back.")
+	fmt.Fprintln(i.out(), "Starting today would be 0. Choose. (0-7) ")
 	var numberOfDays int
+	reader := i.stdin()
 	for {
-		reader := bufio.NewReader(os.Stdin)
 		dayResult, err := reader.ReadString('\n')
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
+		if err != nil && strings.TrimSpace(dayResult) == "" {
+			// Input ended without an answer
+			fmt.Fprintln(i.out(), "\nNo answer, starting from today.")
+			return 0
 		}
 
 		dayResult = strings.TrimSpace(dayResult)
@@ -53,9 +96,16 @@ func (i *Input) Onboard() int {
 	return numberOfDays
 }
 
-// AskHabits handles the interactive habit asking process
+// AskHabits handles the interactive habit asking process, ending with a
+// summary of everything answered
 func (i *Input) AskHabits(habits []*storage.Habit, log *storage.Log, repository storage.Repository, maxHabitNameLength int, countBack int, check string) {
-	now := civil.DateOf(time.Now())
+	i.answers = nil
+	i.askHabits(habits, log, repository, maxHabitNameLength, countBack, check)
+	i.finish(log, repository)
+}
+
+func (i *Input) askHabits(habits []*storage.Habit, log *storage.Log, repository storage.Repository, maxHabitNameLength int, countBack int, check string) {
+	now := clock.Today()
 	to := now
 	from := to.AddDays(-countBack - 40)
 
@@ -71,6 +121,7 @@ func (i *Input) AskHabits(habits []*storage.Habit, log *storage.Log, repository
 	}
 	// Checks for any fragment argument sent along only only asks for it, otherwise all
 	filteredHabits := []*storage.Habit{}
+	byName := false
 	if len(strings.TrimSpace(check)) > 0 {
 		askDate, err := civil.ParseDate(check)
 		if err == nil {
@@ -90,6 +141,7 @@ func (i *Input) AskHabits(habits []*storage.Habit, log *storage.Log, repository
 			for _, habit := range habits {
 				if strings.Contains(strings.ToLower(habit.Name), strings.ToLower(check)) {
 					filteredHabits = append(filteredHabits, habit)
+					byName = true
 				}
 			}
 		}
@@ -97,10 +149,15 @@ func (i *Input) AskHabits(habits []*storage.Habit, log *storage.Log, repository
 		filteredHabits = habits
 	}
 
-	dayHabits := GetTodos(habits, &log.Entries, to, checkBackDays)
+	dayHabits := GetTodos(habits, log, to, checkBackDays)
+	if !byName {
+		dayHabits = withoutSnoozed(dayHabits, i.Snoozed)
+		dayHabits = onlyAsked(dayHabits, habits, log.Entries)
+	}
+	reader := i.stdin()
 
 	if len(filteredHabits) == 0 {
-		fmt.Println("You have no habits that contain that string")
+		fmt.Fprintln(i.out(), "You have no habits that contain that string")
 	} else {
 		for dt := from; !dt.After(to); dt = dt.AddDays(1) {
 			if dayhabit, ok := dayHabits[dt.String()]; ok {
@@ -113,6 +170,7 @@ func (i *Input) AskHabits(habits []*storage.Habit, log *storage.Log, repository
 				// Go through habit file ordered habits,
 				// Check if in returned todos for day and prompt
 				heading := ""
+				batches := map[string]batchAnswer{}
 				for _, habit := range filteredHabits {
 					for _, dh := range dayhabit {
 						if habit.Name == dh && (dt.After(habit.FirstRecord) || dt == habit.FirstRecord) {
@@ -120,64 +178,82 @@ func (i *Input) AskHabits(habits []*storage.Habit, log *storage.Log, repository
 								i.colorManager.PrintfBold("\n%s\n", habit.Heading)
 								heading = habit.Heading
 							}
+							if batch, ok := batches[strings.ToLower(habit.Heading)]; ok {
+								// Answered along with the rest of its heading
+								fmt.Fprintf(i.out(), "%*v", maxHabitNameLength, fitName(habit.Name, maxHabitNameLength)+"  ")
+								fmt.Fprint(i.out(), graph.BuildNotedGraph(habit, log, log.Notes, countBack, true))
+								fmt.Fprintf(i.out(), " [y/n/s/⏎] %s\n", batch.result)
+								if err := i.record(repository, log, Answer{Day: dt, Habit: habit.Name, Result: batch.result, Amount: batch.amount, Comment: batch.comment}); err != nil {
+									i.colorManager.PrintfRed("%v\n", err)
+									break
+								}
+								continue
+							}
+							choices := "[y/n/s/⏎]"
+							if habit.Fallback != "" {
+								// o logs the habit's fallback, eg. "Walk 20min"
+								choices = "[y/o/n/s/⏎]"
+							}
 							for {
-								fmt.Printf("%*v", maxHabitNameLength, habit.Name+"  ")
-								fmt.Print(graph.BuildGraph(habit, &log.Entries, countBack, true))
-								fmt.Printf(" [y/n/s/⏎] ")
+								fmt.Fprintf(i.out(), "%*v", maxHabitNameLength, fitName(habit.Name, maxHabitNameLength)+"  ")
+								fmt.Fprint(i.out(), graph.BuildNotedGraph(habit, log, log.Notes, countBack, true))
+								fmt.Fprintf(i.out(), " %s ", choices)
 
-								reader := bufio.NewReader(os.Stdin)
 								habitResultInput, err := reader.ReadString('\n')
-								if err != nil {
-									fmt.Fprintln(os.Stderr, err)
+								if err != nil && strings.TrimSpace(habitResultInput) == "" {
+									// Input ended, so there's no one left to ask
+									fmt.Fprintln(i.out())
+									return
 								}
 
 								// No input
-								if len(habitResultInput) == 1 {
+								if strings.TrimSpace(habitResultInput) == "" {
 									break
 								}
 
-								// Sanitize : colons out of string for log files
-								habitResultInput = strings.ReplaceAll(habitResultInput, ":", "")
-
-								var result, amount, comment string
-								atIndex := strings.Index(habitResultInput, "@")
-								hashIndex := strings.Index(habitResultInput, "#")
-
-								if atIndex > 0 && hashIndex > 0 && atIndex < hashIndex {
-									parts := strings.SplitN(habitResultInput, "@", 2)
-									secondParts := strings.SplitN(parts[1], "#", 2)
-									result = strings.TrimSpace(parts[0])
-									amount = strings.TrimSpace(secondParts[0])
-									comment = strings.TrimSpace(secondParts[1])
-								}
-								// only has an @ Amount
-								if hashIndex == -1 && atIndex > 0 {
-									parts := strings.SplitN(habitResultInput, "@", 2)
-									result = strings.TrimSpace(parts[0])
-									amount = strings.TrimSpace(parts[1])
-									comment = ""
+								batchHeading, answer, all := cutBatch(habitResultInput, habit.Heading)
+								if all {
+									habitResultInput = answer
 								}
-								// only has a # comment
-								if atIndex == -1 && hashIndex > 0 {
-									parts := strings.SplitN(habitResultInput, "#", 2)
-									result = strings.TrimSpace(parts[0])
-									amount = ""
-									comment = strings.TrimSpace(parts[1])
+								result, amount, comment := parseAnswer(habitResultInput)
+								if all && len(result) == 1 && strings.ContainsAny(result, "yns") && !strings.EqualFold(batchHeading, habit.Heading) {
+									// Another heading's habits, answered when they come up
+									batches[strings.ToLower(batchHeading)] = batchAnswer{result: result, amount: amount, comment: comment}
+									fmt.Fprintf(i.out(), "%*v%s\n", maxHabitNameLength+2, "", fmt.Sprintf("The rest of %s will be answered %s.", batchHeading, result))
+									continue
 								}
-								if atIndex == -1 && hashIndex == -1 {
-									result = strings.TrimSpace(habitResultInput)
+								if result == "o" && habit.Fallback != "" && !all {
+									// Done the easy way, which still counts as done
+									result, comment = "y", storage.WithFallback(comment)
 								}
 
 								if strings.ContainsAny(result, "yns") && len(result) == 1 {
-									repository.WriteEntry(dt, habit.Name, result, comment, amount, log.Header)
-									// Updates the Entries map to get updated buildGraph across days
-									famount, _ := strconv.ParseFloat(amount, 64)
-									log.Entries[storage.DailyHabit{Day: dt, Habit: habit.Name}] = storage.Outcome{Result: result, Amount: famount, Comment: comment}
+									if i.BreakReasons && result == "n" && BreaksStreak(dt, habit, log) {
+										fmt.Fprintf(i.out(), "%*v", maxHabitNameLength+2, "Streak broken. Why? (⏎ to skip) ")
+										reason, _ := reader.ReadString('\n')
+										if reason = strings.TrimSpace(reason); reason != "" {
+											comment = storage.WithBreakReason(comment, reason)
+										}
+									}
+									if err := i.record(repository, log, Answer{Day: dt, Habit: habit.Name, Result: result, Amount: amount, Comment: comment}); err != nil {
+										i.colorManager.PrintfRed("%v\n", err)
+										break
+									}
+									if result == "y" && graph.TooClose(dt, habit, log.Entries) {
+										i.colorManager.PrintfYellow("%*v%s\n", maxHabitNameLength+2, "", fmt.Sprintf("Too close to another completion for minGap=%d, so it won't count toward %s.", habit.MinGap, habit.Frequency))
+									}
+									if all {
+										batches[strings.ToLower(habit.Heading)] = batchAnswer{result: result, amount: amount, comment: comment}
+									}
 									break
 								}
 
 								i.colorManager.PrintfRed("%*v", maxHabitNameLength+22, "Sorry! Please choose from")
-								i.colorManager.PrintfRed(" [y/n/s/⏎] " + "(+ optional @ amounts then # comments)" + "\n")
+								i.colorManager.PrintfRed(" %s (+ optional @ amounts then # comments, or all y/n/s for the whole heading, all <heading>: y/n/s for another)\n", choices)
+								if habit.Fallback != "" {
+									i.colorManager.PrintfRed("%*v", maxHabitNameLength+22, "o is the fallback:")
+									i.colorManager.PrintfRed(" %s\n", habit.Fallback)
+								}
 							}
 						}
 					}
@@ -186,3 +262,232 @@ func (i *Input) AskHabits(habits []*storage.Habit, log *storage.Log, repository
 		}
 	}
 }
+
+// onlyAsked leaves out the todos ask shouldn't prompt for yet: ask=due
+// habits until they're about to break, and ask=mon,thu habits on other days
+func onlyAsked(todos map[string][]string, habits []*storage.Habit, entries storage.Entries) map[string][]string {
+	byName := map[string]*storage.Habit{}
+	for _, habit := range habits {
+		byName[habit.Name] = habit
+	}
+	for day, names := range todos {
+		d, err := civil.ParseDate(day)
+		if err != nil {
+			continue
+		}
+		asked := slices.DeleteFunc(names, func(name string) bool {
+			habit, ok := byName[name]
+			if !ok {
+				return false
+			}
+			due := !habit.AskDue || habit.Target < 1 || graph.Warning(d, habit, entries)
+			return !due || !habit.AsksOn(d)
+		})
+		if len(asked) == 0 {
+			delete(todos, day)
+		} else {
+			todos[day] = asked
+		}
+	}
+	return todos
+}
+
+// record logs answer, writing it straight away unless answers are held for
+// review. Entries are updated either way so graphs stay current across days.
+func (i *Input) record(repository storage.Repository, log *storage.Log, answer Answer) error {
+	if !i.Review {
+		if err := repository.WriteEntry(answer.Day, answer.Habit, answer.Result, answer.Comment, answer.Amount, log.Header); err != nil {
+			return err
+		}
+	}
+	amount, _ := strconv.ParseFloat(answer.Amount, 64)
+	log.Record(storage.DailyHabit{Day: answer.Day, Habit: answer.Habit}, storage.Outcome{Result: answer.Result, Amount: amount, Comment: answer.Comment})
+	i.answers = append(i.answers, answer)
+	return nil
+}
+
+// finish shows what the session logged. Held answers can then be amended by
+// number, and are written together once confirmed or dropped if not. Answers
+// that fail to write stay held, to try again.
+func (i *Input) finish(log *storage.Log, repository storage.Repository) {
+	if len(i.answers) == 0 {
+		return
+	}
+	fmt.Fprintln(i.out())
+	i.colorManager.PrintlnBold("Answered this session:")
+	for _, line := range AnswerSummary(i.answers) {
+		fmt.Fprintln(i.out(), line)
+	}
+	if !i.Review {
+		return
+	}
+
+	reader := i.stdin()
+	for {
+		fmt.Fprintf(i.out(), "Write %d answers? [y/n/number to amend] ", len(i.answers))
+		reply, err := reader.ReadString('\n')
+		reply = strings.TrimSpace(reply)
+		if err != nil && reply == "" {
+			fmt.Fprintln(i.out())
+			reply = "n"
+		}
+		switch strings.ToLower(reply) {
+		case "", "y", "yes":
+			writes := make([]storage.EntryWrite, len(i.answers))
+			for n, answer := range i.answers {
+				writes[n] = storage.EntryWrite{Day: answer.Day, Habit: answer.Habit, Result: answer.Result, Comment: answer.Comment, Amount: answer.Amount}
+			}
+			if err := storage.WriteEntries(repository, writes, log.Header); err != nil {
+				// Still held, so they can be written again once the problem's fixed
+				i.colorManager.PrintfRed("%v\n", err)
+				fmt.Fprintf(i.out(), "Your %d answers weren't written and are still held.\n", len(i.answers))
+				continue
+			}
+			fmt.Fprintf(i.out(), "Wrote %d answers.\n", len(i.answers))
+			return
+		case "n", "no":
+			// Asked days were unanswered, so dropping an answer unlogs the day
+			for _, answer := range i.answers {
+				log.Forget(storage.DailyHabit{Day: answer.Day, Habit: answer.Habit})
+			}
+			fmt.Fprintln(i.out(), "Nothing written.")
+			return
+		}
+		n, err := strconv.Atoi(reply)
+		if err != nil || n < 1 || n > len(i.answers) {
+			i.colorManager.PrintfRed("Sorry! Please choose y, n, or an answer's number (1-%d)\n", len(i.answers))
+			continue
+		}
+		answer := &i.answers[n-1]
+		fmt.Fprintf(i.out(), "%s on %s [y/n/s] ", answer.Habit, answer.Day)
+		amended, _ := reader.ReadString('\n')
+		result, amount, comment := parseAnswer(amended)
+		if !strings.ContainsAny(result, "yns") || len(result) != 1 {
+			i.colorManager.PrintfRed("Sorry! Please choose from [y/n/s] (+ optional @ amounts then # comments)\n")
+			continue
+		}
+		answer.Result, answer.Amount, answer.Comment = result, amount, comment
+		famount, _ := strconv.ParseFloat(amount, 64)
+		log.Record(storage.DailyHabit{Day: answer.Day, Habit: answer.Habit}, storage.Outcome{Result: result, Amount: famount, Comment: comment})
+		for _, line := range AnswerSummary(i.answers) {
+			fmt.Fprintln(i.out(), line)
+		}
+	}
+}
+
+// AnswerSummary lays answers out as a numbered table of dates, habits,
+// outcomes, amounts, and comments
+func AnswerSummary(answers []Answer) []string {
+	width := len("Habit")
+	for _, answer := range answers {
+		width = max(width, len([]rune(answer.Habit)))
+	}
+	lines := []string{fmt.Sprintf("%3s  %-10s  %-*s  %-7s  %-6s  %s", "#", "Date", width, "Habit", "Outcome", "Amount", "Comment")}
+	for n, answer := range answers {
+		line := fmt.Sprintf("%3d  %-10s  %-*s  %-7s  %-6s  %s", n+1, answer.Day, width, answer.Habit, answer.Result, answer.Amount, answer.Comment)
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	return lines
+}
+
+// batchAnswer is an answer given with "all" to apply to every remaining habit
+// under a heading for the day being asked about
+type batchAnswer struct {
+	result  string
+	amount  string
+	comment string
+}
+
+// cutBatch splits an "all" answer into the heading it's for and the answer
+// itself. "all s # travel" is for current, the heading being asked about,
+// while "all Health: s # travel" names its heading.
+func cutBatch(input string, current string) (heading string, answer string, ok bool) {
+	answer, ok = strings.CutPrefix(strings.TrimLeft(input, " "), "all ")
+	if !ok {
+		return "", input, false
+	}
+	if named, rest, found := strings.Cut(answer, ":"); found && !strings.ContainsAny(named, "@#") {
+		return strings.TrimSpace(named), rest, true
+	}
+	return current, answer, true
+}
+
+// parseAnswer splits an ask answer like "y @ 5 # felt good" into its result,
+// amount, and comment
+func parseAnswer(habitResultInput string) (result string, amount string, comment string) {
+	// Sanitize : colons out of string for log files
+	habitResultInput = strings.ReplaceAll(habitResultInput, ":", "")
+
+	atIndex := strings.Index(habitResultInput, "@")
+	hashIndex := strings.Index(habitResultInput, "#")
+
+	if atIndex > 0 && hashIndex > 0 && atIndex < hashIndex {
+		parts := strings.SplitN(habitResultInput, "@", 2)
+		secondParts := strings.SplitN(parts[1], "#", 2)
+		result = strings.TrimSpace(parts[0])
+		amount = strings.TrimSpace(secondParts[0])
+		comment = strings.TrimSpace(secondParts[1])
+	}
+	// only has an @ Amount
+	if hashIndex == -1 && atIndex > 0 {
+		parts := strings.SplitN(habitResultInput, "@", 2)
+		result = strings.TrimSpace(parts[0])
+		amount = strings.TrimSpace(parts[1])
+		comment = ""
+	}
+	// only has a # comment
+	if atIndex == -1 && hashIndex > 0 {
+		parts := strings.SplitN(habitResultInput, "#", 2)
+		result = strings.TrimSpace(parts[0])
+		amount = ""
+		comment = strings.TrimSpace(parts[1])
+	}
+	if atIndex == -1 && hashIndex == -1 {
+		result = strings.TrimSpace(habitResultInput)
+	}
+	return result, amount, comment
+}
+
+// AskPlan prompts for how many times to do each scoreable habit in current's
+// week, suggesting the count already planned or else the habit's
+// frequency. Answering 0 leaves a habit out of the plan.
+func (i *Input) AskPlan(habits []*storage.Habit, current storage.Plan, maxHabitNameLength int) storage.Plan {
+	plan := storage.Plan{Week: current.Week}
+	reader := i.stdin()
+	i.colorManager.PrintlnBold("How many times this week? (⏎ keeps the suggestion)")
+	ended := false
+	for _, habit := range habits {
+		if habit.Target == 0 || habit.Hidden {
+			continue
+		}
+		suggestion, planned := current.Count(habit.Name)
+		if !planned {
+			suggestion = SuggestedPlanCount(habit)
+		}
+		for !ended {
+			fmt.Fprintf(i.out(), "%*v", maxHabitNameLength, fitName(habit.Name, maxHabitNameLength)+"  ")
+			fmt.Fprintf(i.out(), "(%s) [%d] ", habit.Frequency, suggestion)
+			answer, err := reader.ReadString('\n')
+			answer = strings.TrimSpace(answer)
+			if err != nil && answer == "" {
+				// Input ended, keep the suggestions for the rest
+				fmt.Fprintln(i.out())
+				ended = true
+				break
+			}
+			if answer == "" {
+				break
+			}
+			count, err := strconv.Atoi(answer)
+			if err == nil && count >= 0 {
+				suggestion = count
+				break
+			}
+			i.colorManager.PrintfRed("%*v\n", maxHabitNameLength+24, "Sorry! Please give a whole number")
+		}
+		if suggestion > 0 {
+			plan.Targets = append(plan.Targets, storage.PlanTarget{Habit: habit.Name, Count: suggestion})
+		}
+	}
+	return plan
+}
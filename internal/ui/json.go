@@ -0,0 +1,214 @@
+package ui
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// LogReport is what harsh log shows, for scripts
+type LogReport struct {
+	From   string      `json:"from"`
+	To     string      `json:"to"`
+	Scores []ScoreDay  `json:"scores"`
+	Habits []HabitLog  `json:"habits"`
+	Notes  []NoteEntry `json:"notes"`
+	// Averages are the rolling average scores up to the day before To, keyed
+	// by their length in days
+	Averages map[string]*float64 `json:"averages"`
+}
+
+// ScoreDay is a day's score, null on days with nothing to score
+type ScoreDay struct {
+	Date  string   `json:"date"`
+	Score *float64 `json:"score"`
+}
+
+// HabitLog is a habit's graph in a LogReport, a day at a time
+type HabitLog struct {
+	Name      string     `json:"name"`
+	Heading   string     `json:"heading,omitempty"`
+	Frequency string     `json:"frequency"`
+	Days      []HabitDay `json:"days"`
+}
+
+// HabitDay is a day of a habit's graph: the kind of day it's drawn as (see
+// the graph package's states) and the entry, if there is one
+type HabitDay struct {
+	Date    string  `json:"date"`
+	State   string  `json:"state"`
+	Result  string  `json:"result,omitempty"`
+	Comment string  `json:"comment,omitempty"`
+	Amount  float64 `json:"amount,omitempty"`
+}
+
+// NoteEntry is a dated note from the log
+type NoteEntry struct {
+	Date string `json:"date"`
+	Text string `json:"text"`
+}
+
+// HabitStatsReport is a habit's line of harsh log stats, for scripts
+type HabitStatsReport struct {
+	Name      string `json:"name"`
+	Heading   string `json:"heading,omitempty"`
+	Frequency string `json:"frequency"`
+	HabitStats
+}
+
+// HabitReport is a habit as the habits file defines it, for scripts
+type HabitReport struct {
+	Name      string   `json:"name"`
+	Heading   string   `json:"heading,omitempty"`
+	Frequency string   `json:"frequency"`
+	Target    int      `json:"target"`
+	Interval  int      `json:"interval"`
+	Hidden    bool     `json:"hidden,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	// FirstRecord is the first day the habit was logged, empty before then
+	FirstRecord string `json:"first_record,omitempty"`
+}
+
+// EntryReport is an entry of the log, for scripts
+type EntryReport struct {
+	Date    string  `json:"date"`
+	Habit   string  `json:"habit"`
+	Result  string  `json:"result"`
+	Comment string  `json:"comment,omitempty"`
+	Amount  float64 `json:"amount,omitempty"`
+}
+
+// AskSummary is how things stand after harsh ask, for scripts
+type AskSummary struct {
+	Status
+	// XP is the XP earned on Date, when the xp setting is on
+	XP *int `json:"xp,omitempty"`
+}
+
+// BuildLog returns the same graphs and scores harsh log shows for the
+// countBack days before date to, with the same habits
+func (d *Display) BuildLog(habits []*storage.Habit, entries storage.History, to civil.Date, countBack int, habitFragment string) LogReport {
+	from := to.AddDays(-countBack)
+	report := LogReport{From: from.String(), To: to.String(), Scores: []ScoreDay{}, Habits: []HabitLog{}, Notes: []NoteEntry{}, Averages: map[string]*float64{}}
+	for day := from; !day.After(to); day = day.AddDays(1) {
+		score := ScoreDay{Date: day.String()}
+		if value, ok := graph.DayScore(day, habits, entries); ok {
+			score.Score = &value
+		}
+		report.Scores = append(report.Scores, score)
+	}
+
+	for _, habit := range d.sortHabits(d.logHabits(habits, habitFragment), entries, to) {
+		log := HabitLog{Name: habit.Name, Heading: habit.Heading, Frequency: habit.Frequency, Days: []HabitDay{}}
+		for day := from; !day.After(to); day = day.AddDays(1) {
+			outcome := storage.OutcomeAt(entries, storage.DailyHabit{Day: day, Habit: habit.Name})
+			log.Days = append(log.Days, HabitDay{
+				Date:    day.String(),
+				State:   graph.DayState(day, to, habit, entries),
+				Result:  outcome.Result,
+				Comment: outcome.Comment,
+				Amount:  outcome.Amount,
+			})
+		}
+		report.Habits = append(report.Habits, log)
+	}
+
+	for _, day := range d.Notes.SortedDays(from, to) {
+		for _, text := range d.Notes[day] {
+			report.Notes = append(report.Notes, NoteEntry{Date: day.String(), Text: text})
+		}
+	}
+
+	averages := graph.CachedAverageScores(to.AddDays(-1), RollingScoreDays, habits, entries, d.Scores)
+	for i, days := range RollingScoreDays {
+		var average *float64
+		if !math.IsNaN(averages[i]) {
+			average = &averages[i]
+		}
+		report.Averages[strconv.Itoa(days)] = average
+	}
+	return report
+}
+
+// BuildHabits returns the habits in habits file order
+func BuildHabits(habits []*storage.Habit) []HabitReport {
+	report := []HabitReport{}
+	for _, habit := range habits {
+		info := HabitReport{
+			Name:      habit.Name,
+			Heading:   habit.Heading,
+			Frequency: habit.Frequency,
+			Target:    habit.Target,
+			Interval:  habit.Interval,
+			Hidden:    habit.Hidden,
+			Tags:      habit.Tags,
+		}
+		if !habit.FirstRecord.IsZero() {
+			info.FirstRecord = habit.FirstRecord.String()
+		}
+		report = append(report, info)
+	}
+	return report
+}
+
+// BuildEntries returns the entries from date from to date to in date order,
+// only habit's when it isn't empty
+func BuildEntries(entries storage.History, habit string, from civil.Date, to civil.Date) []EntryReport {
+	report := []EntryReport{}
+	for _, key := range entries.All().SortedKeys() {
+		if key.Day.Before(from) || key.Day.After(to) || (habit != "" && key.Habit != habit) {
+			continue
+		}
+		outcome := storage.OutcomeAt(entries, key)
+		report = append(report, EntryReport{
+			Date:    key.Day.String(),
+			Habit:   key.Habit,
+			Result:  outcome.Result,
+			Comment: outcome.Comment,
+			Amount:  outcome.Amount,
+		})
+	}
+	return report
+}
+
+// BuildStatsReport returns the same stats as harsh log stats, up to date to
+func BuildStatsReport(habits []*storage.Habit, entries storage.History, to civil.Date) []HabitStatsReport {
+	report := []HabitStatsReport{}
+	for _, habit := range habits {
+		report = append(report, HabitStatsReport{
+			Name:       habit.Name,
+			Heading:    habit.Heading,
+			Frequency:  habit.Frequency,
+			HabitStats: BuildStatsUntil(habit, entries, to),
+		})
+	}
+	return report
+}
+
+// BuildAskSummary returns the score and what's left to do on day to after
+// answering, with the XP earned that day when withXP is set
+func BuildAskSummary(habits []*storage.Habit, entries storage.History, to civil.Date, snoozed storage.Snoozed, withXP bool) AskSummary {
+	summary := AskSummary{Status: BuildStatus(habits, entries, to, snoozed)}
+	if summary.Todos == nil {
+		summary.Todos = []string{}
+	}
+	if withXP {
+		xp := 0
+		for _, day := range BuildXP(habits, entries, to).Days {
+			if day.Day == to {
+				xp = day.XP
+			}
+		}
+		summary.XP = &xp
+	}
+	return summary
+}
+
+// IndentJSON renders v as indented JSON, the way harsh's --json output reads
+func IndentJSON(v any) ([]byte, error) {
+	return json.MarshalIndent(v, "", "  ")
+}
@@ -0,0 +1,146 @@
+package ui
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// diffMonthColumns is how many months a LogDiff shows as their own columns
+// before counting by year instead
+const diffMonthColumns = 12
+
+// diffConflictLines is how many conflicts a LogDiff lists before summing up
+// the rest
+const diffConflictLines = 10
+
+// LogDiff is what an import or merge changed in the log, so a large move of
+// data can be checked at a glance rather than trusted from a count
+type LogDiff struct {
+	// Added counts the new entries for each habit by month, as YYYY-MM
+	Added map[string]map[string]int
+	// Conflicts are days both sides logged with different answers
+	Conflicts []LogConflict
+}
+
+// LogConflict is a day two sides of a LogDiff disagree on
+type LogConflict struct {
+	Day   civil.Date
+	Habit string
+	// Kept is the answer the log has now, Dropped the one it won over
+	Kept    string
+	Dropped string
+}
+
+// BuildLogDiff compares the entries before an import or merge with after,
+// counting the ones added, and the days incoming disagreed on for the ones
+// both had. Incoming may hold the whole of after or just what was brought in.
+func BuildLogDiff(before storage.Entries, after storage.Entries, incoming storage.Entries) LogDiff {
+	diff := LogDiff{Added: map[string]map[string]int{}}
+	for _, key := range after.SortedKeys() {
+		current, existed := before[key]
+		if !existed {
+			if diff.Added[key.Habit] == nil {
+				diff.Added[key.Habit] = map[string]int{}
+			}
+			diff.Added[key.Habit][key.Day.String()[:7]]++
+			continue
+		}
+		kept := after[key].Result
+		dropped := current.Result
+		if other, ok := incoming[key]; ok && kept == current.Result {
+			dropped = other.Result
+		}
+		if kept != dropped {
+			diff.Conflicts = append(diff.Conflicts, LogConflict{Day: key.Day, Habit: key.Habit, Kept: kept, Dropped: dropped})
+		}
+	}
+	return diff
+}
+
+// AddedCount is how many entries a LogDiff adds in all
+func (diff LogDiff) AddedCount() int {
+	total := 0
+	for _, months := range diff.Added {
+		for _, count := range months {
+			total += count
+		}
+	}
+	return total
+}
+
+// ShowAdded prints a table of the entries a LogDiff adds for each habit by
+// month, or by year when they span more than a year
+func (d *Display) ShowAdded(diff LogDiff) {
+	if len(diff.Added) == 0 {
+		return
+	}
+	months := map[string]bool{}
+	for _, counts := range diff.Added {
+		for month := range counts {
+			months[month] = true
+		}
+	}
+	byYear := len(months) > diffMonthColumns
+
+	habits := make([]string, 0, len(diff.Added))
+	added := map[string]map[string]int{}
+	nameWidth := len("Added")
+	for habit, counts := range diff.Added {
+		habits = append(habits, habit)
+		nameWidth = max(nameWidth, len([]rune(habit)))
+		added[habit] = map[string]int{}
+		for month, count := range counts {
+			if byYear {
+				month = month[:4]
+			}
+			added[habit][month] += count
+		}
+	}
+	slices.Sort(habits)
+	widths := map[string]int{}
+	for _, counts := range added {
+		for period, count := range counts {
+			widths[period] = max(widths[period], len(period), len("+"+strconv.Itoa(count)))
+		}
+	}
+	periods := slices.Sorted(maps.Keys(widths))
+
+	d.colorManager.PrintfBold("%-*s", nameWidth, "Added")
+	for _, period := range periods {
+		d.colorManager.PrintfBold("  %*s", widths[period], period)
+	}
+	fmt.Fprintln(d.out())
+	for _, habit := range habits {
+		fmt.Fprintf(d.out(), "%-*s", nameWidth, habit)
+		for _, period := range periods {
+			count, ok := added[habit][period]
+			if !ok {
+				fmt.Fprintf(d.out(), "  %*s", widths[period], "·")
+				continue
+			}
+			d.colorManager.PrintfGreen("  %*s", widths[period], "+"+strconv.Itoa(count))
+		}
+		fmt.Fprintln(d.out())
+	}
+}
+
+// ShowConflicts lists the days a LogDiff's sides disagreed on and the answer
+// kept for each
+func (d *Display) ShowConflicts(diff LogDiff) {
+	for i, conflict := range diff.Conflicts {
+		if i == diffConflictLines {
+			fmt.Fprintf(d.out(), "  … and %d more\n", len(diff.Conflicts)-i)
+			break
+		}
+		fmt.Fprintf(d.out(), "  %s %s: kept ", conflict.Day, conflict.Habit)
+		d.colorManager.PrintGreen(conflict.Kept)
+		fmt.Fprint(d.out(), " over ")
+		d.colorManager.PrintRed(conflict.Dropped)
+		fmt.Fprintln(d.out())
+	}
+}
@@ -0,0 +1,165 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// sessionGap is how long between writes starts a new logging session
+const sessionGap = 30 * time.Minute
+
+// metaWeeks is how many recent weeks of sessions harsh meta shows
+const metaWeeks = 8
+
+// Meta is how consistently harsh itself gets used, from recorded write times
+type Meta struct {
+	Writes int
+	// From is the day of the first recorded write
+	From     civil.Date
+	Sessions int
+	PerWeek  float64
+	// Weeks counts the sessions in each of the last metaWeeks weeks, oldest first
+	Weeks []int
+	// AverageDelay is how many days after its day an entry was written, on average
+	AverageDelay float64
+	SameDay      int
+	// Late counts entries written a week or more after their day
+	Late int
+	// Habits splits each habit's entries into same-day and backfilled
+	Habits []HabitLogTiming
+}
+
+// HabitLogTiming is how many of a habit's timed entries were first written
+// on their own day rather than backfilled later
+type HabitLogTiming struct {
+	Habit   string
+	Entries int
+	SameDay int
+}
+
+// Backfilled returns how many entries were first written after their day
+func (h HabitLogTiming) Backfilled() int {
+	return h.Entries - h.SameDay
+}
+
+// SameDayRate returns the percentage of entries written on their own day
+func (h HabitLogTiming) SameDayRate() float64 {
+	if h.Entries == 0 {
+		return 0
+	}
+	return 100 * float64(h.SameDay) / float64(h.Entries)
+}
+
+// BuildMeta sums up writes up to and including day to. Writes close
+// together make up one session, like a run of harsh ask. Habits are
+// broken down in the order given.
+func BuildMeta(habits []*storage.Habit, writes []storage.Write, to civil.Date) Meta {
+	writes = slices.DeleteFunc(slices.Clone(writes), func(w storage.Write) bool { return w.At.Date.After(to) })
+	meta := Meta{Writes: len(writes), Weeks: make([]int, metaWeeks)}
+	if len(writes) == 0 {
+		return meta
+	}
+	slices.SortStableFunc(writes, func(a, b storage.Write) int { return a.At.Compare(b.At) })
+	meta.From = writes[0].At.Date
+
+	totalDelay := 0
+	var last time.Time
+	for i, write := range writes {
+		at := write.At.In(time.UTC)
+		if i == 0 || at.Sub(last) > sessionGap {
+			meta.Sessions++
+			if week := to.DaysSince(write.At.Date) / 7; week < metaWeeks {
+				meta.Weeks[metaWeeks-1-week]++
+			}
+		}
+		last = at
+		delay := max(0, write.Delay())
+		totalDelay += delay
+		if delay == 0 {
+			meta.SameDay++
+		}
+		if delay >= 7 {
+			meta.Late++
+		}
+	}
+	meta.Habits = buildLogTimings(habits, writes)
+	meta.PerWeek = float64(meta.Sessions) / max(1, float64(to.DaysSince(meta.From)+1)/7)
+	meta.AverageDelay = float64(totalDelay) / float64(len(writes))
+	return meta
+}
+
+// buildLogTimings judges each entry by when it was first written, as
+// rewriting a day later corrects it rather than backfills it. writes must
+// be oldest first.
+func buildLogTimings(habits []*storage.Habit, writes []storage.Write) []HabitLogTiming {
+	firstWrites := map[storage.DailyHabit]storage.Write{}
+	for _, write := range writes {
+		key := storage.DailyHabit{Day: write.Day, Habit: write.Habit}
+		if _, ok := firstWrites[key]; !ok {
+			firstWrites[key] = write
+		}
+	}
+	byHabit := map[string]*HabitLogTiming{}
+	for key, write := range firstWrites {
+		timing, ok := byHabit[key.Habit]
+		if !ok {
+			timing = &HabitLogTiming{Habit: key.Habit}
+			byHabit[key.Habit] = timing
+		}
+		timing.Entries++
+		if write.Delay() <= 0 {
+			timing.SameDay++
+		}
+	}
+	var timings []HabitLogTiming
+	for _, habit := range habits {
+		if timing, ok := byHabit[habit.Name]; ok {
+			timings = append(timings, *timing)
+		}
+	}
+	return timings
+}
+
+// ShowMeta displays how consistently harsh gets used
+func (d *Display) ShowMeta(meta Meta) {
+	if meta.Writes == 0 {
+		fmt.Fprintln(d.out(), "No write times recorded yet. Turn on `harsh config set write_times true` and harsh meta fills in as you log.")
+		return
+	}
+	d.colorManager.PrintlnBold(fmt.Sprintf("Logging since %s (%d timed entries)", meta.From, meta.Writes))
+	fmt.Fprintln(d.out())
+	weeks := make([]string, len(meta.Weeks))
+	for i, sessions := range meta.Weeks {
+		weeks[i] = strconv.Itoa(sessions)
+	}
+	fmt.Fprintf(d.out(), "%-16s%d, %.1f a week\n", "Sessions", meta.Sessions, meta.PerWeek)
+	fmt.Fprintf(d.out(), "%-16s%s (oldest first)\n", fmt.Sprintf("Last %d weeks", len(meta.Weeks)), strings.Join(weeks, " "))
+	fmt.Fprintf(d.out(), "%-16s%.1f days on average\n", "Logging delay", meta.AverageDelay)
+	fmt.Fprintf(d.out(), "%-16s%.0f%% of entries\n", "Same day", 100*float64(meta.SameDay)/float64(meta.Writes))
+	fmt.Fprintf(d.out(), "%-16s%d written a week or more after the day\n", "Backfilled", meta.Late)
+	if len(meta.Habits) == 0 {
+		return
+	}
+
+	width := len("Habit")
+	for _, timing := range meta.Habits {
+		width = max(width, len([]rune(timing.Habit)))
+	}
+	fmt.Fprintln(d.out())
+	d.colorManager.PrintlnBold(fmt.Sprintf("%-*s  %8s  %10s", width, "Habit", "Same day", "Backfilled"))
+	for _, timing := range meta.Habits {
+		line := fmt.Sprintf("%-*s  %7.0f%%  %10d", width, timing.Habit, timing.SameDayRate(), timing.Backfilled())
+		if timing.SameDayRate() < 50 {
+			// Mostly logged from memory, so worth taking with a pinch of salt
+			d.colorManager.PrintfYellow("%s\n", line)
+		} else {
+			fmt.Fprintln(d.out(), line)
+		}
+	}
+}
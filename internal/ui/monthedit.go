@@ -0,0 +1,182 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// MonthEditorAction is what the month editor does after a key
+type MonthEditorAction int
+
+const (
+	MonthEditorContinue MonthEditorAction = iota
+	MonthEditorSave
+	MonthEditorQuit
+)
+
+// MonthChange is a day the month editor changed, with the outcome to log
+type MonthChange struct {
+	Day     civil.Date
+	Outcome storage.Outcome
+}
+
+// MonthEditor edits a habit's results for every day of a month in a grid,
+// holding the changes until they're saved in one go
+type MonthEditor struct {
+	Habit    *storage.Habit
+	Month    civil.Date
+	days     []civil.Date
+	original storage.Entries
+	results  map[civil.Date]string
+	cursor   int
+}
+
+// NewMonthEditor creates an editor for habit over the month starting on
+// month, up to and including today
+func NewMonthEditor(habit *storage.Habit, month civil.Date, entries storage.History, today civil.Date) *MonthEditor {
+	editor := &MonthEditor{Habit: habit, Month: month, original: storage.Entries{}, results: map[civil.Date]string{}}
+	for d := month; d.Month == month.Month && !d.After(today); d = d.AddDays(1) {
+		editor.days = append(editor.days, d)
+		key := storage.DailyHabit{Day: d, Habit: habit.Name}
+		if outcome, ok := entries.Outcome(key); ok {
+			editor.original[key] = outcome
+			editor.results[d] = outcome.Result
+		}
+	}
+	return editor
+}
+
+// Days returns how many days of the month can be edited
+func (e *MonthEditor) Days() int {
+	return len(e.days)
+}
+
+// Cursor returns the day the cursor is on
+func (e *MonthEditor) Cursor() civil.Date {
+	return e.days[e.cursor]
+}
+
+// HandleKey applies a key from ReadKey and says what to do next
+func (e *MonthEditor) HandleKey(key string) MonthEditorAction {
+	day := e.days[e.cursor]
+	switch key {
+	case "y", "n", "s":
+		e.results[day] = key
+		e.move(1)
+	case " ":
+		next := map[string]string{"": "y", "y": "n", "n": "s", "s": "y"}
+		e.results[day] = next[e.results[day]]
+	case "u":
+		if outcome, ok := e.original[storage.DailyHabit{Day: day, Habit: e.Habit.Name}]; ok {
+			e.results[day] = outcome.Result
+		} else {
+			delete(e.results, day)
+		}
+	case "left", "h":
+		e.move(-1)
+	case "right", "l":
+		e.move(1)
+	case "up", "k":
+		e.move(-7)
+	case "down", "j":
+		e.move(7)
+	case "w", "enter":
+		return MonthEditorSave
+	case "q", "esc", "ctrl-c":
+		return MonthEditorQuit
+	}
+	return MonthEditorContinue
+}
+
+func (e *MonthEditor) move(days int) {
+	e.cursor = max(0, min(len(e.days)-1, e.cursor+days))
+}
+
+// Changes returns the days whose result differs from the log, in date
+// order, keeping any comment and amount already logged
+func (e *MonthEditor) Changes() []MonthChange {
+	var changes []MonthChange
+	for _, day := range e.days {
+		result, ok := e.results[day]
+		outcome := e.original[storage.DailyHabit{Day: day, Habit: e.Habit.Name}]
+		if !ok || result == outcome.Result {
+			continue
+		}
+		outcome.Result = result
+		changes = append(changes, MonthChange{Day: day, Outcome: outcome})
+	}
+	return changes
+}
+
+// Render draws the month as a calendar grid, weeks starting on
+// storage.FirstWeekday. The cursor's day is bracketed and changed days are
+// marked with *.
+func (e *MonthEditor) Render() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s, %s %d\n\n", e.Habit.Name, time.Month(e.Month.Month), e.Month.Year)
+	weekdays := make([]string, 7)
+	for i := range weekdays {
+		weekdays[i] = time.Weekday((int(storage.FirstWeekday) + i) % 7).String()[:2]
+	}
+	b.WriteString("  " + strings.Join(weekdays, "   ") + "\n")
+	b.WriteString(strings.Repeat("     ", e.Month.DaysSince(storage.WeekStart(e.Month))))
+	changed := map[civil.Date]bool{}
+	for _, change := range e.Changes() {
+		changed[change.Day] = true
+	}
+	for i, day := range e.days {
+		open, end := " ", " "
+		if changed[day] {
+			end = "*"
+		}
+		if i == e.cursor {
+			open, end = "[", "]"
+		}
+		symbol := "·"
+		if result, ok := e.results[day]; ok {
+			symbol = result
+		}
+		fmt.Fprintf(&b, "%s%2d%s%s", open, day.Day, symbol, end)
+		if day.AddDays(1).Weekday() == storage.FirstWeekday {
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\n\n")
+	b.WriteString("y/n/s set a day   space cycles   u undoes   arrows or hjkl move\n")
+	fmt.Fprintf(&b, "w saves %d changes   q quits without saving\n", len(changed))
+	return b.String()
+}
+
+// ReadKey reads one keypress from a terminal in raw mode, naming arrow keys
+// "up", "down", "left", and "right", and enter, escape and ctrl-c "enter",
+// "esc", and "ctrl-c"
+func ReadKey(r io.Reader) (string, error) {
+	buf := make([]byte, 8)
+	n, err := r.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	switch key := string(buf[:n]); key {
+	case "\x1b[A", "\x1bOA":
+		return "up", nil
+	case "\x1b[B", "\x1bOB":
+		return "down", nil
+	case "\x1b[C", "\x1bOC":
+		return "right", nil
+	case "\x1b[D", "\x1bOD":
+		return "left", nil
+	case "\r", "\n":
+		return "enter", nil
+	case "\x1b":
+		return "esc", nil
+	case "\x03":
+		return "ctrl-c", nil
+	default:
+		return key, nil
+	}
+}
@@ -0,0 +1,88 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// BuildOrgHabits renders habits as org-mode habit entries (`:STYLE: habit`)
+// with their completion history as logbook state changes, so org-agenda can
+// draw its consistency graph. Tracking-only habits have no schedule and are left out.
+func BuildOrgHabits(habits []*storage.Habit, entries storage.History, to civil.Date) string {
+	var b strings.Builder
+	b.WriteString("#+TITLE: harsh habits\n")
+	b.WriteString("#+STARTUP: logdone\n")
+
+	heading := ""
+	for _, habit := range habits {
+		if habit.Target < 1 {
+			continue
+		}
+		if heading != habit.Heading {
+			heading = habit.Heading
+			if heading != "" {
+				fmt.Fprintf(&b, "\n* %s\n", heading)
+			}
+		}
+
+		var done []civil.Date
+		comments := map[civil.Date]string{}
+		for d := habit.FirstRecord; !d.After(to) && habit.FirstRecord.Year > 0; d = d.AddDays(1) {
+			if outcome, ok := entries.Outcome(storage.DailyHabit{Day: d, Habit: habit.Name}); ok && habit.MetGoal(outcome) {
+				done = append(done, d)
+				comments[d] = outcome.Comment
+			}
+		}
+
+		minDays, maxDays := orgRepeatInterval(habit)
+		repeater := fmt.Sprintf(".+%dd", minDays)
+		if maxDays > minDays {
+			repeater += fmt.Sprintf("/%dd", maxDays)
+		}
+		scheduled := to
+		if len(done) > 0 {
+			scheduled = done[len(done)-1].AddDays(minDays)
+		}
+
+		stars := "**"
+		if habit.Heading == "" {
+			stars = "*"
+		}
+		fmt.Fprintf(&b, "%s TODO %s\n", stars, habit.Name)
+		fmt.Fprintf(&b, "   SCHEDULED: <%s %s>\n", orgDate(scheduled), repeater)
+		b.WriteString("   :PROPERTIES:\n")
+		b.WriteString("   :STYLE:    habit\n")
+		if len(done) > 0 {
+			fmt.Fprintf(&b, "   :LAST_REPEAT: [%s]\n", orgDate(done[len(done)-1]))
+		}
+		b.WriteString("   :END:\n")
+		// org lists state changes newest first
+		for i := len(done) - 1; i >= 0; i-- {
+			fmt.Fprintf(&b, "   - State \"DONE\"       from \"TODO\"       [%s]", orgDate(done[i]))
+			if comment := comments[done[i]]; comment != "" {
+				fmt.Fprintf(&b, " \\\\\n     %s", comment)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// orgRepeatInterval converts a habit's target within an interval to org's
+// minimum and maximum days between completions
+func orgRepeatInterval(habit *storage.Habit) (int, int) {
+	spacing := float64(habit.Interval) / float64(habit.Target)
+	minDays := max(1, int(math.Floor(spacing)))
+	maxDays := max(minDays, int(math.Ceil(spacing)))
+	return minDays, maxDays
+}
+
+// orgDate formats a date as an org timestamp body, eg. "2025-03-08 Sat"
+func orgDate(d civil.Date) string {
+	return d.String() + " " + d.In(time.UTC).Weekday().String()[:3]
+}
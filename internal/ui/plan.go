@@ -0,0 +1,100 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// PlanProgress is how a planned habit's week is going
+type PlanProgress struct {
+	Habit   string
+	Planned int
+	Done    int
+}
+
+// Met reports whether the habit was done as often as planned
+func (p PlanProgress) Met() bool {
+	return p.Done >= p.Planned
+}
+
+// BuildPlanProgress counts each planned habit's completions in the plan's
+// week, up to and including to
+func BuildPlanProgress(plan storage.Plan, habits []*storage.Habit, entries storage.History, to civil.Date) []PlanProgress {
+	last := plan.Week.AddDays(6)
+	if to.Before(last) {
+		last = to
+	}
+	var progress []PlanProgress
+	for _, target := range plan.Targets {
+		habit := &storage.Habit{Name: target.Habit}
+		for _, h := range habits {
+			if h.Name == target.Habit {
+				habit = h
+			}
+		}
+		p := PlanProgress{Habit: target.Habit, Planned: target.Count}
+		for d := plan.Week; !d.After(last); d = d.AddDays(1) {
+			p.Done += habit.Completions(storage.OutcomeAt(entries, storage.DailyHabit{Day: d, Habit: habit.Name}))
+		}
+		progress = append(progress, p)
+	}
+	return progress
+}
+
+// SuggestedPlanCount is how many times habit's frequency asks for in a week,
+// eg. 3 for 3/7 and 1 for 1/14
+func SuggestedPlanCount(habit *storage.Habit) int {
+	if habit.Interval < 1 {
+		return 0
+	}
+	return int(math.Round(float64(habit.Target) * 7 / float64(habit.Interval)))
+}
+
+// planBarWidth is the width of plan progress bars
+const planBarWidth = 14
+
+// ShowPlan prints planned against done for each habit in a week's plan, as of date to
+func (d *Display) ShowPlan(plan storage.Plan, progress []PlanProgress, to civil.Date, maxHabitNameLength int) {
+	d.colorManager.PrintfBold("Plan for the week of %s", plan.Week)
+	if day := to.DaysSince(plan.Week) + 1; day >= 1 && day <= 7 {
+		fmt.Fprintf(d.out(), " (day %d of 7)", day)
+	}
+	fmt.Fprintln(d.out())
+	met := 0
+	for _, p := range progress {
+		fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, fitName(p.Habit, maxHabitNameLength)+"  ")
+		fmt.Fprint(d.out(), ProgressBar(p.Done, max(p.Planned, 1), planBarWidth))
+		if p.Met() {
+			met++
+			d.colorManager.PrintfGreen(" %d/%d\n", p.Done, p.Planned)
+		} else {
+			fmt.Fprintf(d.out(), " %d/%d\n", p.Done, p.Planned)
+		}
+	}
+	fmt.Fprintf(d.out(), "\n%d of %d planned habits on target.\n", met, len(progress))
+}
+
+// PlanCheckIn writes planned against done for a week's plan in plain text,
+// to follow a weekly check-in
+func PlanCheckIn(plan storage.Plan, progress []PlanProgress) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nPlan for the week of %s\n\n", plan.Week)
+	nameWidth := 0
+	for _, p := range progress {
+		nameWidth = max(nameWidth, len([]rune(p.Habit)))
+	}
+	for _, p := range progress {
+		padding := strings.Repeat(" ", nameWidth-len([]rune(p.Habit)))
+		status := ""
+		if p.Met() {
+			status = "  ✓"
+		}
+		fmt.Fprintf(&b, "%s%s  %s/%s%s\n", p.Habit, padding, strconv.Itoa(p.Done), strconv.Itoa(p.Planned), status)
+	}
+	return b.String()
+}
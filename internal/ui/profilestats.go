@@ -0,0 +1,114 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// ProfileHabitStats is one profile's stats for a habit in GroupedHabitStats
+type ProfileHabitStats struct {
+	Profile   string `json:"profile"`
+	Frequency string `json:"frequency"`
+	HabitStats
+	CompletionRate float64 `json:"completion_rate"`
+}
+
+// GroupedHabitStats is a habit's stats summed over every profile with a
+// habit of that name, with each profile's own stats alongside
+type GroupedHabitStats struct {
+	Name        string  `json:"name"`
+	DaysTracked int     `json:"days_tracked"`
+	Total       float64 `json:"total"`
+	Streaks     int     `json:"streaks"`
+	Breaks      int     `json:"breaks"`
+	Skips       int     `json:"skips"`
+	// CompletionRate is the profiles' done or on target days over all their
+	// tracked days that weren't skipped, so longer tracked profiles weigh more
+	CompletionRate float64             `json:"completion_rate"`
+	Profiles       []ProfileHabitStats `json:"profiles"`
+}
+
+// BuildProfileStats groups the habits of profiles by name and works out
+// their stats up to date to, in the order the habits first appear. Habits a
+// profile has never logged are left out of its breakdown.
+func BuildProfileStats(profiles []*storage.Profile, to civil.Date) []GroupedHabitStats {
+	groups := []GroupedHabitStats{}
+	index := map[string]int{}
+	for _, profile := range profiles {
+		for _, habit := range profile.Habits {
+			if habit.FirstRecord.IsZero() || habit.FirstRecord.After(to) {
+				continue
+			}
+			i, ok := index[habit.Name]
+			if !ok {
+				i = len(groups)
+				index[habit.Name] = i
+				groups = append(groups, GroupedHabitStats{Name: habit.Name})
+			}
+			stats := BuildStatsUntil(habit, profile.Log, to)
+			group := &groups[i]
+			group.DaysTracked += stats.DaysTracked
+			group.Total += stats.Total
+			group.Streaks += stats.Streaks
+			group.Breaks += stats.Breaks
+			group.Skips += stats.Skips
+			group.Profiles = append(group.Profiles, ProfileHabitStats{
+				Profile:        profile.Name,
+				Frequency:      habit.Frequency,
+				HabitStats:     stats,
+				CompletionRate: CompletionRate(stats),
+			})
+		}
+	}
+	for i := range groups {
+		groups[i].CompletionRate = CompletionRate(HabitStats{DaysTracked: groups[i].DaysTracked, Streaks: groups[i].Streaks, Skips: groups[i].Skips})
+	}
+	return groups
+}
+
+// ShowProfileStats displays each habit's stats summed across profiles, then
+// broken down by profile
+func (d *Display) ShowProfileStats(groups []GroupedHabitStats, maxHabitNameLength int) {
+	if len(groups) == 0 {
+		fmt.Fprintln(d.out(), "No profile has logged any habits yet.")
+		return
+	}
+	for _, group := range groups {
+		fmt.Fprintln(d.out())
+		d.colorManager.PrintfBold("%*v", maxHabitNameLength, fitName(group.Name, maxHabitNameLength)+"  ")
+		d.printProfileStatsLine(group.Streaks, group.Breaks, group.Skips, group.DaysTracked, group.CompletionRate, group.Total)
+		for _, profile := range group.Profiles {
+			fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, fitName(profile.Profile, maxHabitNameLength-2)+"  ")
+			d.printProfileStatsLine(profile.Streaks, profile.Breaks, profile.Skips, profile.DaysTracked, profile.CompletionRate, profile.Total)
+		}
+	}
+}
+
+// printProfileStatsLine prints a line of ShowProfileStats, laid out like
+// ShowHabitStats with completion in place of consistency
+func (d *Display) printProfileStatsLine(streaks, breaks, skips, tracked int, completion, total float64) {
+	d.colorManager.PrintGreen("Streaks ")
+	d.colorManager.PrintfGreen("%4v", strconv.Itoa(streaks))
+	d.colorManager.PrintGreen(" days")
+	fmt.Fprintf(d.out(), "%4v", "")
+	d.colorManager.PrintRed("Breaks ")
+	d.colorManager.PrintfRed("%4v", strconv.Itoa(breaks))
+	d.colorManager.PrintRed(" days")
+	fmt.Fprintf(d.out(), "%4v", "")
+	d.colorManager.PrintYellow("Skips ")
+	d.colorManager.PrintfYellow("%4v", strconv.Itoa(skips))
+	d.colorManager.PrintYellow(" days")
+	fmt.Fprintf(d.out(), "%4v", "")
+	fmt.Fprintf(d.out(), "Tracked %4v days", strconv.Itoa(tracked))
+	fmt.Fprintf(d.out(), "%4v", "")
+	fmt.Fprintf(d.out(), "Completion %5.1f%%", completion)
+	if total != 0 {
+		fmt.Fprintf(d.out(), "%4v", "")
+		d.colorManager.PrintBlue("Total ")
+		d.colorManager.PrintfBlue("%5v", total)
+	}
+	fmt.Fprintln(d.out())
+}
@@ -0,0 +1,54 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Renderer draws full-screen frames to a terminal, rewriting only the lines
+// that changed since the previous frame so repeated redraws don't flicker
+type Renderer struct {
+	out      io.Writer
+	previous []string
+	drawn    bool
+}
+
+// NewRenderer creates a renderer drawing to out
+func NewRenderer(out io.Writer) *Renderer {
+	return &Renderer{out: out}
+}
+
+// Render draws frame, clearing the screen the first time and afterwards
+// only rewriting lines that differ from the previous frame
+func (r *Renderer) Render(frame string) error {
+	lines := strings.Split(strings.TrimRight(frame, "\n"), "\n")
+
+	var b strings.Builder
+	if !r.drawn {
+		b.WriteString("\x1b[H\x1b[2J")
+	}
+	for i, line := range lines {
+		if r.drawn && i < len(r.previous) && r.previous[i] == line {
+			continue
+		}
+		// Move to the line, write it, and clear whatever the old line left over
+		fmt.Fprintf(&b, "\x1b[%d;1H%s\x1b[K", i+1, line)
+	}
+	for i := len(lines); i < len(r.previous); i++ {
+		fmt.Fprintf(&b, "\x1b[%d;1H\x1b[K", i+1)
+	}
+	// Park the cursor under the frame
+	fmt.Fprintf(&b, "\x1b[%d;1H", len(lines)+1)
+
+	r.previous = lines
+	r.drawn = true
+	_, err := io.WriteString(r.out, b.String())
+	return err
+}
+
+// Reset forgets the previous frame so the next Render redraws the whole screen
+func (r *Renderer) Reset() {
+	r.previous = nil
+	r.drawn = false
+}
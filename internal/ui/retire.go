@@ -0,0 +1,96 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// RetirementReport is the final summary of a habit as it's retired
+type RetirementReport struct {
+	Habit      *storage.Habit
+	Retired    civil.Date
+	LastEntry  civil.Date
+	Stats      HabitStats
+	LongestRun int
+}
+
+// LongestStreak returns the most days in a row habit was done or had its
+// target met up to date to. Skipped days and days off neither break a streak
+// nor add to it, unless graph.PositiveSkips counts skips as done or
+// graph.BreakingSkips has them end it.
+func LongestStreak(habit *storage.Habit, entries storage.History, to civil.Date) int {
+	longest, run := 0, 0
+	for d := habit.FirstRecord; !d.After(to); d = d.AddDays(1) {
+		outcome, ok := entries.Outcome(storage.DailyHabit{Day: d, Habit: habit.Name})
+		skipped := ok && (outcome.Result == "s" || graph.Skipified(d, habit, entries))
+		switch {
+		case !habit.At(d).ExpectedOn(d):
+			// Days off leave the run as it is, whatever's logged on them
+		case ok && habit.MetGoal(outcome), ok && graph.Satisfied(d, habit, entries), skipped && graph.PositiveSkips:
+			run++
+			longest = max(longest, run)
+		case skipped && graph.BreakingSkips:
+			run = 0
+		case skipped:
+		default:
+			run = 0
+		}
+	}
+	return longest
+}
+
+// BuildRetirementReport sums up habit's whole history for retiring it on day to
+func BuildRetirementReport(habit *storage.Habit, entries storage.History, to civil.Date) RetirementReport {
+	report := RetirementReport{Habit: habit, Retired: to}
+	if habit.FirstRecord == (civil.Date{}) {
+		return report
+	}
+	for d := to; !d.Before(habit.FirstRecord); d = d.AddDays(-1) {
+		if _, ok := entries.Outcome(storage.DailyHabit{Day: d, Habit: habit.Name}); ok {
+			report.LastEntry = d
+			break
+		}
+	}
+	report.Stats = BuildStatsUntil(habit, entries, report.LastEntry)
+	report.LongestRun = LongestStreak(habit, entries, report.LastEntry)
+	return report
+}
+
+// String writes the report as plain text, the way it's kept in the retired
+// habits file
+func (r RetirementReport) String() string {
+	var b strings.Builder
+	title := r.Habit.Name
+	if r.Habit.Heading != "" && !strings.HasPrefix(title, r.Habit.Heading+"/") {
+		title += " (" + r.Habit.Heading + ")"
+	}
+	fmt.Fprintf(&b, "## %s, retired %s\n", title, r.Retired)
+	fmt.Fprintf(&b, "Frequency:       %s\n", r.Habit.Frequency)
+	if r.LastEntry == (civil.Date{}) {
+		b.WriteString("Never logged.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Tracked:         %s to %s (%d days)\n", r.Habit.FirstRecord, r.LastEntry, r.Stats.DaysTracked)
+	if r.Habit.Target > 0 {
+		fmt.Fprintf(&b, "Completion rate: %.1f%% (%d days done or on target, %d broken, %d skipped)\n", CompletionRate(r.Stats), r.Stats.Streaks, r.Stats.Breaks, r.Stats.Skips)
+		fmt.Fprintf(&b, "Longest streak:  %d days\n", r.LongestRun)
+	}
+	if r.Stats.Total != 0 {
+		fmt.Fprintf(&b, "Total amount:    %s\n", formatAmount(r.Stats.Total))
+	}
+	return b.String()
+}
+
+// ShowRetirementReport prints a retiring habit's final summary
+func (d *Display) ShowRetirementReport(report RetirementReport) {
+	lines := strings.SplitAfter(strings.TrimSuffix(report.String(), "\n"), "\n")
+	d.colorManager.PrintfBold("%s", strings.TrimPrefix(lines[0], "## "))
+	for _, line := range lines[1:] {
+		fmt.Fprint(d.out(), line)
+	}
+	fmt.Fprintln(d.out())
+}
@@ -0,0 +1,86 @@
+package ui
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// RetunePreview compares a habit's stats and streaks before and after
+// changing its frequency
+type RetunePreview struct {
+	Before, After               *storage.Habit
+	Since                       civil.Date
+	BeforeStats, AfterStats     HabitStats
+	BeforeCurrent, AfterCurrent int
+	BeforeLongest, AfterLongest int
+}
+
+// CurrentStreak returns how many days in a row up to date to habit was done
+// or had its target met, counted like LongestStreak. Nothing logged yet on to
+// doesn't end it, as the day isn't over.
+func CurrentStreak(habit *storage.Habit, entries storage.History, to civil.Date) int {
+	run := 0
+	for d := to; !d.Before(habit.FirstRecord); d = d.AddDays(-1) {
+		outcome, ok := entries.Outcome(storage.DailyHabit{Day: d, Habit: habit.Name})
+		skipped := ok && (outcome.Result == "s" || graph.Skipified(d, habit, entries))
+		switch {
+		case !habit.At(d).ExpectedOn(d):
+			// Days off leave the run as it is, whatever's logged on them
+		case ok && habit.MetGoal(outcome), ok && graph.Satisfied(d, habit, entries), skipped && graph.PositiveSkips:
+			run++
+		case skipped && graph.BreakingSkips:
+			return run
+		case skipped, !ok && d == to:
+		default:
+			return run
+		}
+	}
+	return run
+}
+
+// BuildRetunePreview works out habit's stats and streaks up to date to as
+// they are and as they'd be retuned, where retuned is habit with its new
+// frequency from since on
+func BuildRetunePreview(habit *storage.Habit, retuned *storage.Habit, since civil.Date, entries storage.History, to civil.Date) RetunePreview {
+	return RetunePreview{
+		Before:        habit,
+		After:         retuned,
+		Since:         since,
+		BeforeStats:   BuildStatsUntil(habit, entries, to),
+		AfterStats:    BuildStatsUntil(retuned, entries, to),
+		BeforeCurrent: CurrentStreak(habit, entries, to),
+		AfterCurrent:  CurrentStreak(retuned, entries, to),
+		BeforeLongest: LongestStreak(habit, entries, to),
+		AfterLongest:  LongestStreak(retuned, entries, to),
+	}
+}
+
+// ShowRetunePreview prints the stats and streaks a frequency change would
+// affect side by side, with the ones that change in bold
+func (d *Display) ShowRetunePreview(preview RetunePreview) {
+	d.colorManager.PrintlnBold(fmt.Sprintf("%s: %s to %s from %s", preview.Before.Name, preview.Before.Frequency, preview.After.Frequency, preview.Since))
+	fmt.Fprintf(d.out(), "%-17s %7s %8s\n", "", "now", "after")
+	rows := []struct {
+		label         string
+		before, after string
+	}{
+		{"Current streak", fmt.Sprint(preview.BeforeCurrent), fmt.Sprint(preview.AfterCurrent)},
+		{"Longest streak", fmt.Sprint(preview.BeforeLongest), fmt.Sprint(preview.AfterLongest)},
+		{"Done or on target", fmt.Sprint(preview.BeforeStats.Streaks), fmt.Sprint(preview.AfterStats.Streaks)},
+		{"Breaks", fmt.Sprint(preview.BeforeStats.Breaks), fmt.Sprint(preview.AfterStats.Breaks)},
+		{"Skips", fmt.Sprint(preview.BeforeStats.Skips), fmt.Sprint(preview.AfterStats.Skips)},
+		{"Completion rate", fmt.Sprintf("%.1f%%", CompletionRate(preview.BeforeStats)), fmt.Sprintf("%.1f%%", CompletionRate(preview.AfterStats))},
+	}
+	for _, row := range rows {
+		line := fmt.Sprintf("%-17s %7s %8s", row.label, row.before, row.after)
+		if row.before != row.after {
+			d.colorManager.PrintlnBold(line)
+		} else {
+			fmt.Fprintln(d.out(), line)
+		}
+	}
+	fmt.Fprintln(d.out())
+}
@@ -0,0 +1,88 @@
+package ui
+
+import (
+	"cmp"
+	"maps"
+	"math"
+	"slices"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// The orders harsh log can show habits in
+const (
+	// SortFile keeps the habits file's order, under its headings
+	SortFile = "file"
+	// SortRisk puts the habits closest to breaking first
+	SortRisk = "risk"
+)
+
+// BreakDays returns how many days, today included, each habit has left
+// before it breaks if nothing more is logged. Habits that won't break within
+// their interval, and tracking habits, which never do, are left out.
+func BreakDays(habits []*storage.Habit, entries storage.History, today civil.Date) map[string]int {
+	// Leaving a day undone reads as answering n, so unlogged days are
+	// filled in with n to see when each habit would break, as in forecasts
+	undone := maps.Clone(entries.All())
+	days := map[string]int{}
+	for _, habit := range habits {
+		current := habit.At(today)
+		if current.Target < 1 {
+			continue
+		}
+		lookahead := max(1, current.Interval)
+		for day := today; day.Before(today.AddDays(lookahead)); day = day.AddDays(1) {
+			key := storage.DailyHabit{Day: day, Habit: habit.Name}
+			if _, ok := undone[key]; !ok {
+				undone[key] = storage.Outcome{Result: "n"}
+			}
+		}
+		for left := 1; left <= lookahead; left++ {
+			day := today.AddDays(left - 1)
+			if graph.DayState(day, day, habit, undone) == graph.StateBroken {
+				days[habit.Name] = left
+				break
+			}
+		}
+	}
+	return days
+}
+
+// SortByRisk orders habits by how close they are to breaking on day to:
+// those showing a warning first, then by the days they have left, and those
+// not about to break last, each in habits file order
+func SortByRisk(habits []*storage.Habit, entries storage.History, to civil.Date) []*storage.Habit {
+	breakDays := BreakDays(habits, entries, to)
+	// Each habit's risk as [warning, days left], lowest first
+	risks := map[string][2]int{}
+	for _, habit := range habits {
+		warning := 1
+		if _, logged := entries.Outcome(storage.DailyHabit{Day: to, Habit: habit.Name}); !logged && graph.Warning(to, habit, entries) {
+			warning = 0
+		}
+		left, ok := breakDays[habit.Name]
+		if !ok {
+			left = math.MaxInt
+		}
+		risks[habit.Name] = [2]int{warning, left}
+	}
+	sorted := slices.Clone(habits)
+	slices.SortStableFunc(sorted, func(a, b *storage.Habit) int {
+		riskA, riskB := risks[a.Name], risks[b.Name]
+		if c := cmp.Compare(riskA[0], riskB[0]); c != 0 {
+			return c
+		}
+		return cmp.Compare(riskA[1], riskB[1])
+	})
+	return sorted
+}
+
+// sortHabits puts the log's habits in the order Sort asks for
+func (d *Display) sortHabits(habits []*storage.Habit, entries storage.History, to civil.Date) []*storage.Habit {
+	if d.Sort == SortRisk {
+		return SortByRisk(habits, entries, to)
+	}
+	return habits
+}
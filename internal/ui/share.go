@@ -0,0 +1,92 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// Share is an anonymised snapshot of habit progress, safe to hand to an
+// accountability partner. It never contains comments or amounts.
+type Share struct {
+	Generated string       `json:"generated"`
+	Days      int          `json:"days"`
+	Habits    []ShareHabit `json:"habits"`
+}
+
+// ShareHabit is a single habit's entry in a Share snapshot
+type ShareHabit struct {
+	Name      string       `json:"name"`
+	Heading   string       `json:"heading,omitempty"`
+	Frequency string       `json:"frequency"`
+	Rate      float64      `json:"rate"`
+	Streaks   int          `json:"streaks"`
+	Breaks    int          `json:"breaks"`
+	Skips     int          `json:"skips"`
+	Graph     string       `json:"graph"`
+	History   []ShareEntry `json:"history"`
+}
+
+// ShareEntry is the outcome of a habit on a single day, without amount or comment
+type ShareEntry struct {
+	Date   string `json:"date"`
+	Result string `json:"result"`
+}
+
+// BuildShare snapshots the last days of each habit up to date to. With
+// pseudonymize set, habit names are replaced by "Habit N" and headings dropped.
+func BuildShare(habits []*storage.Habit, entries storage.History, to civil.Date, days int, pseudonymize bool) Share {
+	from := to.AddDays(-days + 1)
+	share := Share{Generated: to.String(), Days: days, Habits: []ShareHabit{}}
+	for i, habit := range habits {
+		stats := BuildStatsUntil(habit, entries, to)
+		shared := ShareHabit{
+			Name:      habit.Name,
+			Heading:   habit.Heading,
+			Frequency: habit.Frequency,
+			Rate:      RateBetween(habit, entries, teamFrom(habit, from), to),
+			Streaks:   stats.Streaks,
+			Breaks:    stats.Breaks,
+			Skips:     stats.Skips,
+			Graph:     graph.BuildGraphUntil(habit, entries, to, days-1, false),
+			History:   []ShareEntry{},
+		}
+		if pseudonymize {
+			shared.Name = fmt.Sprintf("Habit %d", i+1)
+			shared.Heading = ""
+		}
+		for d := from; !d.After(to); d = d.AddDays(1) {
+			if outcome, ok := entries.Outcome(storage.DailyHabit{Day: d, Habit: habit.Name}); ok {
+				shared.History = append(shared.History, ShareEntry{Date: d.String(), Result: outcome.Result})
+			}
+		}
+		share.Habits = append(share.Habits, shared)
+	}
+	return share
+}
+
+// JSON renders the snapshot as indented JSON
+func (s Share) JSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// HTML renders the snapshot as a self-contained HTML snippet for pasting into a page or email
+func (s Share) HTML() string {
+	var b strings.Builder
+	b.WriteString(`<div class="harsh-share">` + "\n")
+	fmt.Fprintf(&b, "  <p>Last %d days to %s</p>\n", s.Days, html.EscapeString(s.Generated))
+	b.WriteString("  <table>\n")
+	b.WriteString("    <tr><th>Habit</th><th>Graph</th><th>Rate</th></tr>\n")
+	for _, habit := range s.Habits {
+		fmt.Fprintf(&b, "    <tr><td>%s</td><td style=\"font-family: monospace; white-space: pre\">%s</td><td>%.1f%%</td></tr>\n",
+			html.EscapeString(habit.Name), html.EscapeString(habit.Graph), habit.Rate)
+	}
+	b.WriteString("  </table>\n")
+	b.WriteString("</div>\n")
+	return b.String()
+}
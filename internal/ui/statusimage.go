@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// Status is today's state of play, small enough for a lock screen widget or
+// an e-ink display
+type Status struct {
+	Date      civil.Date `json:"date"`
+	Score     float64    `json:"score"`
+	Yesterday float64    `json:"yesterday_score"`
+	Todos     []string   `json:"todos"`
+}
+
+// BuildStatus gathers the score and habits still to do on day to, leaving
+// out snoozed habits
+func BuildStatus(habits []*storage.Habit, entries storage.History, to civil.Date, snoozed storage.Snoozed) Status {
+	status := Status{
+		Date:      to,
+		Score:     graph.Score(to, habits, entries),
+		Yesterday: graph.Score(to.AddDays(-1), habits, entries),
+	}
+	for _, day := range BuildTodos(habits, entries, to, snoozed) {
+		if day.Date != to.String() {
+			continue
+		}
+		for _, habit := range day.Habits {
+			status.Todos = append(status.Todos, habit.Name)
+		}
+	}
+	return status
+}
+
+// Limits keeping the status image small
+const (
+	maxStatusTodos = 8
+	maxStatusChars = 28
+	statusScale    = 2
+	statusMargin   = 6
+)
+
+// Lines returns the text drawn onto the status image
+func (s Status) Lines() []string {
+	lines := []string{
+		"harsh " + s.Date.String(),
+		fmt.Sprintf("Today %.1f%%  Yday %.1f%%", s.Score, s.Yesterday),
+		"",
+	}
+	if len(s.Todos) == 0 {
+		return append(lines, "All done for today!")
+	}
+	lines = append(lines, fmt.Sprintf("%d to do:", len(s.Todos)))
+	for i, name := range s.Todos {
+		if i == maxStatusTodos-1 && len(s.Todos) > maxStatusTodos {
+			lines = append(lines, fmt.Sprintf("+%d more", len(s.Todos)-i))
+			break
+		}
+		if runes := []rune(name); len(runes) > maxStatusChars-2 {
+			name = string(runes[:maxStatusChars-5]) + "..."
+		}
+		lines = append(lines, "- "+name)
+	}
+	return lines
+}
+
+// Image draws the status as black text on white, sized to fit
+func (s Status) Image() *image.Gray {
+	lines := s.Lines()
+	chars := 0
+	for _, line := range lines {
+		chars = max(chars, len([]rune(line)))
+	}
+	cellWidth, cellHeight := (glyphWidth+1)*statusScale, (glyphHeight+3)*statusScale
+	img := image.NewGray(image.Rect(0, 0, chars*cellWidth+2*statusMargin*statusScale, len(lines)*cellHeight+2*statusMargin*statusScale))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for row, line := range lines {
+		for col, r := range []rune(line) {
+			x, y := statusMargin*statusScale+col*cellWidth, statusMargin*statusScale+row*cellHeight
+			drawGlyph(img, glyph(r), x, y)
+		}
+	}
+	return img
+}
+
+// drawGlyph draws g with its top left corner at x, y, statusScale times its size
+func drawGlyph(img *image.Gray, g [glyphHeight]uint8, x, y int) {
+	for gy, bits := range g {
+		for gx := range glyphWidth {
+			if bits&(1<<(glyphWidth-1-gx)) == 0 {
+				continue
+			}
+			for dy := range statusScale {
+				for dx := range statusScale {
+					img.SetGray(x+gx*statusScale+dx, y+gy*statusScale+dy, color.Gray{})
+				}
+			}
+		}
+	}
+}
+
+// PNG writes the status image as a PNG
+func (s Status) PNG(w io.Writer) error {
+	return png.Encode(w, s.Image())
+}
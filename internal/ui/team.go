@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// TeamStanding is a profile's place on the team leaderboard
+type TeamStanding struct {
+	Profile string
+	Rate    float64
+}
+
+// SharedHabits returns the names of habits scored in every profile, in the first profile's order
+func SharedHabits(profiles []*storage.Profile) []string {
+	if len(profiles) == 0 {
+		return nil
+	}
+	counts := map[string]int{}
+	for _, profile := range profiles {
+		for _, habit := range profile.Habits {
+			if habit.Target > 0 {
+				counts[habit.Name]++
+			}
+		}
+	}
+	shared := []string{}
+	for _, habit := range profiles[0].Habits {
+		if counts[habit.Name] == len(profiles) {
+			shared = append(shared, habit.Name)
+		}
+	}
+	return shared
+}
+
+// BuildLeaderboard ranks profiles by their average completion rate of the
+// shared habits between from and to, best first
+func BuildLeaderboard(profiles []*storage.Profile, shared []string, from civil.Date, to civil.Date) []TeamStanding {
+	standings := make([]TeamStanding, 0, len(profiles))
+	for _, profile := range profiles {
+		total := 0.0
+		for _, name := range shared {
+			if habit := profileHabit(profile, name); habit != nil {
+				total += RateBetween(habit, profile.Log, teamFrom(habit, from), to)
+			}
+		}
+		standing := TeamStanding{Profile: profile.Name}
+		if len(shared) > 0 {
+			standing.Rate = total / float64(len(shared))
+		}
+		standings = append(standings, standing)
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		return standings[i].Rate > standings[j].Rate
+	})
+	return standings
+}
+
+// ShowTeam displays a leaderboard and per-habit graphs for habits shared across profiles
+func (d *Display) ShowTeam(profiles []*storage.Profile, days int, maxHabitNameLength int) {
+	to := d.Today()
+	from := to.AddDays(-days + 1)
+	shared := SharedHabits(profiles)
+	if len(shared) == 0 {
+		fmt.Fprintln(d.out(), "These profiles have no scored habits in common.")
+		return
+	}
+
+	d.colorManager.PrintfBold("Leaderboard (last %d days)\n", days)
+	for i, standing := range BuildLeaderboard(profiles, shared, from, to) {
+		fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, standing.Profile+"  ")
+		if i == 0 {
+			d.colorManager.PrintfGreen("#%-3d %5.1f%%\n", i+1, standing.Rate)
+		} else {
+			fmt.Fprintf(d.out(), "#%-3d %5.1f%%\n", i+1, standing.Rate)
+		}
+	}
+
+	for _, name := range shared {
+		d.colorManager.PrintfBold("\n%s\n", name)
+		for _, profile := range profiles {
+			habit := profileHabit(profile, name)
+			fmt.Fprintf(d.out(), "%*v", maxHabitNameLength, profile.Name+"  ")
+			fmt.Fprint(d.out(), graph.BuildGraphUntil(habit, profile.Log, to, days-1, false))
+			fmt.Fprintf(d.out(), "  %5.1f%%\n", RateBetween(habit, profile.Log, teamFrom(habit, from), to))
+		}
+	}
+}
+
+// profileHabit returns the profile's habit with the given name, or nil
+func profileHabit(profile *storage.Profile, name string) *storage.Habit {
+	for _, habit := range profile.Habits {
+		if habit.Name == name {
+			return habit
+		}
+	}
+	return nil
+}
+
+// teamFrom doesn't count days before a habit was first recorded against a profile
+func teamFrom(habit *storage.Habit, from civil.Date) civil.Date {
+	if habit.FirstRecord.After(from) {
+		return habit.FirstRecord
+	}
+	return from
+}
@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"encoding/json"
+	"slices"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// TodoDay lists the habits still unanswered on one day, for scripts
+type TodoDay struct {
+	Date   string      `json:"date"`
+	Habits []TodoHabit `json:"habits"`
+}
+
+// TodoHabit is a single unanswered habit in a TodoDay
+type TodoHabit struct {
+	Name      string `json:"name"`
+	Heading   string `json:"heading,omitempty"`
+	Frequency string `json:"frequency"`
+}
+
+// BuildTodos returns the same todos as harsh todo, oldest day first and
+// habits in habits file order, leaving out habits snoozed for their day
+func BuildTodos(habits []*storage.Habit, entries storage.History, to civil.Date, snoozed storage.Snoozed) []TodoDay {
+	undone := withoutSnoozed(GetTodos(habits, entries, to, 8), snoozed)
+	days := make([]string, 0, len(undone))
+	for day := range undone {
+		days = append(days, day)
+	}
+	slices.Sort(days)
+
+	todos := []TodoDay{}
+	for _, day := range days {
+		todo := TodoDay{Date: day}
+		for _, habit := range habits {
+			if slices.Contains(undone[day], habit.Name) {
+				todo.Habits = append(todo.Habits, TodoHabit{Name: habit.Name, Heading: habit.Heading, Frequency: habit.Frequency})
+			}
+		}
+		todos = append(todos, todo)
+	}
+	return todos
+}
+
+// TodosJSON renders todos as indented JSON, an empty list when all are done
+func TodosJSON(todos []TodoDay) ([]byte, error) {
+	return json.MarshalIndent(todos, "", "  ")
+}
@@ -0,0 +1,224 @@
+package ui
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// EntryChange is an entry the grid editor changed, with the outcome to log
+type EntryChange struct {
+	Day     civil.Date
+	Habit   string
+	Outcome storage.Outcome
+}
+
+// GridEditor edits the results of every habit over the days leading up to
+// today in a grid drawn like harsh log, holding the changes until they're
+// saved in one go. Like MonthEditor it's a model driven by HandleKey and
+// drawn by Render, leaving the terminal to its caller.
+type GridEditor struct {
+	Habits []*storage.Habit
+	// scored are all the habits, for the day's score
+	scored []*storage.Habit
+	today  civil.Date
+	// entries is the log with the changes so far, so graphs redraw with them
+	entries   storage.Entries
+	original  *storage.Entries
+	touched   map[storage.DailyHabit]bool
+	nameWidth int
+	days      int
+	rows      int
+	// end is the last day shown, scrolling back as the cursor goes past the first
+	end    civil.Date
+	day    civil.Date
+	row    int
+	offset int
+}
+
+// NewGridEditor creates a grid editor for the habits harsh log would show
+// for habitFragment, ending today and sized to fit a screen width by height
+func (d *Display) NewGridEditor(habits []*storage.Habit, entries *storage.Entries, habitFragment string, width int, height int) *GridEditor {
+	today := d.Today()
+	editor := &GridEditor{
+		Habits:   d.logHabits(habits, habitFragment),
+		scored:   habits,
+		today:    today,
+		entries:  maps.Clone(*entries),
+		original: entries,
+		touched:  map[storage.DailyHabit]bool{},
+		end:      today,
+		day:      today,
+	}
+	for _, habit := range editor.Habits {
+		editor.nameWidth = max(editor.nameWidth, len([]rune(habit.Name)))
+	}
+	// Room for the cursor and change marks around the names, and for the
+	// lines above and below the grid
+	editor.days = max(1, width-editor.nameWidth-3)
+	editor.rows = max(1, height-gridEditorLines)
+	return editor
+}
+
+// gridEditorLines are the lines the grid editor draws besides the habits
+const gridEditorLines = 8
+
+// Cursor returns the habit and day the cursor is on
+func (e *GridEditor) Cursor() (*storage.Habit, civil.Date) {
+	return e.Habits[e.row], e.day
+}
+
+// HandleKey applies a key from ReadKey and says what to do next, the way
+// the month editor does
+func (e *GridEditor) HandleKey(key string) MonthEditorAction {
+	habit, day := e.Cursor()
+	entry := storage.DailyHabit{Day: day, Habit: habit.Name}
+	switch key {
+	case "y", "n", "s":
+		e.set(entry, key)
+		// On to the next habit, for going down a day's answers
+		e.moveRow(1)
+	case " ":
+		next := map[string]string{"": "y", "y": "n", "n": "s", "s": "y"}
+		e.set(entry, next[e.entries[entry].Result])
+	case "u":
+		if outcome, ok := (*e.original)[entry]; ok {
+			e.entries[entry] = outcome
+		} else {
+			delete(e.entries, entry)
+		}
+	case "left", "h":
+		e.moveDay(-1)
+	case "right", "l":
+		e.moveDay(1)
+	case "up", "k":
+		e.moveRow(-1)
+	case "down", "j":
+		e.moveRow(1)
+	case "w", "enter":
+		return MonthEditorSave
+	case "q", "esc", "ctrl-c":
+		return MonthEditorQuit
+	}
+	return MonthEditorContinue
+}
+
+// set gives entry result, keeping any comment and amount it has
+func (e *GridEditor) set(entry storage.DailyHabit, result string) {
+	outcome := e.entries[entry]
+	outcome.Result = result
+	e.entries[entry] = outcome
+	e.touched[entry] = true
+}
+
+func (e *GridEditor) moveDay(days int) {
+	e.day = e.day.AddDays(days)
+	if e.day.After(e.today) {
+		e.day = e.today
+	}
+	// Scroll to keep the cursor's day on screen
+	if e.day.After(e.end) {
+		e.end = e.day
+	}
+	if start := e.end.AddDays(-e.days + 1); e.day.Before(start) {
+		e.end = e.day.AddDays(e.days - 1)
+	}
+}
+
+func (e *GridEditor) moveRow(rows int) {
+	e.row = max(0, min(len(e.Habits)-1, e.row+rows))
+	if e.row < e.offset {
+		e.offset = e.row
+	}
+	if e.row >= e.offset+e.rows {
+		e.offset = e.row - e.rows + 1
+	}
+}
+
+// Changes returns the entries whose result differs from the log, by habit
+// and then date
+func (e *GridEditor) Changes() []EntryChange {
+	var changes []EntryChange
+	for _, habit := range e.Habits {
+		changes = append(changes, e.habitChanges(habit)...)
+	}
+	return changes
+}
+
+// habitChanges returns habit's changed entries in date order
+func (e *GridEditor) habitChanges(habit *storage.Habit) []EntryChange {
+	var changes []EntryChange
+	for entry := range e.touched {
+		outcome, ok := e.entries[entry]
+		if entry.Habit == habit.Name && ok && outcome.Result != (*e.original)[entry].Result {
+			changes = append(changes, EntryChange{Day: entry.Day, Habit: habit.Name, Outcome: outcome})
+		}
+	}
+	slices.SortFunc(changes, func(a, b EntryChange) int { return a.Day.Compare(b.Day) })
+	return changes
+}
+
+// Render draws the grid: each habit's graph over the days shown with the
+// changes so far, > by the cursor's habit and ^ under its day, then the
+// cursor's entry and how to drive the editor. Habits with changes are
+// marked with *.
+func (e *GridEditor) Render() string {
+	var b strings.Builder
+	start := e.end.AddDays(-e.days + 1)
+	fmt.Fprintf(&b, "%*s  %s to %s\n\n", e.nameWidth+2, "", start, e.end)
+
+	changes := 0
+	last := min(len(e.Habits), e.offset+e.rows)
+	for i, habit := range e.Habits {
+		changed := len(e.habitChanges(habit))
+		changes += changed
+		if i < e.offset || i >= last {
+			continue
+		}
+		marker, mark := " ", " "
+		if i == e.row {
+			marker = ">"
+		}
+		if changed > 0 {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "%s%*s%s ", marker, e.nameWidth, habit.Name, mark)
+		// A day at a time, where harsh log squeezes slow habits' graphs
+		symbol := graph.Symbols.Blank
+		for day := start; !day.After(e.end); day = day.AddDays(1) {
+			if state := graph.DayState(day, e.today, habit, e.entries); state != "" {
+				symbol = graph.Symbols.Symbol(state)
+			}
+			b.WriteString(symbol)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%*s%s^\n", e.nameWidth+3, "", strings.Repeat(" ", e.day.DaysSince(start)))
+
+	habit, day := e.Cursor()
+	outcome, ok := e.entries[storage.DailyHabit{Day: day, Habit: habit.Name}]
+	status := "not logged"
+	if ok {
+		status = outcome.Result
+		if outcome.Comment != "" {
+			status += ", " + outcome.Comment
+		}
+		if outcome.Amount != 0 {
+			status += ", " + formatAmount(outcome.Amount)
+		}
+	}
+	fmt.Fprintf(&b, "%s, %s %s: %s", habit.Name, day.In(time.UTC).Format("Mon"), day, status)
+	if score, ok := graph.DayScore(day, e.scored, &e.entries); ok {
+		fmt.Fprintf(&b, "   day's score %.0f%%", score)
+	}
+	b.WriteString("\n\n")
+	b.WriteString("y/n/s set a day   space cycles   u undoes   arrows or hjkl move\n")
+	fmt.Fprintf(&b, "w saves %d changes   q quits without saving\n", changes)
+	return b.String()
+}
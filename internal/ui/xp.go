@@ -0,0 +1,142 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// XP rewards, see BuildXP
+const (
+	XPPerCompletion   = 10
+	XPStreakBonusCap  = 10
+	XPPerfectDayBonus = 25
+	// XPPerLevel grows the XP needed for each level: reaching level n+1 takes n*XPPerLevel more
+	XPPerLevel = 100
+)
+
+// XPDay is the XP earned on one day
+type XPDay struct {
+	Day civil.Date
+	XP  int
+}
+
+// XPReport holds the XP earned from a log, day by day
+type XPReport struct {
+	Total int
+	Days  []XPDay
+}
+
+// BuildXP awards XP for every day up to date to. Each logged completion earns
+// XPPerCompletion plus a bonus of one per day of the habit's running streak
+// (up to XPStreakBonusCap), and a day with every scoreable habit done or
+// skipped earns XPPerfectDayBonus on top. Tracking habits (frequency 0) earn nothing.
+func BuildXP(habits []*storage.Habit, entries storage.History, to civil.Date) XPReport {
+	var report XPReport
+	noFirstRecord := civil.Date{Year: 0, Month: 0, Day: 0}
+	from := to.AddDays(1)
+	for _, habit := range habits {
+		if habit.Target > 0 && habit.FirstRecord != noFirstRecord && habit.FirstRecord.Before(from) {
+			from = habit.FirstRecord
+		}
+	}
+
+	streaks := make(map[string]int, len(habits))
+	for d := from; !d.After(to); d = d.AddDays(1) {
+		gained := 0
+		for _, habit := range habits {
+			if habit.Target == 0 || d.Before(habit.FirstRecord) {
+				continue
+			}
+			switch completion(d, habit, entries) {
+			case "y":
+				streaks[habit.Name]++
+				if habit.MetGoal(storage.OutcomeAt(entries, storage.DailyHabit{Day: d, Habit: habit.Name})) {
+					gained += XPPerCompletion + min(streaks[habit.Name]-1, XPStreakBonusCap)
+				}
+			case "s":
+				// Skips neither earn XP nor break streaks
+			default:
+				streaks[habit.Name] = 0
+			}
+		}
+		if gained > 0 && graph.Score(d, habits, entries) == 100 {
+			gained += XPPerfectDayBonus
+		}
+		report.Total += gained
+		report.Days = append(report.Days, XPDay{Day: d, XP: gained})
+	}
+	return report
+}
+
+// LevelFor returns the level reached with xp, starting from level 1, along
+// with the XP at which that level and the next one begin
+func LevelFor(xp int) (level int, levelStart int, nextLevel int) {
+	level = 1
+	for xp >= levelStart+level*XPPerLevel {
+		levelStart += level * XPPerLevel
+		level++
+	}
+	return level, levelStart, levelStart + level*XPPerLevel
+}
+
+// RebuildXPState recomputes from the log the XP state as it should have been
+// on day checked, for comparing with the cached state
+func RebuildXPState(habits []*storage.Habit, entries storage.History, checked civil.Date) storage.XPState {
+	total := BuildXP(habits, entries, checked).Total
+	level, _, _ := LevelFor(total)
+	return storage.XPState{XP: total, Level: level, Checked: checked}
+}
+
+// ShowLevel displays the current level and XP, the XP gained since the
+// previously shown state, and the XP earned on each of the last days
+func (d *Display) ShowLevel(report XPReport, previous storage.XPState, days int) {
+	level, levelStart, nextLevel := LevelFor(report.Total)
+	barWidth := 20
+	filled := (report.Total - levelStart) * barWidth / (nextLevel - levelStart)
+
+	d.colorManager.PrintfBold("Level %d", level)
+	fmt.Fprintf(d.out(), "  %s%s  ", strings.Repeat("█", filled), strings.Repeat("░", barWidth-filled))
+	fmt.Fprintf(d.out(), "%d / %d XP\n", report.Total, nextLevel)
+
+	noCheck := civil.Date{Year: 0, Month: 0, Day: 0}
+	if previous.Checked != noCheck && report.Total > previous.XP {
+		d.colorManager.PrintfGreen("+%d XP", report.Total-previous.XP)
+		fmt.Fprintf(d.out(), " since %s\n", previous.Checked)
+	}
+	if previous.Level > 0 && level > previous.Level {
+		d.colorManager.PrintfGreen("Level up! %d → %d\n", previous.Level, level)
+	}
+
+	fmt.Fprintln(d.out())
+	d.colorManager.PrintlnBold("Recent gains")
+	start := max(0, len(report.Days)-days)
+	for i := len(report.Days) - 1; i >= start; i-- {
+		day := report.Days[i]
+		t, _ := time.Parse(time.DateOnly, day.Day.String())
+		fmt.Fprintf(d.out(), "%s %s  ", day.Day, t.Weekday().String()[:3])
+		if day.XP > 0 {
+			d.colorManager.PrintfGreen("%+5d XP\n", day.XP)
+		} else {
+			fmt.Fprintf(d.out(), "%5d XP\n", 0)
+		}
+	}
+}
+
+// ShowXPGain prints the XP earned on day and the level it brings you to
+func (d *Display) ShowXPGain(report XPReport, day civil.Date) {
+	gained := 0
+	for _, xpDay := range report.Days {
+		if xpDay.Day == day {
+			gained = xpDay.XP
+		}
+	}
+	level, _, nextLevel := LevelFor(report.Total)
+	fmt.Fprintln(d.out())
+	d.colorManager.PrintfGreen("+%d XP", gained)
+	fmt.Fprintf(d.out(), " today · Level %d (%d / %d XP)\n", level, report.Total, nextLevel)
+}
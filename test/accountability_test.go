@@ -0,0 +1,82 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/accountability"
+	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
+)
+
+func TestWeeklyCheckIn(t *testing.T) {
+	to := civil.Date{Year: 2025, Month: 3, Day: 14}
+	first := to.AddDays(-30)
+	habits := []*storage.Habit{
+		{Name: "Run", Target: 1, Interval: 1, FirstRecord: first},
+		{Name: "Coffee", Target: 0, Interval: 1, FirstRecord: first},
+		{Name: "Swim", Target: 1, Interval: 1},
+	}
+	entries := storage.Entries{}
+	for i := range 7 {
+		result := "y"
+		if i%2 == 1 {
+			result = "n"
+		}
+		entries[storage.DailyHabit{Day: to.AddDays(-i), Habit: "Run"}] = storage.Outcome{Result: result}
+	}
+
+	checkIn := ui.WeeklyCheckIn(habits, &entries, to)
+	expected := "Habits for the week of 2025-03-08 to 2025-03-14\n\n" +
+		"Run  ━ ━ ━ ━   57%\n\n" +
+		"Average daily score: 57%\n"
+	if checkIn != expected {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expected, checkIn)
+	}
+}
+
+func TestSendMatrix(t *testing.T) {
+	var path, auth string
+	var event map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.EscapedPath(), r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&event)
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Write([]byte(`{"event_id":"$1"}`))
+	}))
+	defer server.Close()
+
+	message := accountability.Message{Subject: "Check-in", Body: "Run  ━ ━  <3"}
+	if err := accountability.SendMatrix(server.URL+"/", "secret", "!room:example.org", message); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(path, "/_matrix/client/v3/rooms/%21room:example.org/send/m.room.message/harsh-") {
+		t.Errorf("Expected the room's send endpoint, got %s", path)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Expected the access token as a bearer token, got %q", auth)
+	}
+	if event["body"] != "Check-in\n\nRun  ━ ━  <3" || !strings.Contains(event["formatted_body"], "<pre>Run  ━ ━  &lt;3</pre>") {
+		t.Errorf("Expected the message as text and preformatted HTML, got %+v", event)
+	}
+
+	refusing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errcode":"M_FORBIDDEN","error":"not in room"}`))
+	}))
+	defer refusing.Close()
+	err := accountability.SendMatrix(refusing.URL, "secret", "!room:example.org", message)
+	if err == nil || !strings.Contains(err.Error(), "not in room") {
+		t.Errorf("Expected Matrix's error to be reported, got %v", err)
+	}
+	if !accountability.IsMatrixRoom("!room:example.org") || accountability.IsMatrixRoom("friend@example.com") {
+		t.Error("Expected room IDs and email addresses to be told apart")
+	}
+}
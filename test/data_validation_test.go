@@ -392,7 +392,7 @@ Meeting: 1
 Meeting: 1`,
 			"Same habit name twice",
 			false,
-			2, // Both should load
+			1, // The duplicate is skipped with a warning
 		},
 		{
 			"Special chars in heading",
@@ -0,0 +1,121 @@
+package test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wakatara/harsh/internal/gitsync"
+)
+
+func TestGitSync(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	root := t.TempDir()
+	remote := filepath.Join(root, "remote.git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("cannot make the remote: %v %s", err, out)
+	}
+	write := func(path string, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	appendLine := func(path string, line string) {
+		t.Helper()
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	laptop, desktop := filepath.Join(root, "laptop"), filepath.Join(root, "desktop")
+	for _, dir := range []string{laptop, desktop} {
+		if err := os.Mkdir(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		write(filepath.Join(dir, "habits"), "Gym: 3/7\nRead: 1\n")
+		write(filepath.Join(dir, "log"), "2025-01-01 : Gym : y :  : \n")
+		write(filepath.Join(dir, "signing.key"), "secret\n")
+	}
+
+	// Without a remote changes are only committed
+	if err := gitsync.Init(laptop, ""); err != nil {
+		t.Fatal(err)
+	}
+	appendLine(filepath.Join(laptop, "log"), "2025-01-02 : Read : y :  : ")
+	if result, err := gitsync.Sync(laptop, "harsh sync"); err != nil || !result.Committed || result.Pushed {
+		t.Fatalf("Expected a commit and nothing pushed, got %+v, %v", result, err)
+	}
+
+	if err := gitsync.Init(laptop, remote); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := gitsync.Sync(laptop, "harsh sync"); err != nil || !result.Pushed {
+		t.Fatalf("Expected the laptop to push, got %+v, %v", result, err)
+	}
+	if err := gitsync.Init(desktop, remote); err != nil {
+		t.Fatal(err)
+	}
+	appendLine(filepath.Join(desktop, "log"), "2025-01-02 : Gym : n :  : ")
+	if result, err := gitsync.Sync(desktop, "harsh sync"); err != nil || !result.Committed || !result.Pulled || !result.Pushed {
+		t.Fatalf("Expected the desktop to merge in the laptop's history, got %+v, %v", result, err)
+	}
+	if result, err := gitsync.Sync(laptop, "harsh sync"); err != nil || !result.Pulled {
+		t.Fatalf("Expected the laptop to pull the desktop's entry, got %+v, %v", result, err)
+	}
+
+	// Both machines' lines are kept rather than conflicting
+	for _, dir := range []string{laptop, desktop} {
+		log, err := os.ReadFile(filepath.Join(dir, "log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(log), "2025-01-02 : Read : y") || !strings.Contains(string(log), "2025-01-02 : Gym : n") {
+			t.Errorf("Expected both entries in %s, got\n%s", dir, log)
+		}
+	}
+	tracked, err := exec.Command("git", "-C", laptop, "ls-files").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(tracked), "signing.key") || !strings.Contains(string(tracked), "habits") {
+		t.Errorf("Expected the habits tracked and the signing key left out, got\n%s", tracked)
+	}
+
+	// An ignore file of the user's own still gets harsh's added
+	tablet := filepath.Join(root, "tablet")
+	if err := os.Mkdir(tablet, 0700); err != nil {
+		t.Fatal(err)
+	}
+	write(filepath.Join(tablet, ".gitignore"), "*.swp")
+	write(filepath.Join(tablet, "signing.key"), "secret\n")
+	if err := gitsync.Init(tablet, ""); err != nil {
+		t.Fatal(err)
+	}
+	if ignores, _ := os.ReadFile(filepath.Join(tablet, ".gitignore")); !strings.HasPrefix(string(ignores), "*.swp\nsigning.key\n") {
+		t.Errorf("Expected harsh's ignores added to the tablet's own, got\n%s", ignores)
+	}
+	if tracked, _ := exec.Command("git", "-C", tablet, "ls-files").Output(); strings.Contains(string(tracked), "signing.key") {
+		t.Errorf("Expected the tablet's signing key left out, got\n%s", tracked)
+	}
+
+	// Nothing's committed in the middle of a merge
+	head, err := exec.Command("git", "-C", laptop, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(filepath.Join(laptop, ".git", "MERGE_HEAD"), string(head))
+	appendLine(filepath.Join(laptop, "log"), "2025-01-03 : Gym : y :  : ")
+	if committed, err := gitsync.Commit(laptop, "harsh sync"); err == nil || committed {
+		t.Errorf("Expected no commit during a merge, got %v, %v", committed, err)
+	}
+}
@@ -2,11 +2,13 @@ package test
 
 import (
 	"fmt"
+	"math"
 	"runtime"
 	"strings"
 	"testing"
 
 	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/clock"
 	"github.com/wakatara/harsh/internal/graph"
 	"github.com/wakatara/harsh/internal/storage"
 )
@@ -157,6 +159,117 @@ func TestGraphSatisfied(t *testing.T) {
 	}
 }
 
+func TestGraphMinGap(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habit := &storage.Habit{Name: "Lift", Target: 2, Interval: 7, FirstRecord: first, MinGap: 1}
+	day := func(n int) storage.DailyHabit { return storage.DailyHabit{Day: first.AddDays(n), Habit: "Lift"} }
+
+	// Back to back days only count once
+	entries := storage.Entries{day(0): {Result: "y"}, day(1): {Result: "y"}, day(3): {Result: "n"}}
+	if graph.Satisfied(first.AddDays(3), habit, entries) {
+		t.Error("Expected consecutive completions not to meet a minGap=1 target")
+	}
+	if !graph.TooClose(first.AddDays(1), habit, entries) {
+		t.Error("Expected a completion the day after another to be too close")
+	}
+
+	// With a rest day between them both count
+	entries = storage.Entries{day(0): {Result: "y"}, day(2): {Result: "y"}, day(3): {Result: "n"}}
+	if !graph.Satisfied(first.AddDays(3), habit, entries) {
+		t.Error("Expected spaced completions to meet the target")
+	}
+	if graph.TooClose(first.AddDays(2), habit, entries) {
+		t.Error("Expected a completion after a rest day not to be too close")
+	}
+}
+
+func TestGraphCountTimes(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habit := &storage.Habit{Name: "Water", Target: 3, Interval: 7, FirstRecord: first}
+	day := func(n int) storage.DailyHabit { return storage.DailyHabit{Day: first.AddDays(n), Habit: "Water"} }
+	entries := storage.Entries{day(0): {Result: "y", Amount: 2}, day(1): {Result: "y", Amount: 1}, day(2): {Result: "n"}}
+
+	// By default each day counts once, however much was done
+	if graph.Satisfied(first.AddDays(2), habit, entries) {
+		t.Error("Expected two done days not to meet a target of 3")
+	}
+
+	// With count=times amounts count as that many completions
+	habit.CountTimes = true
+	if !graph.Satisfied(first.AddDays(2), habit, entries) {
+		t.Error("Expected amounts of 2 and 1 to meet a target of 3")
+	}
+	if got := habit.Completions(storage.Outcome{Result: "y"}); got != 1 {
+		t.Errorf("Expected a done day without an amount to count once, got %d", got)
+	}
+}
+
+func TestGraphAmountGoal(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habit := &storage.Habit{Name: "Pushups", Target: 2, Interval: 7, FirstRecord: first, AmountGoal: 50}
+	day := func(n int) storage.DailyHabit { return storage.DailyHabit{Day: first.AddDays(n), Habit: "Pushups"} }
+	entries := storage.Entries{day(0): {Result: "y", Amount: 50}, day(1): {Result: "y", Amount: 20}, day(2): {Result: "n"}}
+
+	if graph.Satisfied(first.AddDays(2), habit, entries) {
+		t.Error("Expected a day under the goal not to count toward the target")
+	}
+	if got := graph.DayState(first, first.AddDays(2), habit, entries); got != graph.StateDone {
+		t.Errorf("Expected a day reaching the goal to be done, got %q", got)
+	}
+	if got := graph.DayState(first.AddDays(1), first.AddDays(2), habit, entries); got != graph.StateShort {
+		t.Errorf("Expected a day under the goal to be short, got %q", got)
+	}
+	if graph.Symbols.Symbol(graph.StateShort) == graph.Symbols.Done {
+		t.Error("Expected short days drawn apart from done ones")
+	}
+
+	entries[day(3)] = storage.Outcome{Result: "y", Amount: 55}
+	if !graph.Satisfied(first.AddDays(2), habit, entries) {
+		t.Error("Expected two days reaching the goal to meet a target of 2")
+	}
+	if got := graph.DayState(first.AddDays(1), first.AddDays(3), habit, entries); got != graph.StateSatisfied {
+		t.Errorf("Expected a short day inside a met window to be satisfied, got %q", got)
+	}
+}
+
+func TestGraphFallback(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habit := &storage.Habit{Name: "Run", Target: 1, Interval: 1, FirstRecord: first, Fallback: "Walk"}
+	day := func(n int) storage.DailyHabit { return storage.DailyHabit{Day: first.AddDays(n), Habit: "Run"} }
+	entries := storage.Entries{day(0): {Result: "y"}, day(1): {Result: "y", Comment: storage.WithFallback("rainy")}, day(2): {Result: "y"}}
+
+	if got := graph.DayState(first.AddDays(1), first.AddDays(2), habit, entries); got != graph.StateFallback {
+		t.Errorf("Expected a day done the fallback way to be a fallback day, got %q", got)
+	}
+	if got := graph.BuildGraphUntil(habit, &entries, first.AddDays(2), 2, false); got != "━╍━" {
+		t.Errorf("Expected the fallback day drawn apart from done ones, got %q", got)
+	}
+	if !habit.MetGoal(entries[day(1)]) {
+		t.Error("Expected a fallback day to still count as done")
+	}
+}
+
+func TestGraphProRateFirstWindow(t *testing.T) {
+	// A 3/7 habit started on a Friday, done Friday and Saturday
+	first := civil.Date{Year: 2025, Month: 1, Day: 3}
+	habit := &storage.Habit{Name: "Gym", Target: 3, Interval: 7, FirstRecord: first}
+	day := func(n int) storage.DailyHabit { return storage.DailyHabit{Day: first.AddDays(n), Habit: "Gym"} }
+	entries := storage.Entries{day(0): {Result: "y"}, day(1): {Result: "y"}, day(2): {Result: "n"}, day(6): {Result: "n"}}
+	defer func() { graph.ProRateFirstWindow = false }()
+
+	if graph.Satisfied(first.AddDays(2), habit, entries) {
+		t.Error("Expected 2 of 3 not to be satisfied without pro-rating")
+	}
+	graph.ProRateFirstWindow = true
+	if !graph.Satisfied(first.AddDays(2), habit, entries) {
+		t.Error("Expected 2 done in the first 3 days to meet a pro-rated target of 2")
+	}
+	// Once a full interval has passed the whole target applies again
+	if graph.Satisfied(first.AddDays(6), habit, entries) {
+		t.Error("Expected the full target on the seventh day")
+	}
+}
+
 func TestGraphSkipified(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -367,3 +480,357 @@ func TestGraphBuildSpark(t *testing.T) {
 		}
 	}
 }
+
+func TestGraphBuildGraphUntil(t *testing.T) {
+	habit := &storage.Habit{
+		Name:        "Test",
+		Target:      1,
+		Interval:    1,
+		FirstRecord: civil.Date{Year: 2025, Month: 6, Day: 1},
+	}
+
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 6, Day: 28}, Habit: "Test"}: {Result: "y"},
+		storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 6, Day: 29}, Habit: "Test"}: {Result: "s"},
+		storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 6, Day: 30}, Habit: "Test"}: {Result: "y"},
+	}
+
+	result := graph.BuildGraphUntil(habit, entries, civil.Date{Year: 2025, Month: 6, Day: 30}, 2, false)
+	if result != "━•━" {
+		t.Errorf("Expected graph ending on 2025-06-30 to be %q, got %q", "━•━", result)
+	}
+
+	results := graph.BuildGraphsParallelUntil([]*storage.Habit{habit}, entries, civil.Date{Year: 2025, Month: 6, Day: 29}, 1, false)
+	if results["Test"] != "━•" {
+		t.Errorf("Expected parallel graph ending on 2025-06-29 to be %q, got %q", "━•", results["Test"])
+	}
+}
+
+func TestGraphAmountOverlay(t *testing.T) {
+	habit := &storage.Habit{Name: "Pushups", Target: 1, Interval: 1}
+	to := civil.Date{Year: 2025, Month: 6, Day: 30}
+
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: to.AddDays(-3), Habit: "Pushups"}: {Result: "y", Amount: 10},
+		storage.DailyHabit{Day: to.AddDays(-2), Habit: "Pushups"}: {Result: "y", Amount: 40},
+		storage.DailyHabit{Day: to.AddDays(-1), Habit: "Pushups"}: {Result: "s"},
+		storage.DailyHabit{Day: to, Habit: "Pushups"}:             {Result: "y", Amount: 25},
+	}
+
+	result := graph.AmountOverlay("━━━•━", habit, entries, to)
+	if result != "━▁▇•▅" {
+		t.Errorf("Expected amount overlay %q, got %q", "━▁▇•▅", result)
+	}
+
+	plain := &storage.Entries{
+		storage.DailyHabit{Day: to, Habit: "Pushups"}: {Result: "y"},
+	}
+	if result := graph.AmountOverlay("◌━", habit, plain, to); result != "◌━" {
+		t.Errorf("Expected graph without amounts to be unchanged, got %q", result)
+	}
+}
+
+func TestGraphAverageScores(t *testing.T) {
+	start := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habits := []*storage.Habit{
+		{Name: "Run", Target: 1, Interval: 1, FirstRecord: start},
+		{Name: "Read", Target: 1, Interval: 1, FirstRecord: start},
+		// Never logged: it counts against each day's score, but doesn't
+		// stretch the averages back before the first record
+		{Name: "Unused", Target: 1, Interval: 1},
+	}
+
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: start, Habit: "Run"}:             {Result: "y"},
+		storage.DailyHabit{Day: start, Habit: "Read"}:            {Result: "y"},
+		storage.DailyHabit{Day: start.AddDays(1), Habit: "Run"}:  {Result: "y"},
+		storage.DailyHabit{Day: start.AddDays(1), Habit: "Read"}: {Result: "n"},
+		storage.DailyHabit{Day: start.AddDays(2), Habit: "Run"}:  {Result: "n"},
+		storage.DailyHabit{Day: start.AddDays(2), Habit: "Read"}: {Result: "n"},
+	}
+
+	averages := graph.AverageScores(start.AddDays(2), []int{1, 2, 30}, habits, entries)
+	expected := []float64{0, 100.0 / 6, 100.0 / 3}
+	for i, want := range expected {
+		if math.Abs(averages[i]-want) > 0.01 {
+			t.Errorf("Window %d: expected %.1f, got %.1f", i, want, averages[i])
+		}
+	}
+
+	// Cached days aren't scored again, and the rest are added to the cache
+	cache := map[civil.Date]float64{start.AddDays(2): 100}
+	averages = graph.CachedAverageScores(start.AddDays(2), []int{1, 30}, habits, entries, cache)
+	if averages[0] != 100 || len(cache) != 3 || math.Abs(cache[start]-100.0*2/3) > 0.01 {
+		t.Errorf("Expected the cached score used and the others cached, got %v and %v", averages, cache)
+	}
+}
+
+func TestGraphBuildGraphGraphDays(t *testing.T) {
+	to := civil.Date{Year: 2025, Month: 6, Day: 30}
+	habit := &storage.Habit{
+		Name:        "Rent",
+		Target:      1,
+		Interval:    30,
+		FirstRecord: to.AddDays(-11),
+		GraphDays:   12,
+	}
+
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: to.AddDays(-11), Habit: "Rent"}: {Result: "y"},
+		storage.DailyHabit{Day: to.AddDays(-6), Habit: "Rent"}:  {Result: "s"},
+	}
+
+	// 12 days squeezed into 4 cells of 3 days, each showing its most telling day
+	result := graph.BuildGraphUntil(habit, entries, to, 3, false)
+	if result != "━•◌◌" {
+		t.Errorf("Expected compressed graph %q, got %q", "━•◌◌", result)
+	}
+
+	// Graphs already covering graphdays are unaffected
+	habit.GraphDays = 4
+	if result := graph.BuildGraphUntil(habit, entries, to, 3, false); len([]rune(result)) != 4 {
+		t.Errorf("Expected 4 day graph, got %q", result)
+	}
+}
+
+func TestGraphLetterSymbols(t *testing.T) {
+	defer func() { graph.Symbols = graph.Blocks }()
+	graph.Symbols = graph.Letters
+
+	to := civil.Date{Year: 2025, Month: 6, Day: 30}
+	habit := &storage.Habit{Name: "Run", Target: 1, Interval: 1, FirstRecord: to.AddDays(-4)}
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: to.AddDays(-4), Habit: "Run"}: {Result: "y"},
+		storage.DailyHabit{Day: to.AddDays(-3), Habit: "Run"}: {Result: "n"},
+		storage.DailyHabit{Day: to.AddDays(-2), Habit: "Run"}: {Result: "s"},
+		storage.DailyHabit{Day: to, Habit: "Run"}:             {Result: "y"},
+	}
+
+	if result := graph.BuildGraphUntil(habit, entries, to, 5, false); result != " YNS!Y" {
+		t.Errorf("Expected letter graph %q, got %q", " YNS!Y", result)
+	}
+
+	// Compressed graphs pick the most telling letter
+	habit.GraphDays = 6
+	if result := graph.BuildGraphUntil(habit, entries, to, 2, false); result != "YSY" {
+		t.Errorf("Expected compressed letter graph %q, got %q", "YSY", result)
+	}
+}
+
+func TestGraphNoteOverlay(t *testing.T) {
+	to := civil.Date{Year: 2025, Month: 3, Day: 14}
+	notes := storage.Notes{
+		to.AddDays(-4): {"Started new job"},
+		to.AddDays(-2): {"Flu"},
+		to:             {"Holiday"},
+		to.AddDays(-9): {"Out of view"},
+	}
+
+	// Days with outcomes or warnings keep their glyph
+	result := graph.NoteOverlay("━◌ ◌•!", notes, to)
+	if expected := "━┆ ┆•!"; result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestBuildNotedGraph(t *testing.T) {
+	to := civil.Date{Year: 2025, Month: 3, Day: 14}
+	habit := &storage.Habit{Name: "Run", Target: 1, Interval: 1, FirstRecord: to.AddDays(-3)}
+	entries := storage.Entries{
+		storage.DailyHabit{Day: to.AddDays(-3), Habit: "Run"}: {Result: "y"},
+		storage.DailyHabit{Day: to.AddDays(-2), Habit: "Run"}: {Result: "n"},
+		storage.DailyHabit{Day: to.AddDays(-1), Habit: "Run"}: {Result: "n"},
+		storage.DailyHabit{Day: to, Habit: "Run"}:             {Result: "y"},
+	}
+	notes := storage.Notes{to.AddDays(-2): {"Flu"}, to: {"Holiday"}}
+
+	if result := graph.BuildNotedGraphUntil(habit, &entries, notes, to, 3, false); result != "━┆ ━" {
+		t.Errorf("Expected the noted day marked, got %q", result)
+	}
+
+	defer func(symbols graph.GraphSymbols) { graph.Symbols = symbols }(graph.Symbols)
+	graph.Symbols = graph.Letters
+	if result := graph.BuildNotedGraphUntil(habit, &entries, notes, to, 3, false); result != "YNNY" {
+		t.Errorf("Expected letter graphs left unmarked, got %q", result)
+	}
+}
+
+func TestGraphFollowsClock(t *testing.T) {
+	day := civil.Date{Year: 2024, Month: 2, Day: 29}
+	habit := &storage.Habit{Name: "Run", Target: 1, Interval: 1, FirstRecord: day.AddDays(-3)}
+	entries := storage.Entries{
+		storage.DailyHabit{Day: day.AddDays(-3), Habit: "Run"}: {Result: "y"},
+		storage.DailyHabit{Day: day.AddDays(-1), Habit: "Run"}: {Result: "s"},
+		storage.DailyHabit{Day: day, Habit: "Run"}:             {Result: "y"},
+	}
+
+	defer func(previous clock.Clock) { clock.Default = previous }(clock.Default)
+	clock.Default = clock.Fixed(day)
+
+	if clock.Today() != day {
+		t.Fatalf("Expected the fixed clock to say %s, got %s", day, clock.Today())
+	}
+	expected := graph.BuildGraphUntil(habit, &entries, day, 5, false)
+	if got := graph.BuildGraph(habit, &entries, 5, false); got != expected {
+		t.Errorf("Expected the graph to end on the clock's date: %q, got %q", expected, got)
+	}
+}
+
+func TestGraphEmptyDays(t *testing.T) {
+	defer func(emptyDays string) { graph.EmptyDays = emptyDays }(graph.EmptyDays)
+	start := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habits := []*storage.Habit{
+		{Name: "Run", Target: 1, Interval: 1, FirstRecord: start},
+		{Name: "Coffee", Target: 0, Interval: 1, FirstRecord: start.AddDays(-2)},
+	}
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: start.AddDays(-2), Habit: "Coffee"}: {Result: "y"},
+		storage.DailyHabit{Day: start, Habit: "Run"}:                {Result: "y"},
+		storage.DailyHabit{Day: start.AddDays(1), Habit: "Run"}:     {Result: "s"},
+		storage.DailyHabit{Day: start.AddDays(2), Habit: "Run"}:     {Result: "n"},
+	}
+
+	if _, ok := graph.DayScore(start.AddDays(1), habits, entries); ok {
+		t.Error("Expected nothing to score on a day every habit was skipped")
+	}
+	if _, ok := graph.DayScore(start.AddDays(-1), habits, entries); ok {
+		t.Error("Expected nothing to score on a day with only tracking habits")
+	}
+	if score, ok := graph.DayScore(start, habits, entries); !ok || score != 100 {
+		t.Errorf("Expected 100 on a day Run was done, got %.1f (%v)", score, ok)
+	}
+
+	tests := []struct {
+		emptyDays string
+		score     float64
+		sparkline string
+		average   float64
+	}{
+		{graph.EmptyDaysNA, 0, "  ––█– ", 50},
+		{graph.EmptyDaysPerfect, 100, "  ████ ", 200.0 / 3},
+		{graph.EmptyDaysSkip, 0, "    █  ", 50},
+	}
+	for _, tt := range tests {
+		graph.EmptyDays = tt.emptyDays
+		// Score keeps scoring skipped days 100 whatever the setting
+		if score := graph.Score(start.AddDays(1), habits, entries); score != 100 {
+			t.Errorf("%s: expected a score of 100 for a skipped day, got %.1f", tt.emptyDays, score)
+		}
+		if score := graph.Score(start.AddDays(-1), habits, entries); score != tt.score {
+			t.Errorf("%s: expected a score of %.1f for a day with only tracking habits, got %.1f", tt.emptyDays, tt.score, score)
+		}
+		sparkline, _ := graph.BuildSpark(start.AddDays(-4), start.AddDays(2), habits, entries)
+		if got := strings.Join(sparkline, ""); got != tt.sparkline {
+			t.Errorf("%s: expected sparkline %q, got %q", tt.emptyDays, tt.sparkline, got)
+		}
+		if average := graph.AverageScores(start.AddDays(2), []int{7}, habits, entries)[0]; math.Abs(average-tt.average) > 0.01 {
+			t.Errorf("%s: expected a 7-day average of %.1f, got %.1f", tt.emptyDays, tt.average, average)
+		}
+	}
+}
+
+func TestGraphFrequencyHistory(t *testing.T) {
+	dir := t.TempDir()
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	changed := first.AddDays(14)
+	track := func(habit *storage.Habit, today civil.Date) {
+		t.Helper()
+		changes, err := storage.TrackFrequencies(dir, []*storage.Habit{habit}, today)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := storage.RecordFrequencyChanges(dir, changes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	habit := &storage.Habit{Name: "Gym", Frequency: "2/7", Target: 2, Interval: 7, FirstRecord: first}
+	track(habit, first)
+	// Edited in the habits file to a daily habit
+	habit = &storage.Habit{Name: "Gym", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first}
+
+	// Until recorded the change only applies to the run that saw it
+	if changes, err := storage.TrackFrequencies(dir, []*storage.Habit{habit}, changed); err != nil || len(changes) != 1 {
+		t.Fatalf("Expected the change found, got %v (%v)", changes, err)
+	}
+	if history, _ := storage.LoadFrequencyHistory(dir); len(history) != 1 {
+		t.Fatalf("Expected nothing recorded by tracking alone, got %v", history)
+	}
+	if then := habit.At(changed.AddDays(-1)); then.Frequency != "2/7" {
+		t.Errorf("Expected 2/7 before the unrecorded change, got %+v", then)
+	}
+
+	track(habit, changed)
+	track(habit, changed.AddDays(1))
+	history, err := storage.LoadFrequencyHistory(dir)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("Expected the first frequency and one change recorded, got %v (%v)", history, err)
+	}
+
+	if then := habit.At(changed.AddDays(-1)); then.Frequency != "2/7" || then.Target != 2 || then.Interval != 7 {
+		t.Errorf("Expected 2/7 before the change, got %+v", then)
+	}
+	if now := habit.At(changed); now != habit {
+		t.Errorf("Expected the habit itself from the change on, got %+v", now)
+	}
+
+	entries := storage.Entries{
+		storage.DailyHabit{Day: first, Habit: "Gym"}:              {Result: "y"},
+		storage.DailyHabit{Day: first.AddDays(1), Habit: "Gym"}:   {Result: "n"},
+		storage.DailyHabit{Day: first.AddDays(3), Habit: "Gym"}:   {Result: "y"},
+		storage.DailyHabit{Day: changed, Habit: "Gym"}:            {Result: "y"},
+		storage.DailyHabit{Day: changed.AddDays(1), Habit: "Gym"}: {Result: "n"},
+	}
+	habits := []*storage.Habit{habit}
+	if score, ok := graph.DayScore(first.AddDays(1), habits, &entries); !ok || score != 100 {
+		t.Errorf("Expected a missed day within the old 2/7 target to score 100, got %v", score)
+	}
+	if score, ok := graph.DayScore(changed.AddDays(1), habits, &entries); !ok || score != 0 {
+		t.Errorf("Expected a missed day after the change to daily to score 0, got %v", score)
+	}
+
+	// A change backdated to before one already recorded wins from its day on
+	backdated := storage.FrequencyChange{Since: first.AddDays(7), Habit: "Gym", Frequency: "3/7"}
+	if err := storage.RecordFrequencyChanges(dir, []storage.FrequencyChange{backdated}); err != nil {
+		t.Fatal(err)
+	}
+	history, _ = storage.LoadFrequencyHistory(dir)
+	storage.ApplyFrequencyHistory(habits, history)
+	if then := habit.At(first.AddDays(6)); then.Frequency != "2/7" {
+		t.Errorf("Expected 2/7 before the backdated change, got %s", then.Frequency)
+	}
+	if now := habit.At(changed.AddDays(1)); now.Frequency != "3/7" || now.Target != 3 {
+		t.Errorf("Expected the backdated 3/7 after the earlier recorded change, got %+v", now)
+	}
+}
+
+func TestGraphWeekdaySchedule(t *testing.T) {
+	habits, problems := storage.CheckHabitsConfig(strings.NewReader("Gym: Mon,wed, Fri\nRun: Mon,Funday\n"))
+	if len(habits) != 1 || len(problems) != 1 {
+		t.Fatalf("Expected Gym kept and Run refused for its unknown weekday, got %d habits and %v", len(habits), problems)
+	}
+	gym := habits[0]
+	if gym.Target != 1 || gym.Interval != 1 || len(gym.Days) != 3 {
+		t.Fatalf("Expected Gym expected once on three weekdays, got %+v", gym)
+	}
+
+	monday := civil.Date{Year: 2025, Month: 1, Day: 6}
+	gym.FirstRecord = monday
+	entries := storage.Entries{
+		storage.DailyHabit{Day: monday, Habit: "Gym"}:            {Result: "y"},
+		storage.DailyHabit{Day: monday.AddDays(1), Habit: "Gym"}: {Result: "n"},
+		storage.DailyHabit{Day: monday.AddDays(2), Habit: "Gym"}: {Result: "y"},
+	}
+	tuesday, thursday, friday := monday.AddDays(1), monday.AddDays(3), monday.AddDays(4)
+	if !graph.Satisfied(tuesday, gym, entries) || graph.Warning(thursday, gym, entries) {
+		t.Error("Expected nothing expected of Gym on days off")
+	}
+	if !graph.Warning(friday, gym, entries) {
+		t.Error("Expected a warning for Gym unlogged on a Friday")
+	}
+	if graph.BuildGraphUntil(gym, &entries, friday, 4, false) != "━─━─!" {
+		t.Errorf("Expected days off drawn as met, got %q", graph.BuildGraphUntil(gym, &entries, friday, 4, false))
+	}
+	if _, ok := graph.DayScore(thursday, habits, &entries); ok {
+		t.Error("Expected no score on a day every habit has off")
+	}
+}
@@ -2,13 +2,16 @@ package test
 
 import (
 	"fmt"
+	"os"
 	"testing"
 	"time"
 
 	"cloud.google.com/go/civil"
 	"github.com/wakatara/harsh/internal"
 	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/sample"
 	"github.com/wakatara/harsh/internal/storage"
+	"github.com/wakatara/harsh/internal/ui"
 )
 
 // BenchmarkSequentialGraphBuilding benchmarks original sequential approach
@@ -34,6 +37,100 @@ func BenchmarkParallelGraphBuilding(b *testing.B) {
 	}
 }
 
+// BenchmarkCommentSearch benchmarks searching a large commented log through
+// the trigram index, building the index included as harsh search does once
+// per run
+func BenchmarkCommentSearch(b *testing.B) {
+	entries := storage.Entries{}
+	start := civil.Date{Year: 2000, Month: 1, Day: 1}
+	for i := range 20000 {
+		comment := fmt.Sprintf("session %d felt strong", i)
+		if i%500 == 0 {
+			comment = "sore knee after the hill run"
+		}
+		entries[storage.DailyHabit{Day: start.AddDays(i), Habit: "Run"}] = storage.Outcome{Result: "y", Comment: comment}
+	}
+
+	for b.Loop() {
+		log := &storage.Log{Entries: entries}
+		_ = log.CommentIndex().Search("sore knee")
+	}
+}
+
+// BenchmarkGeneratedLoad benchmarks reading and graphing a large made-up
+// profile: the one in HARSH_BENCH_DIR, made with harsh gen, or else a year
+// of 50 habits
+func BenchmarkGeneratedLoad(b *testing.B) {
+	dir := os.Getenv("HARSH_BENCH_DIR")
+	if dir == "" {
+		dir = b.TempDir()
+		to := civil.DateOf(time.Now())
+		if err := sample.Write(dir, sample.Habits(50), to.AddYears(-1), to, 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for b.Loop() {
+		habits, _ := storage.LoadHabitsConfig(dir)
+		log := storage.LoadLog(dir)
+		_ = graph.BuildGraphsParallel(habits, &log.Entries, 100, false)
+	}
+}
+
+// BenchmarkIndexedFirstRecords benchmarks finding the first records of 50
+// habits over five years of log, building the log's habit index included
+func BenchmarkIndexedFirstRecords(b *testing.B) {
+	dir := b.TempDir()
+	to := civil.Date{Year: 2025, Month: 6, Day: 30}
+	if err := sample.Write(dir, sample.Habits(50), to.AddYears(-5), to, 1); err != nil {
+		b.Fatal(err)
+	}
+	habits, _ := storage.LoadHabitsConfig(dir)
+	log := storage.LoadLog(dir)
+
+	for b.Loop() {
+		log.IndexHabits()
+		log.FirstRecords(to.AddYears(-5), to, habits)
+	}
+}
+
+// BenchmarkStats benchmarks log stats and target windows over five years of
+// 50 habits, read day by day from the entries and from the log's habit index
+func BenchmarkStats(b *testing.B) {
+	dir := b.TempDir()
+	to := civil.Date{Year: 2025, Month: 6, Day: 30}
+	if err := sample.Write(dir, sample.Habits(50), to.AddYears(-5), to, 1); err != nil {
+		b.Fatal(err)
+	}
+	habits, _ := storage.LoadHabitsConfig(dir)
+	log := storage.LoadLog(dir)
+	log.FirstRecords(to.AddYears(-5), to, habits)
+	indexed := storage.LoadLog(dir)
+	indexed.IndexHabits()
+
+	for _, history := range []struct {
+		name    string
+		history storage.History
+	}{{"entries", log.Entries}, {"indexed", indexed}} {
+		b.Run("BuildStats/"+history.name, func(b *testing.B) {
+			for b.Loop() {
+				for _, habit := range habits {
+					_ = ui.BuildStatsUntil(habit, history.history, to)
+				}
+			}
+		})
+		b.Run("Satisfied/"+history.name, func(b *testing.B) {
+			for b.Loop() {
+				for _, habit := range habits {
+					for d := to.AddDays(-365); !d.After(to); d = d.AddDays(1) {
+						_ = graph.Satisfied(d, habit, history.history)
+					}
+				}
+			}
+		})
+	}
+}
+
 // createTestHarsh creates a test Harsh instance with sample data
 func createTestHarsh() *internal.Harsh {
 	log := storage.Log{}
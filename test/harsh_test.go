@@ -391,6 +391,30 @@ func TestScore(t *testing.T) {
 	}
 }
 
+func TestKeepTagged(t *testing.T) {
+	h := &internal.Harsh{
+		Habits: []*storage.Habit{
+			{Name: "Run 5k", Tags: []string{"fitness", "outdoor"}},
+			{Name: "Read a long book", Tags: []string{"mind"}},
+			{Name: "Gym", Tags: []string{"Fitness"}},
+		},
+		MaxHabitNameLength: 16 + storage.HabitNamePadding,
+	}
+
+	if h.KeepTagged([]string{"nothing"}) || len(h.GetHabits()) != 3 {
+		t.Fatalf("Expected no habits tagged #nothing and the habits left alone, got %d", len(h.GetHabits()))
+	}
+	if !h.KeepTagged([]string{"#fitness", "garden"}) {
+		t.Fatal("Expected habits tagged #fitness")
+	}
+	if len(h.GetHabits()) != 2 || h.GetHabits()[0].Name != "Run 5k" || h.GetHabits()[1].Name != "Gym" {
+		t.Errorf("Expected Run 5k and Gym, got %d habits", len(h.GetHabits()))
+	}
+	if h.GetMaxHabitNameLength() != len("Run 5k")+storage.HabitNamePadding {
+		t.Errorf("Expected the name column fitted to Run 5k, got %d", h.GetMaxHabitNameLength())
+	}
+}
+
 func TestConfigFileCreation(t *testing.T) {
 	// Create temporary directory for test
 	tmpDir, err := os.MkdirTemp("", "harsh_test")
@@ -814,6 +838,23 @@ func TestCLICommands(t *testing.T) {
 	cmd.RootCmd.SetArgs([]string{})
 }
 
+// A habit named like log's stats subcommand can't be recorded through
+// harsh log, so the subcommand refuses the result rather than ignore it
+func TestLogStatsRefusesResult(t *testing.T) {
+	for _, name := range []string{"stats", "s"} {
+		found, args, err := cmd.RootCmd.Find([]string{"log", name, "y"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := found.ValidateArgs(args); err == nil || !strings.Contains(err.Error(), "harsh done") {
+			t.Errorf("Expected harsh log %s y refused, pointing to harsh done, got %v", name, err)
+		}
+		if err := found.ValidateArgs(nil); err != nil {
+			t.Errorf("Expected harsh log %s alone accepted, got %v", name, err)
+		}
+	}
+}
+
 // Test parallel graph building
 func TestBuildGraphsParallel(t *testing.T) {
 	log := &storage.Log {
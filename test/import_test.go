@@ -0,0 +1,146 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+func TestImportStreaksCSV(t *testing.T) {
+	export := `title,category,entry_type,entry_date,entry_quantity,entry_note,task_frequency
+Meditate,Mind,completed_manually,2025-03-01,10,calm: focused,every day
+Meditate,Mind,missed_auto,2025-03-02,,,every day
+Stretch,,skipped,2025-03-01T08:00:00Z,,,"Mon, Wed, Fri"
+Gym,,completed_manually,2025-03-01,,,3 times per week
+Odd,,weird_state,2025-03-01,,,every fortnight
+`
+	report, err := storage.ImportStreaksCSV(strings.NewReader(export), storage.DetectDateOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frequencies := map[string]string{}
+	for _, habit := range report.Habits {
+		frequencies[habit.Name] = habit.Frequency
+	}
+	want := map[string]string{"Meditate": "1", "Stretch": "Mon,Wed,Fri", "Gym": "3/7", "Odd": "1"}
+	for name, frequency := range want {
+		if frequencies[name] != frequency {
+			t.Errorf("Expected %s frequency %s, got %q", name, frequency, frequencies[name])
+		}
+	}
+	if report.Habits[0].Heading != "Mind" {
+		t.Errorf("Expected category to become heading, got %q", report.Habits[0].Heading)
+	}
+
+	meditate := report.Entries[storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 3, Day: 1}, Habit: "Meditate"}]
+	if meditate.Result != "y" || meditate.Amount != 10 || meditate.Comment != "calm focused" {
+		t.Errorf("Meditate entry imported incorrectly: %+v", meditate)
+	}
+	if report.Entries[storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 3, Day: 2}, Habit: "Meditate"}].Result != "n" {
+		t.Error("Missed entries should import as n")
+	}
+	if report.Entries[storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 3, Day: 1}, Habit: "Stretch"}].Result != "s" {
+		t.Error("Skipped entries with timestamps should import as s")
+	}
+	if len(report.Entries) != 4 {
+		t.Errorf("Expected 4 entries, got %d", len(report.Entries))
+	}
+
+	// Unknown schedules and unknown states are reported
+	if len(report.Unsupported) != 2 {
+		t.Errorf("Expected 2 unsupported notes, got %v", report.Unsupported)
+	}
+}
+
+func TestImportHabitify(t *testing.T) {
+	csvExport := "Habit Name,Date,Status,Value,Note,Goal Period\nRead,2025-02-01,Completed,20,,weekly\nRead,2025-02-02,Failed,,,weekly\n"
+	report, err := storage.ImportHabitifyCSV(strings.NewReader(csvExport), storage.DetectDateOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Habits) != 1 || report.Habits[0].Frequency != "7" || report.Habits[0].Interval != 7 {
+		t.Errorf("Habitify CSV habits imported incorrectly: %+v", report.Habits)
+	}
+	if len(report.Entries) != 2 || len(report.Unsupported) != 0 {
+		t.Errorf("Expected 2 entries and nothing unsupported, got %d entries, %v", len(report.Entries), report.Unsupported)
+	}
+
+	jsonExport := `[{"name": "Walk", "area": "Health", "goal": {"periodicity": "2 times per month"},
+		"logs": [{"date": "2025-02-01", "status": "completed", "value": 3, "note": "park"}]}]`
+	report, err = storage.ImportHabitifyJSON(strings.NewReader(jsonExport), storage.DetectDateOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Habits) != 1 || report.Habits[0].Frequency != "2/30" || report.Habits[0].Heading != "Health" {
+		t.Errorf("Habitify JSON habits imported incorrectly: %+v", report.Habits)
+	}
+	walk := report.Entries[storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 2, Day: 1}, Habit: "Walk"}]
+	if walk.Result != "y" || walk.Amount != 3 || walk.Comment != "park" {
+		t.Errorf("Walk entry imported incorrectly: %+v", walk)
+	}
+
+	if _, err := storage.ImportHabitifyCSV(strings.NewReader("Name,When\nRead,today\n"), storage.DetectDateOrder); err == nil {
+		t.Error("Expected error for export missing required columns")
+	}
+}
+
+func TestImportDateOrder(t *testing.T) {
+	export := "Habit Name,Date,Status\nRead,03/04/2025,Completed\nRead,03/14/2025,Completed\n"
+	report, err := storage.ImportHabitifyCSV(strings.NewReader(export), storage.DetectDateOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := report.Entries[storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 3, Day: 4}, Habit: "Read"}]; !ok {
+		t.Errorf("Expected month first dates read as such, got %v", report.Entries)
+	}
+
+	// Nothing says which way round these are
+	ambiguous := "Habit Name,Date,Status\nRead,03/04/2025,Completed\n"
+	if _, err := storage.ImportHabitifyCSV(strings.NewReader(ambiguous), storage.DetectDateOrder); err == nil {
+		t.Error("Expected ambiguous dates to need a date order")
+	}
+	report, err = storage.ImportHabitifyCSV(strings.NewReader(ambiguous), storage.DayFirst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := report.Entries[storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 4, Day: 3}, Habit: "Read"}]; !ok {
+		t.Errorf("Expected day first dates read as such, got %v", report.Entries)
+	}
+
+	mixed := "Habit Name,Date,Status\nRead,03/14/2025,Completed\nRead,14/03/2025,Completed\n"
+	if _, err := storage.ImportHabitifyCSV(strings.NewReader(mixed), storage.DetectDateOrder); err == nil {
+		t.Error("Expected dates in both orders to be refused")
+	}
+}
+
+func TestAppendHabitsConfig(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "harsh_import_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// A hand-edited file without a trailing newline
+	os.WriteFile(filepath.Join(tmpDir, "habits"), []byte("! Work\nStandup: 1"), 0644)
+
+	err = storage.AppendHabitsConfig(tmpDir, []*storage.Habit{
+		{Heading: "Mind", Name: "Meditate", Frequency: "1"},
+		{Heading: "Mind", Name: "Journal", Frequency: "3/7"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	habits, _ := storage.LoadHabitsConfig(tmpDir)
+	if len(habits) != 3 {
+		t.Fatalf("Expected 3 habits after append, got %d", len(habits))
+	}
+	if habits[0].Name != "Standup" || habits[1].Heading != "Mind" || habits[2].Target != 3 {
+		t.Errorf("Appended habits parsed incorrectly: %+v %+v %+v", habits[0], habits[1], habits[2])
+	}
+}
@@ -0,0 +1,191 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/wakatara/harsh/cmd"
+	"github.com/wakatara/harsh/internal/keychain"
+)
+
+// fakeSecretTool stands in for secret-tool, keeping secrets as files in a
+// temporary directory, and points keychain.FallbackFile somewhere temporary
+func fakeSecretTool(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("the keychain only uses secret-tool on Linux and the BSDs")
+	}
+	bin := t.TempDir()
+	script := `#!/bin/sh
+case "$1" in
+lookup) [ -f "$FAKE_KEYRING/$5" ] || exit 1; /bin/cat "$FAKE_KEYRING/$5" ;;
+store) /bin/cat > "$FAKE_KEYRING/$7" ;;
+clear) /bin/rm -f "$FAKE_KEYRING/$5" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(bin, "secret-tool"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	t.Setenv("FAKE_KEYRING", t.TempDir())
+	fallbackFile(t)
+}
+
+// noKeychain leaves the keychain unreachable, so credentials go in a
+// temporary keychain.FallbackFile
+func noKeychain(t *testing.T) string {
+	t.Setenv("PATH", t.TempDir())
+	return fallbackFile(t)
+}
+
+func fallbackFile(t *testing.T) string {
+	old := keychain.FallbackFile
+	keychain.FallbackFile = filepath.Join(t.TempDir(), "harsh", "credentials")
+	t.Cleanup(func() { keychain.FallbackFile = old })
+	return keychain.FallbackFile
+}
+
+func TestKeychainStoresInSecretTool(t *testing.T) {
+	fakeSecretTool(t)
+
+	where, err := keychain.Set("smtp", "hunter2")
+	if err != nil || where != keychain.Keychain {
+		t.Fatalf("Expected the secret in the keychain, got %q (%v)", where, err)
+	}
+	if secret, where, err := keychain.Get("smtp"); err != nil || secret != "hunter2" || where != keychain.Keychain {
+		t.Errorf("Expected hunter2 from the keychain, got %q from %q (%v)", secret, where, err)
+	}
+	if _, err := os.Stat(keychain.FallbackFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected no credentials file with a keychain, got %v", err)
+	}
+	if err := keychain.Remove("smtp"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := keychain.Get("smtp"); !errors.Is(err, keychain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after removing, got %v", err)
+	}
+	if err := keychain.Remove("smtp"); !errors.Is(err, keychain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound removing twice, got %v", err)
+	}
+}
+
+func TestKeychainFallsBackToFile(t *testing.T) {
+	file := noKeychain(t)
+
+	for account, secret := range map[string]string{"smtp": "hunter2", "mqtt": "broker pass"} {
+		if where, err := keychain.Set(account, secret); err != nil || where != file {
+			t.Fatalf("Expected %s in %s, got %q (%v)", account, file, where, err)
+		}
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the credentials file readable only by you, got %v", info.Mode().Perm())
+	}
+	if secret, where, err := keychain.Get("mqtt"); err != nil || secret != "broker pass" || where != file {
+		t.Errorf("Expected the mqtt password from the file, got %q from %q (%v)", secret, where, err)
+	}
+	if _, err := keychain.Set("smtp", "line\nbreak"); err == nil {
+		t.Error("Expected secrets with line breaks refused")
+	}
+
+	if err := keychain.Remove("smtp"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := keychain.Get("smtp"); !errors.Is(err, keychain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after removing, got %v", err)
+	}
+	if secret, _, err := keychain.Get("mqtt"); err != nil || secret != "broker pass" {
+		t.Errorf("Expected the mqtt password kept, got %q (%v)", secret, err)
+	}
+	if err := keychain.Remove("smtp"); !errors.Is(err, keychain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound removing twice, got %v", err)
+	}
+}
+
+// A credentials file loosened by hand is made private again on the next write
+func TestKeychainFileStaysPrivate(t *testing.T) {
+	file := noKeychain(t)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("mqtt: broker pass\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keychain.Set("smtp", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the credentials file made private, got %v", info.Mode().Perm())
+	}
+	if secret, _, err := keychain.Get("mqtt"); err != nil || secret != "broker pass" {
+		t.Errorf("Expected the mqtt password kept, got %q (%v)", secret, err)
+	}
+}
+
+// Once there's a keychain, a copy stored in the file before mustn't linger
+func TestKeychainReplacesFileCopy(t *testing.T) {
+	file := noKeychain(t)
+	if _, err := keychain.Set("smtp", "old"); err != nil {
+		t.Fatal(err)
+	}
+	fakeSecretTool(t)
+	keychain.FallbackFile = file
+
+	if secret, where, err := keychain.Get("smtp"); err != nil || secret != "old" || where != file {
+		t.Errorf("Expected the file's copy until the keychain has one, got %q from %q (%v)", secret, where, err)
+	}
+	if where, err := keychain.Set("smtp", "new"); err != nil || where != keychain.Keychain {
+		t.Fatalf("Expected the secret in the keychain, got %q (%v)", where, err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Errorf("Expected the file's copy removed, got %q", data)
+	}
+}
+
+func TestAuthCredentials(t *testing.T) {
+	noKeychain(t)
+	t.Setenv("HARSH_SMTP_PASSWORD", "")
+
+	if _, err := cmd.StoreCredential("ftp", "x"); err == nil {
+		t.Error("Expected an unknown service refused")
+	}
+	if _, err := cmd.StoreCredential("smtp", "  \n"); err == nil {
+		t.Error("Expected an empty secret refused")
+	}
+	if _, err := cmd.StoreCredential("SMTP", " hunter2\n"); err != nil {
+		t.Fatal(err)
+	}
+	if secret := cmd.LookupCredential("smtp"); secret != "hunter2" {
+		t.Errorf("Expected the stored secret, trimmed, got %q", secret)
+	}
+	t.Setenv("HARSH_SMTP_PASSWORD", "from-env")
+	if secret := cmd.LookupCredential("smtp"); secret != "from-env" {
+		t.Errorf("Expected the environment variable to win, got %q", secret)
+	}
+	t.Setenv("HARSH_SMTP_PASSWORD", "")
+
+	if err := cmd.RemoveCredential("smtp"); err != nil {
+		t.Fatal(err)
+	}
+	if secret := cmd.LookupCredential("smtp"); secret != "" {
+		t.Errorf("Expected nothing after removing, got %q", secret)
+	}
+	if err := cmd.RemoveCredential("smtp"); err == nil {
+		t.Error("Expected removing a missing secret to fail")
+	} else if code := cmd.ExitCode(err); code != cmd.ExitNotFound {
+		t.Errorf("Expected exit code %d removing a missing secret, got %d", cmd.ExitNotFound, code)
+	}
+}
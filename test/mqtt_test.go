@@ -0,0 +1,124 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/wakatara/harsh/internal/mqtt"
+)
+
+// fakeBroker accepts one MQTT session, answering CONNECT with returnCode,
+// and sends what the client wrote after CONNECT to received
+func fakeBroker(t *testing.T, returnCode byte) (string, <-chan [2][]byte) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan [2][]byte, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		header := make([]byte, 2)
+		io.ReadFull(r, header)
+		connect := make([]byte, header[1])
+		io.ReadFull(r, connect)
+		conn.Write([]byte{0x20, 2, 0, returnCode})
+		rest, _ := io.ReadAll(r)
+		received <- [2][]byte{connect, rest}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestMQTTStateMessages(t *testing.T) {
+	state := mqtt.State{
+		Date:   "2025-03-14",
+		Score:  66.666,
+		Todos:  1,
+		Habits: []mqtt.HabitState{{Name: "Push ups!", Status: mqtt.HabitStatus("y")}, {Name: "Health/Run", Status: mqtt.HabitStatus("")}},
+	}
+	messages, err := mqtt.StateMessages("home/harsh/", state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"home/harsh/score":             "66.7",
+		"home/harsh/todos":             "1",
+		"home/harsh/habits/push_ups":   "done",
+		"home/harsh/habits/health_run": "todo",
+	}
+	for _, message := range messages {
+		if want, ok := expected[message.Topic]; ok && string(message.Payload) != want {
+			t.Errorf("Expected %s to be %q, got %q", message.Topic, want, message.Payload)
+		}
+		delete(expected, message.Topic)
+		if !message.Retain {
+			t.Errorf("Expected %s to be retained", message.Topic)
+		}
+	}
+	if len(expected) != 0 {
+		t.Errorf("Expected topics missing: %v", expected)
+	}
+	if messages[0].Topic != "home/harsh/state" || !strings.Contains(string(messages[0].Payload), `"yesterday_score":0`) {
+		t.Errorf("Expected the whole state as JSON first, got %s %s", messages[0].Topic, messages[0].Payload)
+	}
+}
+
+func TestMQTTPublish(t *testing.T) {
+	address, received := fakeBroker(t, 0)
+	broker := mqtt.Broker{Address: address, User: "harsh", Password: "secret", ClientID: "harsh-test"}
+	if err := mqtt.Publish(broker, []mqtt.Message{{Topic: "harsh/todos", Payload: []byte("2"), Retain: true}}); err != nil {
+		t.Fatal(err)
+	}
+	session := <-received
+	connect, rest := session[0], session[1]
+	for _, want := range []string{"\x00\x04MQTT\x04\xc2", "\x00\x0aharsh-test", "\x00\x05harsh", "\x00\x06secret"} {
+		if !bytes.Contains(connect, []byte(want)) {
+			t.Errorf("Expected CONNECT to contain %q, got %q", want, connect)
+		}
+	}
+	if expected := "\x31\x0e\x00\x0bharsh/todos2\xe0\x00"; string(rest) != expected {
+		t.Errorf("Expected a retained PUBLISH then DISCONNECT %q, got %q", expected, rest)
+	}
+
+	address, _ = fakeBroker(t, 4)
+	err := mqtt.Publish(mqtt.Broker{Address: address}, nil)
+	if err == nil || !strings.Contains(err.Error(), "bad user name or password") {
+		t.Errorf("Expected a refused login to be reported, got %v", err)
+	}
+}
+
+func TestMQTTPlaintext(t *testing.T) {
+	for address, want := range map[string]bool{
+		"homeassistant.local:1883":      true,
+		"tcp://192.168.1.5":             true,
+		"ssl://homeassistant.local":     false,
+		"tls://homeassistant.local:443": false,
+		"localhost":                     false,
+		"127.0.0.1:1883":                false,
+		"[::1]:1883":                    false,
+	} {
+		if got := (mqtt.Broker{Address: address, Password: "secret"}).Plaintext(); got != want {
+			t.Errorf("%s: expected plaintext %v, got %v", address, want, got)
+		}
+	}
+	if (mqtt.Broker{Address: "homeassistant.local"}).Plaintext() {
+		t.Error("Expected no warning without a password to send")
+	}
+}
+
+func TestMQTTSlugs(t *testing.T) {
+	got := mqtt.Slugs([]string{"Push ups", "Push-ups", "!!", "push ups!", "Run"})
+	want := []string{"push_ups", "push_ups_2", "", "push_ups_3", "run"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,71 @@
+package test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/query"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+func TestQuery(t *testing.T) {
+	jan := func(day int) civil.Date { return civil.Date{Year: 2025, Month: 1, Day: day} }
+	entries := storage.Entries{
+		storage.DailyHabit{Day: jan(1), Habit: "Run"}:           {Result: "y", Amount: 3},
+		storage.DailyHabit{Day: jan(2), Habit: "Run"}:           {Result: "y", Amount: 8},
+		storage.DailyHabit{Day: jan(3), Habit: "Run"}:           {Result: "s", Comment: "Sick day"},
+		storage.DailyHabit{Day: jan(2), Habit: "Morning Pages"}: {Result: "n"},
+		storage.DailyHabit{Day: jan(4), Habit: "Morning Pages"}: {Result: "y", Comment: "felt sick"},
+	}
+
+	tests := []struct {
+		query string
+		want  []storage.DailyHabit
+	}{
+		{"habit=run and result=y and amount>5", []storage.DailyHabit{{Day: jan(2), Habit: "Run"}}},
+		{`habit="Morning Pages"`, []storage.DailyHabit{{Day: jan(2), Habit: "Morning Pages"}, {Day: jan(4), Habit: "Morning Pages"}}},
+		{"comment~sick", []storage.DailyHabit{{Day: jan(3), Habit: "Run"}, {Day: jan(4), Habit: "Morning Pages"}}},
+		{"result=y since 2025-01-02 until 2025-01-03", []storage.DailyHabit{{Day: jan(2), Habit: "Run"}}},
+		{"not result=y and (habit=run or date>=2025-01-02)", []storage.DailyHabit{{Day: jan(2), Habit: "Morning Pages"}, {Day: jan(3), Habit: "Run"}}},
+		{"result=n or amount>=8 and result=y", []storage.DailyHabit{{Day: jan(2), Habit: "Morning Pages"}, {Day: jan(2), Habit: "Run"}}},
+		{"since 2025-01-04", []storage.DailyHabit{{Day: jan(4), Habit: "Morning Pages"}}},
+	}
+	for _, tt := range tests {
+		q, err := query.Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.query, err)
+			continue
+		}
+		got := q.Filter(entries)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: expected %v, got %v", tt.query, tt.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	for _, input := range []string{
+		"habit",
+		"habit=",
+		"mood=good",
+		"amount~5",
+		"amount>lots",
+		"result=maybe",
+		"(habit=Run",
+		`habit="Run`,
+		"habit=Run since yesterday",
+		"habit=Run habit=Gym",
+		"habit!Run",
+	} {
+		if _, err := query.Parse(input); err == nil {
+			t.Errorf("Expected Parse(%q) to fail", input)
+		}
+	}
+}
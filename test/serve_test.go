@@ -0,0 +1,161 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wakatara/harsh/cmd"
+	"github.com/wakatara/harsh/internal"
+	"github.com/wakatara/harsh/internal/storage"
+)
+
+// serveConfig sets up a config directory with a Gym habit and serves it
+func serveConfig(t *testing.T, token string) (string, http.Handler) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "habits"), []byte("Gym: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "log"), []byte("2025-01-01 : Gym : y :  : \n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HARSHPATH", dir)
+	return dir, cmd.ServeHandler(internal.NewHarsh(), token, false)
+}
+
+func serveRequest(handler http.Handler, method string, target string, body string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, value := range header {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestServeRequiresToken(t *testing.T) {
+	_, handler := serveConfig(t, "secret")
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"No token", "", http.StatusUnauthorized},
+		{"Wrong token", "Bearer wrong", http.StatusUnauthorized},
+		{"Token without Bearer", "secret", http.StatusUnauthorized},
+		{"Right token", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveRequest(handler, "GET", "/api/habits", "", map[string]string{"Authorization": tt.authorization})
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestServeListensOnLoopbackWithoutToken(t *testing.T) {
+	tests := []struct {
+		listen string
+		token  string
+		ok     bool
+	}{
+		{"127.0.0.1:8765", "", true},
+		{"localhost:8765", "", true},
+		{"[::1]:8765", "", true},
+		{"0.0.0.0:8765", "", false},
+		{"192.168.1.2:8765", "", false},
+		{":8765", "", false},
+		{"0.0.0.0:8765", "secret", true},
+	}
+	for _, tt := range tests {
+		if err := cmd.CheckListen(tt.listen, tt.token); (err == nil) != tt.ok {
+			t.Errorf("CheckListen(%q, %q) = %v, expected allowed %v", tt.listen, tt.token, err, tt.ok)
+		}
+	}
+}
+
+func TestServeRecordsEntry(t *testing.T) {
+	dir, handler := serveConfig(t, "")
+
+	w := serveRequest(handler, "POST", "/api/entries", `{"habit": "gym", "result": "y", "date": "2025-01-02", "amount": 45}`,
+		map[string]string{"Content-Type": "application/json"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var entry struct {
+		Date   string  `json:"date"`
+		Habit  string  `json:"habit"`
+		Result string  `json:"result"`
+		Amount float64 `json:"amount"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Date != "2025-01-02" || entry.Habit != "Gym" || entry.Result != "y" || entry.Amount != 45 {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+	log, err := os.ReadFile(filepath.Join(dir, "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(log), "2025-01-02 : Gym : y") {
+		t.Errorf("Expected the entry in the log, got:\n%s", log)
+	}
+	if history, err := storage.LoadFrequencyHistory(dir); err != nil || len(history) != 1 || history[0].Habit != "Gym" {
+		t.Errorf("Expected Gym's frequency recorded with the entry, got %v (%v)", history, err)
+	}
+}
+
+func TestServeRefusesEntry(t *testing.T) {
+	_, handler := serveConfig(t, "")
+
+	tests := []struct {
+		name   string
+		body   string
+		header map[string]string
+		want   int
+	}{
+		{"Form post", "habit=gym", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, http.StatusForbidden},
+		{"No content type", `{"habit": "gym"}`, nil, http.StatusForbidden},
+		{"Other site", `{"habit": "gym"}`, map[string]string{"Content-Type": "application/json", "Origin": "https://evil.test"}, http.StatusForbidden},
+		{"Unknown habit", `{"habit": "swim"}`, map[string]string{"Content-Type": "application/json"}, http.StatusNotFound},
+		{"Invalid result", `{"habit": "gym", "result": "x"}`, map[string]string{"Content-Type": "application/json"}, http.StatusBadRequest},
+		{"Invalid JSON", `{"habit": `, map[string]string{"Content-Type": "application/json"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveRequest(handler, "POST", "/api/entries", tt.body, tt.header)
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+// A habits file caught half-saved mustn't take the server down
+func TestServeKeepsHabitsWhenReloadFails(t *testing.T) {
+	dir, handler := serveConfig(t, "")
+
+	if err := os.WriteFile(filepath.Join(dir, "habits"), []byte("Gym: 1\nSwim: nonsense\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := serveRequest(handler, "GET", "/api/habits", "", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Gym") {
+		t.Fatalf("Expected the habits read before, got %d: %s", w.Code, w.Body)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "habits"), []byte("Gym: 1\nSwim: 7\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w = serveRequest(handler, "GET", "/api/habits", "", nil)
+	if !strings.Contains(w.Body.String(), "Swim") {
+		t.Errorf("Expected the fixed habits reread, got %d: %s", w.Code, w.Body)
+	}
+}
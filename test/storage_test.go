@@ -1,12 +1,28 @@
 package test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"math"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/civil"
+	"github.com/wakatara/harsh/internal/clock"
+	"github.com/wakatara/harsh/internal/sample"
 	"github.com/wakatara/harsh/internal/storage"
 )
 
@@ -16,14 +32,19 @@ func TestHabitParseHabitFrequency(t *testing.T) {
 		frequency string
 		target    int
 		interval  int
+		days      int
 		shouldErr bool
 	}{
-		{"Daily habit", "1", 1, 1, false},
-		{"Weekly habit", "7", 1, 7, false},
-		{"Three times weekly", "3/7", 3, 7, false},
-		{"Tracking only", "0", 0, 1, false},
-		{"Monthly habit", "30", 1, 30, false},
-		{"Twice daily", "2/2", 2, 2, false},
+		{"Daily habit", "1", 1, 1, 0, false},
+		{"Weekly habit", "7", 1, 7, 0, false},
+		{"Three times weekly", "3/7", 3, 7, 0, false},
+		{"Tracking only", "0", 0, 1, 0, false},
+		{"Monthly habit", "30", 1, 30, 0, false},
+		{"Twice daily", "2/2", 2, 2, 0, false},
+		{"Weekly in weeks", "1w", 1, 7, 0, false},
+		{"Bare week", "w", 1, 7, 0, false},
+		{"Twice a week", "2/w", 2, 7, 0, false},
+		{"Weekdays", "Mon,Wed,Fri", 1, 1, 3, false},
 	}
 
 	for _, tt := range tests {
@@ -38,9 +59,9 @@ func TestHabitParseHabitFrequency(t *testing.T) {
 			}
 
 			h.ParseHabitFrequency()
-			if h.Target != tt.target || h.Interval != tt.interval {
-				t.Errorf("got target=%d interval=%d, want target=%d interval=%d",
-					h.Target, h.Interval, tt.target, tt.interval)
+			if h.Target != tt.target || h.Interval != tt.interval || len(h.Days) != tt.days {
+				t.Errorf("got target=%d interval=%d days=%v, want target=%d interval=%d and %d days",
+					h.Target, h.Interval, h.Days, tt.target, tt.interval, tt.days)
 			}
 		})
 	}
@@ -94,6 +115,245 @@ Sleep tracking: 0
 	}
 }
 
+func TestCheckHabitsConfig(t *testing.T) {
+	config := `# habits
+! Health
+Gym: 3/7
+Stretch 1
+Read:
+: 1
+!Work
+Gym: 1
+Standup: 8/7
+Plan : Review: 1
+`
+	habits, problems := storage.CheckHabitsConfig(strings.NewReader(config))
+
+	// Habits without a frequency load as daily ones
+	names := []string{"Gym", "Stretch 1", "Read"}
+	if len(habits) != len(names) {
+		t.Fatalf("Expected %v to load, got %v", names, habits)
+	}
+	for i, name := range names {
+		if habits[i].Name != name || habits[i].Heading != "Health" || habits[i].Interval != []int{7, 1, 1}[i] {
+			t.Errorf("Expected %s under Health, got %+v", name, habits[i])
+		}
+	}
+
+	expected := []struct {
+		line  int
+		fatal bool
+		hint  string
+	}{
+		{4, false, `Did you mean "Stretch: 1"?`},
+		{6, false, "name before the colon"},
+		{7, false, `"! Work"`},
+		{8, false, "already defined at line 3"},
+		{9, true, "3/7"},
+		{10, true, "Remove or replace the colon"},
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("Expected %d problems, got %d: %+v", len(expected), len(problems), problems)
+	}
+	for i, want := range expected {
+		got := problems[i]
+		if got.Line != want.line || got.Fatal != want.fatal || !strings.Contains(got.Suggestion, want.hint) {
+			t.Errorf("Problem %d: expected line %d fatal=%v suggesting %q, got %+v", i, want.line, want.fatal, want.hint, got)
+		}
+	}
+}
+
+func TestCheckHabitsConfigQualifiesDuplicateNames(t *testing.T) {
+	config := `Stretch: 1
+! Health
+Stretch: 1
+Walk: 1
+! Work
+Stretch: 3/7
+Walk: 1
+`
+	habits, problems := storage.CheckHabitsConfig(strings.NewReader(config))
+
+	// Only the later Walk and Stretches are qualified, the first keeps its name
+	names := []string{"Stretch", "Health/Stretch", "Walk", "Work/Stretch", "Work/Walk"}
+	if len(problems) != 0 || len(habits) != len(names) {
+		t.Fatalf("Expected %d habits and no problems, got %d habits and %+v", len(names), len(habits), problems)
+	}
+	for i, name := range names {
+		if habits[i].Name != name {
+			t.Errorf("Habit %d: expected %q, got %q", i, name, habits[i].Name)
+		}
+	}
+
+	// An explicit qualified name can't be shared with a derived one
+	_, problems = storage.CheckHabitsConfig(strings.NewReader("! A\nB: 1\n! C\nB: 1\nC/B: 1\n"))
+	if len(problems) != 1 || !problems[0].Fatal || problems[0].Line != 5 {
+		t.Errorf("Expected a fatal clash at line 5, got %+v", problems)
+	}
+}
+
+func TestCheckHabitsConfigKeepsNamesWhenDuplicated(t *testing.T) {
+	before, _ := storage.CheckHabitsConfig(strings.NewReader("! Health\nStretch: 1\n"))
+	after, problems := storage.CheckHabitsConfig(strings.NewReader("! Health\nStretch: 1\n! Work\nStretch: 1\n"))
+	if len(problems) != 0 || len(after) != 2 {
+		t.Fatalf("Expected 2 habits and no problems, got %d and %+v", len(after), problems)
+	}
+	if after[0].Name != before[0].Name || after[1].Name != "Work/Stretch" {
+		t.Errorf("Expected Stretch kept and the new one qualified, got %q and %q", after[0].Name, after[1].Name)
+	}
+}
+
+func TestCheckHabitsConfigHeadingDefaults(t *testing.T) {
+	config := `! Health (default 3/7)
+Gym
+Stretch:
+Walk: 1
+! Work
+Standup: 1
+! Bad (default 9/7)
+`
+	habits, problems := storage.CheckHabitsConfig(strings.NewReader(config))
+
+	expected := []struct {
+		heading, name, frequency string
+		target, interval         int
+	}{
+		{"Health", "Gym", "3/7", 3, 7},
+		{"Health", "Stretch", "3/7", 3, 7},
+		{"Health", "Walk", "1", 1, 1},
+		{"Work", "Standup", "1", 1, 1},
+	}
+	if len(habits) != len(expected) {
+		t.Fatalf("Expected %d habits, got %d", len(expected), len(habits))
+	}
+	for i, want := range expected {
+		got := habits[i]
+		if got.Heading != want.heading || got.Name != want.name || got.Frequency != want.frequency || got.Target != want.target || got.Interval != want.interval {
+			t.Errorf("Habit %d: expected %+v, got %+v", i, want, *got)
+		}
+	}
+
+	if len(problems) != 1 || !problems[0].Fatal || problems[0].Line != 7 {
+		t.Errorf("Expected a fatal problem for the bad default at line 7, got %+v", problems)
+	}
+}
+
+func TestCheckHabitsConfigOptions(t *testing.T) {
+	config := `Pay rent: 30 graphdays=365
+Water: 1 graphdays=lots
+Read: 3 / 7 colour=red
+Stretch: 1 autofill=no
+Heavy lifting: 3/7 minGap=1
+Weight: 0 hidden=yes
+Glasses of water: 8/1 count=times
+`
+	habits, problems := storage.CheckHabitsConfig(strings.NewReader(config))
+
+	if len(habits) != 7 {
+		t.Fatalf("Expected 7 habits, got %d", len(habits))
+	}
+	if !habits[6].CountTimes || habits[5].CountTimes {
+		t.Errorf("Expected only Glasses of water to count times, got %+v", habits)
+	}
+	if !habits[5].Hidden || habits[4].Hidden {
+		t.Errorf("Expected only Weight hidden, got %+v", habits)
+	}
+	if habits[4].MinGap != 1 || habits[4].Frequency != "3/7" || habits[0].MinGap != 0 {
+		t.Errorf("Expected only Heavy lifting to need a rest day, got %+v", habits)
+	}
+	if !habits[3].NoAutofill || habits[0].NoAutofill {
+		t.Errorf("Expected only Stretch to opt out of autofill, got %+v", habits)
+	}
+	if habits[0].GraphDays != 365 || habits[0].Interval != 30 {
+		t.Errorf("Expected Pay rent every 30 days over 365 graph days, got %+v", *habits[0])
+	}
+	if habits[1].GraphDays != 0 || habits[2].Target != 3 || habits[2].Interval != 7 {
+		t.Errorf("Expected bad options to be ignored, got %+v and %+v", *habits[1], *habits[2])
+	}
+	if len(problems) != 2 || problems[0].Line != 2 || problems[1].Line != 3 || problems[0].Fatal || problems[1].Fatal {
+		t.Errorf("Expected warnings for lines 2 and 3, got %+v", problems)
+	}
+}
+
+func TestCheckHabitsConfigAmountGoal(t *testing.T) {
+	config := `Pushups: 1 @ 50
+Run: 3/7 @2.5 hidden=yes
+Plank: 1 @ lots
+! Strength (default 1)
+Squats: @ 20
+`
+	habits, problems := storage.CheckHabitsConfig(strings.NewReader(config))
+
+	if len(habits) != 4 {
+		t.Fatalf("Expected 4 habits, got %d with problems %+v", len(habits), problems)
+	}
+	if habits[0].AmountGoal != 50 || habits[0].Frequency != "1" {
+		t.Errorf("Expected Pushups daily with a goal of 50, got %+v", *habits[0])
+	}
+	if habits[1].AmountGoal != 2.5 || habits[1].Frequency != "3/7" || !habits[1].Hidden {
+		t.Errorf("Expected Run 3/7 with a goal of 2.5 and its option, got %+v", *habits[1])
+	}
+	if habits[2].AmountGoal != 0 || habits[2].Frequency != "1" {
+		t.Errorf("Expected Plank's bad goal ignored, got %+v", *habits[2])
+	}
+	if habits[3].AmountGoal != 20 || habits[3].Frequency != "1" {
+		t.Errorf("Expected Squats to take the default frequency with a goal of 20, got %+v", *habits[3])
+	}
+	if len(problems) != 1 || problems[0].Line != 3 || problems[0].Fatal {
+		t.Errorf("Expected a warning for line 3, got %+v", problems)
+	}
+
+	if habits[0].Completions(storage.Outcome{Result: "y", Amount: 49}) != 0 || habits[0].Completions(storage.Outcome{Result: "y", Amount: 50}) != 1 {
+		t.Error("Expected only done days reaching the goal to count")
+	}
+
+	f := storage.ParseHabitsFile(config)
+	if err := f.SetFrequency(habits[0], "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetFrequency(habits[3], "2"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(f.String(), "Pushups: 2 @ 50\n") || !strings.Contains(f.String(), "Squats: 2 @ 20\n") {
+		t.Errorf("Expected retuning to keep the goals, got\n%s", f.String())
+	}
+}
+
+func TestCheckHabitsConfigTags(t *testing.T) {
+	config := `Run 5k: 3/7 #fitness #outdoor
+Gym: 2/7 priority=2 #Fitness #fitness
+Read: 1 #
+! Mind (default 1)
+Meditate: #mind
+`
+	habits, problems := storage.CheckHabitsConfig(strings.NewReader(config))
+
+	if len(habits) != 4 {
+		t.Fatalf("Expected 4 habits, got %d with problems %+v", len(habits), problems)
+	}
+	if !reflect.DeepEqual(habits[0].Tags, []string{"fitness", "outdoor"}) || habits[0].Frequency != "3/7" {
+		t.Errorf("Expected Run 5k 3/7 with two tags, got %+v", *habits[0])
+	}
+	// Tags mix with options, and the same tag twice is only kept once
+	if !reflect.DeepEqual(habits[1].Tags, []string{"Fitness"}) || habits[1].Priority != 2 || !habits[1].HasTag("#fitness") {
+		t.Errorf("Expected Gym tagged once with its priority, got %+v", *habits[1])
+	}
+	if len(habits[2].Tags) != 0 || len(problems) != 1 || problems[0].Line != 3 || problems[0].Fatal {
+		t.Errorf("Expected a warning for Read's empty tag, got %+v and %+v", *habits[2], problems)
+	}
+	if !habits[3].HasTag("mind") || habits[3].Frequency != "1" {
+		t.Errorf("Expected Meditate tagged with the default frequency, got %+v", *habits[3])
+	}
+
+	f := storage.ParseHabitsFile(config)
+	if err := f.SetFrequency(habits[0], "4/7"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(f.String(), "Run 5k: 4/7 #fitness #outdoor\n") {
+		t.Errorf("Expected retuning to keep the tags, got\n%s", f.String())
+	}
+}
+
 func TestLoadLog(t *testing.T) {
 	// Create temporary directory for test
 	tmpDir, err := os.MkdirTemp("", "harsh_storage_test")
@@ -298,6 +558,48 @@ func TestFindConfigFiles(t *testing.T) {
 	}
 }
 
+func TestProjectConfigDir(t *testing.T) {
+	// Resolved, as the working directory comes back without symlinks
+	project, _ := filepath.EvalSymlinks(t.TempDir())
+	nested := filepath.Join(project, "chapters", "intro")
+	os.MkdirAll(nested, 0755)
+	os.MkdirAll(filepath.Join(project, storage.ProjectConfigDir), 0755)
+
+	// Found from anywhere inside the project, like git finds .git
+	want := filepath.Join(project, storage.ProjectConfigDir)
+	if dir := storage.FindProjectConfigDir(nested); dir != want {
+		t.Errorf("Expected %s found from %s, got %q", want, nested, dir)
+	}
+	if dir := storage.FindProjectConfigDir(t.TempDir()); dir != "" {
+		t.Errorf("Expected no project config dir outside the project, got %q", dir)
+	}
+
+	// It takes over from the default, but not from HARSHPATH
+	t.Chdir(nested)
+	t.Setenv("HARSHPATH", "")
+	if dir := storage.ConfigDir(); dir != want {
+		t.Errorf("Expected the project's config dir in use, got %q", dir)
+	}
+	t.Setenv("HARSHPATH", project)
+	if dir := storage.ConfigDir(); dir != project {
+		t.Errorf("Expected HARSHPATH to win over the project's config dir, got %q", dir)
+	}
+
+	// Nothing's looked for in or above the home directory
+	t.Setenv("HOME", filepath.Join(project, "chapters"))
+	if dir := storage.FindProjectConfigDir(nested); dir != "" {
+		t.Errorf("Expected no project config dir above home, got %q", dir)
+	}
+	t.Setenv("HOME", t.TempDir())
+
+	// nor taken from someone else
+	if os.Chown(want, os.Getuid()+1, -1) == nil {
+		if dir := storage.FindProjectConfigDir(nested); dir != "" {
+			t.Errorf("Expected a project config dir owned by someone else passed over, got %q", dir)
+		}
+	}
+}
+
 func TestCreateExampleHabitsFile(t *testing.T) {
 	// Create temporary directory for test
 	tmpDir, err := os.MkdirTemp("", "harsh_storage_test")
@@ -357,3 +659,1750 @@ func TestCreateNewLogFile(t *testing.T) {
 		t.Error("Log file should be empty initially")
 	}
 }
+
+func TestProfiles(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "harsh_profiles_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// No profiles folder yet
+	names, err := storage.ListProfiles(tmpDir)
+	if err != nil || len(names) != 0 {
+		t.Errorf("Expected no profiles, got %v (err %v)", names, err)
+	}
+
+	for _, name := range []string{"bob", "alice"} {
+		dir := storage.ProfileDir(tmpDir, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(dir, "habits"), []byte("Gym: 3/7\n"), 0644)
+		os.WriteFile(filepath.Join(dir, "log"), []byte("2025-01-01 : Gym : y :  : \n"), 0644)
+	}
+	os.MkdirAll(storage.ProfileDir(tmpDir, "carol"), 0755)
+
+	names, err = storage.ListProfiles(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "alice,bob,carol" {
+		t.Errorf("Expected sorted profiles alice,bob,carol, got %v", names)
+	}
+
+	profile, err := storage.LoadProfile(tmpDir, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Name != "alice" || len(profile.Habits) != 1 || len(profile.Log.Entries) != 1 {
+		t.Errorf("Profile loaded incorrectly: %+v", profile)
+	}
+
+	// Profiles missing their files are reported rather than exiting
+	if _, err := storage.LoadProfile(tmpDir, "carol"); err == nil {
+		t.Error("Expected error loading profile without habits file")
+	}
+	dave := storage.ProfileDir(tmpDir, "dave")
+	os.MkdirAll(dave, 0755)
+	os.WriteFile(filepath.Join(dave, "habits"), []byte("Gym: often\n"), 0644)
+	os.WriteFile(filepath.Join(dave, "log"), []byte(""), 0644)
+	if _, err := storage.LoadProfile(tmpDir, "dave"); err == nil {
+		t.Error("Expected error loading profile with a broken habits file")
+	}
+}
+
+func TestReaderRepository(t *testing.T) {
+	habits := strings.NewReader("! Health\nGym: 3/7\nWater: 1\n")
+	log := strings.NewReader("2025-01-01 : Gym : y : Leg day : 1.5\n2025-01-01 : Water : n\n")
+
+	repo := storage.NewReaderRepository(habits, log)
+	var _ storage.Repository = repo
+
+	loadedHabits, maxLength, err := repo.LoadHabits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loadedHabits) != 2 || loadedHabits[0].Heading != "Health" || loadedHabits[0].Target != 3 {
+		t.Errorf("Habits parsed incorrectly: %+v", loadedHabits)
+	}
+	if maxLength != len("Water")+10 {
+		t.Errorf("Unexpected max habit name length %d", maxLength)
+	}
+
+	loadedLog, err := repo.LoadEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gym := loadedLog.Entries[storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 1, Day: 1}, Habit: "Gym"}]
+	if len(loadedLog.Entries) != 2 || gym.Amount != 1.5 || gym.Comment != "Leg day" {
+		t.Errorf("Log parsed incorrectly: %+v", loadedLog.Entries)
+	}
+
+	if err := repo.WriteEntry(civil.Date{Year: 2025, Month: 1, Day: 2}, "Gym", "y", "", "", loadedLog.Header); err == nil {
+		t.Error("Expected writing to a reader repository to fail")
+	}
+	if repo.GetConfigDir() != "" {
+		t.Error("Reader repository should have no config directory")
+	}
+}
+
+func TestSettings(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "harsh_settings_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Missing config file gives defaults
+	settings, err := storage.LoadSettings(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Int("countback") != 0 || settings.String("theme") != "default" || settings.IsSet("theme") {
+		t.Error("Expected default settings when no config file exists")
+	}
+
+	// Validation
+	invalid := []struct{ key, value string }{
+		{"countback", "lots"},
+		{"countback", "-1"},
+		{"theme", "neon"},
+		{"no-such-key", "1"},
+	}
+	for _, tt := range invalid {
+		if err := settings.Set(tt.key, tt.value); err == nil {
+			t.Errorf("Expected error setting %s to %q", tt.key, tt.value)
+		}
+	}
+
+	// Round trip through the file
+	if err := settings.Set("countback", "45"); err != nil {
+		t.Fatal(err)
+	}
+	if err := settings.Set("theme", "mono"); err != nil {
+		t.Fatal(err)
+	}
+	if err := settings.Save(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := storage.LoadSettings(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Int("countback") != 45 || loaded.String("theme") != "mono" {
+		t.Errorf("Settings did not round trip: countback=%d theme=%s", loaded.Int("countback"), loaded.String("theme"))
+	}
+
+	// Hand written files with comments and quoting are understood
+	os.WriteFile(filepath.Join(tmpDir, storage.SettingsFile), []byte("# mine\ncountback = 30 # a month\ntheme = \"default\"\n"), 0644)
+	loaded, err = storage.LoadSettings(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Int("countback") != 30 || loaded.String("theme") != "default" {
+		t.Errorf("Hand written settings parsed incorrectly: countback=%d theme=%s", loaded.Int("countback"), loaded.String("theme"))
+	}
+
+	// Invalid files report the offending line
+	os.WriteFile(filepath.Join(tmpDir, storage.SettingsFile), []byte("countback = 30\ntheme\n"), 0644)
+	if _, err := storage.LoadSettings(tmpDir); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected error mentioning line 2, got %v", err)
+	}
+}
+
+// Changing a setting edits its line and leaves the rest of the file alone,
+// even lines harsh doesn't understand
+func TestSettingsSaveInPlace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, storage.SettingsFile)
+	original := "# mine\ncountback = 30 # a month\nfuture_key = 1\ntheme = \"mono\"\n# theme = \"default\"\ncountback = 40\n"
+	if err := os.WriteFile(path, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := storage.LoadSettings(dir)
+	if err == nil {
+		t.Fatal("Expected the unknown key reported")
+	}
+	settings.Set("countback", "45")
+	settings.Set("score_goal", "80")
+	if err := settings.Save(dir, "countback", "score_goal"); err != nil {
+		t.Fatal(err)
+	}
+	settings.Unset("theme")
+	if err := settings.Save(dir, "theme"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# mine\ncountback = 45\nfuture_key = 1\n# theme = \"default\"\nscore_goal = 80\n"
+	if string(data) != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, data)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the config file to stay 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestSettingsAliases(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "harsh_settings_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	settings := storage.NewSettings()
+	if err := settings.Set("alias.gym", `done Gym --comment "leg day"`); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"alias.", "alias.two words", "alias.empty"} {
+		if err := settings.Set(key, ""); err == nil {
+			t.Errorf("Expected error setting %s", key)
+		}
+	}
+	if err := settings.Save(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := storage.LoadSettings(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Aliases()["gym"]; got != `done Gym --comment "leg day"` || !loaded.IsSet("alias.gym") {
+		t.Errorf("Alias did not round trip, got %q", got)
+	}
+	if err := loaded.Unset("alias.gym"); err != nil || len(loaded.Aliases()) != 0 {
+		t.Errorf("Expected unsetting to remove the alias, got %v and %v", err, loaded.Aliases())
+	}
+	if _, err := loaded.Get("alias.gym"); err == nil {
+		t.Error("Expected an error getting a removed alias")
+	}
+}
+
+func TestLockedRepository(t *testing.T) {
+	mockRepo := &MockRepository{
+		log: &storage.Log{Entries: storage.Entries{}, Header: storage.DefaultHeader},
+	}
+	today := civil.DateOf(time.Now())
+
+	locked := storage.NewLockedRepository(mockRepo, 7, false)
+	var _ storage.Repository = locked
+
+	if err := locked.WriteEntry(today.AddDays(-7), "Test", "y", "", "", storage.DefaultHeader); err != nil {
+		t.Errorf("Entries within the lock window should be writable: %v", err)
+	}
+	if err := locked.WriteEntry(today.AddDays(-8), "Test", "y", "", "", storage.DefaultHeader); err == nil {
+		t.Error("Entries older than the lock window should be refused")
+	}
+	if _, ok := mockRepo.log.Entries[storage.DailyHabit{Day: today.AddDays(-8), Habit: "Test"}]; ok {
+		t.Error("Locked entry should not reach the underlying repository")
+	}
+
+	forced := storage.NewLockedRepository(mockRepo, 7, true)
+	if err := forced.WriteEntry(today.AddDays(-8), "Test", "y", "", "", storage.DefaultHeader); err != nil {
+		t.Errorf("--force should allow writing locked entries: %v", err)
+	}
+
+	disabled := storage.NewLockedRepository(mockRepo, 0, false)
+	if err := disabled.CheckLocked(today.AddDays(-3650)); err != nil {
+		t.Errorf("A lock of 0 days should never lock: %v", err)
+	}
+}
+
+func TestWriteHabitLogs(t *testing.T) {
+	dir := t.TempDir()
+	// A hand-edited last line without its newline
+	os.WriteFile(filepath.Join(dir, "log"), []byte("# harsh log version 2\n2025-01-01 : Gym : y : : "), 0644)
+	day := civil.Date{Year: 2025, Month: 1, Day: 2}
+	writes := []storage.EntryWrite{
+		{Day: day, Habit: "Gym", Result: "n"},
+		storage.OutcomeWrite(storage.DailyHabit{Day: day, Habit: "Run"}, storage.Outcome{Result: "y", Comment: "park", Amount: 5}),
+	}
+	if err := storage.WriteHabitLogs(dir, writes, storage.DefaultHeader); err != nil {
+		t.Fatal(err)
+	}
+	want := "# harsh log version 2\n2025-01-01 : Gym : y : : \n2025-01-02 : Gym : n :  : \n2025-01-02 : Run : y : park : 5\n"
+	if data, _ := os.ReadFile(filepath.Join(dir, "log")); string(data) != want {
+		t.Errorf("Expected\n%q\ngot\n%q", want, data)
+	}
+}
+
+func TestUpdateHabitLog(t *testing.T) {
+	dir := t.TempDir()
+	log := "Date : Status : Habit : Comment : Amount\n" +
+		"# harsh log version 2\n" +
+		"2025-01-01 : y : Gym : : \n" +
+		"# Logged twice, the last one counts\n" +
+		"2025-01-01 : n : Gym : : \n" +
+		"2025-01-01 :: Gym was closed\n" +
+		"2025-01-02 : y : Run : : 5"
+	os.WriteFile(filepath.Join(dir, "log"), []byte(log), 0600)
+	day := civil.Date{Year: 2025, Month: 1, Day: 1}
+
+	header := storage.LoadLog(dir).Header
+	if err := storage.UpdateHabitLog(dir, day, "Gym", "s", "closed", "", header); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.UpdateHabitLog(dir, day.AddDays(1), "Run", "y", "", "6", header); err != nil {
+		t.Fatal(err)
+	}
+	want := "Date : Status : Habit : Comment : Amount\n" +
+		"# harsh log version 2\n" +
+		"2025-01-01 : y : Gym : : \n" +
+		"# Logged twice, the last one counts\n" +
+		"2025-01-01 : s : Gym : closed : \n" +
+		"2025-01-01 :: Gym was closed\n" +
+		"2025-01-02 : y : Run :  : 6\n"
+	if data, _ := os.ReadFile(filepath.Join(dir, "log")); string(data) != want {
+		t.Errorf("Expected\n%q\ngot\n%q", want, data)
+	}
+
+	err := storage.UpdateHabitLog(dir, day.AddDays(1), "Gym", "y", "", "", header)
+	if !errors.Is(err, storage.ErrNoEntry) {
+		t.Errorf("Expected ErrNoEntry for a day with nothing logged, got %v", err)
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(tmps) != 0 {
+		t.Errorf("Expected no temporary file left behind, got %v", tmps)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "log")); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the log to keep its 0600 permissions, got %v", info.Mode().Perm())
+	}
+
+	// Signed edits swap the old line's signature for the new one's
+	key := []byte("secret")
+	lines := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	storage.WriteSignatures(dir, storage.SignLog(lines, key))
+	repo := storage.NewSignedRepository(logRepository{writeOnlyRepository{dir: dir}}, key)
+	if err := repo.UpdateEntry(day, "Gym", "y", "", "", header); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "log"))
+	signatures, _ := storage.LoadSignatures(dir)
+	v := storage.VerifyLog(strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), key, signatures)
+	if !v.Intact() || v.Signed != 4 || len(v.Unsigned) != 0 {
+		t.Errorf("Expected the edited log to verify, got %+v", v)
+	}
+}
+
+// logRepository edits the log in its directory, for wrappers under test
+type logRepository struct {
+	writeOnlyRepository
+}
+
+func (r logRepository) UpdateEntry(d civil.Date, habit string, result string, comment string, amount string, header storage.Header) error {
+	return storage.UpdateHabitLog(r.dir, d, habit, result, comment, amount, header)
+}
+
+func TestWriteHabitLogAfterUnterminatedLine(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "harsh_storage_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	os.WriteFile(filepath.Join(tmpDir, "log"), []byte("2025-01-01 : Gym : y : : "), 0644)
+	err = storage.WriteHabitLog(tmpDir, civil.Date{Year: 2025, Month: 1, Day: 2}, "Gym", "n", "", "", storage.DefaultHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := storage.LoadLog(tmpDir)
+	if len(log.Entries) != 2 {
+		t.Errorf("Expected both entries to survive an unterminated last line, got %d", len(log.Entries))
+	}
+}
+
+func TestQuotes(t *testing.T) {
+	quotes, err := storage.ParseQuotes(strings.NewReader(`# Quotes
+Keep going.
+Small steps add up.
+
+! Health
+Move it or lose it.
+
+@ Gym
+Lift heavy things.
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes.General) != 2 || len(quotes.Headings["Health"]) != 1 || len(quotes.Habits["Gym"]) != 1 {
+		t.Fatalf("Unexpected quotes: %+v", quotes)
+	}
+
+	day := civil.Date{Year: 2025, Month: 1, Day: 15}
+	gym := &storage.Habit{Heading: "Health", Name: "Gym"}
+	walk := &storage.Habit{Heading: "Health", Name: "Walk"}
+	read := &storage.Habit{Heading: "Mind", Name: "Read"}
+	habits := []*storage.Habit{gym, walk, read}
+
+	entries := &storage.Entries{}
+	if got := quotes.Pick(habits, entries, day); got != quotes.Pick(habits, entries, day) || !strings.Contains("Keep going. Small steps add up.", got) {
+		t.Errorf("Expected the same general quote with nothing done, got %q", got)
+	}
+
+	(*entries)[storage.DailyHabit{Day: day, Habit: "Walk"}] = storage.Outcome{Result: "y"}
+	if got := quotes.Pick(habits, entries, day); got != "Move it or lose it." {
+		t.Errorf("Expected heading quote after Walk, got %q", got)
+	}
+
+	(*entries)[storage.DailyHabit{Day: day, Habit: "Gym"}] = storage.Outcome{Result: "y"}
+	if got := quotes.Pick(habits, entries, day); got != "Lift heavy things." {
+		t.Errorf("Expected habit quote after Gym, got %q", got)
+	}
+
+	// Skipped habits don't count as done
+	(*entries)[storage.DailyHabit{Day: day, Habit: "Gym"}] = storage.Outcome{Result: "s"}
+	if got := quotes.Pick(habits, entries, day); got != "Move it or lose it." {
+		t.Errorf("Expected heading quote after skipping Gym, got %q", got)
+	}
+
+	var none *storage.Quotes
+	if got := none.Pick(habits, entries, day); got != "" {
+		t.Errorf("Expected no quote without a quotes file, got %q", got)
+	}
+}
+
+func TestLoadQuotes(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	quotes, err := storage.LoadQuotes(tmpDir)
+	if err != nil || quotes != nil {
+		t.Fatalf("Expected no quotes and no error without a quotes file, got %v, %v", quotes, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, storage.QuotesFile), []byte("Onwards.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	quotes, err = storage.LoadQuotes(tmpDir)
+	if err != nil || quotes == nil || len(quotes.General) != 1 {
+		t.Errorf("Expected one general quote, got %v, %v", quotes, err)
+	}
+}
+
+func TestXPState(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	state, err := storage.LoadXPState(tmpDir)
+	if err != nil || state != (storage.XPState{}) {
+		t.Fatalf("Expected a zero state without an XP file, got %+v, %v", state, err)
+	}
+
+	saved := storage.XPState{XP: 450, Level: 3, Checked: civil.Date{Year: 2025, Month: 1, Day: 15}}
+	if err := saved.Save(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	state, err = storage.LoadXPState(tmpDir)
+	if err != nil || state != saved {
+		t.Errorf("Expected %+v after saving, got %+v, %v", saved, state, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, storage.XPStateFile), []byte("xp = lots\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.LoadXPState(tmpDir); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected a line number in the error for a bad XP file, got %v", err)
+	}
+}
+
+func TestScoreCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "habits"), []byte("Gym: 1\n"), 0644)
+	day := civil.Date{Year: 2025, Month: 1, Day: 15}
+
+	fingerprint := storage.DataFingerprint(tmpDir)
+	cache, err := storage.LoadScoreCache(tmpDir, fingerprint)
+	if err != nil || len(cache.Scores) != 0 {
+		t.Fatalf("Expected no scores without a scores file, got %v, %v", cache.Scores, err)
+	}
+	cache.Scores[day] = 50
+	cache.Scores[day.AddDays(1)] = math.NaN()
+	if err := cache.Save(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	cache, err = storage.LoadScoreCache(tmpDir, storage.DataFingerprint(tmpDir))
+	if err != nil || len(cache.Scores) != 2 || cache.Scores[day] != 50 || !math.IsNaN(cache.Scores[day.AddDays(1)]) {
+		t.Errorf("Expected the saved scores back, got %v, %v", cache.Scores, err)
+	}
+
+	// Scores worked out before the habits changed are stale
+	os.WriteFile(filepath.Join(tmpDir, "habits"), []byte("Gym: 1\nRead: 1\n"), 0644)
+	if cache, err := storage.LoadScoreCache(tmpDir, storage.DataFingerprint(tmpDir)); err != nil || len(cache.Scores) != 0 {
+		t.Errorf("Expected no scores once the habits changed, got %v, %v", cache.Scores, err)
+	}
+	if storage.DataFingerprint(tmpDir, "positive") == storage.DataFingerprint(tmpDir, "neutral") {
+		t.Error("Expected settings to change the fingerprint")
+	}
+}
+
+func TestParseLogWarnings(t *testing.T) {
+	log := storage.ParseLog(strings.NewReader(`2025-03-09 : Gym : y :  : 1
+2025-13-01 : Gym : y :  : 
+2025-03-10 : Gym : maybe :  : 
+2025-03-11 : Gym : y
+`))
+	if len(log.Entries) != 2 {
+		t.Errorf("Expected the 2 readable entries, got %d", len(log.Entries))
+	}
+	if len(log.Warnings) != 3 || !strings.Contains(log.Warnings[0], "line 2") || !strings.Contains(log.Warnings[1], "'maybe'") {
+		t.Errorf("Expected warnings for lines 2, 3 and 4, got %q", log.Warnings)
+	}
+
+	clean := storage.ParseLog(strings.NewReader("2025-03-09 : Gym : y :  : 1\n"))
+	if len(clean.Warnings) != 0 {
+		t.Errorf("Expected no warnings for a clean log, got %q", clean.Warnings)
+	}
+}
+
+func TestParseLogMalformedLines(t *testing.T) {
+	// Lines too short for the header's status column, or with amounts no
+	// graph can draw, are skipped or zeroed rather than crashing
+	log := storage.ParseLog(strings.NewReader(`Habit : Date : Status : Comment : Amount
+Gym : 2025-03-09
+Gym : 2025-03-10 : y :  : Inf
+Gym : 2025-03-11 : y :  : 1e999
+`))
+	if len(log.Entries) != 2 {
+		t.Errorf("Expected the 2 entries with results, got %d", len(log.Entries))
+	}
+	for day, outcome := range log.Entries {
+		if outcome.Amount != 0 {
+			t.Errorf("Expected %s's infinite amount zeroed, got %v", day.Day, outcome.Amount)
+		}
+	}
+	if len(log.Warnings) != 4 || !strings.Contains(log.Warnings[1], "without a result") {
+		t.Errorf("Expected warnings for every line, got %q", log.Warnings)
+	}
+}
+
+func TestLogNotes(t *testing.T) {
+	log := storage.ParseLog(strings.NewReader(`2025-03-09 : Gym : y : Before :: after : 1
+2025-03-10 :: Started new job
+2025-03-10 :: Moved desk
+# 2025-03-11 :: Commented out
+2025-03-12 : Gym : n
+`))
+
+	if len(log.Entries) != 2 {
+		t.Errorf("Expected notes to leave the 2 entries alone, got %d entries", len(log.Entries))
+	}
+	if outcome := log.Entries[storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 3, Day: 9}, Habit: "Gym"}]; outcome.Comment != "Before :: after" {
+		t.Errorf("Expected comment containing :: to stay a comment, got %q", outcome.Comment)
+	}
+	notes := log.Notes[civil.Date{Year: 2025, Month: 3, Day: 10}]
+	if len(log.Notes) != 1 || len(notes) != 2 || notes[0] != "Started new job" || notes[1] != "Moved desk" {
+		t.Errorf("Unexpected notes: %v", log.Notes)
+	}
+
+	days := log.Notes.SortedDays(civil.Date{Year: 2025, Month: 3, Day: 11}, civil.Date{Year: 2025, Month: 3, Day: 31})
+	if len(days) != 0 {
+		t.Errorf("Expected no notes after 2025-03-10, got %v", days)
+	}
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "log"), []byte("2025-03-12 : Gym : n : : "), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.WriteNote(tmpDir, civil.Date{Year: 2025, Month: 3, Day: 13}, "Flu"); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := storage.LoadLog(tmpDir)
+	if len(reloaded.Entries) != 1 || len(reloaded.Notes[civil.Date{Year: 2025, Month: 3, Day: 13}]) != 1 {
+		t.Errorf("Expected the note on its own line after the entry, got %+v", reloaded)
+	}
+}
+
+func TestCommentIndex(t *testing.T) {
+	day := civil.Date{Year: 2025, Month: 3, Day: 1}
+	log := &storage.Log{Entries: storage.Entries{
+		storage.DailyHabit{Day: day, Habit: "Run"}:             {Result: "y", Comment: "Sore knees after hills"},
+		storage.DailyHabit{Day: day.AddDays(1), Habit: "Run"}:  {Result: "n", Comment: "knee still sore"},
+		storage.DailyHabit{Day: day.AddDays(1), Habit: "Yoga"}: {Result: "y", Comment: "helped my knee"},
+		storage.DailyHabit{Day: day.AddDays(2), Habit: "Yoga"}: {Result: "y"},
+	}}
+
+	index := log.CommentIndex()
+	tests := []struct {
+		text     string
+		expected []storage.DailyHabit
+	}{
+		{"knee", []storage.DailyHabit{{Day: day, Habit: "Run"}, {Day: day.AddDays(1), Habit: "Run"}, {Day: day.AddDays(1), Habit: "Yoga"}}},
+		{"SORE knee", []storage.DailyHabit{{Day: day, Habit: "Run"}, {Day: day.AddDays(1), Habit: "Run"}}},
+		{"my", []storage.DailyHabit{{Day: day.AddDays(1), Habit: "Yoga"}}},
+		{"eenk", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got := index.Search(tt.text)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Search(%q): expected %v, got %v", tt.text, tt.expected, got)
+		}
+	}
+
+	// Entries recorded after the index is built are searchable, and replaced comments aren't
+	log.Record(storage.DailyHabit{Day: day.AddDays(2), Habit: "Yoga"}, storage.Outcome{Result: "y", Comment: "knee fine now"})
+	log.Record(storage.DailyHabit{Day: day, Habit: "Run"}, storage.Outcome{Result: "y", Comment: "hills"})
+	got := log.CommentIndex().Search("knee")
+	expected := []storage.DailyHabit{{Day: day.AddDays(1), Habit: "Run"}, {Day: day.AddDays(1), Habit: "Yoga"}, {Day: day.AddDays(2), Habit: "Yoga"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("After recording: expected %v, got %v", expected, got)
+	}
+}
+
+func TestLoadLogReadsArchivedSegments(t *testing.T) {
+	dir := t.TempDir()
+	writeGzip := func(name string, content string) {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write([]byte(content))
+		gz.Close()
+		if err := os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeGzip("log-2020.gz", "2020-06-01 : Run : y : old : \n2020-06-02 : Run : y :  : \n2020-06-01 :: Moved house\n")
+	writeGzip("log-2021.gz", "2020-06-02 : Run : n : corrected : \n2021-03-01 : Run : s :  : \n")
+	os.WriteFile(filepath.Join(dir, "log"), []byte("2021-03-01 : Run : y : current : \n2022-01-01 : Run : y :  : \n"), 0644)
+
+	log := storage.LoadLog(dir)
+	expected := map[string]storage.Outcome{
+		"2020-06-01": {Result: "y", Comment: "old"},
+		"2020-06-02": {Result: "n", Comment: "corrected"},
+		"2021-03-01": {Result: "y", Comment: "current"},
+		"2022-01-01": {Result: "y"},
+	}
+	if len(log.Entries) != len(expected) {
+		t.Errorf("Expected %d entries, got %v", len(expected), log.Entries)
+	}
+	for day, want := range expected {
+		d, _ := civil.ParseDate(day)
+		if got := log.Entries[storage.DailyHabit{Day: d, Habit: "Run"}]; got != want {
+			t.Errorf("%s: expected %+v, got %+v", day, want, got)
+		}
+	}
+	if notes := log.Notes[civil.Date{Year: 2020, Month: 6, Day: 1}]; len(notes) != 1 || notes[0] != "Moved house" {
+		t.Errorf("Expected the archived note, got %v", notes)
+	}
+}
+
+func TestSnoozed(t *testing.T) {
+	dir := t.TempDir()
+	today := civil.Date{Year: 2025, Month: 3, Day: 10}
+
+	snoozed, err := storage.LoadSnoozed(dir, today)
+	if err != nil || len(snoozed.Habits) != 0 {
+		t.Fatalf("Expected nothing snoozed without a file, got %+v (%v)", snoozed, err)
+	}
+	snoozed.Add("Gym")
+	snoozed.Add("Walk")
+	snoozed.Add("Gym")
+	if err := snoozed.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := storage.LoadSnoozed(dir, today)
+	if err != nil || !reflect.DeepEqual(loaded.Habits, []string{"Gym", "Walk"}) {
+		t.Errorf("Expected Gym and Walk snoozed, got %+v (%v)", loaded, err)
+	}
+	if tomorrow, _ := storage.LoadSnoozed(dir, today.AddDays(1)); len(tomorrow.Habits) != 0 {
+		t.Errorf("Expected snoozes to end with the day, got %+v", tomorrow)
+	}
+
+	loaded.Remove("Gym")
+	loaded.Remove("Walk")
+	if err := loaded.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, storage.SnoozeFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected the snooze file removed once empty, got %v", err)
+	}
+}
+
+func TestChallenges(t *testing.T) {
+	dir := t.TempDir()
+	start := civil.Date{Year: 2025, Month: 3, Day: 1}
+
+	challenges, err := storage.LoadChallenges(dir)
+	if err != nil || len(challenges) != 0 {
+		t.Fatalf("Expected no challenges without a file, got %+v (%v)", challenges, err)
+	}
+	challenges = append(challenges,
+		storage.Challenge{Name: "30-day meditation", Habit: "Meditate", Start: start, Days: 30},
+		storage.Challenge{Name: "Sober October", Habit: "No alcohol", Start: civil.Date{Year: 2025, Month: 10, Day: 1}, Days: 31},
+	)
+	if err := challenges.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := storage.LoadChallenges(dir)
+	if err != nil || !reflect.DeepEqual(loaded, challenges) {
+		t.Errorf("Expected %+v back, got %+v (%v)", challenges, loaded, err)
+	}
+	if active := loaded.Active(start.AddDays(29)); len(active) != 1 || active[0].Name != "30-day meditation" {
+		t.Errorf("Expected only the meditation challenge on its last day, got %+v", active)
+	}
+	if active := loaded.Active(start.AddDays(30)); len(active) != 0 {
+		t.Errorf("Expected no challenge the day after it ends, got %+v", active)
+	}
+
+	loaded = loaded.Remove("30-DAY MEDITATION")
+	if _, ok := loaded.Find("30-day meditation"); ok || len(loaded) != 1 {
+		t.Errorf("Expected the meditation challenge removed, got %+v", loaded)
+	}
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"habits":                "Gym: 3/7\n",
+		"log":                   "2025-01-01 : Gym : y :  : \n",
+		"config.toml":           "countback = 30\n",
+		"profiles/alice/habits": "Read: 1\n",
+		".git/HEAD":             "ref: refs/heads/main\n",
+		"signing.key":           "secret\n",
+		"includes/team":         "Standup: 1\n",
+		"log.tmp":               "half written\n",
+		"log.db":                "stale\n",
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	os.Chmod(filepath.Join(dir, "profiles/alice/habits"), 0600)
+
+	var bundle bytes.Buffer
+	written, err := storage.WriteBundle(&bundle, dir, storage.BundleManifest{HarshVersion: "1.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written.Files) != 4 {
+		t.Errorf("Expected 4 files bundled without hidden ones, secrets or caches, got %+v", written.Files)
+	}
+
+	manifest, contents, err := storage.ReadBundle(bytes.NewReader(bundle.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Version != storage.BundleVersion || manifest.HarshVersion != "1.0.0" {
+		t.Errorf("Unexpected manifest %+v", manifest)
+	}
+
+	// A fresh config directory's example habits and empty log give way
+	target := t.TempDir()
+	os.WriteFile(filepath.Join(target, "habits"), []byte(storage.DEFAULT_HABITS), 0644)
+	os.WriteFile(filepath.Join(target, "log"), nil, 0644)
+	if conflicts := storage.BundleConflicts(target, contents); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts with a fresh config directory, got %v", conflicts)
+	}
+	if err := storage.ExtractBundle(target, manifest, contents); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(target, "profiles/alice/habits")); string(data) != "Read: 1\n" {
+		t.Errorf("Expected alice's habits restored, got %q", data)
+	}
+	if info, err := os.Stat(filepath.Join(target, "profiles/alice/habits")); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected alice's habits restored as 0600, got %v", info.Mode().Perm())
+	}
+
+	os.WriteFile(filepath.Join(target, "log"), []byte("changed\n"), 0644)
+	if conflicts := storage.BundleConflicts(target, contents); !reflect.DeepEqual(conflicts, []string{"log"}) {
+		t.Errorf("Expected the changed log to conflict, got %v", conflicts)
+	}
+
+	// Crash reports ride along with the config directory
+	var crash bytes.Buffer
+	written, err = storage.WriteBundleWith(&crash, dir, storage.BundleManifest{}, map[string][]byte{"crash.txt": []byte("panic: oops\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, contents, err := storage.ReadBundle(&crash); err != nil || len(written.Files) != 5 || string(contents["crash.txt"]) != "panic: oops\n" {
+		t.Errorf("Expected the crash report bundled with the 4 files, got %+v (%v)", written.Files, err)
+	}
+
+	// or, without a config directory, alone with whatever files they pick
+	crash.Reset()
+	written, err = storage.WriteBundleWith(&crash, "", storage.BundleManifest{}, map[string][]byte{"crash.txt": []byte("panic: oops\n"), "habits": []byte("Gym: 3/7\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, contents, err := storage.ReadBundle(&crash); err != nil || len(written.Files) != 2 || string(contents["habits"]) != "Gym: 3/7\n" {
+		t.Errorf("Expected only the crash report and habits bundled, got %+v (%v)", written.Files, err)
+	}
+
+	// Bundles reaching outside the config directory are refused
+	var evil bytes.Buffer
+	gz := gzip.NewWriter(&evil)
+	archive := tar.NewWriter(gz)
+	manifestData := []byte(`{"format": "harsh-bundle", "version": 1}`)
+	archive.WriteHeader(&tar.Header{Name: storage.BundleManifestFile, Mode: 0644, Size: int64(len(manifestData))})
+	archive.Write(manifestData)
+	archive.WriteHeader(&tar.Header{Name: "../escape", Mode: 0644, Size: 1})
+	archive.Write([]byte("x"))
+	archive.Close()
+	gz.Close()
+	if _, _, err := storage.ReadBundle(&evil); err == nil {
+		t.Error("Expected a bundle with ../ paths to be refused")
+	}
+}
+
+func TestMigrateLog(t *testing.T) {
+	dir := t.TempDir()
+	v1 := "2025-01-01 : Gym : y : Leg day : 1.5\n2025-01-02 : Gym : n :  : \n"
+	os.WriteFile(filepath.Join(dir, "log"), []byte(v1), 0644)
+	before := storage.LoadLog(dir)
+
+	from, err := storage.MigrateLog(dir)
+	if err != nil || from != 1 {
+		t.Fatalf("Expected a migration from version 1, got %d (%v)", from, err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "log"))
+	want := "Date : Habit : Status : Comment : Amount\n# harsh log version 2\n" + v1
+	if string(data) != want {
+		t.Errorf("Expected migrated log\n%s\ngot\n%s", want, data)
+	}
+	if backup, _ := os.ReadFile(filepath.Join(dir, "log.v1.bak")); string(backup) != v1 {
+		t.Errorf("Expected the version 1 log backed up, got %q", backup)
+	}
+
+	after := storage.LoadLog(dir)
+	if after.Version != storage.LogVersion || !reflect.DeepEqual(after.Entries, before.Entries) {
+		t.Errorf("Expected the same entries at version %d, got version %d with %v", storage.LogVersion, after.Version, after.Entries)
+	}
+	if from, err := storage.MigrateLog(dir); err != nil || from != 0 {
+		t.Errorf("Expected a current log to be left alone, got %d (%v)", from, err)
+	}
+
+	// Logs from a newer harsh aren't touched
+	newer := "Date : Habit : Status : Comment : Amount\n# harsh log version 99\n"
+	os.WriteFile(filepath.Join(dir, "log"), []byte(newer), 0644)
+	if _, err := storage.MigrateLog(dir); err == nil {
+		t.Error("Expected an error for a log from a newer harsh")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "log")); string(data) != newer {
+		t.Errorf("Expected the newer log unchanged, got %q", data)
+	}
+	if err := storage.WriteHabitLog(dir, civil.Date{Year: 2025, Month: 1, Day: 3}, "Gym", "y", "", "", storage.DefaultHeader); err == nil {
+		t.Error("Expected logging to a newer log to be refused")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "log")); string(data) != newer {
+		t.Errorf("Expected the newer log unchanged, got %q", data)
+	}
+}
+
+func TestLogMigratesOnWrite(t *testing.T) {
+	dir := t.TempDir()
+	v1 := "2025-01-01 : Gym : y : Leg day : 1.5\n"
+	logPath := filepath.Join(dir, "log")
+	os.WriteFile(logPath, []byte(v1), 0600)
+	os.WriteFile(filepath.Join(dir, "habits"), []byte("Gym: 1\n"), 0644)
+	t.Setenv("HARSHPATH", dir)
+
+	// Reading leaves an older log as it is
+	if log, err := storage.NewFileRepository().LoadEntries(); err != nil || log.Version != 1 || len(log.Entries) != 1 {
+		t.Fatalf("Expected the version 1 log read, got %+v (%v)", log, err)
+	}
+	if data, _ := os.ReadFile(logPath); string(data) != v1 {
+		t.Fatalf("Expected reading to leave the log alone, got %q", data)
+	}
+
+	if err := storage.WriteHabitLog(dir, civil.Date{Year: 2025, Month: 1, Day: 2}, "Gym", "n", "", "", storage.DefaultHeader); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(logPath)
+	want := "Date : Habit : Status : Comment : Amount\n# harsh log version 2\n" + v1 + "2025-01-02 : Gym : n :  : \n"
+	if string(data) != want {
+		t.Errorf("Expected the log upgraded before the entry, got\n%s", data)
+	}
+	for _, path := range []string{logPath, filepath.Join(dir, "log.v1.bak")} {
+		if info, err := os.Stat(path); err != nil {
+			t.Error(err)
+		} else if info.Mode().Perm() != 0600 {
+			t.Errorf("Expected %s to keep the log's 0600, got %v", path, info.Mode().Perm())
+		}
+	}
+}
+
+func TestPlans(t *testing.T) {
+	tmpDir := t.TempDir()
+	wednesday := civil.Date{Year: 2025, Month: 1, Day: 15}
+	monday := civil.Date{Year: 2025, Month: 1, Day: 13}
+	for _, d := range []civil.Date{monday, wednesday, monday.AddDays(6)} {
+		if got := storage.WeekStart(d); got != monday {
+			t.Errorf("Expected the week of %s to start on %s, got %s", d, monday, got)
+		}
+	}
+	storage.FirstWeekday = time.Sunday
+	if got := storage.WeekStart(wednesday); got != monday.AddDays(-1) {
+		t.Errorf("Expected Sunday-first weeks to start on %s, got %s", monday.AddDays(-1), got)
+	}
+	storage.FirstWeekday = time.Monday
+
+	plans, err := storage.LoadPlans(tmpDir)
+	if err != nil || len(plans) != 0 {
+		t.Fatalf("Expected no plans without a plans file, got %v, %v", plans, err)
+	}
+	plans = plans.Set(storage.Plan{Week: monday, Targets: []storage.PlanTarget{{Habit: "Gym", Count: 3}, {Habit: "a=b", Count: 1}}})
+	plans = plans.Set(storage.Plan{Week: monday.AddDays(-7), Targets: []storage.PlanTarget{{Habit: "Gym", Count: 2}}})
+	plans = plans.Set(storage.Plan{Week: monday, Targets: []storage.PlanTarget{{Habit: "Gym", Count: 4}, {Habit: "a=b", Count: 1}}})
+	if err := plans.Save(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := storage.LoadPlans(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 2 || loaded[0].Week != monday.AddDays(-7) {
+		t.Fatalf("Expected 2 plans, oldest first, got %+v", loaded)
+	}
+	plan, ok := loaded.For(wednesday)
+	if !ok {
+		t.Fatal("Expected a plan for this week")
+	}
+	if count, _ := plan.Count("Gym"); count != 4 {
+		t.Errorf("Expected the replaced plan's Gym count of 4, got %d", count)
+	}
+	if count, ok := plan.Count("a=b"); !ok || count != 1 {
+		t.Errorf("Expected a habit with = in its name to round trip, got %d", count)
+	}
+	if _, ok := loaded.For(monday.AddDays(7)); ok {
+		t.Error("Expected no plan for next week")
+	}
+
+	os.WriteFile(filepath.Join(tmpDir, storage.PlansFile), []byte("Gym = 3\n"), 0644)
+	if _, err := storage.LoadPlans(tmpDir); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected an error for a count before any week, got %v", err)
+	}
+}
+
+func TestRetireHabit(t *testing.T) {
+	tmpDir := t.TempDir()
+	config := "! Health\nGym: 3/7\nRun: 1\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "habits"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	habits, _ := storage.CheckHabitsConfig(strings.NewReader(config))
+	if habits[1].Line != 3 {
+		t.Fatalf("Expected Run defined on line 3, got %d", habits[1].Line)
+	}
+
+	if err := storage.RemoveHabitConfig(tmpDir, habits[0]); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(tmpDir, "habits"))
+	if string(data) != "! Health\nRun: 1\n" {
+		t.Errorf("Expected only Gym's line removed, got %q", data)
+	}
+	// The file has changed since Run's line was read
+	if err := storage.RemoveHabitConfig(tmpDir, habits[1]); err == nil {
+		t.Error("Expected an error removing a habit whose line has moved")
+	}
+
+	for _, report := range []string{"## Gym\n", "## Run\n"} {
+		if err := storage.AppendRetiredHabit(tmpDir, report); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ = os.ReadFile(filepath.Join(tmpDir, storage.RetiredHabitsFile))
+	if string(data) != "## Gym\n\n## Run\n" {
+		t.Errorf("Expected reports separated by a blank line, got %q", data)
+	}
+}
+
+func TestHabitFallback(t *testing.T) {
+	config := `Run 5k | or: Walk 20min: 3/7
+Read | or:Read a page: 1
+Meditate: 1
+`
+	habits, problems := storage.CheckHabitsConfig(strings.NewReader(config))
+	if len(habits) != 3 || len(problems) != 0 {
+		t.Fatalf("Expected 3 habits and no problems, got %d and %+v", len(habits), problems)
+	}
+	if habits[0].Name != "Run 5k" || habits[0].Fallback != "Walk 20min" || habits[0].Frequency != "3/7" {
+		t.Errorf("Expected Run 5k with a fallback, got %+v", *habits[0])
+	}
+	if habits[1].Name != "Read" || habits[1].Fallback != "Read a page" || habits[2].Fallback != "" {
+		t.Errorf("Expected the fallback without a space after the separator and none for Meditate, got %+v and %+v", *habits[1], *habits[2])
+	}
+
+	if comment := storage.WithFallback(""); comment != "[or]" || !storage.IsFallback(comment) {
+		t.Errorf("Expected a bare fallback tag, got %q", comment)
+	}
+	if comment := storage.WithFallback("rainy"); comment != "[or] rainy" || !storage.IsFallback(comment) {
+		t.Errorf("Expected the tag before the comment, got %q", comment)
+	}
+	if storage.IsFallback("rainy [or] not") {
+		t.Error("Expected only comments starting with the tag to be fallbacks")
+	}
+}
+
+func TestHabitAskOption(t *testing.T) {
+	config := `Call grandma: 1/14 ask=due
+Gym: 1 ask=mon,Thu
+Read: 1 ask=daily
+Swim: 1 ask=someday
+`
+	habits, problems := storage.CheckHabitsConfig(strings.NewReader(config))
+	if len(habits) != 4 || len(problems) != 1 {
+		t.Fatalf("Expected 4 habits and a problem with Swim's option, got %d and %+v", len(habits), problems)
+	}
+	if !habits[0].AskDue || habits[0].AskDays != nil {
+		t.Errorf("Expected Call grandma to be asked when due, got %+v", *habits[0])
+	}
+	if !slices.Equal(habits[1].AskDays, []time.Weekday{time.Monday, time.Thursday}) {
+		t.Errorf("Expected Gym to be asked Mondays and Thursdays, got %v", habits[1].AskDays)
+	}
+	monday := civil.Date{Year: 2025, Month: 1, Day: 13}
+	if !habits[1].AsksOn(monday) || habits[1].AsksOn(monday.AddDays(1)) || !habits[2].AsksOn(monday.AddDays(1)) {
+		t.Error("Expected Gym asked only on its days and Read every day")
+	}
+}
+
+// writeOnlyRepository accepts every write, for wrappers under test
+type writeOnlyRepository struct {
+	storage.Repository
+	dir string
+}
+
+func (r writeOnlyRepository) WriteEntry(d civil.Date, habit string, result string, comment string, amount string, header storage.Header) error {
+	return nil
+}
+
+func (r writeOnlyRepository) GetConfigDir() string {
+	return r.dir
+}
+
+func TestTimedRepository(t *testing.T) {
+	dir := t.TempDir()
+	today := civil.Date{Year: 2025, Month: 3, Day: 10}
+	defer func(old clock.Clock) { clock.Default = old }(clock.Default)
+	clock.Default = clock.Fixed(today)
+
+	if writes, err := storage.LoadWrites(dir); err != nil || len(writes) != 0 {
+		t.Fatalf("Expected no writes without a file, got %+v (%v)", writes, err)
+	}
+	repo := storage.NewTimedRepository(writeOnlyRepository{dir: dir})
+	repo.WriteEntry(today, "Gym", "y", "", "", storage.DefaultHeader)
+	repo.WriteEntry(today.AddDays(-3), "Read : Books", "n", "", "", storage.DefaultHeader)
+
+	writes, err := storage.LoadWrites(dir)
+	if err != nil || len(writes) != 2 {
+		t.Fatalf("Expected 2 writes, got %+v (%v)", writes, err)
+	}
+	if writes[0].At.Date != today || writes[0].Day != today || writes[0].Habit != "Gym" || writes[0].Delay() != 0 {
+		t.Errorf("Unexpected same-day write: %+v", writes[0])
+	}
+	if writes[1].Habit != "Read : Books" || writes[1].Delay() != 3 {
+		t.Errorf("Unexpected backfilled write: %+v", writes[1])
+	}
+}
+
+// Frequencies are recorded by whatever writes an entry, and only then
+func TestVersionedRepository(t *testing.T) {
+	dir := t.TempDir()
+	today := civil.Date{Year: 2025, Month: 3, Day: 10}
+	changes := []storage.FrequencyChange{{Since: today, Habit: "Gym", Frequency: "3/7"}}
+	repo := storage.NewVersionedRepository(writeOnlyRepository{dir: dir}, changes)
+
+	if history, err := storage.LoadFrequencyHistory(dir); err != nil || len(history) != 0 {
+		t.Fatalf("Expected nothing recorded before a write, got %v (%v)", history, err)
+	}
+	for range 2 {
+		if err := storage.WriteEntries(repo, []storage.EntryWrite{{Day: today, Habit: "Gym", Result: "y"}}, storage.DefaultHeader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history, err := storage.LoadFrequencyHistory(dir)
+	if err != nil || !slices.Equal(history, changes) {
+		t.Errorf("Expected the change recorded once with the first write, got %v (%v)", history, err)
+	}
+}
+
+func TestNormalizeLog(t *testing.T) {
+	lines := []string{
+		"Habit : Date : Status : Amount : Comment",
+		"# my habits since 2025",
+		"Read : 2025-01-02 : y : 5.0  : chapter 3  ",
+		"",
+		"# walked twice",
+		"Walk : 2025-01-01 : n :  : ",
+		"2025-01-01 :: New job",
+		"Gym : 2025-01-01 : y : 1.50 : ",
+		"Walk : 2025-01-01 : y :  : evening",
+	}
+	result, err := storage.NormalizeLog(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"Date : Habit : Status : Comment : Amount",
+		"# harsh log version 2",
+		"# my habits since 2025",
+		"2025-01-01 : Gym : y :  : 1.5",
+		"# walked twice",
+		"2025-01-01 : Walk : y : evening : ",
+		"2025-01-01 :: New job",
+		"2025-01-02 : Read : y : chapter 3 : 5",
+	}
+	if !slices.Equal(result.Lines, expected) {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(result.Lines, "\n"))
+	}
+	if !result.Changed || result.Entries != 3 || result.Notes != 1 || result.Duplicates != 1 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	// Normalizing again changes nothing
+	again, err := storage.NormalizeLog(result.Lines)
+	if err != nil || again.Changed {
+		t.Errorf("Expected a normalized log to stay the same, got %+v (%v)", again, err)
+	}
+
+	if _, err := storage.NormalizeLog(append(expected, "2025-13-01 : Gym : y :  : ")); err == nil {
+		t.Error("Expected an unreadable line to stop normalizing")
+	}
+}
+
+func TestNormalizeLogFileKeepsMode(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "log")
+	os.WriteFile(logPath, []byte("Date : Habit : Status : Comment : Amount\n# harsh log version 2\n2025-01-02 : Read : y :  : \n2025-01-01 : Gym : y :  : \n"), 0600)
+
+	result, err := storage.NormalizeLogFile(dir, false)
+	if err != nil || !result.Changed {
+		t.Fatalf("Expected the log normalized, got %+v (%v)", result, err)
+	}
+	for _, name := range []string{"log", "log.bak"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("Expected %s to stay 0600, got %v", name, info.Mode().Perm())
+		}
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, "log*.tmp")); len(leftovers) > 0 {
+		t.Errorf("Expected no temporary files left, got %v", leftovers)
+	}
+}
+
+func TestVerifyLog(t *testing.T) {
+	key := []byte("secret")
+	lines := []string{
+		"Date : Habit : Status : Comment : Amount",
+		"# harsh log version 2",
+		"2025-01-01 : Gym : y :  : ",
+		"2025-01-02 : Gym : y :  : ",
+		"2025-01-02 : Gym : y :  : ",
+		"2025-01-03 :: Rest day",
+	}
+	signatures := storage.SignLog(lines, key)
+	if len(signatures) != 4 || signatures[1] == signatures[2] {
+		t.Fatalf("Expected the 4 lines signed, repeats told apart by the chain, got %v", signatures)
+	}
+	if v := storage.VerifyLog(lines, key, signatures); !v.Intact() || v.Signed != 4 || len(v.Unsigned) != 0 || v.First != 3 {
+		t.Errorf("Expected an intact log, got %+v", v)
+	}
+
+	// An edited line breaks the chain only there, and one added by hand too
+	edited := slices.Clone(lines)
+	edited[3] = "2025-01-02 : Gym : n :  : "
+	edited = append(edited, "2025-01-04 :: Added by hand")
+	v := storage.VerifyLog(edited, key, signatures)
+	if v.Intact() || v.Orphaned != 1 || v.Signed != 3 || !slices.Equal(v.Broken, []int{4, 7}) {
+		t.Errorf("Expected the edit caught, got %+v", v)
+	}
+
+	// Lines swapped or removed are caught even though each is unchanged
+	swapped := slices.Clone(lines)
+	swapped[2], swapped[5] = swapped[5], swapped[2]
+	if v := storage.VerifyLog(swapped, key, signatures); v.Intact() {
+		t.Errorf("Expected reordered lines caught, got %+v", v)
+	}
+	removed := slices.Delete(slices.Clone(lines), 3, 4)
+	if v := storage.VerifyLog(removed, key, signatures); v.Intact() || v.Orphaned != 1 || len(v.Broken) != 0 {
+		t.Errorf("Expected the removed line caught, got %+v", v)
+	}
+
+	// Lines before the first signed one were written before signing was on
+	older := append([]string{lines[0], "2024-12-31 : Gym : y :  : "}, lines[1:]...)
+	if v := storage.VerifyLog(older, key, signatures); !v.Intact() || !slices.Equal(v.Unsigned, []int{2}) || v.First != 4 {
+		t.Errorf("Expected the older line unsigned but not a failure, got %+v", v)
+	}
+
+	// Another key can't vouch for the log
+	if v := storage.VerifyLog(lines, []byte("guess"), signatures); v.Intact() || v.Signed != 0 {
+		t.Errorf("Expected nothing to verify with the wrong key, got %+v", v)
+	}
+
+	dir := t.TempDir()
+	repo := storage.NewSignedRepository(writeOnlyRepository{dir: dir}, key)
+	repo.WriteEntry(civil.Date{Year: 2025, Month: 1, Day: 1}, "Gym", "y", "", "", storage.DefaultHeader)
+	repo.WriteEntry(civil.Date{Year: 2025, Month: 1, Day: 2}, "Gym", "y", "", "", storage.DefaultHeader)
+	written, err := storage.LoadSignatures(dir)
+	if err != nil || !slices.Equal(written, signatures[:2]) {
+		t.Errorf("Expected the written entries signed in a chain, got %v (%v)", written, err)
+	}
+}
+
+func TestTemporarySession(t *testing.T) {
+	// A config directory inside a file can never be created, even as root
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HARSHPATH", filepath.Join(blocker, "harsh"))
+	t.Setenv("TMPDIR", t.TempDir())
+
+	configDir := storage.FindConfigFiles()
+	if configDir != storage.TemporarySessionDir() {
+		t.Fatalf("Expected a temporary session, got %s", configDir)
+	}
+	habits, _ := storage.LoadHabitsConfig(configDir)
+	if len(habits) == 0 {
+		t.Error("Expected the temporary session to have example habits")
+	}
+	if err := storage.WriteHabitLog(configDir, civil.Date{Year: 2025, Month: 1, Day: 1}, habits[0].Name, "y", "", "", storage.DefaultHeader); err != nil {
+		t.Errorf("Expected to log in the temporary session, got %v", err)
+	}
+	// Later runs carry on in the same session
+	if again := storage.FindConfigFiles(); again != configDir {
+		t.Errorf("Expected the session to be reused, got %s", again)
+	}
+}
+
+func TestPrivateDir(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "session")
+	if err := storage.PrivateDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(dir); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm()&0077 != 0 {
+		t.Errorf("Expected a directory closed to others, got %v", info.Mode().Perm())
+	}
+	if err := storage.PrivateDir(dir); err != nil {
+		t.Errorf("Expected the same directory accepted again, got %v", err)
+	}
+
+	// Directories others can get into, and links, are refused
+	open := filepath.Join(root, "open")
+	os.Mkdir(open, 0755)
+	os.Chmod(open, 0755)
+	if err := storage.PrivateDir(open); err == nil {
+		t.Error("Expected a directory others can read refused")
+	}
+	link := filepath.Join(root, "link")
+	os.Symlink(dir, link)
+	if err := storage.PrivateDir(link); err == nil {
+		t.Error("Expected a link to a directory refused")
+	}
+}
+
+func TestSampleLog(t *testing.T) {
+	habits := sample.Habits(20)
+	if len(habits) != 20 {
+		t.Fatalf("Expected 20 habits, got %d", len(habits))
+	}
+	names := map[string]bool{}
+	for _, habit := range habits {
+		if names[habit.Name] {
+			t.Errorf("Expected habit names to be unique, got %s twice", habit.Name)
+		}
+		names[habit.Name] = true
+	}
+
+	from := civil.Date{Year: 2025, Month: 1, Day: 1}
+	to := civil.Date{Year: 2025, Month: 3, Day: 31}
+	lines := sample.Log(habits, from, to, 7)
+	if !slices.Equal(lines, sample.Log(habits, from, to, 7)) {
+		t.Error("Expected the same seed to make up the same log")
+	}
+	log := storage.ParseLog(strings.NewReader(strings.Join(lines, "\n")))
+	if len(log.Warnings) > 0 {
+		t.Errorf("Expected a readable log, got warnings %v", log.Warnings)
+	}
+	results := map[string]int{}
+	for key, outcome := range log.Entries {
+		if key.Day.Before(from) || key.Day.After(to) || !names[key.Habit] {
+			t.Errorf("Unexpected entry %v", key)
+		}
+		results[outcome.Result]++
+	}
+	for _, result := range []string{"y", "n", "s"} {
+		if results[result] == 0 {
+			t.Errorf("Expected some %q entries, got %v", result, results)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := sample.Write(dir, habits, from, to, 7); err != nil {
+		t.Fatal(err)
+	}
+	loaded, _ := storage.LoadHabitsConfig(dir)
+	if len(loaded) != len(habits) {
+		t.Errorf("Expected %d habits in the habits file, got %d", len(habits), len(loaded))
+	}
+	if err := sample.Write(dir, habits, from, to, 7); err == nil {
+		t.Error("Expected writing over an existing profile to fail")
+	}
+}
+
+func TestParseLogLongLines(t *testing.T) {
+	// Well past bufio.Scanner's 64KB limit, like a long pasted journal entry
+	huge := strings.TrimSpace(strings.Repeat("all work and no play ", 100000))
+	input := "2025-01-01 : Journal : y : " + huge + " : \r\n" +
+		"2025-01-02 : Journal : n : tired : \n" +
+		"2025-01-02 :: " + huge + "\n" +
+		"2025-01-03 : Journal : y : " + huge + " : 1"
+	log := storage.ParseLog(strings.NewReader(input))
+	if len(log.Warnings) > 0 {
+		t.Errorf("Expected no warnings, got %d", len(log.Warnings))
+	}
+	if got := log.Entries[storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 1, Day: 1}, Habit: "Journal"}].Comment; got != huge {
+		t.Errorf("Expected the whole comment of %d bytes, got %d bytes", len(huge), len(got))
+	}
+	if got := log.Entries[storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 1, Day: 2}, Habit: "Journal"}].Result; got != "n" {
+		t.Errorf("Expected the line after a long one to be read, got %q", got)
+	}
+	if got := log.Notes[civil.Date{Year: 2025, Month: 1, Day: 2}]; len(got) != 1 || got[0] != huge {
+		t.Error("Expected the long note to be read")
+	}
+	// The last line needn't end with a newline
+	if _, ok := log.Entries[storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 1, Day: 3}, Habit: "Journal"}]; !ok {
+		t.Error("Expected the long last line to be read")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "log"), []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := storage.LoadLog(dir); len(loaded.Entries) != 3 {
+		t.Errorf("Expected LoadLog to read 3 entries, got %d", len(loaded.Entries))
+	}
+}
+
+func TestParseLogMaxLineLength(t *testing.T) {
+	defer func(max int) { storage.MaxLineLength = max }(storage.MaxLineLength)
+	storage.MaxLineLength = 1000
+
+	input := "2025-01-01 : Journal : y : " + strings.Repeat("x", 5000) + " : \n" +
+		"2025-01-02 : Journal : y : short : \n"
+	log := storage.ParseLog(strings.NewReader(input))
+	if len(log.Warnings) != 1 || !strings.Contains(log.Warnings[0], "line 1") {
+		t.Errorf("Expected a warning about line 1, got %v", log.Warnings)
+	}
+	if len(log.Entries) != 1 {
+		t.Errorf("Expected only the short entry to be read, got %d entries", len(log.Entries))
+	}
+
+	settings := storage.NewSettings()
+	if settings.Int("max_line_length") != 0 {
+		t.Errorf("Expected lines of any length read by default, got a limit of %d", settings.Int("max_line_length"))
+	}
+	if err := settings.Set("max_line_length", "4096"); err != nil || settings.Int("max_line_length") != 4096 {
+		t.Errorf("Expected max_line_length settable, got %d (%v)", settings.Int("max_line_length"), err)
+	}
+}
+
+func TestHabitsFileRoundTrip(t *testing.T) {
+	config := "# My habits\n\n" +
+		"Water: 1\n\n" +
+		"# Mostly mornings\n" +
+		"! Health\n" +
+		"# the important one\n" +
+		"Gym:   3/7   graphdays=90\n" +
+		"Walk | or: stroll: 1\n\n" +
+		"! Work (default 1)\n" +
+		"Standup"
+	habits, problems := storage.CheckHabitsConfig(strings.NewReader(config))
+	if len(problems) > 0 || len(habits) != 4 {
+		t.Fatalf("Expected 4 habits, got %d with problems %v", len(habits), problems)
+	}
+
+	f := storage.ParseHabitsFile(config)
+	if f.String() != config {
+		t.Fatalf("Expected the file unchanged when nothing's changed, got %q", f.String())
+	}
+	if err := f.Rename(habits[1], "Lift"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Rename(habits[2], "Hike"); err != nil {
+		t.Fatal(err)
+	}
+	f.Add(&storage.Habit{Heading: "Health", Name: "Stretch", Frequency: "1"})
+	f.Add(&storage.Habit{Heading: "Work", Name: "Inbox zero", Frequency: "1"})
+	f.Add(&storage.Habit{Name: "Floss", Frequency: "1"})
+	f.Add(&storage.Habit{Heading: "Mind", Name: "Read", Frequency: "1"})
+	if err := f.Remove(habits[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "# My habits\n\n" +
+		"Floss: 1\n\n" +
+		"# Mostly mornings\n" +
+		"! Health\n" +
+		"# the important one\n" +
+		"Lift:   3/7   graphdays=90\n" +
+		"Hike | or: stroll: 1\n" +
+		"Stretch: 1\n\n" +
+		"! Work (default 1)\n" +
+		"Standup\n" +
+		"Inbox zero: 1\n\n" +
+		"! Mind\n" +
+		"Read: 1\n"
+	if f.String() != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, f.String())
+	}
+	if err := f.Remove(habits[3]); err == nil {
+		t.Error("Expected an error removing a habit whose line has moved")
+	}
+
+	// Files with Windows line endings keep them
+	f = storage.ParseHabitsFile("! Health\r\nGym: 3/7\r\n")
+	f.Add(&storage.Habit{Heading: "Health", Name: "Run", Frequency: "1"})
+	if f.String() != "! Health\r\nGym: 3/7\r\nRun: 1\r\n" {
+		t.Errorf("Expected Windows line endings kept, got %q", f.String())
+	}
+
+	// Saving keeps the file's permissions and leaves no temporary file behind
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "habits")
+	os.WriteFile(path, []byte("! Health\nGym: 3/7\n"), 0600)
+	f, err := storage.LoadHabitsFile(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Add(&storage.Habit{Heading: "Health", Name: "Run", Frequency: "1"})
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the habits file to stay 0600, got %v", info.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(path); string(data) != "! Health\nGym: 3/7\nRun: 1\n" {
+		t.Errorf("Expected the added habit saved, got %q", data)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(tmpDir, "habits*.tmp")); len(leftovers) > 0 {
+		t.Errorf("Expected no temporary files left, got %v", leftovers)
+	}
+}
+
+func TestCheckHabitsConfigIncludes(t *testing.T) {
+	config := `! Health
+Gym: 3/7
+include https://example.com/team.txt sha256=abc
+Walk: 1
+include https://example.com/gone.txt
+`
+	shared := "Stretch: 1\n! Team (default 2/7)\nStandup\nBroken: often\ninclude https://example.com/more.txt\n"
+	fetch := func(url string, checksum string) (string, error) {
+		if url == "https://example.com/team.txt" && checksum == "abc" {
+			return shared, nil
+		}
+		return "", errors.New("cannot fetch " + url)
+	}
+	habits, problems := storage.CheckHabitsConfigWith(strings.NewReader(config), fetch)
+
+	expected := []struct{ name, heading, include string }{
+		{"Gym", "Health", ""},
+		{"Stretch", "Health", "https://example.com/team.txt"},
+		{"Standup", "Team", "https://example.com/team.txt"},
+		{"Walk", "Health", ""},
+	}
+	if len(habits) != len(expected) {
+		t.Fatalf("Expected %d habits, got %d", len(expected), len(habits))
+	}
+	for i, want := range expected {
+		got := habits[i]
+		if got.Name != want.name || got.Heading != want.heading || got.Include != want.include {
+			t.Errorf("Habit %d: expected %+v, got %+v", i, want, *got)
+		}
+	}
+	if habits[1].Line != 0 || habits[3].Line != 4 {
+		t.Errorf("Expected only habits from the file itself to have lines, got %d and %d", habits[1].Line, habits[3].Line)
+	}
+
+	// The broken line and nested include in the shared file, and the include
+	// that can't be fetched, are skipped without stopping harsh
+	if len(problems) != 3 {
+		t.Fatalf("Expected 3 problems, got %+v", problems)
+	}
+	for _, problem := range problems {
+		if problem.Fatal {
+			t.Errorf("Expected no fatal problems, got %+v", problem)
+		}
+	}
+	if !strings.Contains(problems[0].Problem, "in https://example.com/team.txt") || problems[2].Line != 5 {
+		t.Errorf("Expected problems to say where they come from, got %+v", problems)
+	}
+
+	f := storage.ParseHabitsFile(config)
+	if err := f.SetFrequency(habits[1], "2"); err == nil || !strings.Contains(err.Error(), "change it there") {
+		t.Errorf("Expected included habits to be read only, got %v", err)
+	}
+
+	habits, problems = storage.CheckHabitsConfig(strings.NewReader(config))
+	if len(habits) != 2 || len(problems) != 2 {
+		t.Errorf("Expected includes skipped without a fetcher, got %d habits and %+v", len(habits), problems)
+	}
+}
+
+func TestCheckHabitsConfigIncludesKeepOwnHabits(t *testing.T) {
+	// The shared file comes first and reuses names from both headings
+	config := `! Health
+include https://example.com/team.txt
+Stretch: 1
+! Work
+Stretch: 3/7
+Gym: 1
+`
+	shared := "Stretch: 2\nGym: 2\n! Work\nGym: 2\nWork/Stretch: 1\nStandup: 1\n"
+	fetch := func(url string, checksum string) (string, error) { return shared, nil }
+	habits, problems := storage.CheckHabitsConfigWith(strings.NewReader(config), fetch)
+
+	expected := []struct{ name, include string }{
+		{"Standup", "https://example.com/team.txt"},
+		{"Stretch", ""},
+		{"Work/Stretch", ""},
+		{"Gym", ""},
+	}
+	if len(habits) != len(expected) {
+		t.Fatalf("Expected %d habits, got %d with %+v", len(expected), len(habits), problems)
+	}
+	for i, want := range expected {
+		if habits[i].Name != want.name || habits[i].Include != want.include || (want.include == "" && habits[i].Frequency == "2") {
+			t.Errorf("Habit %d: expected your own %+v, got %+v", i, want, *habits[i])
+		}
+	}
+	if len(problems) != 4 {
+		t.Fatalf("Expected the 4 shared habits skipped, got %+v", problems)
+	}
+	for _, problem := range problems {
+		if problem.Fatal || problem.Line != 2 || !strings.Contains(problem.Problem, "in https://example.com/team.txt") {
+			t.Errorf("Expected a warning at the include line, got %+v", problem)
+		}
+	}
+}
+
+func TestCachedIncludes(t *testing.T) {
+	body := "Stretch: 1\n"
+	sum := sha256.Sum256([]byte(body))
+	pin := hex.EncodeToString(sum[:])
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	fetch := storage.CachedIncludes(dir)
+	text, err := fetch(server.URL, pin)
+	if err != nil || text != body {
+		t.Fatalf("Expected the pinned file, got %q, %v", text, err)
+	}
+	if text, err = fetch(server.URL, pin); err != nil || text != body || fetches != 1 {
+		t.Errorf("Expected a fresh cached copy to be used, got %q, %v after %d fetches", text, err, fetches)
+	}
+
+	// Stale and then changed upstream: the pin keeps the copy that matched
+	entries, _ := os.ReadDir(filepath.Join(dir, storage.IncludesDir))
+	if len(entries) != 1 {
+		t.Fatalf("Expected one cached file, got %d", len(entries))
+	}
+	stale := time.Now().Add(-2 * storage.IncludeMaxAge)
+	os.Chtimes(filepath.Join(dir, storage.IncludesDir, entries[0].Name()), stale, stale)
+	body = "Stretch: 1\nSurprise: 1\n"
+	text, err = fetch(server.URL, pin)
+	if err == nil || text != "Stretch: 1\n" || fetches != 2 {
+		t.Errorf("Expected the cached copy with a checksum error, got %q, %v", text, err)
+	}
+
+	server.Close()
+	if text, err = fetch(server.URL, pin); err == nil || text != "Stretch: 1\n" {
+		t.Errorf("Expected the cached copy when the URL is down, got %q, %v", text, err)
+	}
+	if _, err = fetch("file:///etc/passwd", ""); err == nil {
+		t.Error("Expected only http and https URLs to be fetched")
+	}
+	if _, err = fetch("http://example.com/team.txt", ""); err == nil || fetches != 2 {
+		t.Errorf("Expected plain http refused without a pin, got %v", err)
+	}
+}
+
+func TestHabitsFileSetFrequency(t *testing.T) {
+	config := "Gym:   3/7   graphdays=90\n" +
+		"Walk | or: stroll: 1\r\n" +
+		"! Work (default 1)\n" +
+		"Standup\n" +
+		"Water: ask=mon"
+	habits, problems := storage.CheckHabitsConfig(strings.NewReader(config))
+	if len(problems) > 0 || len(habits) != 4 {
+		t.Fatalf("Expected 4 habits, got %d with problems %v", len(habits), problems)
+	}
+	f := storage.ParseHabitsFile(config)
+	for i, frequency := range []string{"2/7", "1w", "2", "3"} {
+		if err := f.SetFrequency(habits[i], frequency); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "Gym:   2/7 graphdays=90\n" +
+		"Walk | or: stroll: 1w\r\n" +
+		"! Work (default 1)\n" +
+		"Standup: 2\n" +
+		"Water: 3 ask=mon"
+	if f.String() != want {
+		t.Errorf("Expected\n%q\ngot\n%q", want, f.String())
+	}
+}
+
+func TestCheckReadOnlyQuery(t *testing.T) {
+	for _, sql := range []string{
+		"SELECT * FROM entries",
+		"  select habit from entries where comment = 'a;b' ;",
+		"-- busiest days\nWITH d AS (SELECT day FROM entries) SELECT * FROM d",
+		"/* how many */ SELECT count(*) FROM entries; -- that's all",
+	} {
+		if err := storage.CheckReadOnlyQuery(sql); err != nil {
+			t.Errorf("Expected %q to be allowed, got %v", sql, err)
+		}
+	}
+	for _, sql := range []string{
+		"",
+		"-- nothing",
+		"DELETE FROM entries",
+		"SELECT 1; DROP TABLE entries",
+		"ATTACH 'other.db' AS other",
+		".shell rm -rf /",
+		"SELECT 'unclosed",
+	} {
+		if err := storage.CheckReadOnlyQuery(sql); err == nil {
+			t.Errorf("Expected %q to be refused", sql)
+		}
+	}
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 isn't installed")
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "habits"), []byte("Gym: 3/7\nRun: 1\n"), 0644)
+	log := storage.DefaultHeader.String() + "\n" +
+		"2025-01-01 : Gym : y : it's leg day : \n" +
+		"2025-01-01 : Run : y :  : 5.5\n" +
+		"2025-01-02 : Gym : n :  : \n" +
+		"2025-01-02 :: Started a new job\n" +
+		"2025-01-03 : Run : s : sick : \n"
+	os.WriteFile(filepath.Join(dir, "log"), []byte(log), 0644)
+	want := storage.LoadLog(dir)
+
+	// Reading doesn't create the database, that's left to the first write
+	if empty, err := storage.NewSQLiteRepository(dir).LoadEntries(); err != nil || len(empty.Entries) != 0 {
+		t.Errorf("Expected no entries before the database exists, got %v (%v)", empty.Entries, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, storage.SQLiteFile)); err == nil {
+		t.Error("Expected reading not to create the database")
+	}
+
+	entries, notes, err := storage.MigrateToSQLite(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries != 4 || notes != 1 {
+		t.Errorf("Expected 4 entries and 1 note migrated, got %d and %d", entries, notes)
+	}
+	if _, _, err := storage.MigrateToSQLite(dir); err == nil {
+		t.Error("Expected migrating over an existing database to fail")
+	}
+
+	repository := storage.NewSQLiteRepository(dir)
+	got, err := repository.LoadEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Entries, want.Entries) || !reflect.DeepEqual(got.Notes, want.Notes) {
+		t.Errorf("Expected the log's entries and notes, got %v and %v", got.Entries, got.Notes)
+	}
+
+	day := civil.Date{Year: 2025, Month: 1, Day: 2}
+	if err := repository.WriteEntry(day, "Gym", "y", "made up for it", "", storage.DefaultHeader); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.WriteNote(day, "Second day"); err != nil {
+		t.Fatal(err)
+	}
+	gym, err := repository.EntriesBetween("Gym", day, day.AddDays(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gym) != 1 || gym[storage.DailyHabit{Day: day, Habit: "Gym"}].Result != "y" {
+		t.Errorf("Expected the rewritten Gym entry alone, got %v", gym)
+	}
+
+	if err := repository.UpdateEntry(day.AddDays(-1), "Run", "y", "", "6", storage.DefaultHeader); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.UpdateEntry(day.AddDays(5), "Run", "y", "", "", storage.DefaultHeader); !errors.Is(err, storage.ErrNoEntry) {
+		t.Errorf("Expected ErrNoEntry editing a day with nothing logged, got %v", err)
+	}
+	if run, _ := repository.EntriesBetween("Run", day.AddDays(5), day.AddDays(5)); len(run) != 0 {
+		t.Errorf("Expected editing not to add entries, got %v", run)
+	}
+
+	habits, _, _ := repository.LoadHabits()
+	columns, rows, err := repository.Query("SELECT h.name, h.target, count(e.day) AS days FROM habits h "+
+		"LEFT JOIN entries e ON e.habit = h.name AND e.result = 'y' GROUP BY 1 ORDER BY 1; -- done days", habits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(columns, []string{"name", "target", "days"}) || !reflect.DeepEqual(rows, [][]string{{"Gym", "3", "2"}, {"Run", "1", "1"}}) {
+		t.Errorf("Expected each habit's done days, got %v %v", columns, rows)
+	}
+	if out, err := repository.QueryJSON("SELECT amount FROM entries WHERE habit = 'Gym' LIMIT 1", habits); err != nil || !strings.Contains(string(out), `"amount":null`) {
+		t.Errorf("Expected a JSON row with a null amount, got %s (%v)", out, err)
+	}
+	if _, _, err := repository.Query("WITH gone AS (SELECT 1) DELETE FROM entries", habits); err == nil {
+		t.Error("Expected writes to fail against the read only database")
+	}
+
+	os.Chmod(filepath.Join(dir, "log"), 0600)
+	result, err := storage.ExportSQLiteLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Entries != 4 || result.Notes != 2 {
+		t.Errorf("Expected 4 entries and 2 notes exported, got %d and %d", result.Entries, result.Notes)
+	}
+	exported := storage.LoadLog(dir)
+	if outcome := exported.Entries[storage.DailyHabit{Day: day, Habit: "Gym"}]; outcome.Comment != "made up for it" {
+		t.Errorf("Expected the database's entry in the log, got %v", outcome)
+	}
+	if amount := exported.Entries[storage.DailyHabit{Day: day.AddDays(-1), Habit: "Run"}].Amount; amount != 6 {
+		t.Errorf("Expected amounts kept, got %v", amount)
+	}
+	if !slices.Equal(exported.Notes[day], []string{"Started a new job", "Second day"}) {
+		t.Errorf("Expected both notes in order, got %v", exported.Notes[day])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "log.bak")); err != nil {
+		t.Error("Expected the old log backed up")
+	}
+	// A private log stays private, backup included
+	for _, name := range []string{"log", "log.bak"} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && info.Mode().Perm() != 0600 {
+			t.Errorf("Expected %s to stay 0600, got %v", name, info.Mode().Perm())
+		}
+	}
+}
@@ -2,11 +2,24 @@ package test
 
 import (
 	"bytes"
+	"errors"
+	"encoding/json"
+	"flag"
+	"image/png"
+	"maps"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/civil"
+	"github.com/gookit/color"
+	"github.com/wakatara/harsh/internal/graph"
+	"github.com/wakatara/harsh/internal/sample"
 	"github.com/wakatara/harsh/internal/storage"
 	"github.com/wakatara/harsh/internal/ui"
 )
@@ -75,6 +88,33 @@ func TestGetTodos(t *testing.T) {
 	if len(onboardTodos) == 0 {
 		t.Error("Should have onboarding todos")
 	}
+
+	// Habits kept on certain weekdays aren't todos on their days off
+	gym := &storage.Habit{Name: "Gym", Frequency: "Mon,Wed,Fri", FirstRecord: civil.Date{Year: 2025, Month: 1, Day: 1}}
+	gym.ParseHabitFrequency()
+	// Wednesday the 15th back to Monday the 13th
+	todos = ui.GetTodos([]*storage.Habit{gym}, entries, civil.Date{Year: 2025, Month: 1, Day: 15}, 2)
+	if len(todos["2025-01-15"]) != 1 || len(todos["2025-01-14"]) != 0 || len(todos["2025-01-13"]) != 1 {
+		t.Errorf("Expected Gym due Monday and Wednesday but not Tuesday, got %v", todos)
+	}
+	if got := ui.LongestStreak(gym, &storage.Entries{
+		{Day: civil.Date{Year: 2025, Month: 1, Day: 13}, Habit: "Gym"}: {Result: "y"},
+		{Day: civil.Date{Year: 2025, Month: 1, Day: 15}, Habit: "Gym"}: {Result: "y"},
+	}, civil.Date{Year: 2025, Month: 1, Day: 16}); got != 2 {
+		t.Errorf("Expected the Tuesday off not to break Gym's streak, got %d", got)
+	}
+	// An n logged on a day off neither breaks nor extends the streak
+	offDay := storage.Entries{
+		{Day: civil.Date{Year: 2025, Month: 1, Day: 13}, Habit: "Gym"}: {Result: "y"},
+		{Day: civil.Date{Year: 2025, Month: 1, Day: 14}, Habit: "Gym"}: {Result: "n"},
+		{Day: civil.Date{Year: 2025, Month: 1, Day: 15}, Habit: "Gym"}: {Result: "y"},
+	}
+	if got := ui.LongestStreak(gym, &offDay, civil.Date{Year: 2025, Month: 1, Day: 16}); got != 2 {
+		t.Errorf("Expected the n on Tuesday off to leave Gym's longest streak at 2, got %d", got)
+	}
+	if got := ui.CurrentStreak(gym, &offDay, civil.Date{Year: 2025, Month: 1, Day: 16}); got != 2 {
+		t.Errorf("Expected the n on Tuesday off to leave Gym's current streak at 2, got %d", got)
+	}
 }
 
 func TestUIBuildStats(t *testing.T) {
@@ -135,7 +175,7 @@ func TestDisplayShowHabitLog(t *testing.T) {
 	os.Stdout = w
 
 	display := ui.NewDisplay(true) // no color for testing
-	display.ShowHabitLog(habits, entries, 10, 20, "")
+	display.ShowHabitLog(habits, entries, civil.Date{Year: 2025, Month: 1, Day: 15}, 10, 20, "")
 
 	// Restore stdout
 	w.Close()
@@ -340,6 +380,13 @@ func (m *MockRepository) WriteEntry(d civil.Date, habit string, result string, c
 	return nil
 }
 
+func (m *MockRepository) UpdateEntry(d civil.Date, habit string, result string, comment string, amount string, header storage.Header) error {
+	if _, ok := m.log.Entries[storage.DailyHabit{Day: d, Habit: habit}]; !ok {
+		return storage.ErrNoEntry
+	}
+	return m.WriteEntry(d, habit, result, comment, amount, header)
+}
+
 func (m *MockRepository) GetConfigDir() string {
 	return "/tmp/test"
 }
@@ -378,3 +425,2120 @@ func TestHabitStatsStruct(t *testing.T) {
 		t.Errorf("Expected 2 skips, got %d", stats.Skips)
 	}
 }
+
+func TestBuildMonthlyRates(t *testing.T) {
+	habit := &storage.Habit{
+		Name:        "Test",
+		Target:      1,
+		Interval:    1,
+		FirstRecord: civil.Date{Year: 2025, Month: 1, Day: 30},
+	}
+
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 1, Day: 30}, Habit: "Test"}: {Result: "y"},
+		storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 1, Day: 31}, Habit: "Test"}: {Result: "n"},
+		storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 2, Day: 1}, Habit: "Test"}:  {Result: "y"},
+		storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 2, Day: 2}, Habit: "Test"}:  {Result: "s"},
+		storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 2, Day: 3}, Habit: "Test"}:  {Result: "y"},
+	}
+
+	rates := ui.BuildMonthlyRates(habit, entries, civil.Date{Year: 2025, Month: 2, Day: 4})
+
+	if len(rates) != 2 {
+		t.Fatalf("Expected 2 months, got %d", len(rates))
+	}
+	if rates[0].Month != (civil.Date{Year: 2025, Month: 1, Day: 1}) || rates[0].Days != 2 || rates[0].Rate != 50 {
+		t.Errorf("January incorrect: %+v", rates[0])
+	}
+	// Feb has 4 days tracked, one skipped, two done
+	if rates[1].Days != 4 || rates[1].Rate < 66.6 || rates[1].Rate > 66.7 {
+		t.Errorf("February incorrect: %+v", rates[1])
+	}
+
+	// Habits that were never recorded have no months
+	if rates := ui.BuildMonthlyRates(&storage.Habit{Name: "None", Target: 1, Interval: 1}, entries, civil.Date{Year: 2025, Month: 2, Day: 4}); len(rates) != 0 {
+		t.Errorf("Expected no months for unrecorded habit, got %d", len(rates))
+	}
+}
+
+func TestMonthPercentile(t *testing.T) {
+	start := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habit := &storage.Habit{Name: "Read", Target: 1, Interval: 1, FirstRecord: start}
+	entries := storage.Entries{}
+	// 10 days done in January, 20 in February, 5 in March, and every day of April so far
+	for month, done := range map[time.Month]int{time.January: 10, time.February: 20, time.March: 5, time.April: 8} {
+		for day := 1; day <= done; day++ {
+			entries[storage.DailyHabit{Day: civil.Date{Year: 2025, Month: month, Day: day}, Habit: "Read"}] = storage.Outcome{Result: "y"}
+		}
+	}
+
+	to := civil.Date{Year: 2025, Month: 4, Day: 8}
+	rate, percentile := ui.MonthPercentile(habit, &entries, to)
+	if rate != 100 || percentile == nil || *percentile != 100 {
+		t.Errorf("Expected April's 100%% to beat every month, got %v%% and %v", rate, percentile)
+	}
+	stats := ui.BuildStatsUntil(habit, &entries, to)
+	if stats.MonthPercentile == nil || *stats.MonthPercentile != 100 {
+		t.Errorf("Expected stats to rank April, got %+v", stats)
+	}
+
+	// Half of April is better than January and March only
+	if _, percentile := ui.MonthPercentile(habit, &entries, to.AddDays(8)); percentile == nil || math.Round(*percentile) != 67 {
+		t.Errorf("Expected April to beat 2 of 3 months, got %v", percentile)
+	}
+
+	// Two earlier months are too few to compare with
+	if _, percentile := ui.MonthPercentile(habit, &entries, civil.Date{Year: 2025, Month: 3, Day: 31}); percentile != nil {
+		t.Errorf("Expected no percentile from 2 earlier months, got %v", *percentile)
+	}
+}
+
+func TestHabitIndexMatchesEntries(t *testing.T) {
+	dir := t.TempDir()
+	to := civil.Date{Year: 2025, Month: 6, Day: 30}
+	from := to.AddYears(-1)
+	if err := sample.Write(dir, sample.Habits(12), from, to, 3); err != nil {
+		t.Fatal(err)
+	}
+	habits, _ := storage.LoadHabitsConfig(dir)
+	indexedHabits, _ := storage.LoadHabitsConfig(dir)
+	plain := storage.LoadLog(dir)
+	indexed := storage.LoadLog(dir)
+	indexed.IndexHabits()
+	plain.Entries.FirstRecords(from, to, habits)
+	indexed.FirstRecords(from, to, indexedHabits)
+
+	// The index reads the same as looking up day by day
+	for i, habit := range habits {
+		if a, b := plain.Entries.Outcomes(habit.Name, from, to), indexed.Outcomes(habit.Name, from, to); !reflect.DeepEqual(a, b) {
+			t.Errorf("%s: expected the same outcomes indexed, got %+v and %+v", habit.Name, a, b)
+		}
+		if habit.FirstRecord != indexedHabits[i].FirstRecord {
+			t.Errorf("%s: expected the same first record indexed, got %v and %v", habit.Name, habit.FirstRecord, indexedHabits[i].FirstRecord)
+		}
+	}
+
+	// Stats and graphs read the same from the index as day by day
+	for _, habit := range habits {
+		if a, b := ui.BuildStatsUntil(habit, plain.Entries, to), ui.BuildStatsUntil(habit, indexed, to); !reflect.DeepEqual(a, b) {
+			t.Errorf("%s: expected the same stats indexed, got %+v and %+v", habit.Name, a, b)
+		}
+		if a, b := graph.BuildGraphUntil(habit, plain.Entries, to, 100, false), graph.BuildGraphUntil(habit, indexed, to, 100, false); a != b {
+			t.Errorf("%s: expected the same graph indexed, got\n%s\n%s", habit.Name, a, b)
+		}
+	}
+
+	// Recording and forgetting keep the index up to date
+	key := storage.DailyHabit{Day: to.AddDays(1), Habit: habits[0].Name}
+	indexed.Record(key, storage.Outcome{Result: "y"})
+	if outcomes := indexed.Outcomes(key.Habit, key.Day, key.Day); len(outcomes) != 1 || outcomes[0].Result != "y" {
+		t.Errorf("Expected the recorded entry indexed, got %+v", outcomes)
+	}
+	indexed.Forget(key)
+	if outcomes := indexed.Outcomes(key.Habit, key.Day, key.Day); len(outcomes) != 0 {
+		t.Errorf("Expected the forgotten entry gone from the index, got %+v", outcomes)
+	}
+
+	// Entries copied out of an indexed log are read day by day, unaffected
+	// by the log's index
+	clone := maps.Clone(indexed.Entries)
+	clone[key] = storage.Outcome{Result: "n"}
+	if outcomes := clone.Outcomes(key.Habit, key.Day, key.Day); len(outcomes) != 1 || outcomes[0].Result != "n" {
+		t.Errorf("Expected the clone's own entry, got %+v", outcomes)
+	}
+}
+
+func TestConsistencyIndex(t *testing.T) {
+	start := civil.Date{Year: 2025, Month: 1, Day: 1}
+	to := start.AddDays(13)
+	steady := &storage.Habit{Name: "Steady", Target: 1, Interval: 2, FirstRecord: start}
+	bursty := &storage.Habit{Name: "Bursty", Target: 1, Interval: 2, FirstRecord: start}
+
+	entries := storage.Entries{}
+	// Both are done 5 times: Steady every other day, Bursty in clumps
+	for _, day := range []int{0, 2, 4, 6, 8} {
+		entries[storage.DailyHabit{Day: start.AddDays(day), Habit: "Steady"}] = storage.Outcome{Result: "y"}
+	}
+	for _, day := range []int{0, 1, 2, 9, 10} {
+		entries[storage.DailyHabit{Day: start.AddDays(day), Habit: "Bursty"}] = storage.Outcome{Result: "y"}
+	}
+
+	if got := ui.ConsistencyIndex(steady, &entries, to); got != 100 {
+		t.Errorf("Expected evenly spaced habit to score 100, got %.1f", got)
+	}
+	if got := ui.ConsistencyIndex(bursty, &entries, to); got <= 0 || got >= 50 {
+		t.Errorf("Expected clumped habit to score between 0 and 50, got %.1f", got)
+	}
+
+	// Skipped days don't count towards gaps
+	entries[storage.DailyHabit{Day: start.AddDays(10), Habit: "Steady"}] = storage.Outcome{Result: "s"}
+	entries[storage.DailyHabit{Day: start.AddDays(11), Habit: "Steady"}] = storage.Outcome{Result: "s"}
+	entries[storage.DailyHabit{Day: start.AddDays(12), Habit: "Steady"}] = storage.Outcome{Result: "y"}
+	if got := ui.ConsistencyIndex(steady, &entries, to); got != 100 {
+		t.Errorf("Expected skips to keep spacing even, got %.1f", got)
+	}
+
+	// Too few completions to judge
+	few := &storage.Habit{Name: "Few", Target: 1, Interval: 1, FirstRecord: start}
+	entries[storage.DailyHabit{Day: start, Habit: "Few"}] = storage.Outcome{Result: "y"}
+	entries[storage.DailyHabit{Day: start.AddDays(3), Habit: "Few"}] = storage.Outcome{Result: "y"}
+	if got := ui.ConsistencyIndex(few, &entries, to); got != 0 {
+		t.Errorf("Expected 0 with two completions, got %.1f", got)
+	}
+}
+
+func TestBuildComparison(t *testing.T) {
+	start := civil.Date{Year: 2025, Month: 1, Day: 1}
+	run := &storage.Habit{Name: "Run", Target: 1, Interval: 1, FirstRecord: start}
+	read := &storage.Habit{Name: "Read", Target: 1, Interval: 1, FirstRecord: start}
+
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: start, Habit: "Run"}:             {Result: "y"},
+		storage.DailyHabit{Day: start, Habit: "Read"}:            {Result: "y"},
+		storage.DailyHabit{Day: start.AddDays(1), Habit: "Run"}:  {Result: "y"},
+		storage.DailyHabit{Day: start.AddDays(1), Habit: "Read"}: {Result: "n"},
+		storage.DailyHabit{Day: start.AddDays(2), Habit: "Run"}:  {Result: "n"},
+		storage.DailyHabit{Day: start.AddDays(2), Habit: "Read"}: {Result: "y"},
+		storage.DailyHabit{Day: start.AddDays(3), Habit: "Run"}:  {Result: "y"},
+		storage.DailyHabit{Day: start.AddDays(3), Habit: "Read"}: {Result: "y"},
+	}
+
+	comparison := ui.BuildComparison(run, read, entries, start.AddDays(3))
+
+	if comparison.DaysCompared != 4 || comparison.DoneA != 3 || comparison.DoneB != 3 || comparison.DoneBoth != 2 {
+		t.Errorf("Unexpected comparison counts: %+v", comparison)
+	}
+	if p := comparison.ProbBGivenA(); p < 66.6 || p > 66.7 {
+		t.Errorf("Expected P(Read | Run) of 66.7%%, got %f", p)
+	}
+
+	// Habits never recorded have nothing to compare
+	empty := ui.BuildComparison(run, &storage.Habit{Name: "None"}, entries, start.AddDays(3))
+	if empty.DaysCompared != 0 || empty.ProbAGivenB() != 0 {
+		t.Errorf("Expected empty comparison, got %+v", empty)
+	}
+}
+
+func TestBuildLeaderboard(t *testing.T) {
+	start := civil.Date{Year: 2025, Month: 1, Day: 1}
+	profile := func(name string, done int) *storage.Profile {
+		entries := storage.Entries{}
+		for i := range 4 {
+			result := "n"
+			if i < done {
+				result = "y"
+			}
+			entries[storage.DailyHabit{Day: start.AddDays(i), Habit: "Gym"}] = storage.Outcome{Result: result}
+		}
+		return &storage.Profile{
+			Name: name,
+			Habits: []*storage.Habit{
+				{Name: "Gym", Target: 1, Interval: 1, FirstRecord: start},
+				{Name: name + " only", Target: 1, Interval: 1, FirstRecord: start},
+			},
+			Log: &storage.Log{Entries: entries, Header: storage.DefaultHeader},
+		}
+	}
+	profiles := []*storage.Profile{profile("bob", 1), profile("alice", 3)}
+
+	shared := ui.SharedHabits(profiles)
+	if len(shared) != 1 || shared[0] != "Gym" {
+		t.Fatalf("Expected only Gym to be shared, got %v", shared)
+	}
+
+	standings := ui.BuildLeaderboard(profiles, shared, start, start.AddDays(3))
+	if standings[0].Profile != "alice" || standings[0].Rate != 75 {
+		t.Errorf("Expected alice first at 75%%, got %+v", standings[0])
+	}
+	if standings[1].Profile != "bob" || standings[1].Rate != 25 {
+		t.Errorf("Expected bob second at 25%%, got %+v", standings[1])
+	}
+}
+
+func TestBuildProfileStats(t *testing.T) {
+	start := civil.Date{Year: 2025, Month: 1, Day: 1}
+	alice := &storage.Profile{
+		Name:   "alice",
+		Habits: []*storage.Habit{{Name: "Gym", Frequency: "1", Target: 1, Interval: 1, FirstRecord: start}},
+		Log: &storage.Log{Entries: storage.Entries{
+			{Day: start, Habit: "Gym"}:            {Result: "y", Amount: 2},
+			{Day: start.AddDays(1), Habit: "Gym"}: {Result: "y", Amount: 3},
+			{Day: start.AddDays(2), Habit: "Gym"}: {Result: "n"},
+			{Day: start.AddDays(3), Habit: "Gym"}: {Result: "s"},
+		}},
+	}
+	bob := &storage.Profile{
+		Name: "bob",
+		Habits: []*storage.Habit{
+			{Name: "Never logged", Frequency: "1", Target: 1, Interval: 1},
+			{Name: "Gym", Frequency: "1", Target: 1, Interval: 1, FirstRecord: start.AddDays(2)},
+		},
+		Log: &storage.Log{Entries: storage.Entries{
+			{Day: start.AddDays(2), Habit: "Gym"}: {Result: "y", Amount: 5},
+			{Day: start.AddDays(3), Habit: "Gym"}: {Result: "n"},
+		}},
+	}
+
+	groups := ui.BuildProfileStats([]*storage.Profile{alice, bob}, start.AddDays(3))
+	if len(groups) != 1 || groups[0].Name != "Gym" || len(groups[0].Profiles) != 2 {
+		t.Fatalf("Expected Gym grouped across both profiles and the unlogged habit left out, got %+v", groups)
+	}
+	gym := groups[0]
+	if gym.Total != 10 || gym.Streaks != 3 || gym.Breaks != 2 || gym.DaysTracked != 6 {
+		t.Errorf("Expected the profiles' stats summed, got %+v", gym)
+	}
+	// 3 done of the 5 tracked days that weren't skipped
+	if gym.CompletionRate != 60 {
+		t.Errorf("Expected a combined completion of 60%%, got %v", gym.CompletionRate)
+	}
+	if gym.Profiles[1].Profile != "bob" || gym.Profiles[1].CompletionRate != 50 {
+		t.Errorf("Expected bob's own completion of 50%%, got %+v", gym.Profiles[1])
+	}
+}
+
+func TestBuildShare(t *testing.T) {
+	to := civil.Date{Year: 2025, Month: 1, Day: 3}
+	habits := []*storage.Habit{
+		{Name: "Therapy", Heading: "Private", Frequency: "1", Target: 1, Interval: 1, FirstRecord: to.AddDays(-2)},
+	}
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: to.AddDays(-2), Habit: "Therapy"}: {Result: "y", Comment: "secret comment", Amount: 42},
+		storage.DailyHabit{Day: to, Habit: "Therapy"}:             {Result: "n"},
+	}
+
+	share := ui.BuildShare(habits, entries, to, 3, true)
+	if len(share.Habits) != 1 || share.Habits[0].Name != "Habit 1" || share.Habits[0].Heading != "" {
+		t.Fatalf("Expected pseudonymised habit, got %+v", share.Habits)
+	}
+	if len(share.Habits[0].History) != 2 || share.Habits[0].History[0].Result != "y" {
+		t.Errorf("Unexpected history: %+v", share.Habits[0].History)
+	}
+
+	out, err := share.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, private := range []string{"Therapy", "Private", "secret comment", "42"} {
+		if strings.Contains(string(out), private) || strings.Contains(share.HTML(), private) {
+			t.Errorf("Share snapshot leaks %q", private)
+		}
+	}
+
+	named := ui.BuildShare(habits, entries, to, 3, false)
+	if named.Habits[0].Name != "Therapy" || !strings.Contains(named.HTML(), "Therapy") {
+		t.Error("Expected real habit names without pseudonymisation")
+	}
+}
+
+func TestDisplayTruncatesLongHabitNames(t *testing.T) {
+	longName := "A habit name far too long to fit in the name column"
+	habits := []*storage.Habit{
+		{Name: longName, Heading: "Work", Target: 1, Interval: 1, FirstRecord: civil.Date{Year: 2025, Month: 1, Day: 1}},
+	}
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 1, Day: 15}, Habit: longName}: {Result: "y"},
+	}
+
+	capture := func(show func(display *ui.Display)) string {
+		old := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		show(ui.NewDisplay(true))
+		w.Close()
+		os.Stdout = old
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r)
+		return buf.String()
+	}
+
+	// 20 wide name column keeps 10 characters of name
+	logOutput := capture(func(display *ui.Display) {
+		display.ShowHabitLog(habits, entries, civil.Date{Year: 2025, Month: 1, Day: 15}, 10, 20, "")
+	})
+	if !strings.Contains(logOutput, "A habit n…  ") || strings.Contains(logOutput, longName) {
+		t.Errorf("Expected truncated habit name in log output, got:\n%s", logOutput)
+	}
+
+	statsOutput := capture(func(display *ui.Display) {
+		display.ShowHabitStats(habits, entries, 20)
+	})
+	if !strings.Contains(statsOutput, "A habit n…  ") || !strings.Contains(statsOutput, "↳ "+longName) {
+		t.Errorf("Expected truncated name with full name underneath in stats output, got:\n%s", statsOutput)
+	}
+}
+
+func TestBuildOrgHabits(t *testing.T) {
+	start := civil.Date{Year: 2025, Month: 3, Day: 3}
+	habits := []*storage.Habit{
+		{Name: "Gym", Heading: "Health", Target: 3, Interval: 7, FirstRecord: start},
+		{Name: "Weight", Heading: "Health", Target: 0, Interval: 1, FirstRecord: start},
+	}
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: start, Habit: "Gym"}:            {Result: "y", Comment: "legs"},
+		storage.DailyHabit{Day: start.AddDays(1), Habit: "Gym"}: {Result: "n"},
+		storage.DailyHabit{Day: start.AddDays(2), Habit: "Gym"}: {Result: "y"},
+		storage.DailyHabit{Day: start, Habit: "Weight"}:         {Result: "y", Amount: 80},
+	}
+
+	org := ui.BuildOrgHabits(habits, entries, start.AddDays(3))
+
+	expected := []string{
+		"* Health\n",
+		"** TODO Gym\n",
+		"SCHEDULED: <2025-03-07 Fri .+2d/3d>",
+		":STYLE:    habit",
+		":LAST_REPEAT: [2025-03-05 Wed]",
+		"- State \"DONE\"       from \"TODO\"       [2025-03-05 Wed]\n",
+		"- State \"DONE\"       from \"TODO\"       [2025-03-03 Mon] \\\\\n     legs",
+	}
+	for _, want := range expected {
+		if !strings.Contains(org, want) {
+			t.Errorf("Expected org export to contain %q, got:\n%s", want, org)
+		}
+	}
+	// Newest state change comes first, as org writes them
+	if strings.Index(org, "2025-03-05 Wed]\n") > strings.Index(org, "[2025-03-03 Mon]") {
+		t.Error("Expected state changes newest first")
+	}
+	if strings.Contains(org, "Weight") {
+		t.Error("Tracking-only habits have no schedule and should be left out")
+	}
+}
+
+func TestDisplayShowCompactLog(t *testing.T) {
+	habits := []*storage.Habit{
+		{Name: "Test1", Heading: "Work", Target: 1, Interval: 1, FirstRecord: civil.Date{Year: 2025, Month: 1, Day: 1}},
+		{Name: "Test2", Heading: "Health", Target: 1, Interval: 1, FirstRecord: civil.Date{Year: 2025, Month: 1, Day: 1}},
+	}
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: civil.Date{Year: 2025, Month: 1, Day: 15}, Habit: "Test1"}: {Result: "y"},
+	}
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	ui.NewDisplay(true).ShowCompactLog(habits, entries, civil.Date{Year: 2025, Month: 1, Day: 15}, 4, 5, "")
+	w.Close()
+	os.Stdout = old
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	expected := "Test1 !!!!━\nTest2 !!!!!\n"
+	if output != expected {
+		t.Errorf("Expected compact output %q, got %q", expected, output)
+	}
+}
+
+func TestBuildXP(t *testing.T) {
+	start := civil.Date{Year: 2025, Month: 1, Day: 1}
+	run := &storage.Habit{Name: "Run", Target: 1, Interval: 1, FirstRecord: start}
+	read := &storage.Habit{Name: "Read", Target: 1, Interval: 1, FirstRecord: start}
+	coffee := &storage.Habit{Name: "Coffee", Target: 0, Interval: 1, FirstRecord: start}
+	habits := []*storage.Habit{run, read, coffee}
+
+	entries := &storage.Entries{
+		// Day 1: both done, a perfect day
+		storage.DailyHabit{Day: start, Habit: "Run"}:    {Result: "y"},
+		storage.DailyHabit{Day: start, Habit: "Read"}:   {Result: "y"},
+		storage.DailyHabit{Day: start, Habit: "Coffee"}: {Result: "y"},
+		// Day 2: Run continues its streak, Read is broken
+		storage.DailyHabit{Day: start.AddDays(1), Habit: "Run"}:  {Result: "y"},
+		storage.DailyHabit{Day: start.AddDays(1), Habit: "Read"}: {Result: "n"},
+		// Day 3: Run skipped, Read restarts
+		storage.DailyHabit{Day: start.AddDays(2), Habit: "Run"}:  {Result: "s"},
+		storage.DailyHabit{Day: start.AddDays(2), Habit: "Read"}: {Result: "y"},
+		// Day 4: Run's streak survived the skip
+		storage.DailyHabit{Day: start.AddDays(3), Habit: "Run"}: {Result: "y"},
+	}
+
+	report := ui.BuildXP(habits, entries, start.AddDays(3))
+
+	expected := []int{
+		2*ui.XPPerCompletion + ui.XPPerfectDayBonus,
+		ui.XPPerCompletion + 1,
+		ui.XPPerCompletion + ui.XPPerfectDayBonus,
+		ui.XPPerCompletion + 2,
+	}
+	if len(report.Days) != len(expected) {
+		t.Fatalf("Expected %d days, got %d", len(expected), len(report.Days))
+	}
+	total := 0
+	for i, xp := range expected {
+		if report.Days[i].XP != xp {
+			t.Errorf("Day %d: expected %d XP, got %d", i+1, xp, report.Days[i].XP)
+		}
+		total += xp
+	}
+	if report.Total != total {
+		t.Errorf("Expected %d XP in total, got %d", total, report.Total)
+	}
+}
+
+func TestLevelFor(t *testing.T) {
+	tests := []struct {
+		xp, level, levelStart, nextLevel int
+	}{
+		{0, 1, 0, 100},
+		{99, 1, 0, 100},
+		{100, 2, 100, 300},
+		{299, 2, 100, 300},
+		{300, 3, 300, 600},
+	}
+	for _, tt := range tests {
+		level, levelStart, nextLevel := ui.LevelFor(tt.xp)
+		if level != tt.level || levelStart != tt.levelStart || nextLevel != tt.nextLevel {
+			t.Errorf("LevelFor(%d) = %d, %d, %d; want %d, %d, %d", tt.xp, level, levelStart, nextLevel, tt.level, tt.levelStart, tt.nextLevel)
+		}
+	}
+}
+
+func TestRebuildXPState(t *testing.T) {
+	start := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habits := []*storage.Habit{{Name: "Run", Target: 1, Interval: 1, FirstRecord: start}}
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: start, Habit: "Run"}:            {Result: "y"},
+		storage.DailyHabit{Day: start.AddDays(1), Habit: "Run"}: {Result: "y"},
+		// Logged after the state was checked, so left out of it
+		storage.DailyHabit{Day: start.AddDays(2), Habit: "Run"}: {Result: "y"},
+	}
+
+	state := ui.RebuildXPState(habits, entries, start.AddDays(1))
+	expected := storage.XPState{XP: ui.XPPerCompletion + ui.XPPerfectDayBonus + ui.XPPerCompletion + 1 + ui.XPPerfectDayBonus, Level: 1, Checked: start.AddDays(1)}
+	if state != expected {
+		t.Errorf("Expected %+v, got %+v", expected, state)
+	}
+}
+
+func TestStaleHabits(t *testing.T) {
+	to := civil.Date{Year: 2025, Month: 3, Day: 31}
+	active := &storage.Habit{Name: "Active", Target: 1, Interval: 1, FirstRecord: to.AddDays(-60)}
+	forgotten := &storage.Habit{Name: "Forgotten", Target: 1, Interval: 1, FirstRecord: to.AddDays(-60)}
+	broken := &storage.Habit{Name: "Broken", Target: 1, Interval: 7, FirstRecord: to.AddDays(-60)}
+	tracking := &storage.Habit{Name: "Coffee", Target: 0, Interval: 1, FirstRecord: to.AddDays(-60)}
+	unlogged := &storage.Habit{Name: "New", Target: 1, Interval: 1}
+	habits := []*storage.Habit{active, forgotten, broken, tracking, unlogged}
+
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: to.AddDays(-60), Habit: "Active"}:    {Result: "y"},
+		storage.DailyHabit{Day: to.AddDays(-2), Habit: "Active"}:     {Result: "s"},
+		storage.DailyHabit{Day: to.AddDays(-60), Habit: "Forgotten"}: {Result: "y"},
+		storage.DailyHabit{Day: to.AddDays(-20), Habit: "Forgotten"}: {Result: "y"},
+		// Logging a break still counts as tracking
+		storage.DailyHabit{Day: to.AddDays(-60), Habit: "Broken"}: {Result: "y"},
+		storage.DailyHabit{Day: to.AddDays(-5), Habit: "Broken"}:  {Result: "n"},
+		storage.DailyHabit{Day: to.AddDays(-60), Habit: "Coffee"}: {Result: "y"},
+	}
+
+	stale := ui.StaleHabits(habits, entries, to, 14)
+	if len(stale) != 1 {
+		t.Fatalf("Expected only Forgotten to be stale, got %+v", stale)
+	}
+	if stale[0].Habit != forgotten || stale[0].LastEntry != to.AddDays(-20) || stale[0].Days != 20 {
+		t.Errorf("Unexpected stale habit: %+v", stale[0])
+	}
+
+	if stale := ui.StaleHabits(habits, entries, to, 20); len(stale) != 0 {
+		t.Errorf("Expected nothing stale at exactly the limit, got %+v", stale)
+	}
+}
+
+func TestAskHabitsAnswersWholeHeading(t *testing.T) {
+	day := civil.Date{Year: 2025, Month: 1, Day: 15}
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habits := []*storage.Habit{
+		{Heading: "Health", Name: "Gym", Target: 1, Interval: 1, FirstRecord: first},
+		{Heading: "Health", Name: "Walk", Target: 1, Interval: 1, FirstRecord: first},
+		{Heading: "Work", Name: "Email", Target: 1, Interval: 1, FirstRecord: first},
+	}
+	repo := &MockRepository{
+		habits: habits,
+		log: &storage.Log{Entries: storage.Entries{
+			storage.DailyHabit{Day: first, Habit: "Email"}: {Result: "y"},
+		}, Header: storage.DefaultHeader},
+	}
+
+	// One answer for Gym covers Walk, then Email is asked on its own
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	r, w, _ := os.Pipe()
+	w.WriteString("all s # travel\nn\n")
+	w.Close()
+	devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	os.Stdin, os.Stdout = r, devNull
+
+	input := ui.NewInput(true)
+	input.AskHabits(habits, repo.log, repo, 20, 30, day.String())
+
+	os.Stdin, os.Stdout = oldStdin, oldStdout
+	devNull.Close()
+
+	expected := map[string]storage.Outcome{
+		"Gym":   {Result: "s", Comment: "travel"},
+		"Walk":  {Result: "s", Comment: "travel"},
+		"Email": {Result: "n"},
+	}
+	for name, want := range expected {
+		if got := repo.log.Entries[storage.DailyHabit{Day: day, Habit: name}]; got != want {
+			t.Errorf("%s: expected %+v, got %+v", name, want, got)
+		}
+	}
+}
+
+func TestAskHabitsOutput(t *testing.T) {
+	day := civil.Date{Year: 2025, Month: 1, Day: 15}
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habits := []*storage.Habit{{Heading: "Health", Name: "Gym", Target: 1, Interval: 1, FirstRecord: first}}
+	repo := &MockRepository{
+		habits: habits,
+		log: &storage.Log{Entries: storage.Entries{
+			storage.DailyHabit{Day: first, Habit: "Gym"}: {Result: "y"},
+		}, Header: storage.DefaultHeader},
+	}
+
+	oldStdin := os.Stdin
+	r, w, _ := os.Pipe()
+	w.WriteString("y\n")
+	w.Close()
+	os.Stdin = r
+	defer func() { os.Stdin = oldStdin }()
+
+	// Questions go where they're sent, leaving stdout alone
+	var out strings.Builder
+	input := ui.NewInput(true)
+	input.SetOutput(&out)
+	input.AskHabits(habits, repo.log, repo, 20, 30, day.String())
+
+	if !strings.Contains(out.String(), "Gym") || !strings.Contains(out.String(), "Health") {
+		t.Errorf("Expected the questions in the output, got %q", out.String())
+	}
+	if got := repo.log.Entries[storage.DailyHabit{Day: day, Habit: "Gym"}]; got.Result != "y" {
+		t.Errorf("Expected Gym answered, got %+v", got)
+	}
+}
+
+func TestAskHabitsAnswersNamedHeading(t *testing.T) {
+	day := civil.Date{Year: 2025, Month: 1, Day: 15}
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habits := []*storage.Habit{
+		{Heading: "Health", Name: "Gym", Target: 1, Interval: 1, FirstRecord: first},
+		{Heading: "Health", Name: "Walk", Target: 1, Interval: 1, FirstRecord: first},
+		{Heading: "Work", Name: "Email", Target: 1, Interval: 1, FirstRecord: first},
+		{Heading: "Work", Name: "Inbox", Target: 1, Interval: 1, FirstRecord: first},
+	}
+	repo := &MockRepository{
+		habits: habits,
+		log: &storage.Log{Entries: storage.Entries{
+			storage.DailyHabit{Day: first, Habit: "Email"}: {Result: "y"},
+		}, Header: storage.DefaultHeader},
+	}
+
+	// Work is answered ahead of time, Gym is still asked, then Health's done
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	r, w, _ := os.Pipe()
+	w.WriteString("all work: y @ 2 # caught up\nall Health: s\n")
+	w.Close()
+	devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	os.Stdin, os.Stdout = r, devNull
+
+	input := ui.NewInput(true)
+	input.AskHabits(habits, repo.log, repo, 20, 30, day.String())
+
+	os.Stdin, os.Stdout = oldStdin, oldStdout
+	devNull.Close()
+
+	expected := map[string]storage.Outcome{
+		"Gym":   {Result: "s"},
+		"Walk":  {Result: "s"},
+		"Email": {Result: "y", Amount: 2, Comment: "caught up"},
+		"Inbox": {Result: "y", Amount: 2, Comment: "caught up"},
+	}
+	for name, want := range expected {
+		if got := repo.log.Entries[storage.DailyHabit{Day: day, Habit: name}]; got != want {
+			t.Errorf("%s: expected %+v, got %+v", name, want, got)
+		}
+	}
+}
+
+func TestBuildGoalMonths(t *testing.T) {
+	start := civil.Date{Year: 2025, Month: 1, Day: 30}
+	habits := []*storage.Habit{
+		{Name: "Run", Target: 1, Interval: 1, FirstRecord: start},
+		{Name: "Read", Target: 1, Interval: 1, FirstRecord: start},
+	}
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: start, Habit: "Run"}:             {Result: "y"},
+		storage.DailyHabit{Day: start, Habit: "Read"}:            {Result: "y"},
+		storage.DailyHabit{Day: start.AddDays(1), Habit: "Run"}:  {Result: "y"},
+		storage.DailyHabit{Day: start.AddDays(1), Habit: "Read"}: {Result: "n"},
+		storage.DailyHabit{Day: start.AddDays(2), Habit: "Run"}:  {Result: "y"},
+		storage.DailyHabit{Day: start.AddDays(2), Habit: "Read"}: {Result: "s"},
+	}
+
+	months := ui.BuildGoalMonths(habits, entries, start.AddDays(3), 80)
+	if len(months) != 2 {
+		t.Fatalf("Expected 2 months, got %+v", months)
+	}
+	// January: a 100% day and a 50% day
+	if months[0].Month != (civil.Date{Year: 2025, Month: 1, Day: 1}) || months[0].Days != 2 || months[0].AtGoal != 1 {
+		t.Errorf("January incorrect: %+v", months[0])
+	}
+	// February: a day with Read skipped scores 100%, then nothing logged
+	if months[1].Days != 2 || months[1].AtGoal != 1 {
+		t.Errorf("February incorrect: %+v", months[1])
+	}
+
+	if month, ok := ui.CurrentGoalMonth(habits, entries, start.AddDays(3), 80); !ok || month != months[1] {
+		t.Errorf("Expected the current month to be %+v, got %+v", months[1], month)
+	}
+	if _, ok := ui.CurrentGoalMonth(habits, entries, start.AddDays(-1), 80); ok {
+		t.Error("Expected no current month before anything was scoreable")
+	}
+}
+
+func TestAskHabitsAsksBreakReason(t *testing.T) {
+	day := civil.Date{Year: 2025, Month: 1, Day: 15}
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habits := []*storage.Habit{
+		{Heading: "Health", Name: "Gym", Target: 1, Interval: 1, FirstRecord: first},
+		{Heading: "Health", Name: "Walk", Target: 1, Interval: 1, FirstRecord: first},
+	}
+	repo := &MockRepository{
+		habits: habits,
+		log: &storage.Log{Entries: storage.Entries{
+			storage.DailyHabit{Day: day.AddDays(-1), Habit: "Gym"}: {Result: "y"},
+		}, Header: storage.DefaultHeader},
+	}
+
+	// Gym's streak breaks so its reason is asked for, Walk had no streak to break
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	r, w, _ := os.Pipe()
+	w.WriteString("n # tired\nSlept in\nn\n")
+	w.Close()
+	devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	os.Stdin, os.Stdout = r, devNull
+
+	input := ui.NewInput(true)
+	input.BreakReasons = true
+	input.AskHabits(habits, repo.log, repo, 20, 30, day.String())
+
+	os.Stdin, os.Stdout = oldStdin, oldStdout
+	devNull.Close()
+
+	expected := map[string]storage.Outcome{
+		"Gym":  {Result: "n", Comment: "tired [why] Slept in"},
+		"Walk": {Result: "n"},
+	}
+	for name, want := range expected {
+		if got := repo.log.Entries[storage.DailyHabit{Day: day, Habit: name}]; got != want {
+			t.Errorf("%s: expected %+v, got %+v", name, want, got)
+		}
+	}
+}
+
+func TestAskHabitsReview(t *testing.T) {
+	day := civil.Date{Year: 2025, Month: 1, Day: 15}
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habits := []*storage.Habit{
+		{Heading: "Health", Name: "Gym", Target: 1, Interval: 1, FirstRecord: first},
+		{Heading: "Health", Name: "Walk", Target: 1, Interval: 1, FirstRecord: first},
+	}
+	ask := func(answers string) *MockRepository {
+		repo := &MockRepository{
+			habits: habits,
+			log: &storage.Log{Entries: storage.Entries{
+				storage.DailyHabit{Day: first, Habit: "Gym"}: {Result: "y"},
+			}, Header: storage.DefaultHeader},
+		}
+		oldStdin, oldStdout := os.Stdin, os.Stdout
+		r, w, _ := os.Pipe()
+		w.WriteString(answers)
+		w.Close()
+		devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		os.Stdin, os.Stdout = r, devNull
+
+		input := ui.NewInput(true)
+		input.Review = true
+		input.AskHabits(habits, repo.log, repo, 20, 30, day.String())
+
+		os.Stdin, os.Stdout = oldStdin, oldStdout
+		devNull.Close()
+		return repo
+	}
+
+	// Walk's slip is amended by its number before writing
+	repo := ask("y # early\nn\n2\ny # evening\ny\n")
+	expected := map[string]storage.Outcome{
+		"Gym":  {Result: "y", Comment: "early"},
+		"Walk": {Result: "y", Comment: "evening"},
+	}
+	for name, want := range expected {
+		if got := repo.log.Entries[storage.DailyHabit{Day: day, Habit: name}]; got != want {
+			t.Errorf("%s: expected %+v, got %+v", name, want, got)
+		}
+	}
+
+	// Declining leaves the day unanswered
+	repo = ask("y\nn\nn\n")
+	for _, name := range []string{"Gym", "Walk"} {
+		if got, ok := repo.log.Entries[storage.DailyHabit{Day: day, Habit: name}]; ok {
+			t.Errorf("%s: expected nothing logged, got %+v", name, got)
+		}
+	}
+
+	// A failed write keeps the answers held for another try
+	repo = &MockRepository{habits: habits, log: &storage.Log{Entries: storage.Entries{
+		storage.DailyHabit{Day: first, Habit: "Gym"}: {Result: "y"},
+	}, Header: storage.DefaultHeader}}
+	failing := &failingBatchRepository{MockRepository: repo, failures: 1}
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	r, w, _ := os.Pipe()
+	w.WriteString("y\nn\ny\ny\n")
+	w.Close()
+	devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	os.Stdin, os.Stdout = r, devNull
+	input := ui.NewInput(true)
+	input.Review = true
+	input.AskHabits(habits, repo.log, failing, 20, 30, day.String())
+	os.Stdin, os.Stdout = oldStdin, oldStdout
+	devNull.Close()
+	if failing.batches != 2 || len(failing.written) != 2 {
+		t.Errorf("Expected the answers written together on the second try, got %d tries and %d written", failing.batches, len(failing.written))
+	}
+
+	lines := ui.AnswerSummary([]ui.Answer{
+		{Day: day, Habit: "Gym", Result: "y", Amount: "5", Comment: "early"},
+		{Day: day, Habit: "Walk", Result: "n"},
+	})
+	want := []string{
+		"  #  Date        Habit  Outcome  Amount  Comment",
+		"  1  2025-01-15  Gym    y        5       early",
+		"  2  2025-01-15  Walk   n",
+	}
+	if !slices.Equal(lines, want) {
+		t.Errorf("Unexpected summary:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+// failingBatchRepository fails its first batch writes, as on a full disk
+type failingBatchRepository struct {
+	*MockRepository
+	failures int
+	batches  int
+	written  []storage.EntryWrite
+}
+
+func (f *failingBatchRepository) WriteEntries(writes []storage.EntryWrite, header storage.Header) error {
+	f.batches++
+	if f.batches <= f.failures {
+		return errors.New("no space left on device")
+	}
+	f.written = append(f.written, writes...)
+	return nil
+}
+
+func TestAskHabitsAskOption(t *testing.T) {
+	// A Wednesday
+	day := civil.Date{Year: 2025, Month: 1, Day: 15}
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habits := []*storage.Habit{
+		{Heading: "Health", Name: "Walk", Target: 1, Interval: 1, FirstRecord: first},
+		{Heading: "Health", Name: "Gym", Target: 1, Interval: 1, FirstRecord: first, AskDays: []time.Weekday{time.Monday}},
+		{Heading: "Health", Name: "Swim", Target: 2, Interval: 14, FirstRecord: first, AskDue: true},
+		{Heading: "Family", Name: "Call", Target: 2, Interval: 14, FirstRecord: first, AskDue: true},
+	}
+	repo := &MockRepository{
+		habits: habits,
+		log: &storage.Log{Entries: storage.Entries{
+			storage.DailyHabit{Day: first, Habit: "Walk"}:           {Result: "y"},
+			storage.DailyHabit{Day: day.AddDays(-3), Habit: "Swim"}: {Result: "y"},
+			storage.DailyHabit{Day: first, Habit: "Call"}:           {Result: "y"},
+		}, Header: storage.DefaultHeader},
+	}
+
+	// Gym isn't asked on a Wednesday, nor Swim done days ago, but Call is due
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	r, w, _ := os.Pipe()
+	w.WriteString("y\ny\n")
+	w.Close()
+	devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	os.Stdin, os.Stdout = r, devNull
+
+	input := ui.NewInput(true)
+	input.AskHabits(habits, repo.log, repo, 20, 30, day.String())
+
+	os.Stdin, os.Stdout = oldStdin, oldStdout
+	devNull.Close()
+
+	for name, want := range map[string]bool{"Walk": true, "Gym": false, "Swim": false, "Call": true} {
+		if _, ok := repo.log.Entries[storage.DailyHabit{Day: day, Habit: name}]; ok != want {
+			t.Errorf("%s: expected asked to be %v", name, want)
+		}
+	}
+}
+
+func TestBuildBreakReasons(t *testing.T) {
+	start := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habits := []*storage.Habit{
+		{Name: "Run", Target: 1, Interval: 1, FirstRecord: start},
+		{Name: "Read", Target: 1, Interval: 1, FirstRecord: start},
+		{Name: "Meditate", Target: 1, Interval: 1, FirstRecord: start},
+	}
+	entries := &storage.Entries{
+		storage.DailyHabit{Day: start, Habit: "Run"}:             {Result: "n", Comment: "[why] Rain"},
+		storage.DailyHabit{Day: start.AddDays(1), Habit: "Run"}:  {Result: "n", Comment: "cold [why] rain"},
+		storage.DailyHabit{Day: start.AddDays(2), Habit: "Run"}:  {Result: "n", Comment: "[why] travel"},
+		storage.DailyHabit{Day: start, Habit: "Read"}:            {Result: "n", Comment: "[why] tired"},
+		storage.DailyHabit{Day: start.AddDays(1), Habit: "Read"}: {Result: "n", Comment: "no reason given"},
+	}
+
+	report := ui.BuildBreakReasons(habits, entries)
+	if len(report) != 2 || report[0].Habit != "Run" || report[1].Habit != "Read" {
+		t.Fatalf("Expected reasons for Run then Read, got %+v", report)
+	}
+	if report[0].Total != 3 || len(report[0].Reasons) != 2 || report[0].Reasons[0] != (ui.BreakReasonCount{Reason: "rain", Count: 2}) {
+		t.Errorf("Expected rain twice then travel for Run, got %+v", report[0])
+	}
+	if report[1].Total != 1 || report[1].Reasons[0].Reason != "tired" {
+		t.Errorf("Expected tired once for Read, got %+v", report[1])
+	}
+
+	if !ui.BreaksStreak(start.AddDays(3), habits[0], &storage.Entries{
+		storage.DailyHabit{Day: start.AddDays(2), Habit: "Run"}: {Result: "y"},
+	}) {
+		t.Error("Expected a miss after a done day to break the streak")
+	}
+	if ui.BreaksStreak(start.AddDays(3), habits[0], entries) {
+		t.Error("Expected a miss after a missed day not to break a streak")
+	}
+}
+
+func TestRendererRewritesChangedLines(t *testing.T) {
+	var out bytes.Buffer
+	renderer := ui.NewRenderer(&out)
+
+	if err := renderer.Render("Run  ━━\nRead ━ \nScore\n"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "\x1b[H\x1b[2J") || !strings.Contains(out.String(), "Read ━ ") {
+		t.Errorf("Expected the first frame to clear the screen and draw every line, got %q", out.String())
+	}
+
+	out.Reset()
+	if err := renderer.Render("Run  ━━\nRead ━━\n"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "\x1b[2;1HRead ━━\x1b[K\x1b[3;1H\x1b[K\x1b[3;1H"
+	if out.String() != expected {
+		t.Errorf("Expected only the changed and removed lines redrawn, got %q", out.String())
+	}
+}
+
+// showFrame returns what show prints on display
+func showFrame(display *ui.Display, show func()) string {
+	var out bytes.Buffer
+	display.SetOutput(&out)
+	show()
+	return out.String()
+}
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files with the current output")
+
+func TestDeterministicDisplayGolden(t *testing.T) {
+	today := civil.Date{Year: 2025, Month: 3, Day: 14}
+	first := today.AddDays(-20)
+	habits := []*storage.Habit{
+		{Heading: "Health", Name: "Run", Frequency: "3/7", Target: 3, Interval: 7, FirstRecord: first},
+		{Heading: "Health", Name: "Stretch", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first},
+		{Heading: "Mind", Name: "Read", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first},
+	}
+	entries := storage.Entries{}
+	for i := range 21 {
+		day := first.AddDays(i)
+		if i%3 == 0 {
+			entries[storage.DailyHabit{Day: day, Habit: "Run"}] = storage.Outcome{Result: "y"}
+		}
+		switch {
+		case i%5 == 4:
+			entries[storage.DailyHabit{Day: day, Habit: "Stretch"}] = storage.Outcome{Result: "s"}
+		case i%2 == 0:
+			entries[storage.DailyHabit{Day: day, Habit: "Stretch"}] = storage.Outcome{Result: "y"}
+		default:
+			entries[storage.DailyHabit{Day: day, Habit: "Stretch"}] = storage.Outcome{Result: "n"}
+		}
+		if i > 10 {
+			entries[storage.DailyHabit{Day: day, Habit: "Read"}] = storage.Outcome{Result: "y"}
+		}
+	}
+
+	display := ui.NewDeterministicDisplay(today, 80)
+	if display.Today() != today || display.TerminalWidth() != 80 {
+		t.Fatalf("Expected today and width to be fixed, got %s and %d", display.Today(), display.TerminalWidth())
+	}
+	frame := showFrame(display, func() {
+		display.ShowHabitLog(habits, &entries, display.Today(), 30, 20, "")
+		display.ShowHabitStats(habits, &entries, 20)
+	})
+
+	golden := filepath.Join("fixtures", "log.golden")
+	if *updateGolden {
+		if err := os.WriteFile(golden, []byte(frame), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Cannot read golden file (run with -update to create it): %v", err)
+	}
+	if frame != string(expected) {
+		t.Errorf("Output differs from %s (run with -update if the change is intended):\n%s", golden, frame)
+	}
+}
+
+func TestAskHabitsSkipsSnoozed(t *testing.T) {
+	day := civil.Date{Year: 2025, Month: 1, Day: 15}
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habits := []*storage.Habit{
+		{Heading: "Health", Name: "Gym", Target: 1, Interval: 1, FirstRecord: first},
+		{Heading: "Health", Name: "Walk", Target: 1, Interval: 1, FirstRecord: first},
+	}
+	repo := &MockRepository{
+		habits: habits,
+		log: &storage.Log{Entries: storage.Entries{
+			storage.DailyHabit{Day: first, Habit: "Walk"}: {Result: "y"},
+		}, Header: storage.DefaultHeader},
+	}
+
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	r, w, _ := os.Pipe()
+	w.WriteString("y\n")
+	w.Close()
+	devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	os.Stdin, os.Stdout = r, devNull
+
+	input := ui.NewInput(true)
+	input.Snoozed = storage.Snoozed{Day: day, Habits: []string{"Gym"}}
+	input.AskHabits(habits, repo.log, repo, 20, 30, day.String())
+
+	os.Stdin, os.Stdout = oldStdin, oldStdout
+	devNull.Close()
+
+	if _, ok := repo.log.Entries[storage.DailyHabit{Day: day, Habit: "Gym"}]; ok {
+		t.Error("Expected snoozed Gym not to be asked about")
+	}
+	if got := repo.log.Entries[storage.DailyHabit{Day: day, Habit: "Walk"}]; got.Result != "y" {
+		t.Errorf("Expected Walk to get the answer, got %+v", got)
+	}
+}
+
+func TestAskHabitsStopsAtEndOfInput(t *testing.T) {
+	day := civil.Date{Year: 2025, Month: 1, Day: 15}
+	habits := []*storage.Habit{
+		{Heading: "Health", Name: "Gym", Target: 1, Interval: 1},
+		{Heading: "Health", Name: "Walk", Target: 1, Interval: 1},
+	}
+	// An empty log onboards first, which must not wait for input either
+	repo := &MockRepository{
+		habits: habits,
+		log:    &storage.Log{Entries: storage.Entries{}, Header: storage.DefaultHeader},
+	}
+
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	r, w, _ := os.Pipe()
+	w.Close()
+	devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	os.Stdin, os.Stdout = r, devNull
+	defer func() {
+		os.Stdin, os.Stdout = oldStdin, oldStdout
+		devNull.Close()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		ui.NewInput(true).AskHabits(habits, repo.log, repo, 20, 30, day.String())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected AskHabits to return when stdin ends")
+	}
+	if len(repo.log.Entries) != 0 {
+		t.Errorf("Expected no answers without input, got %v", repo.log.Entries)
+	}
+}
+
+func TestBuildTodos(t *testing.T) {
+	day := civil.Date{Year: 2025, Month: 1, Day: 15}
+	first := civil.Date{Year: 2025, Month: 1, Day: 14}
+	habits := []*storage.Habit{
+		{Heading: "Health", Name: "Gym", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first},
+		{Name: "Walk", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first},
+	}
+	entries := storage.Entries{
+		storage.DailyHabit{Day: first, Habit: "Gym"}:  {Result: "y"},
+		storage.DailyHabit{Day: first, Habit: "Walk"}: {Result: "y"},
+		storage.DailyHabit{Day: day, Habit: "Walk"}:   {Result: "n"},
+	}
+
+	todos := ui.BuildTodos(habits, &entries, day, storage.Snoozed{})
+	want := []ui.TodoDay{{Date: "2025-01-15", Habits: []ui.TodoHabit{{Name: "Gym", Heading: "Health", Frequency: "1"}}}}
+	if !reflect.DeepEqual(todos, want) {
+		t.Errorf("Expected %+v, got %+v", want, todos)
+	}
+
+	todos = ui.BuildTodos(habits, &entries, day, storage.Snoozed{Day: day, Habits: []string{"Gym"}})
+	out, err := ui.TodosJSON(todos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "[]" {
+		t.Errorf("Expected an empty list with Gym snoozed, got %s", out)
+	}
+}
+
+func TestHabitSummary(t *testing.T) {
+	to := civil.Date{Year: 2025, Month: 1, Day: 15}
+	habit := &storage.Habit{Name: "Run", Target: 1, Interval: 1, FirstRecord: to.AddDays(-10)}
+	entries := storage.Entries{
+		storage.DailyHabit{Day: to.AddDays(-10), Habit: "Run"}: {Result: "y"},
+		storage.DailyHabit{Day: to.AddDays(-5), Habit: "Run"}:  {Result: "y"},
+		storage.DailyHabit{Day: to.AddDays(-3), Habit: "Run"}:  {Result: "s"},
+		storage.DailyHabit{Day: to.AddDays(-1), Habit: "Run"}:  {Result: "n"},
+		storage.DailyHabit{Day: to, Habit: "Run"}:              {Result: "y"},
+	}
+
+	if got, want := ui.HabitSummary(habit, &entries, to, 7), "Run: 2 of last 7 days, 1 skipped"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestMonthShading(t *testing.T) {
+	to := civil.Date{Year: 2025, Month: 3, Day: 2}
+	habit := &storage.Habit{Name: "Run", Target: 1, Interval: 1, FirstRecord: to.AddDays(-5)}
+	entries := storage.Entries{storage.DailyHabit{Day: to.AddDays(-5), Habit: "Run"}: {Result: "y"}}
+
+	oldLevel, oldEnable := color.ForceSetColorLevel(color.Level256), color.Enable
+	defer func() {
+		color.ForceSetColorLevel(oldLevel)
+		color.Enable = oldEnable
+	}()
+	color.Enable = true
+	display := ui.NewDisplay(false)
+	display.MonthShading = true
+	frame := showFrame(display, func() {
+		display.ShowCompactLog([]*storage.Habit{habit}, &entries, to, 5, 3, "")
+	})
+
+	// February, an even month, is shaded up to March 1st
+	shade := color.C256(236, true).Sprint
+	if want := "Run " + shade("━!!!") + "!!\n"; frame != want {
+		t.Errorf("Expected %q, got %q", want, frame)
+	}
+}
+
+func TestBuildChallengeProgress(t *testing.T) {
+	start := civil.Date{Year: 2025, Month: 3, Day: 1}
+	challenge := storage.Challenge{Name: "Week of runs", Habit: "Run", Start: start, Days: 7}
+	entries := storage.Entries{}
+	for day, result := range []string{"y", "y", "n", "y", "s", "y"} {
+		entries[storage.DailyHabit{Day: start.AddDays(day), Habit: "Run"}] = storage.Outcome{Result: result}
+	}
+
+	// Today, the last day, isn't missed before it's over
+	progress := ui.BuildChallengeProgress(challenge, &entries, start.AddDays(6))
+	if progress.Day != 7 || progress.Done != 4 || progress.Skipped != 1 || progress.Missed != 1 || progress.BestRun != 2 || progress.Finished {
+		t.Errorf("Unexpected progress on the last day: %+v", progress)
+	}
+
+	entries[storage.DailyHabit{Day: start.AddDays(6), Habit: "Run"}] = storage.Outcome{Result: "y"}
+	if progress := ui.BuildChallengeProgress(challenge, &entries, start.AddDays(10)); !progress.Finished || progress.Succeeded() || progress.Day != 7 {
+		t.Errorf("Expected the challenge finished without succeeding, got %+v", progress)
+	}
+	if bar := ui.ProgressBar(3, 6, 4); bar != "██░░" {
+		t.Errorf("Expected half a bar, got %q", bar)
+	}
+}
+
+func TestLogLeavesOutHiddenHabits(t *testing.T) {
+	to := civil.Date{Year: 2025, Month: 1, Day: 15}
+	habits := []*storage.Habit{
+		{Name: "Run", Target: 1, Interval: 1},
+		{Name: "Weight", Target: 0, Interval: 1, Hidden: true},
+	}
+	entries := storage.Entries{}
+	display := ui.NewDisplay(true)
+	show := func(fragment string) string {
+		return showFrame(display, func() { display.ShowCompactLog(habits, &entries, to, 3, 6, fragment) })
+	}
+
+	if frame := show(""); strings.Contains(frame, "Weight") || !strings.Contains(frame, "Run") {
+		t.Errorf("Expected only Run in the log, got %q", frame)
+	}
+	if frame := show("weig"); !strings.Contains(frame, "Weight") {
+		t.Errorf("Expected a hidden habit asked for by name to show, got %q", frame)
+	}
+	display.ShowHidden = true
+	if frame := show(""); !strings.Contains(frame, "Weight") {
+		t.Errorf("Expected hidden habits with ShowHidden, got %q", frame)
+	}
+}
+
+func TestAutofill(t *testing.T) {
+	day := civil.Date{Year: 2025, Month: 1, Day: 15}
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habits := []*storage.Habit{
+		{Name: "Run", Target: 1, Interval: 1, FirstRecord: first},
+		{Name: "Read", Target: 1, Interval: 1, FirstRecord: first},
+		{Name: "Coffee", Target: 0, Interval: 1, FirstRecord: first},
+		{Name: "Stretch", Target: 1, Interval: 1, FirstRecord: first, NoAutofill: true},
+		{Name: "Swim", Target: 1, Interval: 1},
+		{Name: "Gym", Target: 1, Interval: 1, FirstRecord: first},
+		{Name: "Clean", Target: 1, Interval: 7, FirstRecord: first},
+	}
+	repo := &MockRepository{
+		habits: habits,
+		log: &storage.Log{Entries: storage.Entries{
+			storage.DailyHabit{Day: day, Habit: "Read"}:              {Result: "y"},
+			storage.DailyHabit{Day: day.AddDays(-2), Habit: "Clean"}: {Result: "y"},
+		}, Header: storage.DefaultHeader},
+	}
+	snoozed := storage.Snoozed{Day: day, Habits: []string{"Gym"}}
+
+	filled, err := ui.Autofill(habits, repo.log, repo, day, snoozed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Read is answered, Coffee doesn't score, Stretch opted out, Swim never
+	// started, Gym is snoozed, and Clean is done for the week
+	if !reflect.DeepEqual(filled, []string{"Run"}) {
+		t.Errorf("Expected only Run filled in, got %v", filled)
+	}
+	if got := repo.log.Entries[storage.DailyHabit{Day: day, Habit: "Run"}]; got.Result != "n" || got.Comment != ui.AutofillComment {
+		t.Errorf("Expected Run logged as not done by autofill, got %+v", got)
+	}
+	if again := ui.Unanswered(habits, &repo.log.Entries, day, snoozed); len(again) != 0 {
+		t.Errorf("Expected nothing left unanswered, got %v", again)
+	}
+}
+
+func TestBuildScoreCorrelation(t *testing.T) {
+	start := civil.Date{Year: 2025, Month: 1, Day: 1}
+	sleep := &storage.Habit{Name: "Sleep", Target: 0, Interval: 1, FirstRecord: start}
+	gym := &storage.Habit{Name: "Gym", Target: 1, Interval: 1, FirstRecord: start}
+	read := &storage.Habit{Name: "Read", Target: 1, Interval: 1, FirstRecord: start}
+	habits := []*storage.Habit{sleep, gym, read}
+
+	// More sleep, more done
+	entries := storage.Entries{}
+	for i, hours := range []float64{5, 6, 7, 8} {
+		d := start.AddDays(i)
+		entries[storage.DailyHabit{Day: d, Habit: "Sleep"}] = storage.Outcome{Result: "y", Amount: hours}
+		entries[storage.DailyHabit{Day: d, Habit: "Gym"}] = storage.Outcome{Result: map[bool]string{true: "y", false: "n"}[hours >= 7]}
+		entries[storage.DailyHabit{Day: d, Habit: "Read"}] = storage.Outcome{Result: map[bool]string{true: "y", false: "n"}[hours >= 6]}
+	}
+
+	correlation := ui.BuildScoreCorrelation(sleep, habits, &entries, start.AddDays(5))
+	if len(correlation.Points) != 4 {
+		t.Fatalf("Expected 4 days with an amount, got %d", len(correlation.Points))
+	}
+	if correlation.R < 0.9 || correlation.Strength() != "strong positive" {
+		t.Errorf("Expected a strong positive correlation, got r = %.2f (%s)", correlation.R, correlation.Strength())
+	}
+	if correlation.Median != 6.5 || correlation.LowScore != 25 || correlation.HighScore != 100 {
+		t.Errorf("Expected 25%% below and 100%% above a median of 6.5, got %+v", correlation)
+	}
+	if rows := correlation.Scatter(); len(rows) != 12 || !strings.HasPrefix(rows[0], "100% │") {
+		t.Errorf("Expected a 10 row scatter with axes, got %q", rows)
+	}
+
+	// Amounts no axis can scale to still land in a cell
+	extreme := ui.ScoreCorrelation{Points: []ui.ScorePoint{{Amount: 1}, {Amount: math.Inf(1), Score: 100}}}
+	if rows := extreme.Scatter(); len(rows) != 12 {
+		t.Errorf("Expected a scatter with an infinite amount, got %q", rows)
+	}
+
+	// Too few days can't say anything
+	few := ui.BuildScoreCorrelation(sleep, habits, &entries, start.AddDays(1))
+	if len(few.Points) != 2 || few.Strength() != "unknown" {
+		t.Errorf("Expected no correlation from 2 days, got %+v", few)
+	}
+}
+
+func TestDaysSinceDone(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	to := first.AddDays(10)
+	habits := []*storage.Habit{
+		{Name: "Run", Target: 1, Interval: 1, FirstRecord: first},
+		{Name: "Read", Target: 1, Interval: 1, FirstRecord: first},
+	}
+	entries := storage.Entries{
+		storage.DailyHabit{Day: first.AddDays(7), Habit: "Run"}: {Result: "y"},
+		storage.DailyHabit{Day: first.AddDays(9), Habit: "Run"}: {Result: "n"},
+		storage.DailyHabit{Day: first, Habit: "Read"}:           {Result: "n"},
+	}
+
+	if days, ok := ui.DaysSinceDone(habits[0], &entries, to); !ok || days != 3 {
+		t.Errorf("Expected Run last done 3 days ago, got %d (%v)", days, ok)
+	}
+	if _, ok := ui.DaysSinceDone(habits[1], &entries, to); ok {
+		t.Error("Expected Read never to have been done")
+	}
+
+	display := ui.NewDeterministicDisplay(to, 80)
+	display.DaysSince = true
+	frame := showFrame(display, func() { display.ShowTodos(habits, &entries, 6) })
+	if !strings.Contains(frame, "Run  last done 3 days ago") || !strings.Contains(frame, "Read  never done") {
+		t.Errorf("Expected days since in todos, got %q", frame)
+	}
+}
+
+func TestWindowProgress(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	to := first.AddDays(9)
+	habits := []*storage.Habit{
+		{Name: "Gym", Target: 3, Interval: 7, FirstRecord: first},
+		{Name: "Rest", Target: 2, Interval: 14, MinGap: 1, FirstRecord: first},
+		{Name: "Read", Target: 1, Interval: 1, FirstRecord: first},
+	}
+	entries := storage.Entries{
+		// Out of the week ending on to
+		storage.DailyHabit{Day: to.AddDays(-7), Habit: "Gym"}: {Result: "y"},
+		storage.DailyHabit{Day: to.AddDays(-5), Habit: "Gym"}: {Result: "y"},
+		storage.DailyHabit{Day: to.AddDays(-2), Habit: "Gym"}: {Result: "y"},
+		storage.DailyHabit{Day: to.AddDays(-1), Habit: "Gym"}: {Result: "n"},
+		// The second is too soon after the first to count
+		storage.DailyHabit{Day: to.AddDays(-4), Habit: "Rest"}: {Result: "y"},
+		storage.DailyHabit{Day: to.AddDays(-3), Habit: "Rest"}: {Result: "y"},
+	}
+
+	if done := ui.WindowProgress(habits[0], &entries, to); done != 2 {
+		t.Errorf("Expected Gym done twice in the last 7 days, got %d", done)
+	}
+	if done := ui.WindowProgress(habits[1], &entries, to); done != 1 {
+		t.Errorf("Expected Rest done once counting its gap, got %d", done)
+	}
+	if ui.HasWindow(habits[2]) {
+		t.Error("Expected a daily habit to have no window to show")
+	}
+
+	display := ui.NewDeterministicDisplay(to, 80)
+	display.WindowProgress = true
+	frame := showFrame(display, func() { display.ShowTodos(habits, &entries, 6) })
+	if !strings.Contains(frame, "Gym  2/3 last 7 days") || !strings.Contains(frame, "Rest  1/2 last 14 days") {
+		t.Errorf("Expected window progress in todos, got %q", frame)
+	}
+	if strings.Contains(frame, "Read  ") {
+		t.Errorf("Expected no window progress for a daily habit, got %q", frame)
+	}
+	frame = showFrame(display, func() { display.ShowHabitLog(habits, &entries, to, 10, 14, "") })
+	if !strings.Contains(frame, "2/3 7d\n") || !strings.Contains(frame, "1/2 14d\n") {
+		t.Errorf("Expected window progress after the log's graphs, got %q", frame)
+	}
+}
+
+func TestDaysLeft(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	to := first.AddDays(20)
+	habits := []*storage.Habit{
+		{Name: "Gym", Target: 3, Interval: 7, FirstRecord: first},
+		{Name: "Plants", Target: 1, Interval: 3, FirstRecord: first},
+		{Name: "Call", Target: 1, Interval: 14, FirstRecord: first},
+		{Name: "Read", Target: 1, Interval: 1, FirstRecord: first},
+	}
+	entries := storage.Entries{
+		// The window runs out when the oldest of these drops out of it
+		storage.DailyHabit{Day: to.AddDays(-5), Habit: "Gym"}:  {Result: "y"},
+		storage.DailyHabit{Day: to.AddDays(-2), Habit: "Gym"}:  {Result: "y"},
+		storage.DailyHabit{Day: to.AddDays(-1), Habit: "Call"}: {Result: "y"},
+	}
+
+	if days, ok := ui.DaysLeft(habits[0], &entries, to); !ok || days != 2 {
+		t.Errorf("Expected 2 days left for Gym, got %d, %v", days, ok)
+	}
+	if days, ok := ui.DaysLeft(habits[1], &entries, to); !ok || days != 3 {
+		t.Errorf("Expected a whole window left when nothing's done, got %d, %v", days, ok)
+	}
+	if _, ok := ui.DaysLeft(habits[2], &entries, to); ok {
+		t.Error("Expected no countdown once the target is met")
+	}
+	if _, ok := ui.DaysLeft(habits[3], &entries, to); ok {
+		t.Error("Expected no countdown for a daily habit")
+	}
+
+	display := ui.NewDeterministicDisplay(to, 80)
+	frame := showFrame(display, func() { display.ShowTodos(habits, &entries, 8) })
+	if !strings.Contains(frame, "Gym  2 days left\n") || !strings.Contains(frame, "Plants  3 days left\n") {
+		t.Errorf("Expected days left in today's todos, got %q", frame)
+	}
+	if strings.Count(frame, "days left") != 2 {
+		t.Errorf("Expected days left only in today's todos, got %q", frame)
+	}
+}
+
+func TestBuildPlanProgress(t *testing.T) {
+	monday := civil.Date{Year: 2025, Month: 1, Day: 13}
+	habits := []*storage.Habit{
+		{Name: "Gym", Target: 3, Interval: 7, Frequency: "3/7"},
+		{Name: "Water", Target: 8, Interval: 1, CountTimes: true},
+	}
+	entries := storage.Entries{
+		storage.DailyHabit{Day: monday, Habit: "Gym"}:             {Result: "y"},
+		storage.DailyHabit{Day: monday.AddDays(2), Habit: "Gym"}:  {Result: "y"},
+		storage.DailyHabit{Day: monday.AddDays(-1), Habit: "Gym"}: {Result: "y"},
+		storage.DailyHabit{Day: monday, Habit: "Water"}:           {Result: "y", Amount: 5},
+	}
+	plan := storage.Plan{Week: monday, Targets: []storage.PlanTarget{{Habit: "Gym", Count: 2}, {Habit: "Water", Count: 40}}}
+
+	progress := ui.BuildPlanProgress(plan, habits, &entries, monday.AddDays(3))
+	if len(progress) != 2 || progress[0].Done != 2 || !progress[0].Met() {
+		t.Errorf("Expected Gym done twice this week, meeting its plan, got %+v", progress)
+	}
+	if progress[1].Done != 5 || progress[1].Met() {
+		t.Errorf("Expected Water's amount counted toward its plan, got %+v", progress[1])
+	}
+	if ui.SuggestedPlanCount(habits[0]) != 3 {
+		t.Errorf("Expected 3/7 to suggest 3, got %d", ui.SuggestedPlanCount(habits[0]))
+	}
+
+	checkIn := ui.PlanCheckIn(plan, progress)
+	if !strings.Contains(checkIn, "Gym    2/2  ✓") || !strings.Contains(checkIn, "Water  5/40\n") {
+		t.Errorf("Unexpected plan check-in:\n%s", checkIn)
+	}
+}
+
+func TestRetirementReport(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habit := &storage.Habit{Name: "Run", Heading: "Health", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first}
+	entries := storage.Entries{}
+	for i, result := range []string{"y", "y", "s", "y", "n", "y", "y"} {
+		entries[storage.DailyHabit{Day: first.AddDays(i), Habit: "Run"}] = storage.Outcome{Result: result, Amount: 2}
+	}
+
+	if got := ui.LongestStreak(habit, &entries, first.AddDays(6)); got != 3 {
+		t.Errorf("Expected a longest streak of 3 with the skip not breaking it, got %d", got)
+	}
+
+	report := ui.BuildRetirementReport(habit, &entries, first.AddDays(30))
+	if report.LastEntry != first.AddDays(6) || report.Stats.DaysTracked != 7 {
+		t.Errorf("Expected the report to end on the last entry, got %+v", report)
+	}
+	expected := "## Run (Health), retired 2025-01-31\n" +
+		"Frequency:       1\n" +
+		"Tracked:         2025-01-01 to 2025-01-07 (7 days)\n" +
+		"Completion rate: 83.3% (5 days done or on target, 1 broken, 1 skipped)\n" +
+		"Longest streak:  3 days\n" +
+		"Total amount:    14\n"
+	if report.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, report.String())
+	}
+}
+
+func TestRetunePreview(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	today := first.AddDays(9)
+	habit := &storage.Habit{Name: "Run", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first}
+	entries := storage.Entries{}
+	for i, result := range []string{"y", "n", "y", "n", "y", "n", "y", "y", "y"} {
+		entries[storage.DailyHabit{Day: first.AddDays(i), Habit: "Run"}] = storage.Outcome{Result: result}
+	}
+
+	if got := ui.CurrentStreak(habit, &entries, today); got != 3 {
+		t.Errorf("Expected a current streak of 3 with today not yet logged, got %d", got)
+	}
+
+	retuned, err := habit.Retuned("1/2", first.AddDays(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	preview := ui.BuildRetunePreview(habit, retuned, first.AddDays(5), &entries, today)
+	if preview.BeforeCurrent != 3 || preview.BeforeLongest != 3 {
+		t.Errorf("Expected streaks of 3 as it is, got %+v", preview)
+	}
+	// Misses from the change on are within the new target, those before aren't
+	if preview.AfterCurrent != 5 || preview.AfterLongest != 5 {
+		t.Errorf("Expected streaks of 5 retuned, got current %d and longest %d", preview.AfterCurrent, preview.AfterLongest)
+	}
+	if preview.AfterStats.Breaks != preview.BeforeStats.Breaks-1 {
+		t.Errorf("Expected one break fewer retuned, got %d then %d", preview.BeforeStats.Breaks, preview.AfterStats.Breaks)
+	}
+
+	if _, err := habit.Retuned("3/2", today); err == nil {
+		t.Error("Expected a frequency with a target above its interval to be refused")
+	}
+}
+
+func TestBuildFocus(t *testing.T) {
+	today := civil.Date{Year: 2025, Month: 1, Day: 10}
+	first := today.AddDays(-30)
+	habits := []*storage.Habit{
+		{Name: "Run", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first},
+		{Name: "Read", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first},
+		{Name: "Stretch", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first},
+		{Name: "Weekly review", Frequency: "7", Target: 1, Interval: 7, FirstRecord: first},
+		{Name: "Gym", Frequency: "2/7", Target: 2, Interval: 7, FirstRecord: first, Priority: 3},
+		{Name: "Weight", Frequency: "0", Target: 0, Interval: 0, FirstRecord: first},
+	}
+	entries := storage.Entries{
+		{Day: today.AddDays(-3), Habit: "Run"}:           {Result: "y"},
+		{Day: today.AddDays(-2), Habit: "Run"}:           {Result: "y"},
+		{Day: today.AddDays(-1), Habit: "Run"}:           {Result: "y"},
+		{Day: today.AddDays(-1), Habit: "Read"}:          {Result: "y"},
+		{Day: today.AddDays(-5), Habit: "Weekly review"}: {Result: "y"},
+		{Day: today.AddDays(-6), Habit: "Gym"}:           {Result: "y"},
+		{Day: today.AddDays(-1), Habit: "Gym"}:           {Result: "y"},
+	}
+	snoozed := storage.Snoozed{Day: today, Habits: []string{"Stretch"}}
+
+	focus := ui.BuildFocus(habits, &entries, today, snoozed, 3)
+	names := []string{}
+	for _, habit := range focus.Habits {
+		names = append(names, habit.Name)
+	}
+	// Gym breaks tomorrow but its priority of 3 puts it first, then the
+	// daily habits breaking today by streak
+	if !reflect.DeepEqual(names, []string{"Gym", "Run", "Read"}) {
+		t.Fatalf("Expected Gym, Run, and Read, got %+v", focus.Habits)
+	}
+	if focus.Habits[0].DaysLeft != 2 || focus.Habits[1].DaysLeft != 1 || focus.Habits[1].Streak != 3 {
+		t.Errorf("Expected days left and streaks worked out, got %+v", focus.Habits)
+	}
+	// Weight never breaks so it's left over, while Weekly review, done this
+	// week, and the snoozed Stretch aren't todos at all
+	if focus.Others != 1 {
+		t.Errorf("Expected Weight left over, got %d", focus.Others)
+	}
+
+	if focus := ui.BuildFocus(habits, &entries, today, snoozed, 1); len(focus.Habits) != 1 || focus.Others != 3 {
+		t.Errorf("Expected only the most urgent habit, got %+v", focus)
+	}
+}
+
+func TestForecast(t *testing.T) {
+	today := civil.Date{Year: 2025, Month: 1, Day: 10}
+	first := today.AddDays(-30)
+	habits := []*storage.Habit{
+		{Name: "Run", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first},
+		{Name: "Weekly review", Frequency: "7", Target: 1, Interval: 7, FirstRecord: first},
+		{Name: "Gym", Frequency: "3/7", Target: 3, Interval: 7, FirstRecord: first},
+		{Name: "Taxes", Frequency: "30", Target: 1, Interval: 30, FirstRecord: first, Hidden: true},
+		{Name: "New", Frequency: "2/7", Target: 2, Interval: 7},
+	}
+	entries := storage.Entries{
+		{Day: today.AddDays(-5), Habit: "Weekly review"}: {Result: "y"},
+		{Day: today.AddDays(-2), Habit: "Gym"}:           {Result: "y"},
+		{Day: today.AddDays(-1), Habit: "Gym"}:           {Result: "y"},
+		{Day: today, Habit: "Gym"}:                       {Result: "y"},
+	}
+
+	display := ui.NewDisplay(true)
+	forecast := display.BuildForecast(habits, &entries, today, ui.ForecastDays, "")
+	if forecast.From != "2025-01-10" || forecast.To != "2025-01-23" {
+		t.Errorf("Expected two weeks from today, got %s to %s", forecast.From, forecast.To)
+	}
+	// Daily, hidden, and never logged habits are left out
+	if len(forecast.Habits) != 2 {
+		t.Fatalf("Expected Weekly review and Gym, got %+v", forecast.Habits)
+	}
+	review, gym := forecast.Habits[0], forecast.Habits[1]
+	if review.Name != "Weekly review" || review.Due != "2025-01-11" || review.Breaks != "2025-01-12" {
+		t.Errorf("Expected Weekly review due tomorrow and broken the day after, got %+v", review)
+	}
+	if gym.Due != "" || gym.Breaks != "2025-01-15" || len(gym.Days) != ui.ForecastDays || gym.Days[0].State != graph.StateDone {
+		t.Errorf("Expected Gym to break once its first completion drops out, got %+v", gym)
+	}
+
+	// Done today keeps it going
+	entries[storage.DailyHabit{Day: today, Habit: "Weekly review"}] = storage.Outcome{Result: "y"}
+	if forecast := display.BuildForecast(habits, &entries, today, ui.ForecastDays, "review"); len(forecast.Habits) != 1 || forecast.Habits[0].Breaks != "2025-01-17" {
+		t.Errorf("Expected Weekly review to break a week after today, got %+v", forecast.Habits)
+	}
+}
+
+func TestMonthEditor(t *testing.T) {
+	month := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habit := &storage.Habit{Name: "Gym", Frequency: "1", Target: 1, Interval: 1, FirstRecord: month}
+	entries := storage.Entries{
+		{Day: month, Habit: "Gym"}:            {Result: "n", Comment: "tired", Amount: 2},
+		{Day: month.AddDays(1), Habit: "Gym"}: {Result: "y"},
+	}
+	editor := ui.NewMonthEditor(habit, month, &entries, civil.Date{Year: 2025, Month: 1, Day: 10})
+	if editor.Days() != 10 {
+		t.Errorf("Expected only days up to today to be editable, got %d", editor.Days())
+	}
+
+	for _, key := range []string{"y", "y", "n", "left", "u", "down", "s", "right", "right", "right", "right", "right"} {
+		if action := editor.HandleKey(key); action != ui.MonthEditorContinue {
+			t.Fatalf("Expected %q to keep editing, got %v", key, action)
+		}
+	}
+	if editor.Cursor() != month.AddDays(9) {
+		t.Errorf("Expected the cursor to stop on the last editable day, got %s", editor.Cursor())
+	}
+	editor.HandleKey(" ")
+
+	expected := []ui.MonthChange{
+		{Day: month, Outcome: storage.Outcome{Result: "y", Comment: "tired", Amount: 2}},
+		{Day: month.AddDays(9), Outcome: storage.Outcome{Result: "y"}},
+	}
+	changes := editor.Changes()
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %+v", len(expected), changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("Expected change %+v, got %+v", expected[i], changes[i])
+		}
+	}
+
+	frame := editor.Render()
+	for _, want := range []string{"Gym, January 2025", "       1y*  2y   3·   4·   5· \n", "[10y]", "w saves 2 changes"} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("Expected the grid to contain %q, got:\n%s", want, frame)
+		}
+	}
+	storage.FirstWeekday = time.Sunday
+	if frame := editor.Render(); !strings.Contains(frame, "  Su   Mo   Tu") || !strings.Contains(frame, "            1y*  2y   3·   4· \n") {
+		t.Errorf("Expected Sunday-first weeks, got:\n%s", frame)
+	}
+	storage.FirstWeekday = time.Monday
+	if editor.HandleKey("w") != ui.MonthEditorSave || editor.HandleKey("q") != ui.MonthEditorQuit {
+		t.Error("Expected w to save and q to quit")
+	}
+
+	for input, key := range map[string]string{"\x1b[A": "up", "\r": "enter", "\x1b": "esc", "y": "y"} {
+		if got, err := ui.ReadKey(strings.NewReader(input)); err != nil || got != key {
+			t.Errorf("Expected %q to read as %q, got %q (%v)", input, key, got, err)
+		}
+	}
+}
+
+func TestGridEditor(t *testing.T) {
+	start := civil.Date{Year: 2025, Month: 1, Day: 1}
+	today := start.AddDays(4)
+	habits := []*storage.Habit{
+		{Name: "Gym", Frequency: "1", Target: 1, Interval: 1, FirstRecord: start},
+		{Name: "Read", Frequency: "1", Target: 1, Interval: 1, FirstRecord: start},
+		{Name: "Weight", Frequency: "0", Target: 0, Interval: 1, FirstRecord: start, Hidden: true},
+	}
+	entries := storage.Entries{
+		{Day: start, Habit: "Gym"}:              {Result: "y"},
+		{Day: today.AddDays(-1), Habit: "Gym"}:  {Result: "n", Comment: "tired", Amount: 2},
+		{Day: today.AddDays(-1), Habit: "Read"}: {Result: "y"},
+	}
+	display := ui.NewDisplay(true)
+	display.Date = today
+	editor := display.NewGridEditor(habits, &entries, "", 12, 20)
+	if len(editor.Habits) != 2 {
+		t.Fatalf("Expected hidden habits left out like harsh log, got %d habits", len(editor.Habits))
+	}
+
+	// Yesterday: Gym done, then Read left as it was
+	for _, key := range []string{"left", "y", "y", "up", "right", "down", "left", "left", "y"} {
+		if action := editor.HandleKey(key); action != ui.MonthEditorContinue {
+			t.Fatalf("Expected %q to keep editing, got %v", key, action)
+		}
+	}
+	if habit, day := editor.Cursor(); habit.Name != "Read" || day != today.AddDays(-2) {
+		t.Errorf("Expected the cursor on Read two days ago, got %s on %s", habit.Name, day)
+	}
+	expected := []ui.EntryChange{
+		{Day: today.AddDays(-1), Habit: "Gym", Outcome: storage.Outcome{Result: "y", Comment: "tired", Amount: 2}},
+		{Day: today.AddDays(-2), Habit: "Read", Outcome: storage.Outcome{Result: "y"}},
+	}
+	changes := editor.Changes()
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %+v", len(expected), changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("Expected change %+v, got %+v", expected[i], changes[i])
+		}
+	}
+	if _, ok := entries[storage.DailyHabit{Day: today.AddDays(-2), Habit: "Read"}]; ok {
+		t.Error("Expected the log left alone until saved")
+	}
+
+	// The grid is 5 days wide: 12 less the names and the marks around them
+	frame := editor.Render()
+	for _, want := range []string{"  Gym* ━!!━!\n", ">Read* !!━━!\n", "\n         ^\n", "Read, Fri 2025-01-03: y", "w saves 2 changes"} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("Expected the grid to contain %q, got:\n%s", want, frame)
+		}
+	}
+	editor.HandleKey("u")
+	if len(editor.Changes()) != 1 {
+		t.Errorf("Expected u to undo the change under the cursor, got %+v", editor.Changes())
+	}
+}
+
+func TestStatusImage(t *testing.T) {
+	today := civil.Date{Year: 2025, Month: 1, Day: 20}
+	habits := []*storage.Habit{
+		{Name: "Gym", Frequency: "1", Target: 1, Interval: 1, FirstRecord: today.AddDays(-1)},
+		{Name: "Read", Frequency: "1", Target: 1, Interval: 1, FirstRecord: today.AddDays(-1)},
+	}
+	entries := storage.Entries{
+		{Day: today.AddDays(-1), Habit: "Gym"}:  {Result: "y"},
+		{Day: today.AddDays(-1), Habit: "Read"}: {Result: "n"},
+		{Day: today, Habit: "Read"}:             {Result: "y"},
+	}
+	status := ui.BuildStatus(habits, &entries, today, storage.Snoozed{})
+	if status.Score != 50 || status.Yesterday != 50 || !slices.Equal(status.Todos, []string{"Gym"}) {
+		t.Errorf("Expected a 50%% score with Gym to do, got %+v", status)
+	}
+	expected := []string{"harsh 2025-01-20", "Today 50.0%  Yday 50.0%", "", "1 to do:", "- Gym"}
+	if !slices.Equal(status.Lines(), expected) {
+		t.Errorf("Expected lines %q, got %q", expected, status.Lines())
+	}
+
+	var out bytes.Buffer
+	if err := status.PNG(&out); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&out)
+	if err != nil {
+		t.Fatalf("Expected a readable PNG: %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 23*12+24 || bounds.Dy() != 5*20+24 {
+		t.Errorf("Expected the image to fit the text, got %v", bounds)
+	}
+	dark := 0
+	for y := range img.Bounds().Dy() {
+		for x := range img.Bounds().Dx() {
+			if r, _, _, _ := img.At(x, y).RGBA(); r == 0 {
+				dark++
+			}
+		}
+	}
+	if dark == 0 {
+		t.Error("Expected text drawn onto the image")
+	}
+
+	status.Todos = []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+	if lines := status.Lines(); len(lines) != 12 || lines[11] != "+3 more" {
+		t.Errorf("Expected todos capped with a count of the rest, got %q", lines)
+	}
+}
+
+func TestHomeAssistantSensors(t *testing.T) {
+	today := civil.Date{Year: 2025, Month: 1, Day: 20}
+	first := today.AddDays(-9)
+	habits := []*storage.Habit{
+		{Name: "Push ups", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first},
+		{Name: "Gym", Frequency: "2/7", Target: 2, Interval: 7, FirstRecord: first},
+	}
+	entries := storage.Entries{}
+	for i := range 10 {
+		entries[storage.DailyHabit{Day: first.AddDays(i), Habit: "Push ups"}] = storage.Outcome{Result: "y"}
+	}
+	entries[storage.DailyHabit{Day: today.AddDays(-5), Habit: "Gym"}] = storage.Outcome{Result: "y"}
+	entries[storage.DailyHabit{Day: today.AddDays(-3), Habit: "Gym"}] = storage.Outcome{Result: "y"}
+
+	score := ui.BuildHAScore(habits, &entries, today, storage.Snoozed{})
+	if score.Value != 50.0 || score.Attributes["todos"] != 1 || !slices.Equal(score.Attributes["todo_habits"].([]string), []string{"Gym"}) {
+		t.Errorf("Expected half the score with Gym left to do, got %+v", score)
+	}
+
+	sensors := ui.BuildHAHabits(habits, &entries, today)
+	pushUps, gym := sensors["push_ups"], sensors["gym"]
+	if pushUps.Value != "done" || pushUps.Attributes["completion_rate"] != 100.0 || pushUps.Attributes["days_since_done"] != 0 {
+		t.Errorf("Expected push ups done today, got %+v", pushUps)
+	}
+	if gym.Value != "todo" || gym.Attributes["satisfied"] != true || gym.Attributes["days_since_done"] != 3 {
+		t.Errorf("Expected gym to do but satisfied by the week's two sessions, got %+v", gym)
+	}
+}
+
+func TestPositiveSkips(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habit := &storage.Habit{Name: "Run", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first}
+	entries := storage.Entries{}
+	for i, result := range []string{"y", "y", "s", "y", "n", "y"} {
+		entries[storage.DailyHabit{Day: first.AddDays(i), Habit: "Run"}] = storage.Outcome{Result: result}
+	}
+	to := first.AddDays(5)
+	defer func() { graph.PositiveSkips = false }()
+
+	stats := ui.BuildStatsUntil(habit, &entries, to)
+	if stats.Streaks != 4 || stats.Skips != 1 || ui.LongestStreak(habit, &entries, to) != 3 {
+		t.Errorf("Expected neutral skips left out of stats and bridging streaks, got %+v", stats)
+	}
+	if rate := ui.RateBetween(habit, &entries, first, to); rate != 80 {
+		t.Errorf("Expected the skip left out of the rate, got %.1f", rate)
+	}
+	if got := graph.BuildGraphUntil(habit, &entries, to, 5, false); got != "━━•━ ━" {
+		t.Errorf("Expected the skip drawn as a skip, got %q", got)
+	}
+
+	graph.PositiveSkips = true
+	stats = ui.BuildStatsUntil(habit, &entries, to)
+	if stats.Streaks != 5 || stats.Skips != 0 || ui.LongestStreak(habit, &entries, to) != 4 {
+		t.Errorf("Expected positive skips counted as done, got %+v", stats)
+	}
+	if rate := ui.RateBetween(habit, &entries, first, to); math.Abs(rate-83.33) > 0.01 {
+		t.Errorf("Expected the skip counted as done in the rate, got %.2f", rate)
+	}
+	if got := graph.BuildGraphUntil(habit, &entries, to, 5, false); got != "━━─━ ━" {
+		t.Errorf("Expected the skip drawn as satisfied, got %q", got)
+	}
+
+	graph.PositiveSkips, graph.BreakingSkips = false, true
+	defer func() { graph.BreakingSkips = false }()
+	stats = ui.BuildStatsUntil(habit, &entries, to)
+	if stats.Streaks != 4 || stats.Skips != 1 || stats.LongestStreak != 2 || ui.CurrentStreak(habit, &entries, to.AddDays(-2)) != 1 {
+		t.Errorf("Expected breaking skips to end streaks, got %+v", stats)
+	}
+	if rate := ui.RateBetween(habit, &entries, first, to); rate != 80 {
+		t.Errorf("Expected the breaking skip left out of the rate, got %.1f", rate)
+	}
+	if got := graph.BuildGraphUntil(habit, &entries, to, 5, false); got != "━━•━ ━" {
+		t.Errorf("Expected the breaking skip drawn as a skip, got %q", got)
+	}
+}
+
+func TestBuildGaps(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habit := &storage.Habit{Name: "Gym", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first}
+	entries := storage.Entries{}
+	for _, day := range []int{0, 1, 4, 6} {
+		entries[storage.DailyHabit{Day: first.AddDays(day), Habit: "Gym"}] = storage.Outcome{Result: "n"}
+	}
+
+	gaps := ui.BuildGaps(habit, &entries, first.AddDays(8))
+	expected := []ui.LogGap{
+		{From: first.AddDays(2), To: first.AddDays(3)},
+		{From: first.AddDays(5), To: first.AddDays(5)},
+		{From: first.AddDays(7), To: first.AddDays(8)},
+	}
+	if !slices.Equal(gaps, expected) {
+		t.Errorf("Expected gaps %v, got %v", expected, gaps)
+	}
+	if gaps[0].Days() != 2 || gaps[1].Days() != 1 {
+		t.Errorf("Expected gaps of 2 and 1 days, got %d and %d", gaps[0].Days(), gaps[1].Days())
+	}
+	if gaps := ui.BuildGaps(&storage.Habit{Name: "New"}, &entries, first); len(gaps) != 0 {
+		t.Errorf("Expected no gaps for a habit never logged, got %v", gaps)
+	}
+}
+
+func TestStatsFallbacks(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habit := &storage.Habit{Name: "Run", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first, Fallback: "Walk"}
+	entries := storage.Entries{
+		{Day: first, Habit: "Run"}:            {Result: "y"},
+		{Day: first.AddDays(1), Habit: "Run"}: {Result: "y", Comment: storage.WithFallback("")},
+		{Day: first.AddDays(2), Habit: "Run"}: {Result: "y", Comment: storage.WithFallback("tired")},
+	}
+	stats := ui.BuildStatsUntil(habit, &entries, first.AddDays(2))
+	if stats.Streaks != 3 || stats.Fallbacks != 2 || ui.LongestStreak(habit, &entries, first.AddDays(2)) != 3 {
+		t.Errorf("Expected fallbacks to keep the streak while counted apart, got %+v", stats)
+	}
+}
+
+func TestStatsStreaks(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habit := &storage.Habit{Name: "Gym", Frequency: "2/7", Target: 2, Interval: 7, FirstRecord: first}
+	entries := storage.Entries{}
+	// Twice a week for two weeks, then a week and a half off, then back at it
+	to := first.AddDays(28)
+	for d := first; !d.After(to); d = d.AddDays(1) {
+		entries[storage.DailyHabit{Day: d, Habit: "Gym"}] = storage.Outcome{Result: "n"}
+	}
+	for _, n := range []int{0, 3, 7, 10, 24, 27} {
+		entries[storage.DailyHabit{Day: first.AddDays(n), Habit: "Gym"}] = storage.Outcome{Result: "y"}
+	}
+	stats := ui.BuildStatsUntil(habit, &entries, to)
+	if stats.LongestStreak != 14 {
+		t.Errorf("Expected the first fortnight on target as the longest streak, got %+v", stats)
+	}
+	if stats.CurrentStreak != 5 {
+		t.Errorf("Expected 5 days on target since getting back to it, got %+v", stats)
+	}
+}
+
+func TestStatsAmountGoal(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	habit := &storage.Habit{Name: "Pushups", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first, AmountGoal: 50}
+	entries := storage.Entries{
+		{Day: first, Habit: "Pushups"}:            {Result: "y", Amount: 50},
+		{Day: first.AddDays(1), Habit: "Pushups"}: {Result: "y", Amount: 30},
+		{Day: first.AddDays(2), Habit: "Pushups"}: {Result: "y", Amount: 60},
+		{Day: first.AddDays(3), Habit: "Pushups"}: {Result: "n"},
+	}
+	stats := ui.BuildStatsUntil(habit, &entries, first.AddDays(3))
+	if stats.Streaks != 2 || stats.Short != 1 || stats.Breaks != 1 || stats.Total != 140 {
+		t.Errorf("Expected the day under the goal counted apart, got %+v", stats)
+	}
+	if longest := ui.LongestStreak(habit, &entries, first.AddDays(3)); longest != 1 {
+		t.Errorf("Expected the day under the goal to end the streak, got %d", longest)
+	}
+}
+
+func TestBuildMeta(t *testing.T) {
+	to := civil.Date{Year: 2025, Month: 3, Day: 14}
+	at := func(d civil.Date, hour, minute int) civil.DateTime {
+		return civil.DateTime{Date: d, Time: civil.Time{Hour: hour, Minute: minute}}
+	}
+	writes := []storage.Write{
+		// One session logging the day itself and a backfill
+		{At: at(to, 21, 0), Day: to, Habit: "Gym"},
+		{At: at(to, 21, 5), Day: to.AddDays(-10), Habit: "Read"},
+		// A session two weeks back, an hour apart from another
+		{At: at(to.AddDays(-14), 8, 0), Day: to.AddDays(-15), Habit: "Gym"},
+		{At: at(to.AddDays(-14), 9, 0), Day: to.AddDays(-14), Habit: "Read"},
+		// Written after to, so left out
+		{At: at(to.AddDays(1), 8, 0), Day: to, Habit: "Read"},
+	}
+
+	habits := []*storage.Habit{{Name: "Read"}, {Name: "Gym"}, {Name: "Walk"}}
+	meta := ui.BuildMeta(habits, writes, to)
+	if meta.Writes != 4 || meta.Sessions != 3 || meta.From != to.AddDays(-14) {
+		t.Fatalf("Unexpected meta: %+v", meta)
+	}
+	if !reflect.DeepEqual(meta.Weeks, []int{0, 0, 0, 0, 0, 2, 0, 1}) {
+		t.Errorf("Unexpected sessions per week: %v", meta.Weeks)
+	}
+	if meta.SameDay != 2 || meta.Late != 1 || meta.AverageDelay != 11.0/4 {
+		t.Errorf("Unexpected delays: %+v", meta)
+	}
+	if math.Abs(meta.PerWeek-3/(15.0/7)) > 0.001 {
+		t.Errorf("Expected 1.4 sessions a week, got %.2f", meta.PerWeek)
+	}
+
+	// Read's day rewritten later stays same day, judged by its first write
+	writes = append(writes, storage.Write{At: at(to.AddDays(-10), 20, 0), Day: to.AddDays(-14), Habit: "Read"})
+	meta = ui.BuildMeta(habits, writes, to)
+	expected := []ui.HabitLogTiming{
+		{Habit: "Read", Entries: 2, SameDay: 1},
+		{Habit: "Gym", Entries: 2, SameDay: 1},
+	}
+	if !reflect.DeepEqual(meta.Habits, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, meta.Habits)
+	}
+	if meta.Habits[0].Backfilled() != 1 || meta.Habits[0].SameDayRate() != 50 {
+		t.Errorf("Unexpected Read timing: %+v", meta.Habits[0])
+	}
+}
+
+func TestJSONReports(t *testing.T) {
+	first := civil.Date{Year: 2025, Month: 1, Day: 1}
+	to := first.AddDays(3)
+	habits := []*storage.Habit{
+		{Name: "Gym", Heading: "Health", Frequency: "1/7", Target: 1, Interval: 7, FirstRecord: first},
+		{Name: "Read", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first},
+		{Name: "Secret", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first, Hidden: true},
+	}
+	entries := storage.Entries{
+		storage.DailyHabit{Day: first, Habit: "Gym"}:             {Result: "y", Amount: 5},
+		storage.DailyHabit{Day: first.AddDays(1), Habit: "Gym"}:  {Result: "n"},
+		storage.DailyHabit{Day: first, Habit: "Read"}:            {Result: "y"},
+		storage.DailyHabit{Day: first.AddDays(1), Habit: "Read"}: {Result: "s", Comment: "travelling"},
+		storage.DailyHabit{Day: first.AddDays(2), Habit: "Read"}: {Result: "n"},
+	}
+
+	display := ui.NewDeterministicDisplay(to, 80)
+	display.Notes = storage.Notes{first.AddDays(1): {"Flew out"}}
+	report := display.BuildLog(habits, &entries, to, 3, "")
+	if report.From != first.String() || report.To != to.String() || len(report.Scores) != 4 {
+		t.Fatalf("Expected 4 days from %s, got %+v", first, report)
+	}
+	if len(report.Habits) != 2 {
+		t.Fatalf("Expected hidden habits left out as in the log, got %+v", report.Habits)
+	}
+	gym, read := report.Habits[0], report.Habits[1]
+	if gym.Days[0].State != graph.StateDone || gym.Days[0].Amount != 5 || gym.Days[1].State != graph.StateSatisfied {
+		t.Errorf("Expected Gym done then satisfied, got %+v", gym.Days)
+	}
+	states := []string{}
+	for _, day := range read.Days {
+		states = append(states, day.State)
+	}
+	if !slices.Equal(states, []string{graph.StateDone, graph.StateSkipped, graph.StateBroken, graph.StateWarning}) {
+		t.Errorf("Expected Read's states in order, got %v", states)
+	}
+	if read.Days[1].Comment != "travelling" || len(report.Notes) != 1 {
+		t.Errorf("Expected comments and notes, got %+v and %+v", read.Days[1], report.Notes)
+	}
+	if _, err := json.Marshal(report); err != nil {
+		t.Errorf("Expected the log report to marshal, got %v", err)
+	}
+
+	stats := ui.BuildStatsReport(habits, &entries, to)
+	if len(stats) != 3 || stats[1].Name != "Read" || stats[1].Streaks != 1 || stats[1].Skips != 1 {
+		t.Errorf("Expected stats for every habit, got %+v", stats)
+	}
+	out, err := ui.IndentJSON(stats[1])
+	if err != nil || !strings.Contains(string(out), `"streaks": 1`) || !strings.Contains(string(out), `"name": "Read"`) {
+		t.Errorf("Expected stats flattened into the habit's object, got %s (%v)", out, err)
+	}
+
+	summary := ui.BuildAskSummary(habits, &entries, to, storage.Snoozed{}, false)
+	if summary.XP != nil || !slices.Equal(summary.Todos, []string{"Read", "Secret"}) {
+		t.Errorf("Expected today's todos and no XP, got %+v", summary)
+	}
+	if summary := ui.BuildAskSummary(habits, &entries, first, storage.Snoozed{}, true); summary.XP == nil || *summary.XP == 0 {
+		t.Errorf("Expected XP earned on the first day, got %+v", summary)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	// A Wednesday, so the calendar starts on the Monday before
+	from := civil.Date{Year: 2025, Month: 1, Day: 1}
+	to := civil.Date{Year: 2025, Month: 2, Day: 9}
+	habit := &storage.Habit{Name: "Run", Frequency: "1", Target: 1, Interval: 1, FirstRecord: from}
+	entries := storage.Entries{
+		storage.DailyHabit{Day: from, Habit: "Run"}:            {Result: "y", Amount: 10},
+		storage.DailyHabit{Day: from.AddDays(1), Habit: "Run"}: {Result: "n"},
+		storage.DailyHabit{Day: from.AddDays(2), Habit: "Run"}: {Result: "s"},
+		storage.DailyHabit{Day: from.AddDays(7), Habit: "Run"}: {Result: "y", Amount: 2},
+	}
+
+	display := ui.NewDeterministicDisplay(to, 80)
+	frame := showFrame(display, func() {
+		display.ShowCalendar(habit, []*storage.Habit{habit}, &entries, from, to, false)
+	})
+	lines := strings.Split(frame, "\n")
+	if lines[0] != "Run, 1, Jan 2025 to Feb 2025" || lines[1] != "    Jan" {
+		t.Errorf("Expected a title and month labels, Feb's with no room, got %q", lines[:2])
+	}
+	// Weeks run down the columns, so Wednesday the 1st opens the third row
+	if !strings.HasPrefix(lines[4], "Wed Y") || !strings.HasPrefix(lines[5], "    N") || !strings.HasPrefix(lines[6], "Fri S") {
+		t.Errorf("Expected days by weekday, got %q", lines[2:9])
+	}
+	if !strings.HasPrefix(lines[2], "Mon  ·") || len([]rune(lines[2])) != 4+6 {
+		t.Errorf("Expected a column per week, blank before the habit started, got %q", lines[2])
+	}
+
+	frame = showFrame(display, func() {
+		display.ShowCalendar(habit, []*storage.Habit{habit}, &entries, from, to, true)
+	})
+	if lines := strings.Split(frame, "\n"); !strings.HasPrefix(lines[4], "Wed █░") {
+		t.Errorf("Expected done days shaded by amount, got %q", lines[4])
+	}
+
+	frame = showFrame(display, func() {
+		display.ShowCalendar(nil, []*storage.Habit{habit}, &entries, from, to, false)
+	})
+	if lines := strings.Split(frame, "\n"); lines[0] != "Daily scores, Jan 2025 to Feb 2025" || !strings.HasPrefix(lines[4], "Wed █") || !strings.HasPrefix(lines[5], "    ·") {
+		t.Errorf("Expected days shaded by score, got %q", lines)
+	}
+
+	// Weeks with notes are marked under the grid, and the notes listed
+	display.Notes = storage.Notes{from.AddDays(8): {"Moved house"}}
+	frame = showFrame(display, func() {
+		display.ShowCalendar(habit, []*storage.Habit{habit}, &entries, from, to, false)
+	})
+	if lines := strings.Split(frame, "\n"); lines[9] != "     ^" || !strings.Contains(frame, "2025-01-09 Moved house") {
+		t.Errorf("Expected the second week marked and its note listed, got %q", lines)
+	}
+}
+
+func TestSortByRisk(t *testing.T) {
+	today := civil.Date{Year: 2025, Month: 1, Day: 10}
+	first := today.AddDays(-30)
+	habits := []*storage.Habit{
+		{Name: "Weight", Frequency: "0", Target: 0, Interval: 0, FirstRecord: first},
+		{Name: "Weekly review", Frequency: "7", Target: 1, Interval: 7, FirstRecord: first},
+		{Name: "Gym", Frequency: "2/7", Target: 2, Interval: 7, FirstRecord: first},
+		{Name: "Read", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first},
+		{Name: "Run", Frequency: "1", Target: 1, Interval: 1, FirstRecord: first},
+	}
+	entries := storage.Entries{
+		{Day: today, Habit: "Read"}:                      {Result: "y"},
+		{Day: today.AddDays(-1), Habit: "Run"}:           {Result: "y"},
+		{Day: today.AddDays(-5), Habit: "Weekly review"}: {Result: "y"},
+		{Day: today.AddDays(-6), Habit: "Gym"}:           {Result: "y"},
+		{Day: today.AddDays(-1), Habit: "Gym"}:           {Result: "y"},
+	}
+
+	names := []string{}
+	for _, habit := range ui.SortByRisk(habits, &entries, today) {
+		names = append(names, habit.Name)
+	}
+	// Run breaks today, Gym tomorrow, and Weekly review the day after, while
+	// Weight never breaks and Read, done today, is safe, so they keep their
+	// habits file order at the end
+	if !reflect.DeepEqual(names, []string{"Run", "Gym", "Weekly review", "Weight", "Read"}) {
+		t.Errorf("Expected habits closest to breaking first, got %v", names)
+	}
+	if habits[0].Name != "Weight" {
+		t.Errorf("Expected the habits given left in file order, got %v", habits)
+	}
+}
+
+func TestBuildLogDiff(t *testing.T) {
+	day := func(month time.Month, d int) civil.Date { return civil.Date{Year: 2025, Month: month, Day: d} }
+	before := storage.Entries{
+		{Day: day(1, 1), Habit: "Gym"}:  {Result: "y"},
+		{Day: day(1, 2), Habit: "Read"}: {Result: "y"},
+	}
+	incoming := storage.Entries{
+		{Day: day(1, 1), Habit: "Gym"}:  {Result: "n"},
+		{Day: day(1, 2), Habit: "Read"}: {Result: "y"},
+		{Day: day(1, 3), Habit: "Gym"}:  {Result: "y"},
+		{Day: day(1, 9), Habit: "Gym"}:  {Result: "n"},
+		{Day: day(2, 1), Habit: "Gym"}:  {Result: "y"},
+		{Day: day(2, 1), Habit: "Run"}:  {Result: "s"},
+	}
+	after := maps.Clone(before)
+	for key, outcome := range incoming {
+		if _, ok := before[key]; !ok {
+			after[key] = outcome
+		}
+	}
+
+	// An import keeps the log's answers, so the export's differing one is
+	// what's dropped
+	diff := ui.BuildLogDiff(before, after, incoming)
+	expected := map[string]map[string]int{"Gym": {"2025-01": 2, "2025-02": 1}, "Run": {"2025-02": 1}}
+	if !reflect.DeepEqual(diff.Added, expected) || diff.AddedCount() != 4 {
+		t.Errorf("Expected %v added, got %v", expected, diff.Added)
+	}
+	conflicts := []ui.LogConflict{{Day: day(1, 1), Habit: "Gym", Kept: "y", Dropped: "n"}}
+	if !reflect.DeepEqual(diff.Conflicts, conflicts) {
+		t.Errorf("Expected %v, got %v", conflicts, diff.Conflicts)
+	}
+
+	// A merge can change the answer, and what was logged before is dropped
+	after[storage.DailyHabit{Day: day(1, 2), Habit: "Read"}] = storage.Outcome{Result: "n"}
+	diff = ui.BuildLogDiff(before, after, nil)
+	conflicts = []ui.LogConflict{{Day: day(1, 2), Habit: "Read", Kept: "n", Dropped: "y"}}
+	if !reflect.DeepEqual(diff.Conflicts, conflicts) {
+		t.Errorf("Expected %v, got %v", conflicts, diff.Conflicts)
+	}
+}
+
+func TestBuildEntries(t *testing.T) {
+	day := civil.Date{Year: 2025, Month: 1, Day: 10}
+	habits := []*storage.Habit{
+		{Name: "Gym", Heading: "Health", Frequency: "3/7", Target: 3, Interval: 7, FirstRecord: day.AddDays(-2)},
+		{Name: "Read", Frequency: "1", Target: 1, Interval: 1, Hidden: true},
+	}
+	entries := storage.Entries{
+		{Day: day, Habit: "Read"}:            {Result: "y", Amount: 30},
+		{Day: day, Habit: "Gym"}:             {Result: "s", Comment: "travelling"},
+		{Day: day.AddDays(-2), Habit: "Gym"}: {Result: "y"},
+		{Day: day.AddDays(1), Habit: "Gym"}:  {Result: "y"},
+	}
+
+	infos := ui.BuildHabits(habits)
+	if len(infos) != 2 || infos[0].FirstRecord != "2025-01-08" || infos[1].FirstRecord != "" || !infos[1].Hidden {
+		t.Errorf("Expected both habits with Gym's first record, got %+v", infos)
+	}
+
+	// In date order, then by habit, and only up to the day asked for
+	expected := []ui.EntryReport{
+		{Date: "2025-01-08", Habit: "Gym", Result: "y"},
+		{Date: "2025-01-10", Habit: "Gym", Result: "s", Comment: "travelling"},
+		{Date: "2025-01-10", Habit: "Read", Result: "y", Amount: 30},
+	}
+	if got := ui.BuildEntries(&entries, "", day.AddDays(-7), day); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+	if got := ui.BuildEntries(&entries, "Read", day.AddDays(-7), day.AddDays(7)); len(got) != 1 || got[0].Habit != "Read" {
+		t.Errorf("Expected only Read's entry, got %+v", got)
+	}
+	if got := ui.BuildEntries(&entries, "", day.AddDays(10), day.AddDays(20)); got == nil || len(got) != 0 {
+		t.Errorf("Expected an empty list, got %+v", got)
+	}
+}